@@ -16,7 +16,7 @@ import (
 )
 
 func main() {
-	mode := flag.String("mode", "", "run mode: api or worker (overrides APP_MODE)")
+	mode := flag.String("mode", "", "run mode: api, worker, smtp, seed, seed-demo, tenant, audit, export or import (overrides APP_MODE)")
 	flag.Parse()
 
 	cfg, err := coreconfig.Load[config.Config]()
@@ -33,7 +33,7 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	if err := app.Run(ctx, cfg); err != nil {
+	if err := app.Run(ctx, cfg, flag.Args()); err != nil {
 		slog.Error("fatal", "error", err)
 		os.Exit(1)
 	}
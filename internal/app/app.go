@@ -2,15 +2,21 @@ package app
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
 
 	"golang.org/x/oauth2"
@@ -28,23 +34,32 @@ import (
 	nightowlmetrics "github.com/wisbric/nightowl/internal/telemetry"
 	"github.com/wisbric/nightowl/pkg/alert"
 	"github.com/wisbric/nightowl/pkg/alertgroup"
+	"github.com/wisbric/nightowl/pkg/analytics"
 	"github.com/wisbric/nightowl/pkg/apikey"
 	"github.com/wisbric/nightowl/pkg/bookowl"
+	"github.com/wisbric/nightowl/pkg/emailingest"
 	"github.com/wisbric/nightowl/pkg/escalation"
+	"github.com/wisbric/nightowl/pkg/events"
+	"github.com/wisbric/nightowl/pkg/heartbeat"
 	"github.com/wisbric/nightowl/pkg/incident"
 	"github.com/wisbric/nightowl/pkg/integration"
+	"github.com/wisbric/nightowl/pkg/mailer"
 	nightowlmm "github.com/wisbric/nightowl/pkg/mattermost"
 	"github.com/wisbric/nightowl/pkg/messaging"
 	"github.com/wisbric/nightowl/pkg/pat"
 	"github.com/wisbric/nightowl/pkg/roster"
 	nightowlslack "github.com/wisbric/nightowl/pkg/slack"
+	"github.com/wisbric/nightowl/pkg/statuspage"
+	"github.com/wisbric/nightowl/pkg/tenant"
 	"github.com/wisbric/nightowl/pkg/tenantconfig"
+	"github.com/wisbric/nightowl/pkg/timeoff"
 	"github.com/wisbric/nightowl/pkg/user"
 )
 
 // Run is the main application entry point. It reads config, connects to
-// infrastructure, and starts the appropriate mode (api or worker).
-func Run(ctx context.Context, cfg *config.Config) error {
+// infrastructure, and starts the appropriate mode (api or worker). args are
+// the positional command-line arguments, used by the tenant mode.
+func Run(ctx context.Context, cfg *config.Config, args []string) error {
 	logger := coretelemetry.NewLogger(cfg.LogFormat, cfg.LogLevel)
 	slog.SetDefault(logger)
 
@@ -97,11 +112,21 @@ func Run(ctx context.Context, cfg *config.Config) error {
 	case "api":
 		return runAPI(ctx, cfg, logger, db, rdb, metricsReg)
 	case "worker":
-		return runWorker(ctx, logger, db, rdb, metricsReg)
+		return runWorker(ctx, cfg, logger, db, rdb, metricsReg)
 	case "seed":
 		return seed.Run(ctx, db, cfg.DatabaseURL, cfg.MigrationsTenantDir, logger, cfg.AdminPassword)
 	case "seed-demo":
 		return seed.RunDemo(ctx, db, cfg.DatabaseURL, cfg.MigrationsTenantDir, logger, cfg.AdminPassword)
+	case "tenant":
+		return runTenantCLI(ctx, cfg, logger, db, args, os.Stdout)
+	case "export":
+		return runTenantExport(ctx, cfg, logger, db, args, os.Stdout)
+	case "import":
+		return runTenantImport(ctx, cfg, logger, db, args, os.Stdout)
+	case "smtp":
+		return runSMTP(ctx, cfg, logger, db, rdb)
+	case "audit":
+		return runAuditCLI(ctx, cfg, logger, db, args, os.Stdout)
 	default:
 		return fmt.Errorf("unknown mode: %s", cfg.Mode)
 	}
@@ -145,14 +170,18 @@ func runAPI(ctx context.Context, cfg *config.Config, logger *slog.Logger, db *pg
 	// PAT authenticator.
 	patAuth := auth.NewPATAuthenticator(authStore)
 
+	// Live events for the event stream: audited changes, alert grouping.
+	eventPublisher := events.NewPublisher(rdb, logger)
+	go eventPublisher.Run(ctx)
+
 	// Audit log writer (async, buffered).
-	auditWriter := audit.NewWriter(db, logger)
-	auditWriter.Start(ctx)
+	auditWriter := newAuditWriter(ctx, cfg, logger, db, eventPublisher)
 	defer auditWriter.Close()
 
 	srv := httpserver.NewServer(httpserver.ServerConfig{
 		CORSAllowedOrigins: cfg.CORSAllowedOrigins,
 		DevMode:            cfg.DevMode,
+		TenantLookup:       tenant.NewLookup(db),
 	}, logger, db, rdb, metricsReg, sessionMgr, oidcAuth, patAuth, authStore)
 
 	// --- Auth routes (public, pre-authentication) ---
@@ -215,62 +244,136 @@ func runAPI(ctx context.Context, cfg *config.Config, logger *slog.Logger, db *pg
 		logger.Info("OIDC Authorization Code flow enabled", "redirect_url", cfg.OIDCRedirectURL)
 	}
 
+	// Platform tenant management (super-admin token, outside tenant scope).
+	if cfg.PlatformAdminToken != "" {
+		grace, err := tenantDeletionGrace(cfg)
+		if err != nil {
+			return err
+		}
+		platformHandler := tenant.NewHandler(tenant.NewService(newProvisioner(cfg, db, logger), logger), logger, cfg.PlatformAdminToken, grace)
+		srv.Router.Mount("/api/v1/platform/tenants", platformHandler.Routes())
+		logger.Info("platform tenant API enabled")
+	}
+
 	// Public status endpoint (no auth required — used by about page).
 	srv.Router.Get("/status", srv.HandleStatus)
 
 	// Authenticated status endpoint (backward compat).
 	srv.APIRouter.Get("/status", srv.HandleStatus)
 
+	// API key scopes and source-IP allowlists apply to every domain route.
+	trustedProxies, err := apikey.ParseCIDRs(cfg.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("parsing NIGHTOWL_TRUSTED_PROXIES: %w", err)
+	}
+	keyScopes := apikey.NewEnforcer(db, logger, trustedProxies)
+	scoped := func(resource string) chi.Router {
+		return srv.APIRouter.With(keyScopes.Require(resource))
+	}
+
 	// Mount domain handlers.
 	incidentHandler := incident.NewHandler(logger, auditWriter)
-	srv.APIRouter.Mount("/incidents", incidentHandler.Routes())
+	scoped(apikey.ResourceIncidents).Mount("/incidents", incidentHandler.Routes())
 
 	alertHandler := alert.NewHandler(logger, auditWriter)
-	srv.APIRouter.Mount("/alerts", alertHandler.Routes())
+	scoped(apikey.ResourceAlerts).Mount("/alerts", alertHandler.Routes())
 
-	dedup := alert.NewDeduplicator(rdb, logger, nightowlmetrics.AlertsDeduplicatedTotal)
-	enricher := alert.NewEnricher(logger, bookowl.NewClient())
-	webhookMetrics := &alert.WebhookMetrics{
-		ReceivedTotal:      nightowlmetrics.AlertsReceivedTotal,
-		ProcessingDuration: nightowlmetrics.AlertProcessingDuration,
-		KBHitsTotal:        nightowlmetrics.KBHitsTotal,
-		AgentResolvedTotal: nightowlmetrics.AlertsAgentResolvedTotal,
-	}
-	cfgSvc := tenantconfig.NewService(db, logger)
 	grouper := alertgroup.NewEvaluator(logger)
-	webhookHandler := alert.NewWebhookHandler(logger, auditWriter, dedup, enricher, webhookMetrics, cfgSvc, grouper)
-	srv.APIRouter.Mount("/webhooks", webhookHandler.Routes())
+	grouper.Events = eventPublisher
+	webhookHandler := newWebhookHandler(logger, db, rdb, auditWriter, grouper)
+	scoped(apikey.ResourceWebhooks).Mount("/webhooks", webhookHandler.Routes())
+
+	// Webhooks only queue payloads; every API instance processes queued
+	// payloads in the background, picking up others' leftovers and retries.
+	ingestProcessor := alert.NewProcessor(db, webhookHandler, logger)
+	webhookHandler.OnQueued = ingestProcessor.Wake
+	go ingestProcessor.Run(ctx)
+	queueHandler := alert.NewQueueHandler(logger, auditWriter)
+	queueHandler.OnQueued = ingestProcessor.Wake
+	scoped(apikey.ResourceAdmin).Mount("/ingest-queue", queueHandler.Routes())
+	archiveHandler := alert.NewArchiveHandler(logger, grouper)
+	scoped(apikey.ResourceAdmin).Mount("/webhook-payloads", archiveHandler.Routes())
+
+	// Named integrations: managed by admins, and each ingests on its own
+	// token-authenticated URL outside the API-key protected router.
+	integrationHandler := alert.NewIntegrationHandler(logger, auditWriter)
+	integrationHandler.EmailDomain = cfg.SMTPDomain
+	scoped(apikey.ResourceAdmin).Mount("/integrations", integrationHandler.Routes())
+	srv.Router.Route("/api/v1/ingest/{tenant}", func(r chi.Router) {
+		r.Use(tenant.Middleware(db, tenant.PathResolver{Param: "tenant"}, logger))
+		r.Mount("/", webhookHandler.IngestRoutes())
+	})
 
-	rosterHandler := roster.NewHandler(logger, auditWriter)
-	srv.APIRouter.Mount("/rosters", rosterHandler.Routes())
+	// Heartbeat monitors: managed by admins, and each pinged on its own
+	// token-authenticated URL. The worker raises alerts for missed pings.
+	heartbeatHandler := heartbeat.NewHandler(logger, auditWriter)
+	heartbeatHandler.Alerts = webhookHandler
+	scoped(apikey.ResourceAdmin).Mount("/heartbeats", heartbeatHandler.Routes())
+	srv.Router.Route("/api/v1/heartbeat/{tenant}", func(r chi.Router) {
+		r.Use(tenant.Middleware(db, tenant.PathResolver{Param: "tenant"}, logger))
+		r.Mount("/", heartbeatHandler.PingRoutes())
+	})
+
+	// Status pages: managed through the API, and public pages served with
+	// their feeds and subscription links below the tenant. Subscription
+	// attempts are limited to 5 per client IP and 5 per email address per
+	// hour; the worker mails incident updates.
+	statusKey, err := statusTokenKey(cfg)
+	if err != nil {
+		return err
+	}
+	statusHandler := statuspage.NewHandler(logger, auditWriter, cfg.PublicURL, statusKey)
+	statusHandler.Mailer = newMailer(cfg)
+	statusHandler.Limiter = auth.NewRateLimiter(rdb, 5, time.Hour)
+	statusHandler.TrustedProxies = trustedProxies
+	scoped(apikey.ResourceIncidents).Mount("/status-pages", statusHandler.Routes())
+	srv.Router.Route("/status/{tenant}", func(r chi.Router) {
+		r.Use(tenant.Middleware(db, tenant.PathResolver{Param: "tenant"}, logger))
+		r.Mount("/", statusHandler.PublicRoutes())
+	})
+
+	// Messaging providers register below; handlers that notify users hold
+	// the registry and see every provider registered before serving.
+	msgRegistry := messaging.NewRegistry()
+	swapNotifier := roster.NewSwapNotifier(msgRegistry, logger)
+
+	rosterHandler := roster.NewHandler(logger, auditWriter, swapNotifier)
+	scoped(apikey.ResourceRosters).Mount("/rosters", rosterHandler.Routes())
+
+	timeoffHandler := timeoff.NewHandler(logger, auditWriter)
+	scoped(apikey.ResourceRosters).Mount("/timeoff", timeoffHandler.Routes())
 
 	escalationHandler := escalation.NewHandler(logger, auditWriter)
-	srv.APIRouter.Mount("/escalation-policies", escalationHandler.Routes())
+	scoped(apikey.ResourceEscalations).Mount("/escalation-policies", escalationHandler.Routes())
+
+	analyticsHandler := analytics.NewHandler(logger)
+	scoped(apikey.ResourceAlerts).Mount("/analytics", analyticsHandler.Routes())
 
 	alertGroupHandler := alertgroup.NewHandler(logger, auditWriter, grouper)
-	srv.APIRouter.Mount("/alert-groups", alertGroupHandler.Routes())
+	scoped(apikey.ResourceAlerts).Mount("/alert-groups", alertGroupHandler.Routes())
 
 	twilioHandler := integration.NewTwilioHandler(logger)
-	srv.APIRouter.Mount("/twilio", twilioHandler.Routes())
+	scoped(apikey.ResourceWebhooks).Mount("/twilio", twilioHandler.Routes())
 
-	userHandler := user.NewHandler(logger, auditWriter)
-	srv.APIRouter.Mount("/users", userHandler.Routes())
-	srv.APIRouter.Mount("/user/preferences", userHandler.PreferencesRoutes())
+	userHandler := user.NewHandler(logger, auditWriter, msgRegistry)
+	scoped(apikey.ResourceUsers).Mount("/users", userHandler.Routes())
+	scoped(apikey.ResourceUsers).Mount("/user/preferences", userHandler.PreferencesRoutes())
+	scoped(apikey.ResourceUsers).Mount("/user/chat-links", userHandler.ChatLinkRoutes())
 
 	apikeyHandler := apikey.NewHandler(logger, auditWriter, db)
-	srv.APIRouter.Mount("/api-keys", apikeyHandler.Routes())
+	scoped(apikey.ResourceAdmin).Mount("/api-keys", apikeyHandler.Routes())
 
 	patHandler := pat.NewHandler(logger)
-	srv.APIRouter.Mount("/user/tokens", patHandler.Routes())
+	scoped(apikey.ResourceAdmin).Mount("/user/tokens", patHandler.Routes())
 
-	auditHandler := audit.NewHandler(logger)
-	srv.APIRouter.Mount("/audit-log", auditHandler.Routes())
+	auditHandler := audit.NewHandler(logger, auditWriter)
+	scoped(apikey.ResourceAudit).Mount("/audit-log", auditHandler.Routes())
 
 	bookowlHandler := bookowl.NewHandler(logger, db)
-	srv.APIRouter.Mount("/bookowl", bookowlHandler.Routes())
+	scoped(apikey.ResourceIncidents).Mount("/bookowl", bookowlHandler.Routes())
 
 	tenantConfigHandler := tenantconfig.NewHandler(logger, auditWriter, db)
-	srv.APIRouter.Mount("/admin/config", tenantConfigHandler.Routes())
+	scoped(apikey.ResourceAdmin).Mount("/admin/config", tenantConfigHandler.Routes())
 
 	// OIDC admin config endpoints (admin role required).
 	oidcAdminHandler := auth.NewOIDCAdminHandler(authStore, logger, sessionSecret)
@@ -279,23 +382,24 @@ func runAPI(ctx context.Context, cfg *config.Config, logger *slog.Logger, db *pg
 		ClientID:  cfg.OIDCClientID,
 	})
 	srv.APIRouter.Route("/admin/oidc", func(r chi.Router) {
+		r.Use(keyScopes.Require(apikey.ResourceAdmin))
 		r.Use(auth.RequireRole(auth.RoleAdmin))
 		r.Get("/config", oidcAdminHandler.HandleGetOIDCConfig)
 		r.Put("/config", oidcAdminHandler.HandleUpdateOIDCConfig)
 		r.Post("/test", oidcAdminHandler.HandleTestOIDCConnection)
 	})
 	srv.APIRouter.Route("/admin/local-admin", func(r chi.Router) {
+		r.Use(keyScopes.Require(apikey.ResourceAdmin))
 		r.Use(auth.RequireRole(auth.RoleAdmin))
 		r.Post("/reset", oidcAdminHandler.HandleResetLocalAdmin)
 	})
 
 	// --- Messaging provider registry ---
-	msgRegistry := messaging.NewRegistry()
 
 	// Slack provider + routes.
 	slackNotifier := nightowlslack.NewNotifier(cfg.SlackBotToken, cfg.SlackAlertChannel, logger)
 	slackProvider := nightowlslack.NewProvider(slackNotifier, logger)
-	slackHandler := nightowlslack.NewHandler(slackNotifier, db, logger, cfg.SlackSigningSecret, "devco")
+	slackHandler := nightowlslack.NewHandler(slackNotifier, db, logger, cfg.SlackSigningSecret, "devco", auditWriter, swapNotifier)
 	srv.Router.Mount("/api/v1/slack", slackHandler.Routes())
 
 	if slackNotifier.IsEnabled() {
@@ -310,7 +414,7 @@ func runAPI(ctx context.Context, cfg *config.Config, logger *slog.Logger, db *pg
 		mmClient := nightowlmm.NewClient(cfg.MattermostURL, cfg.MattermostBotToken, logger)
 		actionURL := fmt.Sprintf("http://%s/api/v1/mattermost/actions", cfg.ListenAddr())
 		mmProvider := nightowlmm.NewProvider(mmClient, cfg.MattermostDefaultChannelID, actionURL, logger)
-		mmHandler := nightowlmm.NewHandler(mmProvider, db, logger, cfg.MattermostWebhookSecret, "devco")
+		mmHandler := nightowlmm.NewHandler(mmProvider, db, logger, cfg.MattermostWebhookSecret, "devco", auditWriter, swapNotifier)
 		srv.Router.Mount("/api/v1/mattermost", mmHandler.Routes())
 		msgRegistry.Register(mmProvider)
 		logger.Info("mattermost integration enabled", "url", cfg.MattermostURL)
@@ -321,13 +425,27 @@ func runAPI(ctx context.Context, cfg *config.Config, logger *slog.Logger, db *pg
 	// Store registry for use by test connection endpoint.
 	_ = msgRegistry
 
+	// The event stream is served beside srv.Router: its response wrapper
+	// cannot flush, and its tenant middleware would pin a database
+	// connection for as long as each client stays connected.
+	streamRouter := chi.NewRouter()
+	streamRouter.Use(auth.Middleware(sessionMgr, oidcAuth, patAuth, authStore, logger, cfg.DevMode))
+	streamRouter.Use(auth.RequireAuth)
+	streamRouter.Use(keyScopes.Require(apikey.ResourceEvents))
+	streamHandler := events.NewHandler(rdb, tenant.NewLookup(db), logger)
+	streamRouter.Handle("/api/v1/events", streamHandler)
+	root := http.NewServeMux()
+	root.Handle("/api/v1/events", streamRouter)
+	root.Handle("/", srv)
+
 	httpSrv := &http.Server{
 		Addr:         cfg.ListenAddr(),
-		Handler:      srv,
+		Handler:      root,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
+	httpSrv.RegisterOnShutdown(streamHandler.Shutdown)
 
 	errCh := make(chan error, 1)
 	go func() {
@@ -349,12 +467,178 @@ func runAPI(ctx context.Context, cfg *config.Config, logger *slog.Logger, db *pg
 	}
 }
 
-func runWorker(ctx context.Context, logger *slog.Logger, pool *pgxpool.Pool, rdb *redis.Client, metricsReg *prometheus.Registry) error {
+func runWorker(ctx context.Context, cfg *config.Config, logger *slog.Logger, pool *pgxpool.Pool, rdb *redis.Client, metricsReg *prometheus.Registry) error {
 	logger.Info("worker started")
 
+	go serveWorkerMetrics(ctx, cfg, logger, metricsReg)
+
+	// Purge tenants whose deletion grace period has ended.
+	go tenant.RunPurgeLoop(ctx, tenant.NewService(newProvisioner(cfg, pool, logger), logger), logger, time.Hour)
+
 	// Schedule top-up: runs once at start, then every 6 hours.
 	go roster.RunScheduleTopUpLoop(ctx, pool, logger, 6*time.Hour)
 
+	// Forward audit entries to each tenant's sinks.
+	go audit.NewForwarder(pool, logger, &audit.ForwarderMetrics{
+		Lag:       nightowlmetrics.AuditForwardLag,
+		Forwarded: nightowlmetrics.AuditForwardedTotal,
+		Failures:  nightowlmetrics.AuditForwardFailuresTotal,
+	}).Run(ctx)
+
+	// Weekly noise reports, checked hourly so each tenant's lands soon after
+	// Monday midnight in its timezone.
+	workerMessaging := newWorkerMessaging(cfg, logger)
+	go analytics.NewNoiseReporter(pool, workerMessaging, logger).Run(ctx, time.Hour)
+
+	eventPublisher := events.NewPublisher(rdb, logger)
+	go eventPublisher.Run(ctx)
+
+	// Missed heartbeats raise alerts through the same pipeline as webhooks.
+	auditWriter := newAuditWriter(ctx, cfg, logger, pool, eventPublisher)
+	defer auditWriter.Close()
+	grouper := alertgroup.NewEvaluator(logger)
+	grouper.Events = eventPublisher
+	heartbeatChecker := heartbeat.NewChecker(pool, newWebhookHandler(logger, pool, rdb, auditWriter, grouper), workerMessaging, logger)
+	heartbeatChecker.Missed = nightowlmetrics.HeartbeatsMissedTotal
+	go heartbeatChecker.Run(ctx, 30*time.Second)
+
+	// Incident updates posted on status pages are mailed to subscribers.
+	if mail := newMailer(cfg); mail != nil {
+		statusKey, err := statusTokenKey(cfg)
+		if err != nil {
+			return err
+		}
+		go statuspage.NewNotifier(pool, mail, cfg.PublicURL, statusKey, logger).Run(ctx, 30*time.Second)
+	}
+
 	engine := escalation.NewEngine(pool, rdb, logger, nightowlmetrics.AlertsEscalatedTotal)
+	engine.Events = eventPublisher
 	return engine.Run(ctx)
 }
+
+// newWorkerMessaging registers the messaging providers the worker posts
+// through. The worker serves no chat callbacks, so only outbound clients
+// are set up.
+func newWorkerMessaging(cfg *config.Config, logger *slog.Logger) *messaging.Registry {
+	registry := messaging.NewRegistry()
+	slackNotifier := nightowlslack.NewNotifier(cfg.SlackBotToken, cfg.SlackAlertChannel, logger)
+	if slackNotifier.IsEnabled() {
+		registry.Register(nightowlslack.NewProvider(slackNotifier, logger))
+	}
+	if cfg.MattermostURL != "" && cfg.MattermostBotToken != "" {
+		mmClient := nightowlmm.NewClient(cfg.MattermostURL, cfg.MattermostBotToken, logger)
+		actionURL := fmt.Sprintf("http://%s/api/v1/mattermost/actions", cfg.ListenAddr())
+		registry.Register(nightowlmm.NewProvider(mmClient, cfg.MattermostDefaultChannelID, actionURL, logger))
+	}
+	return registry
+}
+
+// newMailer returns the outbound mail sender, or nil when
+// NIGHTOWL_MAIL_SMTP_ADDR is not set.
+func newMailer(cfg *config.Config) mailer.Sender {
+	if cfg.MailSMTPAddr == "" {
+		return nil
+	}
+	return mailer.NewSMTP(cfg.MailSMTPAddr, cfg.MailFrom, cfg.MailUsername, cfg.MailPassword)
+}
+
+// statusTokenKey returns the key signing status page subscriber links,
+// derived from NIGHTOWL_SESSION_SECRET so that the API and the worker agree
+// on it. In dev mode without a secret a fixed key is used.
+func statusTokenKey(cfg *config.Config) ([]byte, error) {
+	secret := cfg.SessionSecret
+	if secret == "" {
+		if !cfg.DevMode {
+			return nil, fmt.Errorf("missing NIGHTOWL_SESSION_SECRET (required when DEV_MODE=false)")
+		}
+		secret = "nightowl-dev-secret"
+	}
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte("status page subscriber tokens"))
+	return m.Sum(nil), nil
+}
+
+// serveWorkerMetrics exposes the worker's metrics, such as escalations and
+// audit forwarding lag, on the listen address until ctx is cancelled.
+func serveWorkerMetrics(ctx context.Context, cfg *config.Config, logger *slog.Logger, reg *prometheus.Registry) {
+	mux := http.NewServeMux()
+	mux.Handle(cfg.MetricsPath, promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	srv := &http.Server{Addr: cfg.ListenAddr(), Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	logger.Info("worker metrics listening", "addr", srv.Addr, "path", cfg.MetricsPath)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("worker metrics server", "error", err)
+	}
+}
+
+// newAuditWriter starts an audit writer, spilling to a per-mode file when
+// NIGHTOWL_AUDIT_SPILL_DIR is set. Audited changes are also published as
+// live events.
+func newAuditWriter(ctx context.Context, cfg *config.Config, logger *slog.Logger, db *pgxpool.Pool, publisher *events.Publisher) *audit.Writer {
+	w := audit.NewWriter(db, logger)
+	w.Notify = publisher.PublishAudit
+	if cfg.AuditSpillDir != "" {
+		w.SpillPath = filepath.Join(cfg.AuditSpillDir, "audit-"+cfg.Mode+".jsonl")
+	}
+	w.Start(ctx)
+	return w
+}
+
+// newWebhookHandler wires the alert ingest pipeline: deduplication,
+// grouping, knowledge base enrichment and metrics.
+func newWebhookHandler(logger *slog.Logger, db *pgxpool.Pool, rdb *redis.Client, auditWriter *audit.Writer, grouper *alertgroup.Evaluator) *alert.WebhookHandler {
+	dedup := alert.NewDeduplicator(rdb, logger, nightowlmetrics.AlertsDeduplicatedTotal)
+	enricher := alert.NewEnricher(logger, bookowl.NewClient())
+	webhookMetrics := &alert.WebhookMetrics{
+		ReceivedTotal:      nightowlmetrics.AlertsReceivedTotal,
+		ProcessingDuration: nightowlmetrics.AlertProcessingDuration,
+		KBHitsTotal:        nightowlmetrics.KBHitsTotal,
+		AgentResolvedTotal: nightowlmetrics.AlertsAgentResolvedTotal,
+		QueuedTotal:        nightowlmetrics.IngestQueuedTotal,
+		RetriesTotal:       nightowlmetrics.IngestRetriesTotal,
+		DeadLetteredTotal:  nightowlmetrics.IngestDeadLetteredTotal,
+		QueueLatency:       nightowlmetrics.IngestQueueLatency,
+	}
+	cfgSvc := tenantconfig.NewService(db, logger)
+	return alert.NewWebhookHandler(logger, auditWriter, dedup, enricher, webhookMetrics, cfgSvc, grouper)
+}
+
+// runSMTP runs the SMTP listener that ingests email for email integrations.
+// Alerts go through the same pipeline as webhooks; escalation is picked up
+// by the worker as usual.
+func runSMTP(ctx context.Context, cfg *config.Config, logger *slog.Logger, db *pgxpool.Pool, rdb *redis.Client) error {
+	if cfg.SMTPDomain == "" {
+		return fmt.Errorf("missing NIGHTOWL_SMTP_DOMAIN (required in smtp mode)")
+	}
+
+	var tlsConfig *tls.Config
+	if cfg.SMTPTLSCertFile != "" || cfg.SMTPTLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.SMTPTLSCertFile, cfg.SMTPTLSKeyFile)
+		if err != nil {
+			return fmt.Errorf("loading SMTP TLS certificate: %w", err)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+
+	eventPublisher := events.NewPublisher(rdb, logger)
+	go eventPublisher.Run(ctx)
+
+	auditWriter := newAuditWriter(ctx, cfg, logger, db, eventPublisher)
+	defer auditWriter.Close()
+
+	grouper := alertgroup.NewEvaluator(logger)
+	grouper.Events = eventPublisher
+	webhookHandler := newWebhookHandler(logger, db, rdb, auditWriter, grouper)
+	srv := emailingest.NewServer(emailingest.Config{
+		Addr:            cfg.SMTPListenAddr,
+		Hostname:        cfg.SMTPDomain,
+		TLSConfig:       tlsConfig,
+		MaxMessageBytes: cfg.SMTPMaxMessageBytes,
+	}, emailingest.NewPipeline(db, webhookHandler, cfg.SMTPDomain), logger)
+	return srv.ListenAndServe(ctx)
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/wisbric/nightowl/internal/audit"
+	"github.com/wisbric/nightowl/internal/config"
+	"github.com/wisbric/nightowl/pkg/tenant"
+)
+
+const auditUsage = `usage: nightowl -mode audit <command> [args]
+
+commands:
+  verify [slug...]              verify the audit hash chain of the given tenants, or of all tenants`
+
+// errAuditUsage is returned for malformed audit CLI invocations.
+var errAuditUsage = errors.New(auditUsage)
+
+// runAuditCLI runs one audit log command and writes its result to out.
+func runAuditCLI(ctx context.Context, cfg *config.Config, logger *slog.Logger, db *pgxpool.Pool, args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] != "verify" {
+		return errAuditUsage
+	}
+	svc := tenant.NewService(newProvisioner(cfg, db, logger), logger)
+	slugs := args[1:]
+	if len(slugs) == 0 {
+		items, err := svc.List(ctx)
+		if err != nil {
+			return err
+		}
+		for _, t := range items {
+			slugs = append(slugs, t.Slug)
+		}
+	}
+
+	failed := 0
+	for _, slug := range slugs {
+		res, err := verifyTenantAudit(ctx, svc, db, slug)
+		if err != nil {
+			failed++
+			_, _ = fmt.Fprintf(out, "%s: ERROR: %v\n", slug, err)
+			continue
+		}
+		printVerifyResult(out, slug, res)
+		if !res.Valid {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tenant audit logs failed verification", failed, len(slugs))
+	}
+	return nil
+}
+
+// verifyTenantAudit verifies one tenant's chain regardless of its status,
+// so suspended tenants can be audited too.
+func verifyTenantAudit(ctx context.Context, svc *tenant.Service, db *pgxpool.Pool, slug string) (audit.VerifyResult, error) {
+	if _, err := svc.Get(ctx, slug); err != nil {
+		return audit.VerifyResult{}, err
+	}
+	conn, err := db.Acquire(ctx)
+	if err != nil {
+		return audit.VerifyResult{}, fmt.Errorf("acquiring connection: %w", err)
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, "SELECT set_config('search_path', $1, false)", tenant.SchemaName(slug)+", public"); err != nil {
+		return audit.VerifyResult{}, fmt.Errorf("setting search_path: %w", err)
+	}
+	return audit.Verify(ctx, conn)
+}
+
+func printVerifyResult(out io.Writer, slug string, res audit.VerifyResult) {
+	status := "OK"
+	if !res.Valid {
+		status = fmt.Sprintf("FAILED: %d problems", res.ProblemCount)
+	}
+	_, _ = fmt.Fprintf(out, "%s: %s (%d chained, %d unchained entries; head %d %s)\n",
+		slug, status, res.Entries, res.Unchained, res.HeadSeq, res.HeadHash)
+	for _, p := range res.Problems {
+		id := ""
+		if p.ID != nil {
+			id = " " + p.ID.String()
+		}
+		_, _ = fmt.Fprintf(out, "  %-12s seq %d%s: %s\n", p.Kind, p.Seq, id, p.Detail)
+	}
+	if n := res.ProblemCount - len(res.Problems); n > 0 {
+		_, _ = fmt.Fprintf(out, "  ... and %d more\n", n)
+	}
+}
@@ -0,0 +1,307 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/wisbric/nightowl/internal/config"
+	"github.com/wisbric/nightowl/pkg/tenant"
+)
+
+const tenantUsage = `usage: nightowl -mode tenant <command> [args]
+
+commands:
+  list                          list tenants with status and migration version
+  get <slug>                    show one tenant
+  create [-config JSON] <slug> <name>
+                                provision a tenant schema and run its migrations
+  rename <slug> <name>          change a tenant's display name
+  suspend <slug>                block API access and background processing
+  resume <slug>                 reactivate a suspended tenant
+  delete [-grace DURATION] <slug>
+                                soft-delete; the schema is purged after the grace period
+  restore <slug>                cancel a pending deletion
+  purge                         drop tenants whose grace period has ended
+  migrate <slug>                run pending tenant migrations for one tenant
+  migrate-all                   run pending tenant migrations for every tenant`
+
+// errTenantUsage is returned for malformed tenant CLI invocations.
+var errTenantUsage = errors.New(tenantUsage)
+
+func newProvisioner(cfg *config.Config, db *pgxpool.Pool, logger *slog.Logger) *tenant.Provisioner {
+	return &tenant.Provisioner{
+		DB:            db,
+		DatabaseURL:   cfg.DatabaseURL,
+		MigrationsDir: cfg.MigrationsTenantDir,
+		Logger:        logger,
+	}
+}
+
+func tenantDeletionGrace(cfg *config.Config) (time.Duration, error) {
+	grace, err := time.ParseDuration(cfg.TenantDeletionGrace)
+	if err != nil {
+		return 0, fmt.Errorf("parsing tenant deletion grace %q: %w", cfg.TenantDeletionGrace, err)
+	}
+	if grace < tenant.MinDeletionGrace {
+		return 0, tenant.ErrGraceTooShort
+	}
+	return grace, nil
+}
+
+// runTenantCLI runs one tenant lifecycle command and writes its result to out.
+func runTenantCLI(ctx context.Context, cfg *config.Config, logger *slog.Logger, db *pgxpool.Pool, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errTenantUsage
+	}
+	svc := tenant.NewService(newProvisioner(cfg, db, logger), logger)
+	cmd, args := args[0], args[1:]
+
+	switch cmd {
+	case "list":
+		items, err := svc.List(ctx)
+		if err != nil {
+			return err
+		}
+		printTenants(out, items)
+		return nil
+
+	case "get":
+		slug, err := oneArg(args)
+		if err != nil {
+			return err
+		}
+		resp, err := svc.Get(ctx, slug)
+		if err != nil {
+			return err
+		}
+		printTenants(out, []tenant.Response{resp})
+		return nil
+
+	case "create":
+		fs := flag.NewFlagSet("create", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		cfgJSON := fs.String("config", "", "tenant config JSON")
+		if err := fs.Parse(args); err != nil || fs.NArg() < 2 {
+			return errTenantUsage
+		}
+		var raw json.RawMessage
+		if *cfgJSON != "" {
+			if !json.Valid([]byte(*cfgJSON)) {
+				return fmt.Errorf("-config is not valid JSON")
+			}
+			raw = json.RawMessage(*cfgJSON)
+		}
+		resp, err := svc.Create(ctx, strings.Join(fs.Args()[1:], " "), fs.Arg(0), raw)
+		if err != nil {
+			return err
+		}
+		printTenants(out, []tenant.Response{resp})
+		return nil
+
+	case "rename":
+		if len(args) < 2 {
+			return errTenantUsage
+		}
+		resp, err := svc.Rename(ctx, args[0], strings.Join(args[1:], " "))
+		if err != nil {
+			return err
+		}
+		printTenants(out, []tenant.Response{resp})
+		return nil
+
+	case "suspend", "resume", "restore":
+		slug, err := oneArg(args)
+		if err != nil {
+			return err
+		}
+		change := map[string]func(context.Context, string) (tenant.Response, error){
+			"suspend": svc.Suspend,
+			"resume":  svc.Resume,
+			"restore": svc.Restore,
+		}[cmd]
+		resp, err := change(ctx, slug)
+		if err != nil {
+			return err
+		}
+		printTenants(out, []tenant.Response{resp})
+		return nil
+
+	case "delete":
+		defaultGrace, err := tenantDeletionGrace(cfg)
+		if err != nil {
+			return err
+		}
+		fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		grace := fs.Duration("grace", defaultGrace, "soft-delete grace period")
+		if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+			return errTenantUsage
+		}
+		resp, err := svc.Delete(ctx, fs.Arg(0), *grace)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(out, "tenant %s scheduled for deletion; purge after %s\n",
+			resp.Slug, resp.PurgeAfter.Format(time.RFC3339))
+		return nil
+
+	case "purge":
+		purged, err := svc.Purge(ctx)
+		for _, slug := range purged {
+			_, _ = fmt.Fprintf(out, "purged %s\n", slug)
+		}
+		if err == nil && len(purged) == 0 {
+			_, _ = fmt.Fprintln(out, "no tenants past their deletion grace period")
+		}
+		return err
+
+	case "migrate":
+		slug, err := oneArg(args)
+		if err != nil {
+			return err
+		}
+		res, err := svc.Migrate(ctx, slug)
+		if err != nil {
+			return err
+		}
+		return printMigrateResults(out, []tenant.MigrateResult{res})
+
+	case "migrate-all":
+		results, err := svc.MigrateAll(ctx)
+		if err != nil {
+			return err
+		}
+		return printMigrateResults(out, results)
+	}
+	return errTenantUsage
+}
+
+func oneArg(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", errTenantUsage
+	}
+	return args[0], nil
+}
+
+func printTenants(out io.Writer, items []tenant.Response) {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "SLUG\tNAME\tSTATUS\tVERSION\tLATEST\tNOTE")
+	for _, t := range items {
+		version, latest, note := "-", "-", ""
+		if m := t.Migration; m != nil {
+			version, latest = fmt.Sprint(m.Version), fmt.Sprint(m.Latest)
+			switch {
+			case m.Dirty:
+				note = "dirty"
+			case m.Pending:
+				note = "migrations pending"
+			}
+		}
+		if t.PurgeAfter != nil {
+			note = "purge after " + t.PurgeAfter.Format(time.RFC3339)
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", t.Slug, t.Name, t.Status, version, latest, note)
+	}
+	_ = tw.Flush()
+}
+
+func printMigrateResults(out io.Writer, results []tenant.MigrateResult) error {
+	failed := 0
+	for _, res := range results {
+		if res.Error != "" {
+			failed++
+			_, _ = fmt.Fprintf(out, "%s: FAILED: %s\n", res.Slug, res.Error)
+			continue
+		}
+		if res.Migration != nil {
+			_, _ = fmt.Fprintf(out, "%s: at version %d\n", res.Slug, res.Migration.Version)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tenant migrations failed", failed, len(results))
+	}
+	return nil
+}
+
+const exportUsage = `usage: nightowl -mode export [-alerts] <slug> <file|->`
+
+const importUsage = `usage: nightowl -mode import [-slug SLUG] [-name NAME] <file|->`
+
+// runTenantExport writes one tenant's data to a versioned archive.
+func runTenantExport(ctx context.Context, cfg *config.Config, logger *slog.Logger, db *pgxpool.Pool, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	alerts := fs.Bool("alerts", false, "include alerts, alert groups and escalation events")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 2 {
+		return errors.New(exportUsage)
+	}
+	slug, file := fs.Arg(0), fs.Arg(1)
+
+	svc := tenant.NewService(newProvisioner(cfg, db, logger), logger)
+	archive, err := svc.Export(ctx, slug, *alerts)
+	if err != nil {
+		return err
+	}
+
+	out, summary := stdout, io.Writer(os.Stderr)
+	if file != "-" {
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err != nil {
+			return fmt.Errorf("creating archive: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		out, summary = f, stdout
+	}
+	if err := tenant.WriteArchive(out, archive); err != nil {
+		return err
+	}
+	for _, t := range archive.Manifest.Tables {
+		_, _ = fmt.Fprintf(summary, "%-26s %d rows\n", t.Name, t.Rows)
+	}
+	_, _ = fmt.Fprintf(summary, "exported %s at migration version %d\n", slug, archive.Manifest.MigrationVersion)
+	return nil
+}
+
+// runTenantImport provisions a new tenant from an archive.
+func runTenantImport(ctx context.Context, cfg *config.Config, logger *slog.Logger, db *pgxpool.Pool, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	slug := fs.String("slug", "", "slug for the new tenant (default: the exported slug)")
+	name := fs.String("name", "", "name for the new tenant (default: the exported name)")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errors.New(importUsage)
+	}
+
+	in := io.Reader(os.Stdin)
+	if file := fs.Arg(0); file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return fmt.Errorf("opening archive: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		in = f
+	}
+	archive, err := tenant.ReadArchive(in)
+	if err != nil {
+		return err
+	}
+
+	svc := tenant.NewService(newProvisioner(cfg, db, logger), logger)
+	resp, err := svc.Import(ctx, archive, *slug, *name)
+	if err != nil {
+		return err
+	}
+	printTenants(stdout, []tenant.Response{resp})
+	return nil
+}
@@ -13,12 +13,12 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/wisbric/core/pkg/auth"
 
-	"github.com/wisbric/nightowl/internal/db"
 	"github.com/wisbric/nightowl/pkg/tenant"
 )
 
@@ -33,23 +33,47 @@ type Entry struct {
 	Detail       json.RawMessage
 	IPAddress    *netip.Addr
 	UserAgent    *string
+	// CreatedAt defaults to the time the entry is logged.
+	CreatedAt time.Time
 }
 
 // Writer is an async, buffered audit log writer.
-// Entries are sent to an internal channel and flushed by a background goroutine.
+// Entries are sent to an internal channel and flushed by a background
+// goroutine, which appends them to each tenant's hash chain with COPY.
+// No entry is dropped: when the database is unavailable, failed batches go
+// to the spill file if one is configured, and otherwise are retried while
+// Log blocks callers once the buffer is full.
 type Writer struct {
 	pool    *pgxpool.Pool
 	logger  *slog.Logger
 	entries chan Entry
 	wg      sync.WaitGroup
+
+	// SpillPath, when set before Start, is a local file that holds entries
+	// the database did not accept until they can be replayed. Each process
+	// needs its own file.
+	SpillPath string
+	spill     *spill
+
+	// Notify, when set before Start, is called with every entry as it is
+	// logged, before it is written. It must not block.
+	Notify func(Entry)
 }
 
 const (
 	bufferSize    = 256
 	flushInterval = 2 * time.Second
 	flushBatch    = 32
+
+	retryMin   = 500 * time.Millisecond
+	retryMax   = 30 * time.Second
+	writeLimit = 10 * time.Second
 )
 
+// chainLockKey prefixes the advisory lock that serializes writers of a
+// tenant's chain across processes.
+const chainLockKey = "nightowl.audit_log."
+
 // NewWriter creates an audit Writer. Call Start to begin processing entries.
 func NewWriter(pool *pgxpool.Pool, logger *slog.Logger) *Writer {
 	return &Writer{
@@ -59,9 +83,13 @@ func NewWriter(pool *pgxpool.Pool, logger *slog.Logger) *Writer {
 	}
 }
 
-// Start begins the background goroutine that flushes audit entries to the database.
-// It returns when the context is cancelled and all pending entries are flushed.
+// Start begins the background goroutine that flushes audit entries to the
+// database. It runs until Close; cancelling ctx only stops waiting for an
+// unavailable database.
 func (w *Writer) Start(ctx context.Context) {
+	if w.SpillPath != "" {
+		w.spill = &spill{path: w.SpillPath}
+	}
 	w.wg.Add(1)
 	go func() {
 		defer w.wg.Done()
@@ -75,27 +103,40 @@ func (w *Writer) Close() {
 	w.wg.Wait()
 }
 
-// Log enqueues an audit entry for async writing. It never blocks the caller;
-// if the buffer is full the entry is dropped and a warning is logged.
+// Log enqueues an audit entry for async writing. If the buffer is full it
+// blocks until the writer catches up instead of dropping the entry.
 func (w *Writer) Log(entry Entry) {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	if w.Notify != nil {
+		w.Notify(entry)
+	}
 	select {
 	case w.entries <- entry:
 	default:
-		w.logger.Warn("audit log buffer full, dropping entry",
+		w.logger.Warn("audit log buffer full, waiting for writer",
 			"action", entry.Action, "resource", entry.Resource)
+		w.entries <- entry
 	}
 }
 
 // LogFromRequest is a convenience method that extracts identity, tenant, IP,
 // and user agent from the request context, then enqueues the entry.
 func (w *Writer) LogFromRequest(r *http.Request, action, resource string, resourceID uuid.UUID, detail json.RawMessage) {
-	entry := Entry{
-		Action:     action,
-		Resource:   resource,
-		ResourceID: resourceID,
-		Detail:     detail,
-	}
+	entry := EntryFromRequest(r)
+	entry.Action = action
+	entry.Resource = resource
+	entry.ResourceID = resourceID
+	entry.Detail = detail
+	w.Log(entry)
+}
 
+// EntryFromRequest returns an entry attributed to the request's tenant,
+// identity, client IP and user agent, for callers that log on the request's
+// behalf later, after it has completed.
+func EntryFromRequest(r *http.Request) Entry {
+	var entry Entry
 	if ti := tenant.FromContext(r.Context()); ti != nil {
 		entry.TenantSchema = ti.Schema
 	}
@@ -118,8 +159,7 @@ func (w *Writer) LogFromRequest(r *http.Request, action, resource string, resour
 	if ua != "" {
 		entry.UserAgent = &ua
 	}
-
-	w.Log(entry)
+	return entry
 }
 
 // run is the background loop that drains the entries channel.
@@ -133,7 +173,7 @@ func (w *Writer) run(ctx context.Context) {
 		if len(batch) == 0 {
 			return
 		}
-		w.flush(batch)
+		w.write(ctx, batch)
 		batch = batch[:0]
 	}
 
@@ -143,6 +183,7 @@ func (w *Writer) run(ctx context.Context) {
 			if !ok {
 				// Channel closed — flush remaining and exit.
 				flush()
+				w.replaySpill()
 				return
 			}
 			batch = append(batch, entry)
@@ -151,73 +192,134 @@ func (w *Writer) run(ctx context.Context) {
 			}
 		case <-ticker.C:
 			flush()
-		case <-ctx.Done():
-			// Drain any remaining entries.
-			for {
-				select {
-				case entry, ok := <-w.entries:
-					if !ok {
-						flush()
-						return
-					}
-					batch = append(batch, entry)
-				default:
-					flush()
-					return
-				}
+			w.replaySpill()
+		}
+	}
+}
+
+// write stores entries, retrying the tenants whose write failed until they
+// are written or spilled. Once ctx is cancelled it gives up after one more
+// attempt and logs the entries it could not keep.
+func (w *Writer) write(ctx context.Context, entries []Entry) {
+	pending := w.flush(entries)
+	backoff := retryMin
+	for len(pending) > 0 {
+		if w.spill != nil {
+			err := w.spill.append(pending)
+			if err == nil {
+				w.logger.Warn("audit entries spilled to disk", "count", len(pending), "path", w.spill.path)
+				return
 			}
+			w.logger.Error("spilling audit entries", "error", err, "count", len(pending))
 		}
+		if ctx.Err() != nil {
+			w.logger.Error("audit entries lost: database unavailable during shutdown", "count", len(pending))
+			return
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+		backoff = min(backoff*2, retryMax)
+		pending = w.flush(pending)
 	}
 }
 
-// flush writes a batch of entries to the database, grouped by tenant schema.
-func (w *Writer) flush(entries []Entry) {
+// replaySpill writes spilled entries back to the database, keeping the ones
+// that still fail.
+func (w *Writer) replaySpill() {
+	if w.spill == nil {
+		return
+	}
+	spilled, err := w.spill.load()
+	if err != nil {
+		w.logger.Error("reading audit spill file", "error", err)
+		return
+	}
+	if len(spilled) == 0 {
+		return
+	}
+	pending := w.flush(spilled)
+	if len(pending) == len(spilled) {
+		return
+	}
+	if err := w.spill.replace(pending); err != nil {
+		// The file still holds every spilled entry; the written ones are
+		// duplicated on the next replay rather than lost.
+		w.logger.Error("rewriting audit spill file", "error", err)
+		return
+	}
+	w.logger.Info("replayed spilled audit entries", "written", len(spilled)-len(pending), "remaining", len(pending))
+}
+
+// flush writes a batch of entries to the database, grouped by tenant
+// schema, and returns the entries of tenants whose write failed.
+func (w *Writer) flush(entries []Entry) []Entry {
 	// Group by tenant schema.
 	bySchema := make(map[string][]Entry)
 	for _, e := range entries {
 		bySchema[e.TenantSchema] = append(bySchema[e.TenantSchema], e)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
+	var failed []Entry
 	for schema, schemaEntries := range bySchema {
 		if schema == "" {
-			w.logger.Warn("audit entry without tenant schema, skipping", "count", len(schemaEntries))
+			w.logger.Error("audit entry without tenant schema, skipping", "count", len(schemaEntries))
 			continue
 		}
-
-		conn, err := w.pool.Acquire(ctx)
-		if err != nil {
-			w.logger.Error("acquiring connection for audit flush", "error", err, "schema", schema)
-			continue
+		if err := w.append(schema, schemaEntries); err != nil {
+			w.logger.Error("writing audit log entries", "error", err, "schema", schema, "count", len(schemaEntries))
+			failed = append(failed, schemaEntries...)
 		}
+	}
+	return failed
+}
 
-		if _, err := conn.Exec(ctx, fmt.Sprintf("SET search_path TO %s, public", schema)); err != nil {
-			w.logger.Error("setting search_path for audit flush", "error", err, "schema", schema)
-			conn.Release()
-			continue
-		}
+// append writes entries for one tenant, bounded by writeLimit.
+func (w *Writer) append(schema string, entries []Entry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), writeLimit)
+	defer cancel()
+	return Append(ctx, w.pool, schema, entries)
+}
 
-		q := db.New(conn)
-		for _, e := range schemaEntries {
-			if _, err := q.CreateAuditLogEntry(ctx, db.CreateAuditLogEntryParams{
-				UserID:     e.UserID,
-				ApiKeyID:   e.APIKeyID,
-				Action:     e.Action,
-				Resource:   e.Resource,
-				ResourceID: pgtype.UUID{Bytes: e.ResourceID, Valid: e.ResourceID != uuid.Nil},
-				Detail:     e.Detail,
-				IpAddress:  e.IPAddress,
-				UserAgent:  e.UserAgent,
-			}); err != nil {
-				w.logger.Error("writing audit log entry", "error", err,
-					"action", e.Action, "resource", e.Resource, "schema", schema)
-			}
-		}
+// Append synchronously adds entries to the end of a tenant's hash chain in
+// one transaction, bypassing the Writer's buffer. An advisory lock
+// serializes writers of the same chain, so every process links to the true
+// head.
+func Append(ctx context.Context, pool *pgxpool.Pool, schema string, entries []Entry) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
 
-		conn.Release()
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtextextended($1, 0))", chainLockKey+schema); err != nil {
+		return fmt.Errorf("locking audit chain: %w", err)
+	}
+	table := pgx.Identifier{schema, "audit_log"}
+	head, err := chainHead(ctx, tx, table)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i := range entries {
+		if entries[i].CreatedAt.IsZero() {
+			entries[i].CreatedAt = now
+		}
+	}
+	records := link(head, entries)
+	rows := make([][]any, len(records))
+	for i := range records {
+		rows[i] = records[i].values()
+	}
+	if _, err := tx.CopyFrom(ctx, table, copyColumns, pgx.CopyFromRows(rows)); err != nil {
+		return fmt.Errorf("copying audit entries: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing audit entries: %w", err)
 	}
+	return nil
 }
 
 // clientIP extracts the client IP address from the request,
@@ -5,6 +5,7 @@ import (
 	"net/http/httptest"
 	"net/netip"
 	"testing"
+	"time"
 )
 
 func TestClientIP_XForwardedFor(t *testing.T) {
@@ -77,7 +78,7 @@ func TestClientIP_InvalidXFF(t *testing.T) {
 	}
 }
 
-func TestLog_DropsWhenFull(t *testing.T) {
+func TestLog_BlocksWhenFull(t *testing.T) {
 	logger := slog.Default()
 	w := NewWriter(nil, logger)
 	// Don't start the background goroutine — nothing drains the channel.
@@ -87,10 +88,24 @@ func TestLog_DropsWhenFull(t *testing.T) {
 		w.Log(Entry{Action: "test", Resource: "test"})
 	}
 
-	// The next log should be dropped (non-blocking).
-	w.Log(Entry{Action: "dropped", Resource: "dropped"})
+	// The next log must wait for room instead of dropping the entry.
+	done := make(chan struct{})
+	go func() {
+		w.Log(Entry{Action: "waiting", Resource: "test"})
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Log returned with a full buffer")
+	case <-time.After(50 * time.Millisecond):
+	}
 
-	// Verify buffer is full.
+	<-w.entries
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Log still blocked after the buffer drained")
+	}
 	if len(w.entries) != bufferSize {
 		t.Errorf("buffer size = %d, want %d", len(w.entries), bufferSize)
 	}
@@ -126,4 +141,7 @@ func TestLogFromRequest_ExtractsFields(t *testing.T) {
 	if entry.UserAgent == nil || *entry.UserAgent != "test-agent/1.0" {
 		t.Errorf("UserAgent = %v, want test-agent/1.0", entry.UserAgent)
 	}
+	if entry.CreatedAt.IsZero() {
+		t.Error("CreatedAt should default to the time of logging")
+	}
 }
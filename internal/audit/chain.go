@@ -0,0 +1,328 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wisbric/nightowl/internal/db"
+)
+
+// genesisHash is the prev_hash of the first entry in a tenant's chain.
+var genesisHash = make([]byte, sha256.Size)
+
+// copyColumns are the audit_log columns written by the Writer, in the order
+// of record.values.
+var copyColumns = []string{
+	"id", "seq", "prev_hash", "hash", "created_at", "user_id", "api_key_id",
+	"action", "resource", "resource_id", "detail", "ip_address", "user_agent",
+}
+
+// record is one chained audit_log row.
+type record struct {
+	ID         uuid.UUID
+	Seq        int64
+	PrevHash   []byte
+	Hash       []byte
+	CreatedAt  time.Time
+	UserID     pgtype.UUID
+	APIKeyID   pgtype.UUID
+	Action     string
+	Resource   string
+	ResourceID pgtype.UUID
+	Detail     json.RawMessage
+	IPAddress  *netip.Addr
+	UserAgent  *string
+}
+
+// canonicalRecord fixes the fields, order and encoding that are hashed.
+type canonicalRecord struct {
+	Seq        int64           `json:"seq"`
+	ID         string          `json:"id"`
+	CreatedAt  string          `json:"created_at"`
+	UserID     *string         `json:"user_id"`
+	APIKeyID   *string         `json:"api_key_id"`
+	Action     string          `json:"action"`
+	Resource   string          `json:"resource"`
+	ResourceID *string         `json:"resource_id"`
+	Detail     json.RawMessage `json:"detail"`
+	IPAddress  *string         `json:"ip_address"`
+	UserAgent  *string         `json:"user_agent"`
+}
+
+// newRecord prepares an entry for storage: values are normalized to what
+// PostgreSQL returns for them, so the hash survives a round trip.
+func newRecord(e Entry) record {
+	r := record{
+		ID:         uuid.New(),
+		CreatedAt:  e.CreatedAt.UTC().Truncate(time.Microsecond),
+		UserID:     e.UserID,
+		APIKeyID:   e.APIKeyID,
+		Action:     cleanText(e.Action),
+		Resource:   cleanText(e.Resource),
+		ResourceID: pgtype.UUID{Bytes: e.ResourceID, Valid: e.ResourceID != uuid.Nil},
+		Detail:     canonicalDetail(e.Detail),
+	}
+	if e.IPAddress != nil && e.IPAddress.IsValid() {
+		ip := e.IPAddress.WithZone("")
+		r.IPAddress = &ip
+	}
+	if e.UserAgent != nil {
+		ua := cleanText(*e.UserAgent)
+		r.UserAgent = &ua
+	}
+	return r
+}
+
+// cleanText makes s storable as TEXT, which rejects NUL bytes and invalid
+// UTF-8, such as in a hostile User-Agent header.
+func cleanText(s string) string {
+	return strings.ReplaceAll(strings.ToValidUTF8(s, "\uFFFD"), "\x00", "")
+}
+
+// canonicalDetail re-encodes a detail document with sorted keys and
+// normalized numbers, as JSONB reorders keys and drops whitespace. Invalid
+// JSON is kept as a string under "raw".
+func canonicalDetail(raw json.RawMessage) json.RawMessage {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return json.RawMessage("{}")
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		v = map[string]string{"raw": cleanText(string(raw))}
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return json.RawMessage("{}")
+	}
+	return out
+}
+
+// computeHash returns SHA-256(prev_hash || canonical record).
+func (r *record) computeHash() []byte {
+	uuidString := func(u pgtype.UUID) *string {
+		if !u.Valid {
+			return nil
+		}
+		s := uuid.UUID(u.Bytes).String()
+		return &s
+	}
+	c := canonicalRecord{
+		Seq:        r.Seq,
+		ID:         r.ID.String(),
+		CreatedAt:  r.CreatedAt.UTC().Format(time.RFC3339Nano),
+		UserID:     uuidString(r.UserID),
+		APIKeyID:   uuidString(r.APIKeyID),
+		Action:     r.Action,
+		Resource:   r.Resource,
+		ResourceID: uuidString(r.ResourceID),
+		Detail:     canonicalDetail(r.Detail),
+		UserAgent:  r.UserAgent,
+	}
+	if r.IPAddress != nil {
+		ip := r.IPAddress.String()
+		c.IPAddress = &ip
+	}
+	// Marshalling a struct of strings and raw JSON that is already valid
+	// cannot fail.
+	b, _ := json.Marshal(c)
+
+	h := sha256.New()
+	h.Write(r.PrevHash)
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+func (r *record) values() []any {
+	return []any{
+		r.ID, r.Seq, r.PrevHash, r.Hash, r.CreatedAt, r.UserID, r.APIKeyID,
+		r.Action, r.Resource, r.ResourceID, []byte(r.Detail), r.IPAddress, r.UserAgent,
+	}
+}
+
+// chainLink is a position in a tenant's chain.
+type chainLink struct {
+	Seq  int64
+	Hash []byte
+}
+
+// link appends entries to the chain after head.
+func link(head chainLink, entries []Entry) []record {
+	records := make([]record, len(entries))
+	for i, e := range entries {
+		r := newRecord(e)
+		r.Seq = head.Seq + 1
+		r.PrevHash = head.Hash
+		r.Hash = r.computeHash()
+		head = chainLink{Seq: r.Seq, Hash: r.Hash}
+		records[i] = r
+	}
+	return records
+}
+
+// chainHead returns the last chained entry of an audit_log table, or the
+// genesis link for an empty chain.
+func chainHead(ctx context.Context, q db.DBTX, table pgx.Identifier) (chainLink, error) {
+	var head chainLink
+	err := q.QueryRow(ctx,
+		`SELECT seq, hash FROM `+table.Sanitize()+` WHERE seq IS NOT NULL ORDER BY seq DESC LIMIT 1`,
+	).Scan(&head.Seq, &head.Hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return chainLink{Hash: genesisHash}, nil
+	}
+	if err != nil {
+		return chainLink{}, fmt.Errorf("reading chain head: %w", err)
+	}
+	return head, nil
+}
+
+// Problem kinds reported by Verify.
+const (
+	ProblemGap        = "gap"         // sequence numbers are missing
+	ProblemBrokenLink = "broken_link" // prev_hash is not the previous entry's hash
+	ProblemModified   = "modified"    // the entry no longer matches its hash
+	ProblemUnchained  = "unchained"   // an entry without a chain position was added after the chain started
+)
+
+// maxProblems caps the problems listed in a VerifyResult; ProblemCount has
+// the total.
+const maxProblems = 100
+
+// Problem is one integrity violation found by Verify.
+type Problem struct {
+	Kind   string     `json:"kind"`
+	Seq    int64      `json:"seq,omitempty"`
+	ID     *uuid.UUID `json:"id,omitempty"`
+	Detail string     `json:"detail"`
+}
+
+// VerifyResult is the outcome of verifying a tenant's audit chain.
+type VerifyResult struct {
+	Valid        bool      `json:"valid"`
+	Entries      int64     `json:"entries"`
+	Unchained    int64     `json:"unchained"`
+	HeadSeq      int64     `json:"head_seq"`
+	HeadHash     string    `json:"head_hash"`
+	ProblemCount int       `json:"problem_count"`
+	Problems     []Problem `json:"problems"`
+	VerifiedAt   time.Time `json:"verified_at"`
+}
+
+// verifier checks records in sequence order.
+type verifier struct {
+	head   chainLink
+	result VerifyResult
+}
+
+func newVerifier() *verifier {
+	return &verifier{
+		head:   chainLink{Hash: genesisHash},
+		result: VerifyResult{Problems: []Problem{}},
+	}
+}
+
+func (v *verifier) report(p Problem) {
+	v.result.ProblemCount++
+	if len(v.result.Problems) < maxProblems {
+		v.result.Problems = append(v.result.Problems, p)
+	}
+}
+
+// check verifies r against the previous record and its own hash. After a
+// problem the chain continues from r, so each tampered spot is reported once.
+func (v *verifier) check(r *record) {
+	v.result.Entries++
+	id := r.ID
+	if want := v.head.Seq + 1; r.Seq != want {
+		v.report(Problem{Kind: ProblemGap, Seq: r.Seq, ID: &id,
+			Detail: fmt.Sprintf("expected seq %d, found %d", want, r.Seq)})
+	} else if !bytes.Equal(r.PrevHash, v.head.Hash) {
+		v.report(Problem{Kind: ProblemBrokenLink, Seq: r.Seq, ID: &id,
+			Detail: "prev_hash does not match the previous entry's hash"})
+	}
+	if !bytes.Equal(r.computeHash(), r.Hash) {
+		v.report(Problem{Kind: ProblemModified, Seq: r.Seq, ID: &id,
+			Detail: "entry content does not match its hash"})
+	}
+	v.head = chainLink{Seq: r.Seq, Hash: r.Hash}
+}
+
+func (v *verifier) finish() VerifyResult {
+	res := v.result
+	res.HeadSeq = v.head.Seq
+	res.HeadHash = hex.EncodeToString(v.head.Hash)
+	res.Valid = res.ProblemCount == 0
+	res.VerifiedAt = time.Now().UTC()
+	return res
+}
+
+// Verify walks the audit_log of the tenant q is connected to and reports
+// gaps, broken links, modified entries and unchained entries added after
+// the chain started. Deleting entries from the end of the chain can only be
+// detected by comparing HeadSeq and HeadHash with a previously recorded head.
+func Verify(ctx context.Context, q db.DBTX) (VerifyResult, error) {
+	v := newVerifier()
+
+	rows, err := q.Query(ctx, `
+		SELECT id, seq, prev_hash, hash, created_at, user_id, api_key_id,
+		       action, resource, resource_id, detail, ip_address, user_agent
+		FROM audit_log
+		WHERE seq IS NOT NULL
+		ORDER BY seq`)
+	if err != nil {
+		return VerifyResult{}, fmt.Errorf("reading audit chain: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r record
+		var detail []byte
+		if err := rows.Scan(&r.ID, &r.Seq, &r.PrevHash, &r.Hash, &r.CreatedAt, &r.UserID, &r.APIKeyID,
+			&r.Action, &r.Resource, &r.ResourceID, &detail, &r.IPAddress, &r.UserAgent); err != nil {
+			return VerifyResult{}, fmt.Errorf("scanning audit entry: %w", err)
+		}
+		r.Detail = detail
+		v.check(&r)
+	}
+	if err := rows.Err(); err != nil {
+		return VerifyResult{}, fmt.Errorf("reading audit chain: %w", err)
+	}
+
+	// Entries written before the chain existed are expected; unchained
+	// entries newer than the first chained one were inserted around it.
+	unchained, err := q.Query(ctx, `
+		SELECT id, created_at < coalesce((SELECT min(created_at) FROM audit_log WHERE seq IS NOT NULL), 'infinity')
+		FROM audit_log
+		WHERE seq IS NULL`)
+	if err != nil {
+		return VerifyResult{}, fmt.Errorf("reading unchained audit entries: %w", err)
+	}
+	defer unchained.Close()
+	for unchained.Next() {
+		var id uuid.UUID
+		var legacy bool
+		if err := unchained.Scan(&id, &legacy); err != nil {
+			return VerifyResult{}, fmt.Errorf("scanning unchained audit entry: %w", err)
+		}
+		v.result.Unchained++
+		if !legacy {
+			v.report(Problem{Kind: ProblemUnchained, ID: &id,
+				Detail: "entry without chain position created after the chain started"})
+		}
+	}
+	if err := unchained.Err(); err != nil {
+		return VerifyResult{}, fmt.Errorf("reading unchained audit entries: %w", err)
+	}
+
+	return v.finish(), nil
+}
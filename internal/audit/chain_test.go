@@ -0,0 +1,183 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func testEntries(n int) []Entry {
+	ip := netip.MustParseAddr("2001:db8::1")
+	ua := "test-agent/1.0"
+	entries := make([]Entry, n)
+	for i := range entries {
+		entries[i] = Entry{
+			TenantSchema: "tenant_acme",
+			UserID:       pgtype.UUID{Bytes: uuid.New(), Valid: true},
+			Action:       "update",
+			Resource:     "incident",
+			ResourceID:   uuid.New(),
+			Detail:       json.RawMessage(`{"title":"Disk full","count":3}`),
+			IPAddress:    &ip,
+			UserAgent:    &ua,
+			CreatedAt:    time.Date(2026, 3, 1, 12, 0, i, 123456789, time.UTC),
+		}
+	}
+	return entries
+}
+
+func verifyRecords(records []record) VerifyResult {
+	v := newVerifier()
+	for i := range records {
+		v.check(&records[i])
+	}
+	return v.finish()
+}
+
+func problemKinds(res VerifyResult) []string {
+	kinds := []string{}
+	for _, p := range res.Problems {
+		kinds = append(kinds, p.Kind)
+	}
+	return kinds
+}
+
+func TestLink_ChainsRecords(t *testing.T) {
+	records := link(chainLink{Hash: genesisHash}, testEntries(3))
+
+	for i, r := range records {
+		if r.Seq != int64(i+1) {
+			t.Errorf("records[%d].Seq = %d, want %d", i, r.Seq, i+1)
+		}
+		if len(r.Hash) != 32 {
+			t.Errorf("records[%d].Hash has %d bytes", i, len(r.Hash))
+		}
+	}
+	if !bytes.Equal(records[0].PrevHash, genesisHash) {
+		t.Error("first record does not link to the genesis hash")
+	}
+	if !bytes.Equal(records[2].PrevHash, records[1].Hash) {
+		t.Error("records[2] does not link to records[1]")
+	}
+	if records[0].CreatedAt.Nanosecond()%1000 != 0 {
+		t.Errorf("CreatedAt = %v, want microsecond precision", records[0].CreatedAt)
+	}
+
+	res := verifyRecords(records)
+	if !res.Valid || res.Entries != 3 || res.HeadSeq != 3 {
+		t.Errorf("result = %+v, want a valid chain of 3", res)
+	}
+}
+
+func TestLink_ContinuesFromHead(t *testing.T) {
+	first := link(chainLink{Hash: genesisHash}, testEntries(2))
+	head := chainLink{Seq: first[1].Seq, Hash: first[1].Hash}
+	second := link(head, testEntries(2))
+
+	if second[0].Seq != 3 || !bytes.Equal(second[0].PrevHash, first[1].Hash) {
+		t.Errorf("second batch starts at seq %d, not linked to the head", second[0].Seq)
+	}
+	if res := verifyRecords(append(first, second...)); !res.Valid {
+		t.Errorf("problems = %v", res.Problems)
+	}
+}
+
+// TestComputeHash_RoundTrip checks that the hash survives the changes
+// PostgreSQL makes when storing a row: JSONB key order and whitespace, and
+// timestamps read back in another time zone.
+func TestComputeHash_RoundTrip(t *testing.T) {
+	r := link(chainLink{Hash: genesisHash}, testEntries(1))[0]
+
+	stored := r
+	stored.Detail = json.RawMessage(`{"count": 3, "title": "Disk full"}`)
+	stored.CreatedAt = r.CreatedAt.In(time.FixedZone("NZDT", 13*3600))
+	if !bytes.Equal(stored.computeHash(), r.Hash) {
+		t.Error("hash changed after a storage round trip")
+	}
+}
+
+func TestVerify_DetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func([]record) []record
+		want   []string
+	}{
+		{"edited field", func(rs []record) []record {
+			rs[1].Action = "delete"
+			return rs
+		}, []string{ProblemModified}},
+		{"edited detail", func(rs []record) []record {
+			rs[2].Detail = json.RawMessage(`{"title":"All good","count":3}`)
+			return rs
+		}, []string{ProblemModified}},
+		{"deleted entry", func(rs []record) []record {
+			return append(rs[:1], rs[2:]...)
+		}, []string{ProblemGap}},
+		{"deleted first entry", func(rs []record) []record {
+			return rs[1:]
+		}, []string{ProblemGap}},
+		{"replaced entry with rehashed forgery", func(rs []record) []record {
+			rs[1].Action = "delete"
+			rs[1].PrevHash = bytes.Repeat([]byte{1}, 32)
+			rs[1].Hash = rs[1].computeHash()
+			return rs
+		}, []string{ProblemBrokenLink, ProblemBrokenLink}},
+		{"swapped entries", func(rs []record) []record {
+			rs[1], rs[2] = rs[2], rs[1]
+			return rs
+		}, []string{ProblemGap, ProblemGap, ProblemGap}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := tt.tamper(link(chainLink{Hash: genesisHash}, testEntries(4)))
+			res := verifyRecords(records)
+			if res.Valid {
+				t.Fatal("tampered chain verified as valid")
+			}
+			got := problemKinds(res)
+			if len(got) != len(tt.want) {
+				t.Fatalf("problems = %v, want kinds %v", res.Problems, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("problem %d kind = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestVerify_CapsProblems(t *testing.T) {
+	records := link(chainLink{Hash: genesisHash}, testEntries(maxProblems+5))
+	for i := range records {
+		records[i].Resource = "forged"
+	}
+	res := verifyRecords(records)
+	if res.ProblemCount != maxProblems+5 || len(res.Problems) != maxProblems {
+		t.Errorf("ProblemCount = %d, listed %d", res.ProblemCount, len(res.Problems))
+	}
+}
+
+func TestNewRecord_Normalizes(t *testing.T) {
+	zoned := netip.MustParseAddr("fe80::1%eth0")
+	ua := "bad\x00agent\xff"
+	r := newRecord(Entry{Action: "create", IPAddress: &zoned, UserAgent: &ua, Detail: json.RawMessage(`not json`)})
+
+	if r.IPAddress.Zone() != "" {
+		t.Errorf("IPAddress = %v, want the zone dropped", r.IPAddress)
+	}
+	if *r.UserAgent != "badagent�" {
+		t.Errorf("UserAgent = %q", *r.UserAgent)
+	}
+	if string(r.Detail) != `{"raw":"not json"}` {
+		t.Errorf("Detail = %s", r.Detail)
+	}
+	if string(newRecord(Entry{}).Detail) != "{}" {
+		t.Error("empty detail should be stored as {}")
+	}
+}
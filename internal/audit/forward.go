@@ -0,0 +1,291 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/wisbric/nightowl/internal/db"
+	"github.com/wisbric/nightowl/pkg/tenant"
+)
+
+const (
+	forwardInterval = 5 * time.Second
+	// forwardBatches caps the batches sent to one sink per cycle so one
+	// backlog cannot starve the other tenants.
+	forwardBatches = 20
+
+	forwardRetryMin = time.Second
+	forwardRetryMax = 5 * time.Minute
+)
+
+// ForwarderMetrics holds the Prometheus collectors a Forwarder reports to.
+type ForwarderMetrics struct {
+	// Lag is the age in seconds of the oldest entry a sink has not accepted,
+	// labelled by tenant and sink name. It is 0 when the sink is caught up.
+	Lag *prometheus.GaugeVec
+	// Forwarded counts delivered entries by sink kind.
+	Forwarded *prometheus.CounterVec
+	// Failures counts failed deliveries by sink kind.
+	Failures *prometheus.CounterVec
+}
+
+// Forwarder tails every tenant's audit chain and delivers new entries to the
+// tenant's enabled sinks. Each sink's position is stored with the sink, so
+// entries are buffered by the audit log itself: a sink that is down catches
+// up once it recovers, also across restarts. Delivery is at least once;
+// receivers can deduplicate by seq or id. Several forwarders may run, each
+// sink is delivered by one of them at a time.
+type Forwarder struct {
+	pool    *pgxpool.Pool
+	logger  *slog.Logger
+	metrics *ForwarderMetrics
+
+	sinks map[uuid.UUID]*activeSink
+}
+
+// activeSink is a connected sink and its retry state.
+type activeSink struct {
+	sink      Sink
+	kind      string
+	batchSize int
+	updatedAt time.Time
+	tenant    string
+	name      string
+
+	retryAt time.Time
+	backoff time.Duration
+	seen    bool
+}
+
+// NewForwarder creates a Forwarder. metrics may be nil.
+func NewForwarder(pool *pgxpool.Pool, logger *slog.Logger, metrics *ForwarderMetrics) *Forwarder {
+	return &Forwarder{pool: pool, logger: logger, metrics: metrics, sinks: map[uuid.UUID]*activeSink{}}
+}
+
+// Run forwards entries every few seconds until ctx is cancelled.
+func (f *Forwarder) Run(ctx context.Context) {
+	f.logger.Info("audit forwarder started", "interval", forwardInterval)
+	ticker := time.NewTicker(forwardInterval)
+	defer ticker.Stop()
+	defer f.closeAll()
+
+	for {
+		f.forwardAll(ctx)
+		select {
+		case <-ctx.Done():
+			f.logger.Info("audit forwarder stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (f *Forwarder) forwardAll(ctx context.Context) {
+	tenants, err := db.New(f.pool).ListTenants(ctx)
+	if err != nil {
+		f.logger.Error("listing tenants for audit forwarding", "error", err)
+		return
+	}
+	for _, a := range f.sinks {
+		a.seen = false
+	}
+	for _, t := range tenants {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := f.forwardTenant(ctx, t.Slug); err != nil {
+			f.logger.Error("forwarding tenant audit log", "tenant", t.Slug, "error", err)
+			// Keep the tenant's sinks until the next successful cycle.
+			for _, a := range f.sinks {
+				if a.tenant == t.Slug {
+					a.seen = true
+				}
+			}
+		}
+	}
+	for id, a := range f.sinks {
+		if !a.seen {
+			f.drop(id, a)
+		}
+	}
+}
+
+func (f *Forwarder) forwardTenant(ctx context.Context, slug string) error {
+	// Tenants suspended or deleted since they were listed are skipped.
+	ctx, release, err := tenant.Acquire(ctx, f.pool, slug)
+	if errors.Is(err, tenant.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer release()
+	conn := tenant.ConnFromContext(ctx)
+
+	configured, err := NewSinkStore(conn).ListEnabled(ctx)
+	if err != nil {
+		return err
+	}
+	for _, cs := range configured {
+		a, err := f.activate(slug, cs)
+		if err != nil {
+			f.logger.Error("invalid audit sink", "tenant", slug, "sink", cs.Name, "error", err)
+			continue
+		}
+		a.seen = true
+		if time.Now().Before(a.retryAt) {
+			f.reportLag(ctx, conn, cs.ID, a, cs.DeliveredSeq)
+			continue
+		}
+
+		seq, err := f.deliver(ctx, conn, slug, cs.ID, a)
+		if err != nil {
+			a.backoff = min(max(a.backoff*2, forwardRetryMin), forwardRetryMax)
+			a.retryAt = time.Now().Add(a.backoff)
+			f.logger.Warn("audit sink delivery failed",
+				"tenant", slug, "sink", cs.Name, "retry_in", a.backoff, "error", err)
+		} else {
+			a.backoff, a.retryAt = 0, time.Time{}
+		}
+		f.reportLag(ctx, conn, cs.ID, a, seq)
+	}
+	return nil
+}
+
+// activate returns the connected sink for a configuration, reconnecting
+// when it changed.
+func (f *Forwarder) activate(slug string, cs ConfiguredSink) (*activeSink, error) {
+	if a, ok := f.sinks[cs.ID]; ok {
+		if a.updatedAt.Equal(cs.UpdatedAt) {
+			return a, nil
+		}
+		f.drop(cs.ID, a)
+	}
+	sink, err := NewSink(cs.Kind, cs.Config)
+	if err != nil {
+		return nil, err
+	}
+	a := &activeSink{
+		sink: sink, kind: cs.Kind, batchSize: cs.Config.batchSize(),
+		updatedAt: cs.UpdatedAt, tenant: slug, name: cs.Name,
+	}
+	f.sinks[cs.ID] = a
+	return a, nil
+}
+
+// deliver sends batches until the sink is caught up, a send fails or the
+// per-cycle cap is reached. It returns the sink's position afterwards, or -1
+// when another forwarder holds the sink.
+func (f *Forwarder) deliver(ctx context.Context, conn *pgxpool.Conn, slug string, id uuid.UUID, a *activeSink) (int64, error) {
+	for range forwardBatches {
+		seq, more, err := f.deliverBatch(ctx, conn, slug, id, a)
+		if err != nil || !more {
+			return seq, err
+		}
+	}
+	return -1, nil
+}
+
+func (f *Forwarder) deliverBatch(ctx context.Context, conn *pgxpool.Conn, slug string, id uuid.UUID, a *activeSink) (seq int64, more bool, err error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return -1, false, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	store := NewSinkStore(tx)
+	seq, ok, err := store.claim(ctx, id)
+	if err != nil || !ok {
+		return -1, false, err
+	}
+	entries, err := pendingEntries(ctx, tx, seq, a.batchSize)
+	if err != nil || len(entries) == 0 {
+		return seq, false, err
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, sinkTimeout)
+	sendErr := a.sink.Send(sendCtx, slug, entries)
+	cancel()
+	if sendErr != nil {
+		f.count(a, len(entries), sendErr)
+		if err := store.recordFailure(ctx, id, sendErr.Error()); err != nil {
+			return seq, false, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return seq, false, fmt.Errorf("committing: %w", err)
+		}
+		return seq, false, sendErr
+	}
+
+	last := *entries[len(entries)-1].Seq
+	if err := store.recordDelivery(ctx, id, last); err != nil {
+		return seq, false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return seq, false, fmt.Errorf("committing: %w", err)
+	}
+	f.count(a, len(entries), nil)
+	return last, len(entries) == a.batchSize, nil
+}
+
+func (f *Forwarder) count(a *activeSink, n int, err error) {
+	if f.metrics == nil {
+		return
+	}
+	if err != nil {
+		if f.metrics.Failures != nil {
+			f.metrics.Failures.WithLabelValues(a.kind).Inc()
+		}
+		return
+	}
+	if f.metrics.Forwarded != nil {
+		f.metrics.Forwarded.WithLabelValues(a.kind).Add(float64(n))
+	}
+}
+
+// reportLag sets the lag gauge from the oldest entry after seq. A negative
+// seq means the position is unknown; the sink's stored position is used.
+func (f *Forwarder) reportLag(ctx context.Context, conn *pgxpool.Conn, id uuid.UUID, a *activeSink, seq int64) {
+	if f.metrics == nil || f.metrics.Lag == nil {
+		return
+	}
+	if seq < 0 {
+		if err := conn.QueryRow(ctx, `SELECT delivered_seq FROM audit_sinks WHERE id = $1`, id).Scan(&seq); err != nil {
+			return
+		}
+	}
+	oldest, err := oldestPending(ctx, conn, seq)
+	if err != nil {
+		f.logger.Error("measuring audit sink lag", "tenant", a.tenant, "sink", a.name, "error", err)
+		return
+	}
+	lag := 0.0
+	if !oldest.IsZero() {
+		lag = max(time.Since(oldest).Seconds(), 0)
+	}
+	f.metrics.Lag.WithLabelValues(a.tenant, a.name).Set(lag)
+}
+
+// drop disconnects a sink that was removed, disabled or reconfigured.
+func (f *Forwarder) drop(id uuid.UUID, a *activeSink) {
+	if err := a.sink.Close(); err != nil {
+		f.logger.Warn("closing audit sink", "tenant", a.tenant, "sink", a.name, "error", err)
+	}
+	if f.metrics != nil && f.metrics.Lag != nil {
+		f.metrics.Lag.DeleteLabelValues(a.tenant, a.name)
+	}
+	delete(f.sinks, id)
+}
+
+func (f *Forwarder) closeAll() {
+	for id, a := range f.sinks {
+		f.drop(id, a)
+	}
+}
@@ -1,11 +1,21 @@
 package audit
 
 import (
+	"bufio"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 
+	"github.com/wisbric/core/pkg/auth"
 	"github.com/wisbric/core/pkg/httpserver"
 
 	"github.com/wisbric/nightowl/internal/db"
@@ -15,39 +25,193 @@ import (
 // Handler provides HTTP handlers for the audit log API.
 type Handler struct {
 	logger *slog.Logger
+	audit  *Writer
 }
 
-// NewHandler creates an audit log Handler.
-func NewHandler(logger *slog.Logger) *Handler {
-	return &Handler{logger: logger}
+// NewHandler creates an audit log Handler. Exports are themselves audited
+// through w.
+func NewHandler(logger *slog.Logger, w *Writer) *Handler {
+	return &Handler{logger: logger, audit: w}
 }
 
 // Routes returns a chi.Router with audit log routes mounted.
 func (h *Handler) Routes() chi.Router {
 	r := chi.NewRouter()
 	r.Get("/", h.handleList)
+	r.Get("/export", h.handleExport)
+	r.Get("/history/{resource}/{id}", h.handleHistory)
+	r.With(auth.RequireRole(auth.RoleAdmin)).Get("/verify", h.handleVerify)
+	r.Mount("/sinks", h.sinkRoutes())
 	return r
 }
 
+// handleList returns entries matching the filter, newest first. The body
+// stays a plain array; the cursor for the next page is in X-Next-Cursor.
 func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
-	params, err := httpserver.ParseOffsetParams(r)
+	f, err := ParseFilter(r)
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	params, err := httpserver.ParseCursorParams(r)
 	if err != nil {
 		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
 		return
 	}
 
-	conn := tenant.ConnFromContext(r.Context())
-	q := db.New(conn)
-
-	entries, err := q.ListAuditLog(r.Context(), db.ListAuditLogParams{
-		Limit:  int32(params.PageSize),
-		Offset: int32(params.Offset),
-	})
+	entries, err := Search(r.Context(), tenant.ConnFromContext(r.Context()), f, params.After, params.Limit+1)
 	if err != nil {
 		h.logger.Error("listing audit log", "error", err)
 		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to list audit log")
 		return
 	}
 
-	httpserver.Respond(w, http.StatusOK, entries)
+	page := httpserver.NewCursorPage(entries, params.Limit, func(e db.AuditLog) httpserver.Cursor {
+		return httpserver.Cursor{CreatedAt: e.CreatedAt, ID: e.ID}
+	})
+	if page.NextCursor != nil {
+		w.Header().Set("X-Next-Cursor", *page.NextCursor)
+	}
+	httpserver.Respond(w, http.StatusOK, page.Items)
+}
+
+// csvHeader is the header row of CSV exports.
+var csvHeader = []string{
+	"id", "created_at", "seq", "user_id", "api_key_id", "action", "resource",
+	"resource_id", "ip_address", "user_agent", "detail", "hash",
+}
+
+// handleExport streams every entry matching the filter as CSV or NDJSON.
+func (h *Handler) handleExport(w http.ResponseWriter, r *http.Request) {
+	f, err := ParseFilter(r)
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "ndjson"
+	}
+
+	bw := bufio.NewWriterSize(w, 64<<10)
+	var write func(db.AuditLog) error
+	var flush func() error
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(bw)
+		_ = cw.Write(csvHeader)
+		write = func(e db.AuditLog) error { return cw.Write(csvRecord(e)) }
+		flush = func() error {
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				return err
+			}
+			return bw.Flush()
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	case "ndjson":
+		enc := json.NewEncoder(bw)
+		write = func(e db.AuditLog) error { return enc.Encode(e) }
+		flush = bw.Flush
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	default:
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "format must be csv or ndjson")
+		return
+	}
+
+	detail, _ := json.Marshal(map[string]any{"format": format, "query": r.URL.RawQuery})
+	h.audit.LogFromRequest(r, "export", "audit_log", uuid.Nil, detail)
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-log-%s.%s"`,
+		time.Now().UTC().Format("20060102T150405Z"), format))
+
+	rows := 0
+	err = Each(r.Context(), tenant.ConnFromContext(r.Context()), f, func(e db.AuditLog) error {
+		rows++
+		return write(e)
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		h.logger.Error("exporting audit log", "error", err, "rows", rows)
+		if rows == 0 {
+			// Nothing beyond the buffered CSV header was produced yet.
+			httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to export audit log")
+			return
+		}
+		// Part of the export may already be sent; abort the response so
+		// the client cannot mistake a truncated export for a complete one.
+		panic(http.ErrAbortHandler)
+	}
+}
+
+func csvRecord(e db.AuditLog) []string {
+	uuidString := func(u pgtype.UUID) string {
+		if !u.Valid {
+			return ""
+		}
+		return uuid.UUID(u.Bytes).String()
+	}
+	rec := []string{
+		e.ID.String(), e.CreatedAt.UTC().Format(time.RFC3339Nano), "",
+		uuidString(e.UserID), uuidString(e.ApiKeyID), e.Action, e.Resource,
+		uuidString(e.ResourceID), "", "", string(e.Detail), hex.EncodeToString(e.Hash),
+	}
+	if e.Seq != nil {
+		rec[2] = strconv.FormatInt(*e.Seq, 10)
+	}
+	if e.IpAddress != nil {
+		rec[8] = e.IpAddress.String()
+	}
+	if e.UserAgent != nil {
+		rec[9] = *e.UserAgent
+	}
+	return rec
+}
+
+// handleHistory returns the timeline of one resource, oldest first: its
+// audit entries, plus escalation events for alerts and change history for
+// incidents. The cursor for the next page is in X-Next-Cursor.
+func (h *Handler) handleHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := httpserver.URLParamUUID(r, "id")
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid resource ID")
+		return
+	}
+	params, err := httpserver.ParseCursorParams(r)
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	resource := chi.URLParam(r, "resource")
+	events, err := History(r.Context(), tenant.ConnFromContext(r.Context()), resource, id, params.After, params.Limit+1)
+	if err != nil {
+		h.logger.Error("reading resource history", "error", err, "resource", resource, "id", id)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to read resource history")
+		return
+	}
+
+	page := httpserver.NewCursorPage(events, params.Limit, func(e HistoryEvent) httpserver.Cursor {
+		return httpserver.Cursor{CreatedAt: e.At, ID: e.ID}
+	})
+	if page.NextCursor != nil {
+		w.Header().Set("X-Next-Cursor", *page.NextCursor)
+	}
+	httpserver.Respond(w, http.StatusOK, page.Items)
+}
+
+// handleVerify walks the tenant's audit hash chain and reports tampering.
+func (h *Handler) handleVerify(w http.ResponseWriter, r *http.Request) {
+	res, err := Verify(r.Context(), tenant.ConnFromContext(r.Context()))
+	if err != nil {
+		h.logger.Error("verifying audit log", "error", err)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to verify audit log")
+		return
+	}
+	if !res.Valid {
+		h.logger.Warn("audit log verification failed", "problems", res.ProblemCount, "head_seq", res.HeadSeq)
+	}
+	httpserver.Respond(w, http.StatusOK, res)
 }
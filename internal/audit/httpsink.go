@@ -0,0 +1,182 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/wisbric/nightowl/internal/db"
+)
+
+// httpSink posts each batch to a URL. The encoder decides the body: a JSON
+// array of events, or an OTLP logs request.
+type httpSink struct {
+	url     string
+	headers map[string]string
+	encode  func(tenant string, entries []db.AuditLog) ([]byte, error)
+	client  *http.Client
+}
+
+func newHTTPSink(cfg SinkConfig, tlsConfig *tls.Config, encode func(string, []db.AuditLog) ([]byte, error)) *httpSink {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &httpSink{
+		url:     cfg.URL,
+		headers: cfg.Headers,
+		encode:  encode,
+		client:  &http.Client{Timeout: sinkTimeout, Transport: transport},
+	}
+}
+
+func (s *httpSink) Send(ctx context.Context, tenant string, entries []db.AuditLog) error {
+	body, err := s.encode(tenant, entries)
+	if err != nil {
+		return fmt.Errorf("encoding batch: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "nightowl-audit")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting to %s: %w", s.url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("posting to %s: status %d: %s", s.url, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return nil
+}
+
+func (s *httpSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+func encodeJSONBatch(tenant string, entries []db.AuditLog) ([]byte, error) {
+	events := make([]Event, len(entries))
+	for i, e := range entries {
+		events[i] = newEvent(tenant, e)
+	}
+	return json.Marshal(events)
+}
+
+// OTLP/HTTP JSON encoding of an ExportLogsServiceRequest. Only the fields
+// NightOwl sets are modelled; 64-bit integers are strings as in the
+// protobuf JSON mapping.
+type (
+	otlpLogsRequest struct {
+		ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+	}
+	otlpResourceLogs struct {
+		Resource  otlpResource    `json:"resource"`
+		ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeLogs struct {
+		Scope      otlpScope       `json:"scope"`
+		LogRecords []otlpLogRecord `json:"logRecords"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpLogRecord struct {
+		TimeUnixNano         string         `json:"timeUnixNano"`
+		ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+		SeverityNumber       int            `json:"severityNumber"`
+		SeverityText         string         `json:"severityText"`
+		EventName            string         `json:"eventName"`
+		Body                 otlpAnyValue   `json:"body"`
+		Attributes           []otlpKeyValue `json:"attributes"`
+	}
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		StringValue *string `json:"stringValue,omitempty"`
+		IntValue    *string `json:"intValue,omitempty"`
+	}
+)
+
+// otlpSeverityInfo is SEVERITY_NUMBER_INFO.
+const otlpSeverityInfo = 9
+
+func otlpString(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &value}}
+}
+
+func otlpInt(key string, value int64) otlpKeyValue {
+	s := strconv.FormatInt(value, 10)
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{IntValue: &s}}
+}
+
+// encodeOTLPBatch renders a batch as one resource (the tenant) with one log
+// record per entry. The body is the full event as JSON; the attributes use
+// OpenTelemetry semantic convention names where one exists.
+func encodeOTLPBatch(tenant string, entries []db.AuditLog) ([]byte, error) {
+	records := make([]otlpLogRecord, len(entries))
+	for i, e := range entries {
+		ev := newEvent(tenant, e)
+		body, err := json.Marshal(ev)
+		if err != nil {
+			return nil, err
+		}
+		ts := strconv.FormatInt(ev.Time.UnixNano(), 10)
+		attrs := []otlpKeyValue{
+			otlpString("nightowl.audit.id", ev.ID.String()),
+			otlpInt("nightowl.audit.seq", ev.Seq),
+			otlpString("nightowl.audit.action", ev.Action),
+			otlpString("nightowl.audit.resource", ev.Resource),
+			otlpString("nightowl.audit.hash", ev.Hash),
+		}
+		if ev.ResourceID != nil {
+			attrs = append(attrs, otlpString("nightowl.audit.resource_id", ev.ResourceID.String()))
+		}
+		if ev.UserID != nil {
+			attrs = append(attrs, otlpString("user.id", ev.UserID.String()))
+		}
+		if ev.APIKeyID != nil {
+			attrs = append(attrs, otlpString("nightowl.audit.api_key_id", ev.APIKeyID.String()))
+		}
+		if ev.IPAddress != "" {
+			attrs = append(attrs, otlpString("client.address", ev.IPAddress))
+		}
+		if ev.UserAgent != "" {
+			attrs = append(attrs, otlpString("user_agent.original", ev.UserAgent))
+		}
+		bodyStr := string(body)
+		records[i] = otlpLogRecord{
+			TimeUnixNano:         ts,
+			ObservedTimeUnixNano: ts,
+			SeverityNumber:       otlpSeverityInfo,
+			SeverityText:         "INFO",
+			EventName:            "nightowl.audit." + ev.Action,
+			Body:                 otlpAnyValue{StringValue: &bodyStr},
+			Attributes:           attrs,
+		}
+	}
+
+	return json.Marshal(otlpLogsRequest{ResourceLogs: []otlpResourceLogs{{
+		Resource: otlpResource{Attributes: []otlpKeyValue{
+			otlpString("service.name", "nightowl"),
+			otlpString("nightowl.tenant", tenant),
+		}},
+		ScopeLogs: []otlpScopeLogs{{Scope: otlpScope{Name: "nightowl.audit"}, LogRecords: records}},
+	}}})
+}
@@ -0,0 +1,154 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// captureServer records the body and headers of each request and answers
+// with status.
+func captureServer(t *testing.T, status int) (*httptest.Server, <-chan *http.Request, <-chan []byte) {
+	t.Helper()
+	reqs := make(chan *http.Request, 4)
+	bodies := make(chan []byte, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		reqs <- r
+		bodies <- body
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("receiver says no\n"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, reqs, bodies
+}
+
+func TestHTTPSink_PostsJSONArray(t *testing.T) {
+	srv, reqs, bodies := captureServer(t, http.StatusAccepted)
+	sink, err := NewSink(SinkHTTP, SinkConfig{URL: srv.URL + "/ingest", Headers: map[string]string{"Authorization": "Splunk abc"}})
+	if err != nil {
+		t.Fatalf("NewSink: %v", err)
+	}
+	defer func() { _ = sink.Close() }()
+
+	logs := testAuditLogs(3)
+	if err := sink.Send(context.Background(), "acme", logs); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	r := <-reqs
+	if r.URL.Path != "/ingest" || r.Header.Get("Authorization") != "Splunk abc" ||
+		r.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("request = %s %s %v", r.Method, r.URL, r.Header)
+	}
+	var events []Event
+	if err := json.Unmarshal(<-bodies, &events); err != nil {
+		t.Fatalf("body: %v", err)
+	}
+	if len(events) != 3 || events[2].Seq != 3 || events[0].Tenant != "acme" || events[1].ID != logs[1].ID {
+		t.Errorf("events = %+v", events)
+	}
+	if string(events[0].Detail) != `{"count":3,"title":"Disk full"}` {
+		t.Errorf("detail = %s", events[0].Detail)
+	}
+}
+
+func TestHTTPSink_RejectedBatchFails(t *testing.T) {
+	srv, _, _ := captureServer(t, http.StatusServiceUnavailable)
+	sink, _ := NewSink(SinkHTTP, SinkConfig{URL: srv.URL})
+	defer func() { _ = sink.Close() }()
+
+	err := sink.Send(context.Background(), "acme", testAuditLogs(1))
+	if err == nil || !strings.Contains(err.Error(), "status 503: receiver says no") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestOTLPSink_PostsLogRecords(t *testing.T) {
+	srv, reqs, bodies := captureServer(t, http.StatusOK)
+	sink, err := NewSink(SinkOTLP, SinkConfig{URL: srv.URL + "/v1/logs"})
+	if err != nil {
+		t.Fatalf("NewSink: %v", err)
+	}
+	defer func() { _ = sink.Close() }()
+
+	logs := testAuditLogs(2)
+	if err := sink.Send(context.Background(), "acme", logs); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if r := <-reqs; r.URL.Path != "/v1/logs" {
+		t.Errorf("path = %s", r.URL.Path)
+	}
+
+	var req otlpLogsRequest
+	if err := json.Unmarshal(<-bodies, &req); err != nil {
+		t.Fatalf("body: %v", err)
+	}
+	if len(req.ResourceLogs) != 1 || len(req.ResourceLogs[0].ScopeLogs) != 1 {
+		t.Fatalf("request = %+v", req)
+	}
+	attrs := map[string]string{}
+	for _, kv := range req.ResourceLogs[0].Resource.Attributes {
+		attrs[kv.Key] = *kv.Value.StringValue
+	}
+	if attrs["service.name"] != "nightowl" || attrs["nightowl.tenant"] != "acme" {
+		t.Errorf("resource attributes = %v", attrs)
+	}
+
+	records := req.ResourceLogs[0].ScopeLogs[0].LogRecords
+	if len(records) != 2 {
+		t.Fatalf("got %d log records", len(records))
+	}
+	rec := records[1]
+	if rec.TimeUnixNano != "1772366401123456000" || rec.SeverityNumber != otlpSeverityInfo || rec.EventName != "nightowl.audit.update" {
+		t.Errorf("record = %+v", rec)
+	}
+	values := map[string]otlpAnyValue{}
+	for _, kv := range rec.Attributes {
+		values[kv.Key] = kv.Value
+	}
+	if v := values["nightowl.audit.seq"]; v.IntValue == nil || *v.IntValue != "2" {
+		t.Errorf("seq attribute = %+v", v)
+	}
+	if v := values["client.address"]; v.StringValue == nil || *v.StringValue != "2001:db8::1" {
+		t.Errorf("client.address = %+v", v)
+	}
+	var ev Event
+	if err := json.Unmarshal([]byte(*rec.Body.StringValue), &ev); err != nil || ev.ID != logs[1].ID {
+		t.Errorf("body = %s (%v)", *rec.Body.StringValue, err)
+	}
+}
+
+func TestSinkConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		kind string
+		cfg  SinkConfig
+		want string
+	}{
+		{"syslog ok", SinkSyslog, SinkConfig{Address: "siem.example.com:6514", TLS: true}, ""},
+		{"syslog without port", SinkSyslog, SinkConfig{Address: "siem.example.com"}, "address must be host:port"},
+		{"http ok", SinkHTTP, SinkConfig{URL: "https://siem.example.com/in"}, ""},
+		{"otlp without scheme", SinkOTLP, SinkConfig{URL: "collector:4318"}, "url must be"},
+		{"unknown kind", "kafka", SinkConfig{}, "kind must be one of"},
+		{"batch too large", SinkHTTP, SinkConfig{URL: "http://x", BatchSize: 5000}, "batch_size"},
+		{"bad ca", SinkHTTP, SinkConfig{URL: "https://x", CACert: "nope"}, "ca_cert"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate(tt.kind)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("err = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/wisbric/core/pkg/httpserver"
+
+	"github.com/wisbric/nightowl/internal/db"
+)
+
+// auditColumns is the column list scanned by scanEntry.
+const auditColumns = `id, user_id, api_key_id, action, resource, resource_id, detail,
+	ip_address, user_agent, created_at, seq, prev_hash, hash`
+
+func scanEntry(row pgx.Row) (db.AuditLog, error) {
+	var e db.AuditLog
+	err := row.Scan(&e.ID, &e.UserID, &e.ApiKeyID, &e.Action, &e.Resource, &e.ResourceID, &e.Detail,
+		&e.IpAddress, &e.UserAgent, &e.CreatedAt, &e.Seq, &e.PrevHash, &e.Hash)
+	return e, err
+}
+
+// Filter selects audit entries. Zero fields match everything.
+type Filter struct {
+	UserID     *uuid.UUID
+	APIKeyID   *uuid.UUID
+	Actions    []string
+	Resource   string
+	ResourceID *uuid.UUID
+	// IP matches a single address or a CIDR range.
+	IP    *netip.Prefix
+	Since *time.Time
+	Until *time.Time
+	// Query is a case-insensitive substring of the action, resource or detail.
+	Query string
+}
+
+// ParseFilter reads a Filter from query parameters: user_id, api_key_id,
+// action (repeatable or comma-separated), resource, resource_id, ip,
+// since, until (RFC 3339) and q.
+func ParseFilter(r *http.Request) (Filter, error) {
+	q := r.URL.Query()
+	f := Filter{
+		Resource: q.Get("resource"),
+		Query:    strings.TrimSpace(q.Get("q")),
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  **uuid.UUID
+	}{{"user_id", &f.UserID}, {"api_key_id", &f.APIKeyID}, {"resource_id", &f.ResourceID}} {
+		if v := q.Get(p.name); v != "" {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				return Filter{}, fmt.Errorf("%s must be a UUID", p.name)
+			}
+			*p.dst = &id
+		}
+	}
+
+	for _, v := range q["action"] {
+		for a := range strings.SplitSeq(v, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				f.Actions = append(f.Actions, a)
+			}
+		}
+	}
+
+	if v := q.Get("ip"); v != "" {
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(v)
+			if aerr != nil {
+				return Filter{}, fmt.Errorf("ip must be an IP address or CIDR range")
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefix = prefix.Masked()
+		f.IP = &prefix
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"since", &f.Since}, {"until", &f.Until}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return Filter{}, fmt.Errorf("%s must be an RFC 3339 timestamp", p.name)
+			}
+			*p.dst = &t
+		}
+	}
+	if f.Since != nil && f.Until != nil && !f.Until.After(*f.Since) {
+		return Filter{}, fmt.Errorf("until must be after since")
+	}
+	return f, nil
+}
+
+// where renders the filter as SQL conditions with numbered arguments.
+func (f Filter) where() ([]string, []any) {
+	var conditions []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.UserID != nil {
+		add("user_id = $%d", *f.UserID)
+	}
+	if f.APIKeyID != nil {
+		add("api_key_id = $%d", *f.APIKeyID)
+	}
+	if len(f.Actions) > 0 {
+		add("action = ANY($%d)", f.Actions)
+	}
+	if f.Resource != "" {
+		add("resource = $%d", f.Resource)
+	}
+	if f.ResourceID != nil {
+		add("resource_id = $%d", *f.ResourceID)
+	}
+	if f.IP != nil {
+		add("ip_address <<= $%d", *f.IP)
+	}
+	if f.Since != nil {
+		add("created_at >= $%d", *f.Since)
+	}
+	if f.Until != nil {
+		add("created_at < $%d", *f.Until)
+	}
+	if f.Query != "" {
+		pattern := "%" + likeEscaper.Replace(f.Query) + "%"
+		args = append(args, pattern)
+		n := len(args)
+		conditions = append(conditions,
+			fmt.Sprintf("(action ILIKE $%d OR resource ILIKE $%d OR detail::text ILIKE $%d)", n, n, n))
+	}
+	return conditions, args
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// searchQuery builds the newest-first query for a filter. With a cursor it
+// continues after the cursor's entry; limit 0 means no limit.
+func searchQuery(f Filter, after *httpserver.Cursor, limit int) (string, []any) {
+	conditions, args := f.where()
+	if after != nil {
+		args = append(args, after.CreatedAt, after.ID)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_log`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	if limit > 0 {
+		args = append(args, limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	return query, args
+}
+
+// Search returns up to limit entries matching f, newest first, after the
+// cursor.
+func Search(ctx context.Context, dbtx db.DBTX, f Filter, after *httpserver.Cursor, limit int) ([]db.AuditLog, error) {
+	query, args := searchQuery(f, after, limit)
+	rows, err := dbtx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("searching audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []db.AuditLog{}
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning audit entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Each streams every entry matching f, newest first, to fn.
+func Each(ctx context.Context, dbtx db.DBTX, f Filter, fn func(db.AuditLog) error) error {
+	query, args := searchQuery(f, nil, 0)
+	rows, err := dbtx.Query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("searching audit log: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return fmt.Errorf("scanning audit entry: %w", err)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Event sources in a resource history.
+const (
+	SourceAudit           = "audit"
+	SourceEscalation      = "escalation"
+	SourceIncidentHistory = "incident_history"
+)
+
+// HistoryEvent is one thing that happened to a resource: an audit entry,
+// an escalation event of an alert, or a change to an incident.
+type HistoryEvent struct {
+	At       time.Time       `json:"at"`
+	Source   string          `json:"source"`
+	ID       uuid.UUID       `json:"id"`
+	Action   string          `json:"action"`
+	UserID   *uuid.UUID      `json:"user_id,omitempty"`
+	APIKeyID *uuid.UUID      `json:"api_key_id,omitempty"`
+	Detail   json.RawMessage `json:"detail"`
+}
+
+// historyQuery builds the oldest-first timeline of a resource. Alerts add
+// their escalation events, incidents their change history.
+func historyQuery(resource string, id uuid.UUID, after *httpserver.Cursor, limit int) (string, []any) {
+	args := []any{resource, id}
+	parts := []string{`SELECT created_at, 'audit' AS source, id, action, user_id, api_key_id, detail
+		FROM audit_log WHERE resource = $1 AND resource_id = $2`}
+	switch resource {
+	case "alert":
+		parts = append(parts, `SELECT created_at, 'escalation', id, action, NULL::uuid, NULL::uuid,
+			jsonb_build_object('tier', tier, 'policy_id', policy_id, 'target_user_id', target_user_id,
+				'notify_method', notify_method, 'notify_result', notify_result)
+		FROM escalation_events WHERE alert_id = $2`)
+	case "incident":
+		parts = append(parts, `SELECT created_at, 'incident_history', id, change_type, changed_by, NULL::uuid, diff
+		FROM incident_history WHERE incident_id = $2`)
+	}
+
+	query := `SELECT created_at, source, id, action, user_id, api_key_id, detail FROM (` +
+		strings.Join(parts, " UNION ALL ") + `) h`
+	if after != nil {
+		args = append(args, after.CreatedAt, after.ID)
+		query += " WHERE (created_at, id) > ($3, $4)"
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY created_at, id LIMIT $%d", len(args))
+	return query, args
+}
+
+// History returns up to limit events of a resource, oldest first, after
+// the cursor.
+func History(ctx context.Context, dbtx db.DBTX, resource string, id uuid.UUID, after *httpserver.Cursor, limit int) ([]HistoryEvent, error) {
+	query, args := historyQuery(resource, id, after, limit)
+	rows, err := dbtx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("reading resource history: %w", err)
+	}
+	defer rows.Close()
+
+	events := []HistoryEvent{}
+	for rows.Next() {
+		var e HistoryEvent
+		var detail []byte
+		if err := rows.Scan(&e.At, &e.Source, &e.ID, &e.Action, &e.UserID, &e.APIKeyID, &detail); err != nil {
+			return nil, fmt.Errorf("scanning history event: %w", err)
+		}
+		e.Detail = detail
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,129 @@
+package audit
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wisbric/core/pkg/httpserver"
+)
+
+func TestParseFilter(t *testing.T) {
+	uid := uuid.New()
+	r := httptest.NewRequest("GET", "/?user_id="+uid.String()+
+		"&action=create,update&action=delete&resource=incident&ip=10.1.2.3/16"+
+		"&since=2026-03-01T00:00:00Z&until=2026-03-02T00:00:00Z&q=+disk+", nil)
+
+	f, err := ParseFilter(r)
+	if err != nil {
+		t.Fatalf("ParseFilter: %v", err)
+	}
+	if f.UserID == nil || *f.UserID != uid {
+		t.Errorf("UserID = %v, want %v", f.UserID, uid)
+	}
+	if strings.Join(f.Actions, ",") != "create,update,delete" {
+		t.Errorf("Actions = %v", f.Actions)
+	}
+	if f.IP == nil || f.IP.String() != "10.1.0.0/16" {
+		t.Errorf("IP = %v, want the masked range", f.IP)
+	}
+	if f.Since == nil || f.Until == nil || f.Until.Sub(*f.Since) != 24*time.Hour {
+		t.Errorf("Since = %v, Until = %v", f.Since, f.Until)
+	}
+	if f.Resource != "incident" || f.Query != "disk" {
+		t.Errorf("Resource = %q, Query = %q", f.Resource, f.Query)
+	}
+}
+
+func TestParseFilter_SingleAddress(t *testing.T) {
+	f, err := ParseFilter(httptest.NewRequest("GET", "/?ip=2001:db8::1", nil))
+	if err != nil {
+		t.Fatalf("ParseFilter: %v", err)
+	}
+	if *f.IP != netip.MustParsePrefix("2001:db8::1/128") {
+		t.Errorf("IP = %v", f.IP)
+	}
+}
+
+func TestParseFilter_Invalid(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"user_id=nope", "user_id must be a UUID"},
+		{"resource_id=1", "resource_id must be a UUID"},
+		{"ip=10.0.0", "ip must be"},
+		{"since=yesterday", "since must be"},
+		{"since=2026-03-02T00:00:00Z&until=2026-03-01T00:00:00Z", "until must be after since"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := ParseFilter(httptest.NewRequest("GET", "/?"+tt.query, nil))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchQuery(t *testing.T) {
+	since := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	f := Filter{Actions: []string{"delete"}, Since: &since, Query: `50%_off\`}
+	after := &httpserver.Cursor{CreatedAt: since.Add(time.Hour), ID: uuid.New()}
+
+	query, args := searchQuery(f, after, 26)
+	for _, want := range []string{
+		"action = ANY($1)",
+		"created_at >= $2",
+		"(action ILIKE $3 OR resource ILIKE $3 OR detail::text ILIKE $3)",
+		"(created_at, id) < ($4, $5)",
+		"ORDER BY created_at DESC, id DESC LIMIT $6",
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query lacks %q:\n%s", want, query)
+		}
+	}
+	if len(args) != 6 || args[2] != `%50\%\_off\\%` || args[5] != 26 {
+		t.Errorf("args = %v", args)
+	}
+}
+
+func TestSearchQuery_Unfiltered(t *testing.T) {
+	query, args := searchQuery(Filter{}, nil, 0)
+	if strings.Contains(query, "WHERE") || strings.Contains(query, "LIMIT") || len(args) != 0 {
+		t.Errorf("query = %s, args = %v", query, args)
+	}
+}
+
+func TestHistoryQuery(t *testing.T) {
+	id := uuid.New()
+	tests := []struct {
+		resource string
+		want     string
+		absent   string
+	}{
+		{"alert", "FROM escalation_events WHERE alert_id = $2", "incident_history"},
+		{"incident", "FROM incident_history WHERE incident_id = $2", "escalation_events"},
+		{"service", "FROM audit_log", "UNION ALL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.resource, func(t *testing.T) {
+			query, args := historyQuery(tt.resource, id, nil, 10)
+			if !strings.Contains(query, tt.want) || strings.Contains(query, tt.absent) {
+				t.Errorf("query:\n%s", query)
+			}
+			if len(args) != 3 || args[0] != tt.resource || args[1] != id || args[2] != 10 {
+				t.Errorf("args = %v", args)
+			}
+		})
+	}
+
+	query, args := historyQuery("alert", id, &httpserver.Cursor{CreatedAt: time.Now(), ID: uuid.New()}, 10)
+	if !strings.Contains(query, "(created_at, id) > ($3, $4) ORDER BY created_at, id LIMIT $5") || len(args) != 5 {
+		t.Errorf("query = %s, args = %v", query, args)
+	}
+}
@@ -0,0 +1,225 @@
+package audit
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wisbric/nightowl/internal/db"
+)
+
+// Sink kinds.
+const (
+	// SinkSyslog sends RFC 5424 messages over TCP or TLS, framed by octet
+	// counting (RFC 6587, RFC 5425).
+	SinkSyslog = "syslog"
+	// SinkHTTP posts batches as a JSON array.
+	SinkHTTP = "http"
+	// SinkOTLP posts batches as OTLP/HTTP JSON log records.
+	SinkOTLP = "otlp"
+)
+
+// SinkKinds lists the sink kinds a sink can be created with.
+var SinkKinds = []string{SinkSyslog, SinkHTTP, SinkOTLP}
+
+const (
+	defaultBatchSize = 100
+	maxBatchSize     = 1000
+	sinkTimeout      = 15 * time.Second
+)
+
+// Sink delivers batches of a tenant's audit entries to an external system.
+// Send either delivers the whole batch or returns an error, in which case the
+// batch is sent again later.
+type Sink interface {
+	Send(ctx context.Context, tenant string, entries []db.AuditLog) error
+	Close() error
+}
+
+// SinkConfig holds the settings of a sink. Which fields apply depends on the
+// kind.
+type SinkConfig struct {
+	// Address is the host:port of a syslog receiver.
+	Address string `json:"address,omitempty"`
+	// TLS enables TLS for syslog; HTTP sinks use TLS for https URLs.
+	TLS bool `json:"tls,omitempty"`
+	// Hostname and AppName fill the syslog header. Hostname defaults to the
+	// host's name and AppName to "nightowl".
+	Hostname string `json:"hostname,omitempty"`
+	AppName  string `json:"app_name,omitempty"`
+
+	// URL is the endpoint of an HTTP or OTLP sink, for OTLP typically
+	// http://collector:4318/v1/logs.
+	URL string `json:"url,omitempty"`
+	// Headers are added to every HTTP and OTLP request, e.g. Authorization.
+	// Their values are write-only: responses show redactedValue instead.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// CACert is a PEM bundle trusted instead of the system roots.
+	CACert             string `json:"ca_cert,omitempty"`
+	ServerName         string `json:"server_name,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+
+	// BatchSize is the number of entries per delivery (default 100).
+	BatchSize int `json:"batch_size,omitempty"`
+}
+
+// redactedValue stands in for header values in responses. Sending it back
+// on update keeps the stored value.
+const redactedValue = "********"
+
+// redacted returns the config with header values masked.
+func (c SinkConfig) redacted() SinkConfig {
+	if len(c.Headers) == 0 {
+		return c
+	}
+	headers := make(map[string]string, len(c.Headers))
+	for name := range c.Headers {
+		headers[name] = redactedValue
+	}
+	c.Headers = headers
+	return c
+}
+
+// keepRedacted replaces header values sent back redacted with the stored
+// ones.
+func (c *SinkConfig) keepRedacted(stored SinkConfig) error {
+	for name, value := range c.Headers {
+		if value != redactedValue {
+			continue
+		}
+		storedValue, ok := stored.Headers[name]
+		if !ok {
+			return fmt.Errorf("header %s has no stored value to keep", name)
+		}
+		c.Headers[name] = storedValue
+	}
+	return nil
+}
+
+func (c SinkConfig) batchSize() int {
+	if c.BatchSize <= 0 {
+		return defaultBatchSize
+	}
+	return c.BatchSize
+}
+
+func (c SinkConfig) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify, //nolint:gosec // opt-in per sink for self-signed receivers
+	}
+	if c.CACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(c.CACert)) {
+			return nil, fmt.Errorf("ca_cert contains no PEM certificates")
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// Validate checks the settings for a sink kind.
+func (c SinkConfig) Validate(kind string) error {
+	switch kind {
+	case SinkSyslog:
+		if _, _, err := net.SplitHostPort(c.Address); err != nil {
+			return fmt.Errorf("address must be host:port")
+		}
+	case SinkHTTP, SinkOTLP:
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("url must be an http or https URL")
+		}
+	default:
+		return fmt.Errorf("kind must be one of %v", SinkKinds)
+	}
+	if c.BatchSize < 0 || c.BatchSize > maxBatchSize {
+		return fmt.Errorf("batch_size must be between 1 and %d", maxBatchSize)
+	}
+	if _, err := c.tlsConfig(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewSink creates a sink of the given kind.
+func NewSink(kind string, cfg SinkConfig) (Sink, error) {
+	if err := cfg.Validate(kind); err != nil {
+		return nil, err
+	}
+	tlsConfig, _ := cfg.tlsConfig()
+	switch kind {
+	case SinkSyslog:
+		return newSyslogSink(cfg, tlsConfig), nil
+	case SinkHTTP:
+		return newHTTPSink(cfg, tlsConfig, encodeJSONBatch), nil
+	default:
+		return newHTTPSink(cfg, tlsConfig, encodeOTLPBatch), nil
+	}
+}
+
+// Event is the JSON form of an audit entry sent to sinks. Hashes are hex so
+// the SIEM can check entries against the chain.
+type Event struct {
+	Tenant     string          `json:"tenant"`
+	ID         uuid.UUID       `json:"id"`
+	Seq        int64           `json:"seq"`
+	Time       time.Time       `json:"time"`
+	UserID     *uuid.UUID      `json:"user_id,omitempty"`
+	APIKeyID   *uuid.UUID      `json:"api_key_id,omitempty"`
+	Action     string          `json:"action"`
+	Resource   string          `json:"resource"`
+	ResourceID *uuid.UUID      `json:"resource_id,omitempty"`
+	Detail     json.RawMessage `json:"detail"`
+	IPAddress  string          `json:"ip_address,omitempty"`
+	UserAgent  string          `json:"user_agent,omitempty"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+func newEvent(tenant string, e db.AuditLog) Event {
+	uuidPtr := func(u pgtype.UUID) *uuid.UUID {
+		if !u.Valid {
+			return nil
+		}
+		id := uuid.UUID(u.Bytes)
+		return &id
+	}
+	ev := Event{
+		Tenant:     tenant,
+		ID:         e.ID,
+		Time:       e.CreatedAt.UTC(),
+		UserID:     uuidPtr(e.UserID),
+		APIKeyID:   uuidPtr(e.ApiKeyID),
+		Action:     e.Action,
+		Resource:   e.Resource,
+		ResourceID: uuidPtr(e.ResourceID),
+		Detail:     json.RawMessage(e.Detail),
+		PrevHash:   hex.EncodeToString(e.PrevHash),
+		Hash:       hex.EncodeToString(e.Hash),
+	}
+	if len(ev.Detail) == 0 {
+		ev.Detail = json.RawMessage("{}")
+	}
+	if e.Seq != nil {
+		ev.Seq = *e.Seq
+	}
+	if e.IpAddress != nil {
+		ev.IPAddress = e.IpAddress.String()
+	}
+	if e.UserAgent != nil {
+		ev.UserAgent = *e.UserAgent
+	}
+	return ev
+}
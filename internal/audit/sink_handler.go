@@ -0,0 +1,232 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wisbric/core/pkg/auth"
+	"github.com/wisbric/core/pkg/httpserver"
+
+	"github.com/wisbric/nightowl/internal/db"
+	"github.com/wisbric/nightowl/pkg/tenant"
+)
+
+// sinkRoutes returns the admin routes for managing audit sinks. Responses
+// mask header values, which hold the receivers' credentials.
+func (h *Handler) sinkRoutes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(auth.RequireRole(auth.RoleAdmin))
+	r.Get("/", h.handleListSinks)
+	r.Post("/", h.handleCreateSink)
+	r.Route("/{id}", func(r chi.Router) {
+		r.Get("/", h.handleGetSink)
+		r.Put("/", h.handleUpdateSink)
+		r.Delete("/", h.handleDeleteSink)
+		r.Post("/enable", h.handleEnableSink)
+		r.Post("/disable", h.handleDisableSink)
+		r.Post("/test", h.handleTestSink)
+	})
+	return r
+}
+
+func sinkStore(r *http.Request) *SinkStore {
+	return NewSinkStore(tenant.ConnFromContext(r.Context()))
+}
+
+func parseSinkID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid sink ID")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// decodeSinkRequest decodes and validates a create or update body.
+func decodeSinkRequest(w http.ResponseWriter, r *http.Request) (SinkRequest, bool) {
+	var req SinkRequest
+	if !httpserver.DecodeAndValidate(w, r, &req) {
+		return req, false
+	}
+	if err := req.Config.Validate(req.Kind); err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return req, false
+	}
+	return req, true
+}
+
+func (h *Handler) logSink(r *http.Request, action string, s ConfiguredSink) {
+	detail, _ := json.Marshal(map[string]string{"name": s.Name, "kind": s.Kind})
+	h.audit.LogFromRequest(r, action, "audit_sink", s.ID, detail)
+}
+
+func (h *Handler) handleListSinks(w http.ResponseWriter, r *http.Request) {
+	items, err := sinkStore(r).List(r.Context())
+	if err != nil {
+		h.respondSinkErr(w, "listing audit sinks", err)
+		return
+	}
+	for i := range items {
+		items[i] = items[i].redacted()
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]any{"sinks": items, "count": len(items)})
+}
+
+func (h *Handler) handleCreateSink(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeSinkRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := req.Config.keepRedacted(SinkConfig{}); err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	var createdBy pgtype.UUID
+	if id := auth.FromContext(r.Context()); id != nil && id.UserID != nil {
+		createdBy = pgtype.UUID{Bytes: *id.UserID, Valid: true}
+	}
+	s, err := sinkStore(r).Create(r.Context(), req, createdBy)
+	if err != nil {
+		h.respondSinkErr(w, "creating audit sink", err)
+		return
+	}
+	h.logSink(r, "create", s)
+	httpserver.Respond(w, http.StatusCreated, s.redacted())
+}
+
+func (h *Handler) handleGetSink(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseSinkID(w, r)
+	if !ok {
+		return
+	}
+	s, err := sinkStore(r).Get(r.Context(), id)
+	if err != nil {
+		h.respondSinkErr(w, "getting audit sink", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, s.redacted())
+}
+
+func (h *Handler) handleUpdateSink(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseSinkID(w, r)
+	if !ok {
+		return
+	}
+	req, ok := decodeSinkRequest(w, r)
+	if !ok {
+		return
+	}
+	store := sinkStore(r)
+	current, err := store.Get(r.Context(), id)
+	if err != nil {
+		h.respondSinkErr(w, "getting audit sink", err)
+		return
+	}
+	if err := req.Config.keepRedacted(current.Config); err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	s, err := store.Update(r.Context(), id, req)
+	if err != nil {
+		h.respondSinkErr(w, "updating audit sink", err)
+		return
+	}
+	h.logSink(r, "update", s)
+	httpserver.Respond(w, http.StatusOK, s.redacted())
+}
+
+func (h *Handler) handleEnableSink(w http.ResponseWriter, r *http.Request) {
+	h.setSinkEnabled(w, r, true)
+}
+
+func (h *Handler) handleDisableSink(w http.ResponseWriter, r *http.Request) {
+	h.setSinkEnabled(w, r, false)
+}
+
+func (h *Handler) setSinkEnabled(w http.ResponseWriter, r *http.Request, enabled bool) {
+	id, ok := parseSinkID(w, r)
+	if !ok {
+		return
+	}
+	s, err := sinkStore(r).SetEnabled(r.Context(), id, enabled)
+	if err != nil {
+		h.respondSinkErr(w, "updating audit sink", err)
+		return
+	}
+	action := "disable"
+	if enabled {
+		action = "enable"
+	}
+	h.logSink(r, action, s)
+	httpserver.Respond(w, http.StatusOK, s.redacted())
+}
+
+func (h *Handler) handleDeleteSink(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseSinkID(w, r)
+	if !ok {
+		return
+	}
+	if err := sinkStore(r).Delete(r.Context(), id); err != nil {
+		h.respondSinkErr(w, "deleting audit sink", err)
+		return
+	}
+	h.audit.LogFromRequest(r, "delete", "audit_sink", id, nil)
+	httpserver.Respond(w, http.StatusNoContent, nil)
+}
+
+// handleTestSink sends one synthetic entry to a sink right away, outside the
+// chain, so admins can check connectivity and parsing on the receiving side.
+func (h *Handler) handleTestSink(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseSinkID(w, r)
+	if !ok {
+		return
+	}
+	cs, err := sinkStore(r).Get(r.Context(), id)
+	if err != nil {
+		h.respondSinkErr(w, "getting audit sink", err)
+		return
+	}
+	sink, err := NewSink(cs.Kind, cs.Config)
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	defer func() { _ = sink.Close() }()
+
+	slug := ""
+	if info := tenant.FromContext(r.Context()); info != nil {
+		slug = info.Slug
+	}
+	entry := db.AuditLog{
+		ID:         uuid.New(),
+		Action:     "test",
+		Resource:   "audit_sink",
+		ResourceID: pgtype.UUID{Bytes: cs.ID, Valid: true},
+		Detail:     []byte(`{"message":"NightOwl audit sink test"}`),
+		CreatedAt:  time.Now(),
+	}
+	if err := sink.Send(r.Context(), slug, []db.AuditLog{entry}); err != nil {
+		httpserver.RespondError(w, http.StatusBadGateway, "sink_error", err.Error())
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]string{"status": "delivered"})
+}
+
+// respondSinkErr maps sink errors to HTTP responses.
+func (h *Handler) respondSinkErr(w http.ResponseWriter, what string, err error) {
+	switch {
+	case errors.Is(err, ErrSinkNotFound):
+		httpserver.RespondError(w, http.StatusNotFound, "not_found", err.Error())
+	case errors.Is(err, ErrSinkExists):
+		httpserver.RespondError(w, http.StatusConflict, "conflict", err.Error())
+	default:
+		h.logger.Error(what, "error", err)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed "+what)
+	}
+}
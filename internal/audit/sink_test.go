@@ -0,0 +1,45 @@
+package audit
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSinkConfig_RedactsHeaderValues(t *testing.T) {
+	cfg := SinkConfig{URL: "https://siem.example.com", Headers: map[string]string{"Authorization": "Splunk abc"}}
+	s := ConfiguredSink{Name: "splunk", Kind: SinkHTTP, Config: cfg}
+
+	body, err := json.Marshal(s.redacted())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), "Splunk abc") {
+		t.Errorf("response leaks the header value: %s", body)
+	}
+	if !strings.Contains(string(body), `"Authorization":"`+redactedValue+`"`) {
+		t.Errorf("response lacks the masked header: %s", body)
+	}
+	if s.Config.Headers["Authorization"] != "Splunk abc" {
+		t.Error("redacting changed the stored config")
+	}
+}
+
+func TestSinkConfig_KeepRedacted(t *testing.T) {
+	stored := SinkConfig{Headers: map[string]string{"Authorization": "Splunk abc", "X-Team": "sec"}}
+
+	// Sending the masked value back keeps the secret; new values replace it.
+	req := SinkConfig{Headers: map[string]string{"Authorization": redactedValue, "X-Team": "ops"}}
+	if err := req.keepRedacted(stored); err != nil {
+		t.Fatal(err)
+	}
+	if req.Headers["Authorization"] != "Splunk abc" || req.Headers["X-Team"] != "ops" {
+		t.Errorf("headers = %v", req.Headers)
+	}
+
+	// A masked value with nothing stored cannot be kept.
+	req = SinkConfig{Headers: map[string]string{"X-Token": redactedValue}}
+	if err := req.keepRedacted(stored); err == nil {
+		t.Error("expected an error for a masked header without a stored value")
+	}
+}
@@ -0,0 +1,254 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wisbric/nightowl/internal/db"
+)
+
+var (
+	// ErrSinkNotFound is returned when no sink matches.
+	ErrSinkNotFound = errors.New("audit sink not found")
+	// ErrSinkExists is returned when a sink name is taken.
+	ErrSinkExists = errors.New("an audit sink with this name already exists")
+)
+
+// ConfiguredSink is a tenant's forwarding destination and its delivery
+// state. DeliveredSeq is the chain position of the last entry it accepted.
+type ConfiguredSink struct {
+	ID           uuid.UUID  `json:"id"`
+	Name         string     `json:"name"`
+	Kind         string     `json:"kind"`
+	Config       SinkConfig `json:"config"`
+	Enabled      bool       `json:"enabled"`
+	DeliveredSeq int64      `json:"delivered_seq"`
+	DeliveredAt  *time.Time `json:"delivered_at,omitempty"`
+	// Pending is the number of chained entries not yet delivered.
+	Pending     int64      `json:"pending"`
+	Failures    int        `json:"failures"`
+	LastError   *string    `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+	CreatedBy   *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// redacted returns the sink with its header values masked, for responses.
+func (s ConfiguredSink) redacted() ConfiguredSink {
+	s.Config = s.Config.redacted()
+	return s
+}
+
+// SinkRequest is the JSON body for creating or updating a sink.
+type SinkRequest struct {
+	Name   string     `json:"name" validate:"required,min=2,max=100"`
+	Kind   string     `json:"kind" validate:"required"`
+	Config SinkConfig `json:"config"`
+	// Backfill, on create, forwards the existing chain from the start.
+	// Otherwise the sink starts with the next entry.
+	Backfill bool `json:"backfill"`
+}
+
+const sinkColumns = `id, name, kind, config, enabled, delivered_seq, delivered_at,
+	GREATEST((SELECT COALESCE(MAX(seq), 0) FROM audit_log) - delivered_seq, 0),
+	failures, last_error, last_error_at, created_by, created_at, updated_at`
+
+// SinkStore provides database operations for audit sinks.
+type SinkStore struct {
+	dbtx db.DBTX
+}
+
+// NewSinkStore creates a SinkStore backed by the given connection.
+func NewSinkStore(dbtx db.DBTX) *SinkStore {
+	return &SinkStore{dbtx: dbtx}
+}
+
+func scanSink(row pgx.Row) (ConfiguredSink, error) {
+	var s ConfiguredSink
+	var config []byte
+	var createdBy pgtype.UUID
+	if err := row.Scan(&s.ID, &s.Name, &s.Kind, &config, &s.Enabled, &s.DeliveredSeq, &s.DeliveredAt,
+		&s.Pending, &s.Failures, &s.LastError, &s.LastErrorAt, &createdBy, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ConfiguredSink{}, ErrSinkNotFound
+		}
+		return ConfiguredSink{}, err
+	}
+	if err := json.Unmarshal(config, &s.Config); err != nil {
+		return ConfiguredSink{}, fmt.Errorf("decoding sink config: %w", err)
+	}
+	if createdBy.Valid {
+		id := uuid.UUID(createdBy.Bytes)
+		s.CreatedBy = &id
+	}
+	return s, nil
+}
+
+// mapSinkUniqueViolation turns a duplicate-name write into ErrSinkExists.
+func mapSinkUniqueViolation(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrSinkExists
+	}
+	return err
+}
+
+func (s *SinkStore) list(ctx context.Context, where string) ([]ConfiguredSink, error) {
+	rows, err := s.dbtx.Query(ctx, `SELECT `+sinkColumns+` FROM audit_sinks `+where+` ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("listing audit sinks: %w", err)
+	}
+	defer rows.Close()
+
+	items := []ConfiguredSink{}
+	for rows.Next() {
+		sink, err := scanSink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning audit sink row: %w", err)
+		}
+		items = append(items, sink)
+	}
+	return items, rows.Err()
+}
+
+// List returns all sinks ordered by name.
+func (s *SinkStore) List(ctx context.Context) ([]ConfiguredSink, error) {
+	return s.list(ctx, "")
+}
+
+// ListEnabled returns the sinks entries are forwarded to.
+func (s *SinkStore) ListEnabled(ctx context.Context) ([]ConfiguredSink, error) {
+	return s.list(ctx, "WHERE enabled")
+}
+
+// Get returns one sink by ID.
+func (s *SinkStore) Get(ctx context.Context, id uuid.UUID) (ConfiguredSink, error) {
+	return scanSink(s.dbtx.QueryRow(ctx, `SELECT `+sinkColumns+` FROM audit_sinks WHERE id = $1`, id))
+}
+
+// Create inserts a sink. Without backfill it starts after the current head
+// of the chain.
+func (s *SinkStore) Create(ctx context.Context, req SinkRequest, createdBy pgtype.UUID) (ConfiguredSink, error) {
+	config, _ := json.Marshal(req.Config)
+	sink, err := scanSink(s.dbtx.QueryRow(ctx, `
+		INSERT INTO audit_sinks (name, kind, config, created_by, delivered_seq)
+		VALUES ($1, $2, $3, $4, CASE WHEN $5 THEN 0 ELSE (SELECT COALESCE(MAX(seq), 0) FROM audit_log) END)
+		RETURNING `+sinkColumns,
+		req.Name, req.Kind, config, createdBy, req.Backfill))
+	if err != nil {
+		return ConfiguredSink{}, fmt.Errorf("creating audit sink: %w", mapSinkUniqueViolation(err))
+	}
+	return sink, nil
+}
+
+// Update replaces a sink's name, kind and config. Its delivery position is
+// kept.
+func (s *SinkStore) Update(ctx context.Context, id uuid.UUID, req SinkRequest) (ConfiguredSink, error) {
+	config, _ := json.Marshal(req.Config)
+	sink, err := scanSink(s.dbtx.QueryRow(ctx, `
+		UPDATE audit_sinks SET name = $2, kind = $3, config = $4, updated_at = now()
+		WHERE id = $1
+		RETURNING `+sinkColumns, id, req.Name, req.Kind, config))
+	if err != nil {
+		return ConfiguredSink{}, mapSinkUniqueViolation(err)
+	}
+	return sink, nil
+}
+
+// SetEnabled pauses or resumes forwarding to a sink. A resumed sink catches
+// up from where it stopped.
+func (s *SinkStore) SetEnabled(ctx context.Context, id uuid.UUID, enabled bool) (ConfiguredSink, error) {
+	return scanSink(s.dbtx.QueryRow(ctx, `
+		UPDATE audit_sinks SET enabled = $2, updated_at = now()
+		WHERE id = $1
+		RETURNING `+sinkColumns, id, enabled))
+}
+
+// Delete removes a sink.
+func (s *SinkStore) Delete(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.dbtx.Exec(ctx, `DELETE FROM audit_sinks WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("deleting audit sink: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSinkNotFound
+	}
+	return nil
+}
+
+// claim locks an enabled sink for one delivery and returns its position.
+// ok is false when the sink is disabled, deleted or being delivered by
+// another process.
+func (s *SinkStore) claim(ctx context.Context, id uuid.UUID) (seq int64, ok bool, err error) {
+	err = s.dbtx.QueryRow(ctx, `
+		SELECT delivered_seq FROM audit_sinks WHERE id = $1 AND enabled
+		FOR UPDATE SKIP LOCKED`, id).Scan(&seq)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("claiming audit sink: %w", err)
+	}
+	return seq, true, nil
+}
+
+func (s *SinkStore) recordDelivery(ctx context.Context, id uuid.UUID, seq int64) error {
+	_, err := s.dbtx.Exec(ctx, `
+		UPDATE audit_sinks SET delivered_seq = $2, delivered_at = now(), failures = 0
+		WHERE id = $1`, id, seq)
+	if err != nil {
+		return fmt.Errorf("recording audit sink delivery: %w", err)
+	}
+	return nil
+}
+
+func (s *SinkStore) recordFailure(ctx context.Context, id uuid.UUID, msg string) error {
+	_, err := s.dbtx.Exec(ctx, `
+		UPDATE audit_sinks SET failures = failures + 1, last_error = $2, last_error_at = now()
+		WHERE id = $1`, id, msg)
+	if err != nil {
+		return fmt.Errorf("recording audit sink failure: %w", err)
+	}
+	return nil
+}
+
+// pendingEntries returns up to limit chained entries after seq, in chain
+// order.
+func pendingEntries(ctx context.Context, dbtx db.DBTX, seq int64, limit int) ([]db.AuditLog, error) {
+	rows, err := dbtx.Query(ctx, `SELECT `+auditColumns+` FROM audit_log
+		WHERE seq > $1 ORDER BY seq LIMIT $2`, seq, limit)
+	if err != nil {
+		return nil, fmt.Errorf("reading pending audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []db.AuditLog{}
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning audit entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// oldestPending returns the time of the first entry after seq, or the zero
+// time when the sink is caught up.
+func oldestPending(ctx context.Context, dbtx db.DBTX, seq int64) (time.Time, error) {
+	var at time.Time
+	err := dbtx.QueryRow(ctx, `SELECT created_at FROM audit_log WHERE seq > $1 ORDER BY seq LIMIT 1`, seq).Scan(&at)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return at, err
+}
@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// spill is a durable overflow file for entries the database did not accept.
+// Entries are appended as JSON lines and fsynced before the writer moves on,
+// then replayed once the database is back. Only the writer goroutine uses it.
+type spill struct {
+	path string
+}
+
+// append durably adds entries to the spill file.
+func (s *spill) append(entries []Entry) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("creating audit spill directory: %w", err)
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening audit spill file: %w", err)
+	}
+	// Terminate a line torn by a crash mid-append so it does not swallow
+	// the first new entry.
+	if fi, err := f.Stat(); err == nil && fi.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, fi.Size()-1); err == nil && last[0] != '\n' {
+			if _, err := f.Write([]byte{'\n'}); err != nil {
+				_ = f.Close()
+				return fmt.Errorf("writing audit spill file: %w", err)
+			}
+		}
+	}
+	if err := writeEntries(f, entries); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// load returns all spilled entries in the order they were spilled.
+func (s *spill) load() ([]Entry, error) {
+	f, err := os.Open(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening audit spill file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var entries []Entry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		var e Entry
+		// Undecodable lines can only be torn by a crash mid-append, before
+		// the entries in them were acknowledged as spilled.
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading audit spill file: %w", err)
+	}
+	return entries, nil
+}
+
+// replace atomically rewrites the spill file with entries, removing it when
+// there are none left.
+func (s *spill) replace(entries []Entry) error {
+	if len(entries) == 0 {
+		if err := os.Remove(s.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("removing audit spill file: %w", err)
+		}
+		return nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("creating audit spill file: %w", err)
+	}
+	if err := writeEntries(tmp, entries); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("writing audit spill file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("replacing audit spill file: %w", err)
+	}
+	return nil
+}
+
+func writeEntries(f *os.File, entries []Entry) error {
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("encoding audit entry: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("writing audit spill file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("syncing audit spill file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSpill_RoundTrip(t *testing.T) {
+	s := &spill{path: filepath.Join(t.TempDir(), "spill", "audit-api.jsonl")}
+
+	entries := testEntries(3)
+	if err := s.append(entries[:2]); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err := s.append(entries[2:]); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	got, err := s.load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("loaded %d entries, want 3", len(got))
+	}
+	for i, e := range got {
+		if e.ResourceID != entries[i].ResourceID || !e.CreatedAt.Equal(entries[i].CreatedAt) ||
+			e.UserID != entries[i].UserID || *e.IPAddress != *entries[i].IPAddress || e.TenantSchema != "tenant_acme" {
+			t.Errorf("entry %d = %+v, want %+v", i, e, entries[i])
+		}
+	}
+
+	if err := s.replace(got[2:]); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if got, _ := s.load(); len(got) != 1 || got[0].ResourceID != entries[2].ResourceID {
+		t.Errorf("after replace: %v", got)
+	}
+
+	if err := s.replace(nil); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if _, err := os.Stat(s.path); !os.IsNotExist(err) {
+		t.Errorf("spill file still exists: %v", err)
+	}
+	if got, err := s.load(); err != nil || len(got) != 0 {
+		t.Errorf("load of missing file = %v, %v", got, err)
+	}
+}
+
+func TestSpill_TornLine(t *testing.T) {
+	s := &spill{path: filepath.Join(t.TempDir(), "audit-api.jsonl")}
+	entries := testEntries(2)
+	if err := s.append(entries[:1]); err != nil {
+		t.Fatal(err)
+	}
+	// Simulate a crash in the middle of an append.
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"TenantSchema":"tenant_ac`)
+	_ = f.Close()
+
+	if err := s.append(entries[1:]); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(got) != 2 || got[1].ResourceID != entries[1].ResourceID {
+		t.Errorf("loaded %d entries, want both intact entries", len(got))
+	}
+}
@@ -0,0 +1,171 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wisbric/nightowl/internal/db"
+)
+
+const (
+	// syslogPriority is facility authpriv (10) with severity informational (6).
+	syslogPriority = 10*8 + 6
+	// syslogSDID identifies NightOwl's structured data element. 32473 is the
+	// private enterprise number reserved for examples (RFC 5612).
+	syslogSDID = "nightowl@32473"
+)
+
+// syslogSink writes RFC 5424 messages to a TCP or TLS receiver over one
+// long-lived connection. Syslog has no acknowledgements, so a batch counts as
+// delivered once it is written to the connection.
+type syslogSink struct {
+	address   string
+	tlsConfig *tls.Config
+	hostname  string
+	appName   string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newSyslogSink(cfg SinkConfig, tlsConfig *tls.Config) *syslogSink {
+	s := &syslogSink{address: cfg.Address, hostname: cfg.Hostname, appName: cfg.AppName}
+	if cfg.TLS {
+		s.tlsConfig = tlsConfig
+		if s.tlsConfig.ServerName == "" {
+			s.tlsConfig.ServerName, _, _ = net.SplitHostPort(cfg.Address)
+		}
+	}
+	if s.hostname == "" {
+		s.hostname, _ = os.Hostname()
+	}
+	if s.appName == "" {
+		s.appName = "nightowl"
+	}
+	return s
+}
+
+func (s *syslogSink) Send(ctx context.Context, tenant string, entries []db.AuditLog) error {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		msg := formatSyslog(s.hostname, s.appName, newEvent(tenant, e))
+		// Octet-counting framing (RFC 6587 §3.4.1, RFC 5425 §4.3).
+		buf.WriteString(strconv.Itoa(len(msg)))
+		buf.WriteByte(' ')
+		buf.WriteString(msg)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		conn, err := s.dial(ctx)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	deadline := time.Now().Add(sinkTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = s.conn.SetWriteDeadline(deadline)
+	if _, err := s.conn.Write(buf.Bytes()); err != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+		return fmt.Errorf("writing to syslog %s: %w", s.address, err)
+	}
+	return nil
+}
+
+func (s *syslogSink) dial(ctx context.Context) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, sinkTimeout)
+	defer cancel()
+	var conn net.Conn
+	var err error
+	if s.tlsConfig != nil {
+		d := &tls.Dialer{Config: s.tlsConfig}
+		conn, err = d.DialContext(ctx, "tcp", s.address)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", s.address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connecting to syslog %s: %w", s.address, err)
+	}
+	return conn, nil
+}
+
+func (s *syslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// formatSyslog renders one event as an RFC 5424 message. The key fields are
+// repeated as structured data for receivers that index it; the message body
+// is the full event as JSON.
+func formatSyslog(hostname, appName string, ev Event) string {
+	body, _ := json.Marshal(ev)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s %s - %s [%s", syslogPriority,
+		ev.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeaderField(hostname, 255), syslogHeaderField(appName, 48),
+		syslogHeaderField(ev.Action, 32), syslogSDID)
+	param := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, ` %s="%s"`, name, sdEscaper.Replace(value))
+		}
+	}
+	param("tenant", ev.Tenant)
+	param("seq", strconv.FormatInt(ev.Seq, 10))
+	param("id", ev.ID.String())
+	param("resource", ev.Resource)
+	if ev.ResourceID != nil {
+		param("resource_id", ev.ResourceID.String())
+	}
+	if ev.UserID != nil {
+		param("user_id", ev.UserID.String())
+	}
+	if ev.APIKeyID != nil {
+		param("api_key_id", ev.APIKeyID.String())
+	}
+	param("ip", ev.IPAddress)
+	param("hash", ev.Hash)
+	// The message is UTF-8, marked by a byte order mark (RFC 5424 §6.4).
+	b.WriteString("] \ufeff")
+	b.Write(body)
+	return b.String()
+}
+
+// sdEscaper escapes structured data parameter values (RFC 5424 §6.3.3).
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// syslogHeaderField restricts a header field to printable US-ASCII without
+// spaces and to limit characters, or "-" when nothing is left.
+func syslogHeaderField(s string, limit int) string {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s) && len(out) < limit; i++ {
+		if c := s[i]; c > 32 && c < 127 {
+			out = append(out, c)
+		}
+	}
+	if len(out) == 0 {
+		return "-"
+	}
+	return string(out)
+}
@@ -0,0 +1,218 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wisbric/nightowl/internal/db"
+)
+
+func testAuditLogs(n int) []db.AuditLog {
+	records := link(chainLink{Hash: genesisHash}, testEntries(n))
+	logs := make([]db.AuditLog, n)
+	for i, r := range records {
+		seq := r.Seq
+		logs[i] = db.AuditLog{
+			ID: r.ID, UserID: r.UserID, Action: r.Action, Resource: r.Resource, ResourceID: r.ResourceID,
+			Detail: r.Detail, IpAddress: r.IPAddress, UserAgent: r.UserAgent,
+			CreatedAt: r.CreatedAt, Seq: &seq, PrevHash: r.PrevHash, Hash: r.Hash,
+		}
+	}
+	return logs
+}
+
+// readFrames reads n octet-counted syslog frames.
+func readFrames(r *bufio.Reader, n int) ([]string, error) {
+	frames := make([]string, n)
+	for i := range frames {
+		size, err := r.ReadString(' ')
+		if err != nil {
+			return nil, err
+		}
+		length, err := strconv.Atoi(strings.TrimSuffix(size, " "))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, length)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		frames[i] = string(buf)
+	}
+	return frames, nil
+}
+
+// acceptFrames accepts one connection on ln and returns its first n frames.
+// The channel is closed without a value if reading fails.
+func acceptFrames(ln net.Listener, n int) <-chan []string {
+	ch := make(chan []string, 1)
+	go func() {
+		defer close(ch)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		if frames, err := readFrames(bufio.NewReader(conn), n); err == nil {
+			ch <- frames
+		}
+	}()
+	return ch
+}
+
+func receive(t *testing.T, ch <-chan []string) []string {
+	t.Helper()
+	select {
+	case frames, ok := <-ch:
+		if !ok {
+			t.Fatal("receiver failed to read syslog frames")
+		}
+		return frames
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for syslog frames")
+		return nil
+	}
+}
+
+func TestSyslogSink_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	ch := acceptFrames(ln, 2)
+
+	sink, err := NewSink(SinkSyslog, SinkConfig{Address: ln.Addr().String(), Hostname: "owl-1"})
+	if err != nil {
+		t.Fatalf("NewSink: %v", err)
+	}
+	defer func() { _ = sink.Close() }()
+
+	logs := testAuditLogs(2)
+	if err := sink.Send(context.Background(), "acme", logs); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	frames := receive(t, ch)
+
+	prefix := "<86>1 2026-03-01T12:00:00.123456Z owl-1 nightowl - update [nightowl@32473 tenant=\"acme\" seq=\"1\""
+	if !strings.HasPrefix(frames[0], prefix) {
+		t.Errorf("frame = %q\nwant prefix %q", frames[0], prefix)
+	}
+	_, body, ok := strings.Cut(frames[1], "] \ufeff")
+	if !ok {
+		t.Fatalf("frame has no message body: %q", frames[1])
+	}
+	var ev Event
+	if err := json.Unmarshal([]byte(body), &ev); err != nil {
+		t.Fatalf("body: %v", err)
+	}
+	if ev.Seq != 2 || ev.Tenant != "acme" || ev.ID != logs[1].ID || ev.PrevHash != hex.EncodeToString(logs[0].Hash) {
+		t.Errorf("event = %+v", ev)
+	}
+}
+
+func TestSyslogSink_TLS(t *testing.T) {
+	srv := httptest.NewUnstartedServer(nil)
+	srv.StartTLS()
+	defer srv.Close()
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: srv.TLS.Certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	ch := acceptFrames(ln, 1)
+
+	sink, err := NewSink(SinkSyslog, SinkConfig{Address: ln.Addr().String(), TLS: true, CACert: string(caPEM)})
+	if err != nil {
+		t.Fatalf("NewSink: %v", err)
+	}
+	defer func() { _ = sink.Close() }()
+
+	if err := sink.Send(context.Background(), "acme", testAuditLogs(1)); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if frames := receive(t, ch); !strings.Contains(frames[0], `tenant="acme"`) {
+		t.Errorf("frame = %q", frames[0])
+	}
+}
+
+func TestSyslogSink_UntrustedCertificate(t *testing.T) {
+	srv := httptest.NewUnstartedServer(nil)
+	srv.StartTLS()
+	defer srv.Close()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: srv.TLS.Certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			_ = conn.(*tls.Conn).Handshake()
+			_ = conn.Close()
+		}
+	}()
+
+	sink, _ := NewSink(SinkSyslog, SinkConfig{Address: ln.Addr().String(), TLS: true})
+	defer func() { _ = sink.Close() }()
+	if err := sink.Send(context.Background(), "acme", testAuditLogs(1)); err == nil {
+		t.Error("Send succeeded against an untrusted certificate")
+	}
+}
+
+func TestSyslogSink_Reconnects(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	sink, _ := NewSink(SinkSyslog, SinkConfig{Address: addr})
+	defer func() { _ = sink.Close() }()
+	if err := sink.Send(context.Background(), "acme", testAuditLogs(1)); err == nil {
+		t.Fatal("Send succeeded without a receiver")
+	}
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("cannot listen on %s again: %v", addr, err)
+	}
+	defer func() { _ = ln.Close() }()
+	ch := acceptFrames(ln, 1)
+	if err := sink.Send(context.Background(), "acme", testAuditLogs(1)); err != nil {
+		t.Fatalf("Send after recovery: %v", err)
+	}
+	receive(t, ch)
+}
+
+func TestFormatSyslog_Escaping(t *testing.T) {
+	rid := uuid.New()
+	msg := formatSyslog("my host", "", Event{
+		Tenant: `a"b]c\d`, Action: "", Resource: "incident", ResourceID: &rid,
+		Time: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+	})
+	if !strings.HasPrefix(msg, "<86>1 2026-03-01T12:00:00.000000Z myhost - - - [") {
+		t.Errorf("header = %q", msg)
+	}
+	if !strings.Contains(msg, `tenant="a\"b\]c\\d"`) {
+		t.Errorf("tenant not escaped: %q", msg)
+	}
+	if !strings.Contains(msg, `resource_id="`+rid.String()+`"`) {
+		t.Errorf("resource_id missing: %q", msg)
+	}
+}
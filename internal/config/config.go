@@ -16,6 +16,10 @@ type Config struct {
 	OIDCClientSecret string `env:"OIDC_CLIENT_SECRET"`
 	OIDCRedirectURL  string `env:"OIDC_REDIRECT_URL" envDefault:"http://localhost:5173/auth/callback"`
 
+	// Proxies whose X-Forwarded-For is trusted when checking API key IP
+	// allowlists (IPs or CIDRs).
+	TrustedProxies []string `env:"NIGHTOWL_TRUSTED_PROXIES" envSeparator:","`
+
 	// Metrics
 	MetricsPath string `env:"METRICS_PATH" envDefault:"/metrics"`
 
@@ -27,12 +31,38 @@ type Config struct {
 	// Admin
 	AdminPassword string `env:"NIGHTOWL_ADMIN_PASSWORD"`
 
+	// Platform (super-admin) tenant management API; disabled when the token is empty.
+	PlatformAdminToken  string `env:"NIGHTOWL_PLATFORM_ADMIN_TOKEN"`
+	TenantDeletionGrace string `env:"NIGHTOWL_TENANT_DELETION_GRACE" envDefault:"720h"`
+
+	// Audit log spill directory. Entries the database does not accept are
+	// kept in a file here until they can be written; when empty, the writer
+	// retries and applies backpressure instead.
+	AuditSpillDir string `env:"NIGHTOWL_AUDIT_SPILL_DIR"`
+
 	// Mattermost
 	MattermostURL              string `env:"MATTERMOST_URL"`
 	MattermostBotToken         string `env:"MATTERMOST_BOT_TOKEN"`
 	MattermostWebhookSecret    string `env:"MATTERMOST_WEBHOOK_SECRET"`
 	MattermostDefaultChannelID string `env:"MATTERMOST_DEFAULT_CHANNEL_ID"`
 
+	// SMTP email ingestion (smtp mode). Email integrations receive mail at
+	// <token>@<tenant-slug>.<SMTPDomain>.
+	SMTPListenAddr      string `env:"NIGHTOWL_SMTP_LISTEN_ADDR" envDefault:":2525"`
+	SMTPDomain          string `env:"NIGHTOWL_SMTP_DOMAIN"`
+	SMTPTLSCertFile     string `env:"NIGHTOWL_SMTP_TLS_CERT_FILE"`
+	SMTPTLSKeyFile      string `env:"NIGHTOWL_SMTP_TLS_KEY_FILE"`
+	SMTPMaxMessageBytes int    `env:"NIGHTOWL_SMTP_MAX_MESSAGE_BYTES" envDefault:"1048576"`
+
+	// Outbound mail for status page subscribers; sending is disabled when
+	// the relay address is empty. PublicURL is the externally reachable base
+	// URL used for links in mail and feeds.
+	MailSMTPAddr string `env:"NIGHTOWL_MAIL_SMTP_ADDR"`
+	MailUsername string `env:"NIGHTOWL_MAIL_USERNAME"`
+	MailPassword string `env:"NIGHTOWL_MAIL_PASSWORD"`
+	MailFrom     string `env:"NIGHTOWL_MAIL_FROM" envDefault:"NightOwl Status <status@localhost>"`
+	PublicURL    string `env:"NIGHTOWL_PUBLIC_URL" envDefault:"http://localhost:8080"`
+
 	// Cross-service links (public URLs for sidebar navigation)
 	BookOwlURL   string `env:"NIGHTOWL_BOOKOWL_URL"`
 	TicketOwlURL string `env:"NIGHTOWL_TICKETOWL_URL"`
@@ -14,7 +14,9 @@ import (
 
 	"github.com/wisbric/core/pkg/auth"
 
+	"github.com/wisbric/nightowl/internal/audit"
 	"github.com/wisbric/nightowl/internal/db"
+	"github.com/wisbric/nightowl/pkg/apikey"
 	"github.com/wisbric/nightowl/pkg/roster"
 	"github.com/wisbric/nightowl/pkg/tenant"
 )
@@ -625,19 +627,22 @@ Longhorn's over-provisioning percentage is set to 100% (default), meaning it won
 		{diana.ID, "update", "escalation_policy", policy.ID, `{"change":"added tier 3 phone escalation"}`},
 	}
 
-	for _, s := range auditSpecs {
-		if _, err := tq.CreateAuditLogEntry(ctx, db.CreateAuditLogEntryParams{
-			UserID:     pgtype.UUID{Bytes: s.userID, Valid: true},
-			Action:     s.action,
-			Resource:   s.resource,
-			ResourceID: pgtype.UUID{Bytes: s.resourceID, Valid: true},
-			Detail:     []byte(s.detail),
-			IpAddress:  &loopback,
-			UserAgent:  &ua,
-		}); err != nil {
-			return fmt.Errorf("creating audit entry: %w", err)
+	entries := make([]audit.Entry, len(auditSpecs))
+	for i, s := range auditSpecs {
+		entries[i] = audit.Entry{
+			TenantSchema: info.Schema,
+			UserID:       pgtype.UUID{Bytes: s.userID, Valid: true},
+			Action:       s.action,
+			Resource:     s.resource,
+			ResourceID:   s.resourceID,
+			Detail:       json.RawMessage(s.detail),
+			IPAddress:    &loopback,
+			UserAgent:    &ua,
 		}
 	}
+	if err := audit.Append(ctx, pool, info.Schema, entries); err != nil {
+		return fmt.Errorf("creating audit entries: %w", err)
+	}
 	logger.Info("seed-demo: created audit log entries", "count", len(auditSpecs))
 
 	// ── API Key ─────────────────────────────────────────────────────────
@@ -648,7 +653,7 @@ Longhorn's over-provisioning percentage is set to 100% (default), meaning it won
 		KeyPrefix:   DevAPIKey[:16],
 		Description: "Development seed API key",
 		Role:        "admin",
-		Scopes:      []string{"*"},
+		Scopes:      []string{apikey.ScopeAdmin},
 	}); err != nil {
 		return fmt.Errorf("creating seed API key: %w", err)
 	}
@@ -16,6 +16,7 @@ import (
 	coretenant "github.com/wisbric/core/pkg/tenant"
 
 	"github.com/wisbric/nightowl/internal/db"
+	"github.com/wisbric/nightowl/pkg/apikey"
 	"github.com/wisbric/nightowl/pkg/tenant"
 )
 
@@ -155,7 +156,7 @@ func Run(ctx context.Context, pool *pgxpool.Pool, databaseURL, migrationsDir str
 		KeyPrefix:   DevAPIKey[:16],
 		Description: "Development seed API key",
 		Role:        "admin",
-		Scopes:      []string{"*"},
+		Scopes:      []string{apikey.ScopeAdmin},
 	})
 	if err != nil {
 		return fmt.Errorf("creating seed API key: %w", err)
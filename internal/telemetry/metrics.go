@@ -69,6 +69,86 @@ var AlertsEscalatedTotal = prometheus.NewCounterVec(
 	[]string{"tier"},
 )
 
+var AuditForwardLag = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: "nightowl",
+		Subsystem: "audit",
+		Name:      "forward_lag_seconds",
+		Help:      "Age of the oldest audit entry not yet accepted by a sink.",
+	},
+	[]string{"tenant", "sink"},
+)
+
+var AuditForwardedTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "nightowl",
+		Subsystem: "audit",
+		Name:      "forwarded_total",
+		Help:      "Total number of audit entries delivered to sinks by sink kind.",
+	},
+	[]string{"kind"},
+)
+
+var AuditForwardFailuresTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "nightowl",
+		Subsystem: "audit",
+		Name:      "forward_failures_total",
+		Help:      "Total number of failed audit sink deliveries by sink kind.",
+	},
+	[]string{"kind"},
+)
+
+var IngestQueuedTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "nightowl",
+		Subsystem: "ingest",
+		Name:      "queued_total",
+		Help:      "Total number of webhook payloads accepted into the ingest queue by source.",
+	},
+	[]string{"source"},
+)
+
+var IngestRetriesTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "nightowl",
+		Subsystem: "ingest",
+		Name:      "retries_total",
+		Help:      "Total number of failed ingest attempts scheduled for retry by source.",
+	},
+	[]string{"source"},
+)
+
+var IngestDeadLetteredTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "nightowl",
+		Subsystem: "ingest",
+		Name:      "dead_lettered_total",
+		Help:      "Total number of webhook payloads moved to the dead letters by source.",
+	},
+	[]string{"source"},
+)
+
+var IngestQueueLatency = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: "nightowl",
+		Subsystem: "ingest",
+		Name:      "queue_latency_seconds",
+		Help:      "Time from accepting a webhook payload to its alerts being persisted.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+	},
+	[]string{"source"},
+)
+
+var HeartbeatsMissedTotal = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: "nightowl",
+		Subsystem: "heartbeat",
+		Name:      "missed_total",
+		Help:      "Total number of heartbeat monitors that stopped receiving pings and raised an alert.",
+	},
+)
+
 // All returns all NightOwl-specific metrics for registration.
 func All() []prometheus.Collector {
 	return []prometheus.Collector{
@@ -79,5 +159,13 @@ func All() []prometheus.Collector {
 		KBHitsTotal,
 		SlackNotificationsTotal,
 		AlertsEscalatedTotal,
+		AuditForwardLag,
+		AuditForwardedTotal,
+		AuditForwardFailuresTotal,
+		IngestQueuedTotal,
+		IngestRetriesTotal,
+		IngestDeadLetteredTotal,
+		IngestQueueLatency,
+		HeartbeatsMissedTotal,
 	}
 }
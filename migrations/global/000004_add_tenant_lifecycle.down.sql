@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS public.idx_tenants_purge_after;
+
+ALTER TABLE public.tenants
+    DROP COLUMN IF EXISTS purge_after,
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS suspended_at,
+    DROP COLUMN IF EXISTS status;
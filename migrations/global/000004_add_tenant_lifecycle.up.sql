@@ -0,0 +1,9 @@
+ALTER TABLE public.tenants
+    ADD COLUMN status       TEXT NOT NULL DEFAULT 'active'
+        CHECK (status IN ('active', 'suspended', 'pending_deletion')),
+    ADD COLUMN suspended_at TIMESTAMPTZ,
+    ADD COLUMN deleted_at   TIMESTAMPTZ,
+    ADD COLUMN purge_after  TIMESTAMPTZ;
+
+CREATE INDEX idx_tenants_purge_after ON public.tenants(purge_after)
+    WHERE status = 'pending_deletion';
@@ -0,0 +1,2 @@
+ALTER TABLE public.api_keys
+    ADD COLUMN allowed_cidrs TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,26 @@
+-- Shifts that do not start on a distinct local date cannot be represented
+-- by the weekly layout; keep the first shift of each day.
+DELETE FROM roster_schedule a
+USING roster_schedule b
+WHERE a.roster_id = b.roster_id
+  AND a.week_start = b.week_start
+  AND a.shift_start > b.shift_start;
+
+UPDATE roster_schedule SET week_end = week_start + 1 WHERE week_end <= week_start;
+
+DROP INDEX IF EXISTS idx_roster_schedule_current;
+
+ALTER TABLE roster_schedule
+    DROP CONSTRAINT IF EXISTS roster_schedule_shift_check,
+    DROP CONSTRAINT IF EXISTS roster_schedule_roster_id_shift_start_key,
+    ADD CONSTRAINT roster_schedule_roster_id_week_start_key UNIQUE (roster_id, week_start),
+    ADD CONSTRAINT roster_schedule_check1 CHECK (week_end > week_start),
+    DROP COLUMN IF EXISTS shift_start,
+    DROP COLUMN IF EXISTS shift_end;
+
+CREATE INDEX idx_roster_schedule_roster ON roster_schedule(roster_id, week_start);
+CREATE INDEX idx_roster_schedule_current ON roster_schedule(roster_id, week_start, week_end);
+
+ALTER TABLE rosters
+    DROP COLUMN IF EXISTS rotation_lengths,
+    DROP COLUMN IF EXISTS rotation_unit;
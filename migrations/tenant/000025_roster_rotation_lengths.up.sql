@@ -0,0 +1,35 @@
+-- Daily and custom-length rotations: shifts are bounded by exact instants
+-- rather than by whole weeks.
+
+-- 1. Rotation shape: a cycle of shift lengths measured in rotation_unit.
+--    weeks/{1} is the classic weekly rotation, days/{1} a daily rotation,
+--    days/{4,3} a 4-on/3-on split week and hours/{12} a 12-hour shift.
+ALTER TABLE rosters
+    ADD COLUMN rotation_unit TEXT NOT NULL DEFAULT 'weeks'
+        CHECK (rotation_unit IN ('hours', 'days', 'weeks')),
+    ADD COLUMN rotation_lengths INTEGER[] NOT NULL DEFAULT '{1}'
+        CHECK (cardinality(rotation_lengths) > 0 AND 0 < ALL(rotation_lengths));
+
+-- 2. Exact shift boundaries on roster_schedule. week_start/week_end are kept
+--    as the local calendar dates the shift starts and ends on.
+ALTER TABLE roster_schedule
+    ADD COLUMN shift_start TIMESTAMPTZ,
+    ADD COLUMN shift_end   TIMESTAMPTZ;
+
+UPDATE roster_schedule rs
+SET shift_start = (rs.week_start + r.handoff_time) AT TIME ZONE r.timezone,
+    shift_end   = (rs.week_end + r.handoff_time) AT TIME ZONE r.timezone
+FROM rosters r
+WHERE r.id = rs.roster_id;
+
+ALTER TABLE roster_schedule
+    ALTER COLUMN shift_start SET NOT NULL,
+    ALTER COLUMN shift_end SET NOT NULL,
+    DROP CONSTRAINT IF EXISTS roster_schedule_roster_id_week_start_key,
+    DROP CONSTRAINT IF EXISTS roster_schedule_check1,
+    ADD CONSTRAINT roster_schedule_roster_id_shift_start_key UNIQUE (roster_id, shift_start),
+    ADD CONSTRAINT roster_schedule_shift_check CHECK (shift_end > shift_start);
+
+DROP INDEX IF EXISTS idx_roster_schedule_roster;
+DROP INDEX IF EXISTS idx_roster_schedule_current;
+CREATE INDEX idx_roster_schedule_current ON roster_schedule(roster_id, shift_start, shift_end);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS roster_layer_members;
+DROP TABLE IF EXISTS roster_layers;
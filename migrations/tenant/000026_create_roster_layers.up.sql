@@ -0,0 +1,32 @@
+-- Layered schedules: rotation layers restricted to times of day and days of
+-- the week, combined in priority order (highest first).
+CREATE TABLE roster_layers (
+    id               UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    roster_id        UUID NOT NULL REFERENCES rosters(id) ON DELETE CASCADE,
+    name             TEXT NOT NULL,
+    priority         INTEGER NOT NULL DEFAULT 0,
+    rotation_unit    TEXT NOT NULL DEFAULT 'weeks'
+        CHECK (rotation_unit IN ('hours', 'days', 'weeks')),
+    rotation_lengths INTEGER[] NOT NULL DEFAULT '{1}'
+        CHECK (cardinality(rotation_lengths) > 0 AND 0 < ALL(rotation_lengths)),
+    days_of_week     INTEGER[] NOT NULL DEFAULT '{}'
+        CHECK (0 <= ALL(days_of_week) AND 6 >= ALL(days_of_week)),
+    start_time       TIME,
+    end_time         TIME,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CHECK ((start_time IS NULL) = (end_time IS NULL))
+);
+
+CREATE INDEX idx_roster_layers_roster ON roster_layers(roster_id, priority DESC);
+
+CREATE TABLE roster_layer_members (
+    layer_id UUID NOT NULL REFERENCES roster_layers(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    user_id  UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    PRIMARY KEY (layer_id, position)
+);
+
+CREATE INDEX idx_roster_layer_members_user ON roster_layer_members(user_id);
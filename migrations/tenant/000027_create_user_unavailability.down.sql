@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS user_unavailability;
+DROP TABLE IF EXISTS unavailability_calendars;
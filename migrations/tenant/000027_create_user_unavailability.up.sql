@@ -0,0 +1,30 @@
+-- Per-user time off / unavailability, entered manually or imported from
+-- subscribed iCal feeds. The schedule generator skips unavailable members.
+CREATE TABLE unavailability_calendars (
+    id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id        UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url            TEXT NOT NULL,
+    last_synced_at TIMESTAMPTZ,
+    last_error     TEXT,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    UNIQUE (user_id, url)
+);
+
+CREATE TABLE user_unavailability (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id      UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    start_at     TIMESTAMPTZ NOT NULL,
+    end_at       TIMESTAMPTZ NOT NULL,
+    reason       TEXT,
+    source       TEXT NOT NULL DEFAULT 'manual' CHECK (source IN ('manual', 'ical')),
+    calendar_id  UUID REFERENCES unavailability_calendars(id) ON DELETE CASCADE,
+    external_uid TEXT,
+    created_by   UUID REFERENCES users(id),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CHECK (end_at > start_at)
+);
+
+CREATE INDEX idx_user_unavailability_user ON user_unavailability(user_id, start_at, end_at);
+CREATE INDEX idx_user_unavailability_calendar ON user_unavailability(calendar_id);
@@ -0,0 +1,26 @@
+-- Shift swap and cover requests: a member offers a shift or time range to a
+-- colleague (or anyone on the roster) and the change is applied on accept.
+CREATE TABLE shift_swap_requests (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    roster_id       UUID NOT NULL REFERENCES rosters(id) ON DELETE CASCADE,
+    kind            TEXT NOT NULL DEFAULT 'cover' CHECK (kind IN ('cover', 'swap')),
+    role            TEXT NOT NULL DEFAULT 'primary' CHECK (role IN ('primary', 'secondary')),
+    requester_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_user_id  UUID REFERENCES users(id) ON DELETE CASCADE,
+    start_at        TIMESTAMPTZ NOT NULL,
+    end_at          TIMESTAMPTZ NOT NULL,
+    swap_start_at   TIMESTAMPTZ,
+    message         TEXT,
+    status          TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'accepted', 'declined', 'cancelled', 'expired')),
+    responder_id    UUID REFERENCES users(id) ON DELETE SET NULL,
+    responded_at    TIMESTAMPTZ,
+    override_id     UUID REFERENCES roster_overrides(id) ON DELETE SET NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CHECK (end_at > start_at)
+);
+
+CREATE INDEX idx_shift_swap_requests_roster ON shift_swap_requests(roster_id, status, start_at);
+CREATE INDEX idx_shift_swap_requests_target ON shift_swap_requests(target_user_id) WHERE status = 'pending';
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS chat_link_codes;
+DROP INDEX IF EXISTS idx_users_mattermost_user_id;
+DROP INDEX IF EXISTS idx_users_slack_user_id;
+ALTER TABLE users DROP COLUMN IF EXISTS mattermost_user_id;
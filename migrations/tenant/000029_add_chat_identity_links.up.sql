@@ -0,0 +1,15 @@
+-- Chat identity linking: users carry their Mattermost ID next to the
+-- existing Slack ID, and one-time codes back the /nightowl link handshake.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mattermost_user_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_users_slack_user_id ON users(slack_user_id) WHERE slack_user_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_users_mattermost_user_id ON users(mattermost_user_id) WHERE mattermost_user_id IS NOT NULL;
+
+CREATE TABLE chat_link_codes (
+    code_hash   TEXT PRIMARY KEY,
+    user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at  TIMESTAMPTZ NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_chat_link_codes_user ON chat_link_codes(user_id);
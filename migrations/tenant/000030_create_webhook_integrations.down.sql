@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_alerts_integration;
+ALTER TABLE alerts DROP COLUMN IF EXISTS integration_id;
+DROP TABLE IF EXISTS webhook_integrations;
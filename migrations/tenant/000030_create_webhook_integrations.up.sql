@@ -0,0 +1,27 @@
+-- Named webhook integrations: each sender gets its own ingest token, source
+-- type and defaults, so it can be identified, tracked and revoked on its own.
+CREATE TABLE webhook_integrations (
+    id                   UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name                 TEXT NOT NULL UNIQUE,
+    source_type          TEXT NOT NULL,
+    token_hash           TEXT NOT NULL UNIQUE,
+    token_prefix         TEXT NOT NULL,
+    enabled              BOOLEAN NOT NULL DEFAULT true,
+    default_labels       JSONB NOT NULL DEFAULT '{}',
+    service_id           UUID REFERENCES services(id) ON DELETE SET NULL,
+    escalation_policy_id UUID REFERENCES escalation_policies(id) ON DELETE SET NULL,
+    request_count        BIGINT NOT NULL DEFAULT 0,
+    alert_count          BIGINT NOT NULL DEFAULT 0,
+    error_count          BIGINT NOT NULL DEFAULT 0,
+    last_received_at     TIMESTAMPTZ,
+    last_error           TEXT,
+    last_error_at        TIMESTAMPTZ,
+    created_by           UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE alerts
+    ADD COLUMN integration_id UUID REFERENCES webhook_integrations(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_alerts_integration ON alerts(integration_id) WHERE integration_id IS NOT NULL;
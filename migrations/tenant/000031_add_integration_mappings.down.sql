@@ -0,0 +1 @@
+ALTER TABLE webhook_integrations DROP COLUMN IF EXISTS mapping;
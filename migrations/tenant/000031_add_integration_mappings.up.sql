@@ -0,0 +1,3 @@
+-- Declarative payload mappings let a generic integration accept any JSON
+-- shape by describing how to extract alert fields from it.
+ALTER TABLE webhook_integrations ADD COLUMN mapping JSONB;
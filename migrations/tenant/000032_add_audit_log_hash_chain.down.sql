@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_audit_log_seq;
+
+ALTER TABLE audit_log
+    DROP COLUMN IF EXISTS hash,
+    DROP COLUMN IF EXISTS prev_hash,
+    DROP COLUMN IF EXISTS seq;
@@ -0,0 +1,10 @@
+-- Audit entries form a per-tenant hash chain: each row stores its position,
+-- the previous row's hash and SHA-256(prev_hash || canonical entry), so
+-- edits, deletions and insertions are detectable. Rows written before this
+-- migration stay unchained (seq IS NULL).
+ALTER TABLE audit_log
+    ADD COLUMN seq       BIGINT,
+    ADD COLUMN prev_hash BYTEA,
+    ADD COLUMN hash      BYTEA;
+
+CREATE UNIQUE INDEX idx_audit_log_seq ON audit_log(seq);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_audit_log_action;
+DROP INDEX IF EXISTS idx_audit_log_api_key;
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at DESC);
+DROP INDEX IF EXISTS idx_audit_log_created_id;
@@ -0,0 +1,7 @@
+-- Keyset pagination orders by (created_at, id); the filters on API key and
+-- action need their own indexes once the log grows.
+CREATE INDEX idx_audit_log_created_id ON audit_log(created_at DESC, id DESC);
+DROP INDEX IF EXISTS idx_audit_log_created;
+
+CREATE INDEX idx_audit_log_api_key ON audit_log(api_key_id) WHERE api_key_id IS NOT NULL;
+CREATE INDEX idx_audit_log_action ON audit_log(action, created_at DESC);
@@ -0,0 +1,18 @@
+-- Audit sinks forward a tenant's audit chain to external systems such as a
+-- SIEM. delivered_seq is the chain position of the last entry the sink
+-- accepted; the forwarder resumes after it.
+CREATE TABLE audit_sinks (
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name          TEXT NOT NULL UNIQUE,
+    kind          TEXT NOT NULL,
+    config        JSONB NOT NULL DEFAULT '{}',
+    enabled       BOOLEAN NOT NULL DEFAULT true,
+    delivered_seq BIGINT NOT NULL DEFAULT 0,
+    delivered_at  TIMESTAMPTZ,
+    failures      INTEGER NOT NULL DEFAULT 0,
+    last_error    TEXT,
+    last_error_at TIMESTAMPTZ,
+    created_by    UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_escalation_events_target;
+DROP INDEX IF EXISTS idx_alerts_first_fired;
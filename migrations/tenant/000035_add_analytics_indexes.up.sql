@@ -0,0 +1,5 @@
+-- Analytics reports select alerts by when they first fired and pages by
+-- recipient and time.
+CREATE INDEX idx_alerts_first_fired ON alerts(first_fired_at);
+CREATE INDEX idx_escalation_events_target ON escalation_events(target_user_id, created_at)
+    WHERE target_user_id IS NOT NULL;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS noise_reports;
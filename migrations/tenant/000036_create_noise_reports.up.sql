@@ -0,0 +1,12 @@
+-- Weekly alert noise reports, one per period, kept for the API after they
+-- are posted through the tenant's messaging provider.
+CREATE TABLE noise_reports (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    period_start    TIMESTAMPTZ NOT NULL,
+    period_end      TIMESTAMPTZ NOT NULL UNIQUE,
+    report          JSONB NOT NULL,
+    delivered_via   TEXT,
+    delivered_at    TIMESTAMPTZ,
+    delivery_error  TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
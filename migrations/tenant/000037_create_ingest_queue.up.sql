@@ -0,0 +1,21 @@
+-- Webhook payloads accepted but not yet turned into alerts. Rows are
+-- removed once processed; payloads that keep failing stay as dead letters
+-- until replayed or discarded.
+CREATE TABLE ingest_queue (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    source          TEXT NOT NULL,
+    integration_id  UUID REFERENCES webhook_integrations(id) ON DELETE SET NULL,
+    payload         BYTEA NOT NULL,
+    query           TEXT NOT NULL DEFAULT '',
+    origin          JSONB NOT NULL DEFAULT '{}',
+    status          TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'dead')),
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    processed       INTEGER NOT NULL DEFAULT 0,
+    alert_ids       UUID[] NOT NULL DEFAULT '{}',
+    last_error      TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    received_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_ingest_queue_due ON ingest_queue (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_ingest_queue_dead ON ingest_queue (received_at DESC) WHERE status = 'dead';
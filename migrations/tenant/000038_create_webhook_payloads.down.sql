@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS webhook_payloads;
+ALTER TABLE ingest_queue DROP COLUMN IF EXISTS headers;
+ALTER TABLE webhook_integrations DROP COLUMN IF EXISTS payload_retention_days;
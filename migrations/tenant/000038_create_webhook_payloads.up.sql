@@ -0,0 +1,24 @@
+-- Raw webhook payloads as received, kept after processing for debugging
+-- and dry-run replays. Rows are pruned after their integration's retention,
+-- or after 7 days for payloads posted to the shared webhook endpoints.
+ALTER TABLE webhook_integrations
+    ADD COLUMN payload_retention_days INTEGER NOT NULL DEFAULT 7
+        CHECK (payload_retention_days BETWEEN 0 AND 90);
+
+ALTER TABLE ingest_queue ADD COLUMN headers JSONB NOT NULL DEFAULT '{}';
+
+CREATE TABLE webhook_payloads (
+    id             UUID PRIMARY KEY,
+    source         TEXT NOT NULL,
+    integration_id UUID REFERENCES webhook_integrations(id) ON DELETE SET NULL,
+    payload        BYTEA NOT NULL,
+    query          TEXT NOT NULL DEFAULT '',
+    headers        JSONB NOT NULL DEFAULT '{}',
+    alert_ids      UUID[] NOT NULL DEFAULT '{}',
+    received_at    TIMESTAMPTZ NOT NULL,
+    processed_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_webhook_payloads_received ON webhook_payloads (received_at DESC, id DESC);
+CREATE INDEX idx_webhook_payloads_integration ON webhook_payloads (integration_id, received_at DESC);
+CREATE INDEX idx_webhook_payloads_alert_ids ON webhook_payloads USING GIN (alert_ids);
@@ -0,0 +1,30 @@
+-- Heartbeat (dead man's switch) monitors: a job or alerting pipeline pings
+-- its monitor's URL on a schedule, and the worker raises an alert when the
+-- pings stop. expected_by is when the next ping is due, grace included.
+-- down_since and alert_id are set while the monitor's alert is open.
+CREATE TABLE heartbeat_monitors (
+    id                   UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name                 TEXT NOT NULL UNIQUE,
+    description          TEXT NOT NULL DEFAULT '',
+    token_hash           TEXT NOT NULL UNIQUE,
+    token_prefix         TEXT NOT NULL,
+    interval_seconds     INTEGER NOT NULL CHECK (interval_seconds BETWEEN 60 AND 604800),
+    grace_seconds        INTEGER NOT NULL DEFAULT 0 CHECK (grace_seconds BETWEEN 0 AND 86400),
+    severity             TEXT NOT NULL DEFAULT 'critical',
+    labels               JSONB NOT NULL DEFAULT '{}',
+    service_id           UUID REFERENCES services(id) ON DELETE SET NULL,
+    escalation_policy_id UUID REFERENCES escalation_policies(id) ON DELETE SET NULL,
+    enabled              BOOLEAN NOT NULL DEFAULT true,
+    expected_by          TIMESTAMPTZ NOT NULL,
+    last_ping_at         TIMESTAMPTZ,
+    ping_count           BIGINT NOT NULL DEFAULT 0,
+    down_since           TIMESTAMPTZ,
+    alert_id             UUID REFERENCES alerts(id) ON DELETE SET NULL,
+    chat_messages        JSONB NOT NULL DEFAULT '[]',
+    created_by           UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_heartbeat_monitors_expected ON heartbeat_monitors (expected_by) WHERE enabled;
+CREATE INDEX idx_heartbeat_monitors_down ON heartbeat_monitors (down_since) WHERE down_since IS NOT NULL;
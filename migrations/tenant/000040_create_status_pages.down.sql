@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS status_subscribers;
+DROP TABLE IF EXISTS status_incident_updates;
+DROP TABLE IF EXISTS status_incidents;
+DROP TABLE IF EXISTS status_components;
+DROP TABLE IF EXISTS status_pages;
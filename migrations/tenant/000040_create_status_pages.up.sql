@@ -0,0 +1,71 @@
+-- Status pages. Each component shows one service; its status is derived
+-- from the service's open alerts unless status_override is set. Public
+-- pages are also served without authentication.
+CREATE TABLE status_pages (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    slug        TEXT NOT NULL UNIQUE CHECK (slug ~ '^[a-z0-9][a-z0-9-]{0,62}$'),
+    name        TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    public      BOOLEAN NOT NULL DEFAULT false,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE status_components (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    page_id         UUID NOT NULL REFERENCES status_pages(id) ON DELETE CASCADE,
+    service_id      UUID NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+    name            TEXT NOT NULL,
+    description     TEXT NOT NULL DEFAULT '',
+    position        INTEGER NOT NULL DEFAULT 0,
+    status_override TEXT CHECK (status_override IN ('operational', 'degraded_performance',
+                        'partial_outage', 'major_outage', 'under_maintenance')),
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (page_id, service_id)
+);
+
+CREATE TABLE status_incidents (
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    page_id       UUID NOT NULL REFERENCES status_pages(id) ON DELETE CASCADE,
+    title         TEXT NOT NULL,
+    status        TEXT NOT NULL CHECK (status IN ('investigating', 'identified', 'monitoring', 'resolved')),
+    impact        TEXT NOT NULL DEFAULT 'minor' CHECK (impact IN ('none', 'minor', 'major', 'critical')),
+    component_ids UUID[] NOT NULL DEFAULT '{}',
+    created_by    UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    resolved_at   TIMESTAMPTZ
+);
+
+CREATE INDEX idx_status_incidents_page ON status_incidents (page_id, created_at DESC);
+
+-- Incident updates form the timeline. Updates with notify set are mailed to
+-- subscribers by the worker, which records notified_at.
+CREATE TABLE status_incident_updates (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    incident_id UUID NOT NULL REFERENCES status_incidents(id) ON DELETE CASCADE,
+    status      TEXT NOT NULL CHECK (status IN ('investigating', 'identified', 'monitoring', 'resolved')),
+    body        TEXT NOT NULL,
+    notify      BOOLEAN NOT NULL DEFAULT true,
+    notified_at TIMESTAMPTZ,
+    created_by  UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_status_incident_updates_incident ON status_incident_updates (incident_id, created_at);
+CREATE INDEX idx_status_incident_updates_pending ON status_incident_updates (created_at)
+    WHERE notify AND notified_at IS NULL;
+
+-- Subscribers confirm their address and unsubscribe through links carrying
+-- a token derived from their ID, so no token is stored. confirmation_sent_at
+-- limits how often a pending address is mailed a confirmation link.
+CREATE TABLE status_subscribers (
+    id                   UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    page_id              UUID NOT NULL REFERENCES status_pages(id) ON DELETE CASCADE,
+    email                TEXT NOT NULL,
+    confirmed_at         TIMESTAMPTZ,
+    confirmation_sent_at TIMESTAMPTZ,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (page_id, email)
+);
@@ -14,16 +14,20 @@ import (
 // NormalizedAlert is the internal representation that all webhook formats
 // normalize to before persisting.
 type NormalizedAlert struct {
-	Fingerprint          string
-	Status               string // firing, resolved
-	Severity             string // info, warning, major, critical
-	Source               string // alertmanager, keep, generic, or custom
-	Title                string
-	Description          *string
-	Labels               json.RawMessage
-	Annotations          json.RawMessage
-	ResolvedByAgent      bool
-	AgentResolutionNotes string
+	Fingerprint          string          `json:"fingerprint"`
+	Status               string          `json:"status"`   // firing, resolved
+	Severity             string          `json:"severity"` // info, warning, major, critical
+	Source               string          `json:"source"`   // alertmanager, keep, generic, or custom
+	Title                string          `json:"title"`
+	Description          *string         `json:"description,omitempty"`
+	Labels               json.RawMessage `json:"labels"`
+	Annotations          json.RawMessage `json:"annotations"`
+	ResolvedByAgent      bool            `json:"-"`
+	AgentResolutionNotes string          `json:"-"`
+	// Set for alerts received through a named integration.
+	IntegrationID      *uuid.UUID `json:"integration_id,omitempty"`
+	ServiceID          *uuid.UUID `json:"service_id,omitempty"`
+	EscalationPolicyID *uuid.UUID `json:"escalation_policy_id,omitempty"`
 }
 
 // Response is the API response for an alert.
@@ -41,6 +45,7 @@ type Response struct {
 	SuggestedSolution *string         `json:"suggested_solution,omitempty"`
 	RunbookURL        *string         `json:"runbook_url,omitempty"`
 	AlertGroupID      *uuid.UUID      `json:"alert_group_id,omitempty"`
+	IntegrationID     *uuid.UUID      `json:"integration_id,omitempty"`
 	OccurrenceCount   int32           `json:"occurrence_count"`
 	FirstFiredAt      time.Time       `json:"first_fired_at"`
 	LastFiredAt       time.Time       `json:"last_fired_at"`
@@ -0,0 +1,216 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wisbric/core/pkg/httpserver"
+
+	"github.com/wisbric/nightowl/internal/db"
+)
+
+// DefaultPayloadRetentionDays is how long raw payloads are archived for new
+// integrations and for payloads posted to the shared webhook endpoints.
+const DefaultPayloadRetentionDays = 7
+
+// ErrArchivedPayloadNotFound is returned when no archived payload matches.
+var ErrArchivedPayloadNotFound = errors.New("archived payload not found")
+
+// secretNameParts mark header and query parameter names that carry
+// credentials, such as Authorization, X-API-Key, Cookie or X-Hub-Signature.
+var secretNameParts = []string{
+	"auth", "token", "secret", "signature", "password", "api-key", "apikey", "api_key", "cookie", "session",
+}
+
+// isSecretName reports whether a header or query parameter must not be stored.
+func isSecretName(name string) bool {
+	lower := strings.ToLower(name)
+	for _, part := range secretNameParts {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactHeaders returns the request headers worth keeping with a payload,
+// without the ones that carry credentials.
+func redactHeaders(h http.Header) http.Header {
+	kept := http.Header{}
+	for name, values := range h {
+		if !isSecretName(name) {
+			kept[name] = values
+		}
+	}
+	return kept
+}
+
+// redactQuery returns a raw query string without parameters that carry
+// credentials.
+func redactQuery(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	query, err := url.ParseQuery(raw)
+	if err != nil {
+		return ""
+	}
+	for name := range query {
+		if isSecretName(name) {
+			delete(query, name)
+		}
+	}
+	return query.Encode()
+}
+
+func nonNilHeaders(h http.Header) http.Header {
+	if h == nil {
+		return http.Header{}
+	}
+	return h
+}
+
+// ArchivedPayload is a processed webhook payload kept for debugging, with
+// the alerts it created or updated.
+type ArchivedPayload struct {
+	ID            uuid.UUID   `json:"id"`
+	Source        string      `json:"source"`
+	IntegrationID *uuid.UUID  `json:"integration_id,omitempty"`
+	Payload       []byte      `json:"-"`
+	PayloadSize   int         `json:"payload_size"`
+	Query         string      `json:"query,omitempty"`
+	Headers       http.Header `json:"headers"`
+	AlertIDs      []uuid.UUID `json:"alert_ids"`
+	ReceivedAt    time.Time   `json:"received_at"`
+	ProcessedAt   time.Time   `json:"processed_at"`
+}
+
+// ArchivedPayloadDetail is an archived payload including the payload itself.
+type ArchivedPayloadDetail struct {
+	ArchivedPayload
+	Payload string `json:"payload"`
+}
+
+// ArchiveFilter narrows a listing of archived payloads.
+type ArchiveFilter struct {
+	IntegrationID *uuid.UUID
+	Source        string
+	AlertID       *uuid.UUID
+}
+
+// archiveColumns omits the payload, which only Get loads.
+const archiveColumns = `id, source, integration_id, octet_length(payload), query, headers,
+	alert_ids, received_at, processed_at`
+
+// ArchiveStore provides database operations for archived webhook payloads.
+type ArchiveStore struct {
+	dbtx db.DBTX
+}
+
+// NewArchiveStore creates an ArchiveStore backed by the given connection.
+func NewArchiveStore(dbtx db.DBTX) *ArchiveStore {
+	return &ArchiveStore{dbtx: dbtx}
+}
+
+// scanArchived scans archiveColumns, followed by the payload if withPayload.
+func scanArchived(row pgx.Row, withPayload bool) (ArchivedPayload, error) {
+	var p ArchivedPayload
+	var integrationID pgtype.UUID
+	var headers []byte
+	dest := []any{&p.ID, &p.Source, &integrationID, &p.PayloadSize, &p.Query, &headers,
+		&p.AlertIDs, &p.ReceivedAt, &p.ProcessedAt}
+	if withPayload {
+		dest = append(dest, &p.Payload)
+	}
+	if err := row.Scan(dest...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ArchivedPayload{}, ErrArchivedPayloadNotFound
+		}
+		return ArchivedPayload{}, err
+	}
+	if err := json.Unmarshal(headers, &p.Headers); err != nil {
+		return ArchivedPayload{}, fmt.Errorf("decoding headers: %w", err)
+	}
+	p.IntegrationID = pgtypeUUIDToPtr(integrationID)
+	if p.AlertIDs == nil {
+		p.AlertIDs = []uuid.UUID{}
+	}
+	return p, nil
+}
+
+// List returns up to limit archived payloads matching f, newest first,
+// after the cursor.
+func (s *ArchiveStore) List(ctx context.Context, f ArchiveFilter, after *httpserver.Cursor, limit int) ([]ArchivedPayload, error) {
+	var conditions []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+	if f.IntegrationID != nil {
+		add("integration_id = $%d", *f.IntegrationID)
+	}
+	if f.Source != "" {
+		add("source = $%d", f.Source)
+	}
+	if f.AlertID != nil {
+		add("alert_ids @> ARRAY[$%d::uuid]", *f.AlertID)
+	}
+	if after != nil {
+		args = append(args, after.CreatedAt, after.ID)
+		conditions = append(conditions, fmt.Sprintf("(received_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	query := `SELECT ` + archiveColumns + ` FROM webhook_payloads`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY received_at DESC, id DESC LIMIT $%d", len(args))
+
+	rows, err := s.dbtx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing archived payloads: %w", err)
+	}
+	defer rows.Close()
+
+	items := []ArchivedPayload{}
+	for rows.Next() {
+		p, err := scanArchived(rows, false)
+		if err != nil {
+			return nil, fmt.Errorf("scanning archived payload: %w", err)
+		}
+		items = append(items, p)
+	}
+	return items, rows.Err()
+}
+
+// Get returns one archived payload including the payload.
+func (s *ArchiveStore) Get(ctx context.Context, id uuid.UUID) (ArchivedPayload, error) {
+	return scanArchived(s.dbtx.QueryRow(ctx,
+		`SELECT `+archiveColumns+`, payload FROM webhook_payloads WHERE id = $1`, id), true)
+}
+
+// Prune deletes payloads older than their integration's retention, or
+// DefaultPayloadRetentionDays without an integration, and reports how many.
+func (s *ArchiveStore) Prune(ctx context.Context) (int64, error) {
+	tag, err := s.dbtx.Exec(ctx, `
+		DELETE FROM webhook_payloads p
+		WHERE p.received_at < now() - make_interval(days => COALESCE(
+		    (SELECT i.payload_retention_days FROM webhook_integrations i WHERE i.id = p.integration_id), $1))`,
+		DefaultPayloadRetentionDays)
+	if err != nil {
+		return 0, fmt.Errorf("pruning archived payloads: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
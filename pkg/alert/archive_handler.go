@@ -0,0 +1,359 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/wisbric/core/pkg/auth"
+	"github.com/wisbric/core/pkg/httpserver"
+
+	"github.com/wisbric/nightowl/internal/db"
+	"github.com/wisbric/nightowl/pkg/tenant"
+)
+
+// ArchiveHandler serves archived webhook payloads: what a source actually
+// sent, and what the current pipeline would make of it.
+type ArchiveHandler struct {
+	logger  *slog.Logger
+	grouper AlertGrouper
+}
+
+// NewArchiveHandler creates an ArchiveHandler. grouper may be nil, in which
+// case dry runs skip grouping rules.
+func NewArchiveHandler(logger *slog.Logger, grouper AlertGrouper) *ArchiveHandler {
+	return &ArchiveHandler{logger: logger, grouper: grouper}
+}
+
+// Routes returns a chi.Router with the payload archive routes mounted.
+func (h *ArchiveHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(auth.RequireRole(auth.RoleAdmin))
+	r.Get("/", h.handleList)
+	r.Route("/{id}", func(r chi.Router) {
+		r.Get("/", h.handleGet)
+		r.Post("/dry-run", h.handleDryRun)
+	})
+	return r
+}
+
+func parseArchiveID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid payload ID")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// parseArchiveFilter reads the integration_id, source and alert_id query
+// parameters.
+func parseArchiveFilter(r *http.Request) (ArchiveFilter, error) {
+	q := r.URL.Query()
+	f := ArchiveFilter{Source: q.Get("source")}
+	for name, dst := range map[string]**uuid.UUID{"integration_id": &f.IntegrationID, "alert_id": &f.AlertID} {
+		if v := q.Get(name); v != "" {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				return ArchiveFilter{}, fmt.Errorf("invalid %s", name)
+			}
+			*dst = &id
+		}
+	}
+	return f, nil
+}
+
+// handleList returns archived payloads, newest first, filtered by
+// integration, source or an alert the payload produced.
+func (h *ArchiveHandler) handleList(w http.ResponseWriter, r *http.Request) {
+	f, err := parseArchiveFilter(r)
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	params, err := httpserver.ParseCursorParams(r)
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	items, err := NewArchiveStore(tenant.ConnFromContext(r.Context())).List(r.Context(), f, params.After, params.Limit+1)
+	if err != nil {
+		h.logger.Error("listing archived payloads", "error", err)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to list archived payloads")
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, httpserver.NewCursorPage(items, params.Limit, func(p ArchivedPayload) httpserver.Cursor {
+		return httpserver.Cursor{CreatedAt: p.ReceivedAt, ID: p.ID}
+	}))
+}
+
+func (h *ArchiveHandler) handleGet(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseArchiveID(w, r)
+	if !ok {
+		return
+	}
+	p, err := NewArchiveStore(tenant.ConnFromContext(r.Context())).Get(r.Context(), id)
+	if err != nil {
+		h.respondErr(w, "getting archived payload", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, ArchivedPayloadDetail{ArchivedPayload: p, Payload: string(p.Payload)})
+}
+
+// DryRunResponse shows what re-running an archived payload through the
+// current normalizers, integration settings and grouping rules would do,
+// compared with the alerts the payload produced when it was received.
+type DryRunResponse struct {
+	PayloadID     uuid.UUID     `json:"payload_id"`
+	Source        string        `json:"source"`
+	IntegrationID *uuid.UUID    `json:"integration_id,omitempty"`
+	Alerts        []DryRunAlert `json:"alerts"`
+	// UnpairedAlertIDs are alerts the payload produced that none of the
+	// current alerts corresponds to.
+	UnpairedAlertIDs []uuid.UUID `json:"unpaired_alert_ids"`
+}
+
+// DryRunAlert is one alert of a dry run. Action is what the pipeline would
+// do now: create, deduplicate, agent_resolve, auto_resolve,
+// auto_acknowledge or none. ExistingAlertID is the open alert it would
+// update. Changes compare the alert with StoredAlertID, the alert the
+// payload produced, as it is stored now.
+type DryRunAlert struct {
+	Alert           NormalizedAlert `json:"alert"`
+	Action          string          `json:"action"`
+	ExistingAlertID *uuid.UUID      `json:"existing_alert_id,omitempty"`
+	GroupRuleID     *uuid.UUID      `json:"group_rule_id,omitempty"`
+	GroupRule       string          `json:"group_rule,omitempty"`
+	StoredAlertID   *uuid.UUID      `json:"stored_alert_id,omitempty"`
+	Changes         []FieldChange   `json:"changes"`
+}
+
+// FieldChange is a field whose stored and current values differ. Labels and
+// annotations are compared per key, as labels.<key> and annotations.<key>.
+type FieldChange struct {
+	Field   string `json:"field"`
+	Stored  any    `json:"stored"`
+	Current any    `json:"current"`
+}
+
+// handleDryRun re-runs an archived payload without persisting anything.
+// Payloads the current normalizers reject get the error they would get
+// when posted now.
+func (h *ArchiveHandler) handleDryRun(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseArchiveID(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+	conn := tenant.ConnFromContext(ctx)
+	p, err := NewArchiveStore(conn).Get(ctx, id)
+	if err != nil {
+		h.respondErr(w, "getting archived payload", err)
+		return
+	}
+
+	var in *Integration
+	var mapping *PayloadMapping
+	if p.IntegrationID != nil {
+		got, err := NewIntegrationStore(conn).Get(ctx, *p.IntegrationID)
+		switch {
+		case err == nil:
+			in, mapping = &got, got.Mapping
+		case !errors.Is(err, ErrIntegrationNotFound):
+			h.respondErr(w, "loading integration", err)
+			return
+		}
+	}
+	query, _ := url.ParseQuery(p.Query)
+	alerts, err := parsePayload(p.Source, p.Payload, query, mapping)
+	if err != nil {
+		respondPayloadError(w, err)
+		return
+	}
+	if in != nil {
+		for i := range alerts {
+			in.applyTo(&alerts[i])
+		}
+	}
+
+	q := db.New(conn)
+	var stored []db.Alert
+	seen := map[uuid.UUID]bool{}
+	for _, alertID := range p.AlertIDs {
+		if seen[alertID] {
+			continue // Deduplicated into more than once.
+		}
+		seen[alertID] = true
+		row, err := q.GetAlert(ctx, alertID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue // Deleted since.
+		}
+		if err != nil {
+			h.respondErr(w, "loading stored alert", err)
+			return
+		}
+		stored = append(stored, row)
+	}
+
+	resp := DryRunResponse{
+		PayloadID: p.ID, Source: p.Source, IntegrationID: p.IntegrationID,
+		Alerts: make([]DryRunAlert, 0, len(alerts)), UnpairedAlertIDs: []uuid.UUID{},
+	}
+	pairs, unpaired := pairAlerts(alerts, stored)
+	for _, i := range unpaired {
+		resp.UnpairedAlertIDs = append(resp.UnpairedAlertIDs, stored[i].ID)
+	}
+	for i, a := range alerts {
+		result, err := h.dryRun(ctx, q, a)
+		if err != nil {
+			h.respondErr(w, "evaluating alert", err)
+			return
+		}
+		result.Changes = []FieldChange{}
+		if j := pairs[i]; j >= 0 {
+			result.StoredAlertID = &stored[j].ID
+			result.Changes = diffAlert(stored[j], a)
+		}
+		resp.Alerts = append(resp.Alerts, result)
+	}
+	httpserver.Respond(w, http.StatusOK, resp)
+}
+
+// dryRun works out what persistAlert would do with an alert now, reading
+// but not writing. Deduplication is judged by the open alert with the same
+// fingerprint, as the database fallback of the deduplicator does.
+func (h *ArchiveHandler) dryRun(ctx context.Context, q *db.Queries, a NormalizedAlert) (DryRunAlert, error) {
+	result := DryRunAlert{Alert: a, Action: "none"}
+	open, err := q.GetAlertByFingerprint(ctx, a.Fingerprint)
+	found := err == nil
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return DryRunAlert{}, fmt.Errorf("looking up alert by fingerprint: %w", err)
+	}
+
+	switch {
+	case a.Status == statusAcknowledged:
+		if found && open.Status == "firing" {
+			result.Action, result.ExistingAlertID = "auto_acknowledge", &open.ID
+		}
+	case a.Status == "resolved" && !a.ResolvedByAgent:
+		if found {
+			result.Action, result.ExistingAlertID = "auto_resolve", &open.ID
+		}
+	case found && a.Status == "firing":
+		result.Action, result.ExistingAlertID = "deduplicate", &open.ID
+	default:
+		result.Action = "create"
+		if a.ResolvedByAgent {
+			result.Action = "agent_resolve"
+		}
+		if h.grouper != nil && a.Status == "firing" {
+			if g := h.grouper.Match(ctx, tenant.ConnFromContext(ctx), ensureJSON(a.Labels)); g.Matched {
+				result.GroupRuleID, result.GroupRule = &g.RuleID, g.RuleName
+			}
+		}
+	}
+	return result, nil
+}
+
+// pairAlerts pairs each current alert with the stored alert it corresponds
+// to: the one with the same fingerprint, else the first stored alert not
+// yet paired, so that alerts whose fingerprint changed are still compared.
+// pairs holds an index into stored or -1; unpaired lists stored alerts left
+// over.
+func pairAlerts(current []NormalizedAlert, stored []db.Alert) (pairs, unpaired []int) {
+	pairs = make([]int, len(current))
+	used := make([]bool, len(stored))
+	for i, a := range current {
+		pairs[i] = -1
+		for j, s := range stored {
+			if !used[j] && s.Fingerprint == a.Fingerprint {
+				pairs[i], used[j] = j, true
+				break
+			}
+		}
+	}
+	for i := range current {
+		if pairs[i] >= 0 {
+			continue
+		}
+		if j := slices.Index(used, false); j >= 0 {
+			pairs[i], used[j] = j, true
+		}
+	}
+	for j, u := range used {
+		if !u {
+			unpaired = append(unpaired, j)
+		}
+	}
+	return pairs, unpaired
+}
+
+// diffAlert lists the fields in which a stored alert differs from what the
+// pipeline produces now. Lifecycle fields such as status and occurrence
+// count are not compared.
+func diffAlert(stored db.Alert, a NormalizedAlert) []FieldChange {
+	changes := []FieldChange{}
+	add := func(field string, before, after any) {
+		if !reflect.DeepEqual(before, after) {
+			changes = append(changes, FieldChange{Field: field, Stored: before, Current: after})
+		}
+	}
+	add("fingerprint", stored.Fingerprint, a.Fingerprint)
+	add("title", stored.Title, a.Title)
+	add("severity", stored.Severity, a.Severity)
+	add("source", stored.Source, a.Source)
+	add("description", derefString(stored.Description), derefString(a.Description))
+	add("service_id", pgtypeUUIDToPtr(stored.ServiceID), a.ServiceID)
+	add("escalation_policy_id", pgtypeUUIDToPtr(stored.EscalationPolicyID), a.EscalationPolicyID)
+	add("integration_id", pgtypeUUIDToPtr(stored.IntegrationID), a.IntegrationID)
+
+	for _, field := range []struct {
+		name          string
+		before, after json.RawMessage
+	}{
+		{"labels", stored.Labels, a.Labels},
+		{"annotations", stored.Annotations, a.Annotations},
+	} {
+		var before, after map[string]any
+		_ = json.Unmarshal(ensureJSON(field.before), &before)
+		_ = json.Unmarshal(ensureJSON(field.after), &after)
+		keys := maps.Clone(before)
+		if keys == nil {
+			keys = map[string]any{}
+		}
+		maps.Copy(keys, after)
+		for _, k := range slices.Sorted(maps.Keys(keys)) {
+			add(field.name+"."+k, before[k], after[k])
+		}
+	}
+	return changes
+}
+
+func derefString(s *string) any {
+	if s == nil {
+		return nil
+	}
+	return *s
+}
+
+// respondErr maps archive errors to HTTP responses.
+func (h *ArchiveHandler) respondErr(w http.ResponseWriter, what string, err error) {
+	if errors.Is(err, ErrArchivedPayloadNotFound) {
+		httpserver.RespondError(w, http.StatusNotFound, "not_found", err.Error())
+		return
+	}
+	h.logger.Error(what, "error", err)
+	httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed "+what)
+}
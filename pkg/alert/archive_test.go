@@ -0,0 +1,127 @@
+package alert
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wisbric/core/pkg/auth"
+
+	"github.com/wisbric/nightowl/internal/db"
+)
+
+func TestRedactHeaders(t *testing.T) {
+	h := http.Header{}
+	for _, name := range []string{
+		"Authorization", "X-API-Key", "Cookie", "X-Auth-Token", "X-Hub-Signature-256",
+		"X-Webhook-Secret", "Content-Type", "User-Agent", "X-Grafana-Org-Id",
+	} {
+		h.Set(name, "v")
+	}
+	got := redactHeaders(h)
+	for _, name := range []string{"Content-Type", "User-Agent", "X-Grafana-Org-Id"} {
+		if got.Get(name) == "" {
+			t.Errorf("%s was dropped", name)
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("kept %v, want only the 3 harmless headers", got)
+	}
+}
+
+func TestRedactQuery(t *testing.T) {
+	got, _ := url.ParseQuery(redactQuery("severity=critical&token=abc&api_key=k"))
+	if got.Get("severity") != "critical" || got.Has("token") || got.Has("api_key") {
+		t.Errorf("redactQuery = %v", got)
+	}
+	if redactQuery("") != "" {
+		t.Error("empty query should stay empty")
+	}
+}
+
+func TestPairAlerts(t *testing.T) {
+	current := []NormalizedAlert{{Fingerprint: "b"}, {Fingerprint: "new"}, {Fingerprint: "z"}}
+	stored := []db.Alert{{Fingerprint: "a"}, {Fingerprint: "b"}}
+
+	pairs, unpaired := pairAlerts(current, stored)
+	// b pairs by fingerprint; new takes the leftover a; z has nothing left.
+	want := []int{1, 0, -1}
+	for i := range want {
+		if pairs[i] != want[i] {
+			t.Fatalf("pairs = %v, want %v", pairs, want)
+		}
+	}
+	if len(unpaired) != 0 {
+		t.Errorf("unpaired = %v, want none", unpaired)
+	}
+
+	_, unpaired = pairAlerts(current[:1], stored)
+	if len(unpaired) != 1 || unpaired[0] != 0 {
+		t.Errorf("unpaired = %v, want [0]", unpaired)
+	}
+}
+
+func TestDiffAlert(t *testing.T) {
+	serviceID := uuid.New()
+	desc := "disk at 95%"
+	stored := db.Alert{
+		Fingerprint: "fp", Title: "Disk full", Severity: "warning", Source: "alertmanager",
+		Description: &desc,
+		Labels:      json.RawMessage(`{"host":"db1","env":"prod"}`),
+		Annotations: json.RawMessage(`{}`),
+		ServiceID:   pgtype.UUID{Bytes: serviceID, Valid: true},
+	}
+	current := NormalizedAlert{
+		Fingerprint: "fp", Title: "Disk full", Severity: "critical", Source: "alertmanager",
+		Description: &desc,
+		Labels:      json.RawMessage(`{"host":"db1","team":"dba"}`),
+		ServiceID:   &serviceID,
+	}
+
+	changes := diffAlert(stored, current)
+	got := map[string]FieldChange{}
+	for _, c := range changes {
+		got[c.Field] = c
+	}
+	if len(changes) != 3 {
+		t.Fatalf("changes = %+v, want severity, labels.env and labels.team", changes)
+	}
+	if c := got["severity"]; c.Stored != "warning" || c.Current != "critical" {
+		t.Errorf("severity change = %+v", c)
+	}
+	if c := got["labels.env"]; c.Stored != "prod" || c.Current != nil {
+		t.Errorf("labels.env change = %+v", c)
+	}
+	if c := got["labels.team"]; c.Stored != nil || c.Current != "dba" {
+		t.Errorf("labels.team change = %+v", c)
+	}
+}
+
+func TestArchiveHandler_RejectsBadRequests(t *testing.T) {
+	h := NewArchiveHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	admin := &auth.Identity{Subject: "admin", Role: auth.RoleAdmin}
+	tests := []struct {
+		method, path string
+	}{
+		{http.MethodGet, "/?integration_id=nope"},
+		{http.MethodGet, "/?alert_id=nope"},
+		{http.MethodGet, "/not-a-uuid"},
+		{http.MethodPost, "/not-a-uuid/dry-run"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		r = r.WithContext(auth.NewContext(r.Context(), admin))
+		rec := httptest.NewRecorder()
+		h.Routes().ServeHTTP(rec, r)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: status = %d, want 400", tt.method, tt.path, rec.Code)
+		}
+	}
+}
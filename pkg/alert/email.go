@@ -0,0 +1,340 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/wisbric/nightowl/internal/audit"
+	"github.com/wisbric/nightowl/pkg/tenant"
+)
+
+// SourceEmail integrations ingest RFC 5322 messages, from the SMTP listener
+// or posted raw to the ingest URL.
+const SourceEmail = "email"
+
+// ErrUnmappable is returned when a message cannot be turned into alerts. It
+// is the sender's fault, so SMTP answers with a permanent failure.
+var ErrUnmappable = errors.New("message cannot be mapped to an alert")
+
+// maxEmailBytes caps the size of an email accepted over HTTP or SMTP.
+const maxEmailBytes = 1 << 20 // 1 MiB
+
+// EmailMessage is the part of an inbound email used to build alerts.
+type EmailMessage struct {
+	From      string
+	To        []string
+	Subject   string
+	Text      string
+	MessageID string
+	Date      time.Time
+	Headers   map[string]string
+}
+
+// defaultEmailMapping applies to email integrations without a mapping: the
+// subject is the title, the body the description, and severity and status
+// are picked from keywords in the subject. Messages with the same sender and
+// subject (ignoring reply prefixes, tags and status words) deduplicate.
+var defaultEmailMapping = PayloadMapping{
+	Title:       "$.subject",
+	Description: "$.text",
+	Severity:    `{{ regex "(?i)\\b(critical|crit|major|error|warning|warn|info)\\b" .subject }}`,
+	Status:      `{{ regex "(?i)\\b(resolved|recovery|recovered|ok)\\b" .subject }}`,
+	StatusMap:   map[string]string{"recovery": "resolved", "recovered": "resolved", "ok": "resolved"},
+	Fingerprint: "email:{{ .from }}:{{ .subject_key }}",
+	Labels:      map[string]string{"from": "$.from"},
+}
+
+// mapEmail maps a parsed email with an integration's mapping, or the default
+// email mapping.
+func mapEmail(m *PayloadMapping, msg *EmailMessage) ([]NormalizedAlert, error) {
+	if m == nil {
+		m = &defaultEmailMapping
+	}
+	alerts, err := m.mapDocument(msg.document(), SourceEmail)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnmappable, err)
+	}
+	return alerts, nil
+}
+
+// ParseEmail reads an RFC 5322 message. MIME-encoded headers are decoded and
+// the body is reduced to plain text, preferring a text/plain part over HTML.
+func ParseEmail(r io.Reader) (*EmailMessage, error) {
+	m, err := mail.ReadMessage(io.LimitReader(r, maxEmailBytes))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnmappable, err)
+	}
+
+	dec := new(mime.WordDecoder)
+	decode := func(s string) string {
+		if d, err := dec.DecodeHeader(s); err == nil {
+			return d
+		}
+		return s
+	}
+
+	msg := &EmailMessage{
+		Subject:   strings.TrimSpace(decode(m.Header.Get("Subject"))),
+		MessageID: strings.Trim(m.Header.Get("Message-Id"), "<> "),
+		Headers:   map[string]string{},
+	}
+	for k, v := range m.Header {
+		msg.Headers[strings.ToLower(k)] = decode(v[0])
+	}
+	if from, err := m.Header.AddressList("From"); err == nil && len(from) > 0 {
+		msg.From = strings.ToLower(from[0].Address)
+	}
+	if to, err := m.Header.AddressList("To"); err == nil {
+		for _, a := range to {
+			msg.To = append(msg.To, strings.ToLower(a.Address))
+		}
+	}
+	if d, err := m.Header.Date(); err == nil {
+		msg.Date = d
+	}
+
+	text, err := readBody(m.Header.Get("Content-Type"), m.Header.Get("Content-Transfer-Encoding"), m.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading body: %v", ErrUnmappable, err)
+	}
+	msg.Text = strings.TrimSpace(text)
+	return msg, nil
+}
+
+// readBody returns a message body as text. Multipart bodies yield their
+// first text/plain part, or their first text/html part with tags stripped.
+func readBody(contentType, encoding string, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	switch strings.ToLower(encoding) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		// The decoder skips the line breaks base64 bodies are wrapped with.
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		var htmlText string
+		for {
+			part, err := mr.NextPart()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return "", err
+			}
+			// multipart.Reader already decodes quoted-printable parts.
+			text, err := readBody(part.Header.Get("Content-Type"), partEncoding(part), part)
+			if err != nil {
+				return "", err
+			}
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			switch {
+			case partType == "text/plain" || strings.HasPrefix(partType, "multipart/") && text != "":
+				return text, nil
+			case partType == "text/html" && htmlText == "":
+				htmlText = text
+			}
+		}
+		return htmlText, nil
+	}
+
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	text := toUTF8(b, params["charset"])
+	if mediaType == "text/html" {
+		text = htmlToText(text)
+	}
+	return text, nil
+}
+
+// partEncoding returns a part's transfer encoding unless multipart.Reader
+// has already decoded it.
+func partEncoding(p *multipart.Part) string {
+	enc := p.Header.Get("Content-Transfer-Encoding")
+	if strings.EqualFold(enc, "quoted-printable") {
+		return ""
+	}
+	return enc
+}
+
+// toUTF8 converts ISO-8859-1 bodies to UTF-8; other charsets are assumed to
+// be UTF-8 compatible.
+func toUTF8(b []byte, charset string) string {
+	cs := strings.ToLower(charset)
+	if (cs == "iso-8859-1" || cs == "latin1" || cs == "windows-1252") && !utf8.Valid(b) {
+		runes := make([]rune, len(b))
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+		return string(runes)
+	}
+	return string(b)
+}
+
+var (
+	htmlBreakRe = regexp.MustCompile(`(?i)<(br\s*/?|/p|/div|/tr|/li|/h[1-6])>`)
+	htmlTagRe   = regexp.MustCompile(`(?s)<[^>]*>`)
+	htmlDropRe  = regexp.MustCompile(`(?is)<(style|script)[^>]*>.*?</(style|script)>`)
+	blankRunRe  = regexp.MustCompile(`\n\s*\n+`)
+)
+
+// htmlToText strips tags from an HTML body, keeping line breaks.
+func htmlToText(s string) string {
+	s = htmlDropRe.ReplaceAllString(s, "")
+	s = htmlBreakRe.ReplaceAllString(s, "\n")
+	s = htmlTagRe.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	return blankRunRe.ReplaceAllString(s, "\n\n")
+}
+
+var (
+	subjectPrefixRe = regexp.MustCompile(`(?i)^\s*((re|fwd?|aw|wg)\s*:|\[[^\]]*\]|(problem|resolved|recovery|recovered|ok|alert|firing)\s*[:\-!])\s*`)
+	fieldLineRe     = regexp.MustCompile(`^\s*([A-Za-z][A-Za-z0-9 _.\-]{0,40}?)\s*:\s*(.+?)\s*$`)
+	fieldKeyRe      = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// subjectKey strips reply prefixes, bracketed tags and leading status words
+// from a subject so problem and recovery messages share a key.
+func subjectKey(subject string) string {
+	for {
+		s := subjectPrefixRe.ReplaceAllString(subject, "")
+		if s == subject {
+			return strings.TrimSpace(s)
+		}
+		subject = s
+	}
+}
+
+// bodyFields extracts "Key: Value" lines from a body, keyed by the key in
+// lower snake case. The first occurrence of a key wins.
+func bodyFields(text string) map[string]any {
+	fields := map[string]any{}
+	for line := range strings.SplitSeq(text, "\n") {
+		m := fieldLineRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key := strings.Trim(fieldKeyRe.ReplaceAllString(strings.ToLower(m[1]), "_"), "_")
+		if _, ok := fields[key]; !ok && key != "" {
+			fields[key] = m[2]
+		}
+	}
+	return fields
+}
+
+// document renders the message as the JSON-like document mappings are
+// evaluated against.
+func (m *EmailMessage) document() map[string]any {
+	to := make([]any, len(m.To))
+	for i, a := range m.To {
+		to[i] = a
+	}
+	headers := make(map[string]any, len(m.Headers))
+	for k, v := range m.Headers {
+		headers[k] = v
+	}
+	doc := map[string]any{
+		"from":        m.From,
+		"to":          to,
+		"subject":     m.Subject,
+		"subject_key": subjectKey(m.Subject),
+		"text":        m.Text,
+		"message_id":  m.MessageID,
+		"headers":     headers,
+		"fields":      bodyFields(m.Text),
+	}
+	if !m.Date.IsZero() {
+		doc["date"] = m.Date.UTC().Format(time.RFC3339)
+	}
+	return doc
+}
+
+// parseEmail parses a raw RFC 5322 message (message/rfc822) posted over
+// HTTP, e.g. by an email forwarding service.
+func parseEmail(body []byte, mapping *PayloadMapping) ([]NormalizedAlert, error) {
+	msg, err := ParseEmail(bytes.NewReader(body))
+	if err != nil {
+		return nil, badPayload("%s", err.Error())
+	}
+	alerts, err := mapEmail(mapping, msg)
+	if err != nil {
+		return nil, invalidPayload("%s", err.Error())
+	}
+	return alerts, nil
+}
+
+// IngestEmail maps an email received for an integration and feeds the
+// alerts through the same pipeline as webhooks, updating the integration's
+// statistics. ctx must carry the tenant, as set by tenant.Acquire. Errors
+// wrapping ErrUnmappable are the sender's fault; other errors are temporary
+// and the sending server retries the message. Email is not queued, since
+// SMTP already keeps undelivered mail with the sender.
+func (h *WebhookHandler) IngestEmail(ctx context.Context, in *Integration, msg *EmailMessage) (BatchResponse, error) {
+	start := time.Now()
+	defer h.recordDuration(SourceEmail, start)
+
+	store := NewIntegrationStore(tenant.ConnFromContext(ctx))
+	alerts, err := mapEmail(in.Mapping, msg)
+	if err != nil {
+		if recErr := store.RecordIngest(ctx, in.ID, 0, err.Error()); recErr != nil {
+			h.logger.Warn("recording integration stats", "error", recErr, "integration", in.Name)
+		}
+		return BatchResponse{}, err
+	}
+	for i := range alerts {
+		in.applyTo(&alerts[i])
+	}
+
+	var results []Response
+	for _, a := range alerts {
+		action, resp, err := h.persistAlert(ctx, a)
+		if err != nil {
+			if recErr := store.RecordIngest(ctx, in.ID, 0, err.Error()); recErr != nil {
+				h.logger.Warn("recording integration stats", "error", recErr, "integration", in.Name)
+			}
+			return BatchResponse{}, fmt.Errorf("persisting alert: %w", err)
+		}
+		if action == "" {
+			continue
+		}
+		results = append(results, resp)
+		if h.audit == nil {
+			continue
+		}
+		detail, _ := json.Marshal(map[string]string{
+			"title": resp.Title, "source": SourceEmail, "integration": in.Name, "from": msg.From,
+		})
+		entry := audit.Entry{Action: action, Resource: "alert", ResourceID: resp.ID, Detail: detail}
+		if info := tenant.FromContext(ctx); info != nil {
+			entry.TenantSchema = info.Schema
+		}
+		h.audit.Log(entry)
+	}
+
+	if err := store.RecordIngest(ctx, in.ID, len(alerts), ""); err != nil {
+		h.logger.Warn("recording integration stats", "error", err, "integration", in.Name)
+	}
+	return BatchResponse{AlertsProcessed: len(results), Alerts: results}, nil
+}
@@ -0,0 +1,190 @@
+package alert
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseEmail_PlainText(t *testing.T) {
+	raw := "From: \"Nagios\" <Nagios@Example.com>\r\n" +
+		"To: nwh_abc@acme.alerts.example.com\r\n" +
+		"Subject: =?UTF-8?Q?PROBLEM:_disk_full_=E2=80=93_db1?=\r\n" +
+		"Message-ID: <123@example.com>\r\n" +
+		"Date: Mon, 02 Jan 2006 15:04:05 +0000\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"Host: db1\r\n" +
+		"Usage: 92% =3D too much\r\n"
+
+	msg, err := ParseEmail(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ParseEmail: %v", err)
+	}
+	if msg.From != "nagios@example.com" {
+		t.Errorf("From = %q", msg.From)
+	}
+	if msg.Subject != "PROBLEM: disk full – db1" {
+		t.Errorf("Subject = %q, want decoded encoded-word", msg.Subject)
+	}
+	if msg.MessageID != "123@example.com" {
+		t.Errorf("MessageID = %q", msg.MessageID)
+	}
+	if msg.Text != "Host: db1\r\nUsage: 92% = too much" {
+		t.Errorf("Text = %q", msg.Text)
+	}
+	if len(msg.To) != 1 || msg.To[0] != "nwh_abc@acme.alerts.example.com" {
+		t.Errorf("To = %v", msg.To)
+	}
+	if msg.Date.IsZero() {
+		t.Error("Date not parsed")
+	}
+}
+
+func TestParseEmail_Multipart(t *testing.T) {
+	raw := "From: a@example.com\r\n" +
+		"Subject: test\r\n" +
+		"Content-Type: multipart/alternative; boundary=XYZ\r\n" +
+		"\r\n" +
+		"--XYZ\r\n" +
+		"Content-Type: text/html\r\n" +
+		"\r\n" +
+		"<p>html &amp; body</p>\r\n" +
+		"--XYZ\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		"cGxhaW4g\r\nYm9keQ==\r\n" +
+		"--XYZ--\r\n"
+
+	msg, err := ParseEmail(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ParseEmail: %v", err)
+	}
+	if msg.Text != "plain body" {
+		t.Errorf("Text = %q, want the text/plain part", msg.Text)
+	}
+}
+
+func TestParseEmail_HTMLOnly(t *testing.T) {
+	raw := "From: a@example.com\r\n" +
+		"Subject: test\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n" +
+		"\r\n" +
+		"<style>p{}</style><p>Disk &gt; 90%</p><br>on db1"
+
+	msg, err := ParseEmail(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ParseEmail: %v", err)
+	}
+	if msg.Text != "Disk > 90%\n\non db1" {
+		t.Errorf("Text = %q", msg.Text)
+	}
+}
+
+func TestParseEmail_Invalid(t *testing.T) {
+	_, err := ParseEmail(strings.NewReader("not an email"))
+	if !errors.Is(err, ErrUnmappable) {
+		t.Errorf("err = %v, want ErrUnmappable", err)
+	}
+}
+
+func TestSubjectKey(t *testing.T) {
+	tests := map[string]string{
+		"PROBLEM: disk full on db1":          "disk full on db1",
+		"RECOVERY - disk full on db1":        "disk full on db1",
+		"Re: Fwd: [prod] ALERT! disk full":   "disk full",
+		"[Zabbix] [RESOLVED] CPU high on x1": "CPU high on x1",
+		"disk full":                          "disk full",
+	}
+	for in, want := range tests {
+		if got := subjectKey(in); got != want {
+			t.Errorf("subjectKey(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestBodyFields(t *testing.T) {
+	f := bodyFields("Notification Type: PROBLEM\nHost: db1\nhost: ignored\nState: CRITICAL\nfree text line\nURL: http://x/y")
+	want := map[string]string{"notification_type": "PROBLEM", "host": "db1", "state": "CRITICAL", "url": "http://x/y"}
+	for k, v := range want {
+		if f[k] != v {
+			t.Errorf("fields[%q] = %v, want %q", k, f[k], v)
+		}
+	}
+	if len(f) != len(want) {
+		t.Errorf("fields = %v", f)
+	}
+}
+
+func TestMapEmail_Default(t *testing.T) {
+	problem := &EmailMessage{From: "nagios@example.com", Subject: "PROBLEM: disk full on db1 is CRITICAL", Text: "Host: db1"}
+	alerts, err := mapEmail(nil, problem)
+	if err != nil {
+		t.Fatalf("mapEmail: %v", err)
+	}
+	a := alerts[0]
+	if a.Title != problem.Subject || a.Severity != "critical" || a.Status != "firing" || a.Source != SourceEmail {
+		t.Errorf("alert = %+v", a)
+	}
+	if a.Description == nil || *a.Description != "Host: db1" {
+		t.Errorf("Description = %v", a.Description)
+	}
+	if string(a.Labels) != `{"from":"nagios@example.com"}` {
+		t.Errorf("Labels = %s", a.Labels)
+	}
+
+	recovery := &EmailMessage{From: "nagios@example.com", Subject: "RECOVERY: disk full on db1 is CRITICAL"}
+	alerts, err = mapEmail(nil, recovery)
+	if err != nil {
+		t.Fatalf("mapEmail: %v", err)
+	}
+	if alerts[0].Status != "resolved" {
+		t.Errorf("Status = %q, want resolved", alerts[0].Status)
+	}
+	if alerts[0].Fingerprint != a.Fingerprint {
+		t.Errorf("recovery fingerprint %q != problem fingerprint %q", alerts[0].Fingerprint, a.Fingerprint)
+	}
+}
+
+func TestMapEmail_CustomMapping(t *testing.T) {
+	m := &PayloadMapping{
+		Title:       "{{ .fields.service }} on {{ .fields.host }}",
+		Severity:    "$.fields.state",
+		SeverityMap: map[string]string{"CRITICAL": "critical"},
+		Fingerprint: "{{ .fields.host }}/{{ .fields.service }}",
+		Labels:      map[string]string{"host": "$.fields.host"},
+	}
+	msg := &EmailMessage{Subject: "ignored", Text: "Host: db1\nService: disk\nState: CRITICAL"}
+	alerts, err := mapEmail(m, msg)
+	if err != nil {
+		t.Fatalf("mapEmail: %v", err)
+	}
+	a := alerts[0]
+	if a.Title != "disk on db1" || a.Severity != "critical" || a.Fingerprint != "db1/disk" {
+		t.Errorf("alert = %+v", a)
+	}
+
+	_, err = mapEmail(&PayloadMapping{Title: "$.fields.nope"}, msg)
+	if !errors.Is(err, ErrUnmappable) {
+		t.Errorf("err = %v, want ErrUnmappable", err)
+	}
+}
+
+func TestHandleEmail_RejectsInvalid(t *testing.T) {
+	handle := (&WebhookHandler{}).receiver(SourceEmail)
+	rec := httptest.NewRecorder()
+	handle(rec, httptest.NewRequest(http.MethodPost, "/email", strings.NewReader("garbage")))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handle(rec, httptest.NewRequest(http.MethodPost, "/email", strings.NewReader("From: a@example.com\r\n\r\nbody")))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want 422 for a message without subject", rec.Code)
+	}
+}
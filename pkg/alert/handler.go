@@ -178,20 +178,23 @@ type alertFilters struct {
 	Severity string
 	Source   string
 	GroupID  string
-	After    *time.Time
-	Before   *time.Time
-	Limit    int
-	Offset   int
+	// IntegrationID limits results to alerts received through one integration.
+	IntegrationID string
+	After         *time.Time
+	Before        *time.Time
+	Limit         int
+	Offset        int
 }
 
 func parseAlertFilters(r *http.Request) alertFilters {
 	f := alertFilters{
-		Status:   r.URL.Query().Get("status"),
-		Severity: r.URL.Query().Get("severity"),
-		Source:   r.URL.Query().Get("source"),
-		GroupID:  r.URL.Query().Get("group_id"),
-		Limit:    50,
-		Offset:   0,
+		Status:        r.URL.Query().Get("status"),
+		Severity:      r.URL.Query().Get("severity"),
+		Source:        r.URL.Query().Get("source"),
+		GroupID:       r.URL.Query().Get("group_id"),
+		IntegrationID: r.URL.Query().Get("integration_id"),
+		Limit:         50,
+		Offset:        0,
 	}
 	if v := r.URL.Query().Get("limit"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 200 {
@@ -241,6 +244,11 @@ func listAlertsFiltered(ctx context.Context, dbtx db.DBTX, f alertFilters) ([]Re
 		args = append(args, f.GroupID)
 		argIdx++
 	}
+	if f.IntegrationID != "" {
+		conditions = append(conditions, fmt.Sprintf("integration_id = $%d", argIdx))
+		args = append(args, f.IntegrationID)
+		argIdx++
+	}
 	if f.After != nil {
 		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", argIdx))
 		args = append(args, *f.After)
@@ -259,7 +267,7 @@ func listAlertsFiltered(ctx context.Context, dbtx db.DBTX, f alertFilters) ([]Re
 		occurrence_count, first_fired_at, last_fired_at,
 		escalation_policy_id, current_escalation_tier,
 		alert_group_id,
-		created_at, updated_at
+		created_at, updated_at, integration_id
 	FROM alerts`
 
 	if len(conditions) > 0 {
@@ -287,7 +295,7 @@ func listAlertsFiltered(ctx context.Context, dbtx db.DBTX, f alertFilters) ([]Re
 			&a.OccurrenceCount, &a.FirstFiredAt, &a.LastFiredAt,
 			&a.EscalationPolicyID, &a.CurrentEscalationTier,
 			&a.AlertGroupID,
-			&a.CreatedAt, &a.UpdatedAt,
+			&a.CreatedAt, &a.UpdatedAt, &a.IntegrationID,
 		); err != nil {
 			return nil, fmt.Errorf("scanning alert row: %w", err)
 		}
@@ -0,0 +1,343 @@
+package alert
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wisbric/nightowl/internal/db"
+)
+
+// Integration source types. The source type picks the payload format an
+// integration's ingest URL accepts.
+const (
+	SourceAlertmanager = "alertmanager"
+	SourceGrafana      = "grafana"
+	SourceKeep         = "keep"
+	SourceGeneric      = "generic"
+	SourceDatadog      = "datadog"
+	SourceCloudWatch   = "cloudwatch"
+	SourceOpsgenie     = "opsgenie"
+	// SourcePagerDuty accepts PagerDuty Events API v2 events.
+	SourcePagerDuty = "pagerduty"
+)
+
+// IntegrationSources lists the source types an integration can be created with.
+var IntegrationSources = []string{
+	SourceAlertmanager, SourceGrafana, SourceKeep, SourceGeneric,
+	SourceDatadog, SourceCloudWatch, SourceOpsgenie, SourcePagerDuty, SourceEmail,
+}
+
+var (
+	// ErrIntegrationNotFound is returned when no integration matches.
+	ErrIntegrationNotFound = errors.New("integration not found")
+	// ErrIntegrationExists is returned when an integration name is taken.
+	ErrIntegrationExists = errors.New("an integration with this name already exists")
+)
+
+// Integration is a named webhook sender with its own ingest token.
+type Integration struct {
+	ID                 uuid.UUID         `json:"id"`
+	Name               string            `json:"name"`
+	SourceType         string            `json:"source_type"`
+	TokenPrefix        string            `json:"token_prefix"`
+	Enabled            bool              `json:"enabled"`
+	DefaultLabels      map[string]string `json:"default_labels"`
+	Mapping            *PayloadMapping   `json:"mapping,omitempty"`
+	ServiceID          *uuid.UUID        `json:"service_id,omitempty"`
+	EscalationPolicyID *uuid.UUID        `json:"escalation_policy_id,omitempty"`
+	// PayloadRetentionDays is how long raw payloads are archived; 0 turns
+	// archiving off.
+	PayloadRetentionDays int              `json:"payload_retention_days"`
+	Stats                IntegrationStats `json:"stats"`
+	CreatedBy            *uuid.UUID       `json:"created_by,omitempty"`
+	CreatedAt            time.Time        `json:"created_at"`
+	UpdatedAt            time.Time        `json:"updated_at"`
+}
+
+// IntegrationStats are the ingestion counters kept per integration.
+type IntegrationStats struct {
+	Requests       int64      `json:"requests"`
+	Alerts         int64      `json:"alerts"`
+	Errors         int64      `json:"errors"`
+	LastReceivedAt *time.Time `json:"last_received_at,omitempty"`
+	LastError      *string    `json:"last_error,omitempty"`
+	LastErrorAt    *time.Time `json:"last_error_at,omitempty"`
+}
+
+// IntegrationRequest is the JSON body for creating or updating an integration.
+type IntegrationRequest struct {
+	Name          string            `json:"name" validate:"required,min=2,max=100"`
+	SourceType    string            `json:"source_type" validate:"required"`
+	DefaultLabels map[string]string `json:"default_labels"`
+	// Mapping is only allowed for generic and email integrations.
+	Mapping            *PayloadMapping `json:"mapping"`
+	ServiceID          *uuid.UUID      `json:"service_id"`
+	EscalationPolicyID *uuid.UUID      `json:"escalation_policy_id"`
+	// PayloadRetentionDays defaults to DefaultPayloadRetentionDays on
+	// create and is kept on update when omitted.
+	PayloadRetentionDays *int `json:"payload_retention_days" validate:"omitempty,min=0,max=90"`
+}
+
+// IntegrationTokenResponse is returned when a token is issued. The raw token
+// is only shown here; IngestPath is the URL path senders post to, and
+// IngestAddress the email address for email integrations.
+type IntegrationTokenResponse struct {
+	Integration
+	Token         string `json:"token"`
+	IngestPath    string `json:"ingest_path"`
+	IngestAddress string `json:"ingest_address,omitempty"`
+}
+
+// IngestPath returns the ingest URL path for a tenant and raw token.
+func IngestPath(tenantSlug, token string) string {
+	return "/api/v1/ingest/" + tenantSlug + "/" + token
+}
+
+// IngestAddress returns the email address the SMTP listener accepts for a
+// tenant and raw token: the token is the local part and the tenant slug a
+// subdomain of the listener's mail domain.
+func IngestAddress(tenantSlug, token, domain string) string {
+	return token + "@" + tenantSlug + "." + domain
+}
+
+// generateIntegrationToken creates a random ingest token with prefix "nwh_",
+// its SHA-256 hash, and a short prefix for display.
+func generateIntegrationToken() (raw, hash, prefix string) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand failed: " + err.Error())
+	}
+	raw = fmt.Sprintf("nwh_%x", b)
+	return raw, hashIntegrationToken(raw), raw[:12]
+}
+
+func hashIntegrationToken(raw string) string {
+	h := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(h[:])
+}
+
+// applyTo fills an alert's integration reference and defaults. Labels sent
+// by the source win over the integration's default labels.
+func (in *Integration) applyTo(a *NormalizedAlert) {
+	a.IntegrationID = &in.ID
+	if in.ServiceID != nil {
+		a.ServiceID = in.ServiceID
+	}
+	if in.EscalationPolicyID != nil {
+		a.EscalationPolicyID = in.EscalationPolicyID
+	}
+	if len(in.DefaultLabels) == 0 {
+		return
+	}
+	labels := maps.Clone(in.DefaultLabels)
+	var sent map[string]string
+	if len(a.Labels) > 0 && json.Unmarshal(a.Labels, &sent) == nil {
+		maps.Copy(labels, sent)
+	}
+	a.Labels, _ = json.Marshal(labels)
+}
+
+const integrationColumns = `id, name, source_type, token_prefix, enabled, default_labels, mapping,
+	service_id, escalation_policy_id, payload_retention_days, request_count, alert_count, error_count,
+	last_received_at, last_error, last_error_at, created_by, created_at, updated_at`
+
+// IntegrationStore provides database operations for webhook integrations.
+type IntegrationStore struct {
+	dbtx db.DBTX
+}
+
+// NewIntegrationStore creates an IntegrationStore backed by the given connection.
+func NewIntegrationStore(dbtx db.DBTX) *IntegrationStore {
+	return &IntegrationStore{dbtx: dbtx}
+}
+
+func scanIntegration(row pgx.Row) (Integration, error) {
+	var in Integration
+	var labels, mapping []byte
+	var serviceID, policyID, createdBy pgtype.UUID
+	var lastReceived, lastErrorAt pgtype.Timestamptz
+	if err := row.Scan(&in.ID, &in.Name, &in.SourceType, &in.TokenPrefix, &in.Enabled, &labels, &mapping,
+		&serviceID, &policyID, &in.PayloadRetentionDays, &in.Stats.Requests, &in.Stats.Alerts, &in.Stats.Errors,
+		&lastReceived, &in.Stats.LastError, &lastErrorAt, &createdBy, &in.CreatedAt, &in.UpdatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Integration{}, ErrIntegrationNotFound
+		}
+		return Integration{}, err
+	}
+	if err := json.Unmarshal(labels, &in.DefaultLabels); err != nil {
+		return Integration{}, fmt.Errorf("decoding default labels: %w", err)
+	}
+	if in.DefaultLabels == nil {
+		in.DefaultLabels = map[string]string{}
+	}
+	if len(mapping) > 0 {
+		if err := json.Unmarshal(mapping, &in.Mapping); err != nil {
+			return Integration{}, fmt.Errorf("decoding mapping: %w", err)
+		}
+	}
+	in.ServiceID = pgtypeUUIDToPtr(serviceID)
+	in.EscalationPolicyID = pgtypeUUIDToPtr(policyID)
+	in.CreatedBy = pgtypeUUIDToPtr(createdBy)
+	if lastReceived.Valid {
+		in.Stats.LastReceivedAt = &lastReceived.Time
+	}
+	if lastErrorAt.Valid {
+		in.Stats.LastErrorAt = &lastErrorAt.Time
+	}
+	return in, nil
+}
+
+// mapUniqueViolation turns a duplicate-name insert into ErrIntegrationExists.
+func mapUniqueViolation(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrIntegrationExists
+	}
+	return err
+}
+
+// List returns all integrations ordered by name.
+func (s *IntegrationStore) List(ctx context.Context) ([]Integration, error) {
+	rows, err := s.dbtx.Query(ctx, `SELECT `+integrationColumns+` FROM webhook_integrations ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("listing integrations: %w", err)
+	}
+	defer rows.Close()
+
+	items := []Integration{}
+	for rows.Next() {
+		in, err := scanIntegration(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning integration row: %w", err)
+		}
+		items = append(items, in)
+	}
+	return items, rows.Err()
+}
+
+// Get returns one integration by ID.
+func (s *IntegrationStore) Get(ctx context.Context, id uuid.UUID) (Integration, error) {
+	return scanIntegration(s.dbtx.QueryRow(ctx,
+		`SELECT `+integrationColumns+` FROM webhook_integrations WHERE id = $1`, id))
+}
+
+// GetByToken returns the integration whose token hashes to the given value.
+func (s *IntegrationStore) GetByToken(ctx context.Context, raw string) (Integration, error) {
+	return scanIntegration(s.dbtx.QueryRow(ctx,
+		`SELECT `+integrationColumns+` FROM webhook_integrations WHERE token_hash = $1`, hashIntegrationToken(raw)))
+}
+
+// Create inserts an integration with the given token hash and prefix.
+func (s *IntegrationStore) Create(ctx context.Context, req IntegrationRequest, hash, prefix string, createdBy pgtype.UUID) (Integration, error) {
+	labels, _ := json.Marshal(nonNilLabels(req.DefaultLabels))
+	retention := DefaultPayloadRetentionDays
+	if req.PayloadRetentionDays != nil {
+		retention = *req.PayloadRetentionDays
+	}
+	in, err := scanIntegration(s.dbtx.QueryRow(ctx, `
+		INSERT INTO webhook_integrations (name, source_type, token_hash, token_prefix,
+		    default_labels, service_id, escalation_policy_id, created_by, mapping, payload_retention_days)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING `+integrationColumns,
+		req.Name, req.SourceType, hash, prefix, labels,
+		ptrToPgtypeUUID(req.ServiceID), ptrToPgtypeUUID(req.EscalationPolicyID), createdBy,
+		encodeMapping(req.Mapping), retention))
+	if err != nil {
+		return Integration{}, fmt.Errorf("creating integration: %w", mapUniqueViolation(err))
+	}
+	return in, nil
+}
+
+// Update replaces an integration's settings. The token and stats are kept.
+func (s *IntegrationStore) Update(ctx context.Context, id uuid.UUID, req IntegrationRequest) (Integration, error) {
+	labels, _ := json.Marshal(nonNilLabels(req.DefaultLabels))
+	in, err := scanIntegration(s.dbtx.QueryRow(ctx, `
+		UPDATE webhook_integrations
+		SET name = $2, source_type = $3, default_labels = $4, service_id = $5,
+		    escalation_policy_id = $6, mapping = $7,
+		    payload_retention_days = COALESCE($8, payload_retention_days), updated_at = now()
+		WHERE id = $1
+		RETURNING `+integrationColumns,
+		id, req.Name, req.SourceType, labels,
+		ptrToPgtypeUUID(req.ServiceID), ptrToPgtypeUUID(req.EscalationPolicyID),
+		encodeMapping(req.Mapping), req.PayloadRetentionDays))
+	if err != nil {
+		return Integration{}, mapUniqueViolation(err)
+	}
+	return in, nil
+}
+
+// SetEnabled enables or disables an integration.
+func (s *IntegrationStore) SetEnabled(ctx context.Context, id uuid.UUID, enabled bool) (Integration, error) {
+	return scanIntegration(s.dbtx.QueryRow(ctx, `
+		UPDATE webhook_integrations SET enabled = $2, updated_at = now()
+		WHERE id = $1
+		RETURNING `+integrationColumns, id, enabled))
+}
+
+// RotateToken replaces an integration's token hash and prefix.
+func (s *IntegrationStore) RotateToken(ctx context.Context, id uuid.UUID, hash, prefix string) (Integration, error) {
+	return scanIntegration(s.dbtx.QueryRow(ctx, `
+		UPDATE webhook_integrations SET token_hash = $2, token_prefix = $3, updated_at = now()
+		WHERE id = $1
+		RETURNING `+integrationColumns, id, hash, prefix))
+}
+
+// Delete removes an integration. Alerts it delivered keep their data but
+// lose the reference.
+func (s *IntegrationStore) Delete(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.dbtx.Exec(ctx, `DELETE FROM webhook_integrations WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("deleting integration: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrIntegrationNotFound
+	}
+	return nil
+}
+
+// RecordIngest updates an integration's counters after one ingest request.
+// A non-empty errMsg counts the request as failed.
+func (s *IntegrationStore) RecordIngest(ctx context.Context, id uuid.UUID, alerts int, errMsg string) error {
+	_, err := s.dbtx.Exec(ctx, `
+		UPDATE webhook_integrations
+		SET request_count    = request_count + 1,
+		    alert_count      = alert_count + $2,
+		    last_received_at = now(),
+		    error_count      = error_count + CASE WHEN $3 = '' THEN 0 ELSE 1 END,
+		    last_error       = CASE WHEN $3 = '' THEN last_error ELSE $3 END,
+		    last_error_at    = CASE WHEN $3 = '' THEN last_error_at ELSE now() END
+		WHERE id = $1`, id, alerts, errMsg)
+	if err != nil {
+		return fmt.Errorf("recording integration stats: %w", err)
+	}
+	return nil
+}
+
+func nonNilLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return map[string]string{}
+	}
+	return labels
+}
+
+// encodeMapping returns the JSONB value for a mapping, or nil for none.
+func encodeMapping(m *PayloadMapping) []byte {
+	if m == nil {
+		return nil
+	}
+	b, _ := json.Marshal(m)
+	return b
+}
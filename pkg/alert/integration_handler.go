@@ -0,0 +1,313 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wisbric/core/pkg/auth"
+	"github.com/wisbric/core/pkg/httpserver"
+
+	"github.com/wisbric/nightowl/internal/audit"
+	"github.com/wisbric/nightowl/pkg/tenant"
+)
+
+// IntegrationHandler provides HTTP handlers for managing webhook integrations.
+type IntegrationHandler struct {
+	logger *slog.Logger
+	audit  *audit.Writer
+
+	// EmailDomain is the SMTP listener's mail domain. When set, tokens issued
+	// for email integrations come with their ingest address.
+	EmailDomain string
+}
+
+// NewIntegrationHandler creates an IntegrationHandler.
+func NewIntegrationHandler(logger *slog.Logger, audit *audit.Writer) *IntegrationHandler {
+	return &IntegrationHandler{logger: logger, audit: audit}
+}
+
+// Routes returns a chi.Router with the integration management routes mounted.
+func (h *IntegrationHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(auth.RequireRole(auth.RoleAdmin))
+	r.Get("/", h.handleList)
+	r.Post("/", h.handleCreate)
+	r.Get("/sources", h.handleListSources)
+	r.Post("/preview", h.handlePreview)
+	r.Route("/{id}", func(r chi.Router) {
+		r.Get("/", h.handleGet)
+		r.Put("/", h.handleUpdate)
+		r.Delete("/", h.handleDelete)
+		r.Post("/enable", h.handleEnable)
+		r.Post("/disable", h.handleDisable)
+		r.Post("/rotate-token", h.handleRotateToken)
+		r.Post("/preview", h.handlePreviewIntegration)
+	})
+	return r
+}
+
+func (h *IntegrationHandler) store(r *http.Request) *IntegrationStore {
+	return NewIntegrationStore(tenant.ConnFromContext(r.Context()))
+}
+
+func parseIntegrationID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid integration ID")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// decodeIntegrationRequest decodes and validates a create or update body.
+func decodeIntegrationRequest(w http.ResponseWriter, r *http.Request) (IntegrationRequest, bool) {
+	var req IntegrationRequest
+	if !httpserver.DecodeAndValidate(w, r, &req) {
+		return req, false
+	}
+	if !slices.Contains(IntegrationSources, req.SourceType) {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "unknown source_type "+req.SourceType)
+		return req, false
+	}
+	if req.Mapping != nil {
+		if req.SourceType != SourceGeneric && req.SourceType != SourceEmail {
+			httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "mapping is only supported for generic and email integrations")
+			return req, false
+		}
+		if _, err := req.Mapping.compile(); err != nil {
+			httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid mapping: "+err.Error())
+			return req, false
+		}
+	}
+	return req, true
+}
+
+func (h *IntegrationHandler) tokenResponse(r *http.Request, in Integration, raw string) IntegrationTokenResponse {
+	slug := ""
+	if info := tenant.FromContext(r.Context()); info != nil {
+		slug = info.Slug
+	}
+	resp := IntegrationTokenResponse{Integration: in, Token: raw, IngestPath: IngestPath(slug, raw)}
+	if in.SourceType == SourceEmail && h.EmailDomain != "" {
+		resp.IngestAddress = IngestAddress(slug, raw, h.EmailDomain)
+	}
+	return resp
+}
+
+func (h *IntegrationHandler) handleList(w http.ResponseWriter, r *http.Request) {
+	items, err := h.store(r).List(r.Context())
+	if err != nil {
+		h.logger.Error("listing integrations", "error", err)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to list integrations")
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]any{"integrations": items, "count": len(items)})
+}
+
+func (h *IntegrationHandler) handleListSources(w http.ResponseWriter, _ *http.Request) {
+	httpserver.Respond(w, http.StatusOK, map[string]any{"sources": IntegrationSources, "count": len(IntegrationSources)})
+}
+
+func (h *IntegrationHandler) handleCreate(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeIntegrationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var createdBy pgtype.UUID
+	if id := auth.FromContext(r.Context()); id != nil && id.UserID != nil {
+		createdBy = pgtype.UUID{Bytes: *id.UserID, Valid: true}
+	}
+
+	raw, hash, prefix := generateIntegrationToken()
+	in, err := h.store(r).Create(r.Context(), req, hash, prefix, createdBy)
+	if err != nil {
+		h.respondErr(w, "creating integration", err)
+		return
+	}
+
+	if h.audit != nil {
+		detail, _ := json.Marshal(map[string]string{"name": in.Name, "source_type": in.SourceType})
+		h.audit.LogFromRequest(r, "create", "integration", in.ID, detail)
+	}
+
+	httpserver.Respond(w, http.StatusCreated, h.tokenResponse(r, in, raw))
+}
+
+func (h *IntegrationHandler) handleGet(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIntegrationID(w, r)
+	if !ok {
+		return
+	}
+	in, err := h.store(r).Get(r.Context(), id)
+	if err != nil {
+		h.respondErr(w, "getting integration", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, in)
+}
+
+func (h *IntegrationHandler) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIntegrationID(w, r)
+	if !ok {
+		return
+	}
+	req, ok := decodeIntegrationRequest(w, r)
+	if !ok {
+		return
+	}
+	in, err := h.store(r).Update(r.Context(), id, req)
+	if err != nil {
+		h.respondErr(w, "updating integration", err)
+		return
+	}
+
+	if h.audit != nil {
+		detail, _ := json.Marshal(map[string]string{"name": in.Name, "source_type": in.SourceType})
+		h.audit.LogFromRequest(r, "update", "integration", in.ID, detail)
+	}
+
+	httpserver.Respond(w, http.StatusOK, in)
+}
+
+func (h *IntegrationHandler) handleEnable(w http.ResponseWriter, r *http.Request) {
+	h.setEnabled(w, r, true)
+}
+
+func (h *IntegrationHandler) handleDisable(w http.ResponseWriter, r *http.Request) {
+	h.setEnabled(w, r, false)
+}
+
+func (h *IntegrationHandler) setEnabled(w http.ResponseWriter, r *http.Request, enabled bool) {
+	id, ok := parseIntegrationID(w, r)
+	if !ok {
+		return
+	}
+	in, err := h.store(r).SetEnabled(r.Context(), id, enabled)
+	if err != nil {
+		h.respondErr(w, "updating integration", err)
+		return
+	}
+
+	if h.audit != nil {
+		action := "disable"
+		if enabled {
+			action = "enable"
+		}
+		detail, _ := json.Marshal(map[string]string{"name": in.Name})
+		h.audit.LogFromRequest(r, action, "integration", in.ID, detail)
+	}
+
+	httpserver.Respond(w, http.StatusOK, in)
+}
+
+// handleRotateToken issues a new ingest token. The old token stops working
+// immediately.
+func (h *IntegrationHandler) handleRotateToken(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIntegrationID(w, r)
+	if !ok {
+		return
+	}
+	raw, hash, prefix := generateIntegrationToken()
+	in, err := h.store(r).RotateToken(r.Context(), id, hash, prefix)
+	if err != nil {
+		h.respondErr(w, "rotating integration token", err)
+		return
+	}
+
+	if h.audit != nil {
+		detail, _ := json.Marshal(map[string]string{"name": in.Name, "token_prefix": in.TokenPrefix})
+		h.audit.LogFromRequest(r, "rotate_token", "integration", in.ID, detail)
+	}
+
+	httpserver.Respond(w, http.StatusOK, h.tokenResponse(r, in, raw))
+}
+
+func (h *IntegrationHandler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIntegrationID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.store(r).Delete(r.Context(), id); err != nil {
+		h.respondErr(w, "deleting integration", err)
+		return
+	}
+
+	if h.audit != nil {
+		h.audit.LogFromRequest(r, "delete", "integration", id, nil)
+	}
+
+	httpserver.Respond(w, http.StatusNoContent, nil)
+}
+
+// handlePreview applies an unsaved mapping to a sample payload and returns
+// the alerts it would produce, without persisting anything.
+func (h *IntegrationHandler) handlePreview(w http.ResponseWriter, r *http.Request) {
+	var req MappingPreviewRequest
+	if !httpserver.DecodeAndValidate(w, r, &req) {
+		return
+	}
+	respondPreview(w, req.Mapping, req.Payload, nil)
+}
+
+// handlePreviewIntegration applies a saved integration's mapping and
+// defaults to a sample payload posted as the request body.
+func (h *IntegrationHandler) handlePreviewIntegration(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIntegrationID(w, r)
+	if !ok {
+		return
+	}
+	in, err := h.store(r).Get(r.Context(), id)
+	if err != nil {
+		h.respondErr(w, "getting integration", err)
+		return
+	}
+	if in.Mapping == nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "integration has no payload mapping")
+		return
+	}
+	var payload json.RawMessage
+	if err := decodeWebhookBody(r, &payload); err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	respondPreview(w, in.Mapping, payload, &in)
+}
+
+func respondPreview(w http.ResponseWriter, m *PayloadMapping, payload json.RawMessage, in *Integration) {
+	alerts, err := m.mapPayload(payload)
+	if err != nil {
+		httpserver.RespondError(w, http.StatusUnprocessableEntity, "validation_error", err.Error())
+		return
+	}
+	if in != nil {
+		for i := range alerts {
+			in.applyTo(&alerts[i])
+		}
+	}
+	httpserver.Respond(w, http.StatusOK, MappingPreviewResponse{Alerts: alerts, Count: len(alerts)})
+}
+
+// respondErr maps integration errors to HTTP responses.
+func (h *IntegrationHandler) respondErr(w http.ResponseWriter, what string, err error) {
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, ErrIntegrationNotFound):
+		httpserver.RespondError(w, http.StatusNotFound, "not_found", err.Error())
+	case errors.Is(err, ErrIntegrationExists):
+		httpserver.RespondError(w, http.StatusConflict, "conflict", err.Error())
+	case errors.As(err, &pgErr) && pgErr.Code == "23503":
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "service or escalation policy does not exist")
+	default:
+		h.logger.Error(what, "error", err)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed "+what)
+	}
+}
@@ -0,0 +1,143 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/wisbric/core/pkg/httpserver"
+)
+
+func TestGenerateIntegrationToken(t *testing.T) {
+	raw, hash, prefix := generateIntegrationToken()
+	if !strings.HasPrefix(raw, "nwh_") || len(raw) != 4+48 {
+		t.Errorf("raw token = %q, want nwh_ followed by 48 hex chars", raw)
+	}
+	if !strings.HasPrefix(raw, prefix) || len(prefix) != 12 {
+		t.Errorf("prefix = %q, want first 12 chars of token", prefix)
+	}
+	if hash != hashIntegrationToken(raw) {
+		t.Error("hash does not match hashIntegrationToken(raw)")
+	}
+	if other, _, _ := generateIntegrationToken(); other == raw {
+		t.Error("expected distinct tokens")
+	}
+}
+
+func TestIngestPath(t *testing.T) {
+	if got := IngestPath("acme", "nwh_abc"); got != "/api/v1/ingest/acme/nwh_abc" {
+		t.Errorf("IngestPath = %q", got)
+	}
+}
+
+func TestIntegration_ApplyTo(t *testing.T) {
+	serviceID, policyID := uuid.New(), uuid.New()
+	in := &Integration{
+		ID:                 uuid.New(),
+		DefaultLabels:      map[string]string{"cluster": "prod-eu", "team": "platform"},
+		ServiceID:          &serviceID,
+		EscalationPolicyID: &policyID,
+	}
+	a := NormalizedAlert{Labels: json.RawMessage(`{"team":"payments","alertname":"HighCPU"}`)}
+	in.applyTo(&a)
+
+	if a.IntegrationID == nil || *a.IntegrationID != in.ID {
+		t.Errorf("IntegrationID = %v, want %s", a.IntegrationID, in.ID)
+	}
+	if a.ServiceID == nil || *a.ServiceID != serviceID {
+		t.Errorf("ServiceID = %v, want %s", a.ServiceID, serviceID)
+	}
+	if a.EscalationPolicyID == nil || *a.EscalationPolicyID != policyID {
+		t.Errorf("EscalationPolicyID = %v, want %s", a.EscalationPolicyID, policyID)
+	}
+
+	var labels map[string]string
+	if err := json.Unmarshal(a.Labels, &labels); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"cluster": "prod-eu", "team": "payments", "alertname": "HighCPU"}
+	for k, v := range want {
+		if labels[k] != v {
+			t.Errorf("labels[%q] = %q, want %q", k, labels[k], v)
+		}
+	}
+	if len(labels) != len(want) {
+		t.Errorf("labels = %v, want %v", labels, want)
+	}
+}
+
+func TestIntegration_ApplyToWithoutDefaults(t *testing.T) {
+	in := &Integration{ID: uuid.New()}
+	a := NormalizedAlert{Labels: json.RawMessage(`{"a":"b"}`)}
+	in.applyTo(&a)
+
+	if string(a.Labels) != `{"a":"b"}` {
+		t.Errorf("labels = %s, want unchanged", a.Labels)
+	}
+	if a.ServiceID != nil || a.EscalationPolicyID != nil {
+		t.Error("expected no service or escalation policy")
+	}
+}
+
+func TestReceive_CountsIntegrationAlerts(t *testing.T) {
+	h := NewWebhookHandler(nil, nil, nil, nil, nil, nil, nil)
+	var queued *QueuedPayload
+	h.enqueue = func(_ context.Context, p *QueuedPayload) error {
+		queued = p
+		return nil
+	}
+
+	st := &ingestState{integration: &Integration{ID: uuid.New(), SourceType: SourceAlertmanager}}
+	body := `{"alerts":[{"status":"firing","labels":{"alertname":"A"}},{"status":"firing","labels":{"alertname":"B"}}]}`
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r = r.WithContext(context.WithValue(r.Context(), ingestKey{}, st))
+	rec := httptest.NewRecorder()
+	h.sourceHandler(st.integration.SourceType)(rec, r)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", rec.Code)
+	}
+	if st.alerts != 2 {
+		t.Errorf("alerts = %d, want 2", st.alerts)
+	}
+	if queued == nil || queued.IntegrationID == nil || *queued.IntegrationID != st.integration.ID {
+		t.Errorf("queued = %+v, want the integration's payload", queued)
+	}
+}
+
+func TestWebhookHandler_SourceHandlerCoversSources(t *testing.T) {
+	h := NewWebhookHandler(nil, nil, nil, nil, nil, nil, nil)
+	for _, src := range IntegrationSources {
+		if h.sourceHandler(src) == nil {
+			t.Errorf("no handler for integration source %q", src)
+		}
+	}
+	if h.sourceHandler("nope") != nil {
+		t.Error("expected no handler for unknown source")
+	}
+}
+
+func TestIngestRecorder_ErrorMessage(t *testing.T) {
+	rec := &ingestRecorder{ResponseWriter: httptest.NewRecorder(), status: http.StatusOK}
+	httpserver.Respond(rec, http.StatusCreated, map[string]int{"alerts_processed": 1})
+	if got := rec.errorMessage(); got != "" {
+		t.Errorf("errorMessage on success = %q, want empty", got)
+	}
+
+	rec = &ingestRecorder{ResponseWriter: httptest.NewRecorder(), status: http.StatusOK}
+	httpserver.RespondError(rec, http.StatusUnprocessableEntity, "validation_error", "no alerts in payload")
+	if got := rec.errorMessage(); got != "no alerts in payload" {
+		t.Errorf("errorMessage = %q, want the response message", got)
+	}
+
+	rec = &ingestRecorder{ResponseWriter: httptest.NewRecorder(), status: http.StatusOK}
+	rec.WriteHeader(http.StatusBadGateway)
+	if got := rec.errorMessage(); got != "Bad Gateway" {
+		t.Errorf("errorMessage = %q, want status text", got)
+	}
+}
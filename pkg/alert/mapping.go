@@ -0,0 +1,501 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// PayloadMapping describes how a generic integration turns an arbitrary JSON
+// payload into alerts. Each field is an expression:
+//
+//   - a JSONPath starting with "$", e.g. "$.alert.name" or "$.tags[0]";
+//   - a Go template containing "{{", e.g. "{{ .host }}: {{ .check }}", with
+//     the functions lower, upper, trim, default, join, json and regex;
+//   - anything else is a literal value.
+//
+// JSONPath supports child names (".name", "['name']"), array indexes ("[0]",
+// "[-1]") and wildcards (".*", "[*]"). A path with several matches yields the
+// first one.
+type PayloadMapping struct {
+	// AlertsPath, if set, is a JSONPath selecting an array; each element is
+	// mapped to one alert and the other expressions are evaluated against it.
+	AlertsPath  string            `json:"alerts_path,omitempty"`
+	Title       string            `json:"title"`
+	Severity    string            `json:"severity,omitempty"`
+	SeverityMap map[string]string `json:"severity_map,omitempty"`
+	Status      string            `json:"status,omitempty"`
+	StatusMap   map[string]string `json:"status_map,omitempty"`
+	Fingerprint string            `json:"fingerprint,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	// LabelsPath, if set, is a JSONPath selecting an object whose scalar
+	// values become labels. Labels set explicitly take precedence.
+	LabelsPath  string            `json:"labels_path,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// MappingPreviewRequest is the JSON body for previewing an unsaved mapping.
+type MappingPreviewRequest struct {
+	Mapping *PayloadMapping `json:"mapping" validate:"required"`
+	Payload json.RawMessage `json:"payload" validate:"required"`
+}
+
+// MappingPreviewResponse shows the alerts a mapping produces for a payload.
+type MappingPreviewResponse struct {
+	Alerts []NormalizedAlert `json:"alerts"`
+	Count  int               `json:"count"`
+}
+
+var (
+	validSeverities = []string{"info", "warning", "major", "critical"}
+	validStatuses   = []string{"firing", "resolved"}
+)
+
+// compiledMapping is a PayloadMapping with its expressions parsed.
+type compiledMapping struct {
+	alertsPath  jsonPath
+	title       *mappingExpr
+	severity    *mappingExpr
+	severityMap map[string]string
+	status      *mappingExpr
+	statusMap   map[string]string
+	fingerprint *mappingExpr
+	description *mappingExpr
+	source      *mappingExpr
+	labelsPath  jsonPath
+	labels      map[string]*mappingExpr
+	annotations map[string]*mappingExpr
+}
+
+// compile parses and validates every expression in the mapping.
+func (m *PayloadMapping) compile() (*compiledMapping, error) {
+	if strings.TrimSpace(m.Title) == "" {
+		return nil, errors.New("title is required")
+	}
+	for k, v := range m.SeverityMap {
+		if !slices.Contains(validSeverities, v) {
+			return nil, fmt.Errorf("severity_map[%q]: %q is not one of %s", k, v, strings.Join(validSeverities, ", "))
+		}
+	}
+	for k, v := range m.StatusMap {
+		if !slices.Contains(validStatuses, v) {
+			return nil, fmt.Errorf("status_map[%q]: %q is not one of %s", k, v, strings.Join(validStatuses, ", "))
+		}
+	}
+
+	c := &compiledMapping{
+		severityMap: m.SeverityMap,
+		statusMap:   m.StatusMap,
+		labels:      map[string]*mappingExpr{},
+		annotations: map[string]*mappingExpr{},
+	}
+	var err error
+	if m.AlertsPath != "" {
+		if c.alertsPath, err = parseJSONPath(m.AlertsPath); err != nil {
+			return nil, fmt.Errorf("alerts_path: %w", err)
+		}
+	}
+	if m.LabelsPath != "" {
+		if c.labelsPath, err = parseJSONPath(m.LabelsPath); err != nil {
+			return nil, fmt.Errorf("labels_path: %w", err)
+		}
+	}
+
+	fields := []struct {
+		name string
+		src  string
+		dst  **mappingExpr
+	}{
+		{"title", m.Title, &c.title},
+		{"severity", m.Severity, &c.severity},
+		{"status", m.Status, &c.status},
+		{"fingerprint", m.Fingerprint, &c.fingerprint},
+		{"description", m.Description, &c.description},
+		{"source", m.Source, &c.source},
+	}
+	for _, f := range fields {
+		if *f.dst, err = compileExpr(f.name, f.src); err != nil {
+			return nil, err
+		}
+	}
+	for k, v := range m.Labels {
+		if k == "" {
+			return nil, errors.New("labels: empty label name")
+		}
+		if c.labels[k], err = compileExpr("labels."+k, v); err != nil {
+			return nil, err
+		}
+	}
+	for k, v := range m.Annotations {
+		if k == "" {
+			return nil, errors.New("annotations: empty annotation name")
+		}
+		if c.annotations[k], err = compileExpr("annotations."+k, v); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// mapPayload compiles the mapping and applies it to a raw JSON payload.
+func (m *PayloadMapping) mapPayload(body []byte) ([]NormalizedAlert, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return m.mapDocument(doc, SourceGeneric)
+}
+
+// mapDocument compiles the mapping and applies it to a decoded document.
+// Alerts without a mapped source get defaultSource.
+func (m *PayloadMapping) mapDocument(doc any, defaultSource string) ([]NormalizedAlert, error) {
+	c, err := m.compile()
+	if err != nil {
+		return nil, fmt.Errorf("invalid mapping: %w", err)
+	}
+	return c.apply(doc, defaultSource)
+}
+
+// apply maps a decoded payload to one or more alerts.
+func (c *compiledMapping) apply(doc any, defaultSource string) ([]NormalizedAlert, error) {
+	items := []any{doc}
+	if c.alertsPath != nil {
+		items = c.alertsPath.eval(doc)
+		if len(items) == 1 {
+			if arr, ok := items[0].([]any); ok {
+				items = arr
+			}
+		}
+		if len(items) == 0 {
+			return nil, errors.New("alerts_path matched no alerts")
+		}
+	}
+
+	alerts := make([]NormalizedAlert, 0, len(items))
+	for i, item := range items {
+		a, err := c.applyOne(item, defaultSource)
+		if err != nil {
+			if len(items) > 1 {
+				return nil, fmt.Errorf("alert %d: %w", i, err)
+			}
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, nil
+}
+
+func (c *compiledMapping) applyOne(item any, defaultSource string) (NormalizedAlert, error) {
+	var errs []error
+	eval := func(e *mappingExpr) string {
+		s, err := e.eval(item)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return s
+	}
+
+	title := eval(c.title)
+	severity := lookupMapped(c.severityMap, eval(c.severity))
+	status := lookupMapped(c.statusMap, eval(c.status))
+	fingerprint := eval(c.fingerprint)
+	description := eval(c.description)
+	source := eval(c.source)
+
+	labels := map[string]string{}
+	if c.labelsPath != nil {
+		if obj, ok := first(c.labelsPath.eval(item)).(map[string]any); ok {
+			for k, v := range obj {
+				switch v.(type) {
+				case map[string]any, []any, nil:
+				default:
+					labels[k] = stringify(v)
+				}
+			}
+		}
+	}
+	for k, e := range c.labels {
+		if v := eval(e); v != "" {
+			labels[k] = v
+		}
+	}
+	annotations := map[string]string{}
+	for k, e := range c.annotations {
+		if v := eval(e); v != "" {
+			annotations[k] = v
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return NormalizedAlert{}, err
+	}
+	if title == "" {
+		return NormalizedAlert{}, errors.New("title mapped to an empty value")
+	}
+
+	labelsJSON, _ := json.Marshal(labels)
+	annotationsJSON, _ := json.Marshal(annotations)
+	if fingerprint == "" {
+		fingerprint = generateFingerprint(title, labelsJSON)
+	}
+	if source == "" {
+		source = defaultSource
+	}
+	var desc *string
+	if description != "" {
+		desc = &description
+	}
+
+	return NormalizedAlert{
+		Fingerprint: fingerprint,
+		Status:      normalizeStatus(status),
+		Severity:    normalizeSeverity(severity),
+		Source:      source,
+		Title:       title,
+		Description: desc,
+		Labels:      labelsJSON,
+		Annotations: annotationsJSON,
+	}, nil
+}
+
+// lookupMapped translates v through a value map, matching keys exactly first
+// and then case-insensitively. Unmapped values are returned unchanged.
+func lookupMapped(m map[string]string, v string) string {
+	if mapped, ok := m[v]; ok {
+		return mapped
+	}
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		if strings.EqualFold(k, v) {
+			return m[k]
+		}
+	}
+	return v
+}
+
+// --- Expressions ---
+
+// mappingExpr is one compiled mapping expression. A nil expression evaluates
+// to the empty string.
+type mappingExpr struct {
+	literal string
+	path    jsonPath
+	tmpl    *template.Template
+}
+
+var mappingFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+	"default": func(def string, v any) string {
+		if s := stringify(v); s != "" {
+			return s
+		}
+		return def
+	},
+	"join": func(sep string, v any) string {
+		list, _ := v.([]any)
+		parts := make([]string, 0, len(list))
+		for _, e := range list {
+			parts = append(parts, stringify(e))
+		}
+		return strings.Join(parts, sep)
+	},
+	"json": func(v any) string {
+		b, _ := json.Marshal(v)
+		return string(b)
+	},
+	// regex returns the first capture group of pattern in s, or the whole
+	// match if the pattern has no groups.
+	"regex": func(pattern string, v any) (string, error) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return "", err
+		}
+		m := re.FindStringSubmatch(stringify(v))
+		switch {
+		case m == nil:
+			return "", nil
+		case len(m) > 1:
+			return m[1], nil
+		default:
+			return m[0], nil
+		}
+	},
+}
+
+func compileExpr(name, src string) (*mappingExpr, error) {
+	switch {
+	case src == "":
+		return nil, nil
+	case strings.HasPrefix(src, "$"):
+		p, err := parseJSONPath(src)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return &mappingExpr{path: p}, nil
+	case strings.Contains(src, "{{"):
+		t, err := template.New(name).Funcs(mappingFuncs).Option("missingkey=zero").Parse(src)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return &mappingExpr{tmpl: t}, nil
+	default:
+		return &mappingExpr{literal: src}, nil
+	}
+}
+
+func (e *mappingExpr) eval(doc any) (string, error) {
+	switch {
+	case e == nil:
+		return "", nil
+	case e.path != nil:
+		return stringify(first(e.path.eval(doc))), nil
+	case e.tmpl != nil:
+		var buf bytes.Buffer
+		if err := e.tmpl.Execute(&buf, doc); err != nil {
+			return "", fmt.Errorf("%s: %w", e.tmpl.Name(), err)
+		}
+		// Missing keys render as "<no value>"; treat them as empty.
+		return strings.TrimSpace(strings.ReplaceAll(buf.String(), "<no value>", "")), nil
+	default:
+		return e.literal, nil
+	}
+}
+
+// stringify renders a decoded JSON value as a label-friendly string.
+func stringify(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		return strconv.FormatBool(t)
+	default:
+		b, _ := json.Marshal(t)
+		return string(b)
+	}
+}
+
+func first(vs []any) any {
+	if len(vs) == 0 {
+		return nil
+	}
+	return vs[0]
+}
+
+// --- JSONPath ---
+
+// jsonPath is a parsed JSONPath expression: a sequence of steps applied to
+// the root document.
+type jsonPath []pathStep
+
+type pathStep struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parseJSONPath parses the supported JSONPath subset. The root "$" alone
+// selects the whole document.
+func parseJSONPath(src string) (jsonPath, error) {
+	if !strings.HasPrefix(src, "$") {
+		return nil, fmt.Errorf("JSONPath %q must start with $", src)
+	}
+	p := jsonPath{}
+	rest := src[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			if name == "" {
+				return nil, fmt.Errorf("JSONPath %q: empty name", src)
+			}
+			if name == "*" {
+				p = append(p, pathStep{wildcard: true})
+			} else {
+				p = append(p, pathStep{key: name})
+			}
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath %q: unclosed [", src)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				p = append(p, pathStep{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				p = append(p, pathStep{key: inner[1 : len(inner)-1]})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("JSONPath %q: invalid index [%s]", src, inner)
+				}
+				p = append(p, pathStep{index: n, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("JSONPath %q: unexpected %q", src, rest[0])
+		}
+	}
+	return p, nil
+}
+
+// eval returns every value the path selects in doc.
+func (p jsonPath) eval(doc any) []any {
+	cur := []any{doc}
+	for _, step := range p {
+		var next []any
+		for _, v := range cur {
+			switch t := v.(type) {
+			case map[string]any:
+				switch {
+				case step.wildcard:
+					for _, k := range slices.Sorted(maps.Keys(t)) {
+						next = append(next, t[k])
+					}
+				case !step.isIndex:
+					if child, ok := t[step.key]; ok {
+						next = append(next, child)
+					}
+				}
+			case []any:
+				switch {
+				case step.wildcard:
+					next = append(next, t...)
+				case step.isIndex:
+					i := step.index
+					if i < 0 {
+						i += len(t)
+					}
+					if i >= 0 && i < len(t) {
+						next = append(next, t[i])
+					}
+				}
+			}
+		}
+		cur = next
+	}
+	return cur
+}
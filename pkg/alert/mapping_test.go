@@ -0,0 +1,232 @@
+package alert
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	doc := map[string]any{
+		"alert":   map[string]any{"name": "DiskFull", "tags": []any{"a", "b", "c"}},
+		"odd key": "x",
+	}
+	tests := []struct {
+		path string
+		want []any
+	}{
+		{"$.alert.name", []any{"DiskFull"}},
+		{"$['alert']['name']", []any{"DiskFull"}},
+		{`$["odd key"]`, []any{"x"}},
+		{"$.alert.tags[1]", []any{"b"}},
+		{"$.alert.tags[-1]", []any{"c"}},
+		{"$.alert.tags[*]", []any{"a", "b", "c"}},
+		{"$.alert.tags[9]", nil},
+		{"$.missing.name", nil},
+	}
+	for _, tt := range tests {
+		p, err := parseJSONPath(tt.path)
+		if err != nil {
+			t.Fatalf("parseJSONPath(%q): %v", tt.path, err)
+		}
+		got := p.eval(doc)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s = %v, want %v", tt.path, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s[%d] = %v, want %v", tt.path, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestParseJSONPath_Invalid(t *testing.T) {
+	for _, path := range []string{"alert.name", "$.", "$.a[", "$.a[x]", "$a"} {
+		if _, err := parseJSONPath(path); err == nil {
+			t.Errorf("parseJSONPath(%q): expected error", path)
+		}
+	}
+}
+
+func TestPayloadMapping_Map(t *testing.T) {
+	m := &PayloadMapping{
+		Title:       "{{ .check }} on {{ .host }}",
+		Severity:    "$.level",
+		SeverityMap: map[string]string{"sev1": "critical", "sev3": "info"},
+		Status:      "$.state",
+		StatusMap:   map[string]string{"cleared": "resolved"},
+		Fingerprint: "$.id",
+		Description: "$.output",
+		Source:      "nagios",
+		Labels:      map[string]string{"host": "$.host", "env": "prod", "missing": "$.nope"},
+		Annotations: map[string]string{"attempt": "$.attempt"},
+	}
+	alerts, err := m.mapPayload([]byte(`{"id":"n-42","check":"disk","host":"db1","level":"SEV1",
+		"state":"problem","output":"92% used","attempt":3}`))
+	if err != nil {
+		t.Fatalf("mapPayload: %v", err)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1", len(alerts))
+	}
+	a := alerts[0]
+	if a.Title != "disk on db1" {
+		t.Errorf("Title = %q", a.Title)
+	}
+	if a.Severity != "critical" {
+		t.Errorf("Severity = %q, want critical (case-insensitive severity_map)", a.Severity)
+	}
+	if a.Status != "firing" {
+		t.Errorf("Status = %q, want firing", a.Status)
+	}
+	if a.Fingerprint != "n-42" || a.Source != "nagios" {
+		t.Errorf("Fingerprint = %q, Source = %q", a.Fingerprint, a.Source)
+	}
+	if a.Description == nil || *a.Description != "92% used" {
+		t.Errorf("Description = %v", a.Description)
+	}
+	if string(a.Labels) != `{"env":"prod","host":"db1"}` {
+		t.Errorf("Labels = %s", a.Labels)
+	}
+	if string(a.Annotations) != `{"attempt":"3"}` {
+		t.Errorf("Annotations = %s", a.Annotations)
+	}
+
+	alerts, err = m.mapPayload([]byte(`{"check":"disk","host":"db1","state":"cleared"}`))
+	if err != nil {
+		t.Fatalf("mapPayload: %v", err)
+	}
+	if alerts[0].Status != "resolved" {
+		t.Errorf("Status = %q, want resolved via status_map", alerts[0].Status)
+	}
+	if alerts[0].Fingerprint == "" {
+		t.Error("expected a generated fingerprint when none is mapped")
+	}
+	if alerts[0].Severity != "warning" {
+		t.Errorf("Severity = %q, want default warning", alerts[0].Severity)
+	}
+}
+
+func TestPayloadMapping_AlertsPath(t *testing.T) {
+	m := &PayloadMapping{
+		AlertsPath: "$.events",
+		Title:      "$.summary",
+		Severity:   "$.priority",
+		LabelsPath: "$.tags",
+		Labels:     map[string]string{"team": "{{ .owner | default \"unowned\" }}"},
+	}
+	alerts, err := m.mapPayload([]byte(`{"events":[
+		{"summary":"A","priority":"P1","tags":{"region":"eu","count":2,"nested":{"x":1}},"owner":"db"},
+		{"summary":"B","priority":"low"}]}`))
+	if err != nil {
+		t.Fatalf("mapPayload: %v", err)
+	}
+	if len(alerts) != 2 {
+		t.Fatalf("got %d alerts, want 2", len(alerts))
+	}
+	if alerts[0].Severity != "critical" || alerts[1].Severity != "info" {
+		t.Errorf("severities = %q, %q", alerts[0].Severity, alerts[1].Severity)
+	}
+	if string(alerts[0].Labels) != `{"count":"2","region":"eu","team":"db"}` {
+		t.Errorf("alerts[0].Labels = %s", alerts[0].Labels)
+	}
+	if string(alerts[1].Labels) != `{"team":"unowned"}` {
+		t.Errorf("alerts[1].Labels = %s", alerts[1].Labels)
+	}
+}
+
+func TestPayloadMapping_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping PayloadMapping
+		body    string
+		want    string
+	}{
+		{"no title", PayloadMapping{Severity: "$.s"}, `{}`, "title is required"},
+		{"bad path", PayloadMapping{Title: "$.a["}, `{}`, "unclosed"},
+		{"bad template", PayloadMapping{Title: "{{ .a "}, `{}`, "title"},
+		{"bad severity map", PayloadMapping{Title: "x", SeverityMap: map[string]string{"a": "urgent"}}, `{}`, "severity_map"},
+		{"bad status map", PayloadMapping{Title: "x", StatusMap: map[string]string{"a": "closed"}}, `{}`, "status_map"},
+		{"empty title", PayloadMapping{Title: "$.missing"}, `{}`, "title mapped to an empty value"},
+		{"no alerts", PayloadMapping{Title: "x", AlertsPath: "$.items"}, `{"items":[]}`, "matched no alerts"},
+		{"bad json", PayloadMapping{Title: "x"}, `{`, "invalid JSON"},
+		{"item error", PayloadMapping{Title: "$.t", AlertsPath: "$.items"}, `{"items":[{"t":"a"},{}]}`, "alert 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.mapping.mapPayload([]byte(tt.body))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMappingExpr_TemplateFuncs(t *testing.T) {
+	doc := map[string]any{"name": " Disk ", "tags": []any{"a", "b"}, "meta": map[string]any{"k": "v"}}
+	tests := map[string]string{
+		"{{ .name | trim | lower }}": "disk",
+		`{{ join "," .tags }}`:       "a,b",
+		"{{ json .meta }}":           `{"k":"v"}`,
+		"{{ .missing }}":             "",
+		"static":                     "static",
+	}
+	for src, want := range tests {
+		e, err := compileExpr("t", src)
+		if err != nil {
+			t.Fatalf("compileExpr(%q): %v", src, err)
+		}
+		got, err := e.eval(doc)
+		if err != nil {
+			t.Fatalf("eval(%q): %v", src, err)
+		}
+		if got != want {
+			t.Errorf("%s = %q, want %q", src, got, want)
+		}
+	}
+}
+
+func TestIntegrationHandler_Preview(t *testing.T) {
+	h := NewIntegrationHandler(nil, nil)
+	body := `{"mapping":{"title":"$.msg","severity":"$.lvl","severity_map":{"hi":"major"}},"payload":{"msg":"boom","lvl":"hi"}}`
+	rec := httptest.NewRecorder()
+	h.handlePreview(rec, httptest.NewRequest(http.MethodPost, "/preview", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var resp MappingPreviewResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Count != 1 || resp.Alerts[0].Title != "boom" || resp.Alerts[0].Severity != "major" {
+		t.Errorf("preview = %+v", resp)
+	}
+
+	rec = httptest.NewRecorder()
+	body = `{"mapping":{"title":"$.nope"},"payload":{}}`
+	h.handlePreview(rec, httptest.NewRequest(http.MethodPost, "/preview", strings.NewReader(body)))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want 422 for a mapping that yields no title", rec.Code)
+	}
+}
+
+func TestRespondPreview_AppliesIntegrationDefaults(t *testing.T) {
+	in := &Integration{ID: uuid.New(), DefaultLabels: map[string]string{"team": "ops"}}
+	rec := httptest.NewRecorder()
+	respondPreview(rec, &PayloadMapping{Title: "x"}, json.RawMessage(`{}`), in)
+
+	var resp MappingPreviewResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	a := resp.Alerts[0]
+	if a.IntegrationID == nil || *a.IntegrationID != in.ID || string(a.Labels) != `{"team":"ops"}` {
+		t.Errorf("alert = %+v, want integration defaults applied", a)
+	}
+}
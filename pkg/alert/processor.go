@@ -0,0 +1,210 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/wisbric/nightowl/internal/db"
+	"github.com/wisbric/nightowl/pkg/tenant"
+)
+
+const (
+	// ingestPollInterval is how often every tenant's queue is checked for
+	// due payloads, such as retries or payloads queued on another instance.
+	ingestPollInterval = 5 * time.Second
+	// ingestLease is how long a claimed payload stays with its processor
+	// without progress before another may take it over.
+	ingestLease = 5 * time.Minute
+	// ingestMaxAttempts is how often a payload is tried before it becomes a
+	// dead letter; with the backoff below that spans about half an hour.
+	ingestMaxAttempts = 10
+
+	ingestRetryMin = 5 * time.Second
+	ingestRetryMax = 10 * time.Minute
+
+	// archivePruneInterval is how often archived payloads past their
+	// retention are deleted.
+	archivePruneInterval = time.Hour
+)
+
+// Processor turns the payloads in tenants' ingest queues into alerts:
+// normalization, deduplication, grouping and enrichment run here rather
+// than in the webhook request. Failed payloads are retried with
+// exponential backoff and become dead letters after ingestMaxAttempts;
+// payloads that cannot be parsed are dead-lettered at once. Several
+// processors may run, each payload is processed by one of them at a time.
+//
+// Retries can reorder payloads: one that failed is applied after payloads
+// received later, which already went through.
+//
+// Processed payloads are archived (see QueueStore.Complete); the processor
+// also prunes the archive once an hour.
+type Processor struct {
+	pool    *pgxpool.Pool
+	webhook *WebhookHandler
+	logger  *slog.Logger
+	wake    chan string
+}
+
+// NewProcessor creates a Processor that ingests through webhook's pipeline.
+func NewProcessor(pool *pgxpool.Pool, webhook *WebhookHandler, logger *slog.Logger) *Processor {
+	return &Processor{pool: pool, webhook: webhook, logger: logger, wake: make(chan string, 64)}
+}
+
+// Wake asks the processor to drain a tenant's queue now instead of at the
+// next poll. It never blocks; set it as WebhookHandler.OnQueued.
+func (p *Processor) Wake(tenantSlug string) {
+	select {
+	case p.wake <- tenantSlug:
+	default: // A drain is pending anyway.
+	}
+}
+
+// Run processes queued payloads until ctx is cancelled.
+func (p *Processor) Run(ctx context.Context) {
+	p.logger.Info("ingest processor started", "poll_interval", ingestPollInterval)
+	ticker := time.NewTicker(ingestPollInterval)
+	defer ticker.Stop()
+	pruneTicker := time.NewTicker(archivePruneInterval)
+	defer pruneTicker.Stop()
+
+	p.drainAll(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			p.logger.Info("ingest processor stopped")
+			return
+		case <-ticker.C:
+			p.drainAll(ctx)
+		case <-pruneTicker.C:
+			p.pruneAll(ctx)
+		case slug := <-p.wake:
+			if err := p.drain(ctx, slug); err != nil {
+				p.logger.Error("draining ingest queue", "tenant", slug, "error", err)
+			}
+		}
+	}
+}
+
+func (p *Processor) drainAll(ctx context.Context) {
+	tenants, err := db.New(p.pool).ListTenants(ctx)
+	if err != nil {
+		p.logger.Error("listing tenants for ingest", "error", err)
+		return
+	}
+	for _, t := range tenants {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := p.drain(ctx, t.Slug); err != nil {
+			p.logger.Error("draining ingest queue", "tenant", t.Slug, "error", err)
+		}
+	}
+}
+
+// pruneAll deletes every tenant's archived payloads past their retention.
+// With several processors the tenants are pruned more than once an hour,
+// which is harmless.
+func (p *Processor) pruneAll(ctx context.Context) {
+	tenants, err := db.New(p.pool).ListTenants(ctx)
+	if err != nil {
+		p.logger.Error("listing tenants for archive pruning", "error", err)
+		return
+	}
+	for _, t := range tenants {
+		if ctx.Err() != nil {
+			return
+		}
+		tctx, release, err := tenant.Acquire(ctx, p.pool, t.Slug)
+		if errors.Is(err, tenant.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			p.logger.Error("acquiring tenant for archive pruning", "tenant", t.Slug, "error", err)
+			continue
+		}
+		n, err := NewArchiveStore(tenant.ConnFromContext(tctx)).Prune(tctx)
+		release()
+		if err != nil {
+			p.logger.Error("pruning payload archive", "tenant", t.Slug, "error", err)
+		} else if n > 0 {
+			p.logger.Info("pruned payload archive", "tenant", t.Slug, "deleted", n)
+		}
+	}
+}
+
+// drain processes a tenant's due payloads until none is left. Inactive
+// tenants keep their queue until they are resumed.
+func (p *Processor) drain(ctx context.Context, slug string) error {
+	tctx, release, err := tenant.Acquire(ctx, p.pool, slug)
+	if errors.Is(err, tenant.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	queue := NewQueueStore(tenant.ConnFromContext(tctx))
+	for tctx.Err() == nil {
+		job, err := queue.Claim(tctx, ingestLease)
+		if errors.Is(err, ErrQueuedPayloadNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("claiming queued payload: %w", err)
+		}
+		if err := p.process(tctx, queue, &job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// process ingests one claimed payload and completes, retries or
+// dead-letters it. It only fails if the queue cannot be updated.
+func (p *Processor) process(ctx context.Context, queue *QueueStore, job *QueuedPayload) error {
+	err := p.webhook.ingest(ctx, queue, job)
+	if err == nil {
+		if m := p.webhook.metrics; m != nil && m.QueueLatency != nil {
+			m.QueueLatency.WithLabelValues(job.Source).Observe(time.Since(job.ReceivedAt).Seconds())
+		}
+		return queue.Complete(ctx, job.ID)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err() // Shutting down; the lease hands the payload on.
+	}
+
+	var pe *payloadError
+	if errors.As(err, &pe) || job.Attempts >= ingestMaxAttempts {
+		p.logger.Error("dead-lettering webhook payload",
+			"id", job.ID, "source", job.Source, "attempts", job.Attempts, "error", err)
+		if m := p.webhook.metrics; m != nil && m.DeadLetteredTotal != nil {
+			m.DeadLetteredTotal.WithLabelValues(job.Source).Inc()
+		}
+		return queue.Kill(ctx, job.ID, err.Error())
+	}
+
+	delay := ingestRetryDelay(job.Attempts)
+	p.logger.Warn("webhook payload failed, retrying",
+		"id", job.ID, "source", job.Source, "attempts", job.Attempts, "retry_in", delay, "error", err)
+	if m := p.webhook.metrics; m != nil && m.RetriesTotal != nil {
+		m.RetriesTotal.WithLabelValues(job.Source).Inc()
+	}
+	return queue.Retry(ctx, job.ID, err.Error(), time.Now().Add(delay))
+}
+
+// ingestRetryDelay is the backoff after the given number of failed
+// attempts: ingestRetryMin, doubling up to ingestRetryMax.
+func ingestRetryDelay(attempts int) time.Duration {
+	delay := ingestRetryMin
+	for i := 1; i < attempts && delay < ingestRetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, ingestRetryMax)
+}
@@ -0,0 +1,277 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wisbric/nightowl/internal/db"
+)
+
+// Ingest queue states. Processed payloads are removed from the queue.
+const (
+	QueuePending = "pending"
+	QueueDead    = "dead"
+)
+
+// ErrQueuedPayloadNotFound is returned when no queued payload matches.
+var ErrQueuedPayloadNotFound = errors.New("queued payload not found")
+
+// IngestOrigin records who sent a queued payload, so the alerts it turns
+// into are audited as the sender's once it is processed.
+type IngestOrigin struct {
+	UserID    *uuid.UUID  `json:"user_id,omitempty"`
+	APIKeyID  *uuid.UUID  `json:"api_key_id,omitempty"`
+	IPAddress *netip.Addr `json:"ip_address,omitempty"`
+	UserAgent *string     `json:"user_agent,omitempty"`
+}
+
+// QueuedPayload is a webhook payload accepted into the ingest queue.
+// Processed counts the payload's alerts already persisted, so a retry
+// resumes after them; AlertIDs are the alerts they created or updated.
+type QueuedPayload struct {
+	ID            uuid.UUID    `json:"id"`
+	Source        string       `json:"source"`
+	IntegrationID *uuid.UUID   `json:"integration_id,omitempty"`
+	Payload       []byte       `json:"-"`
+	PayloadSize   int          `json:"payload_size"`
+	Query         string       `json:"query,omitempty"`
+	Headers       http.Header  `json:"headers"`
+	Origin        IngestOrigin `json:"origin"`
+	Status        string       `json:"status"`
+	Attempts      int          `json:"attempts"`
+	Processed     int          `json:"processed"`
+	AlertIDs      []uuid.UUID  `json:"alert_ids"`
+	LastError     *string      `json:"last_error,omitempty"`
+	NextAttemptAt time.Time    `json:"next_attempt_at"`
+	ReceivedAt    time.Time    `json:"received_at"`
+}
+
+// QueuedPayloadDetail is a queued payload including the payload itself.
+type QueuedPayloadDetail struct {
+	QueuedPayload
+	Payload string `json:"payload"`
+}
+
+// QueueStats counts the payloads in the ingest queue by state.
+type QueueStats struct {
+	Pending int64 `json:"pending"`
+	Dead    int64 `json:"dead"`
+}
+
+// queueColumns omits the payload, which only Get and Claim load.
+const queueColumns = `id, source, integration_id, octet_length(payload), query, headers, origin, status,
+	attempts, processed, alert_ids, last_error, next_attempt_at, received_at`
+
+// QueueStore provides database operations for the ingest queue.
+type QueueStore struct {
+	dbtx db.DBTX
+}
+
+// NewQueueStore creates a QueueStore backed by the given connection.
+func NewQueueStore(dbtx db.DBTX) *QueueStore {
+	return &QueueStore{dbtx: dbtx}
+}
+
+// scanQueued scans queueColumns, followed by the payload if withPayload.
+func scanQueued(row pgx.Row, withPayload bool) (QueuedPayload, error) {
+	var p QueuedPayload
+	var integrationID pgtype.UUID
+	var headers, origin []byte
+	dest := []any{&p.ID, &p.Source, &integrationID, &p.PayloadSize, &p.Query, &headers, &origin, &p.Status,
+		&p.Attempts, &p.Processed, &p.AlertIDs, &p.LastError, &p.NextAttemptAt, &p.ReceivedAt}
+	if withPayload {
+		dest = append(dest, &p.Payload)
+	}
+	if err := row.Scan(dest...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return QueuedPayload{}, ErrQueuedPayloadNotFound
+		}
+		return QueuedPayload{}, err
+	}
+	if err := json.Unmarshal(headers, &p.Headers); err != nil {
+		return QueuedPayload{}, fmt.Errorf("decoding headers: %w", err)
+	}
+	if err := json.Unmarshal(origin, &p.Origin); err != nil {
+		return QueuedPayload{}, fmt.Errorf("decoding origin: %w", err)
+	}
+	p.IntegrationID = pgtypeUUIDToPtr(integrationID)
+	if p.AlertIDs == nil {
+		p.AlertIDs = []uuid.UUID{}
+	}
+	return p, nil
+}
+
+// Enqueue inserts a pending payload and sets its ID and receipt time.
+func (s *QueueStore) Enqueue(ctx context.Context, p *QueuedPayload) error {
+	headers, _ := json.Marshal(nonNilHeaders(p.Headers))
+	origin, _ := json.Marshal(p.Origin)
+	err := s.dbtx.QueryRow(ctx, `
+		INSERT INTO ingest_queue (source, integration_id, payload, query, headers, origin)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, received_at`,
+		p.Source, ptrToPgtypeUUID(p.IntegrationID), p.Payload, p.Query, headers, origin,
+	).Scan(&p.ID, &p.ReceivedAt)
+	if err != nil {
+		return fmt.Errorf("queueing payload: %w", err)
+	}
+	p.Status = QueuePending
+	p.PayloadSize = len(p.Payload)
+	return nil
+}
+
+// Claim takes the oldest due payload and counts the attempt. The payload
+// is leased: it becomes due again after lease unless completed, failed or
+// progressed first, so a processor that dies mid-payload does not lose it.
+// Concurrent processors claim different payloads. It returns
+// ErrQueuedPayloadNotFound when nothing is due.
+func (s *QueueStore) Claim(ctx context.Context, lease time.Duration) (QueuedPayload, error) {
+	return scanQueued(s.dbtx.QueryRow(ctx, `
+		UPDATE ingest_queue
+		SET attempts = attempts + 1, next_attempt_at = now() + $1::interval
+		WHERE id = (
+		    SELECT id FROM ingest_queue
+		    WHERE status = 'pending' AND next_attempt_at <= now()
+		    ORDER BY received_at
+		    LIMIT 1
+		    FOR UPDATE SKIP LOCKED)
+		RETURNING `+queueColumns+`, payload`, lease), true)
+}
+
+// Progress records that the first processed alerts of a payload are
+// persisted, appending alertID if set, and renews the lease.
+func (s *QueueStore) Progress(ctx context.Context, id uuid.UUID, processed int, alertID *uuid.UUID, lease time.Duration) error {
+	_, err := s.dbtx.Exec(ctx, `
+		UPDATE ingest_queue
+		SET processed = $2,
+		    alert_ids = CASE WHEN $3::uuid IS NULL THEN alert_ids ELSE array_append(alert_ids, $3::uuid) END,
+		    next_attempt_at = now() + $4::interval
+		WHERE id = $1`, id, processed, ptrToPgtypeUUID(alertID), lease)
+	if err != nil {
+		return fmt.Errorf("recording queue progress: %w", err)
+	}
+	return nil
+}
+
+// Complete removes a processed payload from the queue and archives it in
+// webhook_payloads, unless its integration keeps no payloads.
+func (s *QueueStore) Complete(ctx context.Context, id uuid.UUID) error {
+	_, err := s.dbtx.Exec(ctx, `
+		WITH done AS (
+		    DELETE FROM ingest_queue WHERE id = $1
+		    RETURNING id, source, integration_id, payload, query, headers, alert_ids, received_at)
+		INSERT INTO webhook_payloads (id, source, integration_id, payload, query, headers, alert_ids, received_at)
+		SELECT d.id, d.source, d.integration_id, d.payload, d.query, d.headers, d.alert_ids, d.received_at
+		FROM done d
+		LEFT JOIN webhook_integrations i ON i.id = d.integration_id
+		WHERE COALESCE(i.payload_retention_days, $2) > 0`, id, DefaultPayloadRetentionDays)
+	if err != nil {
+		return fmt.Errorf("completing queued payload: %w", err)
+	}
+	return nil
+}
+
+// Retry records a failed attempt and schedules the next one.
+func (s *QueueStore) Retry(ctx context.Context, id uuid.UUID, errMsg string, at time.Time) error {
+	_, err := s.dbtx.Exec(ctx, `
+		UPDATE ingest_queue SET last_error = $2, next_attempt_at = $3 WHERE id = $1`, id, errMsg, at)
+	if err != nil {
+		return fmt.Errorf("scheduling queue retry: %w", err)
+	}
+	return nil
+}
+
+// Kill moves a payload to the dead letters.
+func (s *QueueStore) Kill(ctx context.Context, id uuid.UUID, errMsg string) error {
+	_, err := s.dbtx.Exec(ctx, `
+		UPDATE ingest_queue SET status = 'dead', last_error = $2 WHERE id = $1`, id, errMsg)
+	if err != nil {
+		return fmt.Errorf("dead-lettering queued payload: %w", err)
+	}
+	return nil
+}
+
+// List returns payloads in the given state, oldest first for pending and
+// newest first for dead letters.
+func (s *QueueStore) List(ctx context.Context, status string, limit int) ([]QueuedPayload, error) {
+	order := "received_at DESC"
+	if status == QueuePending {
+		order = "next_attempt_at"
+	}
+	rows, err := s.dbtx.Query(ctx, `SELECT `+queueColumns+` FROM ingest_queue
+		WHERE status = $1 ORDER BY `+order+` LIMIT $2`, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("listing queued payloads: %w", err)
+	}
+	defer rows.Close()
+
+	items := []QueuedPayload{}
+	for rows.Next() {
+		p, err := scanQueued(rows, false)
+		if err != nil {
+			return nil, fmt.Errorf("scanning queued payload: %w", err)
+		}
+		items = append(items, p)
+	}
+	return items, rows.Err()
+}
+
+// Stats counts the queued payloads by state.
+func (s *QueueStore) Stats(ctx context.Context) (QueueStats, error) {
+	var st QueueStats
+	err := s.dbtx.QueryRow(ctx, `
+		SELECT count(*) FILTER (WHERE status = 'pending'), count(*) FILTER (WHERE status = 'dead')
+		FROM ingest_queue`).Scan(&st.Pending, &st.Dead)
+	if err != nil {
+		return QueueStats{}, fmt.Errorf("counting queued payloads: %w", err)
+	}
+	return st, nil
+}
+
+// Get returns one queued payload including the payload.
+func (s *QueueStore) Get(ctx context.Context, id uuid.UUID) (QueuedPayload, error) {
+	return scanQueued(s.dbtx.QueryRow(ctx,
+		`SELECT `+queueColumns+`, payload FROM ingest_queue WHERE id = $1`, id), true)
+}
+
+// Replay returns a dead letter to the queue with fresh attempts. Alerts it
+// already persisted are not processed again.
+func (s *QueueStore) Replay(ctx context.Context, id uuid.UUID) (QueuedPayload, error) {
+	return scanQueued(s.dbtx.QueryRow(ctx, `
+		UPDATE ingest_queue
+		SET status = 'pending', attempts = 0, next_attempt_at = now()
+		WHERE id = $1 AND status = 'dead'
+		RETURNING `+queueColumns, id), false)
+}
+
+// ReplayAll returns every dead letter to the queue and reports how many.
+func (s *QueueStore) ReplayAll(ctx context.Context) (int64, error) {
+	tag, err := s.dbtx.Exec(ctx, `
+		UPDATE ingest_queue
+		SET status = 'pending', attempts = 0, next_attempt_at = now()
+		WHERE status = 'dead'`)
+	if err != nil {
+		return 0, fmt.Errorf("replaying dead letters: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Discard deletes a dead letter.
+func (s *QueueStore) Discard(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.dbtx.Exec(ctx, `DELETE FROM ingest_queue WHERE id = $1 AND status = 'dead'`, id)
+	if err != nil {
+		return fmt.Errorf("discarding dead letter: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrQueuedPayloadNotFound
+	}
+	return nil
+}
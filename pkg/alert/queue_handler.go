@@ -0,0 +1,177 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/wisbric/core/pkg/auth"
+	"github.com/wisbric/core/pkg/httpserver"
+
+	"github.com/wisbric/nightowl/internal/audit"
+	"github.com/wisbric/nightowl/pkg/tenant"
+)
+
+// QueueHandler lets admins inspect the ingest queue and deal with dead
+// letters: replaying them once the cause is fixed, or discarding them.
+type QueueHandler struct {
+	logger *slog.Logger
+	audit  *audit.Writer
+
+	// OnQueued, if set, is called with the tenant slug after dead letters
+	// are replayed, e.g. to wake a Processor.
+	OnQueued func(tenantSlug string)
+}
+
+// NewQueueHandler creates a QueueHandler.
+func NewQueueHandler(logger *slog.Logger, audit *audit.Writer) *QueueHandler {
+	return &QueueHandler{logger: logger, audit: audit}
+}
+
+// Routes returns a chi.Router with the ingest queue routes mounted.
+func (h *QueueHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(auth.RequireRole(auth.RoleAdmin))
+	r.Get("/", h.handleList)
+	r.Post("/replay", h.handleReplayAll)
+	r.Route("/{id}", func(r chi.Router) {
+		r.Get("/", h.handleGet)
+		r.Post("/replay", h.handleReplay)
+		r.Delete("/", h.handleDiscard)
+	})
+	return r
+}
+
+func (h *QueueHandler) store(r *http.Request) *QueueStore {
+	return NewQueueStore(tenant.ConnFromContext(r.Context()))
+}
+
+func parseQueueID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid queued payload ID")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// handleList returns dead letters, or pending payloads with
+// ?status=pending, along with the queue's counts.
+func (h *QueueHandler) handleList(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = QueueDead
+	case QueueDead, QueuePending:
+	default:
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "status must be dead or pending")
+		return
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 200 {
+			limit = n
+		}
+	}
+
+	store := h.store(r)
+	items, err := store.List(r.Context(), status, limit)
+	if err != nil {
+		h.logger.Error("listing ingest queue", "error", err)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to list ingest queue")
+		return
+	}
+	stats, err := store.Stats(r.Context())
+	if err != nil {
+		h.logger.Error("counting ingest queue", "error", err)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to list ingest queue")
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]any{"items": items, "count": len(items), "stats": stats})
+}
+
+func (h *QueueHandler) handleGet(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseQueueID(w, r)
+	if !ok {
+		return
+	}
+	p, err := h.store(r).Get(r.Context(), id)
+	if err != nil {
+		h.respondErr(w, "getting queued payload", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, QueuedPayloadDetail{QueuedPayload: p, Payload: string(p.Payload)})
+}
+
+func (h *QueueHandler) handleReplay(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseQueueID(w, r)
+	if !ok {
+		return
+	}
+	p, err := h.store(r).Replay(r.Context(), id)
+	if err != nil {
+		h.respondErr(w, "replaying dead letter", err)
+		return
+	}
+	h.queued(r)
+
+	if h.audit != nil {
+		detail, _ := json.Marshal(map[string]string{"source": p.Source})
+		h.audit.LogFromRequest(r, "replay", "ingest_queue", p.ID, detail)
+	}
+	httpserver.Respond(w, http.StatusOK, p)
+}
+
+func (h *QueueHandler) handleReplayAll(w http.ResponseWriter, r *http.Request) {
+	n, err := h.store(r).ReplayAll(r.Context())
+	if err != nil {
+		h.respondErr(w, "replaying dead letters", err)
+		return
+	}
+	if n > 0 {
+		h.queued(r)
+		if h.audit != nil {
+			detail, _ := json.Marshal(map[string]int64{"replayed": n})
+			h.audit.LogFromRequest(r, "replay_all", "ingest_queue", uuid.Nil, detail)
+		}
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]int64{"replayed": n})
+}
+
+func (h *QueueHandler) handleDiscard(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseQueueID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.store(r).Discard(r.Context(), id); err != nil {
+		h.respondErr(w, "discarding dead letter", err)
+		return
+	}
+
+	if h.audit != nil {
+		h.audit.LogFromRequest(r, "discard", "ingest_queue", id, nil)
+	}
+	httpserver.Respond(w, http.StatusNoContent, nil)
+}
+
+// queued reports replayed payloads to OnQueued.
+func (h *QueueHandler) queued(r *http.Request) {
+	if info := tenant.FromContext(r.Context()); info != nil && h.OnQueued != nil {
+		h.OnQueued(info.Slug)
+	}
+}
+
+// respondErr maps queue errors to HTTP responses.
+func (h *QueueHandler) respondErr(w http.ResponseWriter, what string, err error) {
+	if errors.Is(err, ErrQueuedPayloadNotFound) {
+		httpserver.RespondError(w, http.StatusNotFound, "not_found", err.Error())
+		return
+	}
+	h.logger.Error(what, "error", err)
+	httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed "+what)
+}
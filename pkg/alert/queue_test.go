@@ -0,0 +1,214 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wisbric/core/pkg/auth"
+
+	"github.com/wisbric/nightowl/pkg/tenant"
+)
+
+// queueTestHandler returns a WebhookHandler whose queue records payloads
+// instead of storing them.
+func queueTestHandler(queued *[]*QueuedPayload, err error) *WebhookHandler {
+	h := NewWebhookHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), nil, nil, nil, nil, nil, nil)
+	h.enqueue = func(_ context.Context, p *QueuedPayload) error {
+		if err != nil {
+			return err
+		}
+		p.ID = uuid.New()
+		*queued = append(*queued, p)
+		return nil
+	}
+	return h
+}
+
+func TestReceive_QueuesPayload(t *testing.T) {
+	var queued []*QueuedPayload
+	h := queueTestHandler(&queued, nil)
+	var woken []string
+	h.OnQueued = func(slug string) { woken = append(woken, slug) }
+
+	userID := uuid.New()
+	body := `{"title":"Disk full","severity":"critical"}`
+	r := httptest.NewRequest(http.MethodPost, "/webhooks/generic?x=1&token=t", strings.NewReader(body))
+	r.Header.Set("User-Agent", "probe/1.0")
+	r.Header.Set("X-API-Key", "ow_secret")
+	r.RemoteAddr = "10.0.0.7:5000"
+	ctx := tenant.NewContext(r.Context(), &tenant.Info{Slug: "acme", Schema: "tenant_acme"})
+	ctx = auth.NewContext(ctx, &auth.Identity{Subject: "u", UserID: &userID})
+	rec := httptest.NewRecorder()
+	h.receiver(SourceGeneric)(rec, r.WithContext(ctx))
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", rec.Code, rec.Body)
+	}
+	if len(queued) != 1 {
+		t.Fatalf("queued %d payloads, want 1", len(queued))
+	}
+	p := queued[0]
+	if p.Source != SourceGeneric || string(p.Payload) != body || p.Query != "x=1" {
+		t.Errorf("queued = %+v", p)
+	}
+	if p.Headers.Get("User-Agent") != "probe/1.0" || p.Headers.Get("X-API-Key") != "" {
+		t.Errorf("headers = %v, want the user agent without the API key", p.Headers)
+	}
+	if p.Origin.UserID == nil || *p.Origin.UserID != userID ||
+		p.Origin.IPAddress == nil || p.Origin.IPAddress.String() != "10.0.0.7" ||
+		p.Origin.UserAgent == nil || *p.Origin.UserAgent != "probe/1.0" {
+		t.Errorf("origin = %+v", p.Origin)
+	}
+	if len(woken) != 1 || woken[0] != "acme" {
+		t.Errorf("woken = %v, want [acme]", woken)
+	}
+
+	var resp AcceptedResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Status != "queued" || resp.ID == nil || *resp.ID != p.ID || resp.AlertsReceived != 1 {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestReceive_PagerDutyResponse(t *testing.T) {
+	var queued []*QueuedPayload
+	h := queueTestHandler(&queued, nil)
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"event_action":"acknowledge","dedup_key":"db-1"}`))
+	rec := httptest.NewRecorder()
+	h.receiver(SourcePagerDuty)(rec, r)
+
+	if rec.Code != http.StatusAccepted || len(queued) != 1 {
+		t.Fatalf("status = %d, queued %d; want 202 and 1", rec.Code, len(queued))
+	}
+	var resp eventsV2Response
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Status != "success" || resp.DedupKey != "db-1" {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestReceive_QueueUnavailable(t *testing.T) {
+	var queued []*QueuedPayload
+	h := queueTestHandler(&queued, errors.New("connection refused"))
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"message":"CPU high"}`))
+	rec := httptest.NewRecorder()
+	h.receiver(SourceOpsgenie)(rec, r)
+
+	// The sender must retry: nothing was stored.
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+}
+
+func TestParsePayload_Errors(t *testing.T) {
+	tests := []struct {
+		source string
+		body   string
+		status int
+	}{
+		{SourceAlertmanager, `{`, http.StatusBadRequest},
+		{SourceAlertmanager, `{"alerts":[]}`, http.StatusUnprocessableEntity},
+		{SourceKeep, `{"status":"firing"}`, http.StatusUnprocessableEntity},
+		{SourcePagerDuty, `{"event_action":"resolve"}`, http.StatusBadRequest},
+		{SourceEmail, `garbage`, http.StatusBadRequest},
+		{"nope", `{}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		_, err := parsePayload(tt.source, []byte(tt.body), nil, nil)
+		var pe *payloadError
+		if !errors.As(err, &pe) || pe.status != tt.status {
+			t.Errorf("parsePayload(%s, %s) = %v, want status %d", tt.source, tt.body, err, tt.status)
+		}
+	}
+}
+
+func TestParsePayload_Mapped(t *testing.T) {
+	m := &PayloadMapping{AlertsPath: "$.items", Title: "$.name"}
+	alerts, err := parsePayload(SourceGeneric, []byte(`{"items":[{"name":"a"},{"name":"b"}]}`), nil, m)
+	if err != nil || len(alerts) != 2 {
+		t.Fatalf("parsePayload = %d alerts, %v; want 2", len(alerts), err)
+	}
+	if alerts[1].Title != "b" {
+		t.Errorf("title = %q, want b", alerts[1].Title)
+	}
+}
+
+func TestParsePagerDuty_Acknowledge(t *testing.T) {
+	alerts, err := parsePagerDuty([]byte(`{"event_action":"acknowledge","dedup_key":"k"}`))
+	if err != nil || len(alerts) != 1 {
+		t.Fatalf("parsePagerDuty = %v, %v", alerts, err)
+	}
+	if alerts[0].Status != statusAcknowledged || alerts[0].Fingerprint != "k" {
+		t.Errorf("alert = %+v, want an acknowledgement of k", alerts[0])
+	}
+}
+
+func TestIngestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{4, 40 * time.Second},
+		{8, 10 * time.Minute},
+		{30, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := ingestRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("ingestRetryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestIngestOrigin_AuditEntry(t *testing.T) {
+	keyID := uuid.New()
+	ip := netip.MustParseAddr("192.0.2.1")
+	o := IngestOrigin{APIKeyID: &keyID, IPAddress: &ip}
+
+	raw, _ := json.Marshal(o)
+	var decoded IngestOrigin
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	e := decoded.auditEntry("tenant_acme")
+	if e.TenantSchema != "tenant_acme" || e.UserID.Valid || !e.APIKeyID.Valid || uuid.UUID(e.APIKeyID.Bytes) != keyID {
+		t.Errorf("entry = %+v", e)
+	}
+	if e.IPAddress == nil || *e.IPAddress != ip {
+		t.Errorf("ip = %v, want %v", e.IPAddress, ip)
+	}
+}
+
+func TestQueueHandler_RejectsBadRequests(t *testing.T) {
+	h := NewQueueHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	admin := &auth.Identity{Subject: "admin", Role: auth.RoleAdmin}
+	tests := []struct {
+		method, path string
+	}{
+		{http.MethodGet, "/?status=done"},
+		{http.MethodGet, "/not-a-uuid"},
+		{http.MethodPost, "/not-a-uuid/replay"},
+		{http.MethodDelete, "/not-a-uuid"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		r = r.WithContext(auth.NewContext(r.Context(), admin))
+		rec := httptest.NewRecorder()
+		h.Routes().ServeHTTP(rec, r)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: status = %d, want 400", tt.method, tt.path, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,521 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/wisbric/core/pkg/httpserver"
+)
+
+// --- Datadog payload types ---
+
+// datadogPayload is the body of a Datadog webhook using the template in
+// docs/04-integrations-workflow.md. Datadog payloads are user-defined, so
+// every field is optional.
+type datadogPayload struct {
+	ID              string          `json:"id"`
+	AlertID         string          `json:"alert_id"`
+	Title           string          `json:"title"`
+	Body            string          `json:"body"`
+	AlertTransition string          `json:"alert_transition"`
+	AlertStatus     string          `json:"alert_status"`
+	AlertPriority   string          `json:"alert_priority"`
+	AlertType       string          `json:"alert_type"`
+	AlertMetric     string          `json:"alert_metric"`
+	AlertQuery      string          `json:"alert_query"`
+	AlertScope      string          `json:"alert_scope"`
+	Hostname        string          `json:"hostname"`
+	Tags            json.RawMessage `json:"tags"`
+	Link            string          `json:"link"`
+	Snapshot        string          `json:"snapshot"`
+	Org             struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"org"`
+}
+
+// --- CloudWatch (SNS) payload types ---
+
+// snsMessage is an Amazon SNS HTTP(S) delivery.
+type snsMessage struct {
+	Type         string `json:"Type"`
+	MessageID    string `json:"MessageId"`
+	TopicArn     string `json:"TopicArn"`
+	Subject      string `json:"Subject"`
+	Message      string `json:"Message"`
+	Timestamp    string `json:"Timestamp"`
+	SubscribeURL string `json:"SubscribeURL"`
+}
+
+// cloudWatchAlarm is the JSON message CloudWatch publishes on a state change.
+type cloudWatchAlarm struct {
+	AlarmName        string `json:"AlarmName"`
+	AlarmDescription string `json:"AlarmDescription"`
+	AWSAccountID     string `json:"AWSAccountId"`
+	NewStateValue    string `json:"NewStateValue"`
+	NewStateReason   string `json:"NewStateReason"`
+	OldStateValue    string `json:"OldStateValue"`
+	StateChangeTime  string `json:"StateChangeTime"`
+	Region           string `json:"Region"`
+	AlarmArn         string `json:"AlarmArn"`
+	Trigger          struct {
+		MetricName         string  `json:"MetricName"`
+		Namespace          string  `json:"Namespace"`
+		Statistic          string  `json:"Statistic"`
+		ComparisonOperator string  `json:"ComparisonOperator"`
+		Threshold          float64 `json:"Threshold"`
+		Dimensions         []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"Dimensions"`
+	} `json:"Trigger"`
+}
+
+// --- Opsgenie payload types ---
+
+// opsgeniePayload is an Opsgenie Alert API create-alert request.
+type opsgeniePayload struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description"`
+	Tags        []string          `json:"tags"`
+	Details     map[string]string `json:"details"`
+	Entity      string            `json:"entity"`
+	Source      string            `json:"source"`
+	Priority    string            `json:"priority"`
+	Note        string            `json:"note"`
+}
+
+// --- PagerDuty Events API v2 payload types ---
+
+type eventsV2Payload struct {
+	RoutingKey  string          `json:"routing_key"`
+	EventAction string          `json:"event_action"`
+	DedupKey    string          `json:"dedup_key"`
+	Payload     eventsV2Details `json:"payload"`
+	Client      string          `json:"client"`
+	ClientURL   string          `json:"client_url"`
+	Links       json.RawMessage `json:"links"`
+	Images      json.RawMessage `json:"images"`
+}
+
+type eventsV2Details struct {
+	Summary       string          `json:"summary"`
+	Source        string          `json:"source"`
+	Severity      string          `json:"severity"`
+	Timestamp     string          `json:"timestamp"`
+	Component     string          `json:"component"`
+	Group         string          `json:"group"`
+	Class         string          `json:"class"`
+	CustomDetails json.RawMessage `json:"custom_details"`
+}
+
+// eventsV2Response mirrors the PagerDuty Events API v2 response so existing
+// senders can switch endpoints without changes.
+type eventsV2Response struct {
+	Status   string `json:"status"`
+	Message  string `json:"message"`
+	DedupKey string `json:"dedup_key"`
+}
+
+// --- Parsers ---
+
+// parseDatadog parses a Datadog monitor webhook. Recovered transitions
+// resolve the open alert for the same monitor and scope.
+func parseDatadog(body []byte) ([]NormalizedAlert, error) {
+	var payload datadogPayload
+	if err := decodePayload(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Title == "" && payload.AlertID == "" {
+		return nil, invalidPayload("title or alert_id is required")
+	}
+	return []NormalizedAlert{normalizeDatadog(payload)}, nil
+}
+
+// handleSNSControl answers SNS deliveries other than notifications and
+// reports whether it did. Subscription confirmations are confirmed by
+// fetching the SubscribeURL.
+func (h *WebhookHandler) handleSNSControl(w http.ResponseWriter, r *http.Request, body []byte) bool {
+	var msg snsMessage
+	if err := decodePayload(body, &msg); err != nil {
+		respondPayloadError(w, err)
+		return true
+	}
+	msgType := r.Header.Get("x-amz-sns-message-type")
+	if msgType == "" {
+		msgType = msg.Type
+	}
+
+	switch msgType {
+	case "Notification":
+		return false
+	case "SubscriptionConfirmation":
+		if err := h.confirmSNSSubscription(r, msg.SubscribeURL); err != nil {
+			h.logger.Warn("confirming SNS subscription", "error", err, "topic", msg.TopicArn)
+			httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
+			return true
+		}
+		h.logger.Info("confirmed SNS subscription", "topic", msg.TopicArn)
+		httpserver.Respond(w, http.StatusOK, map[string]string{"status": "subscription_confirmed", "topic_arn": msg.TopicArn})
+	case "UnsubscribeConfirmation":
+		h.logger.Info("SNS subscription removed", "topic", msg.TopicArn)
+		httpserver.Respond(w, http.StatusOK, map[string]string{"status": "unsubscribed", "topic_arn": msg.TopicArn})
+	default:
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "unsupported SNS message type "+msgType)
+	}
+	return true
+}
+
+// parseCloudWatch parses a CloudWatch alarm notification delivered by SNS.
+// severity comes from the optional ?severity= query parameter, since
+// CloudWatch alarms carry none.
+func parseCloudWatch(body []byte, severity string) ([]NormalizedAlert, error) {
+	var msg snsMessage
+	if err := decodePayload(body, &msg); err != nil {
+		return nil, err
+	}
+	var alarm cloudWatchAlarm
+	if err := json.Unmarshal([]byte(msg.Message), &alarm); err != nil || alarm.AlarmName == "" {
+		return nil, invalidPayload("SNS message is not a CloudWatch alarm")
+	}
+	// INSUFFICIENT_DATA says nothing about the monitored system; skip it.
+	if alarm.NewStateValue == "INSUFFICIENT_DATA" {
+		return nil, nil
+	}
+	return []NormalizedAlert{normalizeCloudWatch(alarm, msg.TopicArn, severity)}, nil
+}
+
+// parseOpsgenie parses an Opsgenie Alert API create-alert payload. The
+// alias is the dedup key.
+func parseOpsgenie(body []byte) ([]NormalizedAlert, error) {
+	var payload opsgeniePayload
+	if err := decodePayload(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Message == "" {
+		return nil, invalidPayload("message is required")
+	}
+	return []NormalizedAlert{normalizeOpsgenie(payload)}, nil
+}
+
+// parsePagerDuty parses a PagerDuty Events API v2 event. trigger creates or
+// deduplicates the alert keyed by dedup_key; acknowledge and resolve act on
+// the open alert with that key.
+func parsePagerDuty(body []byte) ([]NormalizedAlert, error) {
+	var payload eventsV2Payload
+	if err := decodePayload(body, &payload); err != nil {
+		return nil, err
+	}
+	switch payload.EventAction {
+	case "trigger":
+		if payload.Payload.Summary == "" {
+			return nil, invalidPayload("payload.summary is required")
+		}
+	case "acknowledge", "resolve":
+		if payload.DedupKey == "" {
+			return nil, badPayload("dedup_key is required for %s", payload.EventAction)
+		}
+	default:
+		return nil, badPayload("event_action must be trigger, acknowledge or resolve")
+	}
+
+	normalized := normalizePagerDuty(payload)
+	if payload.EventAction == "acknowledge" {
+		normalized.Status = statusAcknowledged
+	}
+	return []NormalizedAlert{normalized}, nil
+}
+
+// snsHostPattern matches the SNS endpoints that may appear in a
+// SubscribeURL. Anything else is refused to avoid fetching arbitrary URLs.
+var snsHostPattern = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// validSNSURL reports whether raw is an HTTPS URL on an SNS endpoint.
+func validSNSURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && u.Scheme == "https" && snsHostPattern.MatchString(u.Hostname()) && u.Port() == ""
+}
+
+// confirmSNSSubscription confirms an SNS subscription by fetching its
+// SubscribeURL.
+func (h *WebhookHandler) confirmSNSSubscription(r *http.Request, subscribeURL string) error {
+	if !validSNSURL(subscribeURL) {
+		return fmt.Errorf("SubscribeURL is not an SNS endpoint")
+	}
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, subscribeURL, nil)
+	if err != nil {
+		return fmt.Errorf("building confirmation request: %w", err)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("confirming subscription: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("confirming subscription: SNS returned %s", resp.Status)
+	}
+	return nil
+}
+
+// --- Normalization functions ---
+
+// datadogTitlePrefix matches the "[Triggered on {host:web-01}] " prefix that
+// Datadog puts in front of event titles.
+var datadogTitlePrefix = regexp.MustCompile(`^\[[^\]]*\]\s*`)
+
+// normalizeDatadog converts a Datadog monitor webhook to the internal format.
+func normalizeDatadog(p datadogPayload) NormalizedAlert {
+	title := strings.TrimSpace(datadogTitlePrefix.ReplaceAllString(p.Title, ""))
+	if title == "" {
+		title = "Datadog monitor " + p.AlertID
+	}
+
+	tags := parseTags(stringList(p.Tags))
+	for k, v := range parseTags(strings.Split(p.AlertScope, ",")) {
+		tags[k] = v
+	}
+	if p.Hostname != "" {
+		tags["host"] = p.Hostname
+	}
+	labels, _ := json.Marshal(tags)
+
+	annotations, _ := json.Marshal(nonEmpty(map[string]string{
+		"monitor_id": p.AlertID,
+		"event_id":   p.ID,
+		"transition": p.AlertTransition,
+		"metric":     p.AlertMetric,
+		"query":      p.AlertQuery,
+		"scope":      p.AlertScope,
+		"link":       p.Link,
+		"snapshot":   p.Snapshot,
+		"org":        p.Org.Name,
+	}))
+
+	// A monitor alerts once per scope (group), so both form the identity.
+	fp := generateFingerprint(title, labels)
+	if p.AlertID != "" {
+		fp = generateFingerprint("datadog:"+p.AlertID, []byte(p.AlertScope))
+	}
+
+	status := "firing"
+	if strings.EqualFold(p.AlertTransition, "Recovered") || strings.EqualFold(p.AlertType, "success") {
+		status = "resolved"
+	}
+
+	severity := p.AlertPriority
+	if severity == "" {
+		severity = p.AlertType
+	}
+
+	var desc *string
+	if body := strings.TrimSpace(p.Body); body != "" {
+		desc = &body
+	}
+
+	return NormalizedAlert{
+		Fingerprint: fp,
+		Status:      status,
+		Severity:    normalizeSeverity(severity),
+		Source:      "datadog",
+		Title:       title,
+		Description: desc,
+		Labels:      labels,
+		Annotations: annotations,
+	}
+}
+
+// normalizeCloudWatch converts a CloudWatch alarm state change to the
+// internal format. The alarm ARN is the dedup key.
+func normalizeCloudWatch(a cloudWatchAlarm, topicArn, severity string) NormalizedAlert {
+	region := a.Region
+	// arn:aws:cloudwatch:<region>:<account>:alarm:<name>
+	if parts := strings.SplitN(a.AlarmArn, ":", 6); len(parts) == 6 && parts[3] != "" {
+		region = parts[3]
+	}
+
+	tags := nonEmpty(map[string]string{
+		"alarm_name":     a.AlarmName,
+		"aws_account_id": a.AWSAccountID,
+		"region":         region,
+		"namespace":      a.Trigger.Namespace,
+		"metric_name":    a.Trigger.MetricName,
+	})
+	for _, d := range a.Trigger.Dimensions {
+		if d.Name != "" {
+			tags[d.Name] = d.Value
+		}
+	}
+	labels, _ := json.Marshal(tags)
+
+	ann := nonEmpty(map[string]string{
+		"alarm_arn":           a.AlarmArn,
+		"topic_arn":           topicArn,
+		"state_reason":        a.NewStateReason,
+		"old_state":           a.OldStateValue,
+		"state_change_time":   a.StateChangeTime,
+		"statistic":           a.Trigger.Statistic,
+		"comparison_operator": a.Trigger.ComparisonOperator,
+	})
+	if a.Trigger.ComparisonOperator != "" {
+		ann["threshold"] = fmt.Sprint(a.Trigger.Threshold)
+	}
+	annotations, _ := json.Marshal(ann)
+
+	fp := generateFingerprint("cloudwatch:"+a.AlarmArn, nil)
+	if a.AlarmArn == "" {
+		fp = generateFingerprint(a.AlarmName, labels)
+	}
+
+	status := "firing"
+	if a.NewStateValue == "OK" {
+		status = "resolved"
+	}
+
+	var desc *string
+	if a.AlarmDescription != "" {
+		desc = &a.AlarmDescription
+	} else if a.NewStateReason != "" {
+		desc = &a.NewStateReason
+	}
+
+	return NormalizedAlert{
+		Fingerprint: fp,
+		Status:      status,
+		Severity:    normalizeSeverity(severity),
+		Source:      "cloudwatch",
+		Title:       a.AlarmName,
+		Description: desc,
+		Labels:      labels,
+		Annotations: annotations,
+	}
+}
+
+// normalizeOpsgenie converts an Opsgenie create-alert payload to the
+// internal format.
+func normalizeOpsgenie(p opsgeniePayload) NormalizedAlert {
+	tags := parseTags(p.Tags)
+	if p.Entity != "" {
+		tags["entity"] = p.Entity
+	}
+	labels, _ := json.Marshal(tags)
+
+	ann := map[string]any{}
+	if len(p.Details) > 0 {
+		ann["details"] = p.Details
+	}
+	for k, v := range nonEmpty(map[string]string{"alias": p.Alias, "origin": p.Source, "note": p.Note}) {
+		ann[k] = v
+	}
+	annotations, _ := json.Marshal(ann)
+
+	fp := p.Alias
+	if fp == "" {
+		fp = generateFingerprint(p.Message, labels)
+	}
+
+	var desc *string
+	if p.Description != "" {
+		desc = &p.Description
+	}
+
+	return NormalizedAlert{
+		Fingerprint: fp,
+		Status:      "firing",
+		Severity:    normalizeSeverity(p.Priority),
+		Source:      "opsgenie",
+		Title:       p.Message,
+		Description: desc,
+		Labels:      labels,
+		Annotations: annotations,
+	}
+}
+
+// normalizePagerDuty converts a PagerDuty Events API v2 event to the
+// internal format. The dedup_key is the fingerprint; a trigger without one
+// gets a key derived from its content, as PagerDuty does.
+func normalizePagerDuty(p eventsV2Payload) NormalizedAlert {
+	d := p.Payload
+	labels, _ := json.Marshal(nonEmpty(map[string]string{
+		"source":    d.Source,
+		"component": d.Component,
+		"group":     d.Group,
+		"class":     d.Class,
+	}))
+
+	ann := map[string]any{}
+	for k, v := range map[string]json.RawMessage{"custom_details": d.CustomDetails, "links": p.Links, "images": p.Images} {
+		if len(v) > 0 && string(v) != "null" {
+			ann[k] = v
+		}
+	}
+	for k, v := range nonEmpty(map[string]string{"client": p.Client, "client_url": p.ClientURL, "timestamp": d.Timestamp}) {
+		ann[k] = v
+	}
+	annotations, _ := json.Marshal(ann)
+
+	fp := p.DedupKey
+	if fp == "" {
+		fp = generateFingerprint(d.Summary, labels)
+	}
+
+	status := "firing"
+	if p.EventAction == "resolve" {
+		status = "resolved"
+	}
+
+	return NormalizedAlert{
+		Fingerprint: fp,
+		Status:      status,
+		Severity:    normalizeSeverity(d.Severity),
+		Source:      "pagerduty",
+		Title:       d.Summary,
+		Labels:      labels,
+		Annotations: annotations,
+	}
+}
+
+// parseTags turns "key:value" tags into labels. Tags without a value map to
+// "true".
+func parseTags(tags []string) map[string]string {
+	out := make(map[string]string, len(tags))
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if k, v, ok := strings.Cut(t, ":"); ok && k != "" {
+			out[k] = v
+		} else {
+			out[t] = "true"
+		}
+	}
+	return out
+}
+
+// stringList decodes a JSON array of strings or a comma-separated string.
+func stringList(raw json.RawMessage) []string {
+	var list []string
+	if json.Unmarshal(raw, &list) == nil {
+		return list
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil && s != "" {
+		return strings.Split(s, ",")
+	}
+	return nil
+}
+
+// nonEmpty returns m without its empty values.
+func nonEmpty(m map[string]string) map[string]string {
+	for k, v := range m {
+		if v == "" {
+			delete(m, k)
+		}
+	}
+	return m
+}
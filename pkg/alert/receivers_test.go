@@ -0,0 +1,362 @@
+package alert
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeDatadog(t *testing.T) {
+	p := datadogPayload{
+		ID:              "7231234",
+		AlertID:         "1234567",
+		Title:           "[Triggered on {host:web-01}] High CPU on web-01",
+		Body:            "CPU above 90% for 5 minutes",
+		AlertTransition: "Triggered",
+		AlertPriority:   "P1",
+		AlertType:       "error",
+		AlertScope:      "host:web-01,env:prod",
+		Tags:            json.RawMessage(`"service:api,team:platform,monitored"`),
+		Link:            "https://app.datadoghq.com/event/event?id=7231234",
+	}
+
+	n := normalizeDatadog(p)
+
+	if n.Title != "High CPU on web-01" {
+		t.Errorf("Title = %q, want prefix stripped", n.Title)
+	}
+	if n.Severity != "critical" {
+		t.Errorf("Severity = %q, want critical from P1", n.Severity)
+	}
+	if n.Status != "firing" {
+		t.Errorf("Status = %q, want firing", n.Status)
+	}
+	if n.Source != "datadog" {
+		t.Errorf("Source = %q, want datadog", n.Source)
+	}
+	if n.Description == nil || *n.Description != "CPU above 90% for 5 minutes" {
+		t.Errorf("Description = %v, want body", n.Description)
+	}
+
+	var labels map[string]string
+	if err := json.Unmarshal(n.Labels, &labels); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]string{"service": "api", "team": "platform", "monitored": "true", "host": "web-01", "env": "prod"} {
+		if labels[k] != v {
+			t.Errorf("labels[%q] = %q, want %q", k, labels[k], v)
+		}
+	}
+
+	// The recovery for the same monitor and scope must share the fingerprint.
+	p.AlertTransition = "Recovered"
+	p.Title = "[Recovered on {host:web-01}] High CPU on web-01"
+	recovered := normalizeDatadog(p)
+	if recovered.Status != "resolved" {
+		t.Errorf("recovered Status = %q, want resolved", recovered.Status)
+	}
+	if recovered.Fingerprint != n.Fingerprint {
+		t.Error("recovery fingerprint differs from trigger fingerprint")
+	}
+
+	p.AlertScope = "host:web-02,env:prod"
+	if normalizeDatadog(p).Fingerprint == n.Fingerprint {
+		t.Error("different scopes of one monitor should not share a fingerprint")
+	}
+}
+
+func TestNormalizeDatadog_TagsArrayAndFallbackSeverity(t *testing.T) {
+	n := normalizeDatadog(datadogPayload{
+		AlertID:   "42",
+		AlertType: "warning",
+		Tags:      json.RawMessage(`["env:staging"]`),
+	})
+
+	if n.Title != "Datadog monitor 42" {
+		t.Errorf("Title = %q, want fallback title", n.Title)
+	}
+	if n.Severity != "warning" {
+		t.Errorf("Severity = %q, want warning from alert_type", n.Severity)
+	}
+	var labels map[string]string
+	_ = json.Unmarshal(n.Labels, &labels)
+	if labels["env"] != "staging" {
+		t.Errorf("labels = %v, want env=staging", labels)
+	}
+}
+
+const cloudWatchAlarmJSON = `{
+	"AlarmName": "api-high-5xx",
+	"AlarmDescription": "5xx rate above 5%",
+	"AWSAccountId": "123456789012",
+	"NewStateValue": "ALARM",
+	"NewStateReason": "Threshold Crossed: 1 datapoint [7.2] was greater than the threshold (5.0).",
+	"StateChangeTime": "2026-02-20T10:00:00.000+0000",
+	"Region": "EU (Ireland)",
+	"AlarmArn": "arn:aws:cloudwatch:eu-west-1:123456789012:alarm:api-high-5xx",
+	"OldStateValue": "OK",
+	"Trigger": {
+		"MetricName": "HTTPCode_Target_5XX_Count",
+		"Namespace": "AWS/ApplicationELB",
+		"Statistic": "SUM",
+		"ComparisonOperator": "GreaterThanThreshold",
+		"Threshold": 5.0,
+		"Dimensions": [{"value": "app/api/abc", "name": "LoadBalancer"}]
+	}
+}`
+
+func TestNormalizeCloudWatch(t *testing.T) {
+	var alarm cloudWatchAlarm
+	if err := json.Unmarshal([]byte(cloudWatchAlarmJSON), &alarm); err != nil {
+		t.Fatal(err)
+	}
+
+	n := normalizeCloudWatch(alarm, "arn:aws:sns:eu-west-1:123456789012:nightowl", "critical")
+
+	if n.Title != "api-high-5xx" {
+		t.Errorf("Title = %q, want alarm name", n.Title)
+	}
+	if n.Status != "firing" {
+		t.Errorf("Status = %q, want firing", n.Status)
+	}
+	if n.Severity != "critical" {
+		t.Errorf("Severity = %q, want critical", n.Severity)
+	}
+	if n.Description == nil || *n.Description != "5xx rate above 5%" {
+		t.Errorf("Description = %v, want alarm description", n.Description)
+	}
+
+	var labels map[string]string
+	if err := json.Unmarshal(n.Labels, &labels); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]string{
+		"region":         "eu-west-1",
+		"aws_account_id": "123456789012",
+		"namespace":      "AWS/ApplicationELB",
+		"LoadBalancer":   "app/api/abc",
+	} {
+		if labels[k] != v {
+			t.Errorf("labels[%q] = %q, want %q", k, labels[k], v)
+		}
+	}
+
+	alarm.NewStateValue = "OK"
+	ok := normalizeCloudWatch(alarm, "", "")
+	if ok.Status != "resolved" {
+		t.Errorf("OK Status = %q, want resolved", ok.Status)
+	}
+	if ok.Fingerprint != n.Fingerprint {
+		t.Error("OK fingerprint differs from ALARM fingerprint")
+	}
+	if ok.Severity != "warning" {
+		t.Errorf("Severity = %q, want warning default", ok.Severity)
+	}
+}
+
+func TestNormalizeOpsgenie(t *testing.T) {
+	n := normalizeOpsgenie(opsgeniePayload{
+		Message:     "Disk almost full on db-01",
+		Alias:       "disk-db-01",
+		Description: "95% used on /var/lib/postgresql",
+		Tags:        []string{"env:prod", "database"},
+		Details:     map[string]string{"mount": "/var/lib/postgresql"},
+		Entity:      "db-01",
+		Priority:    "P2",
+	})
+
+	if n.Fingerprint != "disk-db-01" {
+		t.Errorf("Fingerprint = %q, want alias", n.Fingerprint)
+	}
+	if n.Severity != "major" {
+		t.Errorf("Severity = %q, want major from P2", n.Severity)
+	}
+	if n.Title != "Disk almost full on db-01" {
+		t.Errorf("Title = %q", n.Title)
+	}
+	var labels map[string]string
+	_ = json.Unmarshal(n.Labels, &labels)
+	if labels["env"] != "prod" || labels["database"] != "true" || labels["entity"] != "db-01" {
+		t.Errorf("labels = %v", labels)
+	}
+	var ann map[string]any
+	_ = json.Unmarshal(n.Annotations, &ann)
+	if details, _ := ann["details"].(map[string]any); details["mount"] != "/var/lib/postgresql" {
+		t.Errorf("annotations = %v, want details", ann)
+	}
+}
+
+func TestNormalizePagerDuty(t *testing.T) {
+	var p eventsV2Payload
+	body := `{
+		"routing_key": "R0UT1NGKEY",
+		"event_action": "trigger",
+		"dedup_key": "srv01/HTTP",
+		"payload": {
+			"summary": "Example alert on host1.example.com",
+			"source": "host1.example.com",
+			"severity": "error",
+			"component": "mysql",
+			"custom_details": {"free space": "1%"}
+		},
+		"links": [{"href": "https://example.com/", "text": "Link text"}]
+	}`
+	if err := json.Unmarshal([]byte(body), &p); err != nil {
+		t.Fatal(err)
+	}
+
+	n := normalizePagerDuty(p)
+
+	if n.Fingerprint != "srv01/HTTP" {
+		t.Errorf("Fingerprint = %q, want dedup_key", n.Fingerprint)
+	}
+	if n.Severity != "major" {
+		t.Errorf("Severity = %q, want major from error", n.Severity)
+	}
+	if n.Status != "firing" {
+		t.Errorf("Status = %q, want firing", n.Status)
+	}
+	if n.Title != "Example alert on host1.example.com" {
+		t.Errorf("Title = %q", n.Title)
+	}
+	var ann map[string]any
+	_ = json.Unmarshal(n.Annotations, &ann)
+	if _, ok := ann["custom_details"]; !ok {
+		t.Errorf("annotations = %v, want custom_details", ann)
+	}
+	if _, ok := ann["images"]; ok {
+		t.Error("absent images should not be stored")
+	}
+
+	p.EventAction = "resolve"
+	if got := normalizePagerDuty(p).Status; got != "resolved" {
+		t.Errorf("resolve Status = %q, want resolved", got)
+	}
+
+	p.DedupKey = ""
+	if normalizePagerDuty(p).Fingerprint == "" {
+		t.Error("trigger without dedup_key should get a generated key")
+	}
+}
+
+func TestValidSNSURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://sns.eu-west-1.amazonaws.com/?Action=ConfirmSubscription&Token=abc", true},
+		{"https://sns.cn-north-1.amazonaws.com.cn/?Action=ConfirmSubscription", true},
+		{"http://sns.eu-west-1.amazonaws.com/?Action=ConfirmSubscription", false},
+		{"https://sns.eu-west-1.amazonaws.com.evil.example/", false},
+		{"https://evil.example/?sns.eu-west-1.amazonaws.com", false},
+		{"https://sns.eu-west-1.amazonaws.com:8443/", false},
+		{"https://169.254.169.254/latest/meta-data", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := validSNSURL(tt.url); got != tt.want {
+			t.Errorf("validSNSURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	got := parseTags([]string{" env:prod ", "critical", "", "url:https://x", ":bad"})
+	want := map[string]string{"env": "prod", "critical": "true", "url": "https://x", ":bad": "true"}
+	if len(got) != len(want) {
+		t.Fatalf("parseTags = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("parseTags[%q] = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func postWebhook(t *testing.T, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	h, router := newTestRouter()
+	h.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestDatadogWebhook_MissingTitle(t *testing.T) {
+	w := postWebhook(t, "/webhooks/datadog", `{"body":"x"}`, nil)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestCloudWatchWebhook_RejectsForeignSubscribeURL(t *testing.T) {
+	body := `{"Type":"SubscriptionConfirmation","TopicArn":"arn:aws:sns:eu-west-1:1:t","SubscribeURL":"https://evil.example/confirm"}`
+	w := postWebhook(t, "/webhooks/cloudwatch", body, map[string]string{"x-amz-sns-message-type": "SubscriptionConfirmation"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCloudWatchWebhook_NotAnAlarm(t *testing.T) {
+	body := `{"Type":"Notification","Message":"hello"}`
+	w := postWebhook(t, "/webhooks/cloudwatch", body, nil)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestCloudWatchWebhook_SkipsInsufficientData(t *testing.T) {
+	msg, _ := json.Marshal(strings.Replace(cloudWatchAlarmJSON, `"ALARM"`, `"INSUFFICIENT_DATA"`, 1))
+	w := postWebhook(t, "/webhooks/cloudwatch", `{"Type":"Notification","Message":`+string(msg)+`}`, nil)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusAccepted)
+	}
+	var resp AcceptedResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Status != "ignored" || resp.ID != nil || resp.AlertsReceived != 0 {
+		t.Errorf("response = %+v, want an ignored payload", resp)
+	}
+}
+
+func TestCloudWatchWebhook_UnsubscribeConfirmation(t *testing.T) {
+	w := postWebhook(t, "/webhooks/cloudwatch", `{"Type":"UnsubscribeConfirmation"}`, nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestOpsgenieWebhook_MissingMessage(t *testing.T) {
+	w := postWebhook(t, "/webhooks/opsgenie", `{"alias":"x"}`, nil)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestPagerDutyWebhook_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"unknown action", `{"event_action":"escalate","dedup_key":"k"}`, http.StatusBadRequest},
+		{"acknowledge without key", `{"event_action":"acknowledge"}`, http.StatusBadRequest},
+		{"resolve without key", `{"event_action":"resolve"}`, http.StatusBadRequest},
+		{"trigger without summary", `{"event_action":"trigger","payload":{"source":"h","severity":"info"}}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postWebhook(t, "/webhooks/pagerduty", tt.body, nil)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -19,6 +19,14 @@ func pgtypeUUIDToPtr(p pgtype.UUID) *uuid.UUID {
 	return &id
 }
 
+// ptrToPgtypeUUID converts a *uuid.UUID to a pgtype.UUID, invalid when nil.
+func ptrToPgtypeUUID(id *uuid.UUID) pgtype.UUID {
+	if id == nil {
+		return pgtype.UUID{}
+	}
+	return pgtype.UUID{Bytes: *id, Valid: true}
+}
+
 // Store provides database operations for alerts.
 type Store struct {
 	q *db.Queries
@@ -40,8 +48,9 @@ func (s *Store) Create(ctx context.Context, a NormalizedAlert) (Response, error)
 		Description:        a.Description,
 		Labels:             ensureJSON(a.Labels),
 		Annotations:        ensureJSON(a.Annotations),
-		ServiceID:          pgtype.UUID{},
-		EscalationPolicyID: pgtype.UUID{},
+		ServiceID:          ptrToPgtypeUUID(a.ServiceID),
+		EscalationPolicyID: ptrToPgtypeUUID(a.EscalationPolicyID),
+		IntegrationID:      ptrToPgtypeUUID(a.IntegrationID),
 	})
 	if err != nil {
 		return Response{}, fmt.Errorf("creating alert: %w", err)
@@ -65,6 +74,7 @@ func AlertRowToResponse(row db.Alert) Response {
 		MatchedIncidentID: pgtypeUUIDToPtr(row.MatchedIncidentID),
 		SuggestedSolution: row.SuggestedSolution,
 		AlertGroupID:      pgtypeUUIDToPtr(row.AlertGroupID),
+		IntegrationID:     pgtypeUUIDToPtr(row.IntegrationID),
 		OccurrenceCount:   row.OccurrenceCount,
 		FirstFiredAt:      row.FirstFiredAt,
 		LastFiredAt:       row.LastFiredAt,
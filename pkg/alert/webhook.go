@@ -3,14 +3,19 @@ package alert
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"slices"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/wisbric/core/pkg/httpserver"
@@ -38,6 +43,35 @@ type alertmanagerAlert struct {
 	Fingerprint string            `json:"fingerprint"`
 }
 
+// --- Grafana payload types ---
+
+// grafanaPayload is a Grafana unified-alerting webhook notification. Its
+// envelope mirrors Alertmanager's; only the alerts are used.
+type grafanaPayload struct {
+	Receiver string         `json:"receiver"`
+	Status   string         `json:"status"`
+	OrgID    int64          `json:"orgId"`
+	GroupKey string         `json:"groupKey"`
+	Title    string         `json:"title"`
+	Alerts   []grafanaAlert `json:"alerts"`
+}
+
+type grafanaAlert struct {
+	Status       string             `json:"status"`
+	Labels       map[string]string  `json:"labels"`
+	Annotations  map[string]string  `json:"annotations"`
+	StartsAt     time.Time          `json:"startsAt"`
+	EndsAt       time.Time          `json:"endsAt"`
+	Fingerprint  string             `json:"fingerprint"`
+	GeneratorURL string             `json:"generatorURL"`
+	SilenceURL   string             `json:"silenceURL"`
+	DashboardURL string             `json:"dashboardURL"`
+	PanelURL     string             `json:"panelURL"`
+	ImageURL     string             `json:"imageURL"`
+	Values       map[string]float64 `json:"values"`
+	ValueString  string             `json:"valueString"`
+}
+
 // --- Keep payload types ---
 
 type keepPayload struct {
@@ -77,18 +111,27 @@ type WebhookMetrics struct {
 	ProcessingDuration *prometheus.HistogramVec
 	KBHitsTotal        prometheus.Counter
 	AgentResolvedTotal prometheus.Counter
+	// Ingest queue metrics, by source.
+	QueuedTotal       *prometheus.CounterVec
+	RetriesTotal      *prometheus.CounterVec
+	DeadLetteredTotal *prometheus.CounterVec
+	QueueLatency      *prometheus.HistogramVec
 }
 
 // AlertGrouper evaluates an alert against grouping rules.
 type AlertGrouper interface {
 	Evaluate(ctx context.Context, dbtx db.DBTX, alertID uuid.UUID, severity string, labels json.RawMessage) AlertGroupResult
+	// Match returns the rule an alert with the given labels would be
+	// grouped by, without grouping anything. GroupID is not set.
+	Match(ctx context.Context, dbtx db.DBTX, labels json.RawMessage) AlertGroupResult
 }
 
 // AlertGroupResult is the result of grouping evaluation.
 type AlertGroupResult struct {
-	Matched bool
-	GroupID uuid.UUID
-	RuleID  uuid.UUID
+	Matched  bool
+	GroupID  uuid.UUID
+	RuleID   uuid.UUID
+	RuleName string
 }
 
 // WebhookHandler provides HTTP handlers for alert webhook endpoints.
@@ -100,6 +143,12 @@ type WebhookHandler struct {
 	metrics *WebhookMetrics
 	cfgSvc  BookOwlConfigResolver
 	grouper AlertGrouper
+	client  *http.Client // outbound calls, e.g. SNS subscription confirmation
+	enqueue func(context.Context, *QueuedPayload) error
+
+	// OnQueued, if set, is called with the tenant slug after a payload is
+	// queued, e.g. to wake a Processor.
+	OnQueued func(tenantSlug string)
 }
 
 // BookOwlConfigResolver resolves BookOwl API credentials for a tenant.
@@ -109,18 +158,38 @@ type BookOwlConfigResolver interface {
 
 // NewWebhookHandler creates a WebhookHandler.
 func NewWebhookHandler(logger *slog.Logger, audit *audit.Writer, dedup *Deduplicator, enrich *Enricher, metrics *WebhookMetrics, cfgSvc BookOwlConfigResolver, grouper AlertGrouper) *WebhookHandler {
-	return &WebhookHandler{logger: logger, audit: audit, dedup: dedup, enrich: enrich, metrics: metrics, cfgSvc: cfgSvc, grouper: grouper}
+	return &WebhookHandler{
+		logger: logger, audit: audit, dedup: dedup, enrich: enrich, metrics: metrics, cfgSvc: cfgSvc, grouper: grouper,
+		client: &http.Client{Timeout: 10 * time.Second}, enqueue: enqueuePayload,
+	}
 }
 
 // Routes returns a chi.Router with webhook routes mounted.
 func (h *WebhookHandler) Routes() chi.Router {
 	r := chi.NewRouter()
-	r.Post("/alertmanager", h.handleAlertmanager)
-	r.Post("/keep", h.handleKeep)
-	r.Post("/generic", h.handleGeneric)
+	for _, source := range IntegrationSources {
+		r.Post("/"+source, h.receiver(source))
+	}
+	return r
+}
+
+// IngestRoutes returns a chi.Router serving per-integration ingest URLs. It
+// must be mounted behind the tenant middleware; the token in the path selects
+// the integration, which in turn selects the payload format.
+func (h *WebhookHandler) IngestRoutes() chi.Router {
+	r := chi.NewRouter()
+	r.Post("/{token}", h.handleIngest)
 	return r
 }
 
+// sourceHandler returns the handler for an integration source type.
+func (h *WebhookHandler) sourceHandler(source string) http.HandlerFunc {
+	if !slices.Contains(IntegrationSources, source) {
+		return nil
+	}
+	return h.receiver(source)
+}
+
 // recordReceived increments the received counter for the given source and severity.
 func (h *WebhookHandler) recordReceived(source, severity string) {
 	if h.metrics != nil && h.metrics.ReceivedTotal != nil {
@@ -142,26 +211,27 @@ func (h *WebhookHandler) recordKBHit() {
 	}
 }
 
-// store creates a per-request Store from the tenant-scoped connection.
-func (h *WebhookHandler) store(r *http.Request) *Store {
-	conn := tenant.ConnFromContext(r.Context())
-	return NewStore(conn)
+// recordQueued increments the queued counter for the given source.
+func (h *WebhookHandler) recordQueued(source string) {
+	if h.metrics != nil && h.metrics.QueuedTotal != nil {
+		h.metrics.QueuedTotal.WithLabelValues(source).Inc()
+	}
 }
 
-// tenantSchema returns the tenant schema name from the request context.
-func tenantSchema(r *http.Request) string {
-	if info := tenant.FromContext(r.Context()); info != nil {
+// tenantSchema returns the tenant schema name from the context.
+func tenantSchema(ctx context.Context) string {
+	if info := tenant.FromContext(ctx); info != nil {
 		return info.Schema
 	}
 	return "unknown"
 }
 
 // createOrDedup checks for a duplicate alert and either increments the existing
-// alert's occurrence count or creates a new one.
-func (h *WebhookHandler) createOrDedup(r *http.Request, store *Store, normalized NormalizedAlert) (Response, bool, error) {
-	ctx := r.Context()
+// alert's occurrence count or creates a new one. ctx must carry the tenant
+// connection.
+func (h *WebhookHandler) createOrDedup(ctx context.Context, store *Store, normalized NormalizedAlert) (Response, bool, error) {
 	conn := tenant.ConnFromContext(ctx)
-	schema := tenantSchema(r)
+	schema := tenantSchema(ctx)
 
 	if h.dedup != nil && normalized.Status == "firing" {
 		result, err := h.dedup.Check(ctx, schema, normalized.Fingerprint, conn)
@@ -223,17 +293,39 @@ func (h *WebhookHandler) createOrDedup(r *http.Request, store *Store, normalized
 	return resp, false, nil
 }
 
+// auditDetail builds the audit detail for an ingested alert, naming the
+// integration it arrived through if any.
+func auditDetail(title, source string, in *Integration) json.RawMessage {
+	detail := map[string]string{"title": title, "source": source}
+	if in != nil {
+		detail["integration"] = in.Name
+	}
+	raw, _ := json.Marshal(detail)
+	return raw
+}
+
+// maxWebhookBody caps the size of a webhook payload.
+const maxWebhookBody = 1 << 20 // 1 MiB
+
+// readWebhookBody reads a webhook request body.
+func readWebhookBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		return nil, fmt.Errorf("reading request body: %w", err)
+	}
+	if len(body) == 0 {
+		return nil, fmt.Errorf("request body is empty")
+	}
+	return body, nil
+}
+
 // decodeWebhookBody reads and decodes a webhook JSON body.
 // Unlike httpserver.Decode, this is lenient about unknown fields since external
 // systems may include additional data.
 func decodeWebhookBody(r *http.Request, dst any) error {
-	const maxBody = 1 << 20 // 1 MiB
-	body, err := io.ReadAll(io.LimitReader(r.Body, maxBody))
+	body, err := readWebhookBody(r)
 	if err != nil {
-		return fmt.Errorf("reading request body: %w", err)
-	}
-	if len(body) == 0 {
-		return fmt.Errorf("request body is empty")
+		return err
 	}
 	if err := json.Unmarshal(body, dst); err != nil {
 		return fmt.Errorf("invalid JSON: %w", err)
@@ -241,171 +333,404 @@ func decodeWebhookBody(r *http.Request, dst any) error {
 	return nil
 }
 
-// handleAlertmanager processes Alertmanager webhook payloads containing one or
-// more alerts, normalizes each to the internal format, and persists them.
-func (h *WebhookHandler) handleAlertmanager(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-	defer h.recordDuration("alertmanager", start)
+// payloadError is a payload the sender has to fix. Retrying it cannot
+// succeed, so the handler rejects it and the processor dead-letters it.
+type payloadError struct {
+	status  int
+	message string
+}
 
-	var payload alertmanagerPayload
-	if err := decodeWebhookBody(r, &payload); err != nil {
+func (e *payloadError) Error() string { return e.message }
+
+// badPayload is a payload that cannot be decoded.
+func badPayload(format string, args ...any) error {
+	return &payloadError{status: http.StatusBadRequest, message: fmt.Sprintf(format, args...)}
+}
+
+// invalidPayload is a decoded payload that does not describe an alert.
+func invalidPayload(format string, args ...any) error {
+	return &payloadError{status: http.StatusUnprocessableEntity, message: fmt.Sprintf(format, args...)}
+}
+
+// respondPayloadError answers with a *payloadError's status and message.
+func respondPayloadError(w http.ResponseWriter, err error) {
+	var pe *payloadError
+	if !errors.As(err, &pe) {
 		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
 		return
 	}
-
-	if len(payload.Alerts) == 0 {
-		httpserver.RespondError(w, http.StatusUnprocessableEntity, "validation_error", "no alerts in payload")
-		return
+	code := "bad_request"
+	if pe.status == http.StatusUnprocessableEntity {
+		code = "validation_error"
 	}
+	httpserver.RespondError(w, pe.status, code, pe.message)
+}
 
-	store := h.store(r)
-	conn := tenant.ConnFromContext(r.Context())
-	var results []Response
-	for _, a := range payload.Alerts {
-		normalized := normalizeAlertmanager(a)
-		h.recordReceived("alertmanager", normalized.Severity)
-
-		// Auto-resolve: if Alertmanager sends status=resolved, resolve the existing alert.
-		if normalized.Status == "resolved" {
-			q := db.New(conn)
-			row, err := q.ResolveAlertByFingerprint(r.Context(), normalized.Fingerprint)
-			if err != nil {
-				h.logger.Warn("auto-resolve by fingerprint failed", "error", err, "fingerprint", normalized.Fingerprint)
-				continue
-			}
-			resp := AlertRowToResponse(row)
-			results = append(results, resp)
+// decodePayload decodes a JSON payload, leniently about unknown fields.
+func decodePayload(body []byte, dst any) error {
+	if err := json.Unmarshal(body, dst); err != nil {
+		return badPayload("invalid JSON: %v", err)
+	}
+	return nil
+}
 
-			if h.audit != nil {
-				detail, _ := json.Marshal(map[string]string{"title": resp.Title, "source": "alertmanager"})
-				h.audit.LogFromRequest(r, "auto_resolve", "alert", resp.ID, detail)
-			}
-			continue
+// parsePayload decodes, validates and normalizes a payload of the given
+// source. query holds the request's query parameters and mapping the
+// integration's payload mapping, if any. The handler parses to reject bad
+// payloads before queueing them; the processor parses again to ingest them.
+// Errors are *payloadError.
+func parsePayload(source string, body []byte, query url.Values, mapping *PayloadMapping) ([]NormalizedAlert, error) {
+	switch source {
+	case SourceAlertmanager:
+		return parseAlertmanager(body)
+	case SourceGrafana:
+		return parseGrafana(body)
+	case SourceKeep:
+		return parseKeep(body)
+	case SourceGeneric:
+		if mapping != nil {
+			return parseMapped(body, mapping)
 		}
+		return parseGeneric(body)
+	case SourceDatadog:
+		return parseDatadog(body)
+	case SourceCloudWatch:
+		return parseCloudWatch(body, query.Get("severity"))
+	case SourceOpsgenie:
+		return parseOpsgenie(body)
+	case SourcePagerDuty:
+		return parsePagerDuty(body)
+	case SourceEmail:
+		return parseEmail(body, mapping)
+	}
+	return nil, invalidPayload("unsupported source type %s", source)
+}
 
-		resp, isDup, err := h.createOrDedup(r, store, normalized)
-		if err != nil {
-			h.logger.Error("processing alert from alertmanager", "error", err, "fingerprint", normalized.Fingerprint)
-			continue
-		}
-		results = append(results, resp)
+// AcceptedResponse is returned for a webhook payload accepted for
+// processing. ID names the queued payload; it is absent when the payload
+// carried nothing to process.
+type AcceptedResponse struct {
+	Status         string     `json:"status"`
+	ID             *uuid.UUID `json:"id,omitempty"`
+	AlertsReceived int        `json:"alerts_received"`
+}
 
-		if h.audit != nil {
-			action := "create"
-			if isDup {
-				action = "deduplicate"
-			}
-			detail, _ := json.Marshal(map[string]string{"title": resp.Title, "source": "alertmanager"})
-			h.audit.LogFromRequest(r, action, "alert", resp.ID, detail)
-		}
+// receiver returns the handler for a source's webhook endpoint.
+func (h *WebhookHandler) receiver(source string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.receive(w, r, source)
 	}
-
-	httpserver.Respond(w, http.StatusCreated, BatchResponse{
-		AlertsProcessed: len(results),
-		Alerts:          results,
-	})
 }
 
-// handleKeep processes Keep webhook payloads.
-func (h *WebhookHandler) handleKeep(w http.ResponseWriter, r *http.Request) {
+// receive validates a webhook payload, stores it in the tenant's ingest
+// queue and answers 202 Accepted. Alerts are created by the queue's
+// processor, so a payload that has been accepted survives database hiccups
+// and restarts; one that cannot be queued is refused with a 5xx for the
+// sender to retry. Invalid payloads are rejected right away.
+func (h *WebhookHandler) receive(w http.ResponseWriter, r *http.Request, source string) {
 	start := time.Now()
-	defer h.recordDuration("keep", start)
+	defer h.recordDuration(source, start)
 
-	var payload keepPayload
-	if err := decodeWebhookBody(r, &payload); err != nil {
+	body, err := readWebhookBody(r)
+	if err != nil {
 		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
 		return
 	}
-
-	if payload.Name == "" {
-		httpserver.RespondError(w, http.StatusUnprocessableEntity, "validation_error", "name is required")
+	if source == SourceCloudWatch && h.handleSNSControl(w, r, body) {
 		return
 	}
 
-	store := h.store(r)
-	normalized := normalizeKeep(payload)
-	h.recordReceived("keep", normalized.Severity)
-	resp, isDup, err := h.createOrDedup(r, store, normalized)
+	ctx := r.Context()
+	st := ingestFromContext(ctx)
+	var mapping *PayloadMapping
+	if st != nil {
+		mapping = st.integration.Mapping
+	}
+	alerts, err := parsePayload(source, body, r.URL.Query(), mapping)
 	if err != nil {
-		h.logger.Error("processing alert from keep", "error", err)
-		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to process alert")
+		respondPayloadError(w, err)
 		return
 	}
+	if st != nil {
+		st.alerts = len(alerts)
+	}
 
-	if h.audit != nil {
-		action := "create"
-		if isDup {
-			action = "deduplicate"
+	resp := AcceptedResponse{Status: "ignored", AlertsReceived: len(alerts)}
+	if len(alerts) > 0 {
+		job := &QueuedPayload{
+			Source: source, Payload: body, Query: redactQuery(r.URL.RawQuery),
+			Headers: redactHeaders(r.Header), Origin: originFromRequest(r),
+		}
+		if st != nil {
+			job.IntegrationID = &st.integration.ID
+		}
+		if err := h.enqueue(ctx, job); err != nil {
+			h.logger.Error("queueing webhook payload", "error", err, "source", source)
+			httpserver.RespondError(w, http.StatusServiceUnavailable, "unavailable", "failed to queue payload, retry later")
+			return
+		}
+		h.recordQueued(source)
+		if info := tenant.FromContext(ctx); info != nil && h.OnQueued != nil {
+			h.OnQueued(info.Slug)
 		}
-		detail, _ := json.Marshal(map[string]string{"title": resp.Title, "source": "keep"})
-		h.audit.LogFromRequest(r, action, "alert", resp.ID, detail)
+		resp.Status, resp.ID = "queued", &job.ID
 	}
 
-	httpserver.Respond(w, http.StatusCreated, resp)
+	if source == SourcePagerDuty {
+		// Mirror the Events API so existing senders need no changes.
+		httpserver.Respond(w, http.StatusAccepted, eventsV2Response{
+			Status: "success", Message: "Event processed", DedupKey: alerts[0].Fingerprint,
+		})
+		return
+	}
+	httpserver.Respond(w, http.StatusAccepted, resp)
 }
 
-// handleGeneric processes generic JSON webhook payloads.
-func (h *WebhookHandler) handleGeneric(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-	defer h.recordDuration("generic", start)
+// enqueuePayload stores a payload in the ingest queue of the request's
+// tenant.
+func enqueuePayload(ctx context.Context, p *QueuedPayload) error {
+	return NewQueueStore(tenant.ConnFromContext(ctx)).Enqueue(ctx, p)
+}
 
-	var payload genericPayload
-	if err := decodeWebhookBody(r, &payload); err != nil {
-		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
-		return
+// originFromRequest records the sender of a request for auditing.
+func originFromRequest(r *http.Request) IngestOrigin {
+	e := audit.EntryFromRequest(r)
+	return IngestOrigin{
+		UserID:    pgtypeUUIDToPtr(e.UserID),
+		APIKeyID:  pgtypeUUIDToPtr(e.APIKeyID),
+		IPAddress: e.IPAddress,
+		UserAgent: e.UserAgent,
 	}
+}
 
-	if payload.Title == "" {
-		httpserver.RespondError(w, http.StatusUnprocessableEntity, "validation_error", "title is required")
-		return
+// ingest turns a queued payload into alerts, resuming after the alerts an
+// earlier attempt already persisted. ctx must carry the tenant, as set by
+// tenant.Acquire. Errors that are *payloadError will not go away on retry.
+func (h *WebhookHandler) ingest(ctx context.Context, queue *QueueStore, p *QueuedPayload) error {
+	conn := tenant.ConnFromContext(ctx)
+
+	// Integration defaults apply as configured now. A deleted integration
+	// leaves the payload to be processed without them.
+	var in *Integration
+	var mapping *PayloadMapping
+	if p.IntegrationID != nil {
+		got, err := NewIntegrationStore(conn).Get(ctx, *p.IntegrationID)
+		switch {
+		case err == nil:
+			in, mapping = &got, got.Mapping
+		case !errors.Is(err, ErrIntegrationNotFound):
+			return fmt.Errorf("loading integration: %w", err)
+		}
 	}
 
-	store := h.store(r)
-	normalized := normalizeGeneric(payload)
-	h.recordReceived(normalized.Source, normalized.Severity)
-	resp, isDup, err := h.createOrDedup(r, store, normalized)
+	query, _ := url.ParseQuery(p.Query)
+	alerts, err := parsePayload(p.Source, p.Payload, query, mapping)
 	if err != nil {
-		h.logger.Error("processing alert from generic webhook", "error", err)
-		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to process alert")
-		return
+		return err
 	}
 
-	// Agent auto-resolve: mark as resolved by agent and auto-create KB entry.
-	if normalized.ResolvedByAgent && !isDup {
-		ctx := r.Context()
-		conn := tenant.ConnFromContext(ctx)
-		q := db.New(conn)
-
-		row, err := q.ResolveAlertByAgent(ctx, db.ResolveAlertByAgentParams{
-			ID:                   resp.ID,
-			AgentResolutionNotes: &normalized.AgentResolutionNotes,
-		})
+	for i := p.Processed; i < len(alerts); i++ {
+		a := alerts[i]
+		if in != nil {
+			in.applyTo(&a)
+		}
+		action, resp, err := h.persistAlert(ctx, a)
 		if err != nil {
-			h.logger.Error("resolving alert by agent", "error", err, "id", resp.ID)
-		} else {
-			resp = AlertRowToResponse(row)
+			return fmt.Errorf("alert %d of %d: %w", i+1, len(alerts), err)
 		}
-
-		// Auto-create KB entry with the agent's action as the solution.
-		h.createAgentKBEntry(ctx, conn, normalized)
-
-		if h.metrics != nil && h.metrics.AgentResolvedTotal != nil {
-			h.metrics.AgentResolvedTotal.Inc()
+		var alertID *uuid.UUID
+		if action != "" {
+			alertID = &resp.ID
+			if h.audit != nil {
+				entry := p.Origin.auditEntry(tenantSchema(ctx))
+				entry.Action, entry.Resource, entry.ResourceID = action, "alert", resp.ID
+				entry.Detail = auditDetail(resp.Title, a.Source, in)
+				h.audit.Log(entry)
+			}
+		}
+		if err := queue.Progress(ctx, p.ID, i+1, alertID, ingestLease); err != nil {
+			return err
 		}
+		p.Processed = i + 1
 	}
+	return nil
+}
 
+// IngestAlert feeds one alert raised by NightOwl itself, such as a missed
+// heartbeat, through the same pipeline as webhooks and audits the action
+// taken. The action is empty when a resolved alert had nothing open to
+// resolve. ctx must carry the tenant, as set by tenant.Acquire.
+func (h *WebhookHandler) IngestAlert(ctx context.Context, a NormalizedAlert) (string, Response, error) {
+	start := time.Now()
+	defer h.recordDuration(a.Source, start)
+
+	action, resp, err := h.persistAlert(ctx, a)
+	if err != nil || action == "" {
+		return action, resp, err
+	}
 	if h.audit != nil {
-		action := "create"
-		if isDup {
-			action = "deduplicate"
-		} else if normalized.ResolvedByAgent {
-			action = "agent_resolve"
+		h.audit.Log(audit.Entry{
+			TenantSchema: tenantSchema(ctx),
+			Action:       action,
+			Resource:     "alert",
+			ResourceID:   resp.ID,
+			Detail:       auditDetail(resp.Title, a.Source, nil),
+		})
+	}
+	return action, resp, nil
+}
+
+// auditEntry returns an audit entry attributed to the payload's sender.
+func (o IngestOrigin) auditEntry(schema string) audit.Entry {
+	return audit.Entry{
+		TenantSchema: schema,
+		UserID:       ptrToPgtypeUUID(o.UserID),
+		APIKeyID:     ptrToPgtypeUUID(o.APIKeyID),
+		IPAddress:    o.IPAddress,
+		UserAgent:    o.UserAgent,
+	}
+}
+
+// statusAcknowledged marks normalized events that acknowledge the open
+// alert with their fingerprint, such as PagerDuty acknowledge events.
+const statusAcknowledged = "acknowledged"
+
+// persistAlert runs one alert through the ingest pipeline and returns the
+// audit action taken with the resulting alert. Resolved alerts resolve, and
+// acknowledgements acknowledge, the open alert with the same fingerprint;
+// the action is empty if there is none. Firing alerts are created or
+// deduplicated, grouped and enriched. ctx must carry the tenant connection.
+func (h *WebhookHandler) persistAlert(ctx context.Context, a NormalizedAlert) (string, Response, error) {
+	conn := tenant.ConnFromContext(ctx)
+	q := db.New(conn)
+	h.recordReceived(a.Source, a.Severity)
+
+	switch {
+	case a.Status == statusAcknowledged:
+		row, err := q.GetAlertByFingerprint(ctx, a.Fingerprint)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && row.Status != "firing") {
+			return "", Response{}, nil
+		}
+		if err == nil {
+			row, err = q.AcknowledgeAlert(ctx, db.AcknowledgeAlertParams{ID: row.ID, AcknowledgedBy: pgtype.UUID{}})
+		}
+		if err != nil {
+			return "", Response{}, fmt.Errorf("acknowledging alert by fingerprint: %w", err)
+		}
+		return "auto_acknowledge", AlertRowToResponse(row), nil
+
+	// Auto-resolve: a resolved notification resolves the existing alert.
+	case a.Status == "resolved" && !a.ResolvedByAgent:
+		row, err := q.ResolveAlertByFingerprint(ctx, a.Fingerprint)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", Response{}, nil
+		}
+		if err != nil {
+			return "", Response{}, fmt.Errorf("resolving alert by fingerprint: %w", err)
 		}
-		detail, _ := json.Marshal(map[string]string{"title": resp.Title, "source": normalized.Source})
-		h.audit.LogFromRequest(r, action, "alert", resp.ID, detail)
+		return "auto_resolve", AlertRowToResponse(row), nil
+	}
+
+	resp, isDup, err := h.createOrDedup(ctx, NewStore(conn), a)
+	if err != nil {
+		return "", Response{}, err
+	}
+	if isDup {
+		return "deduplicate", resp, nil
+	}
+	if !a.ResolvedByAgent {
+		return "create", resp, nil
+	}
+
+	// Agent auto-resolve: mark as resolved by agent and auto-create KB entry.
+	// Both are best effort; the alert itself is persisted.
+	row, err := q.ResolveAlertByAgent(ctx, db.ResolveAlertByAgentParams{
+		ID:                   resp.ID,
+		AgentResolutionNotes: &a.AgentResolutionNotes,
+	})
+	if err != nil {
+		h.logger.Error("resolving alert by agent", "error", err, "id", resp.ID)
+	} else {
+		resp = AlertRowToResponse(row)
+	}
+	h.createAgentKBEntry(ctx, conn, a)
+	if h.metrics != nil && h.metrics.AgentResolvedTotal != nil {
+		h.metrics.AgentResolvedTotal.Inc()
 	}
+	return "agent_resolve", resp, nil
+}
+
+// parseAlertmanager parses an Alertmanager notification carrying one or
+// more alerts.
+func parseAlertmanager(body []byte) ([]NormalizedAlert, error) {
+	var payload alertmanagerPayload
+	if err := decodePayload(body, &payload); err != nil {
+		return nil, err
+	}
+	if len(payload.Alerts) == 0 {
+		return nil, invalidPayload("no alerts in payload")
+	}
+	alerts := make([]NormalizedAlert, 0, len(payload.Alerts))
+	for _, a := range payload.Alerts {
+		alerts = append(alerts, normalizeAlertmanager(a))
+	}
+	return alerts, nil
+}
 
-	httpserver.Respond(w, http.StatusCreated, resp)
+// parseGrafana parses a Grafana unified-alerting notification. Like
+// Alertmanager, one notification carries several alerts, each firing or
+// resolved.
+func parseGrafana(body []byte) ([]NormalizedAlert, error) {
+	var payload grafanaPayload
+	if err := decodePayload(body, &payload); err != nil {
+		return nil, err
+	}
+	if len(payload.Alerts) == 0 {
+		return nil, invalidPayload("no alerts in payload")
+	}
+	alerts := make([]NormalizedAlert, 0, len(payload.Alerts))
+	for _, a := range payload.Alerts {
+		alerts = append(alerts, normalizeGrafana(a))
+	}
+	return alerts, nil
+}
+
+// parseKeep parses a Keep alert.
+func parseKeep(body []byte) ([]NormalizedAlert, error) {
+	var payload keepPayload
+	if err := decodePayload(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Name == "" {
+		return nil, invalidPayload("name is required")
+	}
+	return []NormalizedAlert{normalizeKeep(payload)}, nil
+}
+
+// parseGeneric parses a generic JSON alert.
+func parseGeneric(body []byte) ([]NormalizedAlert, error) {
+	var payload genericPayload
+	if err := decodePayload(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Title == "" {
+		return nil, invalidPayload("title is required")
+	}
+	return []NormalizedAlert{normalizeGeneric(payload)}, nil
+}
+
+// parseMapped maps an arbitrary JSON payload to alerts with an
+// integration's payload mapping.
+func parseMapped(body []byte, m *PayloadMapping) ([]NormalizedAlert, error) {
+	var payload json.RawMessage
+	if err := decodePayload(body, &payload); err != nil {
+		return nil, err
+	}
+	alerts, err := m.mapPayload(payload)
+	if err != nil {
+		return nil, invalidPayload("%s", err.Error())
+	}
+	return alerts, nil
 }
 
 // createAgentKBEntry creates a knowledge base (incident) entry from an agent-resolved alert.
@@ -430,6 +755,92 @@ func (h *WebhookHandler) createAgentKBEntry(ctx context.Context, dbtx db.DBTX, n
 	}
 }
 
+// --- Per-integration ingest ---
+
+type ingestKey struct{}
+
+// ingestState tracks the integration an ingest request arrived through and
+// how many alerts it carried.
+type ingestState struct {
+	integration *Integration
+	alerts      int
+}
+
+func ingestFromContext(ctx context.Context) *ingestState {
+	st, _ := ctx.Value(ingestKey{}).(*ingestState)
+	return st
+}
+
+// handleIngest authenticates an ingest token, hands the request to the
+// integration's source handler and records ingestion statistics.
+func (h *WebhookHandler) handleIngest(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	store := NewIntegrationStore(tenant.ConnFromContext(ctx))
+
+	in, err := store.GetByToken(ctx, chi.URLParam(r, "token"))
+	if errors.Is(err, ErrIntegrationNotFound) {
+		httpserver.RespondError(w, http.StatusUnauthorized, "unauthorized", "invalid integration token")
+		return
+	}
+	if err != nil {
+		h.logger.Error("looking up integration", "error", err)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to look up integration")
+		return
+	}
+	if !in.Enabled {
+		httpserver.RespondError(w, http.StatusForbidden, "integration_disabled", "integration is disabled")
+		return
+	}
+
+	st := &ingestState{integration: &in}
+	rec := &ingestRecorder{ResponseWriter: w, status: http.StatusOK}
+	if handle := h.sourceHandler(in.SourceType); handle != nil {
+		handle(rec, r.WithContext(context.WithValue(ctx, ingestKey{}, st)))
+	} else {
+		httpserver.RespondError(rec, http.StatusUnprocessableEntity, "validation_error",
+			"unsupported integration source type "+in.SourceType)
+	}
+
+	if err := store.RecordIngest(ctx, in.ID, st.alerts, rec.errorMessage()); err != nil {
+		h.logger.Warn("recording integration stats", "error", err, "integration", in.Name)
+	}
+}
+
+// ingestRecorder captures the status and error message of an ingest response
+// for the integration's statistics.
+type ingestRecorder struct {
+	http.ResponseWriter
+	status int
+	body   []byte
+}
+
+func (rw *ingestRecorder) WriteHeader(status int) {
+	rw.status = status
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *ingestRecorder) Write(b []byte) (int, error) {
+	if rw.status >= http.StatusBadRequest && len(rw.body) < 1024 {
+		rw.body = append(rw.body, b...)
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+// errorMessage returns the error message sent to the caller, or "" when the
+// request succeeded.
+func (rw *ingestRecorder) errorMessage() string {
+	if rw.status < http.StatusBadRequest {
+		return ""
+	}
+	var body struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(rw.body, &body) == nil && body.Message != "" {
+		return body.Message
+	}
+	return http.StatusText(rw.status)
+}
+
 // --- Normalization functions ---
 
 // normalizeAlertmanager converts an Alertmanager alert to the internal format.
@@ -466,6 +877,61 @@ func normalizeAlertmanager(a alertmanagerAlert) NormalizedAlert {
 	}
 }
 
+// normalizeGrafana converts a Grafana alert to the internal format. Grafana's
+// value and link fields are kept in the annotations under their own names.
+func normalizeGrafana(a grafanaAlert) NormalizedAlert {
+	title := a.Labels["alertname"]
+	if title == "" {
+		title = "Unnamed Grafana Alert"
+	}
+
+	var desc *string
+	if summary := a.Annotations["summary"]; summary != "" {
+		desc = &summary
+	} else if description := a.Annotations["description"]; description != "" {
+		desc = &description
+	}
+
+	annotations := make(map[string]any, len(a.Annotations)+7)
+	for k, v := range a.Annotations {
+		annotations[k] = v
+	}
+	for k, v := range map[string]string{
+		"valueString":  a.ValueString,
+		"dashboardURL": a.DashboardURL,
+		"panelURL":     a.PanelURL,
+		"silenceURL":   a.SilenceURL,
+		"generatorURL": a.GeneratorURL,
+		"imageURL":     a.ImageURL,
+	} {
+		if v != "" {
+			annotations[k] = v
+		}
+	}
+	if len(a.Values) > 0 {
+		annotations["values"] = a.Values
+	}
+
+	labels, _ := json.Marshal(a.Labels)
+	annotationsJSON, _ := json.Marshal(annotations)
+
+	fp := a.Fingerprint
+	if fp == "" {
+		fp = generateFingerprint(title, labels)
+	}
+
+	return NormalizedAlert{
+		Fingerprint: fp,
+		Status:      normalizeStatus(a.Status),
+		Severity:    normalizeSeverity(a.Labels["severity"]),
+		Source:      "grafana",
+		Title:       title,
+		Description: desc,
+		Labels:      labels,
+		Annotations: annotationsJSON,
+	}
+}
+
 // normalizeKeep converts a Keep alert to the internal format.
 func normalizeKeep(p keepPayload) NormalizedAlert {
 	labels, _ := json.Marshal(p.Labels)
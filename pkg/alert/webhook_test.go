@@ -191,6 +191,112 @@ func TestNormalizeAlertmanager_Resolved(t *testing.T) {
 	}
 }
 
+func TestNormalizeGrafana(t *testing.T) {
+	var p grafanaPayload
+	body := `{
+		"receiver": "nightowl",
+		"status": "firing",
+		"orgId": 1,
+		"alerts": [{
+			"status": "firing",
+			"labels": {"alertname": "HighLatency", "severity": "critical", "grafana_folder": "API"},
+			"annotations": {"summary": "p99 latency above 2s"},
+			"startsAt": "2026-02-20T10:00:00Z",
+			"endsAt": "0001-01-01T00:00:00Z",
+			"generatorURL": "https://grafana.example.com/alerting/grafana/abc/view",
+			"fingerprint": "5e1c0a3c9a1b2d3e",
+			"silenceURL": "https://grafana.example.com/alerting/silence/new?matcher=alertname%3DHighLatency",
+			"dashboardURL": "https://grafana.example.com/d/xyz",
+			"panelURL": "https://grafana.example.com/d/xyz?viewPanel=4",
+			"values": {"B": 2.31},
+			"valueString": "[ var='B' labels={service=api} value=2.31 ]"
+		}]
+	}`
+	if err := json.Unmarshal([]byte(body), &p); err != nil {
+		t.Fatal(err)
+	}
+
+	n := normalizeGrafana(p.Alerts[0])
+
+	if n.Title != "HighLatency" {
+		t.Errorf("Title = %q, want HighLatency", n.Title)
+	}
+	if n.Severity != "critical" {
+		t.Errorf("Severity = %q, want critical", n.Severity)
+	}
+	if n.Source != "grafana" {
+		t.Errorf("Source = %q, want grafana", n.Source)
+	}
+	if n.Fingerprint != "5e1c0a3c9a1b2d3e" {
+		t.Errorf("Fingerprint = %q, want Grafana fingerprint", n.Fingerprint)
+	}
+	if n.Description == nil || *n.Description != "p99 latency above 2s" {
+		t.Errorf("Description = %v, want summary annotation", n.Description)
+	}
+
+	var labels map[string]string
+	if err := json.Unmarshal(n.Labels, &labels); err != nil {
+		t.Fatal(err)
+	}
+	if labels["grafana_folder"] != "API" {
+		t.Errorf("labels = %v, want Grafana labels preserved", labels)
+	}
+
+	var annotations map[string]any
+	if err := json.Unmarshal(n.Annotations, &annotations); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"summary":      "p99 latency above 2s",
+		"valueString":  "[ var='B' labels={service=api} value=2.31 ]",
+		"dashboardURL": "https://grafana.example.com/d/xyz",
+		"panelURL":     "https://grafana.example.com/d/xyz?viewPanel=4",
+		"silenceURL":   "https://grafana.example.com/alerting/silence/new?matcher=alertname%3DHighLatency",
+		"generatorURL": "https://grafana.example.com/alerting/grafana/abc/view",
+	} {
+		if annotations[key] != want {
+			t.Errorf("annotations[%q] = %v, want %q", key, annotations[key], want)
+		}
+	}
+	if _, ok := annotations["imageURL"]; ok {
+		t.Error("empty imageURL should not be stored")
+	}
+	if values, ok := annotations["values"].(map[string]any); !ok || values["B"] != 2.31 {
+		t.Errorf("annotations[values] = %v, want {B: 2.31}", annotations["values"])
+	}
+}
+
+func TestNormalizeGrafana_Resolved(t *testing.T) {
+	a := grafanaAlert{
+		Status:      "resolved",
+		Labels:      map[string]string{"alertname": "HighLatency"},
+		Fingerprint: "fp1",
+	}
+
+	n := normalizeGrafana(a)
+
+	if n.Status != "resolved" {
+		t.Errorf("Status = %q, want resolved", n.Status)
+	}
+	if n.Fingerprint != "fp1" {
+		t.Errorf("Fingerprint = %q, want fp1 so the firing alert is resolved", n.Fingerprint)
+	}
+}
+
+func TestNormalizeGrafana_MissingFields(t *testing.T) {
+	n := normalizeGrafana(grafanaAlert{Status: "firing"})
+
+	if n.Title != "Unnamed Grafana Alert" {
+		t.Errorf("Title = %q, want fallback title", n.Title)
+	}
+	if n.Fingerprint == "" {
+		t.Error("Fingerprint should be auto-generated")
+	}
+	if string(n.Annotations) != "{}" {
+		t.Errorf("Annotations = %s, want {}", n.Annotations)
+	}
+}
+
 func TestNormalizeKeep(t *testing.T) {
 	p := keepPayload{
 		ID:          "keep-uuid-123",
@@ -440,3 +546,29 @@ func TestGenericWebhook_InvalidJSON(t *testing.T) {
 		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
 	}
 }
+
+func TestGrafanaWebhook_InvalidJSON(t *testing.T) {
+	_, router := newTestRouter()
+
+	r := httptest.NewRequest(http.MethodPost, "/webhooks/grafana", strings.NewReader("{bad"))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestGrafanaWebhook_NoAlerts(t *testing.T) {
+	_, router := newTestRouter()
+
+	r := httptest.NewRequest(http.MethodPost, "/webhooks/grafana", strings.NewReader(`{"receiver":"nightowl","alerts":[]}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+}
@@ -10,11 +10,17 @@ import (
 
 	"github.com/wisbric/nightowl/internal/db"
 	"github.com/wisbric/nightowl/pkg/alert"
+	"github.com/wisbric/nightowl/pkg/events"
+	"github.com/wisbric/nightowl/pkg/tenant"
 )
 
 // Evaluator evaluates incoming alerts against grouping rules.
 type Evaluator struct {
 	logger *slog.Logger
+
+	// Events, when set, receives an alert_group.updated event whenever
+	// alerts join a group.
+	Events *events.Publisher
 }
 
 // NewEvaluator creates an Evaluator.
@@ -67,6 +73,8 @@ func (e *Evaluator) Evaluate(ctx context.Context, dbtx db.DBTX, alertID uuid.UUI
 			return alert.AlertGroupResult{}
 		}
 
+		e.publishGroupUpdate(ctx, groupID, rule.ID, []uuid.UUID{alertID})
+
 		e.logger.Debug("alert grouped",
 			"alert_id", alertID,
 			"group_id", groupID,
@@ -76,15 +84,36 @@ func (e *Evaluator) Evaluate(ctx context.Context, dbtx db.DBTX, alertID uuid.UUI
 		)
 
 		return alert.AlertGroupResult{
-			Matched: true,
-			GroupID: groupID,
-			RuleID:  rule.ID,
+			Matched:  true,
+			GroupID:  groupID,
+			RuleID:   rule.ID,
+			RuleName: rule.Name,
 		}
 	}
 
 	return alert.AlertGroupResult{}
 }
 
+// Match returns the first enabled grouping rule the labels match, without
+// creating or joining a group. Satisfies the alert.AlertGrouper interface.
+func (e *Evaluator) Match(ctx context.Context, dbtx db.DBTX, labels json.RawMessage) alert.AlertGroupResult {
+	rules, err := NewStore(dbtx).ListEnabledRules(ctx)
+	if err != nil {
+		e.logger.Warn("failed to load grouping rules", "error", err)
+		return alert.AlertGroupResult{}
+	}
+	var labelMap map[string]string
+	if len(rules) == 0 || json.Unmarshal(labels, &labelMap) != nil {
+		return alert.AlertGroupResult{}
+	}
+	for _, rule := range rules {
+		if matchAlert(rule.Matchers, labelMap) {
+			return alert.AlertGroupResult{Matched: true, RuleID: rule.ID, RuleName: rule.Name}
+		}
+	}
+	return alert.AlertGroupResult{}
+}
+
 // BackfillRule evaluates all ungrouped firing alerts against the given rule,
 // assigning matching alerts to groups. Called after rule create/update so
 // existing alerts are grouped retroactively.
@@ -101,6 +130,7 @@ func (e *Evaluator) BackfillRule(ctx context.Context, dbtx db.DBTX, rule RuleRes
 	}
 
 	grouped := 0
+	byGroup := make(map[uuid.UUID][]uuid.UUID)
 	for _, a := range alerts {
 		var labelMap map[string]string
 		if err := json.Unmarshal(a.Labels, &labelMap); err != nil {
@@ -127,8 +157,12 @@ func (e *Evaluator) BackfillRule(ctx context.Context, dbtx db.DBTX, rule RuleRes
 			continue
 		}
 
+		byGroup[groupID] = append(byGroup[groupID], a.ID)
 		grouped++
 	}
+	for groupID, alertIDs := range byGroup {
+		e.publishGroupUpdate(ctx, groupID, rule.ID, alertIDs)
+	}
 
 	if grouped > 0 {
 		e.logger.Info("backfilled existing alerts into rule",
@@ -140,3 +174,16 @@ func (e *Evaluator) BackfillRule(ctx context.Context, dbtx db.DBTX, rule RuleRes
 
 	return grouped, nil
 }
+
+// publishGroupUpdate announces alerts joining a group. ctx must carry the
+// tenant.
+func (e *Evaluator) publishGroupUpdate(ctx context.Context, groupID, ruleID uuid.UUID, alertIDs []uuid.UUID) {
+	info := tenant.FromContext(ctx)
+	if info == nil {
+		return
+	}
+	e.Events.Publish(info.Schema, events.AlertGroupUpdated, groupID, map[string]any{
+		"rule_id":   ruleID,
+		"alert_ids": alertIDs,
+	})
+}
@@ -0,0 +1,236 @@
+// Package analytics reports response and on-call load metrics computed from
+// the alert and escalation history.
+package analytics
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Dimensions a summary can be broken down by.
+const (
+	ByService   = "service"
+	BySource    = "source"
+	BySeverity  = "severity"
+	ByRoster    = "roster"
+	ByResponder = "responder"
+)
+
+// Volume bucket sizes.
+const (
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+	IntervalWeek = "week"
+)
+
+const (
+	defaultRange = 30 * 24 * time.Hour
+	defaultLimit = 10
+	maxLimit     = 100
+
+	// Pages outside 09:00–18:00 Monday to Friday in the responder's own
+	// timezone count as after hours.
+	workdayStart = "09:00"
+	workdayEnd   = "18:00"
+)
+
+// Query selects the alerts an analytics report covers. Alerts are matched by
+// when they first fired; responder reports match pages, acknowledgements and
+// resolutions by when they happened.
+type Query struct {
+	From       time.Time
+	To         time.Time
+	ServiceID  *uuid.UUID
+	Sources    []string
+	Severities []string
+	// RosterID matches alerts routed to the roster's escalation policy.
+	RosterID *uuid.UUID
+	// ResponderID matches alerts acknowledged, or else resolved, by the user.
+	ResponderID *uuid.UUID
+
+	// GroupBy is the summary breakdown dimension; empty means totals only.
+	GroupBy string
+	// Interval and Location bucket the volume series.
+	Interval string
+	Location *time.Location
+	// Limit caps the fingerprint report.
+	Limit int
+}
+
+// ParseQuery reads a Query from query parameters: from, to (RFC 3339 or
+// YYYY-MM-DD, default the last 30 days), service_id, source and severity
+// (repeatable or comma-separated), roster_id, responder_id, group_by,
+// interval, tz and limit.
+func ParseQuery(r *http.Request, now time.Time) (Query, error) {
+	q := r.URL.Query()
+	query := Query{
+		To:         now.UTC(),
+		Sources:    listParam(q["source"]),
+		Severities: listParam(q["severity"]),
+		GroupBy:    q.Get("group_by"),
+		Interval:   IntervalDay,
+		Location:   time.UTC,
+		Limit:      defaultLimit,
+	}
+
+	if v := q.Get("to"); v != "" {
+		t, ok := parseTime(v)
+		if !ok {
+			return Query{}, fmt.Errorf("to must be an RFC 3339 timestamp or YYYY-MM-DD date")
+		}
+		query.To = t
+	}
+	query.From = query.To.Add(-defaultRange)
+	if v := q.Get("from"); v != "" {
+		t, ok := parseTime(v)
+		if !ok {
+			return Query{}, fmt.Errorf("from must be an RFC 3339 timestamp or YYYY-MM-DD date")
+		}
+		query.From = t
+	}
+	if !query.To.After(query.From) {
+		return Query{}, fmt.Errorf("to must be after from")
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  **uuid.UUID
+	}{{"service_id", &query.ServiceID}, {"roster_id", &query.RosterID}, {"responder_id", &query.ResponderID}} {
+		if v := q.Get(p.name); v != "" {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				return Query{}, fmt.Errorf("%s must be a UUID", p.name)
+			}
+			*p.dst = &id
+		}
+	}
+
+	switch query.GroupBy {
+	case "", ByService, BySource, BySeverity, ByRoster, ByResponder:
+	default:
+		return Query{}, fmt.Errorf("group_by must be one of service, source, severity, roster, responder")
+	}
+
+	if v := q.Get("interval"); v != "" {
+		switch v {
+		case IntervalHour, IntervalDay, IntervalWeek:
+			query.Interval = v
+		default:
+			return Query{}, fmt.Errorf("interval must be one of hour, day, week")
+		}
+	}
+	if v := q.Get("tz"); v != "" {
+		loc, err := time.LoadLocation(v)
+		if err != nil {
+			return Query{}, fmt.Errorf("tz must be an IANA timezone name")
+		}
+		query.Location = loc
+	}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxLimit {
+			return Query{}, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+		query.Limit = n
+	}
+	return query, nil
+}
+
+// parseTime accepts an RFC 3339 instant or a YYYY-MM-DD date (UTC midnight).
+func parseTime(v string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+func listParam(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for s := range strings.SplitSeq(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// Metrics are the response figures for a set of alerts. Durations are in
+// seconds and measured from when the alert first fired; they are null when
+// no alert in the set was acknowledged or resolved.
+type Metrics struct {
+	Alerts         int64    `json:"alerts"`
+	Acknowledged   int64    `json:"acknowledged"`
+	Resolved       int64    `json:"resolved"`
+	Escalated      int64    `json:"escalated"`
+	EscalationRate float64  `json:"escalation_rate"`
+	MTTA           *float64 `json:"mtta_seconds"`
+	MTTAP90        *float64 `json:"mtta_p90_seconds"`
+	MTTR           *float64 `json:"mttr_seconds"`
+	MTTRP90        *float64 `json:"mttr_p90_seconds"`
+}
+
+// Group is one row of a summary breakdown. Key is null for alerts without a
+// value for the dimension, such as alerts with no service.
+type Group struct {
+	Key   *string `json:"key"`
+	Label string  `json:"label"`
+	Metrics
+}
+
+// Summary is the response for GET /api/v1/analytics/summary.
+type Summary struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	GroupBy string    `json:"group_by,omitempty"`
+	Totals  Metrics   `json:"totals"`
+	Groups  []Group   `json:"groups,omitempty"`
+}
+
+// Bucket is one interval of the alert volume series.
+type Bucket struct {
+	Start        time.Time        `json:"start"`
+	Alerts       int64            `json:"alerts"`
+	Acknowledged int64            `json:"acknowledged"`
+	Escalated    int64            `json:"escalated"`
+	BySeverity   map[string]int64 `json:"by_severity"`
+}
+
+// Fingerprint is the volume and response figures for one alert fingerprint.
+type Fingerprint struct {
+	Fingerprint  string     `json:"fingerprint"`
+	Title        string     `json:"title"`
+	Source       string     `json:"source"`
+	ServiceID    *uuid.UUID `json:"service_id,omitempty"`
+	ServiceName  *string    `json:"service_name,omitempty"`
+	Alerts       int64      `json:"alerts"`
+	Occurrences  int64      `json:"occurrences"`
+	Acknowledged int64      `json:"acknowledged"`
+	Escalated    int64      `json:"escalated"`
+	MTTA         *float64   `json:"mtta_seconds"`
+	LastFiredAt  time.Time  `json:"last_fired_at"`
+}
+
+// Responder is one person's on-call load. Pages are escalation notifications
+// addressed to the person; after-hours pages are those sent outside 09:00 to
+// 18:00 on weekdays in the person's timezone.
+type Responder struct {
+	UserID          uuid.UUID `json:"user_id"`
+	DisplayName     string    `json:"display_name"`
+	Timezone        string    `json:"timezone"`
+	Pages           int64     `json:"pages"`
+	AfterHoursPages int64     `json:"after_hours_pages"`
+	Acknowledged    int64     `json:"acknowledged"`
+	Resolved        int64     `json:"resolved"`
+	MTTA            *float64  `json:"mtta_seconds"`
+}
@@ -0,0 +1,134 @@
+package analytics
+
+import (
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var testNow = time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+
+func TestParseQuery_Defaults(t *testing.T) {
+	q, err := ParseQuery(httptest.NewRequest("GET", "/summary", nil), testNow)
+	if err != nil {
+		t.Fatalf("ParseQuery: %v", err)
+	}
+	if !q.To.Equal(testNow) || !q.From.Equal(testNow.AddDate(0, 0, -30)) {
+		t.Errorf("range = %s..%s", q.From, q.To)
+	}
+	if q.GroupBy != "" || q.Interval != IntervalDay || q.Location != time.UTC || q.Limit != defaultLimit {
+		t.Errorf("query = %+v", q)
+	}
+}
+
+func TestParseQuery_Params(t *testing.T) {
+	svc, roster := uuid.New(), uuid.New()
+	url := "/summary?from=2026-01-01&to=2026-02-01T00:00:00Z&service_id=" + svc.String() +
+		"&roster_id=" + roster.String() + "&source=alertmanager,grafana&source=datadog&severity=critical" +
+		"&group_by=roster&interval=week&tz=Europe/Berlin&limit=25"
+	q, err := ParseQuery(httptest.NewRequest("GET", url, nil), testNow)
+	if err != nil {
+		t.Fatalf("ParseQuery: %v", err)
+	}
+	if !q.From.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) || !q.To.Equal(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("range = %s..%s", q.From, q.To)
+	}
+	if *q.ServiceID != svc || *q.RosterID != roster || q.ResponderID != nil {
+		t.Errorf("ids = %v %v %v", q.ServiceID, q.RosterID, q.ResponderID)
+	}
+	if !slices.Equal(q.Sources, []string{"alertmanager", "grafana", "datadog"}) || !slices.Equal(q.Severities, []string{"critical"}) {
+		t.Errorf("sources = %v, severities = %v", q.Sources, q.Severities)
+	}
+	if q.GroupBy != ByRoster || q.Interval != IntervalWeek || q.Location.String() != "Europe/Berlin" || q.Limit != 25 {
+		t.Errorf("query = %+v", q)
+	}
+}
+
+func TestParseQuery_Errors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"from=yesterday", "from must be"},
+		{"from=2026-02-01&to=2026-01-01", "to must be after from"},
+		{"service_id=svc", "service_id must be a UUID"},
+		{"group_by=team", "group_by must be one of"},
+		{"interval=month", "interval must be one of"},
+		{"tz=Mars/Olympus", "tz must be"},
+		{"limit=0", "limit must be between"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := ParseQuery(httptest.NewRequest("GET", "/summary?"+tt.query, nil), testNow)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuery_SummarySQL(t *testing.T) {
+	svc, responder := uuid.New(), uuid.New()
+	q := Query{
+		From: testNow.AddDate(0, 0, -7), To: testNow,
+		ServiceID: &svc, Severities: []string{"critical"}, ResponderID: &responder,
+	}
+
+	sql, args := q.summarySQL("")
+	if strings.Contains(sql, "GROUP BY") || len(args) != 5 {
+		t.Errorf("totals sql = %s, args = %v", sql, args)
+	}
+	for _, want := range []string{
+		"a.first_fired_at >= $1", "a.first_fired_at < $2", "a.service_id = $3",
+		"a.severity = ANY($4)", "COALESCE(a.acknowledged_by, a.resolved_by) = $5",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("totals sql missing %q", want)
+		}
+	}
+	if args[4] != responder {
+		t.Errorf("args = %v", args)
+	}
+
+	sql, _ = q.summarySQL(ByService)
+	if !strings.Contains(sql, "LEFT JOIN services s") || !strings.Contains(sql, "GROUP BY 1, 2") {
+		t.Errorf("breakdown sql = %s", sql)
+	}
+}
+
+func TestQuery_RespondersSQL(t *testing.T) {
+	roster, responder := uuid.New(), uuid.New()
+	q := Query{From: testNow.AddDate(0, 0, -7), To: testNow, RosterID: &roster, ResponderID: &responder}
+
+	sql, args := q.respondersSQL()
+	if len(args) != 4 || args[2] != roster || args[3] != responder {
+		t.Errorf("args = %v", args)
+	}
+	// The roster filter applies to pages, acknowledgements and resolutions.
+	if n := strings.Count(sql, "FROM rosters WHERE id = $3"); n != 3 {
+		t.Errorf("roster filter appears %d times", n)
+	}
+	if !strings.Contains(sql, "zn.id = $4") || strings.Contains(sql, "COALESCE(a.acknowledged_by, a.resolved_by) =") {
+		t.Errorf("responder filter = %s", sql)
+	}
+	if !strings.Contains(sql, "::time < '09:00'") || !strings.Contains(sql, "::time >= '18:00'") {
+		t.Errorf("working hours missing: %s", sql)
+	}
+}
+
+func TestMetrics_EscalationRate(t *testing.T) {
+	m := Metrics{Alerts: 8, Escalated: 2}
+	m.finish()
+	if m.EscalationRate != 0.25 {
+		t.Errorf("rate = %v", m.EscalationRate)
+	}
+	empty := Metrics{}
+	empty.finish()
+	if empty.EscalationRate != 0 {
+		t.Errorf("empty rate = %v", empty.EscalationRate)
+	}
+}
@@ -0,0 +1,173 @@
+package analytics
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/wisbric/core/pkg/httpserver"
+
+	"github.com/wisbric/nightowl/pkg/tenant"
+)
+
+// Handler provides HTTP handlers for the analytics API.
+type Handler struct {
+	logger *slog.Logger
+}
+
+// NewHandler creates an analytics Handler.
+func NewHandler(logger *slog.Logger) *Handler {
+	return &Handler{logger: logger}
+}
+
+// Routes returns a chi.Router with all analytics routes mounted.
+func (h *Handler) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Get("/summary", h.handleSummary)
+	r.Get("/volume", h.handleVolume)
+	r.Get("/fingerprints", h.handleFingerprints)
+	r.Get("/responders", h.handleResponders)
+	r.Get("/noise", h.handleNoise)
+	r.Get("/noise/reports", h.handleListReports)
+	r.Get("/noise/reports/{id}", h.handleGetReport)
+	return r
+}
+
+func (h *Handler) store(r *http.Request) *Store {
+	return NewStore(tenant.ConnFromContext(r.Context()))
+}
+
+func parseQuery(w http.ResponseWriter, r *http.Request) (Query, bool) {
+	q, err := ParseQuery(r, time.Now())
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return Query{}, false
+	}
+	return q, true
+}
+
+func (h *Handler) respondErr(w http.ResponseWriter, what string, err error) {
+	h.logger.Error(what, "error", err)
+	httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed "+what)
+}
+
+func (h *Handler) handleSummary(w http.ResponseWriter, r *http.Request) {
+	q, ok := parseQuery(w, r)
+	if !ok {
+		return
+	}
+	summary, err := h.store(r).Summary(r.Context(), q)
+	if err != nil {
+		h.respondErr(w, "computing analytics summary", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, summary)
+}
+
+func (h *Handler) handleVolume(w http.ResponseWriter, r *http.Request) {
+	q, ok := parseQuery(w, r)
+	if !ok {
+		return
+	}
+	buckets, err := h.store(r).Volume(r.Context(), q)
+	if err != nil {
+		h.respondErr(w, "computing alert volume", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]any{
+		"from":     q.From,
+		"to":       q.To,
+		"interval": q.Interval,
+		"tz":       q.Location.String(),
+		"buckets":  buckets,
+	})
+}
+
+func (h *Handler) handleFingerprints(w http.ResponseWriter, r *http.Request) {
+	q, ok := parseQuery(w, r)
+	if !ok {
+		return
+	}
+	items, err := h.store(r).NoisyFingerprints(r.Context(), q)
+	if err != nil {
+		h.respondErr(w, "computing noisy fingerprints", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]any{
+		"from":         q.From,
+		"to":           q.To,
+		"fingerprints": items,
+		"count":        len(items),
+	})
+}
+
+func (h *Handler) handleResponders(w http.ResponseWriter, r *http.Request) {
+	q, ok := parseQuery(w, r)
+	if !ok {
+		return
+	}
+	items, err := h.store(r).Responders(r.Context(), q)
+	if err != nil {
+		h.respondErr(w, "computing responder load", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]any{
+		"from":       q.From,
+		"to":         q.To,
+		"responders": items,
+		"count":      len(items),
+	})
+}
+
+// handleNoise computes a noise report on demand. Without from it covers the
+// last 7 days.
+func (h *Handler) handleNoise(w http.ResponseWriter, r *http.Request) {
+	q, ok := parseQuery(w, r)
+	if !ok {
+		return
+	}
+	if r.URL.Query().Get("from") == "" {
+		q.From = q.To.Add(-defaultNoiseRange)
+	}
+	report, err := h.store(r).NoiseReport(r.Context(), q)
+	if err != nil {
+		h.respondErr(w, "computing noise report", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, report)
+}
+
+func (h *Handler) handleListReports(w http.ResponseWriter, r *http.Request) {
+	q, ok := parseQuery(w, r)
+	if !ok {
+		return
+	}
+	items, err := h.store(r).ListReports(r.Context(), q.Limit)
+	if err != nil {
+		h.respondErr(w, "listing noise reports", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]any{"reports": items, "count": len(items)})
+}
+
+func (h *Handler) handleGetReport(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid report ID")
+		return
+	}
+	report, err := h.store(r).GetReport(r.Context(), id)
+	if errors.Is(err, ErrReportNotFound) {
+		httpserver.RespondError(w, http.StatusNotFound, "not_found", err.Error())
+		return
+	}
+	if err != nil {
+		h.respondErr(w, "getting noise report", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, report)
+}
@@ -0,0 +1,276 @@
+package analytics
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wisbric/nightowl/pkg/alertgroup"
+	"github.com/wisbric/nightowl/pkg/messaging"
+)
+
+// Recommended tuning actions.
+const (
+	ActionGroupingRule      = "grouping_rule"
+	ActionSilence           = "silence"
+	ActionDowngradeSeverity = "downgrade_severity"
+)
+
+// Thresholds a fingerprint must reach to be listed in a noise report.
+const (
+	// flappingMinAlerts is how often a fingerprint must fire again after
+	// resolving to count as flapping.
+	flappingMinAlerts = 5
+	// neverAckedMinAlerts is how often a fingerprint must fire without ever
+	// being acknowledged.
+	neverAckedMinAlerts = 3
+	// autoResolveWindow is how soon an alert must resolve without a human
+	// to count as auto-resolved; autoResolveMinAlerts and autoResolveMinShare
+	// are how many and what share of a fingerprint's alerts must do so.
+	autoResolveWindow    = 10 * time.Minute
+	autoResolveMinAlerts = 3
+	autoResolveMinShare  = 0.8
+	// duplicateMinRatio is the average number of times each alert must have
+	// been received while open.
+	duplicateMinRatio = 10
+
+	// noiseMinAlerts is the smallest alert count any list can match on.
+	noiseMinAlerts = min(flappingMinAlerts, neverAckedMinAlerts, autoResolveMinAlerts)
+
+	defaultNoiseRange = 7 * 24 * time.Hour
+)
+
+// severities in increasing order.
+var severities = []string{"info", "warning", "major", "critical"}
+
+// identifyingLabels are the labels a suggested matcher or grouping uses to
+// pick out one alert, in order of preference.
+var identifyingLabels = []string{"alertname", "service", "namespace", "cluster", "job"}
+
+// Recommendation is a suggested change that would cut a noisy alert.
+type Recommendation struct {
+	Action string `json:"action"` // grouping_rule | silence | downgrade_severity
+	Reason string `json:"reason"`
+	// GroupingRule is a draft body for POST /api/v1/alert-groups/rules.
+	GroupingRule *alertgroup.CreateRuleRequest `json:"grouping_rule,omitempty"`
+	// Matchers select the alert for a silence at its source.
+	Matchers []alertgroup.Matcher `json:"matchers,omitempty"`
+	// Severity is the suggested severity for a downgrade.
+	Severity string `json:"severity,omitempty"`
+}
+
+// NoisyAlert is the noise profile of one fingerprint. Title, source,
+// severity, service and labels are those of its latest alert.
+type NoisyAlert struct {
+	Fingerprint string            `json:"fingerprint"`
+	Title       string            `json:"title"`
+	Source      string            `json:"source"`
+	Severity    string            `json:"severity"`
+	ServiceID   *uuid.UUID        `json:"service_id,omitempty"`
+	ServiceName *string           `json:"service_name,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	// Alerts is how often the fingerprint fired anew; Occurrences includes
+	// repeats deduplicated into an open alert.
+	Alerts       int64 `json:"alerts"`
+	Occurrences  int64 `json:"occurrences"`
+	Acknowledged int64 `json:"acknowledged"`
+	Escalated    int64 `json:"escalated"`
+	// AutoResolved counts alerts resolved by their source or the agent
+	// within 10 minutes of firing.
+	AutoResolved int64 `json:"auto_resolved"`
+	// AckedWithoutAction counts alerts that were acknowledged but then
+	// resolved by their source or the agent rather than by a person.
+	AckedWithoutAction int64            `json:"acked_without_action"`
+	MedianResolve      *float64         `json:"median_resolve_seconds"`
+	Recommendations    []Recommendation `json:"recommendations"`
+}
+
+// NoiseReport lists the noisiest alerts of a period by kind of noise.
+type NoiseReport struct {
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Alerts int64     `json:"alerts"`
+	// Flapping alerts fire again and again after resolving.
+	Flapping []NoisyAlert `json:"flapping"`
+	// NeverAcked alerts keep firing but nobody acknowledges them.
+	NeverAcked []NoisyAlert `json:"never_acked"`
+	// AutoResolving alerts almost always clear by themselves within minutes.
+	AutoResolving []NoisyAlert `json:"auto_resolving"`
+	// DuplicateHeavy alerts are re-sent many times while open.
+	DuplicateHeavy []NoisyAlert `json:"duplicate_heavy"`
+}
+
+// Empty reports whether no alert made any list.
+func (r NoiseReport) Empty() bool {
+	return len(r.Flapping)+len(r.NeverAcked)+len(r.AutoResolving)+len(r.DuplicateHeavy) == 0
+}
+
+// BuildNoiseReport sorts fingerprint profiles into the report's lists,
+// keeping the top limit of each with recommendations for that kind of noise.
+func BuildNoiseReport(from, to time.Time, total int64, profiles []NoisyAlert, limit int) NoiseReport {
+	report := NoiseReport{From: from, To: to, Alerts: total}
+
+	pick := func(match func(NoisyAlert) bool, rank func(NoisyAlert) int64, recommend func(NoisyAlert) []Recommendation) []NoisyAlert {
+		var out []NoisyAlert
+		for _, p := range profiles {
+			if match(p) {
+				out = append(out, p)
+			}
+		}
+		slices.SortStableFunc(out, func(a, b NoisyAlert) int {
+			return cmp.Or(cmp.Compare(rank(b), rank(a)), cmp.Compare(a.Fingerprint, b.Fingerprint))
+		})
+		out = out[:min(len(out), limit)]
+		for i := range out {
+			out[i].Recommendations = recommend(out[i])
+		}
+		return slices.Clip(out)
+	}
+
+	report.Flapping = pick(
+		func(p NoisyAlert) bool { return p.Alerts >= flappingMinAlerts },
+		func(p NoisyAlert) int64 { return p.Alerts },
+		recommendFlapping)
+	report.NeverAcked = pick(
+		func(p NoisyAlert) bool { return p.Alerts >= neverAckedMinAlerts && p.Acknowledged == 0 },
+		func(p NoisyAlert) int64 { return p.Alerts },
+		recommendNeverAcked)
+	report.AutoResolving = pick(
+		func(p NoisyAlert) bool {
+			return p.AutoResolved >= autoResolveMinAlerts && float64(p.AutoResolved) >= autoResolveMinShare*float64(p.Alerts)
+		},
+		func(p NoisyAlert) int64 { return p.AutoResolved },
+		recommendAutoResolving)
+	report.DuplicateHeavy = pick(
+		func(p NoisyAlert) bool { return p.Alerts > 0 && p.Occurrences >= duplicateMinRatio*p.Alerts },
+		func(p NoisyAlert) int64 { return p.Occurrences },
+		recommendDuplicates)
+	return report
+}
+
+func recommendFlapping(p NoisyAlert) []Recommendation {
+	rec := groupingRule(p, fmt.Sprintf(
+		"Fired %d times in the period. A grouping rule keeps the re-fires in one group; a longer pending period at the source stops the flapping.",
+		p.Alerts))
+	return []Recommendation{rec}
+}
+
+func recommendNeverAcked(p NoisyAlert) []Recommendation {
+	if lower := lowerSeverity(p.Severity); lower != "" {
+		return []Recommendation{{
+			Action:   ActionDowngradeSeverity,
+			Severity: lower,
+			Reason:   fmt.Sprintf("Fired %d times and was never acknowledged, so it does not seem to need a %s response.", p.Alerts, p.Severity),
+		}}
+	}
+	return []Recommendation{silence(p, fmt.Sprintf(
+		"Fired %d times at the lowest severity and was never acknowledged. Silence it at the source or remove the rule.", p.Alerts))}
+}
+
+func recommendAutoResolving(p NoisyAlert) []Recommendation {
+	reason := fmt.Sprintf("%d of %d alerts cleared by themselves within %d minutes.",
+		p.AutoResolved, p.Alerts, int(autoResolveWindow.Minutes()))
+	if p.AckedWithoutAction > 0 {
+		reason += fmt.Sprintf(" %d were acknowledged but resolved without anyone acting.", p.AckedWithoutAction)
+	}
+	if lower := lowerSeverity(p.Severity); lower != "" && p.Severity != "warning" {
+		return []Recommendation{{Action: ActionDowngradeSeverity, Severity: lower, Reason: reason}}
+	}
+	return []Recommendation{silence(p, reason+" Silence it at the source or raise its threshold.")}
+}
+
+func recommendDuplicates(p NoisyAlert) []Recommendation {
+	return []Recommendation{groupingRule(p, fmt.Sprintf(
+		"Received %d times for %d alerts. A grouping rule folds the repeats and related alerts into one notification; a longer repeat interval at the source sends fewer.",
+		p.Occurrences, p.Alerts))}
+}
+
+// matchers selects the alert by its identifying labels.
+func matchers(p NoisyAlert) []alertgroup.Matcher {
+	var out []alertgroup.Matcher
+	for _, key := range identifyingLabels {
+		if v, ok := p.Labels[key]; ok && v != "" {
+			out = append(out, alertgroup.Matcher{Key: key, Op: "=", Value: v})
+		}
+	}
+	return out
+}
+
+func groupingRule(p NoisyAlert, reason string) Recommendation {
+	rec := Recommendation{Action: ActionGroupingRule, Reason: reason}
+	m := matchers(p)
+	if len(m) == 0 {
+		return rec
+	}
+	groupBy := make([]string, len(m))
+	for i, matcher := range m {
+		groupBy[i] = matcher.Key
+	}
+	rec.GroupingRule = &alertgroup.CreateRuleRequest{
+		Name:     "Group " + messaging.Truncate(p.Title, 60),
+		Matchers: m[:1],
+		GroupBy:  groupBy,
+	}
+	return rec
+}
+
+func silence(p NoisyAlert, reason string) Recommendation {
+	return Recommendation{Action: ActionSilence, Reason: reason, Matchers: matchers(p)}
+}
+
+// lowerSeverity returns the next severity down, or "" at the lowest.
+func lowerSeverity(s string) string {
+	if i := slices.Index(severities, s); i > 0 {
+		return severities[i-1]
+	}
+	return ""
+}
+
+// Message renders the report for posting through a messaging provider.
+func (r NoiseReport) Message(channelID string) messaging.ReportMessage {
+	msg := messaging.ReportMessage{
+		Title: fmt.Sprintf("Alert noise report: %s – %s", r.From.Format("Jan 02"), r.To.Format("Jan 02, 2006")),
+		Summary: fmt.Sprintf("%d alerts fired. %d flapping, %d never acknowledged, %d auto-resolving, %d duplicate-heavy.",
+			r.Alerts, len(r.Flapping), len(r.NeverAcked), len(r.AutoResolving), len(r.DuplicateHeavy)),
+		ChannelID: channelID,
+	}
+	for _, list := range []struct {
+		title string
+		items []NoisyAlert
+	}{
+		{"Flapping", r.Flapping},
+		{"Never acknowledged", r.NeverAcked},
+		{"Auto-resolving within minutes", r.AutoResolving},
+		{"Duplicate-heavy", r.DuplicateHeavy},
+	} {
+		if len(list.items) == 0 {
+			continue
+		}
+		lines := make([]string, len(list.items))
+		for i, a := range list.items {
+			lines[i] = fmt.Sprintf("• %s (%s, %s): %s", messaging.Truncate(a.Title, 80), a.Source, a.Severity, suggestion(a))
+		}
+		msg.Sections = append(msg.Sections, messaging.ResponseSection{Title: list.title, Body: strings.Join(lines, "\n")})
+	}
+	return msg
+}
+
+// suggestion is a one-line summary of an alert's first recommendation.
+func suggestion(a NoisyAlert) string {
+	if len(a.Recommendations) == 0 {
+		return ""
+	}
+	rec := a.Recommendations[0]
+	switch rec.Action {
+	case ActionGroupingRule:
+		return "add a grouping rule. " + rec.Reason
+	case ActionDowngradeSeverity:
+		return "downgrade to " + rec.Severity + ". " + rec.Reason
+	default:
+		return rec.Reason
+	}
+}
@@ -0,0 +1,147 @@
+package analytics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func noisyProfiles() []NoisyAlert {
+	return []NoisyAlert{
+		// Flaps and nobody acks it.
+		{Fingerprint: "disk", Title: "Disk almost full", Source: "alertmanager", Severity: "critical",
+			Labels: map[string]string{"alertname": "DiskFull", "namespace": "db"},
+			Alerts: 12, Occurrences: 14},
+		// Clears by itself, sometimes after an ack.
+		{Fingerprint: "latency", Title: "High latency", Source: "grafana", Severity: "warning",
+			Labels: map[string]string{"alertname": "HighLatency"},
+			Alerts: 4, Occurrences: 4, Acknowledged: 2, AutoResolved: 4, AckedWithoutAction: 2},
+		// Re-sent constantly while open.
+		{Fingerprint: "cert", Title: "Certificate expires soon", Source: "generic", Severity: "info",
+			Alerts: 1, Occurrences: 48},
+		// Quiet and handled: not listed anywhere.
+		{Fingerprint: "oom", Title: "Pod OOMKilled", Source: "alertmanager", Severity: "major",
+			Alerts: 2, Occurrences: 2, Acknowledged: 2},
+	}
+}
+
+func fingerprints(items []NoisyAlert) []string {
+	out := make([]string, len(items))
+	for i, a := range items {
+		out[i] = a.Fingerprint
+	}
+	return out
+}
+
+func TestBuildNoiseReport_Lists(t *testing.T) {
+	from, to := testNow.AddDate(0, 0, -7), testNow
+	r := BuildNoiseReport(from, to, 30, noisyProfiles(), 10)
+
+	for _, tt := range []struct {
+		name string
+		got  []NoisyAlert
+		want string
+	}{
+		{"flapping", r.Flapping, "disk"},
+		{"never acked", r.NeverAcked, "disk"},
+		{"auto resolving", r.AutoResolving, "latency"},
+		{"duplicate heavy", r.DuplicateHeavy, "cert"},
+	} {
+		if got := strings.Join(fingerprints(tt.got), ","); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+	if r.Empty() || r.Alerts != 30 || !r.From.Equal(from) {
+		t.Errorf("report = %+v", r)
+	}
+}
+
+func TestBuildNoiseReport_Limit(t *testing.T) {
+	profiles := []NoisyAlert{
+		{Fingerprint: "a", Alerts: 5, Acknowledged: 1},
+		{Fingerprint: "b", Alerts: 9, Acknowledged: 1},
+		{Fingerprint: "c", Alerts: 7, Acknowledged: 1},
+	}
+	r := BuildNoiseReport(testNow, testNow, 21, profiles, 2)
+	if got := strings.Join(fingerprints(r.Flapping), ","); got != "b,c" {
+		t.Errorf("flapping = %s", got)
+	}
+}
+
+func TestBuildNoiseReport_Recommendations(t *testing.T) {
+	r := BuildNoiseReport(testNow, testNow, 30, noisyProfiles(), 10)
+
+	flap := r.Flapping[0].Recommendations[0]
+	if flap.Action != ActionGroupingRule || flap.GroupingRule == nil {
+		t.Fatalf("flapping recommendation = %+v", flap)
+	}
+	if m := flap.GroupingRule.Matchers; len(m) != 1 || m[0].Key != "alertname" || m[0].Value != "DiskFull" {
+		t.Errorf("matchers = %+v", m)
+	}
+	if g := strings.Join(flap.GroupingRule.GroupBy, ","); g != "alertname,namespace" {
+		t.Errorf("group_by = %s", g)
+	}
+
+	if rec := r.NeverAcked[0].Recommendations[0]; rec.Action != ActionDowngradeSeverity || rec.Severity != "major" {
+		t.Errorf("never acked recommendation = %+v", rec)
+	}
+
+	auto := r.AutoResolving[0].Recommendations[0]
+	if auto.Action != ActionSilence || len(auto.Matchers) != 1 || !strings.Contains(auto.Reason, "2 were acknowledged") {
+		t.Errorf("auto resolving recommendation = %+v", auto)
+	}
+
+	// Without labels there is nothing to match on, so no draft rule.
+	if dup := r.DuplicateHeavy[0].Recommendations[0]; dup.Action != ActionGroupingRule || dup.GroupingRule != nil {
+		t.Errorf("duplicate recommendation = %+v", dup)
+	}
+}
+
+func TestNoiseReport_Message(t *testing.T) {
+	r := BuildNoiseReport(time.Date(2026, 3, 23, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC),
+		30, noisyProfiles(), 10)
+	msg := r.Message("C123")
+
+	if msg.Title != "Alert noise report: Mar 23 – Mar 30, 2026" || msg.ChannelID != "C123" {
+		t.Errorf("message = %+v", msg)
+	}
+	if !strings.Contains(msg.Summary, "1 flapping, 1 never acknowledged") {
+		t.Errorf("summary = %s", msg.Summary)
+	}
+	if len(msg.Sections) != 4 || msg.Sections[1].Title != "Never acknowledged" {
+		t.Fatalf("sections = %+v", msg.Sections)
+	}
+	if body := msg.Sections[1].Body; !strings.HasPrefix(body, "• Disk almost full (alertmanager, critical): downgrade to major.") {
+		t.Errorf("body = %s", body)
+	}
+}
+
+func TestWeekStart(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	tests := []struct {
+		at   time.Time
+		loc  *time.Location
+		want time.Time
+	}{
+		{time.Date(2026, 4, 1, 12, 0, 0, 0, time.UTC), time.UTC, time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC), time.UTC, time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 29, 23, 30, 0, 0, time.UTC), time.UTC, time.Date(2026, 3, 23, 0, 0, 0, 0, time.UTC)},
+		// Already Monday in Berlin.
+		{time.Date(2026, 3, 29, 23, 30, 0, 0, time.UTC), berlin, time.Date(2026, 3, 30, 0, 0, 0, 0, berlin)},
+	}
+	for _, tt := range tests {
+		if got := weekStart(tt.at, tt.loc); !got.Equal(tt.want) {
+			t.Errorf("weekStart(%s, %s) = %s, want %s", tt.at, tt.loc, got, tt.want)
+		}
+	}
+}
+
+func TestStringLabels(t *testing.T) {
+	labels := stringLabels([]byte(`{"alertname":"DiskFull","replicas":3}`))
+	if len(labels) != 1 || labels["alertname"] != "DiskFull" {
+		t.Errorf("labels = %v", labels)
+	}
+	if stringLabels([]byte(`{}`)) != nil {
+		t.Error("empty labels should be nil")
+	}
+}
@@ -0,0 +1,241 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/wisbric/nightowl/internal/db"
+	"github.com/wisbric/nightowl/pkg/messaging"
+	"github.com/wisbric/nightowl/pkg/tenant"
+	"github.com/wisbric/nightowl/pkg/tenantconfig"
+)
+
+// ErrReportNotFound is returned when a stored noise report does not exist.
+var ErrReportNotFound = errors.New("noise report not found")
+
+// noiseReportLimit is how many alerts each list of a weekly report keeps.
+const noiseReportLimit = 5
+
+// StoredReport is a weekly noise report and how it was delivered.
+type StoredReport struct {
+	ID            uuid.UUID    `json:"id"`
+	PeriodStart   time.Time    `json:"period_start"`
+	PeriodEnd     time.Time    `json:"period_end"`
+	DeliveredVia  *string      `json:"delivered_via,omitempty"`
+	DeliveredAt   *time.Time   `json:"delivered_at,omitempty"`
+	DeliveryError *string      `json:"delivery_error,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+	Report        *NoiseReport `json:"report,omitempty"`
+}
+
+const reportColumns = `id, period_start, period_end, delivered_via, delivered_at, delivery_error, created_at`
+
+func scanReport(row pgx.Row, extra ...any) (StoredReport, error) {
+	var r StoredReport
+	err := row.Scan(append([]any{&r.ID, &r.PeriodStart, &r.PeriodEnd, &r.DeliveredVia, &r.DeliveredAt,
+		&r.DeliveryError, &r.CreatedAt}, extra...)...)
+	return r, err
+}
+
+// ListReports returns the latest stored weekly reports without their bodies.
+func (s *Store) ListReports(ctx context.Context, limit int) ([]StoredReport, error) {
+	rows, err := s.dbtx.Query(ctx, `SELECT `+reportColumns+` FROM noise_reports
+		ORDER BY period_end DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("listing noise reports: %w", err)
+	}
+	defer rows.Close()
+
+	items := []StoredReport{}
+	for rows.Next() {
+		r, err := scanReport(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning noise report: %w", err)
+		}
+		items = append(items, r)
+	}
+	return items, rows.Err()
+}
+
+// GetReport returns a stored weekly report with its body.
+func (s *Store) GetReport(ctx context.Context, id uuid.UUID) (StoredReport, error) {
+	var body []byte
+	r, err := scanReport(s.dbtx.QueryRow(ctx, `SELECT `+reportColumns+`, report FROM noise_reports WHERE id = $1`, id), &body)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return StoredReport{}, ErrReportNotFound
+	}
+	if err != nil {
+		return StoredReport{}, fmt.Errorf("getting noise report: %w", err)
+	}
+	r.Report = &NoiseReport{}
+	if err := json.Unmarshal(body, r.Report); err != nil {
+		return StoredReport{}, fmt.Errorf("decoding noise report: %w", err)
+	}
+	return r, nil
+}
+
+// saveReport stores the report for its period. It returns false when the
+// period already has one, for example from another worker.
+func (s *Store) saveReport(ctx context.Context, report NoiseReport) (uuid.UUID, bool, error) {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("encoding noise report: %w", err)
+	}
+	var id uuid.UUID
+	err = s.dbtx.QueryRow(ctx, `INSERT INTO noise_reports (period_start, period_end, report)
+		VALUES ($1, $2, $3) ON CONFLICT (period_end) DO NOTHING RETURNING id`,
+		report.From, report.To, body).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, false, nil
+	}
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("storing noise report: %w", err)
+	}
+	return id, true, nil
+}
+
+func (s *Store) recordDelivery(ctx context.Context, id uuid.UUID, provider string, deliveryErr error) error {
+	var errText *string
+	if deliveryErr != nil {
+		msg := deliveryErr.Error()
+		errText = &msg
+	}
+	_, err := s.dbtx.Exec(ctx, `UPDATE noise_reports
+		SET delivered_via = $2, delivered_at = CASE WHEN $3::text IS NULL THEN now() END, delivery_error = $3
+		WHERE id = $1`, id, provider, errText)
+	if err != nil {
+		return fmt.Errorf("recording noise report delivery: %w", err)
+	}
+	return nil
+}
+
+// weekStart returns the Monday 00:00 in loc at or before t.
+func weekStart(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, loc)
+}
+
+// NoiseReporter writes each tenant a noise report for every week, Monday to
+// Monday in the tenant's default timezone. Reports are kept for the API and
+// posted through the tenant's messaging provider when anything is noisy.
+type NoiseReporter struct {
+	pool     *pgxpool.Pool
+	registry *messaging.Registry
+	configs  *tenantconfig.Service
+	logger   *slog.Logger
+}
+
+// NewNoiseReporter creates a NoiseReporter posting through the registry's providers.
+func NewNoiseReporter(pool *pgxpool.Pool, registry *messaging.Registry, logger *slog.Logger) *NoiseReporter {
+	return &NoiseReporter{
+		pool:     pool,
+		registry: registry,
+		configs:  tenantconfig.NewService(pool, logger),
+		logger:   logger,
+	}
+}
+
+// Run checks for due reports at start and then every interval until ctx is
+// cancelled.
+func (n *NoiseReporter) Run(ctx context.Context, interval time.Duration) {
+	n.logger.Info("noise report loop started", "interval", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n.reportAll(ctx)
+		select {
+		case <-ctx.Done():
+			n.logger.Info("noise report loop stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (n *NoiseReporter) reportAll(ctx context.Context) {
+	tenants, err := db.New(n.pool).ListTenants(ctx)
+	if err != nil {
+		n.logger.Error("listing tenants for noise reports", "error", err)
+		return
+	}
+	for _, t := range tenants {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := n.reportTenant(ctx, t.ID, t.Slug); err != nil {
+			n.logger.Error("noise report failed for tenant", "tenant", t.Slug, "error", err)
+		}
+	}
+}
+
+func (n *NoiseReporter) reportTenant(ctx context.Context, tenantID uuid.UUID, slug string) error {
+	cfg, err := n.configs.Get(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	loc := time.UTC
+	if cfg.DefaultTimezone != "" {
+		if l, err := time.LoadLocation(cfg.DefaultTimezone); err == nil {
+			loc = l
+		}
+	}
+	end := weekStart(time.Now(), loc)
+	q := Query{From: end.AddDate(0, 0, -7), To: end, Limit: noiseReportLimit}
+
+	// Tenants suspended or deleted since they were listed are skipped.
+	ctx, release, err := tenant.Acquire(ctx, n.pool, slug)
+	if errors.Is(err, tenant.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer release()
+	conn := tenant.ConnFromContext(ctx)
+	store := NewStore(conn)
+
+	var exists bool
+	if err := conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM noise_reports WHERE period_end = $1)`, end).Scan(&exists); err != nil {
+		return fmt.Errorf("checking noise reports: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	report, err := store.NoiseReport(ctx, q)
+	if err != nil {
+		return err
+	}
+	id, created, err := store.saveReport(ctx, report)
+	if err != nil || !created {
+		return err
+	}
+	n.logger.Info("noise report created", "tenant", slug, "period_end", end, "empty", report.Empty())
+
+	if report.Empty() || cfg.MessagingProvider == "none" {
+		return nil
+	}
+	provider, err := n.registry.Get(cfg.MessagingProvider)
+	if err != nil {
+		return store.recordDelivery(ctx, id, cfg.MessagingProvider, err)
+	}
+	channel := cfg.SlackChannel
+	if cfg.MessagingProvider == "mattermost" {
+		channel = cfg.MattermostDefaultChannelID
+	}
+	sendErr := provider.PostReport(ctx, report.Message(channel))
+	if sendErr != nil {
+		n.logger.Warn("posting noise report", "tenant", slug, "provider", cfg.MessagingProvider, "error", sendErr)
+	}
+	return store.recordDelivery(ctx, id, cfg.MessagingProvider, sendErr)
+}
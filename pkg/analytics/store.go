@@ -0,0 +1,384 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wisbric/nightowl/internal/db"
+)
+
+// Store runs analytics queries against a tenant schema.
+type Store struct {
+	dbtx db.DBTX
+}
+
+// NewStore creates an analytics Store backed by the given database connection.
+func NewStore(dbtx db.DBTX) *Store {
+	return &Store{dbtx: dbtx}
+}
+
+// escalatedJoin marks each alert that has been escalated at least once.
+const escalatedJoin = `CROSS JOIN LATERAL (
+	SELECT EXISTS (SELECT 1 FROM escalation_events e WHERE e.alert_id = a.id) AS escalated) x`
+
+// metricColumns computes Metrics over the alerts of a group, in Metrics.dest order.
+const metricColumns = `count(*), count(a.acknowledged_at), count(a.resolved_at),
+	count(*) FILTER (WHERE x.escalated),
+	avg(EXTRACT(EPOCH FROM a.acknowledged_at - a.first_fired_at))::float8,
+	percentile_cont(0.9) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM a.acknowledged_at - a.first_fired_at)::float8),
+	avg(EXTRACT(EPOCH FROM a.resolved_at - a.first_fired_at))::float8,
+	percentile_cont(0.9) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM a.resolved_at - a.first_fired_at)::float8)`
+
+func (m *Metrics) dest() []any {
+	return []any{&m.Alerts, &m.Acknowledged, &m.Resolved, &m.Escalated, &m.MTTA, &m.MTTAP90, &m.MTTR, &m.MTTRP90}
+}
+
+func (m *Metrics) finish() {
+	if m.Alerts > 0 {
+		m.EscalationRate = float64(m.Escalated) / float64(m.Alerts)
+	}
+}
+
+// dimension is how a breakdown dimension is keyed, labelled and joined.
+type dimension struct {
+	key, label, join string
+}
+
+var dimensions = map[string]dimension{
+	ByService: {
+		key:   "a.service_id::text",
+		label: "COALESCE(s.name, '')",
+		join:  "LEFT JOIN services s ON s.id = a.service_id",
+	},
+	BySource:   {key: "a.source", label: "a.source"},
+	BySeverity: {key: "a.severity", label: "a.severity"},
+	// An alert counts once for every roster bound to its escalation policy.
+	ByRoster: {
+		key:   "r.id::text",
+		label: "COALESCE(r.name, '')",
+		join:  "LEFT JOIN rosters r ON r.escalation_policy_id = a.escalation_policy_id",
+	},
+	ByResponder: {
+		key:   "COALESCE(a.acknowledged_by, a.resolved_by)::text",
+		label: "COALESCE(u.display_name, '')",
+		join:  "LEFT JOIN users u ON u.id = COALESCE(a.acknowledged_by, a.resolved_by)",
+	},
+}
+
+// filters renders the alert filters as SQL conditions on alias a, appending
+// their arguments to args. The responder filter is left to the caller.
+func (q Query) filters(args []any) ([]string, []any) {
+	var conditions []string
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+	if q.ServiceID != nil {
+		add("a.service_id = $%d", *q.ServiceID)
+	}
+	if len(q.Sources) > 0 {
+		add("a.source = ANY($%d)", q.Sources)
+	}
+	if len(q.Severities) > 0 {
+		add("a.severity = ANY($%d)", q.Severities)
+	}
+	if q.RosterID != nil {
+		add("a.escalation_policy_id IN (SELECT escalation_policy_id FROM rosters WHERE id = $%d)", *q.RosterID)
+	}
+	return conditions, args
+}
+
+// where selects the alerts that first fired in range and match the filters.
+func (q Query) where() (string, []any) {
+	conditions, args := q.filters([]any{q.From, q.To})
+	conditions = append([]string{"a.first_fired_at >= $1", "a.first_fired_at < $2"}, conditions...)
+	if q.ResponderID != nil {
+		args = append(args, *q.ResponderID)
+		conditions = append(conditions, fmt.Sprintf("COALESCE(a.acknowledged_by, a.resolved_by) = $%d", len(args)))
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// summarySQL returns the totals query, or the breakdown query for a dimension.
+func (q Query) summarySQL(groupBy string) (string, []any) {
+	where, args := q.where()
+	if groupBy == "" {
+		return fmt.Sprintf("SELECT %s FROM alerts a %s WHERE %s", metricColumns, escalatedJoin, where), args
+	}
+	d := dimensions[groupBy]
+	return fmt.Sprintf(`SELECT %s, %s, %s FROM alerts a %s %s WHERE %s
+		GROUP BY 1, 2 ORDER BY 3 DESC, 2`,
+		d.key, d.label, metricColumns, escalatedJoin, d.join, where), args
+}
+
+// Summary computes the response metrics of the matching alerts, broken down
+// by q.GroupBy when set.
+func (s *Store) Summary(ctx context.Context, q Query) (Summary, error) {
+	out := Summary{From: q.From, To: q.To, GroupBy: q.GroupBy}
+
+	sql, args := q.summarySQL("")
+	if err := s.dbtx.QueryRow(ctx, sql, args...).Scan(out.Totals.dest()...); err != nil {
+		return Summary{}, fmt.Errorf("computing totals: %w", err)
+	}
+	out.Totals.finish()
+	if q.GroupBy == "" {
+		return out, nil
+	}
+
+	sql, args = q.summarySQL(q.GroupBy)
+	rows, err := s.dbtx.Query(ctx, sql, args...)
+	if err != nil {
+		return Summary{}, fmt.Errorf("computing breakdown by %s: %w", q.GroupBy, err)
+	}
+	defer rows.Close()
+	out.Groups = []Group{}
+	for rows.Next() {
+		var g Group
+		if err := rows.Scan(append([]any{&g.Key, &g.Label}, g.Metrics.dest()...)...); err != nil {
+			return Summary{}, fmt.Errorf("scanning breakdown: %w", err)
+		}
+		g.finish()
+		out.Groups = append(out.Groups, g)
+	}
+	return out, rows.Err()
+}
+
+// Volume counts the matching alerts per interval and severity. Buckets start
+// at interval boundaries in q.Location; empty buckets are omitted.
+func (s *Store) Volume(ctx context.Context, q Query) ([]Bucket, error) {
+	where, args := q.where()
+	args = append(args, q.Interval, q.Location.String())
+	interval, zone := len(args)-1, len(args)
+	sql := fmt.Sprintf(`SELECT date_trunc($%[1]d, a.first_fired_at AT TIME ZONE $%[2]d) AT TIME ZONE $%[2]d, a.severity,
+		count(*), count(a.acknowledged_at), count(*) FILTER (WHERE x.escalated)
+		FROM alerts a %[3]s WHERE %[4]s
+		GROUP BY 1, 2 ORDER BY 1, 2`, interval, zone, escalatedJoin, where)
+
+	rows, err := s.dbtx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("computing alert volume: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := []Bucket{}
+	for rows.Next() {
+		var (
+			start                       time.Time
+			severity                    string
+			alerts, acknowledged, escal int64
+		)
+		if err := rows.Scan(&start, &severity, &alerts, &acknowledged, &escal); err != nil {
+			return nil, fmt.Errorf("scanning alert volume: %w", err)
+		}
+		if n := len(buckets); n == 0 || !buckets[n-1].Start.Equal(start) {
+			buckets = append(buckets, Bucket{Start: start.In(q.Location), BySeverity: map[string]int64{}})
+		}
+		b := &buckets[len(buckets)-1]
+		b.Alerts += alerts
+		b.Acknowledged += acknowledged
+		b.Escalated += escal
+		b.BySeverity[severity] += alerts
+	}
+	return buckets, rows.Err()
+}
+
+// NoisyFingerprints returns the fingerprints with the most occurrences among
+// the matching alerts. Title, source and service come from the latest alert.
+func (s *Store) NoisyFingerprints(ctx context.Context, q Query) ([]Fingerprint, error) {
+	where, args := q.where()
+	args = append(args, q.Limit)
+	sql := fmt.Sprintf(`WITH f AS (
+			SELECT a.fingerprint,
+				(array_agg(a.title ORDER BY a.last_fired_at DESC))[1] AS title,
+				(array_agg(a.source ORDER BY a.last_fired_at DESC))[1] AS source,
+				(array_agg(a.service_id ORDER BY a.last_fired_at DESC))[1] AS service_id,
+				count(*) AS alerts, sum(a.occurrence_count) AS occurrences,
+				count(a.acknowledged_at) AS acknowledged, count(*) FILTER (WHERE x.escalated) AS escalated,
+				avg(EXTRACT(EPOCH FROM a.acknowledged_at - a.first_fired_at))::float8 AS mtta,
+				max(a.last_fired_at) AS last_fired_at
+			FROM alerts a %s WHERE %s
+			GROUP BY a.fingerprint
+			ORDER BY occurrences DESC, alerts DESC, a.fingerprint
+			LIMIT $%d)
+		SELECT f.fingerprint, f.title, f.source, f.service_id, s.name, f.alerts, f.occurrences,
+			f.acknowledged, f.escalated, f.mtta, f.last_fired_at
+		FROM f LEFT JOIN services s ON s.id = f.service_id
+		ORDER BY f.occurrences DESC, f.alerts DESC, f.fingerprint`, escalatedJoin, where, len(args))
+
+	rows, err := s.dbtx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("computing noisy fingerprints: %w", err)
+	}
+	defer rows.Close()
+
+	items := []Fingerprint{}
+	for rows.Next() {
+		var f Fingerprint
+		var serviceID pgtype.UUID
+		if err := rows.Scan(&f.Fingerprint, &f.Title, &f.Source, &serviceID, &f.ServiceName, &f.Alerts,
+			&f.Occurrences, &f.Acknowledged, &f.Escalated, &f.MTTA, &f.LastFiredAt); err != nil {
+			return nil, fmt.Errorf("scanning noisy fingerprints: %w", err)
+		}
+		if serviceID.Valid {
+			id := uuid.UUID(serviceID.Bytes)
+			f.ServiceID = &id
+		}
+		items = append(items, f)
+	}
+	return items, rows.Err()
+}
+
+// respondersSQL builds the per-person load query. Pages, acknowledgements and
+// resolutions are matched by when they happened; the alert filters apply to
+// the alerts behind them. Users with an unknown timezone are treated as UTC.
+func (q Query) respondersSQL() (string, []any) {
+	conditions, args := q.filters([]any{q.From, q.To})
+	filter := ""
+	if len(conditions) > 0 {
+		filter = " AND " + strings.Join(conditions, " AND ")
+	}
+	people := "p.user_id IS NOT NULL OR k.user_id IS NOT NULL OR rs.user_id IS NOT NULL"
+	if q.ResponderID != nil {
+		args = append(args, *q.ResponderID)
+		people = fmt.Sprintf("(%s) AND zn.id = $%d", people, len(args))
+	}
+
+	sql := fmt.Sprintf(`WITH zn AS (
+			SELECT u.id, u.display_name, u.timezone, COALESCE(z.name, 'UTC') AS zone
+			FROM users u LEFT JOIN pg_timezone_names z ON z.name = u.timezone),
+		p AS (
+			SELECT e.target_user_id AS user_id, count(*) AS pages,
+				count(*) FILTER (WHERE EXTRACT(ISODOW FROM e.created_at AT TIME ZONE zn.zone) > 5
+					OR (e.created_at AT TIME ZONE zn.zone)::time < '%[1]s'
+					OR (e.created_at AT TIME ZONE zn.zone)::time >= '%[2]s') AS after_hours
+			FROM escalation_events e
+			JOIN alerts a ON a.id = e.alert_id
+			JOIN zn ON zn.id = e.target_user_id
+			WHERE e.created_at >= $1 AND e.created_at < $2%[3]s
+			GROUP BY e.target_user_id),
+		k AS (
+			SELECT a.acknowledged_by AS user_id, count(*) AS acknowledged,
+				avg(EXTRACT(EPOCH FROM a.acknowledged_at - a.first_fired_at))::float8 AS mtta
+			FROM alerts a
+			WHERE a.acknowledged_by IS NOT NULL AND a.acknowledged_at >= $1 AND a.acknowledged_at < $2%[3]s
+			GROUP BY a.acknowledged_by),
+		rs AS (
+			SELECT a.resolved_by AS user_id, count(*) AS resolved
+			FROM alerts a
+			WHERE a.resolved_by IS NOT NULL AND a.resolved_at >= $1 AND a.resolved_at < $2%[3]s
+			GROUP BY a.resolved_by)
+		SELECT zn.id, zn.display_name, zn.timezone, COALESCE(p.pages, 0), COALESCE(p.after_hours, 0),
+			COALESCE(k.acknowledged, 0), COALESCE(rs.resolved, 0), k.mtta
+		FROM zn
+		LEFT JOIN p ON p.user_id = zn.id
+		LEFT JOIN k ON k.user_id = zn.id
+		LEFT JOIN rs ON rs.user_id = zn.id
+		WHERE %[4]s
+		ORDER BY 4 DESC, 5 DESC, 6 DESC, zn.display_name`, workdayStart, workdayEnd, filter, people)
+	return sql, args
+}
+
+// Responders returns the on-call load of everyone who was paged, acknowledged
+// or resolved a matching alert in range, busiest first.
+func (s *Store) Responders(ctx context.Context, q Query) ([]Responder, error) {
+	sql, args := q.respondersSQL()
+	rows, err := s.dbtx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("computing responder load: %w", err)
+	}
+	defer rows.Close()
+
+	items := []Responder{}
+	for rows.Next() {
+		var r Responder
+		if err := rows.Scan(&r.UserID, &r.DisplayName, &r.Timezone, &r.Pages, &r.AfterHoursPages,
+			&r.Acknowledged, &r.Resolved, &r.MTTA); err != nil {
+			return nil, fmt.Errorf("scanning responder load: %w", err)
+		}
+		items = append(items, r)
+	}
+	return items, rows.Err()
+}
+
+// NoiseReport profiles the fingerprints of the matching alerts and lists the
+// noisiest of each kind, q.Limit per list.
+func (s *Store) NoiseReport(ctx context.Context, q Query) (NoiseReport, error) {
+	where, args := q.where()
+
+	var total int64
+	if err := s.dbtx.QueryRow(ctx, "SELECT count(*) FROM alerts a WHERE "+where, args...).Scan(&total); err != nil {
+		return NoiseReport{}, fmt.Errorf("counting alerts: %w", err)
+	}
+
+	args = append(args, autoResolveWindow.Seconds(), noiseMinAlerts, duplicateMinRatio)
+	window, minAlerts, ratio := len(args)-2, len(args)-1, len(args)
+	sql := fmt.Sprintf(`WITH f AS (
+			SELECT a.fingerprint,
+				(array_agg(a.title ORDER BY a.last_fired_at DESC))[1] AS title,
+				(array_agg(a.source ORDER BY a.last_fired_at DESC))[1] AS source,
+				(array_agg(a.severity ORDER BY a.last_fired_at DESC))[1] AS severity,
+				(array_agg(a.service_id ORDER BY a.last_fired_at DESC))[1] AS service_id,
+				(array_agg(a.labels ORDER BY a.last_fired_at DESC))[1] AS labels,
+				count(*) AS alerts, sum(a.occurrence_count) AS occurrences,
+				count(a.acknowledged_at) AS acknowledged, count(*) FILTER (WHERE x.escalated) AS escalated,
+				count(*) FILTER (WHERE a.resolved_at < a.first_fired_at + $%[3]d * interval '1 second'
+					AND (a.resolved_by IS NULL OR COALESCE(a.resolved_by_agent, false))) AS auto_resolved,
+				count(*) FILTER (WHERE a.acknowledged_at IS NOT NULL AND a.resolved_at IS NOT NULL
+					AND (a.resolved_by IS NULL OR COALESCE(a.resolved_by_agent, false))) AS acked_without_action,
+				percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM a.resolved_at - a.first_fired_at)::float8) AS median_resolve
+			FROM alerts a %[1]s WHERE %[2]s
+			GROUP BY a.fingerprint
+			HAVING count(*) >= $%[4]d OR sum(a.occurrence_count) >= $%[5]d * count(*))
+		SELECT f.fingerprint, f.title, f.source, f.severity, f.service_id, s.name, f.labels, f.alerts,
+			f.occurrences, f.acknowledged, f.escalated, f.auto_resolved, f.acked_without_action, f.median_resolve
+		FROM f LEFT JOIN services s ON s.id = f.service_id`, escalatedJoin, where, window, minAlerts, ratio)
+
+	rows, err := s.dbtx.Query(ctx, sql, args...)
+	if err != nil {
+		return NoiseReport{}, fmt.Errorf("profiling alert noise: %w", err)
+	}
+	defer rows.Close()
+
+	var profiles []NoisyAlert
+	for rows.Next() {
+		var p NoisyAlert
+		var serviceID pgtype.UUID
+		var labels []byte
+		if err := rows.Scan(&p.Fingerprint, &p.Title, &p.Source, &p.Severity, &serviceID, &p.ServiceName, &labels,
+			&p.Alerts, &p.Occurrences, &p.Acknowledged, &p.Escalated, &p.AutoResolved, &p.AckedWithoutAction,
+			&p.MedianResolve); err != nil {
+			return NoiseReport{}, fmt.Errorf("scanning alert noise: %w", err)
+		}
+		if serviceID.Valid {
+			id := uuid.UUID(serviceID.Bytes)
+			p.ServiceID = &id
+		}
+		p.Labels = stringLabels(labels)
+		profiles = append(profiles, p)
+	}
+	if err := rows.Err(); err != nil {
+		return NoiseReport{}, err
+	}
+	return BuildNoiseReport(q.From, q.To, total, profiles, q.Limit), nil
+}
+
+// stringLabels decodes alert labels, keeping the string values.
+func stringLabels(raw []byte) map[string]string {
+	var labels map[string]any
+	if err := json.Unmarshal(raw, &labels); err != nil || len(labels) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(labels))
+	for k, v := range labels {
+		if s, ok := v.(string); ok {
+			out[k] = s
+		}
+	}
+	return out
+}
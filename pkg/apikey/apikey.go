@@ -7,22 +7,29 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-// CreateRequest is the JSON body for POST /api/v1/apikeys.
+// CreateRequest is the JSON body for POST /api/v1/apikeys. Scopes limit
+// what the key may call (see ValidScopes) and at least one is required;
+// ScopeAdmin asks for everything the role allows. AllowedCIDRs restricts
+// the source addresses.
 type CreateRequest struct {
-	Description string `json:"description" validate:"required"`
-	Role        string `json:"role" validate:"required"`
+	Description  string     `json:"description" validate:"required"`
+	Role         string     `json:"role" validate:"required"`
+	Scopes       []string   `json:"scopes" validate:"min=1"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	AllowedCIDRs []string   `json:"allowed_cidrs"`
 }
 
 // Response is the JSON response for a single API key (without the raw key).
 type Response struct {
-	ID          uuid.UUID  `json:"id"`
-	KeyPrefix   string     `json:"key_prefix"`
-	Description string     `json:"description"`
-	Role        string     `json:"role"`
-	Scopes      []string   `json:"scopes"`
-	LastUsed    *time.Time `json:"last_used,omitempty"`
-	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
+	ID           uuid.UUID  `json:"id"`
+	KeyPrefix    string     `json:"key_prefix"`
+	Description  string     `json:"description"`
+	Role         string     `json:"role"`
+	Scopes       []string   `json:"scopes"`
+	AllowedCIDRs []string   `json:"allowed_cidrs"`
+	LastUsed     *time.Time `json:"last_used,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
 }
 
 // CreateResponse includes the raw key (only shown once at creation).
@@ -33,27 +40,29 @@ type CreateResponse struct {
 
 // ApiKeyRow represents a row returned from the public.api_keys table.
 type ApiKeyRow struct {
-	ID          uuid.UUID
-	TenantID    uuid.UUID
-	KeyHash     string
-	KeyPrefix   string
-	Description string
-	Role        string
-	Scopes      []string
-	LastUsed    pgtype.Timestamptz
-	ExpiresAt   pgtype.Timestamptz
-	CreatedAt   time.Time
+	ID           uuid.UUID
+	TenantID     uuid.UUID
+	KeyHash      string
+	KeyPrefix    string
+	Description  string
+	Role         string
+	Scopes       []string
+	AllowedCIDRs []string
+	LastUsed     pgtype.Timestamptz
+	ExpiresAt    pgtype.Timestamptz
+	CreatedAt    time.Time
 }
 
 // ToResponse converts an ApiKeyRow to a Response DTO.
 func (r *ApiKeyRow) ToResponse() Response {
 	resp := Response{
-		ID:          r.ID,
-		KeyPrefix:   r.KeyPrefix,
-		Description: r.Description,
-		Role:        r.Role,
-		Scopes:      ensureSlice(r.Scopes),
-		CreatedAt:   r.CreatedAt,
+		ID:           r.ID,
+		KeyPrefix:    r.KeyPrefix,
+		Description:  r.Description,
+		Role:         r.Role,
+		Scopes:       ensureSlice(r.Scopes),
+		AllowedCIDRs: ensureSlice(r.AllowedCIDRs),
+		CreatedAt:    r.CreatedAt,
 	}
 	if r.LastUsed.Valid {
 		t := r.LastUsed.Time
@@ -38,6 +38,7 @@ func (h *Handler) Routes() chi.Router {
 	r := chi.NewRouter()
 	r.Post("/", h.handleCreate)
 	r.Get("/", h.handleList)
+	r.Get("/scopes", h.handleListScopes)
 	r.Delete("/{id}", h.handleDelete)
 	return r
 }
@@ -55,6 +56,10 @@ func (h *Handler) handleCreate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp, err := h.service.Create(r.Context(), id.TenantID, req)
+	if errors.Is(err, ErrInvalidScope) || errors.Is(err, ErrInvalidCIDR) || errors.Is(err, ErrExpiryInPast) {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
 	if err != nil {
 		h.logger.Error("creating api key", "error", err)
 		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to create api key")
@@ -62,7 +67,11 @@ func (h *Handler) handleCreate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if h.audit != nil {
-		detail, _ := json.Marshal(map[string]string{"description": resp.Description})
+		detail, _ := json.Marshal(map[string]any{
+			"description":   resp.Description,
+			"scopes":        resp.Scopes,
+			"allowed_cidrs": resp.AllowedCIDRs,
+		})
 		h.audit.LogFromRequest(r, "create", "api_key", resp.ID, detail)
 	}
 
@@ -89,6 +98,13 @@ func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (h *Handler) handleListScopes(w http.ResponseWriter, _ *http.Request) {
+	httpserver.Respond(w, http.StatusOK, map[string]any{
+		"scopes": ValidScopes,
+		"count":  len(ValidScopes),
+	})
+}
+
 func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request) {
 	keyID, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
@@ -0,0 +1,257 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/wisbric/core/pkg/auth"
+	"github.com/wisbric/core/pkg/httpserver"
+)
+
+// Resources group API routes for scope checks. A key needs
+// "<resource>:read" for safe methods and "<resource>:write" otherwise.
+const (
+	ResourceAlerts      = "alerts"
+	ResourceIncidents   = "incidents"
+	ResourceRosters     = "rosters"
+	ResourceEscalations = "escalations"
+	ResourceUsers       = "users"
+	// ResourceAudit is read-only except for SIEM sink management, which
+	// needs ScopeAdmin: a sink receives the whole audit trail.
+	ResourceAudit = "audit"
+	// ResourceEvents covers the live event stream; it is read-only.
+	ResourceEvents = "events"
+	// ResourceWebhooks covers alert ingestion; it needs ScopeWebhooksIngest.
+	ResourceWebhooks = "webhooks"
+	// ResourceAdmin covers key, token and tenant settings; it needs ScopeAdmin.
+	ResourceAdmin = "admin"
+)
+
+// Scopes with special meaning.
+const (
+	ScopeWebhooksIngest = "webhooks:ingest"
+	// ScopeAdmin grants every scope.
+	ScopeAdmin = "admin"
+	// scopeLegacyWildcard is what seeded keys held before scoping. Like no
+	// scopes at all, it leaves the key limited only by its role.
+	scopeLegacyWildcard = "*"
+)
+
+// ValidScopes lists every scope a key can be created with.
+var ValidScopes = []string{
+	"alerts:read", "alerts:write",
+	"incidents:read", "incidents:write",
+	"rosters:read", "rosters:write",
+	"escalations:read", "escalations:write",
+	"users:read", "users:write",
+	"audit:read",
+	"events:read",
+	ScopeWebhooksIngest,
+	ScopeAdmin,
+}
+
+var (
+	// ErrInvalidScope is returned when creating a key with an unknown scope.
+	ErrInvalidScope = errors.New("unknown scope")
+	// ErrInvalidCIDR is returned for allowlist entries that are neither an IP
+	// address nor a CIDR prefix.
+	ErrInvalidCIDR = errors.New("invalid IP address or CIDR")
+)
+
+// NormalizeScopes validates scopes and returns them sorted without duplicates.
+func NormalizeScopes(scopes []string) ([]string, error) {
+	out := make([]string, 0, len(scopes))
+	for _, s := range scopes {
+		s = strings.TrimSpace(s)
+		if !slices.Contains(ValidScopes, s) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, s)
+		}
+		if !slices.Contains(out, s) {
+			out = append(out, s)
+		}
+	}
+	slices.Sort(out)
+	return out, nil
+}
+
+// ParseCIDRs parses an allowlist. Bare addresses become single-host prefixes.
+func ParseCIDRs(entries []string) ([]netip.Prefix, error) {
+	out := make([]netip.Prefix, 0, len(entries))
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if p, err := netip.ParsePrefix(e); err == nil {
+			out = append(out, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(e)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidCIDR, e)
+		}
+		out = append(out, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return out, nil
+}
+
+// RequiredScope returns the scope a request with method needs on resource.
+func RequiredScope(resource, method string) string {
+	switch resource {
+	case ResourceWebhooks:
+		return ScopeWebhooksIngest
+	case ResourceAdmin:
+		return ScopeAdmin
+	case ResourceAudit:
+		if safeMethod(method) {
+			return "audit:read"
+		}
+		return ScopeAdmin
+	case ResourceEvents:
+		return "events:read"
+	}
+	if safeMethod(method) {
+		return resource + ":read"
+	}
+	return resource + ":write"
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// Allows reports whether a key holding granted may use required. Keys
+// without scopes, or with the old "*", predate scoping and are limited only
+// by their role; new keys must name their scopes. Write scopes include the
+// matching read scope.
+func Allows(granted []string, required string) bool {
+	if len(granted) == 0 || slices.Contains(granted, scopeLegacyWildcard) ||
+		slices.Contains(granted, ScopeAdmin) || slices.Contains(granted, required) {
+		return true
+	}
+	if resource, ok := strings.CutSuffix(required, ":read"); ok {
+		return slices.Contains(granted, resource+":write")
+	}
+	return false
+}
+
+// allowedFrom reports whether addr falls inside the allowlist. An empty
+// allowlist allows every address.
+func allowedFrom(cidrs []string, addr netip.Addr) bool {
+	if len(cidrs) == 0 {
+		return true
+	}
+	prefixes, err := ParseCIDRs(cidrs)
+	if err != nil || !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Enforcer applies API key scopes and source-IP allowlists to routes.
+// Requests authenticated any other way pass through untouched.
+type Enforcer struct {
+	keys           func(context.Context, uuid.UUID) (ApiKeyRow, error)
+	logger         *slog.Logger
+	trustedProxies []netip.Prefix
+}
+
+// NewEnforcer creates an Enforcer. X-Forwarded-For is only honoured for
+// requests arriving from trustedProxies.
+func NewEnforcer(pool *pgxpool.Pool, logger *slog.Logger, trustedProxies []netip.Prefix) *Enforcer {
+	return &Enforcer{keys: NewStore(pool).Get, logger: logger, trustedProxies: trustedProxies}
+}
+
+// Require returns middleware that rejects API keys lacking the scope for
+// resource, or calling from outside their allowlist.
+func (e *Enforcer) Require(resource string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := auth.FromContext(r.Context())
+			if id == nil || id.Method != auth.MethodAPIKey || id.APIKeyID == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key, err := e.keys(r.Context(), *id.APIKeyID)
+			if err != nil {
+				e.logger.Error("loading api key scopes", "api_key_id", *id.APIKeyID, "error", err)
+				httpserver.RespondError(w, http.StatusUnauthorized, "unauthorized", "invalid API key")
+				return
+			}
+
+			addr := ClientAddr(r, e.trustedProxies)
+			if !allowedFrom(key.AllowedCIDRs, addr) {
+				e.logger.Warn("api key used from disallowed address",
+					"key_prefix", key.KeyPrefix, "addr", addr)
+				httpserver.RespondError(w, http.StatusForbidden, "forbidden", "API key not allowed from this address")
+				return
+			}
+
+			required := RequiredScope(resource, r.Method)
+			if !Allows(key.Scopes, required) {
+				httpserver.RespondError(w, http.StatusForbidden, "insufficient_scope", "API key lacks scope "+required)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientAddr returns the caller's address. When the direct peer is a
+// trusted proxy, it walks X-Forwarded-For from the right and returns the
+// first address that is not itself a trusted proxy.
+func ClientAddr(r *http.Request, trusted []netip.Prefix) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	peer = peer.Unmap()
+	if !inPrefixes(trusted, peer) {
+		return peer
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = addr.Unmap()
+		if !inPrefixes(trusted, addr) {
+			return addr
+		}
+		peer = addr
+	}
+	return peer
+}
+
+func inPrefixes(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,223 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/wisbric/core/pkg/auth"
+	"github.com/wisbric/core/pkg/httpserver"
+)
+
+func TestNormalizeScopes(t *testing.T) {
+	got, err := NormalizeScopes([]string{"webhooks:ingest", " alerts:write", "webhooks:ingest"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"alerts:write", "webhooks:ingest"}; !slices.Equal(got, want) {
+		t.Errorf("NormalizeScopes = %v, want %v", got, want)
+	}
+
+	if _, err := NormalizeScopes([]string{"alerts:delete"}); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("error = %v, want ErrInvalidScope", err)
+	}
+
+	got, err = NormalizeScopes(nil)
+	if err != nil || got == nil || len(got) != 0 {
+		t.Errorf("NormalizeScopes(nil) = %v, %v; want empty slice", got, err)
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	got, err := ParseCIDRs([]string{"10.0.0.0/8", "192.168.1.7", "2001:db8::1", "10.1.2.3/16"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"10.0.0.0/8", "192.168.1.7/32", "2001:db8::1/128", "10.1.0.0/16"}
+	for i, p := range got {
+		if p.String() != want[i] {
+			t.Errorf("prefix %d = %s, want %s", i, p, want[i])
+		}
+	}
+
+	if _, err := ParseCIDRs([]string{"not-an-ip"}); !errors.Is(err, ErrInvalidCIDR) {
+		t.Errorf("error = %v, want ErrInvalidCIDR", err)
+	}
+}
+
+func TestRequiredScope(t *testing.T) {
+	tests := []struct {
+		resource, method, want string
+	}{
+		{ResourceAlerts, http.MethodGet, "alerts:read"},
+		{ResourceAlerts, http.MethodPost, "alerts:write"},
+		{ResourceIncidents, http.MethodHead, "incidents:read"},
+		{ResourceRosters, http.MethodDelete, "rosters:write"},
+		{ResourceWebhooks, http.MethodPost, ScopeWebhooksIngest},
+		{ResourceAudit, http.MethodGet, "audit:read"},
+		{ResourceAudit, http.MethodPost, ScopeAdmin},
+		{ResourceAudit, http.MethodDelete, ScopeAdmin},
+		{ResourceEvents, http.MethodGet, "events:read"},
+		{ResourceAdmin, http.MethodGet, ScopeAdmin},
+	}
+	for _, tt := range tests {
+		if got := RequiredScope(tt.resource, tt.method); got != tt.want {
+			t.Errorf("RequiredScope(%s, %s) = %s, want %s", tt.resource, tt.method, got, tt.want)
+		}
+		if got := RequiredScope(tt.resource, tt.method); !slices.Contains(ValidScopes, got) {
+			t.Errorf("RequiredScope(%s, %s) = %s is not a valid scope", tt.resource, tt.method, got)
+		}
+	}
+}
+
+func TestCreateRequest_RequiresScopes(t *testing.T) {
+	req := CreateRequest{Description: "Alertmanager", Role: "admin"}
+	if errs := httpserver.Validate(&req); len(errs) == 0 {
+		t.Error("expected a key without scopes to be rejected")
+	}
+	req.Scopes = []string{ScopeWebhooksIngest}
+	if errs := httpserver.Validate(&req); len(errs) > 0 {
+		t.Errorf("unexpected validation errors: %v", errs)
+	}
+}
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		name     string
+		granted  []string
+		required string
+		want     bool
+	}{
+		{"unscoped legacy key", nil, ScopeAdmin, true},
+		{"legacy wildcard key", []string{"*"}, "users:write", true},
+		{"exact scope", []string{"webhooks:ingest"}, "webhooks:ingest", true},
+		{"ingest cannot read incidents", []string{"webhooks:ingest"}, "incidents:read", false},
+		{"write implies read", []string{"rosters:write"}, "rosters:read", true},
+		{"read does not imply write", []string{"incidents:read"}, "incidents:write", false},
+		{"admin grants all", []string{ScopeAdmin}, "users:write", true},
+		{"webhook key cannot manage keys", []string{"alerts:write", "webhooks:ingest"}, ScopeAdmin, false},
+	}
+	for _, tt := range tests {
+		if got := Allows(tt.granted, tt.required); got != tt.want {
+			t.Errorf("%s: Allows(%v, %s) = %v, want %v", tt.name, tt.granted, tt.required, got, tt.want)
+		}
+	}
+}
+
+func TestAllowedFrom(t *testing.T) {
+	addr := netip.MustParseAddr("10.1.2.3")
+	if !allowedFrom(nil, addr) {
+		t.Error("empty allowlist must allow every address")
+	}
+	if !allowedFrom([]string{"10.0.0.0/8"}, addr) {
+		t.Error("address inside prefix must be allowed")
+	}
+	if allowedFrom([]string{"192.168.0.0/16"}, addr) {
+		t.Error("address outside prefix must be rejected")
+	}
+	if !allowedFrom([]string{"10.1.2.3/32"}, netip.MustParseAddr("::ffff:10.1.2.3")) {
+		t.Error("IPv4-mapped IPv6 address must match its IPv4 prefix")
+	}
+	if allowedFrom([]string{"10.0.0.0/8"}, netip.Addr{}) {
+		t.Error("unknown address must be rejected when an allowlist is set")
+	}
+}
+
+func TestClientAddr(t *testing.T) {
+	trusted, _ := ParseCIDRs([]string{"10.0.0.0/8"})
+	tests := []struct {
+		name   string
+		remote string
+		xff    string
+		want   string
+	}{
+		{"direct client ignores forwarded header", "203.0.113.9:5000", "198.51.100.1", "203.0.113.9"},
+		{"trusted proxy uses forwarded client", "10.0.0.5:5000", "198.51.100.1", "198.51.100.1"},
+		{"spoofed leftmost entry is skipped", "10.0.0.5:5000", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.5:5000", "198.51.100.1, 10.0.0.7", "198.51.100.1"},
+		{"trusted proxy without header", "10.0.0.5:5000", "", "10.0.0.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if got := ClientAddr(r, trusted); got.String() != tt.want {
+				t.Errorf("ClientAddr = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnforcer_PassesNonAPIKeyIdentities(t *testing.T) {
+	e := NewEnforcer(nil, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+
+	// Mirrors how routes are mounted: an authenticated API router whose
+	// sub-routers are each mounted behind Require.
+	root := chi.NewRouter()
+	var api chi.Router
+	root.Route("/api/v1", func(r chi.Router) {
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				id := &auth.Identity{Method: auth.MethodSession, Role: auth.RoleAdmin}
+				next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), id)))
+			})
+		})
+		r.Get("/ping", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+		api = r
+	})
+	sub := chi.NewRouter()
+	sub.Get("/", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusTeapot) })
+	api.With(e.Require(ResourceAdmin)).Mount("/api-keys", sub)
+
+	w := httptest.NewRecorder()
+	root.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/api-keys", nil))
+	if w.Code != http.StatusTeapot {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusTeapot)
+	}
+}
+
+func TestEnforcer_AuditReadKeyCannotManageSinks(t *testing.T) {
+	keyID := uuid.New()
+	e := NewEnforcer(nil, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	e.keys = func(context.Context, uuid.UUID) (ApiKeyRow, error) {
+		return ApiKeyRow{ID: keyID, Scopes: []string{"audit:read"}}, nil
+	}
+
+	sinks := chi.NewRouter()
+	sinks.Get("/", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	sinks.Post("/", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusCreated) })
+	auditLog := chi.NewRouter()
+	auditLog.Mount("/sinks", sinks)
+	root := chi.NewRouter()
+	root.With(e.Require(ResourceAudit)).Mount("/audit-log", auditLog)
+
+	tests := []struct {
+		method string
+		want   int
+	}{
+		{http.MethodGet, http.StatusOK},
+		{http.MethodPost, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/audit-log/sinks", nil)
+		id := &auth.Identity{Method: auth.MethodAPIKey, APIKeyID: &keyID, Role: auth.RoleAdmin}
+		r = r.WithContext(auth.NewContext(r.Context(), id))
+		w := httptest.NewRecorder()
+		root.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s /audit-log/sinks: status = %d, want %d", tt.method, w.Code, tt.want)
+		}
+	}
+}
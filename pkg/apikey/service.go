@@ -5,8 +5,10 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -41,18 +43,42 @@ func (s *Service) List(ctx context.Context, tenantID uuid.UUID) ([]Response, err
 	return items, nil
 }
 
+// ErrExpiryInPast is returned when creating a key that has already expired.
+var ErrExpiryInPast = errors.New("expires_at must be in the future")
+
 // Create generates a new API key, stores its hash, and returns the raw key once.
 func (s *Service) Create(ctx context.Context, tenantID uuid.UUID, req CreateRequest) (CreateResponse, error) {
+	scopes, err := NormalizeScopes(req.Scopes)
+	if err != nil {
+		return CreateResponse{}, err
+	}
+	prefixes, err := ParseCIDRs(req.AllowedCIDRs)
+	if err != nil {
+		return CreateResponse{}, err
+	}
+	cidrs := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		cidrs = append(cidrs, p.String())
+	}
+	var expiresAt pgtype.Timestamptz
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(time.Now()) {
+			return CreateResponse{}, ErrExpiryInPast
+		}
+		expiresAt = pgtype.Timestamptz{Time: *req.ExpiresAt, Valid: true}
+	}
+
 	raw, hash, prefix := generateAPIKey()
 
 	row, err := s.store.Create(ctx, CreateParams{
-		TenantID:    tenantID,
-		KeyHash:     hash,
-		KeyPrefix:   prefix,
-		Description: req.Description,
-		Role:        req.Role,
-		Scopes:      []string{},
-		ExpiresAt:   pgtype.Timestamptz{},
+		TenantID:     tenantID,
+		KeyHash:      hash,
+		KeyPrefix:    prefix,
+		Description:  req.Description,
+		Role:         req.Role,
+		Scopes:       scopes,
+		AllowedCIDRs: cidrs,
+		ExpiresAt:    expiresAt,
 	})
 	if err != nil {
 		return CreateResponse{}, fmt.Errorf("creating api key: %w", err)
@@ -10,7 +10,7 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-const apiKeyColumns = `id, tenant_id, key_hash, key_prefix, description, role, scopes, last_used, expires_at, created_at`
+const apiKeyColumns = `id, tenant_id, key_hash, key_prefix, description, role, scopes, allowed_cidrs, last_used, expires_at, created_at`
 
 // Store provides database operations for API keys using the global pool.
 type Store struct {
@@ -24,13 +24,14 @@ func NewStore(pool *pgxpool.Pool) *Store {
 
 // CreateParams holds parameters for creating an API key.
 type CreateParams struct {
-	TenantID    uuid.UUID
-	KeyHash     string
-	KeyPrefix   string
-	Description string
-	Role        string
-	Scopes      []string
-	ExpiresAt   pgtype.Timestamptz
+	TenantID     uuid.UUID
+	KeyHash      string
+	KeyPrefix    string
+	Description  string
+	Role         string
+	Scopes       []string
+	AllowedCIDRs []string
+	ExpiresAt    pgtype.Timestamptz
 }
 
 // scanApiKeyRow scans a pgx.Row into an ApiKeyRow.
@@ -38,7 +39,7 @@ func scanApiKeyRow(row pgx.Row) (ApiKeyRow, error) {
 	var r ApiKeyRow
 	err := row.Scan(
 		&r.ID, &r.TenantID, &r.KeyHash, &r.KeyPrefix, &r.Description,
-		&r.Role, &r.Scopes, &r.LastUsed, &r.ExpiresAt, &r.CreatedAt,
+		&r.Role, &r.Scopes, &r.AllowedCIDRs, &r.LastUsed, &r.ExpiresAt, &r.CreatedAt,
 	)
 	return r, err
 }
@@ -51,7 +52,7 @@ func scanApiKeyRows(rows pgx.Rows) ([]ApiKeyRow, error) {
 		var r ApiKeyRow
 		if err := rows.Scan(
 			&r.ID, &r.TenantID, &r.KeyHash, &r.KeyPrefix, &r.Description,
-			&r.Role, &r.Scopes, &r.LastUsed, &r.ExpiresAt, &r.CreatedAt,
+			&r.Role, &r.Scopes, &r.AllowedCIDRs, &r.LastUsed, &r.ExpiresAt, &r.CreatedAt,
 		); err != nil {
 			return nil, fmt.Errorf("scanning api key row: %w", err)
 		}
@@ -73,14 +74,20 @@ func (s *Store) List(ctx context.Context, tenantID uuid.UUID) ([]ApiKeyRow, erro
 	return scanApiKeyRows(rows)
 }
 
+// Get returns a single API key by ID.
+func (s *Store) Get(ctx context.Context, id uuid.UUID) (ApiKeyRow, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM public.api_keys WHERE id = $1`
+	return scanApiKeyRow(s.pool.QueryRow(ctx, query, id))
+}
+
 // Create inserts a new API key and returns the created row.
 func (s *Store) Create(ctx context.Context, p CreateParams) (ApiKeyRow, error) {
-	query := `INSERT INTO public.api_keys (tenant_id, key_hash, key_prefix, description, role, scopes, expires_at)
-	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	query := `INSERT INTO public.api_keys (tenant_id, key_hash, key_prefix, description, role, scopes, allowed_cidrs, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	RETURNING ` + apiKeyColumns
 
 	row := s.pool.QueryRow(ctx, query,
-		p.TenantID, p.KeyHash, p.KeyPrefix, p.Description, p.Role, p.Scopes, p.ExpiresAt,
+		p.TenantID, p.KeyHash, p.KeyPrefix, p.Description, p.Role, p.Scopes, p.AllowedCIDRs, p.ExpiresAt,
 	)
 	return scanApiKeyRow(row)
 }
@@ -0,0 +1,101 @@
+package emailingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/wisbric/nightowl/pkg/alert"
+	"github.com/wisbric/nightowl/pkg/tenant"
+)
+
+// Ingester feeds a parsed email into the alert pipeline.
+// alert.WebhookHandler implements it.
+type Ingester interface {
+	IngestEmail(ctx context.Context, in *alert.Integration, msg *alert.EmailMessage) (alert.BatchResponse, error)
+}
+
+// Pipeline is the Deliverer backed by email integrations: it resolves
+// <token>@<tenant-slug>.<domain> addresses and hands messages to an Ingester.
+type Pipeline struct {
+	pool     *pgxpool.Pool
+	ingester Ingester
+	domain   string
+}
+
+// NewPipeline creates a Pipeline for the given mail domain.
+func NewPipeline(pool *pgxpool.Pool, ingester Ingester, domain string) *Pipeline {
+	return &Pipeline{pool: pool, ingester: ingester, domain: strings.ToLower(domain)}
+}
+
+// CheckRecipient reports whether addr belongs to an enabled email integration.
+func (p *Pipeline) CheckRecipient(ctx context.Context, addr string) error {
+	_, release, _, err := p.resolve(ctx, addr)
+	if err != nil {
+		return err
+	}
+	release()
+	return nil
+}
+
+// Deliver ingests msg through the integration addr belongs to.
+func (p *Pipeline) Deliver(ctx context.Context, addr string, msg *alert.EmailMessage) error {
+	tctx, release, in, err := p.resolve(ctx, addr)
+	if err != nil {
+		return err
+	}
+	defer release()
+	_, err = p.ingester.IngestEmail(tctx, in, msg)
+	return err
+}
+
+// resolve looks up the tenant and integration for an address and returns a
+// context carrying the tenant connection.
+func (p *Pipeline) resolve(ctx context.Context, addr string) (context.Context, func(), *alert.Integration, error) {
+	slug, token, ok := ParseAddress(addr, p.domain)
+	if !ok {
+		return nil, nil, nil, ErrUnknownRecipient
+	}
+
+	tctx, release, err := tenant.Acquire(ctx, p.pool, slug)
+	if errors.Is(err, tenant.ErrNotFound) {
+		return nil, nil, nil, ErrUnknownRecipient
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	in, err := alert.NewIntegrationStore(tenant.ConnFromContext(tctx)).GetByToken(tctx, token)
+	switch {
+	case errors.Is(err, alert.ErrIntegrationNotFound):
+		err = ErrUnknownRecipient
+	case err != nil:
+		err = fmt.Errorf("looking up integration: %w", err)
+	case in.SourceType != alert.SourceEmail:
+		err = ErrUnknownRecipient
+	case !in.Enabled:
+		err = ErrRecipientDisabled
+	}
+	if err != nil {
+		release()
+		return nil, nil, nil, err
+	}
+	return tctx, release, &in, nil
+}
+
+// ParseAddress splits an integration address <token>@<tenant-slug>.<domain>
+// into tenant slug and token.
+func ParseAddress(addr, domain string) (slug, token string, ok bool) {
+	local, host, found := strings.Cut(strings.ToLower(addr), "@")
+	if !found || local == "" {
+		return "", "", false
+	}
+	slug, found = strings.CutSuffix(host, "."+strings.ToLower(domain))
+	if !found || slug == "" || strings.Contains(slug, ".") {
+		return "", "", false
+	}
+	return slug, local, true
+}
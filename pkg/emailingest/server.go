@@ -0,0 +1,421 @@
+// Package emailingest implements the SMTP listener that turns email from
+// legacy systems into alerts. Each email integration has its own address,
+// <token>@<tenant-slug>.<domain>; messages are parsed and handed to the same
+// ingest pipeline as webhooks.
+package emailingest
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wisbric/nightowl/pkg/alert"
+)
+
+var (
+	// ErrUnknownRecipient is returned for addresses that do not belong to an
+	// email integration.
+	ErrUnknownRecipient = errors.New("unknown recipient")
+	// ErrRecipientDisabled is returned for addresses of disabled integrations.
+	ErrRecipientDisabled = errors.New("integration is disabled")
+)
+
+// Deliverer accepts messages for integration addresses.
+type Deliverer interface {
+	// CheckRecipient reports whether addr accepts mail.
+	CheckRecipient(ctx context.Context, addr string) error
+	// Deliver ingests a message for one recipient.
+	Deliver(ctx context.Context, addr string, msg *alert.EmailMessage) error
+}
+
+// Config configures the SMTP listener.
+type Config struct {
+	Addr string
+	// Hostname is announced in the greeting.
+	Hostname string
+	// TLSConfig enables STARTTLS when set.
+	TLSConfig       *tls.Config
+	MaxMessageBytes int
+	MaxRecipients   int
+	// Timeout bounds the wait for each command and for the message data.
+	Timeout time.Duration
+}
+
+const (
+	defaultMaxRecipients = 50
+	defaultTimeout       = 5 * time.Minute
+	maxCommandErrors     = 10
+)
+
+// Server is a minimal inbound SMTP server (RFC 5321). It accepts mail only
+// for recipients its Deliverer knows and does not relay.
+type Server struct {
+	cfg       Config
+	deliverer Deliverer
+	logger    *slog.Logger
+
+	wg sync.WaitGroup
+}
+
+// NewServer creates an SMTP Server.
+func NewServer(cfg Config, deliverer Deliverer, logger *slog.Logger) *Server {
+	if cfg.Hostname == "" {
+		cfg.Hostname = "localhost"
+	}
+	if cfg.MaxMessageBytes <= 0 {
+		cfg.MaxMessageBytes = 1 << 20
+	}
+	if cfg.MaxRecipients <= 0 {
+		cfg.MaxRecipients = defaultMaxRecipients
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	return &Server{cfg: cfg, deliverer: deliverer, logger: logger}
+}
+
+// ListenAndServe listens on the configured address and serves until ctx is
+// cancelled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", s.cfg.Addr, err)
+	}
+	s.logger.Info("smtp server listening", "addr", ln.Addr().String(), "starttls", s.cfg.TLSConfig != nil)
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts connections on ln until ctx is cancelled, then waits for
+// open sessions to finish.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		_ = ln.Close()
+	}()
+	defer s.wg.Wait()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("accepting smtp connection: %w", err)
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			newSession(s, conn).serve(ctx)
+		}()
+	}
+}
+
+// session is one SMTP connection.
+type session struct {
+	srv    *Server
+	conn   net.Conn
+	text   *textproto.Conn
+	logger *slog.Logger
+
+	helo   string
+	tls    bool
+	from   string
+	rcpts  []string
+	errors int
+}
+
+func newSession(s *Server, conn net.Conn) *session {
+	return &session{
+		srv:    s,
+		conn:   conn,
+		text:   textproto.NewConn(conn),
+		logger: s.logger.With("remote", conn.RemoteAddr().String()),
+	}
+}
+
+func (ss *session) reply(code int, msg string) {
+	_ = ss.conn.SetWriteDeadline(time.Now().Add(ss.srv.cfg.Timeout))
+	_ = ss.text.PrintfLine("%d %s", code, msg)
+}
+
+func (ss *session) replyLines(code int, lines []string) {
+	_ = ss.conn.SetWriteDeadline(time.Now().Add(ss.srv.cfg.Timeout))
+	for i, l := range lines {
+		sep := "-"
+		if i == len(lines)-1 {
+			sep = " "
+		}
+		_ = ss.text.PrintfLine("%d%s%s", code, sep, l)
+	}
+}
+
+func (ss *session) reset() {
+	ss.from = ""
+	ss.rcpts = nil
+}
+
+func (ss *session) serve(ctx context.Context) {
+	defer func() { _ = ss.text.Close() }()
+	// On shutdown, interrupt a pending read so the session can say goodbye.
+	// The deadline is set on the raw connection, which also covers TLS.
+	raw := ss.conn
+	stop := context.AfterFunc(ctx, func() { _ = raw.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	ss.reply(220, ss.srv.cfg.Hostname+" ESMTP NightOwl")
+	for {
+		_ = ss.conn.SetReadDeadline(time.Now().Add(ss.srv.cfg.Timeout))
+		if ctx.Err() != nil {
+			ss.reply(421, "4.3.2 Service shutting down")
+			return
+		}
+		line, err := ss.text.ReadLine()
+		if err != nil {
+			if ctx.Err() != nil {
+				ss.reply(421, "4.3.2 Service shutting down")
+			} else if !errors.Is(err, io.EOF) {
+				ss.logger.Debug("smtp read failed", "error", err)
+			}
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			ss.handleHelo(arg, false)
+		case "EHLO":
+			ss.handleHelo(arg, true)
+		case "STARTTLS":
+			if !ss.handleStartTLS() {
+				return
+			}
+		case "MAIL":
+			ss.handleMail(arg)
+		case "RCPT":
+			ss.handleRcpt(ctx, arg)
+		case "DATA":
+			if !ss.handleData(ctx) {
+				return
+			}
+		case "RSET":
+			ss.reset()
+			ss.reply(250, "2.0.0 OK")
+		case "NOOP":
+			ss.reply(250, "2.0.0 OK")
+		case "VRFY":
+			ss.reply(252, "2.5.0 Cannot VRFY user")
+		case "QUIT":
+			ss.reply(221, "2.0.0 Bye")
+			return
+		default:
+			ss.fail(502, "5.5.2 Command not recognized")
+		}
+		if ss.errors >= maxCommandErrors {
+			ss.reply(421, "4.7.0 Too many errors, closing connection")
+			return
+		}
+	}
+}
+
+// fail replies with an error and counts it towards the session's limit.
+func (ss *session) fail(code int, msg string) {
+	ss.errors++
+	ss.reply(code, msg)
+}
+
+func (ss *session) handleHelo(arg string, extended bool) {
+	if strings.TrimSpace(arg) == "" {
+		ss.fail(501, "5.5.4 Domain required")
+		return
+	}
+	ss.helo = strings.TrimSpace(arg)
+	ss.reset()
+	if !extended {
+		ss.reply(250, ss.srv.cfg.Hostname)
+		return
+	}
+	lines := []string{
+		ss.srv.cfg.Hostname,
+		"PIPELINING",
+		"8BITMIME",
+		"ENHANCEDSTATUSCODES",
+		"SIZE " + strconv.Itoa(ss.srv.cfg.MaxMessageBytes),
+	}
+	if ss.srv.cfg.TLSConfig != nil && !ss.tls {
+		lines = append(lines, "STARTTLS")
+	}
+	ss.replyLines(250, lines)
+}
+
+// handleStartTLS upgrades the connection. It returns false if the session
+// must end.
+func (ss *session) handleStartTLS() bool {
+	if ss.srv.cfg.TLSConfig == nil || ss.tls {
+		ss.fail(502, "5.5.1 STARTTLS not available")
+		return true
+	}
+	ss.reply(220, "2.0.0 Ready to start TLS")
+	tlsConn := tls.Server(ss.conn, ss.srv.cfg.TLSConfig)
+	_ = tlsConn.SetDeadline(time.Now().Add(ss.srv.cfg.Timeout))
+	if err := tlsConn.Handshake(); err != nil {
+		ss.logger.Debug("smtp tls handshake failed", "error", err)
+		return false
+	}
+	ss.conn = tlsConn
+	ss.text = textproto.NewConn(tlsConn)
+	ss.tls = true
+	// RFC 3207: the client starts over with EHLO.
+	ss.helo = ""
+	ss.reset()
+	return true
+}
+
+func (ss *session) handleMail(arg string) {
+	if ss.helo == "" {
+		ss.fail(503, "5.5.1 Send HELO/EHLO first")
+		return
+	}
+	if ss.from != "" {
+		ss.fail(503, "5.5.1 Sender already specified")
+		return
+	}
+	addr, params, ok := parsePath(arg, "FROM:")
+	if !ok {
+		ss.fail(501, "5.5.4 Syntax: MAIL FROM:<address>")
+		return
+	}
+	for _, p := range params {
+		k, v, _ := strings.Cut(p, "=")
+		if strings.EqualFold(k, "SIZE") {
+			if n, err := strconv.Atoi(v); err == nil && n > ss.srv.cfg.MaxMessageBytes {
+				ss.fail(552, "5.3.4 Message too big")
+				return
+			}
+		}
+	}
+	// The null reverse-path "<>" is valid for bounces.
+	ss.from = "<" + addr + ">"
+	ss.reply(250, "2.1.0 OK")
+}
+
+func (ss *session) handleRcpt(ctx context.Context, arg string) {
+	if ss.from == "" {
+		ss.fail(503, "5.5.1 Send MAIL first")
+		return
+	}
+	addr, _, ok := parsePath(arg, "TO:")
+	if !ok || addr == "" {
+		ss.fail(501, "5.5.4 Syntax: RCPT TO:<address>")
+		return
+	}
+	if len(ss.rcpts) >= ss.srv.cfg.MaxRecipients {
+		ss.reply(452, "4.5.3 Too many recipients")
+		return
+	}
+
+	addr = strings.ToLower(addr)
+	err := ss.srv.deliverer.CheckRecipient(ctx, addr)
+	switch {
+	case err == nil:
+		ss.rcpts = append(ss.rcpts, addr)
+		ss.reply(250, "2.1.5 OK")
+	case errors.Is(err, ErrUnknownRecipient):
+		ss.fail(550, "5.1.1 No such integration address")
+	case errors.Is(err, ErrRecipientDisabled):
+		ss.fail(550, "5.7.1 Integration is disabled")
+	default:
+		ss.logger.Error("checking smtp recipient", "error", err, "rcpt", addr)
+		ss.reply(451, "4.3.0 Temporary failure, try again later")
+	}
+}
+
+// handleData reads and delivers the message. It returns false if the
+// session must end.
+func (ss *session) handleData(ctx context.Context) bool {
+	if len(ss.rcpts) == 0 {
+		ss.fail(503, "5.5.1 Send RCPT first")
+		return true
+	}
+	ss.reply(354, "Start mail input; end with <CRLF>.<CRLF>")
+
+	_ = ss.conn.SetReadDeadline(time.Now().Add(ss.srv.cfg.Timeout))
+	limit := ss.srv.cfg.MaxMessageBytes
+	dr := ss.text.DotReader()
+	data, err := io.ReadAll(io.LimitReader(dr, int64(limit)+1))
+	if err != nil {
+		ss.logger.Debug("smtp data read failed", "error", err)
+		return false
+	}
+	defer ss.reset()
+	if len(data) > limit {
+		// Drain the rest of the message so the session stays in sync.
+		_, _ = io.Copy(io.Discard, dr)
+		ss.reply(552, "5.3.4 Message too big")
+		return true
+	}
+
+	msg, err := alert.ParseEmail(bytes.NewReader(data))
+	if err != nil {
+		ss.reply(554, "5.6.0 "+replyText(err))
+		return true
+	}
+
+	var permanent, temporary error
+	for _, rcpt := range ss.rcpts {
+		err := ss.srv.deliverer.Deliver(ctx, rcpt, msg)
+		switch {
+		case err == nil:
+		case errors.Is(err, alert.ErrUnmappable):
+			permanent = err
+		default:
+			ss.logger.Error("delivering email alert", "error", err, "rcpt", rcpt)
+			temporary = err
+		}
+	}
+	switch {
+	case temporary != nil:
+		ss.reply(451, "4.3.0 Temporary failure, try again later")
+	case permanent != nil:
+		ss.reply(554, "5.6.0 "+replyText(permanent))
+	default:
+		ss.logger.Info("email accepted", "from", msg.From, "subject", msg.Subject, "recipients", len(ss.rcpts))
+		ss.reply(250, "2.0.0 Message accepted")
+	}
+	return true
+}
+
+// parsePath parses "FROM:<addr> PARAM=..." style arguments.
+func parsePath(arg, prefix string) (addr string, params []string, ok bool) {
+	arg = strings.TrimSpace(arg)
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", nil, false
+	}
+	arg = strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(arg, "<") {
+		return "", nil, false
+	}
+	end := strings.IndexByte(arg, '>')
+	if end < 0 {
+		return "", nil, false
+	}
+	return arg[1:end], strings.Fields(arg[end+1:]), true
+}
+
+// replyText flattens an error into a single reply line.
+func replyText(err error) string {
+	s := strings.Join(strings.Fields(err.Error()), " ")
+	if len(s) > 400 {
+		s = s[:400]
+	}
+	return s
+}
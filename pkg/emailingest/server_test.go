@@ -0,0 +1,199 @@
+package emailingest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/wisbric/nightowl/pkg/alert"
+)
+
+type fakeDeliverer struct {
+	mu        sync.Mutex
+	known     map[string]error
+	delivered map[string][]*alert.EmailMessage
+	deliver   error
+}
+
+func (f *fakeDeliverer) CheckRecipient(_ context.Context, addr string) error {
+	err, ok := f.known[addr]
+	if !ok {
+		return ErrUnknownRecipient
+	}
+	return err
+}
+
+func (f *fakeDeliverer) Deliver(_ context.Context, addr string, msg *alert.EmailMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.delivered == nil {
+		f.delivered = map[string][]*alert.EmailMessage{}
+	}
+	f.delivered[addr] = append(f.delivered[addr], msg)
+	return f.deliver
+}
+
+// startServer serves on a random local port until the test ends.
+func startServer(t *testing.T, d Deliverer, cfg Config) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := NewServer(cfg, d, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = srv.Serve(ctx, ln)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return ln.Addr().String()
+}
+
+// isReply reports whether err is an SMTP reply with the given code and
+// enhanced status.
+func isReply(err error, code int, status string) bool {
+	var te *textproto.Error
+	return errors.As(err, &te) && te.Code == code && strings.HasPrefix(te.Msg, status)
+}
+
+const testMessage = "From: Nagios <nagios@example.com>\r\n" +
+	"To: nwh_abc@acme.alerts.example.com\r\n" +
+	"Subject: PROBLEM: disk full on db1 is CRITICAL\r\n" +
+	"\r\n" +
+	"Host: db1\r\n" +
+	".leading dot line\r\n"
+
+func TestServer_DeliversMessage(t *testing.T) {
+	d := &fakeDeliverer{known: map[string]error{"nwh_abc@acme.alerts.example.com": nil}}
+	addr := startServer(t, d, Config{Hostname: "alerts.example.com"})
+
+	err := smtp.SendMail(addr, nil, "nagios@example.com",
+		[]string{"NWH_ABC@acme.alerts.example.com"}, []byte(testMessage))
+	if err != nil {
+		t.Fatalf("SendMail: %v", err)
+	}
+
+	msgs := d.delivered["nwh_abc@acme.alerts.example.com"]
+	if len(msgs) != 1 {
+		t.Fatalf("delivered %d messages, want 1", len(msgs))
+	}
+	if msgs[0].Subject != "PROBLEM: disk full on db1 is CRITICAL" || msgs[0].From != "nagios@example.com" {
+		t.Errorf("message = %+v", msgs[0])
+	}
+	if !strings.HasSuffix(msgs[0].Text, "\n.leading dot line") {
+		t.Errorf("text = %q, want the leading dot preserved", msgs[0].Text)
+	}
+}
+
+func TestServer_RejectsRecipients(t *testing.T) {
+	d := &fakeDeliverer{known: map[string]error{"off@acme.alerts.example.com": ErrRecipientDisabled}}
+	addr := startServer(t, d, Config{})
+
+	for rcpt, want := range map[string]string{
+		"nobody@acme.alerts.example.com": "5.1.1",
+		"off@acme.alerts.example.com":    "5.7.1",
+	} {
+		err := smtp.SendMail(addr, nil, "a@example.com", []string{rcpt}, []byte(testMessage))
+		if !isReply(err, 550, want) {
+			t.Errorf("SendMail to %s: err = %v, want 550 %s", rcpt, err, want)
+		}
+	}
+	if len(d.delivered) != 0 {
+		t.Errorf("delivered = %v, want nothing", d.delivered)
+	}
+}
+
+func TestServer_DeliveryErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+		want string
+	}{
+		{"unmappable", errors.Join(alert.ErrUnmappable, errors.New("no title")), 554, "5.6.0"},
+		{"temporary", errors.New("database down"), 451, "4.3.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeDeliverer{known: map[string]error{"x@acme.alerts.example.com": nil}, deliver: tt.err}
+			addr := startServer(t, d, Config{})
+			err := smtp.SendMail(addr, nil, "a@example.com", []string{"x@acme.alerts.example.com"}, []byte(testMessage))
+			if !isReply(err, tt.code, tt.want) {
+				t.Errorf("err = %v, want %d %s", err, tt.code, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_MessageTooBig(t *testing.T) {
+	d := &fakeDeliverer{known: map[string]error{"x@acme.alerts.example.com": nil}}
+	addr := startServer(t, d, Config{MaxMessageBytes: 64})
+
+	err := smtp.SendMail(addr, nil, "a@example.com", []string{"x@acme.alerts.example.com"},
+		[]byte(testMessage+strings.Repeat("padding\r\n", 20)))
+	if !isReply(err, 552, "5.3.4") {
+		t.Errorf("err = %v, want 552", err)
+	}
+}
+
+func TestServer_CommandSequence(t *testing.T) {
+	addr := startServer(t, &fakeDeliverer{}, Config{})
+	c, err := smtp.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		t.Error("STARTTLS advertised without a TLS config")
+	}
+	if ok, size := c.Extension("SIZE"); !ok || size != "1048576" {
+		t.Errorf("SIZE = %q, want the default limit", size)
+	}
+	if err := c.Rcpt("x@acme.alerts.example.com"); !isReply(err, 503, "5.5.1") {
+		t.Errorf("RCPT before MAIL: err = %v, want 503", err)
+	}
+	if err := c.Mail("a@example.com"); err != nil {
+		t.Fatalf("MAIL: %v", err)
+	}
+	if _, err := c.Data(); !isReply(err, 503, "5.5.1") {
+		t.Errorf("DATA without recipients: err = %v, want 503", err)
+	}
+	if err := c.Quit(); err != nil {
+		t.Errorf("QUIT: %v", err)
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		addr      string
+		slug, tok string
+		ok        bool
+	}{
+		{"nwh_abc@acme.alerts.example.com", "acme", "nwh_abc", true},
+		{"NWH_ABC@Acme.Alerts.Example.com", "acme", "nwh_abc", true},
+		{"nwh_abc@alerts.example.com", "", "", false},
+		{"nwh_abc@a.b.alerts.example.com", "", "", false},
+		{"nwh_abc@acme.example.org", "", "", false},
+		{"@acme.alerts.example.com", "", "", false},
+		{"no-at-sign", "", "", false},
+	}
+	for _, tt := range tests {
+		slug, tok, ok := ParseAddress(tt.addr, "alerts.example.com")
+		if slug != tt.slug || tok != tt.tok || ok != tt.ok {
+			t.Errorf("ParseAddress(%q) = %q, %q, %v; want %q, %q, %v", tt.addr, slug, tok, ok, tt.slug, tt.tok, tt.ok)
+		}
+	}
+}
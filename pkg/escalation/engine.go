@@ -6,17 +6,25 @@ import (
 	"fmt"
 	"log/slog"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/go-redis/v9"
 
 	"github.com/wisbric/nightowl/internal/db"
+	"github.com/wisbric/nightowl/pkg/events"
+	"github.com/wisbric/nightowl/pkg/roster"
 	"github.com/wisbric/nightowl/pkg/tenant"
 )
 
+// noTargetsResult is the notify result of an escalation event for a tier
+// that resolved to no one to notify.
+const noTargetsResult = "no_targets"
+
 // Engine is a background worker that polls for unacknowledged alerts and
 // escalates them through their configured escalation policy tiers.
 type Engine struct {
@@ -25,6 +33,9 @@ type Engine struct {
 	logger   *slog.Logger
 	interval time.Duration
 	metric   *prometheus.CounterVec // alerts_escalated_total{tier}
+
+	// Events, when set, receives an alert.escalated event per escalation.
+	Events *events.Publisher
 }
 
 // NewEngine creates a new escalation engine.
@@ -101,6 +112,7 @@ func (e *Engine) processTenant(ctx context.Context, slug string) error {
 	}
 
 	tq := db.New(conn)
+	rosters := roster.NewService(conn, e.logger)
 
 	alerts, err := tq.ListPendingEscalationAlerts(ctx)
 	if err != nil {
@@ -108,7 +120,7 @@ func (e *Engine) processTenant(ctx context.Context, slug string) error {
 	}
 
 	for _, a := range alerts {
-		if err := e.processAlert(ctx, tq, a); err != nil {
+		if err := e.processAlert(ctx, schema, tq, rosters, a); err != nil {
 			e.logger.Error("processing alert escalation",
 				"alert_id", a.ID,
 				"error", err,
@@ -119,7 +131,7 @@ func (e *Engine) processTenant(ctx context.Context, slug string) error {
 }
 
 // processAlert evaluates whether an alert needs escalation and performs it.
-func (e *Engine) processAlert(ctx context.Context, q *db.Queries, a db.Alert) error {
+func (e *Engine) processAlert(ctx context.Context, schema string, q *db.Queries, rosters *roster.Service, a db.Alert) error {
 	if !a.EscalationPolicyID.Valid {
 		return nil
 	}
@@ -197,30 +209,76 @@ func (e *Engine) processAlert(ctx context.Context, q *db.Queries, a db.Alert) er
 		return fmt.Errorf("updating escalation tier: %w", err)
 	}
 
-	// Persist escalation event.
+	// Resolve on-call targets through the rosters bound to this policy.
+	// Follow-the-sun pairs answer with whichever half is currently active.
+	// Rosters that fail to resolve are skipped; the others still answer.
+	var oncall []*roster.OnCallResponse
+	if rosters != nil {
+		oncall, err = rosters.GetOnCallForPolicy(ctx, policyID, time.Now())
+		if err != nil {
+			e.logger.Warn("resolving on-call for escalation",
+				"alert_id", a.ID,
+				"policy_id", policyID,
+				"error", err,
+			)
+		}
+	}
+	targetUsers := resolveTargetUsers(nextTier.Targets, oncall)
+
+	// Persist escalation events, one per resolved target. A tier that
+	// resolves to nobody is recorded as such, so the gap shows in the
+	// alert's timeline.
 	notifyVia := ""
 	if len(nextTier.NotifyVia) > 0 {
 		notifyVia = nextTier.NotifyVia[0]
 	}
-	if _, err := q.CreateEscalationEvent(ctx, db.CreateEscalationEventParams{
-		AlertID:      a.ID,
-		PolicyID:     policyID,
-		Tier:         int32(nextTier.Tier),
-		Action:       "escalate",
-		NotifyMethod: &notifyVia,
-	}); err != nil {
-		return fmt.Errorf("creating escalation event: %w", err)
+	var notifyResult *string
+	targetParams := []pgtype.UUID{{}}
+	if len(targetUsers) == 0 {
+		e.logger.Warn("escalation tier has no targets",
+			"alert_id", a.ID,
+			"policy_id", policyID,
+			"tier", nextTier.Tier,
+		)
+		noTargets := noTargetsResult
+		notifyResult = &noTargets
+	} else {
+		targetParams = targetParams[:0]
+		for _, id := range targetUsers {
+			targetParams = append(targetParams, pgtype.UUID{Bytes: id, Valid: true})
+		}
+	}
+	for _, target := range targetParams {
+		if _, err := q.CreateEscalationEvent(ctx, db.CreateEscalationEventParams{
+			AlertID:      a.ID,
+			PolicyID:     policyID,
+			Tier:         int32(nextTier.Tier),
+			Action:       "escalate",
+			TargetUserID: target,
+			NotifyMethod: &notifyVia,
+			NotifyResult: notifyResult,
+		}); err != nil {
+			return fmt.Errorf("creating escalation event: %w", err)
+		}
+	}
+
+	targetIDs := make([]string, 0, len(targetUsers))
+	for _, id := range targetUsers {
+		targetIDs = append(targetIDs, id.String())
 	}
 
 	// Publish escalation event to Redis for notification consumers.
-	payload, _ := json.Marshal(map[string]any{
-		"alert_id":  a.ID.String(),
-		"policy_id": policyID.String(),
-		"tier":      nextTier.Tier,
-		"title":     a.Title,
-		"severity":  a.Severity,
-	})
+	escalated := map[string]any{
+		"alert_id":        a.ID.String(),
+		"policy_id":       policyID.String(),
+		"tier":            nextTier.Tier,
+		"title":           a.Title,
+		"severity":        a.Severity,
+		"target_user_ids": targetIDs,
+	}
+	payload, _ := json.Marshal(escalated)
 	e.rdb.Publish(ctx, "nightowl:alert:escalated", string(payload))
+	e.Events.Publish(schema, events.AlertEscalated, a.ID, escalated)
 
 	// Record metric.
 	if e.metric != nil {
@@ -230,6 +288,42 @@ func (e *Engine) processAlert(ctx context.Context, q *db.Queries, a db.Alert) er
 	return nil
 }
 
+// resolveTargetUsers maps tier targets to concrete user IDs. oncall_primary
+// and oncall_backup resolve against the given on-call answers; user:<id>
+// targets are taken literally. Duplicates are dropped, order is preserved.
+func resolveTargetUsers(targets []string, oncall []*roster.OnCallResponse) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool)
+	var result []uuid.UUID
+	add := func(id uuid.UUID) {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+
+	for _, target := range targets {
+		switch {
+		case target == "oncall_primary":
+			for _, oc := range oncall {
+				if oc != nil && oc.Primary != nil {
+					add(oc.Primary.UserID)
+				}
+			}
+		case target == "oncall_backup":
+			for _, oc := range oncall {
+				if oc != nil && oc.Secondary != nil {
+					add(oc.Secondary.UserID)
+				}
+			}
+		case strings.HasPrefix(target, "user:"):
+			if id, err := uuid.Parse(strings.TrimPrefix(target, "user:")); err == nil {
+				add(id)
+			}
+		}
+	}
+	return result
+}
+
 // PublishAck publishes an alert acknowledgment event to Redis pub/sub,
 // which the escalation engine listens to.
 func PublishAck(ctx context.Context, rdb *redis.Client, alertID uuid.UUID) {
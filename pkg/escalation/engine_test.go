@@ -9,6 +9,7 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 
 	"github.com/wisbric/nightowl/internal/db"
+	"github.com/wisbric/nightowl/pkg/roster"
 )
 
 func TestProcessAlert_NotYetTime(t *testing.T) {
@@ -153,3 +154,34 @@ func TestAlertEscalationPolicyIDValid(t *testing.T) {
 		t.Error("policy ID mismatch")
 	}
 }
+
+func TestResolveTargetUsers(t *testing.T) {
+	primary := uuid.New()
+	secondary := uuid.New()
+	direct := uuid.New()
+	oncall := []*roster.OnCallResponse{
+		{
+			Primary:   &roster.OnCallEntry{UserID: primary},
+			Secondary: &roster.OnCallEntry{UserID: secondary},
+		},
+		{
+			// Both halves of a follow-the-sun pair resolve to the same person.
+			Primary: &roster.OnCallEntry{UserID: primary},
+		},
+	}
+
+	got := resolveTargetUsers([]string{"oncall_primary", "oncall_backup", "user:" + direct.String(), "user:bogus", "team_lead"}, oncall)
+	want := []uuid.UUID{primary, secondary, direct}
+	if len(got) != len(want) {
+		t.Fatalf("got %d targets, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("target[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+
+	if got := resolveTargetUsers([]string{"oncall_primary"}, nil); len(got) != 0 {
+		t.Errorf("expected no targets without on-call data, got %v", got)
+	}
+}
@@ -0,0 +1,205 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/wisbric/nightowl/internal/audit"
+)
+
+// Event types.
+const (
+	AlertCreated      = "alert.created"
+	AlertDeduplicated = "alert.deduplicated"
+	AlertAcknowledged = "alert.acknowledged"
+	AlertResolved     = "alert.resolved"
+	AlertEscalated    = "alert.escalated"
+	AlertGroupUpdated = "alert_group.updated"
+	OnCallChanged     = "oncall.changed"
+	IncidentCreated   = "incident.created"
+	IncidentUpdated   = "incident.updated"
+	IncidentDeleted   = "incident.deleted"
+	IncidentMerged    = "incident.merged"
+
+	// StreamReset tells a resuming client that events it missed are no
+	// longer retained, so it should reload its state.
+	StreamReset = "stream.reset"
+)
+
+const (
+	// streamMaxLen is roughly how many events each tenant's stream keeps
+	// for clients resuming with Last-Event-ID.
+	streamMaxLen = 10000
+	// streamTTL drops the stream of a tenant that has been quiet this long.
+	streamTTL = 24 * time.Hour
+
+	queueSize      = 1024
+	publishTimeout = 2 * time.Second
+)
+
+// Event is one change in a tenant, as delivered to stream clients.
+type Event struct {
+	// ID is the event's position in the tenant's stream; clients resume
+	// after it with Last-Event-ID.
+	ID         string          `json:"id,omitempty"`
+	Type       string          `json:"type"`
+	ResourceID uuid.UUID       `json:"resource_id"`
+	UserID     *uuid.UUID      `json:"user_id,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	Time       time.Time       `json:"time"`
+}
+
+// streamKey names both the Redis stream that retains a tenant's events and
+// the pub/sub channel that carries them live.
+func streamKey(schema string) string {
+	return "nightowl:events:" + schema
+}
+
+// publishScript appends an event to the tenant's stream and publishes it
+// live as "<id> <event>", so subscribers see the same IDs as the stream.
+var publishScript = redis.NewScript(`
+local id = redis.call('XADD', KEYS[1], 'MAXLEN', '~', ARGV[1], '*', 'event', ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+redis.call('PUBLISH', KEYS[1], id .. ' ' .. ARGV[2])
+return id
+`)
+
+type queued struct {
+	schema string
+	event  Event
+}
+
+// Publisher sends events to tenants' streams in the background. Events are
+// best effort: when Redis is slow or down they are dropped rather than
+// holding up the request that caused them, and resuming clients are told to
+// reload. The audit log remains the durable record.
+//
+// A nil Publisher discards events.
+type Publisher struct {
+	rdb    *redis.Client
+	logger *slog.Logger
+	queue  chan queued
+}
+
+// NewPublisher creates a Publisher. Call Run to start sending.
+func NewPublisher(rdb *redis.Client, logger *slog.Logger) *Publisher {
+	return &Publisher{rdb: rdb, logger: logger, queue: make(chan queued, queueSize)}
+}
+
+// Run sends queued events until ctx is cancelled.
+func (p *Publisher) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case q := <-p.queue:
+			p.send(ctx, q)
+		}
+	}
+}
+
+func (p *Publisher) send(ctx context.Context, q queued) {
+	body, err := json.Marshal(q.event)
+	if err != nil {
+		p.logger.Error("encoding event", "type", q.event.Type, "error", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+	err = publishScript.Run(ctx, p.rdb, []string{streamKey(q.schema)},
+		streamMaxLen, streamTTL.Milliseconds(), string(body)).Err()
+	if err != nil {
+		p.logger.Warn("publishing event", "type", q.event.Type, "schema", q.schema, "error", err)
+	}
+}
+
+// Publish queues an event for the tenant with the given schema. data is
+// encoded as the event's data and may be nil. It never blocks.
+func (p *Publisher) Publish(schema, eventType string, resourceID uuid.UUID, data any) {
+	if p == nil || schema == "" {
+		return
+	}
+	ev := Event{Type: eventType, ResourceID: resourceID, Time: time.Now()}
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			p.logger.Error("encoding event data", "type", eventType, "error", err)
+			return
+		}
+		ev.Data = raw
+	}
+	p.enqueue(schema, ev)
+}
+
+// PublishAudit publishes the event an audit entry stands for, if any. It
+// serves as the audit writer's Notify hook, so every audited change to
+// alerts, incidents and rosters reaches the stream.
+func (p *Publisher) PublishAudit(e audit.Entry) {
+	if p == nil || e.TenantSchema == "" {
+		return
+	}
+	eventType, ok := auditEventType(e.Resource, e.Action)
+	if !ok {
+		return
+	}
+	ev := Event{Type: eventType, ResourceID: e.ResourceID, Data: e.Detail, Time: e.CreatedAt}
+	if e.UserID.Valid {
+		id := uuid.UUID(e.UserID.Bytes)
+		ev.UserID = &id
+	}
+	p.enqueue(e.TenantSchema, ev)
+}
+
+func (p *Publisher) enqueue(schema string, ev Event) {
+	select {
+	case p.queue <- queued{schema: schema, event: ev}:
+	default:
+		p.logger.Warn("event queue full, dropping event", "type", ev.Type, "schema", schema)
+	}
+}
+
+// auditEvents maps audited actions to event types by resource.
+var auditEvents = map[string]map[string]string{
+	"alert": {
+		"create":           AlertCreated,
+		"deduplicate":      AlertDeduplicated,
+		"acknowledge":      AlertAcknowledged,
+		"auto_acknowledge": AlertAcknowledged,
+		"resolve":          AlertResolved,
+		"auto_resolve":     AlertResolved,
+		"agent_resolve":    AlertResolved,
+	},
+	"incident": {
+		"create":              IncidentCreated,
+		"update":              IncidentUpdated,
+		"set_post_mortem_url": IncidentUpdated,
+		"delete":              IncidentDeleted,
+		"merge":               IncidentMerged,
+	},
+	// Roster changes that can change who is on call.
+	"roster": {
+		"update":              OnCallChanged,
+		"delete":              OnCallChanged,
+		"update_schedule":     OnCallChanged,
+		"generate_schedule":   OnCallChanged,
+		"add_member":          OnCallChanged,
+		"activate_member":     OnCallChanged,
+		"deactivate_member":   OnCallChanged,
+		"create_layer":        OnCallChanged,
+		"update_layer":        OnCallChanged,
+		"delete_layer":        OnCallChanged,
+		"create_override":     OnCallChanged,
+		"delete_override":     OnCallChanged,
+		"accept_swap_request": OnCallChanged,
+	},
+}
+
+func auditEventType(resource, action string) (string, bool) {
+	t, ok := auditEvents[resource][action]
+	return t, ok
+}
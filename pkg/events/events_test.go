@@ -0,0 +1,194 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wisbric/core/pkg/auth"
+
+	"github.com/wisbric/nightowl/internal/audit"
+)
+
+func TestAuditEventType(t *testing.T) {
+	tests := []struct {
+		resource, action string
+		want             string
+		ok               bool
+	}{
+		{"alert", "create", AlertCreated, true},
+		{"alert", "agent_resolve", AlertResolved, true},
+		{"alert", "acknowledge", AlertAcknowledged, true},
+		{"incident", "set_post_mortem_url", IncidentUpdated, true},
+		{"roster", "create_override", OnCallChanged, true},
+		// Pending swaps do not change who is on call.
+		{"roster", "create_swap_request", "", false},
+		{"api_key", "create", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := auditEventType(tt.resource, tt.action)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("auditEventType(%s, %s) = %q, %v; want %q, %v", tt.resource, tt.action, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPublishAudit(t *testing.T) {
+	p := NewPublisher(nil, slog.Default())
+	userID := uuid.New()
+	alertID := uuid.New()
+	at := time.Date(2026, 4, 1, 12, 0, 0, 0, time.UTC)
+
+	p.PublishAudit(audit.Entry{TenantSchema: "tenant_acme", Resource: "api_key", Action: "create"})
+	p.PublishAudit(audit.Entry{
+		TenantSchema: "tenant_acme",
+		UserID:       pgtype.UUID{Bytes: userID, Valid: true},
+		Resource:     "alert",
+		Action:       "acknowledge",
+		ResourceID:   alertID,
+		Detail:       json.RawMessage(`{"title":"Disk full"}`),
+		CreatedAt:    at,
+	})
+
+	if len(p.queue) != 1 {
+		t.Fatalf("queued %d events, want 1", len(p.queue))
+	}
+	q := <-p.queue
+	if q.schema != "tenant_acme" || q.event.Type != AlertAcknowledged || q.event.ResourceID != alertID {
+		t.Errorf("queued = %+v", q)
+	}
+	if q.event.UserID == nil || *q.event.UserID != userID || !q.event.Time.Equal(at) {
+		t.Errorf("event = %+v", q.event)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	var nilPublisher *Publisher
+	nilPublisher.Publish("tenant_acme", AlertEscalated, uuid.New(), nil) // must not panic
+
+	p := NewPublisher(nil, slog.Default())
+	p.Publish("", AlertEscalated, uuid.New(), nil)
+	p.Publish("tenant_acme", AlertEscalated, uuid.New(), map[string]int{"tier": 2})
+	if len(p.queue) != 1 {
+		t.Fatalf("queued %d events, want 1", len(p.queue))
+	}
+	if q := <-p.queue; string(q.event.Data) != `{"tier":2}` {
+		t.Errorf("data = %s", q.event.Data)
+	}
+
+	// A full queue drops instead of blocking.
+	for range queueSize + 1 {
+		p.Publish("tenant_acme", AlertEscalated, uuid.New(), nil)
+	}
+	if len(p.queue) != queueSize {
+		t.Errorf("queued %d events, want %d", len(p.queue), queueSize)
+	}
+}
+
+func TestWriteEvent(t *testing.T) {
+	id := uuid.MustParse("6f1c2a3e-0000-4000-8000-000000000001")
+	body, _ := json.Marshal(Event{Type: IncidentUpdated, ResourceID: id, Time: time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)})
+
+	var sb strings.Builder
+	if err := writeEvent(&sb, "1711929600000-0", string(body), nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "id: 1711929600000-0\nevent: incident.updated\n" +
+		`data: {"id":"1711929600000-0","type":"incident.updated","resource_id":"6f1c2a3e-0000-4000-8000-000000000001","time":"2026-04-01T00:00:00Z"}` + "\n\n"
+	if sb.String() != want {
+		t.Errorf("got %q\nwant %q", sb.String(), want)
+	}
+
+	sb.Reset()
+	if err := writeEvent(&sb, "1711929600000-1", string(body), []string{"alert"}); err != nil || sb.Len() != 0 {
+		t.Errorf("filtered event written: %q, %v", sb.String(), err)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		filter    string
+		eventType string
+		want      bool
+	}{
+		{"", AlertCreated, true},
+		{"alert", AlertCreated, true},
+		{"alert", AlertGroupUpdated, false},
+		{"alert_group,incident.updated", AlertGroupUpdated, true},
+		{"incident.updated", IncidentDeleted, false},
+	}
+	for _, tt := range tests {
+		if got := matches(parseTypes(tt.filter), tt.eventType); got != tt.want {
+			t.Errorf("matches(%q, %s) = %v, want %v", tt.filter, tt.eventType, got, tt.want)
+		}
+	}
+}
+
+func TestCompareIDs(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1-0", "1-0", 0},
+		{"1-1", "1-0", 1},
+		{"9-5", "10-0", -1},
+		{"1711929600000-12", "1711929600000-3", 1},
+	}
+	for _, tt := range tests {
+		if got := compareIDs(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareIDs(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+	for _, id := range []string{"", "abc", "1-", "-1", "1-x"} {
+		if _, _, ok := parseID(id); ok {
+			t.Errorf("parseID(%q) accepted", id)
+		}
+	}
+}
+
+type fakeLookup struct{ err error }
+
+func (f fakeLookup) LookupBySlug(context.Context, string) (uuid.UUID, string, error) {
+	return uuid.New(), "Acme", f.err
+}
+
+func TestHandler_Rejects(t *testing.T) {
+	identity := &auth.Identity{Subject: "u", TenantSlug: "acme"}
+	tests := []struct {
+		name   string
+		id     *auth.Identity
+		lookup fakeLookup
+		lastID string
+		want   int
+	}{
+		{"no identity", nil, fakeLookup{}, "", http.StatusUnauthorized},
+		{"unknown tenant", identity, fakeLookup{err: errors.New("not found")}, "", http.StatusUnauthorized},
+		{"bad last event id", identity, fakeLookup{}, "yesterday", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(nil, tt.lookup, slog.Default())
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/events", nil)
+			if tt.id != nil {
+				req = req.WithContext(auth.NewContext(req.Context(), tt.id))
+			}
+			if tt.lastID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastID)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
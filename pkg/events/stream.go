@@ -0,0 +1,248 @@
+package events
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/wisbric/core/pkg/auth"
+	"github.com/wisbric/core/pkg/httpserver"
+	coretenant "github.com/wisbric/core/pkg/tenant"
+
+	"github.com/wisbric/nightowl/pkg/tenant"
+)
+
+const (
+	// keepAlive is how often an idle stream sends a comment so proxies and
+	// clients notice dead connections.
+	keepAlive = 15 * time.Second
+	// retryMillis is the reconnect delay suggested to EventSource clients.
+	retryMillis = 3000
+	// replayBatch is how many retained events are read per XRANGE.
+	replayBatch = 500
+)
+
+// Handler serves a tenant's events as server-sent events.
+//
+// A stream lasts as long as the client stays connected, so it must not sit
+// behind the tenant middleware, which holds a database connection for the
+// whole request. The handler expects an authenticated identity and resolves
+// the tenant itself.
+type Handler struct {
+	rdb      *redis.Client
+	lookup   coretenant.TenantLookup
+	logger   *slog.Logger
+	shutdown chan struct{}
+	once     sync.Once
+}
+
+// NewHandler creates a stream Handler.
+func NewHandler(rdb *redis.Client, lookup coretenant.TenantLookup, logger *slog.Logger) *Handler {
+	return &Handler{rdb: rdb, lookup: lookup, logger: logger, shutdown: make(chan struct{})}
+}
+
+// Shutdown ends open streams. http.Server.Shutdown waits for active
+// requests without cancelling them, so register this with
+// RegisterOnShutdown; clients reconnect to another instance and resume.
+func (h *Handler) Shutdown() {
+	h.once.Do(func() { close(h.shutdown) })
+}
+
+// ServeHTTP streams events for the caller's tenant. A client resumes after
+// the event named by the Last-Event-ID header, or the last_event_id query
+// parameter for clients that cannot set headers. types limits the stream to
+// event types or their categories, e.g. types=alert,incident.updated.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := auth.FromContext(ctx)
+	if id == nil || id.TenantSlug == "" {
+		httpserver.RespondError(w, http.StatusUnauthorized, "unauthorized", "no authenticated tenant")
+		return
+	}
+	if _, _, err := h.lookup.LookupBySlug(ctx, id.TenantSlug); err != nil {
+		h.logger.Warn("tenant not found for event stream", "slug", id.TenantSlug, "error", err)
+		httpserver.RespondError(w, http.StatusUnauthorized, "unauthorized", "unknown tenant")
+		return
+	}
+	key := streamKey(tenant.SchemaName(id.TenantSlug))
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	if lastID != "" {
+		if _, _, ok := parseID(lastID); !ok {
+			httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid Last-Event-ID")
+			return
+		}
+	}
+	filter := parseTypes(r.URL.Query().Get("types"))
+
+	// Subscribe before replaying so nothing published in between is lost;
+	// live events already replayed are skipped by ID.
+	sub := h.rdb.Subscribe(ctx, key)
+	defer func() { _ = sub.Close() }()
+	if _, err := sub.Receive(ctx); err != nil {
+		h.logger.Error("subscribing to events", "error", err)
+		httpserver.RespondError(w, http.StatusServiceUnavailable, "unavailable", "event stream unavailable")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// The server's write timeout is meant for ordinary requests.
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", retryMillis); err != nil {
+		return
+	}
+
+	if lastID != "" {
+		var err error
+		if lastID, err = h.replay(ctx, w, key, lastID, filter); err != nil {
+			h.logger.Warn("replaying events", "error", err)
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	live := sub.Channel()
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.shutdown:
+			return
+		case <-ticker.C:
+			if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case msg, ok := <-live:
+			if !ok {
+				return
+			}
+			eventID, body, _ := strings.Cut(msg.Payload, " ")
+			if lastID != "" && compareIDs(eventID, lastID) <= 0 {
+				continue
+			}
+			lastID = eventID
+			if err := writeEvent(w, eventID, body, filter); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// replay writes the retained events after lastID and returns the ID of the
+// last one. If events after lastID may already have been trimmed, it sends
+// a reset instead.
+func (h *Handler) replay(ctx context.Context, w io.Writer, key, lastID string, filter []string) (string, error) {
+	oldest, err := h.rdb.XRangeN(ctx, key, "-", "+", 1).Result()
+	if err != nil {
+		return lastID, fmt.Errorf("reading oldest event: %w", err)
+	}
+	if len(oldest) == 0 || compareIDs(oldest[0].ID, lastID) > 0 {
+		_, err := fmt.Fprintf(w, "event: %s\ndata: {\"type\":%q}\n\n", StreamReset, StreamReset)
+		return lastID, err
+	}
+
+	for {
+		msgs, err := h.rdb.XRangeN(ctx, key, "("+lastID, "+", replayBatch).Result()
+		if err != nil {
+			return lastID, fmt.Errorf("reading events: %w", err)
+		}
+		for _, m := range msgs {
+			body, _ := m.Values["event"].(string)
+			if err := writeEvent(w, m.ID, body, filter); err != nil {
+				return lastID, err
+			}
+			lastID = m.ID
+		}
+		if len(msgs) < replayBatch {
+			return lastID, nil
+		}
+	}
+}
+
+// writeEvent writes a stored event in server-sent events format, unless
+// filter excludes its type.
+func writeEvent(w io.Writer, id, body string, filter []string) error {
+	var ev Event
+	if err := json.Unmarshal([]byte(body), &ev); err != nil {
+		return nil // Not one of ours; skip it.
+	}
+	if !matches(filter, ev.Type) {
+		return nil
+	}
+	ev.ID = id
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", id, ev.Type, data)
+	return err
+}
+
+// parseTypes splits the types query parameter.
+func parseTypes(s string) []string {
+	var out []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// matches reports whether eventType is selected by filter: an entry selects
+// its exact type or, without a dot, the whole category. An empty filter
+// selects everything.
+func matches(filter []string, eventType string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, f := range filter {
+		if f == eventType || strings.HasPrefix(eventType, f+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseID splits a Redis stream ID of the form "<ms>-<seq>".
+func parseID(id string) (ms, seq uint64, ok bool) {
+	a, b, found := strings.Cut(id, "-")
+	if !found {
+		return 0, 0, false
+	}
+	ms, err1 := strconv.ParseUint(a, 10, 64)
+	seq, err2 := strconv.ParseUint(b, 10, 64)
+	return ms, seq, err1 == nil && err2 == nil
+}
+
+// compareIDs orders stream IDs. Malformed IDs sort first.
+func compareIDs(a, b string) int {
+	ams, aseq, _ := parseID(a)
+	bms, bseq, _ := parseID(b)
+	return cmp.Or(cmp.Compare(ams, bms), cmp.Compare(aseq, bseq))
+}
@@ -0,0 +1,188 @@
+package heartbeat
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/wisbric/nightowl/internal/db"
+	"github.com/wisbric/nightowl/pkg/alert"
+	"github.com/wisbric/nightowl/pkg/messaging"
+	"github.com/wisbric/nightowl/pkg/tenant"
+)
+
+// Ingester feeds alerts into the alert pipeline. alert.WebhookHandler
+// implements it.
+type Ingester interface {
+	IngestAlert(ctx context.Context, a alert.NormalizedAlert) (string, alert.Response, error)
+}
+
+// Checker raises an alert for every monitor whose pings stopped, posting it
+// to chat, and resolves the alert once pings resume or the monitor is
+// disabled.
+type Checker struct {
+	pool     *pgxpool.Pool
+	alerts   Ingester
+	registry *messaging.Registry
+	logger   *slog.Logger
+
+	// Missed, if set, counts monitors that went down.
+	Missed prometheus.Counter
+}
+
+// NewChecker creates a Checker raising alerts through alerts and posting
+// them through the registry's providers.
+func NewChecker(pool *pgxpool.Pool, alerts Ingester, registry *messaging.Registry, logger *slog.Logger) *Checker {
+	return &Checker{pool: pool, alerts: alerts, registry: registry, logger: logger}
+}
+
+// Run checks every tenant's monitors at start and then every interval until
+// ctx is cancelled.
+func (c *Checker) Run(ctx context.Context, interval time.Duration) {
+	c.logger.Info("heartbeat checker started", "interval", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		c.checkAll(ctx)
+		select {
+		case <-ctx.Done():
+			c.logger.Info("heartbeat checker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Checker) checkAll(ctx context.Context) {
+	tenants, err := db.New(c.pool).ListTenants(ctx)
+	if err != nil {
+		c.logger.Error("listing tenants for heartbeat checks", "error", err)
+		return
+	}
+	for _, t := range tenants {
+		if ctx.Err() != nil {
+			return
+		}
+		tctx, release, err := tenant.Acquire(ctx, c.pool, t.Slug)
+		if errors.Is(err, tenant.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			c.logger.Error("acquiring tenant for heartbeat checks", "tenant", t.Slug, "error", err)
+			continue
+		}
+		c.checkTenant(tctx, t.Slug)
+		release()
+	}
+}
+
+// checkTenant resolves the alerts of recovered monitors and raises alerts
+// for monitors that went down. ctx must carry the tenant.
+func (c *Checker) checkTenant(ctx context.Context, slug string) {
+	store := NewStore(tenant.ConnFromContext(ctx))
+
+	recovered, err := store.recovered(ctx)
+	if err != nil {
+		c.logger.Error("checking heartbeat monitors", "tenant", slug, "error", err)
+		return
+	}
+	for _, m := range recovered {
+		if err := c.resolve(ctx, store, m); err != nil {
+			c.logger.Error("resolving heartbeat alert", "tenant", slug, "monitor", m.Name, "error", err)
+		}
+	}
+
+	down, err := store.markDown(ctx)
+	if err != nil {
+		c.logger.Error("checking heartbeat monitors", "tenant", slug, "error", err)
+		return
+	}
+	for _, m := range down {
+		if err := c.raise(ctx, store, m); err != nil {
+			c.logger.Error("raising heartbeat alert", "tenant", slug, "monitor", m.Name, "error", err)
+		}
+	}
+}
+
+// raise raises the alert for a down monitor and posts it to chat. If it
+// fails, the monitor stays down without an alert and the next check tries
+// again; the per-outage fingerprint deduplicates a retry of an alert that
+// was raised but not recorded.
+func (c *Checker) raise(ctx context.Context, store *Store, m stored) error {
+	action, resp, err := c.alerts.IngestAlert(ctx, missedAlert(m.Monitor, *m.DownSince))
+	if err != nil {
+		return err
+	}
+
+	var refs []messaging.MessageRef
+	if action == "create" {
+		c.logger.Warn("heartbeat missed", "monitor", m.Name, "down_since", *m.DownSince, "alert", resp.ID)
+		if c.Missed != nil {
+			c.Missed.Inc()
+		}
+		refs = c.post(ctx, m.Monitor, resp)
+	}
+	return store.setAlert(ctx, m.ID, *m.DownSince, resp.ID, refs)
+}
+
+// resolve resolves a recovered monitor's alert and marks its chat messages
+// resolved. The outage is only cleared once the alert is resolved.
+func (c *Checker) resolve(ctx context.Context, store *Store, m stored) error {
+	if _, _, err := c.alerts.IngestAlert(ctx, resolvedAlert(m.Monitor, *m.DownSince)); err != nil {
+		return err
+	}
+	cleared, err := store.clearDown(ctx, m.ID, *m.DownSince)
+	if err != nil || !cleared {
+		return err
+	}
+
+	resolvedBy := "heartbeat resumed"
+	if !m.Enabled {
+		resolvedBy = "monitor disabled"
+	}
+	c.logger.Info("heartbeat recovered", "monitor", m.Name, "resolved_by", resolvedBy)
+	if c.registry == nil {
+		return nil
+	}
+	msg := chatMessage(m.Monitor, m.AlertID, "resolved")
+	msg.ResolvedBy = resolvedBy
+	for _, ref := range m.chat {
+		p, err := c.registry.Get(ref.Provider)
+		if err != nil {
+			continue
+		}
+		if err := p.UpdateAlert(ctx, ref, msg); err != nil {
+			c.logger.Warn("updating heartbeat chat message", "provider", ref.Provider, "monitor", m.Name, "error", err)
+		}
+	}
+	return nil
+}
+
+// post posts a new heartbeat alert through every provider and returns the
+// messages posted. Posting is best effort.
+func (c *Checker) post(ctx context.Context, m Monitor, resp alert.Response) []messaging.MessageRef {
+	if c.registry == nil {
+		return nil
+	}
+	msg := chatMessage(m, &resp.ID, "firing")
+	if resp.Description != nil {
+		msg.Description = *resp.Description
+	}
+	var refs []messaging.MessageRef
+	for _, p := range c.registry.All() {
+		ref, err := p.PostAlert(ctx, msg)
+		if err != nil {
+			c.logger.Warn("posting heartbeat alert", "provider", p.Name(), "monitor", m.Name, "error", err)
+			continue
+		}
+		if ref != nil {
+			refs = append(refs, *ref)
+		}
+	}
+	return refs
+}
@@ -0,0 +1,255 @@
+package heartbeat
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wisbric/core/pkg/auth"
+	"github.com/wisbric/core/pkg/httpserver"
+
+	"github.com/wisbric/nightowl/internal/audit"
+	"github.com/wisbric/nightowl/pkg/tenant"
+)
+
+// Handler provides HTTP handlers for managing heartbeat monitors and for
+// receiving their pings.
+type Handler struct {
+	logger *slog.Logger
+	audit  *audit.Writer
+
+	// Alerts, if set, resolves the open alert of a monitor that is deleted
+	// while down.
+	Alerts Ingester
+}
+
+// NewHandler creates a Handler.
+func NewHandler(logger *slog.Logger, audit *audit.Writer) *Handler {
+	return &Handler{logger: logger, audit: audit}
+}
+
+// Routes returns a chi.Router with the monitor management routes mounted.
+func (h *Handler) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(auth.RequireRole(auth.RoleAdmin))
+	r.Get("/", h.handleList)
+	r.Post("/", h.handleCreate)
+	r.Route("/{id}", func(r chi.Router) {
+		r.Get("/", h.handleGet)
+		r.Put("/", h.handleUpdate)
+		r.Delete("/", h.handleDelete)
+		r.Post("/enable", h.handleEnable)
+		r.Post("/disable", h.handleDisable)
+		r.Post("/rotate-token", h.handleRotateToken)
+	})
+	return r
+}
+
+// PingRoutes returns the token-authenticated ping routes. They are mounted
+// outside the API-key protected router, below a tenant resolved from the
+// path. Any GET, HEAD or POST counts as a ping and the body is ignored, so
+// cron jobs can curl the URL and Alertmanager can post its always-firing
+// Watchdog alert to it.
+func (h *Handler) PingRoutes() chi.Router {
+	r := chi.NewRouter()
+	r.Get("/{token}", h.handlePing)
+	r.Head("/{token}", h.handlePing)
+	r.Post("/{token}", h.handlePing)
+	return r
+}
+
+func (h *Handler) store(r *http.Request) *Store {
+	return NewStore(tenant.ConnFromContext(r.Context()))
+}
+
+func parseMonitorID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid heartbeat monitor ID")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func tokenResponse(r *http.Request, m Monitor, raw string) MonitorTokenResponse {
+	slug := ""
+	if info := tenant.FromContext(r.Context()); info != nil {
+		slug = info.Slug
+	}
+	return MonitorTokenResponse{Monitor: m, Token: raw, PingPath: PingPath(slug, raw)}
+}
+
+func (h *Handler) handlePing(w http.ResponseWriter, r *http.Request) {
+	m, err := h.store(r).Ping(r.Context(), chi.URLParam(r, "token"))
+	if errors.Is(err, ErrMonitorNotFound) {
+		httpserver.RespondError(w, http.StatusUnauthorized, "unauthorized", "invalid heartbeat token")
+		return
+	}
+	if err != nil {
+		h.logger.Error("recording heartbeat ping", "error", err)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to record ping")
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, PingResponse{Status: "ok", Monitor: m.Name, ExpectedBy: m.ExpectedBy})
+}
+
+func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
+	items, err := h.store(r).List(r.Context())
+	if err != nil {
+		h.logger.Error("listing heartbeat monitors", "error", err)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to list heartbeat monitors")
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]any{"monitors": items, "count": len(items)})
+}
+
+func (h *Handler) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var req MonitorRequest
+	if !httpserver.DecodeAndValidate(w, r, &req) {
+		return
+	}
+
+	var createdBy pgtype.UUID
+	if id := auth.FromContext(r.Context()); id != nil && id.UserID != nil {
+		createdBy = pgtype.UUID{Bytes: *id.UserID, Valid: true}
+	}
+
+	raw, hash, prefix := generateToken()
+	m, err := h.store(r).Create(r.Context(), req, hash, prefix, createdBy)
+	if err != nil {
+		h.respondErr(w, "creating heartbeat monitor", err)
+		return
+	}
+
+	h.log(r, "create", m)
+	httpserver.Respond(w, http.StatusCreated, tokenResponse(r, m, raw))
+}
+
+func (h *Handler) handleGet(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseMonitorID(w, r)
+	if !ok {
+		return
+	}
+	m, err := h.store(r).Get(r.Context(), id)
+	if err != nil {
+		h.respondErr(w, "getting heartbeat monitor", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, m)
+}
+
+func (h *Handler) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseMonitorID(w, r)
+	if !ok {
+		return
+	}
+	var req MonitorRequest
+	if !httpserver.DecodeAndValidate(w, r, &req) {
+		return
+	}
+	m, err := h.store(r).Update(r.Context(), id, req)
+	if err != nil {
+		h.respondErr(w, "updating heartbeat monitor", err)
+		return
+	}
+
+	h.log(r, "update", m)
+	httpserver.Respond(w, http.StatusOK, m)
+}
+
+func (h *Handler) handleEnable(w http.ResponseWriter, r *http.Request) {
+	h.setEnabled(w, r, true)
+}
+
+// handleDisable stops a monitor from alerting. If it is down, the checker
+// resolves its alert.
+func (h *Handler) handleDisable(w http.ResponseWriter, r *http.Request) {
+	h.setEnabled(w, r, false)
+}
+
+func (h *Handler) setEnabled(w http.ResponseWriter, r *http.Request, enabled bool) {
+	id, ok := parseMonitorID(w, r)
+	if !ok {
+		return
+	}
+	m, err := h.store(r).SetEnabled(r.Context(), id, enabled)
+	if err != nil {
+		h.respondErr(w, "updating heartbeat monitor", err)
+		return
+	}
+
+	action := "disable"
+	if enabled {
+		action = "enable"
+	}
+	h.log(r, action, m)
+	httpserver.Respond(w, http.StatusOK, m)
+}
+
+// handleRotateToken issues a new ping token. The old token stops working
+// immediately.
+func (h *Handler) handleRotateToken(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseMonitorID(w, r)
+	if !ok {
+		return
+	}
+	raw, hash, prefix := generateToken()
+	m, err := h.store(r).RotateToken(r.Context(), id, hash, prefix)
+	if err != nil {
+		h.respondErr(w, "rotating heartbeat token", err)
+		return
+	}
+
+	h.log(r, "rotate_token", m)
+	httpserver.Respond(w, http.StatusOK, tokenResponse(r, m, raw))
+}
+
+func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseMonitorID(w, r)
+	if !ok {
+		return
+	}
+	m, err := h.store(r).Delete(r.Context(), id)
+	if err != nil {
+		h.respondErr(w, "deleting heartbeat monitor", err)
+		return
+	}
+	if m.DownSince != nil && h.Alerts != nil {
+		if _, _, err := h.Alerts.IngestAlert(r.Context(), resolvedAlert(m, *m.DownSince)); err != nil {
+			h.logger.Warn("resolving alert of deleted heartbeat monitor", "error", err, "monitor", m.Name)
+		}
+	}
+
+	h.log(r, "delete", m)
+	httpserver.Respond(w, http.StatusNoContent, nil)
+}
+
+func (h *Handler) log(r *http.Request, action string, m Monitor) {
+	if h.audit == nil {
+		return
+	}
+	detail, _ := json.Marshal(map[string]string{"name": m.Name, "token_prefix": m.TokenPrefix})
+	h.audit.LogFromRequest(r, action, "heartbeat_monitor", m.ID, detail)
+}
+
+// respondErr maps monitor errors to HTTP responses.
+func (h *Handler) respondErr(w http.ResponseWriter, what string, err error) {
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, ErrMonitorNotFound):
+		httpserver.RespondError(w, http.StatusNotFound, "not_found", err.Error())
+	case errors.Is(err, ErrMonitorExists):
+		httpserver.RespondError(w, http.StatusConflict, "conflict", err.Error())
+	case errors.As(err, &pgErr) && pgErr.Code == "23503":
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "service or escalation policy does not exist")
+	default:
+		h.logger.Error(what, "error", err)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed "+what)
+	}
+}
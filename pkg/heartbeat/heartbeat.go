@@ -0,0 +1,235 @@
+// Package heartbeat implements dead man's switch monitors: each monitor has
+// its own ping URL, and the worker raises an alert through the alert
+// pipeline when pings stop arriving, resolving it once they resume.
+package heartbeat
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wisbric/nightowl/pkg/alert"
+	"github.com/wisbric/nightowl/pkg/messaging"
+)
+
+// Source is the alert source of alerts raised for missed heartbeats.
+const Source = "heartbeat"
+
+// Monitor statuses. A monitor is late once its interval has passed without
+// a ping, and down once the grace period has passed too.
+const (
+	StatusNew      = "new"
+	StatusUp       = "up"
+	StatusLate     = "late"
+	StatusDown     = "down"
+	StatusDisabled = "disabled"
+)
+
+// DefaultSeverity is the severity of missed-heartbeat alerts unless a
+// monitor sets its own.
+const DefaultSeverity = "critical"
+
+var (
+	// ErrMonitorNotFound is returned when no monitor matches.
+	ErrMonitorNotFound = errors.New("heartbeat monitor not found")
+	// ErrMonitorExists is returned when a monitor name is taken.
+	ErrMonitorExists = errors.New("a heartbeat monitor with this name already exists")
+)
+
+// Monitor is a heartbeat monitor with its last-ping status.
+type Monitor struct {
+	ID                 uuid.UUID         `json:"id"`
+	Name               string            `json:"name"`
+	Description        string            `json:"description"`
+	TokenPrefix        string            `json:"token_prefix"`
+	IntervalSeconds    int               `json:"interval_seconds"`
+	GraceSeconds       int               `json:"grace_seconds"`
+	Severity           string            `json:"severity"`
+	Labels             map[string]string `json:"labels"`
+	ServiceID          *uuid.UUID        `json:"service_id,omitempty"`
+	EscalationPolicyID *uuid.UUID        `json:"escalation_policy_id,omitempty"`
+	Enabled            bool              `json:"enabled"`
+	Status             string            `json:"status"`
+	// ExpectedBy is when the next ping is due, grace period included.
+	ExpectedBy time.Time  `json:"expected_by"`
+	LastPingAt *time.Time `json:"last_ping_at,omitempty"`
+	PingCount  int64      `json:"ping_count"`
+	// DownSince and AlertID are set while the monitor's alert is open.
+	DownSince *time.Time `json:"down_since,omitempty"`
+	AlertID   *uuid.UUID `json:"alert_id,omitempty"`
+	CreatedBy *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// MonitorRequest is the JSON body for creating or updating a monitor.
+type MonitorRequest struct {
+	Name        string `json:"name" validate:"required,min=2,max=100"`
+	Description string `json:"description" validate:"max=1000"`
+	// IntervalSeconds is how often pings are expected, from a minute to a week.
+	IntervalSeconds int `json:"interval_seconds" validate:"required,min=60,max=604800"`
+	// GraceSeconds is how much longer a late ping is waited for before
+	// the alert is raised, up to a day.
+	GraceSeconds       int               `json:"grace_seconds" validate:"min=0,max=86400"`
+	Severity           string            `json:"severity" validate:"omitempty,oneof=info warning major critical"`
+	Labels             map[string]string `json:"labels"`
+	ServiceID          *uuid.UUID        `json:"service_id"`
+	EscalationPolicyID *uuid.UUID        `json:"escalation_policy_id"`
+}
+
+// MonitorTokenResponse is returned when a ping token is issued. The raw
+// token is only shown here; PingPath is the URL path to ping.
+type MonitorTokenResponse struct {
+	Monitor
+	Token    string `json:"token"`
+	PingPath string `json:"ping_path"`
+}
+
+// PingResponse is returned to a ping.
+type PingResponse struct {
+	Status     string    `json:"status"`
+	Monitor    string    `json:"monitor"`
+	ExpectedBy time.Time `json:"expected_by"`
+}
+
+// PingPath returns the ping URL path for a tenant and raw token.
+func PingPath(tenantSlug, token string) string {
+	return "/api/v1/heartbeat/" + tenantSlug + "/" + token
+}
+
+// generateToken creates a random ping token with prefix "nhb_", its SHA-256
+// hash, and a short prefix for display.
+func generateToken() (raw, hash, prefix string) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand failed: " + err.Error())
+	}
+	raw = fmt.Sprintf("nhb_%x", b)
+	return raw, hashToken(raw), raw[:12]
+}
+
+func hashToken(raw string) string {
+	h := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(h[:])
+}
+
+// statusAt returns the monitor's status at now.
+func (m *Monitor) statusAt(now time.Time) string {
+	grace := time.Duration(m.GraceSeconds) * time.Second
+	switch {
+	case !m.Enabled:
+		return StatusDisabled
+	case m.DownSince != nil || now.After(m.ExpectedBy):
+		return StatusDown
+	case now.After(m.ExpectedBy.Add(-grace)):
+		return StatusLate
+	case m.LastPingAt == nil:
+		return StatusNew
+	default:
+		return StatusUp
+	}
+}
+
+func alertTitle(m Monitor) string {
+	return "Heartbeat missed: " + m.Name
+}
+
+// fingerprint identifies the alert for one outage. Each outage gets its own
+// alert, so a monitor that goes down again right after recovering is not
+// deduplicated into the resolved alert.
+func fingerprint(id uuid.UUID, downSince time.Time) string {
+	return fmt.Sprintf("heartbeat:%s:%d", id, downSince.Unix())
+}
+
+// missedAlert returns the firing alert for a monitor that went down at
+// downSince. The monitor's labels are kept, plus a heartbeat label naming it.
+func missedAlert(m Monitor, downSince time.Time) alert.NormalizedAlert {
+	interval := time.Duration(m.IntervalSeconds) * time.Second
+	desc := fmt.Sprintf("No ping received within %s", interval)
+	if m.GraceSeconds > 0 {
+		desc += fmt.Sprintf(" plus a grace period of %s", time.Duration(m.GraceSeconds)*time.Second)
+	}
+	if m.LastPingAt != nil {
+		desc += ". Last ping at " + m.LastPingAt.UTC().Format(time.RFC3339) + "."
+	} else {
+		desc += ". No ping has been received yet."
+	}
+
+	labels := maps.Clone(m.Labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels["heartbeat"] = m.Name
+	annotations := map[string]string{"monitor_id": m.ID.String()}
+	if m.Description != "" {
+		annotations["summary"] = m.Description
+	}
+	if m.LastPingAt != nil {
+		annotations["last_ping_at"] = m.LastPingAt.UTC().Format(time.RFC3339)
+	}
+
+	a := alert.NormalizedAlert{
+		Fingerprint:        fingerprint(m.ID, downSince),
+		Status:             "firing",
+		Severity:           m.Severity,
+		Source:             Source,
+		Title:              alertTitle(m),
+		Description:        &desc,
+		ServiceID:          m.ServiceID,
+		EscalationPolicyID: m.EscalationPolicyID,
+	}
+	a.Labels, _ = json.Marshal(labels)
+	a.Annotations, _ = json.Marshal(annotations)
+	return a
+}
+
+// resolvedAlert returns the notification resolving a monitor's alert for
+// the outage that began at downSince.
+func resolvedAlert(m Monitor, downSince time.Time) alert.NormalizedAlert {
+	return alert.NormalizedAlert{
+		Fingerprint: fingerprint(m.ID, downSince),
+		Status:      "resolved",
+		Severity:    m.Severity,
+		Source:      Source,
+		Title:       alertTitle(m),
+	}
+}
+
+// chatMessage returns the chat notification for a monitor's alert.
+func chatMessage(m Monitor, alertID *uuid.UUID, status string) messaging.AlertMessage {
+	msg := messaging.AlertMessage{
+		Title:    alertTitle(m),
+		Severity: m.Severity,
+		Status:   status,
+	}
+	if alertID != nil {
+		msg.AlertID = alertID.String()
+	}
+	if m.DownSince != nil {
+		msg.FiredAt = *m.DownSince
+	}
+	return msg
+}
+
+func pgtypeUUIDToPtr(p pgtype.UUID) *uuid.UUID {
+	if !p.Valid {
+		return nil
+	}
+	id := uuid.UUID(p.Bytes)
+	return &id
+}
+
+func ptrToPgtypeUUID(id *uuid.UUID) pgtype.UUID {
+	if id == nil {
+		return pgtype.UUID{}
+	}
+	return pgtype.UUID{Bytes: *id, Valid: true}
+}
@@ -0,0 +1,121 @@
+package heartbeat
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wisbric/core/pkg/auth"
+)
+
+func TestGenerateToken(t *testing.T) {
+	raw, hash, prefix := generateToken()
+	if !strings.HasPrefix(raw, "nhb_") || len(raw) != 4+48 {
+		t.Errorf("raw token = %q, want nhb_ followed by 48 hex chars", raw)
+	}
+	if !strings.HasPrefix(raw, prefix) || len(prefix) != 12 {
+		t.Errorf("prefix = %q, want first 12 chars of token", prefix)
+	}
+	if hash != hashToken(raw) {
+		t.Error("hash does not match hashToken(raw)")
+	}
+}
+
+func TestPingPath(t *testing.T) {
+	if got := PingPath("acme", "nhb_abc"); got != "/api/v1/heartbeat/acme/nhb_abc" {
+		t.Errorf("PingPath = %q", got)
+	}
+}
+
+func TestMonitor_StatusAt(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	pinged := now.Add(-10 * time.Minute)
+	tests := []struct {
+		name string
+		m    Monitor
+		want string
+	}{
+		{"new", Monitor{Enabled: true, ExpectedBy: now.Add(time.Hour)}, StatusNew},
+		{"up", Monitor{Enabled: true, ExpectedBy: now.Add(time.Hour), LastPingAt: &pinged}, StatusUp},
+		{"late", Monitor{Enabled: true, GraceSeconds: 600, ExpectedBy: now.Add(5 * time.Minute), LastPingAt: &pinged}, StatusLate},
+		{"overdue", Monitor{Enabled: true, ExpectedBy: now.Add(-time.Second), LastPingAt: &pinged}, StatusDown},
+		{"down", Monitor{Enabled: true, ExpectedBy: now.Add(time.Hour), DownSince: &pinged}, StatusDown},
+		{"disabled", Monitor{ExpectedBy: now.Add(-time.Hour), DownSince: &pinged}, StatusDisabled},
+	}
+	for _, tt := range tests {
+		if got := tt.m.statusAt(now); got != tt.want {
+			t.Errorf("%s: status = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMissedAlert(t *testing.T) {
+	serviceID := uuid.New()
+	lastPing := time.Date(2026, 3, 2, 11, 0, 0, 0, time.UTC)
+	downSince := lastPing.Add(6 * time.Minute)
+	m := Monitor{
+		ID: uuid.New(), Name: "watchdog", Description: "Alertmanager Watchdog",
+		IntervalSeconds: 300, GraceSeconds: 60, Severity: "critical",
+		Labels:    map[string]string{"team": "sre", "heartbeat": "spoofed"},
+		ServiceID: &serviceID, LastPingAt: &lastPing,
+	}
+
+	a := missedAlert(m, downSince)
+	if a.Status != "firing" || a.Source != Source || a.Severity != "critical" || a.Title != "Heartbeat missed: watchdog" {
+		t.Errorf("alert = %+v", a)
+	}
+	if a.ServiceID == nil || *a.ServiceID != serviceID {
+		t.Errorf("service = %v, want %v", a.ServiceID, serviceID)
+	}
+	var labels map[string]string
+	_ = json.Unmarshal(a.Labels, &labels)
+	if labels["team"] != "sre" || labels["heartbeat"] != "watchdog" {
+		t.Errorf("labels = %v", labels)
+	}
+	if m.Labels["heartbeat"] != "spoofed" {
+		t.Error("missedAlert modified the monitor's labels")
+	}
+	if a.Description == nil || !strings.Contains(*a.Description, "5m0s plus a grace period of 1m0s") ||
+		!strings.Contains(*a.Description, "2026-03-02T11:00:00Z") {
+		t.Errorf("description = %v", a.Description)
+	}
+
+	// The resolution matches this outage only; the next outage is a new alert.
+	if r := resolvedAlert(m, downSince); r.Status != "resolved" || r.Fingerprint != a.Fingerprint {
+		t.Errorf("resolved = %+v, want fingerprint %q", r, a.Fingerprint)
+	}
+	if next := missedAlert(m, downSince.Add(time.Hour)); next.Fingerprint == a.Fingerprint {
+		t.Error("a later outage reused the fingerprint")
+	}
+}
+
+func TestHandler_RejectsBadRequests(t *testing.T) {
+	h := NewHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	admin := &auth.Identity{Subject: "admin", Role: auth.RoleAdmin}
+	tests := []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodGet, "/not-a-uuid", "", http.StatusBadRequest},
+		{http.MethodPost, "/not-a-uuid/disable", "", http.StatusBadRequest},
+		{http.MethodPost, "/", `{"name":"nightly backup","interval_seconds":30}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, "/", `{"name":"nightly backup","interval_seconds":86400,"severity":"page"}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, "/", `{"name":"nightly backup","interval_seconds":86400,"grace_seconds":-1}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		r = r.WithContext(auth.NewContext(r.Context(), admin))
+		rec := httptest.NewRecorder()
+		h.Routes().ServeHTTP(rec, r)
+		if rec.Code != tt.status {
+			t.Errorf("%s %s %s: status = %d, want %d", tt.method, tt.path, tt.body, rec.Code, tt.status)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package heartbeat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wisbric/nightowl/internal/db"
+	"github.com/wisbric/nightowl/pkg/messaging"
+)
+
+const monitorColumns = `id, name, description, token_prefix, interval_seconds, grace_seconds,
+	severity, labels, service_id, escalation_policy_id, enabled, expected_by, last_ping_at,
+	ping_count, down_since, alert_id, chat_messages, created_by, created_at, updated_at`
+
+// Store provides database operations for heartbeat monitors.
+type Store struct {
+	dbtx db.DBTX
+}
+
+// NewStore creates a Store backed by the given connection.
+func NewStore(dbtx db.DBTX) *Store {
+	return &Store{dbtx: dbtx}
+}
+
+// stored is a monitor with the chat messages posted for its open alert.
+type stored struct {
+	Monitor
+	chat []messaging.MessageRef
+}
+
+func scanMonitor(row pgx.Row) (stored, error) {
+	var m stored
+	var labels, chat []byte
+	var serviceID, policyID, alertID, createdBy pgtype.UUID
+	var lastPing, downSince pgtype.Timestamptz
+	if err := row.Scan(&m.ID, &m.Name, &m.Description, &m.TokenPrefix, &m.IntervalSeconds, &m.GraceSeconds,
+		&m.Severity, &labels, &serviceID, &policyID, &m.Enabled, &m.ExpectedBy, &lastPing,
+		&m.PingCount, &downSince, &alertID, &chat, &createdBy, &m.CreatedAt, &m.UpdatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return stored{}, ErrMonitorNotFound
+		}
+		return stored{}, err
+	}
+	if err := json.Unmarshal(labels, &m.Labels); err != nil {
+		return stored{}, fmt.Errorf("decoding labels: %w", err)
+	}
+	if m.Labels == nil {
+		m.Labels = map[string]string{}
+	}
+	if err := json.Unmarshal(chat, &m.chat); err != nil {
+		return stored{}, fmt.Errorf("decoding chat messages: %w", err)
+	}
+	m.ServiceID = pgtypeUUIDToPtr(serviceID)
+	m.EscalationPolicyID = pgtypeUUIDToPtr(policyID)
+	m.AlertID = pgtypeUUIDToPtr(alertID)
+	m.CreatedBy = pgtypeUUIDToPtr(createdBy)
+	if lastPing.Valid {
+		m.LastPingAt = &lastPing.Time
+	}
+	if downSince.Valid {
+		m.DownSince = &downSince.Time
+	}
+	m.Status = m.statusAt(time.Now())
+	return m, nil
+}
+
+// query returns the monitors a query selects.
+func (s *Store) query(ctx context.Context, sql string, args ...any) ([]stored, error) {
+	rows, err := s.dbtx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []stored{}
+	for rows.Next() {
+		m, err := scanMonitor(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning heartbeat monitor: %w", err)
+		}
+		items = append(items, m)
+	}
+	return items, rows.Err()
+}
+
+// mapUniqueViolation turns a duplicate-name insert into ErrMonitorExists.
+func mapUniqueViolation(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrMonitorExists
+	}
+	return err
+}
+
+// List returns all monitors ordered by name.
+func (s *Store) List(ctx context.Context) ([]Monitor, error) {
+	items, err := s.query(ctx, `SELECT `+monitorColumns+` FROM heartbeat_monitors ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("listing heartbeat monitors: %w", err)
+	}
+	monitors := make([]Monitor, len(items))
+	for i, m := range items {
+		monitors[i] = m.Monitor
+	}
+	return monitors, nil
+}
+
+// Get returns one monitor by ID.
+func (s *Store) Get(ctx context.Context, id uuid.UUID) (Monitor, error) {
+	m, err := scanMonitor(s.dbtx.QueryRow(ctx,
+		`SELECT `+monitorColumns+` FROM heartbeat_monitors WHERE id = $1`, id))
+	return m.Monitor, err
+}
+
+// Create inserts a monitor with the given token hash and prefix. The first
+// ping is due one interval and grace period from now.
+func (s *Store) Create(ctx context.Context, req MonitorRequest, hash, prefix string, createdBy pgtype.UUID) (Monitor, error) {
+	labels, _ := json.Marshal(nonNilLabels(req.Labels))
+	m, err := scanMonitor(s.dbtx.QueryRow(ctx, `
+		INSERT INTO heartbeat_monitors (interval_seconds, grace_seconds, name, description,
+		    token_hash, token_prefix, severity, labels, service_id, escalation_policy_id,
+		    created_by, expected_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11,
+		    now() + make_interval(secs => $1::int + $2::int))
+		RETURNING `+monitorColumns,
+		req.IntervalSeconds, req.GraceSeconds, req.Name, req.Description, hash, prefix,
+		severityOrDefault(req.Severity), labels,
+		ptrToPgtypeUUID(req.ServiceID), ptrToPgtypeUUID(req.EscalationPolicyID), createdBy))
+	if err != nil {
+		return Monitor{}, fmt.Errorf("creating heartbeat monitor: %w", mapUniqueViolation(err))
+	}
+	return m.Monitor, nil
+}
+
+// Update replaces a monitor's settings. The next ping is due one new
+// interval and grace period after the last ping, or from now if there was
+// none. The token and ping history are kept.
+func (s *Store) Update(ctx context.Context, id uuid.UUID, req MonitorRequest) (Monitor, error) {
+	labels, _ := json.Marshal(nonNilLabels(req.Labels))
+	m, err := scanMonitor(s.dbtx.QueryRow(ctx, `
+		UPDATE heartbeat_monitors
+		SET interval_seconds = $1, grace_seconds = $2, name = $3, description = $4,
+		    severity = $5, labels = $6, service_id = $7, escalation_policy_id = $8,
+		    expected_by = COALESCE(last_ping_at, now()) + make_interval(secs => $1::int + $2::int),
+		    updated_at = now()
+		WHERE id = $9
+		RETURNING `+monitorColumns,
+		req.IntervalSeconds, req.GraceSeconds, req.Name, req.Description,
+		severityOrDefault(req.Severity), labels,
+		ptrToPgtypeUUID(req.ServiceID), ptrToPgtypeUUID(req.EscalationPolicyID), id))
+	if err != nil {
+		return Monitor{}, mapUniqueViolation(err)
+	}
+	return m.Monitor, nil
+}
+
+// SetEnabled enables or disables a monitor. Enabling a disabled monitor
+// restarts its clock, so the next ping is due one interval from now.
+func (s *Store) SetEnabled(ctx context.Context, id uuid.UUID, enabled bool) (Monitor, error) {
+	m, err := scanMonitor(s.dbtx.QueryRow(ctx, `
+		UPDATE heartbeat_monitors
+		SET expected_by = CASE WHEN $2 AND NOT enabled
+		        THEN now() + make_interval(secs => interval_seconds + grace_seconds)
+		        ELSE expected_by END,
+		    enabled = $2, updated_at = now()
+		WHERE id = $1
+		RETURNING `+monitorColumns, id, enabled))
+	return m.Monitor, err
+}
+
+// RotateToken replaces a monitor's token hash and prefix.
+func (s *Store) RotateToken(ctx context.Context, id uuid.UUID, hash, prefix string) (Monitor, error) {
+	m, err := scanMonitor(s.dbtx.QueryRow(ctx, `
+		UPDATE heartbeat_monitors SET token_hash = $2, token_prefix = $3, updated_at = now()
+		WHERE id = $1
+		RETURNING `+monitorColumns, id, hash, prefix))
+	return m.Monitor, err
+}
+
+// Delete removes a monitor and returns it as it was.
+func (s *Store) Delete(ctx context.Context, id uuid.UUID) (Monitor, error) {
+	m, err := scanMonitor(s.dbtx.QueryRow(ctx,
+		`DELETE FROM heartbeat_monitors WHERE id = $1 RETURNING `+monitorColumns, id))
+	return m.Monitor, err
+}
+
+// Ping records a ping for the monitor whose token hashes to the given
+// value, moving its next deadline one interval and grace period ahead.
+func (s *Store) Ping(ctx context.Context, raw string) (Monitor, error) {
+	m, err := scanMonitor(s.dbtx.QueryRow(ctx, `
+		UPDATE heartbeat_monitors
+		SET last_ping_at = now(), ping_count = ping_count + 1,
+		    expected_by = now() + make_interval(secs => interval_seconds + grace_seconds)
+		WHERE token_hash = $1
+		RETURNING `+monitorColumns, hashToken(raw)))
+	return m.Monitor, err
+}
+
+// markDown marks enabled monitors whose deadline has passed as down since
+// that deadline, and returns every down monitor that has no alert yet,
+// including ones an earlier check failed to raise.
+func (s *Store) markDown(ctx context.Context) ([]stored, error) {
+	if _, err := s.dbtx.Exec(ctx, `
+		UPDATE heartbeat_monitors SET down_since = expected_by
+		WHERE enabled AND down_since IS NULL AND expected_by < now()`); err != nil {
+		return nil, fmt.Errorf("marking heartbeat monitors down: %w", err)
+	}
+	items, err := s.query(ctx, `SELECT `+monitorColumns+` FROM heartbeat_monitors
+		WHERE enabled AND down_since IS NOT NULL AND alert_id IS NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("listing down heartbeat monitors: %w", err)
+	}
+	return items, nil
+}
+
+// recovered returns down monitors that were pinged again or disabled.
+func (s *Store) recovered(ctx context.Context) ([]stored, error) {
+	items, err := s.query(ctx, `SELECT `+monitorColumns+` FROM heartbeat_monitors
+		WHERE down_since IS NOT NULL AND (NOT enabled OR expected_by > now())`)
+	if err != nil {
+		return nil, fmt.Errorf("listing recovered heartbeat monitors: %w", err)
+	}
+	return items, nil
+}
+
+// setAlert records the alert raised for a monitor's outage and the chat
+// messages posted for it.
+func (s *Store) setAlert(ctx context.Context, id uuid.UUID, downSince time.Time, alertID uuid.UUID, chat []messaging.MessageRef) error {
+	if chat == nil {
+		chat = []messaging.MessageRef{}
+	}
+	refs, _ := json.Marshal(chat)
+	_, err := s.dbtx.Exec(ctx, `
+		UPDATE heartbeat_monitors SET alert_id = $3, chat_messages = $4
+		WHERE id = $1 AND down_since = $2`, id, downSince, alertID, refs)
+	if err != nil {
+		return fmt.Errorf("recording heartbeat alert: %w", err)
+	}
+	return nil
+}
+
+// clearDown ends a monitor's outage. It reports false if the outage was
+// already ended, e.g. by another worker.
+func (s *Store) clearDown(ctx context.Context, id uuid.UUID, downSince time.Time) (bool, error) {
+	tag, err := s.dbtx.Exec(ctx, `
+		UPDATE heartbeat_monitors SET down_since = NULL, alert_id = NULL, chat_messages = '[]'
+		WHERE id = $1 AND down_since = $2`, id, downSince)
+	if err != nil {
+		return false, fmt.Errorf("clearing heartbeat outage: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func severityOrDefault(s string) string {
+	if s == "" {
+		return DefaultSeverity
+	}
+	return s
+}
+
+func nonNilLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return map[string]string{}
+	}
+	return labels
+}
@@ -0,0 +1,175 @@
+// Package mailer sends plain-text email through an SMTP relay.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sendTimeout bounds one delivery when ctx has no earlier deadline.
+const sendTimeout = 30 * time.Second
+
+// ErrInvalidMessage is returned for messages that cannot be sent as given,
+// such as a bad recipient address or a header value spanning lines.
+var ErrInvalidMessage = errors.New("invalid message")
+
+// Message is a plain-text email to one recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+	// Headers are extra headers, such as List-Unsubscribe.
+	Headers map[string]string
+}
+
+// Sender sends email.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTP sends mail through an SMTP relay. It upgrades to TLS when the relay
+// offers STARTTLS and authenticates with PLAIN when a username is set.
+type SMTP struct {
+	addr     string
+	from     string
+	username string
+	password string
+}
+
+// NewSMTP creates an SMTP sender for the relay at addr (host:port). from is
+// the From header, e.g. "Acme Status <status@acme.example>".
+func NewSMTP(addr, from, username, password string) *SMTP {
+	return &SMTP{addr: addr, from: from, username: username, password: password}
+}
+
+// Send delivers msg to the relay.
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	from, err := mail.ParseAddress(s.from)
+	if err != nil {
+		return fmt.Errorf("parsing sender address: %w", err)
+	}
+	data, to, err := format(s.from, from.Address, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	host, _, err := net.SplitHostPort(s.addr)
+	if err != nil {
+		return fmt.Errorf("parsing relay address: %w", err)
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, sendTimeout)
+		defer cancel()
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("connecting to relay: %w", err)
+	}
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("greeting relay: %w", err)
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}); err != nil {
+			return fmt.Errorf("starting TLS: %w", err)
+		}
+	}
+	if s.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.username, s.password, host)); err != nil {
+			return fmt.Errorf("authenticating: %w", err)
+		}
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return fmt.Errorf("MAIL FROM: %w", err)
+	}
+	if err := c.Rcpt(to); err != nil {
+		return fmt.Errorf("RCPT TO: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("DATA: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("writing message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("sending message: %w", err)
+	}
+	return c.Quit()
+}
+
+// format renders msg as an RFC 5322 message with a quoted-printable UTF-8
+// body, which also turns line endings into CRLF, and returns it with the
+// recipient's bare address.
+func format(fromHeader, fromAddr string, msg Message, now time.Time) ([]byte, string, error) {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: recipient %q: %v", ErrInvalidMessage, msg.To, err)
+	}
+	headers := map[string]string{
+		"From":                      fromHeader,
+		"To":                        to.String(),
+		"Subject":                   mime.QEncoding.Encode("utf-8", msg.Subject),
+		"Date":                      now.Format(time.RFC1123Z),
+		"Message-ID":                messageID(fromAddr),
+		"MIME-Version":              "1.0",
+		"Content-Type":              `text/plain; charset="utf-8"`,
+		"Content-Transfer-Encoding": "quoted-printable",
+	}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+
+	var buf bytes.Buffer
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		v := headers[k]
+		if strings.ContainsAny(k+v, "\r\n") {
+			return nil, "", fmt.Errorf("%w: header %s spans lines", ErrInvalidMessage, k)
+		}
+		fmt.Fprintf(&buf, "%s: %s\r\n", k, v)
+	}
+	buf.WriteString("\r\n")
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write([]byte(msg.Body)); err != nil {
+		return nil, "", err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), to.Address, nil
+}
+
+// messageID returns a random Message-ID in the sender's domain.
+func messageID(fromAddr string) string {
+	domain := "nightowl"
+	if at := strings.LastIndex(fromAddr, "@"); at >= 0 {
+		domain = fromAddr[at+1:]
+	}
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("<%x@%s>", b, domain)
+}
@@ -0,0 +1,122 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wisbric/nightowl/pkg/alert"
+	"github.com/wisbric/nightowl/pkg/emailingest"
+)
+
+func TestFormat(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	msg := Message{
+		To:      "Ops <ops@example.com>",
+		Subject: "Störung: API",
+		Body:    "Line one\nLine two\n",
+		Headers: map[string]string{"List-Unsubscribe": "<https://status.example.com/u?token=abc>"},
+	}
+	data, to, err := format("Acme Status <status@acme.example>", "status@acme.example", msg, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if to != "ops@example.com" {
+		t.Errorf("recipient = %q", to)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("parsing message: %v", err)
+	}
+	if got, _ := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject")); got != "Störung: API" {
+		t.Errorf("subject = %q", got)
+	}
+	if got := parsed.Header.Get("List-Unsubscribe"); got != "<https://status.example.com/u?token=abc>" {
+		t.Errorf("List-Unsubscribe = %q", got)
+	}
+	if got := parsed.Header.Get("Message-Id"); !strings.HasSuffix(got, "@acme.example>") {
+		t.Errorf("Message-ID = %q", got)
+	}
+	if got := parsed.Header.Get("Date"); got != "Mon, 02 Mar 2026 12:00:00 +0000" {
+		t.Errorf("Date = %q", got)
+	}
+	if !strings.Contains(string(data), "\r\n\r\nLine one\r\nLine two\r\n") {
+		t.Errorf("body not CRLF-terminated: %q", data)
+	}
+}
+
+func TestFormat_RejectsBadMessages(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  Message
+	}{
+		{"bad recipient", Message{To: "not an address"}},
+		{"header injection", Message{To: "ops@example.com", Headers: map[string]string{"X-Tag": "a\r\nBcc: evil@example.com"}}},
+	}
+	for _, tt := range tests {
+		if _, _, err := format("status@acme.example", "status@acme.example", tt.msg, time.Now()); !errors.Is(err, ErrInvalidMessage) {
+			t.Errorf("%s: err = %v, want ErrInvalidMessage", tt.name, err)
+		}
+	}
+}
+
+type recorder struct {
+	mu  sync.Mutex
+	got map[string]*alert.EmailMessage
+}
+
+func (r *recorder) CheckRecipient(context.Context, string) error { return nil }
+
+func (r *recorder) Deliver(_ context.Context, addr string, msg *alert.EmailMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.got[addr] = msg
+	return nil
+}
+
+func TestSMTP_Send(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &recorder{got: map[string]*alert.EmailMessage{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := emailingest.NewServer(emailingest.Config{Hostname: "relay.example.com"}, rec, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = srv.Serve(ctx, ln)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	s := NewSMTP(ln.Addr().String(), "Acme Status <status@acme.example>", "", "")
+	err = s.Send(context.Background(), Message{
+		To: "ops@example.com", Subject: "[Acme] API errors: Investigating",
+		Body: "We are looking into elevated error rates.\n",
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	got := rec.got["ops@example.com"]
+	if got == nil {
+		t.Fatal("message not delivered")
+	}
+	if got.From != "status@acme.example" || got.Subject != "[Acme] API errors: Investigating" {
+		t.Errorf("message = %+v", got)
+	}
+	if !strings.Contains(got.Text, "elevated error rates") {
+		t.Errorf("text = %q", got.Text)
+	}
+}
@@ -99,6 +99,15 @@ func (c *Client) GetUserByEmail(ctx context.Context, email string) (*MMUser, err
 	return &user, nil
 }
 
+// GetUser looks up a user by ID.
+func (c *Client) GetUser(ctx context.Context, userID string) (*MMUser, error) {
+	var user MMUser
+	if err := c.do(ctx, http.MethodGet, "/api/v4/users/"+userID, nil, &user); err != nil {
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+	return &user, nil
+}
+
 // GetMe returns the authenticated bot user.
 func (c *Client) GetMe(ctx context.Context) (*MMUser, error) {
 	var user MMUser
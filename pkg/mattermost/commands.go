@@ -1,16 +1,25 @@
 package mattermost
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/wisbric/core/pkg/auth"
+
 	"github.com/wisbric/nightowl/internal/db"
+	"github.com/wisbric/nightowl/pkg/roster"
 	"github.com/wisbric/nightowl/pkg/tenant"
+	"github.com/wisbric/nightowl/pkg/user"
 )
 
 // commandPayload is the JSON body Mattermost sends for slash commands.
@@ -44,7 +53,7 @@ func (h *Handler) handleCommands(w http.ResponseWriter, r *http.Request) {
 
 	parts := strings.Fields(cmd.Text)
 	if len(parts) == 0 {
-		respondMM(w, "ephemeral", "Usage: /nightowl <search|oncall|ack|resolve|roster> [args]")
+		respondMM(w, "ephemeral", "Usage: /nightowl <search|oncall|ack|resolve|roster|swap|link> [args]")
 		return
 	}
 
@@ -62,8 +71,12 @@ func (h *Handler) handleCommands(w http.ResponseWriter, r *http.Request) {
 		h.handleResolveCmd(w, r, cmd, args)
 	case "roster":
 		h.handleRosterCmd(w, r, cmd, args)
+	case "swap":
+		h.handleSwapCmd(w, r, cmd, args)
+	case "link":
+		h.handleLinkCmd(w, r, cmd, args)
 	default:
-		respondMM(w, "ephemeral", "Unknown command: "+subcommand+". Available: search, oncall, ack, resolve, roster")
+		respondMM(w, "ephemeral", "Unknown command: "+subcommand+". Available: search, oncall, ack, resolve, roster, swap, link")
 	}
 }
 
@@ -106,31 +119,51 @@ func (h *Handler) handleSearchCmd(w http.ResponseWriter, r *http.Request, cmd co
 }
 
 func (h *Handler) handleOnCallCmd(w http.ResponseWriter, r *http.Request, cmd commandPayload, args []string) {
-	conn, q, err := h.acquireTenantConn(r)
+	conn, _, err := h.acquireTenantConn(r)
 	if err != nil {
 		respondMM(w, "ephemeral", "Internal error.")
 		return
 	}
 	defer conn.Release()
 
-	rosters, err := q.ListRosters(r.Context())
+	svc := roster.NewService(conn, h.logger)
+	rosters, err := svc.ListRosters(r.Context())
 	if err != nil {
 		h.logger.Error("listing rosters from mattermost", "error", err)
 		respondMM(w, "ephemeral", "Failed to list rosters.")
 		return
 	}
 
-	filterName := ""
-	if len(args) > 0 {
-		filterName = strings.ToLower(strings.Join(args, " "))
-	}
+	filter, atArg := roster.SplitOnCallArgs(args)
+	filterName := strings.ToLower(filter)
 
+	now := time.Now()
+	mentions := newMentionCache(roster.NewStore(conn), h.provider)
 	var lines []string
-	for _, roster := range rosters {
-		if filterName != "" && !strings.Contains(strings.ToLower(roster.Name), filterName) {
+	for _, ro := range rosters {
+		if !ro.IsActive {
 			continue
 		}
-		lines = append(lines, fmt.Sprintf("- **%s** (%s)", roster.Name, roster.Timezone))
+		if filterName != "" && !strings.Contains(strings.ToLower(ro.Name), filterName) {
+			continue
+		}
+		loc, err := time.LoadLocation(ro.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		at := now
+		if atArg != "" {
+			if at, err = roster.ParseChatTime(atArg, loc, now); err != nil {
+				respondMM(w, "ephemeral", "Usage: /nightowl oncall [roster] [at <time>]: "+err.Error())
+				return
+			}
+		}
+		sum, err := svc.GetOnCallSummary(r.Context(), ro, at)
+		if err != nil {
+			h.logger.Error("resolving on-call from mattermost", "error", err, "roster_id", ro.ID)
+			continue
+		}
+		lines = append(lines, onCallLine(r.Context(), mentions, sum, at, loc))
 	}
 
 	if len(lines) == 0 {
@@ -138,7 +171,72 @@ func (h *Handler) handleOnCallCmd(w http.ResponseWriter, r *http.Request, cmd co
 		return
 	}
 
-	respondMM(w, "ephemeral", "### Current On-Call\n"+strings.Join(lines, "\n"))
+	title := "### Current On-Call\n"
+	if atArg != "" {
+		title = "### On-Call at " + atArg + "\n"
+	}
+	respondMM(w, "ephemeral", title+strings.Join(lines, "\n"))
+}
+
+// onCallLine renders a roster's on-call summary as a Markdown list item with
+// Mattermost mentions.
+func onCallLine(ctx context.Context, mentions *mentionCache, sum *roster.OnCallSummary, at time.Time, loc *time.Location) string {
+	oc := sum.Current
+	display := "Unassigned"
+	if oc.Primary != nil {
+		display = mentions.mention(ctx, *oc.Primary)
+	}
+	if oc.DelegatedFrom != nil {
+		display += " (via " + oc.RosterName + ")"
+	}
+	line := fmt.Sprintf("- **%s**: %s (%s)", sum.Roster.Name, display, sum.Roster.Timezone)
+	if oc.Source == "override" {
+		line += " _(override)_"
+	}
+	if oc.Secondary != nil {
+		line += "\n  Secondary: " + mentions.mention(ctx, *oc.Secondary)
+	}
+	if sum.HandoffAt != nil {
+		next := "nobody"
+		if sum.Next != nil && sum.Next.Primary != nil {
+			next = mentions.mention(ctx, *sum.Next.Primary)
+		}
+		line += fmt.Sprintf("\n  Handoff in %s (%s) to %s", roster.FormatRemaining(sum.HandoffAt.Sub(at)),
+			sum.HandoffAt.In(loc).Format("Mon Jan 2 15:04 MST"), next)
+	}
+	return line
+}
+
+// mentionCache turns NightOwl users into Mattermost mentions, looking each
+// user up by email at most once per command.
+type mentionCache struct {
+	store    *roster.Store
+	provider *Provider
+	cache    map[uuid.UUID]string
+}
+
+func newMentionCache(store *roster.Store, provider *Provider) *mentionCache {
+	return &mentionCache{store: store, provider: provider, cache: make(map[uuid.UUID]string)}
+}
+
+// mention returns "@username" for users found in Mattermost, else the
+// display name. Unlike Provider.LookupUser this needs the username, not the
+// user ID.
+func (m *mentionCache) mention(ctx context.Context, e roster.OnCallEntry) string {
+	if v, ok := m.cache[e.UserID]; ok {
+		return v
+	}
+	v := e.DisplayName
+	if !m.provider.client.IsEnabled() {
+		return v
+	}
+	if emails, err := m.store.UserEmails(ctx, []uuid.UUID{e.UserID}); err == nil && emails[e.UserID] != "" {
+		if u, err := m.provider.client.GetUserByEmail(ctx, emails[e.UserID]); err == nil && u.Username != "" {
+			v = "@" + u.Username
+		}
+	}
+	m.cache[e.UserID] = v
+	return v
 }
 
 func (h *Handler) handleAckCmd(w http.ResponseWriter, r *http.Request, cmd commandPayload, args []string) {
@@ -160,14 +258,31 @@ func (h *Handler) handleAckCmd(w http.ResponseWriter, r *http.Request, cmd comma
 	}
 	defer conn.Release()
 
-	_, err = q.AcknowledgeAlert(r.Context(), db.AcknowledgeAlertParams{
-		ID: alertID,
-	})
+	actor, msg, err := h.actingUser(r.Context(), conn, cmd.UserID, auth.RoleEngineer, "acknowledge alerts")
 	if err != nil {
+		h.logger.Error("resolving mattermost user for ack", "error", err, "user", cmd.UserID)
+		respondMM(w, "ephemeral", "Internal error.")
+		return
+	}
+	if msg != "" {
+		respondMM(w, "ephemeral", msg)
+		return
+	}
+
+	row, err := q.AcknowledgeAlert(r.Context(), db.AcknowledgeAlertParams{
+		ID:             alertID,
+		AcknowledgedBy: pgtype.UUID{Bytes: actor.ID, Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		respondMM(w, "ephemeral", "Alert not found.")
+		return
+	} else if err != nil {
 		h.logger.Error("acknowledging alert from mattermost", "error", err, "alert_id", alertID)
 		respondMM(w, "ephemeral", "Failed to acknowledge alert.")
 		return
 	}
+	detail, _ := json.Marshal(map[string]string{"title": row.Title})
+	h.auditChat(actor.ID, "acknowledge", "alert", alertID, detail)
 
 	respondMM(w, "in_channel", fmt.Sprintf("Alert `%s` acknowledged by @%s.", alertID.String(), cmd.UserName))
 }
@@ -191,19 +306,39 @@ func (h *Handler) handleResolveCmd(w http.ResponseWriter, r *http.Request, cmd c
 	}
 	defer conn.Release()
 
-	_, err = q.ResolveAlert(r.Context(), db.ResolveAlertParams{
-		ID: alertID,
-	})
+	actor, msg, err := h.actingUser(r.Context(), conn, cmd.UserID, auth.RoleEngineer, "resolve alerts")
 	if err != nil {
+		h.logger.Error("resolving mattermost user for resolve", "error", err, "user", cmd.UserID)
+		respondMM(w, "ephemeral", "Internal error.")
+		return
+	}
+	if msg != "" {
+		respondMM(w, "ephemeral", msg)
+		return
+	}
+
+	row, err := q.ResolveAlert(r.Context(), db.ResolveAlertParams{
+		ID:         alertID,
+		ResolvedBy: pgtype.UUID{Bytes: actor.ID, Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		respondMM(w, "ephemeral", "Alert not found.")
+		return
+	} else if err != nil {
 		h.logger.Error("resolving alert from mattermost", "error", err, "alert_id", alertID)
 		respondMM(w, "ephemeral", "Failed to resolve alert.")
 		return
 	}
 
 	text := fmt.Sprintf("Alert `%s` resolved by @%s.", alertID.String(), cmd.UserName)
+	fields := map[string]string{"title": row.Title}
 	if len(args) > 1 {
-		text += " Notes: " + strings.Join(args[1:], " ")
+		notes := strings.Join(args[1:], " ")
+		text += " Notes: " + notes
+		fields["notes"] = notes
 	}
+	detail, _ := json.Marshal(fields)
+	h.auditChat(actor.ID, "resolve", "alert", alertID, detail)
 
 	respondMM(w, "in_channel", text)
 }
@@ -244,6 +379,78 @@ func (h *Handler) handleRosterCmd(w http.ResponseWriter, r *http.Request, cmd co
 	respondMM(w, "ephemeral", "**Rosters:**\n"+strings.Join(lines, "\n"))
 }
 
+func (h *Handler) handleSwapCmd(w http.ResponseWriter, r *http.Request, cmd commandPayload, args []string) {
+	conn, _, err := h.acquireTenantConn(r)
+	if err != nil {
+		respondMM(w, "ephemeral", "Internal error.")
+		return
+	}
+	defer conn.Release()
+
+	actor, msg, err := h.actingUser(r.Context(), conn, cmd.UserID, auth.RoleEngineer, "manage shift swaps")
+	if err != nil {
+		h.logger.Error("resolving mattermost user for swap", "error", err, "user", cmd.UserID)
+		respondMM(w, "ephemeral", "Internal error.")
+		return
+	}
+	if msg != "" {
+		respondMM(w, "ephemeral", msg)
+		return
+	}
+
+	svc := roster.NewService(conn, h.logger)
+	res, err := svc.RunSwapCommand(r.Context(), actor.ID, args)
+	if err != nil {
+		h.logger.Error("running swap command from mattermost", "error", err)
+		respondMM(w, "ephemeral", "Swap command failed.")
+		return
+	}
+	if res.Request != nil {
+		h.auditChat(actor.ID, res.Action, "roster", res.Request.RosterID, roster.SwapAuditDetail(*res.Request))
+		h.swaps.Notify(r.Context(), conn, res.Event, *res.Request)
+	}
+
+	respondMM(w, "ephemeral", res.Text)
+}
+
+func (h *Handler) handleLinkCmd(w http.ResponseWriter, r *http.Request, cmd commandPayload, args []string) {
+	conn, _, err := h.acquireTenantConn(r)
+	if err != nil {
+		respondMM(w, "ephemeral", "Internal error.")
+		return
+	}
+	defer conn.Release()
+
+	svc := user.NewService(conn, h.logger)
+	if len(args) == 0 {
+		u, err := h.chatUser(r.Context(), conn, cmd.UserID)
+		switch {
+		case errors.Is(err, user.ErrNotLinked):
+			respondMM(w, "ephemeral", user.LinkInstructions)
+		case err != nil:
+			h.logger.Error("resolving mattermost user for link", "error", err, "user", cmd.UserID)
+			respondMM(w, "ephemeral", "Internal error.")
+		default:
+			respondMM(w, "ephemeral", "Your Mattermost account is linked to NightOwl user **"+u.DisplayName+"** ("+u.Email+").")
+		}
+		return
+	}
+
+	u, err := svc.LinkChatAccount(r.Context(), user.ChatMattermost, cmd.UserID, args[0])
+	if errors.Is(err, user.ErrInvalidLinkCode) {
+		respondMM(w, "ephemeral", "That link code is invalid or has expired.")
+		return
+	} else if err != nil {
+		h.logger.Error("linking mattermost account", "error", err, "user", cmd.UserID)
+		respondMM(w, "ephemeral", "Failed to link your account.")
+		return
+	}
+	detail, _ := json.Marshal(map[string]string{"chat_user_id": cmd.UserID})
+	h.auditChat(u.ID, "link_chat_account", "user", u.ID, detail)
+
+	respondMM(w, "ephemeral", "Linked your Mattermost account to NightOwl user **"+u.DisplayName+"**.")
+}
+
 // acquireTenantConn acquires a connection with the default tenant's search_path.
 func (h *Handler) acquireTenantConn(r *http.Request) (*pgxpool.Conn, *db.Queries, error) {
 	schema := tenant.SchemaName(h.defaultTenant)
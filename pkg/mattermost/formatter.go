@@ -165,3 +165,20 @@ func HandoffAttachment(msg messaging.HandoffMessage) Attachment {
 		Text:     text,
 	}
 }
+
+// ReportAttachment builds an attachment for a scheduled report.
+func ReportAttachment(msg messaging.ReportMessage) Attachment {
+	text := fmt.Sprintf("**%s**", msg.Title)
+	if msg.Summary != "" {
+		text += "\n" + msg.Summary
+	}
+	for _, s := range msg.Sections {
+		text += fmt.Sprintf("\n\n**%s**\n%s", s.Title, s.Body)
+	}
+
+	return Attachment{
+		Fallback: msg.Title,
+		Color:    "#6B7280",
+		Text:     text,
+	}
+}
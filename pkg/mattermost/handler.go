@@ -1,10 +1,21 @@
 package mattermost
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"log/slog"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/wisbric/nightowl/internal/audit"
+	"github.com/wisbric/nightowl/internal/db"
+	"github.com/wisbric/nightowl/pkg/roster"
+	"github.com/wisbric/nightowl/pkg/tenant"
+	"github.com/wisbric/nightowl/pkg/user"
 )
 
 // Handler provides HTTP handlers for Mattermost integration.
@@ -14,16 +25,21 @@ type Handler struct {
 	logger        *slog.Logger
 	webhookSecret string
 	defaultTenant string
+	audit         *audit.Writer
+	swaps         *roster.SwapNotifier
 }
 
 // NewHandler creates a Mattermost handler.
-func NewHandler(provider *Provider, pool *pgxpool.Pool, logger *slog.Logger, webhookSecret, defaultTenant string) *Handler {
+func NewHandler(provider *Provider, pool *pgxpool.Pool, logger *slog.Logger, webhookSecret, defaultTenant string,
+	auditWriter *audit.Writer, swaps *roster.SwapNotifier) *Handler {
 	return &Handler{
 		provider:      provider,
 		pool:          pool,
 		logger:        logger,
 		webhookSecret: webhookSecret,
 		defaultTenant: defaultTenant,
+		audit:         auditWriter,
+		swaps:         swaps,
 	}
 }
 
@@ -36,3 +52,52 @@ func (h *Handler) Routes() chi.Router {
 	r.Post("/dialogs", h.handleDialogs)
 	return r
 }
+
+// chatUser resolves the NightOwl user behind a Mattermost user, by linked
+// Mattermost ID or else by the email on their Mattermost profile.
+func (h *Handler) chatUser(ctx context.Context, dbtx db.DBTX, mmUserID string) (user.UserRow, error) {
+	return user.NewService(dbtx, h.logger).ResolveChatUser(ctx, user.ChatMattermost, mmUserID,
+		func(ctx context.Context) (string, error) {
+			u, err := h.provider.client.GetUser(ctx, mmUserID)
+			if err != nil {
+				return "", err
+			}
+			return u.Email, nil
+		})
+}
+
+// actingUser resolves the Mattermost caller and checks they hold at least
+// minRole. A non-empty msg explains to the caller why they can't proceed.
+func (h *Handler) actingUser(ctx context.Context, dbtx db.DBTX, mmUserID, minRole, what string) (u user.UserRow, msg string, err error) {
+	u, err = h.chatUser(ctx, dbtx, mmUserID)
+	if errors.Is(err, user.ErrNotLinked) {
+		return u, "Your Mattermost account is not linked to a NightOwl user. " + user.LinkInstructions, nil
+	}
+	if err != nil {
+		return u, "", err
+	}
+	if !u.HasRole(minRole) {
+		return u, "Your NightOwl role (" + u.Role + ") is not allowed to " + what + ".", nil
+	}
+	return u, "", nil
+}
+
+// auditChat records an action taken from Mattermost on behalf of a linked
+// user. detail must be a JSON object; "source": "mattermost" is added to it.
+func (h *Handler) auditChat(userID uuid.UUID, action, resource string, resourceID uuid.UUID, detail json.RawMessage) {
+	if h.audit == nil {
+		return
+	}
+	fields := map[string]any{}
+	_ = json.Unmarshal(detail, &fields)
+	fields["source"] = "mattermost"
+	detail, _ = json.Marshal(fields)
+	h.audit.Log(audit.Entry{
+		TenantSchema: tenant.SchemaName(h.defaultTenant),
+		UserID:       pgtype.UUID{Bytes: userID, Valid: true},
+		Action:       action,
+		Resource:     resource,
+		ResourceID:   resourceID,
+		Detail:       detail,
+	})
+}
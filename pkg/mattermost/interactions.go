@@ -5,6 +5,9 @@ import (
 	"net/http"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wisbric/core/pkg/auth"
 
 	"github.com/wisbric/nightowl/internal/db"
 )
@@ -73,6 +76,17 @@ func (h *Handler) handleAckAction(w http.ResponseWriter, r *http.Request, payloa
 	}
 	defer conn.Release()
 
+	actor, msg, err := h.actingUser(r.Context(), conn, payload.UserID, auth.RoleEngineer, "acknowledge alerts")
+	if err != nil {
+		h.logger.Error("resolving mattermost user for ack", "error", err, "user", payload.UserID)
+		respondActionJSON(w, actionResponse{EphemeralText: "Internal error."})
+		return
+	}
+	if msg != "" {
+		respondActionJSON(w, actionResponse{EphemeralText: msg})
+		return
+	}
+
 	alert, err := q.GetAlert(r.Context(), alertID)
 	if err != nil {
 		h.logger.Error("getting alert for mattermost ack", "error", err, "alert_id", alertID)
@@ -85,12 +99,17 @@ func (h *Handler) handleAckAction(w http.ResponseWriter, r *http.Request, payloa
 		return
 	}
 
-	_, err = q.AcknowledgeAlert(r.Context(), db.AcknowledgeAlertParams{ID: alertID})
+	_, err = q.AcknowledgeAlert(r.Context(), db.AcknowledgeAlertParams{
+		ID:             alertID,
+		AcknowledgedBy: pgtype.UUID{Bytes: actor.ID, Valid: true},
+	})
 	if err != nil {
 		h.logger.Error("acknowledging alert from mattermost", "error", err, "alert_id", alertID)
 		respondActionJSON(w, actionResponse{EphemeralText: "Failed to acknowledge alert."})
 		return
 	}
+	detail, _ := json.Marshal(map[string]string{"title": alert.Title})
+	h.auditChat(actor.ID, "acknowledge", "alert", alertID, detail)
 
 	acked := AlertAcknowledgedAttachments(alert.Title, "@"+payload.UserName)
 	respondActionJSON(w, actionResponse{
@@ -103,6 +122,7 @@ func (h *Handler) handleAckAction(w http.ResponseWriter, r *http.Request, payloa
 	h.logger.Info("alert acknowledged via mattermost",
 		"alert_id", alertID,
 		"user", payload.UserID,
+		"user_id", actor.ID,
 	)
 }
 
@@ -163,17 +183,31 @@ func (h *Handler) handleCreateIncidentDialog(w http.ResponseWriter, r *http.Requ
 	}
 	defer conn.Release()
 
-	_, err = q.CreateIncident(r.Context(), db.CreateIncidentParams{
-		Title:    title,
-		Severity: sev,
-		Symptoms: &symptoms,
-		Solution: &solution,
+	actor, msg, err := h.actingUser(r.Context(), conn, payload.UserID, auth.RoleEngineer, "create knowledge base entries")
+	if err != nil {
+		h.logger.Error("resolving mattermost user for incident creation", "error", err, "user", payload.UserID)
+		respondDialogError(w, "Internal error")
+		return
+	}
+	if msg != "" {
+		respondDialogError(w, msg)
+		return
+	}
+
+	inc, err := q.CreateIncident(r.Context(), db.CreateIncidentParams{
+		Title:     title,
+		Severity:  sev,
+		Symptoms:  &symptoms,
+		Solution:  &solution,
+		CreatedBy: pgtype.UUID{Bytes: actor.ID, Valid: true},
 	})
 	if err != nil {
 		h.logger.Error("creating incident from mattermost dialog", "error", err)
 		respondDialogError(w, "Failed to create incident")
 		return
 	}
+	detail, _ := json.Marshal(map[string]string{"title": title})
+	h.auditChat(actor.ID, "create", "incident", inc.ID, detail)
 
 	h.logger.Info("incident created from mattermost dialog", "title", title)
 	w.WriteHeader(http.StatusOK) // empty 200 = success, closes dialog
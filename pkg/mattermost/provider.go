@@ -99,6 +99,22 @@ func (p *Provider) PostHandoff(ctx context.Context, msg messaging.HandoffMessage
 	return err
 }
 
+func (p *Provider) PostReport(ctx context.Context, msg messaging.ReportMessage) error {
+	if !p.client.IsEnabled() {
+		return nil
+	}
+
+	channel := msg.ChannelID
+	if channel == "" {
+		channel = p.channelID
+	}
+	_, err := p.client.CreatePost(ctx, Post{
+		ChannelID: channel,
+		Props:     map[string]any{"attachments": []Attachment{ReportAttachment(msg)}},
+	})
+	return err
+}
+
 func (p *Provider) PostResolutionPrompt(ctx context.Context, msg messaging.ResolutionPromptMessage) error {
 	if !p.client.IsEnabled() || msg.ResolverRef == "" {
 		return nil
@@ -22,6 +22,9 @@ type Provider interface {
 	// PostHandoff sends shift handoff notifications (outgoing + incoming).
 	PostHandoff(ctx context.Context, msg HandoffMessage) error
 
+	// PostReport posts a scheduled report to a channel.
+	PostReport(ctx context.Context, msg ReportMessage) error
+
 	// PostResolutionPrompt asks the resolver to add the solution to the KB.
 	PostResolutionPrompt(ctx context.Context, msg ResolutionPromptMessage) error
 
@@ -64,6 +64,15 @@ type HandoffMessage struct {
 	WeekStart      string // "Mar 03, 2026"
 }
 
+// ReportMessage is a scheduled report posted to a channel, such as the
+// weekly alert noise report.
+type ReportMessage struct {
+	Title     string
+	Summary   string
+	Sections  []ResponseSection // Body is plain text, one item per line
+	ChannelID string            // empty posts to the provider's default channel
+}
+
 // ResolutionPromptMessage asks the resolver to document the solution.
 type ResolutionPromptMessage struct {
 	AlertID     string
@@ -0,0 +1,255 @@
+package roster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/wisbric/nightowl/pkg/timeoff"
+)
+
+// ScheduleConflict is a scheduled duty that overlaps the assignee's time off.
+type ScheduleConflict struct {
+	ScheduleID  uuid.UUID `json:"schedule_id"`
+	ShiftStart  time.Time `json:"shift_start"`
+	ShiftEnd    time.Time `json:"shift_end"`
+	Role        string    `json:"role"` // "primary" | "secondary"
+	UserID      uuid.UUID `json:"user_id"`
+	DisplayName string    `json:"display_name"`
+	IsLocked    bool      `json:"is_locked"`
+	PeriodID    uuid.UUID `json:"unavailability_id"`
+	PeriodStart time.Time `json:"unavailable_from"`
+	PeriodEnd   time.Time `json:"unavailable_until"`
+	Reason      *string   `json:"reason,omitempty"`
+}
+
+// SwapSuggestion is a roster member who could take over a conflicting duty.
+type SwapSuggestion struct {
+	UserID      uuid.UUID `json:"user_id"`
+	DisplayName string    `json:"display_name"`
+	HoursServed float64   `json:"hours_served"` // in the requested role
+	// SwapShift is a later shift the candidate holds in the same role that
+	// the original assignee is free for, so the two can trade instead of
+	// the candidate taking an extra shift.
+	SwapShift *ScheduleEntry `json:"swap_shift,omitempty"`
+}
+
+// availableMembers returns the members free for the whole slot. When nobody
+// is free the full list is returned so the shift is still staffed; the
+// resulting conflict is then reported rather than leaving a gap.
+func availableMembers(members []MemberResponse, periods []timeoff.Period, slot shiftWindow) []MemberResponse {
+	var free []MemberResponse
+	for _, m := range members {
+		if !unavailable(periods, m.UserID, slot) {
+			free = append(free, m)
+		}
+	}
+	if len(free) == 0 {
+		return members
+	}
+	return free
+}
+
+// unavailable reports whether the user has time off overlapping the slot.
+func unavailable(periods []timeoff.Period, userID uuid.UUID, slot shiftWindow) bool {
+	return firstOverlap(periods, userID, slot) != nil
+}
+
+func firstOverlap(periods []timeoff.Period, userID uuid.UUID, slot shiftWindow) *timeoff.Period {
+	for i := range periods {
+		if periods[i].UserID == userID && periods[i].Overlaps(slot.start, slot.end) {
+			return &periods[i]
+		}
+	}
+	return nil
+}
+
+// conflictsFor lists the duties in entry that clash with time off.
+func conflictsFor(entry ScheduleEntry, periods []timeoff.Period) []ScheduleConflict {
+	slot := shiftWindow{start: entry.ShiftStart, end: entry.ShiftEnd}
+	var out []ScheduleConflict
+	add := func(role string, userID *uuid.UUID, name string) {
+		if userID == nil {
+			return
+		}
+		p := firstOverlap(periods, *userID, slot)
+		if p == nil {
+			return
+		}
+		out = append(out, ScheduleConflict{
+			ScheduleID:  entry.ID,
+			ShiftStart:  entry.ShiftStart,
+			ShiftEnd:    entry.ShiftEnd,
+			Role:        role,
+			UserID:      *userID,
+			DisplayName: name,
+			IsLocked:    entry.IsLocked,
+			PeriodID:    p.ID,
+			PeriodStart: p.StartAt,
+			PeriodEnd:   p.EndAt,
+			Reason:      p.Reason,
+		})
+	}
+	add("primary", entry.PrimaryUserID, entry.PrimaryDisplayName)
+	add("secondary", entry.SecondaryUserID, entry.SecondaryDisplayName)
+	return out
+}
+
+// memberPeriods loads time off for the roster's active members over [from, to).
+func (s *Service) memberPeriods(ctx context.Context, members []MemberResponse, from, to time.Time) ([]timeoff.Period, error) {
+	ids := make([]uuid.UUID, len(members))
+	for i, m := range members {
+		ids[i] = m.UserID
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	periods, err := s.timeoff.ListOverlapping(ctx, ids, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("listing member unavailability: %w", err)
+	}
+	return periods, nil
+}
+
+// ListConflicts returns scheduled duties in [from, to) whose assignee is
+// unavailable, including locked shifts generation will not touch.
+func (s *Service) ListConflicts(ctx context.Context, rosterID uuid.UUID, from, to time.Time) ([]ScheduleConflict, error) {
+	entries, err := s.GetSchedule(ctx, rosterID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	result := []ScheduleConflict{}
+	for _, e := range entries {
+		result = append(result, e.Conflicts...)
+	}
+	return result, nil
+}
+
+// annotateConflicts fills in Conflicts on each schedule entry.
+func (s *Service) annotateConflicts(ctx context.Context, entries []ScheduleEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	seen := make(map[uuid.UUID]bool)
+	var ids []uuid.UUID
+	from, to := entries[0].ShiftStart, entries[0].ShiftEnd
+	for _, e := range entries {
+		for _, uid := range []*uuid.UUID{e.PrimaryUserID, e.SecondaryUserID} {
+			if uid != nil && !seen[*uid] {
+				seen[*uid] = true
+				ids = append(ids, *uid)
+			}
+		}
+		if e.ShiftStart.Before(from) {
+			from = e.ShiftStart
+		}
+		if e.ShiftEnd.After(to) {
+			to = e.ShiftEnd
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	periods, err := s.timeoff.ListOverlapping(ctx, ids, from, to)
+	if err != nil {
+		return fmt.Errorf("listing unavailability: %w", err)
+	}
+	for i := range entries {
+		entries[i].Conflicts = conflictsFor(entries[i], periods)
+	}
+	return nil
+}
+
+// SuggestSwaps proposes members who could take the given role on the shift
+// named by key. Candidates are free for the whole shift and not already on
+// it, ordered by least time served in that role so the fairness counts stay
+// balanced. Where possible each candidate is paired with one of their own
+// upcoming shifts the current assignee could take in return.
+func (s *Service) SuggestSwaps(ctx context.Context, rosterID uuid.UUID, key, role string) ([]SwapSuggestion, error) {
+	entry, err := s.GetScheduleWeek(ctx, rosterID, key)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, pgx.ErrNoRows
+	}
+	roster, err := s.store.GetRoster(ctx, rosterID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.store.ListActiveMembers(ctx, rosterID)
+	if err != nil {
+		return nil, fmt.Errorf("listing active members: %w", err)
+	}
+
+	holder := entry.PrimaryUserID
+	if role == "secondary" {
+		holder = entry.SecondaryUserID
+	}
+
+	// Look ahead for reciprocal shifts across the roster's planning horizon.
+	horizon := entry.ShiftEnd.AddDate(0, 0, roster.ScheduleWeeksAhead*7)
+	upcoming, err := s.store.ListSchedule(ctx, rosterID, entry.ShiftEnd, horizon)
+	if err != nil {
+		return nil, fmt.Errorf("listing upcoming schedule: %w", err)
+	}
+	periods, err := s.memberPeriods(ctx, members, entry.ShiftStart, horizon)
+	if err != nil {
+		return nil, err
+	}
+
+	slot := shiftWindow{start: entry.ShiftStart, end: entry.ShiftEnd}
+	result := []SwapSuggestion{}
+	for _, m := range members {
+		if isAssigned(*entry, m.UserID) || unavailable(periods, m.UserID, slot) {
+			continue
+		}
+		primary, secondary, err := s.store.DutyMinutes(ctx, rosterID, m.UserID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("counting duty for %s: %w", m.UserID, err)
+		}
+		served := primary
+		if role == "secondary" {
+			served = secondary
+		}
+		sug := SwapSuggestion{UserID: m.UserID, DisplayName: m.DisplayName, HoursServed: float64(served) / 60}
+		if holder != nil {
+			sug.SwapShift = reciprocalShift(upcoming, periods, role, m.UserID, *holder)
+		}
+		result = append(result, sug)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].HoursServed < result[j].HoursServed
+	})
+	return result, nil
+}
+
+// reciprocalShift finds the first unlocked shift where candidate holds role
+// and holder is free and not already on duty.
+func reciprocalShift(upcoming []ScheduleEntry, periods []timeoff.Period, role string, candidate, holder uuid.UUID) *ScheduleEntry {
+	for i := range upcoming {
+		e := &upcoming[i]
+		assignee := e.PrimaryUserID
+		if role == "secondary" {
+			assignee = e.SecondaryUserID
+		}
+		if e.IsLocked || assignee == nil || *assignee != candidate || isAssigned(*e, holder) {
+			continue
+		}
+		if unavailable(periods, holder, shiftWindow{start: e.ShiftStart, end: e.ShiftEnd}) {
+			continue
+		}
+		return e
+	}
+	return nil
+}
+
+func isAssigned(e ScheduleEntry, userID uuid.UUID) bool {
+	return (e.PrimaryUserID != nil && *e.PrimaryUserID == userID) ||
+		(e.SecondaryUserID != nil && *e.SecondaryUserID == userID)
+}
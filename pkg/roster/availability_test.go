@@ -0,0 +1,95 @@
+package roster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wisbric/nightowl/pkg/timeoff"
+)
+
+func TestAvailableMembers(t *testing.T) {
+	alice := MemberResponse{UserID: uuid.New(), DisplayName: "Alice"}
+	bob := MemberResponse{UserID: uuid.New(), DisplayName: "Bob"}
+	members := []MemberResponse{alice, bob}
+
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	slot := shiftWindow{start: start, end: start.AddDate(0, 0, 7)}
+	periods := []timeoff.Period{
+		// Alice is away for one day in the middle of the week.
+		{UserID: alice.UserID, StartAt: start.AddDate(0, 0, 3), EndAt: start.AddDate(0, 0, 4)},
+	}
+
+	free := availableMembers(members, periods, slot)
+	if len(free) != 1 || free[0].UserID != bob.UserID {
+		t.Fatalf("expected only Bob to be available, got %+v", free)
+	}
+
+	// Time off ending exactly at the shift start does not block.
+	periods[0].StartAt, periods[0].EndAt = start.AddDate(0, 0, -7), start
+	if free := availableMembers(members, periods, slot); len(free) != 2 {
+		t.Errorf("expected both available, got %d", len(free))
+	}
+
+	// Nobody free: fall back to everyone rather than leave the shift empty.
+	periods = []timeoff.Period{
+		{UserID: alice.UserID, StartAt: start, EndAt: slot.end},
+		{UserID: bob.UserID, StartAt: start, EndAt: slot.end},
+	}
+	if free := availableMembers(members, periods, slot); len(free) != 2 {
+		t.Errorf("expected fallback to all members, got %d", len(free))
+	}
+}
+
+func TestConflictsFor(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	entry := ScheduleEntry{
+		ID:              uuid.New(),
+		ShiftStart:      start,
+		ShiftEnd:        start.AddDate(0, 0, 7),
+		PrimaryUserID:   &alice,
+		SecondaryUserID: &bob,
+		IsLocked:        true,
+	}
+	periods := []timeoff.Period{
+		{ID: uuid.New(), UserID: bob, StartAt: start.AddDate(0, 0, 5), EndAt: start.AddDate(0, 0, 10), Reason: strPtr("Conference")},
+	}
+
+	conflicts := conflictsFor(entry, periods)
+	if len(conflicts) != 1 {
+		t.Fatalf("expected 1 conflict, got %d", len(conflicts))
+	}
+	c := conflicts[0]
+	if c.Role != "secondary" || c.UserID != bob || !c.IsLocked || c.Reason == nil {
+		t.Errorf("unexpected conflict %+v", c)
+	}
+}
+
+func TestReciprocalShift(t *testing.T) {
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+	start := time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)
+	week := func(i int, primary uuid.UUID, locked bool) ScheduleEntry {
+		s := start.AddDate(0, 0, 7*i)
+		p := primary
+		return ScheduleEntry{ID: uuid.New(), ShiftStart: s, ShiftEnd: s.AddDate(0, 0, 7), PrimaryUserID: &p, IsLocked: locked}
+	}
+	upcoming := []ScheduleEntry{
+		week(0, bob, true),    // locked: not offered
+		week(1, carol, false), // not the candidate's shift
+		week(2, bob, false),   // alice is away
+		week(3, bob, false),
+	}
+	periods := []timeoff.Period{
+		{UserID: alice, StartAt: upcoming[2].ShiftStart, EndAt: upcoming[2].ShiftEnd},
+	}
+
+	got := reciprocalShift(upcoming, periods, "primary", bob, alice)
+	if got == nil || got.ID != upcoming[3].ID {
+		t.Errorf("expected week 3 as reciprocal shift, got %+v", got)
+	}
+	if got := reciprocalShift(upcoming, periods, "secondary", bob, alice); got != nil {
+		t.Errorf("expected no secondary reciprocal shift, got %+v", got)
+	}
+}
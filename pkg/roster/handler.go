@@ -24,11 +24,12 @@ import (
 type Handler struct {
 	logger *slog.Logger
 	audit  *audit.Writer
+	swaps  *SwapNotifier
 }
 
 // NewHandler creates a roster Handler.
-func NewHandler(logger *slog.Logger, audit *audit.Writer) *Handler {
-	return &Handler{logger: logger, audit: audit}
+func NewHandler(logger *slog.Logger, audit *audit.Writer, swaps *SwapNotifier) *Handler {
+	return &Handler{logger: logger, audit: audit, swaps: swaps}
 }
 
 // Routes returns a chi.Router with all roster routes mounted.
@@ -51,6 +52,8 @@ func (h *Handler) Routes() chi.Router {
 		r.Get("/schedule/{weekStart}", h.handleGetScheduleWeek)
 		r.Put("/schedule/{weekStart}", h.handleUpdateScheduleWeek)
 		r.Delete("/schedule/{weekStart}/lock", h.handleUnlockScheduleWeek)
+		r.Get("/schedule/{weekStart}/swap-suggestions", h.handleSwapSuggestions)
+		r.Get("/conflicts", h.handleListConflicts)
 
 		// Members
 		r.Get("/members", h.handleListMembers)
@@ -58,11 +61,25 @@ func (h *Handler) Routes() chi.Router {
 		r.Put("/members/{userID}", h.handleUpdateMember)
 		r.Delete("/members/{userID}", h.handleDeactivateMember)
 
+		// Layers
+		r.Get("/layers", h.handleListLayers)
+		r.Post("/layers", h.handleCreateLayer)
+		r.Put("/layers/{layerID}", h.handleUpdateLayer)
+		r.Delete("/layers/{layerID}", h.handleDeleteLayer)
+
 		// Overrides
 		r.Get("/overrides", h.handleListOverrides)
 		r.Post("/overrides", h.handleCreateOverride)
 		r.Delete("/overrides/{overrideID}", h.handleDeleteOverride)
 
+		// Swap requests
+		r.Get("/swaps", h.handleListSwapRequests)
+		r.Post("/swaps", h.handleCreateSwapRequest)
+		r.Get("/swaps/{swapID}", h.handleGetSwapRequest)
+		r.Post("/swaps/{swapID}/accept", h.handleAcceptSwapRequest)
+		r.Post("/swaps/{swapID}/decline", h.handleDeclineSwapRequest)
+		r.Post("/swaps/{swapID}/cancel", h.handleCancelSwapRequest)
+
 		// Calendar export
 		r.Get("/export.ics", h.handleExportICS)
 	})
@@ -95,6 +112,10 @@ func (h *Handler) handleCreateRoster(w http.ResponseWriter, r *http.Request) {
 	if !httpserver.DecodeAndValidate(w, r, &req) {
 		return
 	}
+	if err := validateRotation(rotationOrDefault(req.RotationUnit, req.RotationLengths)); err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
 
 	svc := h.service(r)
 	resp, err := svc.CreateRoster(r.Context(), req)
@@ -164,6 +185,10 @@ func (h *Handler) handleUpdateRoster(w http.ResponseWriter, r *http.Request) {
 	if !httpserver.DecodeAndValidate(w, r, &req) {
 		return
 	}
+	if err := validateRotation(rotationOrDefault(req.RotationUnit, req.RotationLengths)); err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
 	svc := h.service(r)
 	resp, err := svc.UpdateRoster(r.Context(), id, req)
 	if err != nil {
@@ -289,6 +314,20 @@ func (h *Handler) handleListSchedule(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// respondShiftKeyError writes the client error for a bad schedule key or a
+// missing roster/shift and reports whether it handled err.
+func (h *Handler) respondShiftKeyError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, ErrInvalidShiftKey):
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
+	case errors.Is(err, pgx.ErrNoRows):
+		httpserver.RespondError(w, http.StatusNotFound, "not_found", "no schedule entry for this shift")
+	default:
+		return false
+	}
+	return true
+}
+
 func (h *Handler) handleGetScheduleWeek(w http.ResponseWriter, r *http.Request) {
 	id, err := parseRosterID(r)
 	if err != nil {
@@ -296,21 +335,20 @@ func (h *Handler) handleGetScheduleWeek(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	weekStart, err := time.Parse("2006-01-02", chi.URLParam(r, "weekStart"))
-	if err != nil {
-		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid weekStart (use YYYY-MM-DD)")
-		return
-	}
+	weekStart := chi.URLParam(r, "weekStart")
 
 	svc := h.service(r)
 	entry, err := svc.GetScheduleWeek(r.Context(), id, weekStart)
 	if err != nil {
+		if h.respondShiftKeyError(w, err) {
+			return
+		}
 		h.logger.Error("getting schedule week", "error", err, "roster_id", id)
 		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to get schedule week")
 		return
 	}
 	if entry == nil {
-		httpserver.RespondError(w, http.StatusNotFound, "not_found", "no schedule entry for this week")
+		httpserver.RespondError(w, http.StatusNotFound, "not_found", "no schedule entry for this shift")
 		return
 	}
 	httpserver.Respond(w, http.StatusOK, entry)
@@ -323,11 +361,7 @@ func (h *Handler) handleUpdateScheduleWeek(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	weekStart, err := time.Parse("2006-01-02", chi.URLParam(r, "weekStart"))
-	if err != nil {
-		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid weekStart (use YYYY-MM-DD)")
-		return
-	}
+	weekStart := chi.URLParam(r, "weekStart")
 
 	var req UpdateScheduleWeekRequest
 	if !httpserver.DecodeAndValidate(w, r, &req) {
@@ -343,13 +377,16 @@ func (h *Handler) handleUpdateScheduleWeek(w http.ResponseWriter, r *http.Reques
 	svc := h.service(r)
 	entry, err := svc.UpdateScheduleWeek(r.Context(), id, weekStart, req)
 	if err != nil {
+		if h.respondShiftKeyError(w, err) {
+			return
+		}
 		h.logger.Error("updating schedule week", "error", err, "roster_id", id)
 		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to update schedule week")
 		return
 	}
 
 	if h.audit != nil {
-		detail, _ := json.Marshal(map[string]string{"week_start": weekStart.Format("2006-01-02")})
+		detail, _ := json.Marshal(map[string]string{"week_start": entry.WeekStart, "shift_start": entry.ShiftStart.Format(time.RFC3339)})
 		h.audit.LogFromRequest(r, "update_schedule", "roster", id, detail)
 	}
 
@@ -363,16 +400,11 @@ func (h *Handler) handleUnlockScheduleWeek(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	weekStart, err := time.Parse("2006-01-02", chi.URLParam(r, "weekStart"))
-	if err != nil {
-		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid weekStart (use YYYY-MM-DD)")
-		return
-	}
+	weekStart := chi.URLParam(r, "weekStart")
 
 	svc := h.service(r)
 	if err := svc.UnlockScheduleWeek(r.Context(), id, weekStart); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			httpserver.RespondError(w, http.StatusNotFound, "not_found", "no schedule entry for this week")
+		if h.respondShiftKeyError(w, err) {
 			return
 		}
 		h.logger.Error("unlocking schedule week", "error", err, "roster_id", id)
@@ -440,6 +472,82 @@ func (h *Handler) handleGenerateSchedule(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+func (h *Handler) handleListConflicts(w http.ResponseWriter, r *http.Request) {
+	id, err := parseRosterID(r)
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid roster ID")
+		return
+	}
+
+	svc := h.service(r)
+	roster, err := svc.GetRoster(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			httpserver.RespondError(w, http.StatusNotFound, "not_found", "roster not found")
+			return
+		}
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to get roster")
+		return
+	}
+
+	// Default range: now to schedule_weeks_ahead from now.
+	from := time.Now()
+	to := from.AddDate(0, 0, roster.ScheduleWeeksAhead*7)
+	if v := r.URL.Query().Get("from"); v != "" {
+		if parsed, err := time.Parse("2006-01-02", v); err == nil {
+			from = parsed
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if parsed, err := time.Parse("2006-01-02", v); err == nil {
+			to = parsed
+		}
+	}
+
+	items, err := svc.ListConflicts(r.Context(), id, from, to)
+	if err != nil {
+		h.logger.Error("listing schedule conflicts", "error", err, "roster_id", id)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to list conflicts")
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]any{
+		"conflicts": items,
+		"count":     len(items),
+	})
+}
+
+func (h *Handler) handleSwapSuggestions(w http.ResponseWriter, r *http.Request) {
+	id, err := parseRosterID(r)
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid roster ID")
+		return
+	}
+
+	role := r.URL.Query().Get("role")
+	if role == "" {
+		role = "primary"
+	}
+	if role != "primary" && role != "secondary" {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "role must be primary or secondary")
+		return
+	}
+
+	svc := h.service(r)
+	items, err := svc.SuggestSwaps(r.Context(), id, chi.URLParam(r, "weekStart"), role)
+	if err != nil {
+		if h.respondShiftKeyError(w, err) {
+			return
+		}
+		h.logger.Error("suggesting swaps", "error", err, "roster_id", id)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to suggest swaps")
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]any{
+		"suggestions": items,
+		"count":       len(items),
+	})
+}
+
 // =====================
 // Member handlers
 // =====================
@@ -543,6 +651,122 @@ func (h *Handler) handleDeactivateMember(w http.ResponseWriter, r *http.Request)
 	httpserver.Respond(w, http.StatusNoContent, nil)
 }
 
+// =====================
+// Layer handlers
+// =====================
+
+func (h *Handler) handleListLayers(w http.ResponseWriter, r *http.Request) {
+	id, err := parseRosterID(r)
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid roster ID")
+		return
+	}
+	svc := h.service(r)
+	items, err := svc.ListLayers(r.Context(), id)
+	if err != nil {
+		h.logger.Error("listing roster layers", "error", err, "roster_id", id)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to list layers")
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]any{
+		"layers": items,
+		"count":  len(items),
+	})
+}
+
+func (h *Handler) handleCreateLayer(w http.ResponseWriter, r *http.Request) {
+	id, err := parseRosterID(r)
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid roster ID")
+		return
+	}
+	var req LayerRequest
+	if !httpserver.DecodeAndValidate(w, r, &req) {
+		return
+	}
+	if err := validateLayer(req); err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	svc := h.service(r)
+	resp, err := svc.CreateLayer(r.Context(), id, req)
+	if err != nil {
+		h.logger.Error("creating roster layer", "error", err, "roster_id", id)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to create layer")
+		return
+	}
+	if h.audit != nil {
+		detail, _ := json.Marshal(map[string]string{"layer_id": resp.ID.String(), "name": resp.Name})
+		h.audit.LogFromRequest(r, "create_layer", "roster", id, detail)
+	}
+	httpserver.Respond(w, http.StatusCreated, resp)
+}
+
+func (h *Handler) handleUpdateLayer(w http.ResponseWriter, r *http.Request) {
+	id, err := parseRosterID(r)
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid roster ID")
+		return
+	}
+	layerID, err := uuid.Parse(chi.URLParam(r, "layerID"))
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid layer ID")
+		return
+	}
+	var req LayerRequest
+	if !httpserver.DecodeAndValidate(w, r, &req) {
+		return
+	}
+	if err := validateLayer(req); err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	svc := h.service(r)
+	resp, err := svc.UpdateLayer(r.Context(), id, layerID, req)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			httpserver.RespondError(w, http.StatusNotFound, "not_found", "layer not found")
+			return
+		}
+		h.logger.Error("updating roster layer", "error", err, "layer_id", layerID)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to update layer")
+		return
+	}
+	if h.audit != nil {
+		detail, _ := json.Marshal(map[string]string{"layer_id": resp.ID.String(), "name": resp.Name})
+		h.audit.LogFromRequest(r, "update_layer", "roster", id, detail)
+	}
+	httpserver.Respond(w, http.StatusOK, resp)
+}
+
+func (h *Handler) handleDeleteLayer(w http.ResponseWriter, r *http.Request) {
+	id, err := parseRosterID(r)
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid roster ID")
+		return
+	}
+	layerID, err := uuid.Parse(chi.URLParam(r, "layerID"))
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid layer ID")
+		return
+	}
+	svc := h.service(r)
+	if err := svc.DeleteLayer(r.Context(), id, layerID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			httpserver.RespondError(w, http.StatusNotFound, "not_found", "layer not found")
+			return
+		}
+		h.logger.Error("deleting roster layer", "error", err, "layer_id", layerID)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to delete layer")
+		return
+	}
+	if h.audit != nil {
+		detail, _ := json.Marshal(map[string]string{"layer_id": layerID.String()})
+		h.audit.LogFromRequest(r, "delete_layer", "roster", id, detail)
+	}
+	httpserver.Respond(w, http.StatusNoContent, nil)
+}
+
 // =====================
 // Override handlers
 // =====================
@@ -609,6 +833,182 @@ func (h *Handler) handleDeleteOverride(w http.ResponseWriter, r *http.Request) {
 	httpserver.Respond(w, http.StatusNoContent, nil)
 }
 
+// =====================
+// Swap request handlers
+// =====================
+
+// actingUser is the user a swap action is taken as: the caller, or the
+// user_id in the body when calling with an API key.
+func actingUser(r *http.Request, bodyUserID *uuid.UUID) (uuid.UUID, bool) {
+	if id := auth.FromContext(r.Context()); id != nil && id.UserID != nil {
+		return *id.UserID, true
+	}
+	if bodyUserID != nil {
+		return *bodyUserID, true
+	}
+	return uuid.Nil, false
+}
+
+// respondSwapError writes the client error for a failed swap action and
+// reports whether it handled err.
+func (h *Handler) respondSwapError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, ErrSwapInvalid):
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
+	case errors.Is(err, ErrSwapForbidden):
+		httpserver.RespondError(w, http.StatusForbidden, "forbidden", err.Error())
+	case errors.Is(err, ErrSwapConflict):
+		httpserver.RespondError(w, http.StatusConflict, "conflict", err.Error())
+	case errors.Is(err, pgx.ErrNoRows):
+		httpserver.RespondError(w, http.StatusNotFound, "not_found", "swap request not found")
+	default:
+		return false
+	}
+	return true
+}
+
+func (h *Handler) handleListSwapRequests(w http.ResponseWriter, r *http.Request) {
+	id, err := parseRosterID(r)
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid roster ID")
+		return
+	}
+	items, err := h.service(r).ListSwapRequests(r.Context(), id, r.URL.Query().Get("status"))
+	if err != nil {
+		h.logger.Error("listing swap requests", "error", err, "roster_id", id)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to list swap requests")
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]any{
+		"swaps": items,
+		"count": len(items),
+	})
+}
+
+func (h *Handler) handleGetSwapRequest(w http.ResponseWriter, r *http.Request) {
+	id, err := parseRosterID(r)
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid roster ID")
+		return
+	}
+	swapID, err := uuid.Parse(chi.URLParam(r, "swapID"))
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid swap request ID")
+		return
+	}
+	sr, err := h.service(r).GetSwapRequest(r.Context(), id, swapID)
+	if err != nil {
+		if h.respondSwapError(w, err) {
+			return
+		}
+		h.logger.Error("getting swap request", "error", err, "swap_id", swapID)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to get swap request")
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, sr)
+}
+
+func (h *Handler) handleCreateSwapRequest(w http.ResponseWriter, r *http.Request) {
+	id, err := parseRosterID(r)
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid roster ID")
+		return
+	}
+	var req CreateSwapRequest
+	if !httpserver.DecodeAndValidate(w, r, &req) {
+		return
+	}
+	requester, ok := actingUser(r, req.UserID)
+	if !ok {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "user_id is required when not signed in as a user")
+		return
+	}
+
+	conn := tenant.ConnFromContext(r.Context())
+	sr, err := h.service(r).CreateSwapRequest(r.Context(), id, req, requester)
+	if err != nil {
+		if h.respondSwapError(w, err) || h.respondShiftKeyError(w, err) {
+			return
+		}
+		h.logger.Error("creating swap request", "error", err, "roster_id", id)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to create swap request")
+		return
+	}
+
+	h.recordSwap(r, "create_swap_request", sr)
+	h.swaps.Notify(r.Context(), conn, SwapStatusPending, sr)
+	httpserver.Respond(w, http.StatusCreated, sr)
+}
+
+func (h *Handler) handleAcceptSwapRequest(w http.ResponseWriter, r *http.Request) {
+	h.respondToSwap(w, r, "accept")
+}
+
+func (h *Handler) handleDeclineSwapRequest(w http.ResponseWriter, r *http.Request) {
+	h.respondToSwap(w, r, "decline")
+}
+
+func (h *Handler) handleCancelSwapRequest(w http.ResponseWriter, r *http.Request) {
+	h.respondToSwap(w, r, "cancel")
+}
+
+// respondToSwap handles accept, decline and cancel, which share a body.
+func (h *Handler) respondToSwap(w http.ResponseWriter, r *http.Request, verb string) {
+	id, err := parseRosterID(r)
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid roster ID")
+		return
+	}
+	swapID, err := uuid.Parse(chi.URLParam(r, "swapID"))
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid swap request ID")
+		return
+	}
+	var req RespondSwapRequest
+	if r.ContentLength != 0 && !httpserver.DecodeAndValidate(w, r, &req) {
+		return
+	}
+	user, ok := actingUser(r, req.UserID)
+	if !ok {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "user_id is required when not signed in as a user")
+		return
+	}
+
+	svc := h.service(r)
+	var sr SwapRequestResponse
+	var event string
+	switch verb {
+	case "accept":
+		sr, err = svc.AcceptSwapRequest(r.Context(), id, swapID, user, req.SwapStartAt)
+		event = SwapStatusAccepted
+	case "decline":
+		sr, err = svc.DeclineSwapRequest(r.Context(), id, swapID, user)
+		event = SwapStatusDeclined
+	default:
+		sr, err = svc.CancelSwapRequest(r.Context(), id, swapID, user)
+		event = SwapStatusCancelled
+	}
+	if err != nil {
+		if h.respondSwapError(w, err) || h.respondShiftKeyError(w, err) {
+			return
+		}
+		h.logger.Error(verb+" swap request", "error", err, "swap_id", swapID)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to "+verb+" swap request")
+		return
+	}
+
+	h.recordSwap(r, verb+"_swap_request", sr)
+	h.swaps.Notify(r.Context(), tenant.ConnFromContext(r.Context()), event, sr)
+	httpserver.Respond(w, http.StatusOK, sr)
+}
+
+func (h *Handler) recordSwap(r *http.Request, action string, sr SwapRequestResponse) {
+	if h.audit == nil {
+		return
+	}
+	h.audit.LogFromRequest(r, action, "roster", sr.RosterID, SwapAuditDetail(sr))
+}
+
 // =====================
 // Calendar export
 // =====================
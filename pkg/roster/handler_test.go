@@ -10,7 +10,7 @@ import (
 )
 
 func newTestRouter() chi.Router {
-	h := NewHandler(nil, nil)
+	h := NewHandler(nil, nil, nil)
 	router := chi.NewRouter()
 	router.Mount("/rosters", h.Routes())
 	return router
@@ -22,38 +22,14 @@ func generateICSFromSchedule(roster RosterResponse, schedule []ScheduleEntry, ov
 		tz = time.UTC
 	}
 
-	handoffTime, err := time.Parse("15:04", roster.HandoffTime)
-	if err != nil {
-		handoffTime, _ = time.Parse("15:04", "09:00")
-	}
-
 	for _, entry := range schedule {
-		weekStart, err := time.Parse("2006-01-02", entry.WeekStart)
-		if err != nil {
-			continue
-		}
-		weekEnd, err := time.Parse("2006-01-02", entry.WeekEnd)
-		if err != nil {
-			continue
-		}
-
-		shiftStart := time.Date(weekStart.Year(), weekStart.Month(), weekStart.Day(),
-			handoffTime.Hour(), handoffTime.Minute(), 0, 0, tz)
-		shiftEnd := time.Date(weekEnd.Year(), weekEnd.Month(), weekEnd.Day(),
-			handoffTime.Hour(), handoffTime.Minute(), 0, 0, tz)
+		shiftStart, shiftEnd := entry.ShiftStart, entry.ShiftEnd
 
 		primaryName := "Unassigned"
 		if entry.PrimaryDisplayName != "" {
 			primaryName = entry.PrimaryDisplayName
 		}
 
-		uid := fmt.Sprintf("%s-%s@nightowl", roster.ID, entry.WeekStart)
-		b.WriteString("BEGIN:VEVENT\r\n")
-		fmt.Fprintf(&b, "UID:%s\r\n", uid)
-		fmt.Fprintf(&b, "DTSTART:%s\r\n", shiftStart.UTC().Format("20060102T150405Z"))
-		fmt.Fprintf(&b, "DTEND:%s\r\n", shiftEnd.UTC().Format("20060102T150405Z"))
-		fmt.Fprintf(&b, "SUMMARY:On-Call: %s\r\n", primaryName)
-
 		desc := fmt.Sprintf("Roster: %s\\nPrimary: %s", roster.Name, primaryName)
 		if entry.SecondaryDisplayName != "" {
 			desc += fmt.Sprintf("\\nSecondary: %s", entry.SecondaryDisplayName)
@@ -61,8 +37,27 @@ func generateICSFromSchedule(roster RosterResponse, schedule []ScheduleEntry, ov
 		if entry.Notes != nil && *entry.Notes != "" {
 			desc += fmt.Sprintf("\\nNotes: %s", *entry.Notes)
 		}
-		fmt.Fprintf(&b, "DESCRIPTION:%s\r\n", desc)
-		b.WriteString("END:VEVENT\r\n")
+
+		// A follow-the-sun roster only covers its daily active window; the
+		// linked roster owns the rest of the day.
+		shifts := []shiftWindow{{start: shiftStart, end: shiftEnd}}
+		if roster.IsFollowTheSun {
+			shifts = followTheSunShifts(roster, shiftStart, shiftEnd, tz)
+		}
+
+		for _, shift := range shifts {
+			uid := fmt.Sprintf("%s-%s@nightowl", roster.ID, entry.WeekStart)
+			if roster.IsFollowTheSun || !isWeeklyRotation(roster) {
+				uid = fmt.Sprintf("%s-%s@nightowl", roster.ID, shift.start.UTC().Format("20060102T1504"))
+			}
+			b.WriteString("BEGIN:VEVENT\r\n")
+			fmt.Fprintf(&b, "UID:%s\r\n", uid)
+			fmt.Fprintf(&b, "DTSTART:%s\r\n", shift.start.UTC().Format("20060102T150405Z"))
+			fmt.Fprintf(&b, "DTEND:%s\r\n", shift.end.UTC().Format("20060102T150405Z"))
+			fmt.Fprintf(&b, "SUMMARY:On-Call: %s\r\n", primaryName)
+			fmt.Fprintf(&b, "DESCRIPTION:%s\r\n", desc)
+			b.WriteString("END:VEVENT\r\n")
+		}
 	}
 
 	// Add overrides as separate events.
@@ -84,3 +79,38 @@ func generateICSFromSchedule(roster RosterResponse, schedule []ScheduleEntry, ov
 	b.WriteString("END:VCALENDAR\r\n")
 	return b.String()
 }
+
+// shiftWindow is a half-open [start, end) interval of on-call duty.
+type shiftWindow struct {
+	start time.Time
+	end   time.Time
+}
+
+// followTheSunShifts splits [start, end) into the roster's daily active
+// windows, evaluated in the roster's timezone.
+func followTheSunShifts(roster RosterResponse, start, end time.Time, tz *time.Location) []shiftWindow {
+	startMin, endMin := activeWindowMinutes(roster)
+
+	var shifts []shiftWindow
+	local := start.In(tz)
+	// Begin one day early so a window that wraps past midnight into the
+	// first day is not lost.
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, tz).AddDate(0, 0, -1)
+	for ; day.Before(end); day = day.AddDate(0, 0, 1) {
+		ws := time.Date(day.Year(), day.Month(), day.Day(), startMin/60, startMin%60, 0, 0, tz)
+		we := time.Date(day.Year(), day.Month(), day.Day(), endMin/60, endMin%60, 0, 0, tz)
+		if endMin <= startMin {
+			we = we.AddDate(0, 0, 1)
+		}
+		if ws.Before(start) {
+			ws = start
+		}
+		if we.After(end) {
+			we = end
+		}
+		if ws.Before(we) {
+			shifts = append(shifts, shiftWindow{start: ws, end: we})
+		}
+	}
+	return shifts
+}
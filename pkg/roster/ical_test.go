@@ -45,6 +45,8 @@ func TestGenerateICSFromSchedule_WithEntries(t *testing.T) {
 			RosterID:             rosterID,
 			WeekStart:            "2026-02-24",
 			WeekEnd:              "2026-03-03",
+			ShiftStart:           time.Date(2026, 2, 24, 8, 0, 0, 0, time.UTC),
+			ShiftEnd:             time.Date(2026, 3, 3, 8, 0, 0, 0, time.UTC),
 			PrimaryUserID:        &primary,
 			PrimaryDisplayName:   "Alice",
 			SecondaryUserID:      &secondary,
@@ -98,3 +100,62 @@ func TestGenerateICSFromSchedule_WithOverrides(t *testing.T) {
 		t.Error("expected override reason in calendar")
 	}
 }
+
+func TestGenerateICSFromSchedule_FollowTheSun(t *testing.T) {
+	rosterID := uuid.New()
+	primary := uuid.New()
+	start, end := "08:00", "20:00"
+	r := RosterResponse{
+		ID:               rosterID,
+		Name:             "EMEA",
+		Timezone:         "UTC",
+		HandoffTime:      "08:00",
+		IsFollowTheSun:   true,
+		ActiveHoursStart: &start,
+		ActiveHoursEnd:   &end,
+	}
+
+	schedule := []ScheduleEntry{
+		{
+			ID:                 uuid.New(),
+			RosterID:           rosterID,
+			WeekStart:          "2026-02-24",
+			WeekEnd:            "2026-03-03",
+			ShiftStart:         time.Date(2026, 2, 24, 8, 0, 0, 0, time.UTC),
+			ShiftEnd:           time.Date(2026, 3, 3, 8, 0, 0, 0, time.UTC),
+			PrimaryUserID:      &primary,
+			PrimaryDisplayName: "Alice",
+		},
+	}
+
+	ical := generateICSFromSchedule(r, schedule, nil)
+
+	if got := strings.Count(ical, "BEGIN:VEVENT"); got != 7 {
+		t.Fatalf("expected 7 daily events, got %d", got)
+	}
+	if !strings.Contains(ical, "DTSTART:20260224T080000Z") || !strings.Contains(ical, "DTEND:20260224T200000Z") {
+		t.Error("expected first shift 08:00-20:00 UTC")
+	}
+}
+
+func TestFollowTheSunShifts_WrapsMidnight(t *testing.T) {
+	start, end := "20:00", "08:00"
+	r := RosterResponse{
+		Timezone:         "UTC",
+		HandoffTime:      "20:00",
+		ActiveHoursStart: &start,
+		ActiveHoursEnd:   &end,
+	}
+
+	from := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 3, 4, 8, 0, 0, 0, time.UTC)
+	shifts := followTheSunShifts(r, from, to, time.UTC)
+
+	if len(shifts) != 2 {
+		t.Fatalf("expected 2 shifts, got %d", len(shifts))
+	}
+	if !shifts[0].start.Equal(time.Date(2026, 3, 2, 20, 0, 0, 0, time.UTC)) ||
+		!shifts[0].end.Equal(time.Date(2026, 3, 3, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected first shift %v - %v", shifts[0].start, shifts[0].end)
+	}
+}
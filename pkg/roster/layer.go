@@ -0,0 +1,228 @@
+package roster
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wisbric/nightowl/pkg/timeoff"
+)
+
+// layerMatch is the layer answering for a roster at an instant.
+type layerMatch struct {
+	layer     *LayerResponse
+	primary   *OnCallEntry
+	secondary *OnCallEntry
+	start     time.Time // later of the layer shift start and restriction window start
+	end       time.Time // earliest of the layer shift end, restriction window end and a higher layer's start
+}
+
+// validateLayer checks a layer's rotation and restrictions.
+func validateLayer(req LayerRequest) error {
+	if err := validateRotation(rotationOrDefault(req.RotationUnit, req.RotationLengths)); err != nil {
+		return err
+	}
+	for _, d := range req.DaysOfWeek {
+		if d < 0 || d > 6 {
+			return fmt.Errorf("days_of_week must be between 0 (Sunday) and 6 (Saturday)")
+		}
+	}
+	if (req.StartTime == nil) != (req.EndTime == nil) {
+		return fmt.Errorf("start_time and end_time must be set together")
+	}
+	if req.StartTime != nil {
+		if _, err := time.Parse("15:04", *req.StartTime); err != nil {
+			return fmt.Errorf("invalid start_time (use HH:MM)")
+		}
+		if _, err := time.Parse("15:04", *req.EndTime); err != nil {
+			return fmt.Errorf("invalid end_time (use HH:MM)")
+		}
+	}
+	return nil
+}
+
+// layerLookahead bounds searches for layer windows. Windows repeat weekly,
+// so every boundary there is shows up within eight days.
+const layerLookahead = 8 * 24 * time.Hour
+
+// layerDayWindow returns the restriction window of the layer that starts on
+// day, the local midnight of a day in the roster's timezone. A window that
+// wraps past midnight belongs to the day it starts on, so a weekday night
+// layer covers Friday night into Saturday.
+func layerDayWindow(layer LayerResponse, day time.Time) (shiftWindow, bool) {
+	if len(layer.DaysOfWeek) > 0 && !slices.Contains(layer.DaysOfWeek, int(day.Weekday())) {
+		return shiftWindow{}, false
+	}
+	if layer.StartTime == nil || layer.EndTime == nil {
+		return shiftWindow{start: day, end: day.AddDate(0, 0, 1)}, true
+	}
+	start, errS := time.Parse("15:04", *layer.StartTime)
+	end, errE := time.Parse("15:04", *layer.EndTime)
+	if errS != nil || errE != nil {
+		return shiftWindow{}, false
+	}
+	ws := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, day.Location())
+	we := time.Date(day.Year(), day.Month(), day.Day(), end.Hour(), end.Minute(), 0, 0, day.Location())
+	if !we.After(ws) {
+		we = we.AddDate(0, 0, 1)
+	}
+	return shiftWindow{start: ws, end: we}, true
+}
+
+// layerWindows returns the layer's restriction windows overlapping
+// [from, to], evaluated in loc. Windows that follow on from each other, such
+// as the days of an unrestricted layer, are joined, so every window end is a
+// point where the layer stops covering.
+func layerWindows(layer LayerResponse, loc *time.Location, from, to time.Time) []shiftWindow {
+	f := from.In(loc)
+	var joined []shiftWindow
+	for day := time.Date(f.Year(), f.Month(), f.Day()-1, 0, 0, 0, 0, loc); !day.After(to); day = day.AddDate(0, 0, 1) {
+		w, ok := layerDayWindow(layer, day)
+		if !ok {
+			continue
+		}
+		if n := len(joined); n > 0 && !w.start.After(joined[n-1].end) {
+			if w.end.After(joined[n-1].end) {
+				joined[n-1].end = w.end
+			}
+			continue
+		}
+		joined = append(joined, w)
+	}
+
+	var windows []shiftWindow
+	for _, w := range joined {
+		if w.end.After(from) && !w.start.After(to) {
+			windows = append(windows, w)
+		}
+	}
+	return windows
+}
+
+// windowAt returns the window containing at.
+func windowAt(windows []shiftWindow, at time.Time) (shiftWindow, bool) {
+	for _, w := range windows {
+		if !at.Before(w.start) && at.Before(w.end) {
+			return w, true
+		}
+	}
+	return shiftWindow{}, false
+}
+
+// pickLayer resolves the highest-priority layer covering the given instant.
+// Layers must be ordered by priority, highest first. Each layer rotates
+// through its members on its own rotation, anchored like the roster's.
+// Like the flat rotation, members with time off during the shift are
+// passed over for the next ones in rotation order, unless nobody is free.
+func pickLayer(roster RosterResponse, layers []LayerResponse, periods []timeoff.Period, at time.Time) *layerMatch {
+	loc, err := time.LoadLocation(roster.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	for i := range layers {
+		layer := &layers[i]
+		if len(layer.Members) == 0 {
+			continue
+		}
+		lr := roster
+		lr.RotationUnit = layer.RotationUnit
+		lr.RotationLengths = layer.RotationLengths
+		idx, shift := shiftIndexAt(lr, at)
+
+		window, ok := windowAt(layerWindows(*layer, loc, shift.start, shift.end), at)
+		if !ok {
+			continue
+		}
+
+		m := &layerMatch{layer: layer, start: shift.start, end: shift.end}
+		if window.start.After(m.start) {
+			m.start = window.start
+		}
+		if window.end.Before(m.end) {
+			m.end = window.end
+		}
+		// A higher-priority layer starting before then takes over.
+		for _, higher := range layers[:i] {
+			if len(higher.Members) == 0 {
+				continue
+			}
+			for _, w := range layerWindows(higher, loc, at, m.end) {
+				if w.start.After(at) && w.start.Before(m.end) {
+					m.end = w.start
+				}
+			}
+		}
+
+		free := availableLayerMembers(layer.Members, idx, periods, shiftWindow{start: m.start, end: m.end})
+		m.primary = free[0]
+		for _, next := range free[1:] {
+			if next.UserID != m.primary.UserID {
+				m.secondary = next
+				break
+			}
+		}
+		return m
+	}
+	return nil
+}
+
+// availableLayerMembers returns the layer's members in rotation order from
+// idx, leaving out those unavailable for the slot. When nobody is free all
+// of them are returned so the layer is still staffed.
+func availableLayerMembers(members []OnCallEntry, idx int, periods []timeoff.Period, slot shiftWindow) []*OnCallEntry {
+	n := len(members)
+	all := make([]*OnCallEntry, 0, n)
+	var free []*OnCallEntry
+	for k := 0; k < n; k++ {
+		m := &members[mod(idx+k, n)]
+		all = append(all, m)
+		if !unavailable(periods, m.UserID, slot) {
+			free = append(free, m)
+		}
+	}
+	if len(free) == 0 {
+		return all
+	}
+	return free
+}
+
+// layerPeriods loads time off for the layers' members over the layer shifts
+// running between from and to, so availability is judged per whole shift.
+func (s *Service) layerPeriods(ctx context.Context, roster RosterResponse, layers []LayerResponse, from, to time.Time) ([]timeoff.Period, error) {
+	seen := make(map[uuid.UUID]bool)
+	var ids []uuid.UUID
+	start, end := from, to
+	for _, layer := range layers {
+		for _, m := range layer.Members {
+			if !seen[m.UserID] {
+				seen[m.UserID] = true
+				ids = append(ids, m.UserID)
+			}
+		}
+		lr := roster
+		lr.RotationUnit = layer.RotationUnit
+		lr.RotationLengths = layer.RotationLengths
+		if _, shift := shiftIndexAt(lr, from); shift.start.Before(start) {
+			start = shift.start
+		}
+		if _, shift := shiftIndexAt(lr, to); shift.end.After(end) {
+			end = shift.end
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	periods, err := s.timeoff.ListOverlapping(ctx, ids, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("listing layer member unavailability: %w", err)
+	}
+	return periods, nil
+}
+
+func mod(a, n int) int {
+	return ((a % n) + n) % n
+}
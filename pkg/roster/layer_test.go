@@ -0,0 +1,221 @@
+package roster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wisbric/nightowl/pkg/timeoff"
+)
+
+func strPtr(s string) *string { return &s }
+
+func businessHoursRoster() (RosterResponse, []LayerResponse) {
+	r := RosterResponse{ID: uuid.New(), Name: "Platform", Timezone: "UTC", HandoffTime: "09:00", HandoffDay: 1}
+	alice := OnCallEntry{UserID: uuid.New(), DisplayName: "Alice"}
+	bob := OnCallEntry{UserID: uuid.New(), DisplayName: "Bob"}
+	carol := OnCallEntry{UserID: uuid.New(), DisplayName: "Carol"}
+
+	layers := []LayerResponse{
+		{
+			ID:              uuid.New(),
+			Name:            "Business hours",
+			Priority:        10,
+			RotationUnit:    RotationWeeks,
+			RotationLengths: []int{1},
+			DaysOfWeek:      []int{1, 2, 3, 4, 5},
+			StartTime:       strPtr("09:00"),
+			EndTime:         strPtr("17:00"),
+			Members:         []OnCallEntry{alice, bob},
+		},
+		{
+			ID:              uuid.New(),
+			Name:            "Weeknights",
+			Priority:        5,
+			RotationUnit:    RotationDays,
+			RotationLengths: []int{1},
+			DaysOfWeek:      []int{1, 2, 3, 4},
+			StartTime:       strPtr("17:00"),
+			EndTime:         strPtr("09:00"),
+			Members:         []OnCallEntry{carol},
+		},
+	}
+	return r, layers
+}
+
+func TestPickLayer_Priority(t *testing.T) {
+	r, layers := businessHoursRoster()
+
+	// Tuesday 10:00 — business hours.
+	m := pickLayer(r, layers, nil, time.Date(2026, 3, 3, 10, 0, 0, 0, time.UTC))
+	if m == nil || m.layer.Name != "Business hours" {
+		t.Fatalf("expected business hours layer, got %+v", m)
+	}
+	if m.secondary == nil {
+		t.Error("expected a secondary from a two-member layer")
+	}
+	if want := time.Date(2026, 3, 3, 17, 0, 0, 0, time.UTC); !m.end.Equal(want) {
+		t.Errorf("handoff at %v, want %v", m.end, want)
+	}
+
+	// Tuesday 03:00 — Monday's night window.
+	m = pickLayer(r, layers, nil, time.Date(2026, 3, 3, 3, 0, 0, 0, time.UTC))
+	if m == nil || m.layer.Name != "Weeknights" {
+		t.Fatalf("expected weeknights layer, got %+v", m)
+	}
+	if m.primary.DisplayName != "Carol" || m.secondary != nil {
+		t.Errorf("expected Carol alone, got %+v / %+v", m.primary, m.secondary)
+	}
+}
+
+// alwaysOnRoster has a 24/7 base layer below a weekday 09:00–17:00 layer.
+func alwaysOnRoster() (RosterResponse, []LayerResponse) {
+	r, layers := businessHoursRoster()
+	base := LayerResponse{
+		ID:              uuid.New(),
+		Name:            "Around the clock",
+		Priority:        1,
+		RotationUnit:    RotationWeeks,
+		RotationLengths: []int{1},
+		Members:         []OnCallEntry{{UserID: uuid.New(), DisplayName: "Dave"}},
+	}
+	return r, []LayerResponse{layers[0], base}
+}
+
+func TestPickLayer_EndsWhereHigherLayerStarts(t *testing.T) {
+	r, layers := alwaysOnRoster()
+	tests := []struct {
+		name      string
+		at        time.Time
+		layer     string
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{"before business hours", time.Date(2026, 3, 3, 8, 0, 0, 0, time.UTC), "Around the clock",
+			time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC), time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC)},
+		{"business hours", time.Date(2026, 3, 3, 10, 0, 0, 0, time.UTC), "Business hours",
+			time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC), time.Date(2026, 3, 3, 17, 0, 0, 0, time.UTC)},
+		{"evening runs past midnight", time.Date(2026, 3, 3, 20, 0, 0, 0, time.UTC), "Around the clock",
+			time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC), time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC)},
+		{"weekend until the weekly handoff", time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC), "Around the clock",
+			time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC), time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := pickLayer(r, layers, nil, tt.at)
+			if m == nil || m.layer.Name != tt.layer {
+				t.Fatalf("expected layer %q, got %+v", tt.layer, m)
+			}
+			if !m.start.Equal(tt.wantStart) || !m.end.Equal(tt.wantEnd) {
+				t.Errorf("shift = %v – %v, want %v – %v", m.start, m.end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestPickLayer_GapOverWeekend(t *testing.T) {
+	r, layers := businessHoursRoster()
+
+	// Friday 20:00 — weeknights stop on Thursday night.
+	if m := pickLayer(r, layers, nil, time.Date(2026, 3, 6, 20, 0, 0, 0, time.UTC)); m != nil {
+		t.Errorf("expected gap on Friday night, got layer %q", m.layer.Name)
+	}
+	// Friday 08:00 — Thursday night still running.
+	if m := pickLayer(r, layers, nil, time.Date(2026, 3, 6, 8, 0, 0, 0, time.UTC)); m == nil {
+		t.Error("expected Thursday night layer to cover Friday 08:00")
+	}
+}
+
+func TestPickLayer_RotatesMembers(t *testing.T) {
+	r, layers := businessHoursRoster()
+
+	week1 := pickLayer(r, layers, nil, time.Date(2026, 3, 3, 10, 0, 0, 0, time.UTC))
+	week2 := pickLayer(r, layers, nil, time.Date(2026, 3, 10, 10, 0, 0, 0, time.UTC))
+	if week1.primary.UserID == week2.primary.UserID {
+		t.Error("expected weekly layer to rotate primary between weeks")
+	}
+	if week1.primary.UserID != week2.secondary.UserID {
+		t.Error("expected this week's primary to be next week's secondary")
+	}
+}
+
+func TestPickLayer_SkipsUnavailableMembers(t *testing.T) {
+	r, layers := businessHoursRoster()
+	at := time.Date(2026, 3, 3, 10, 0, 0, 0, time.UTC) // Tuesday business hours
+	scheduled := pickLayer(r, layers, nil, at)
+	holder, next := scheduled.primary.UserID, scheduled.secondary.UserID
+
+	// Time off later in the day still overlaps the shift.
+	periods := []timeoff.Period{{
+		UserID:  holder,
+		StartAt: time.Date(2026, 3, 3, 15, 0, 0, 0, time.UTC),
+		EndAt:   time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC),
+	}}
+	m := pickLayer(r, layers, periods, at)
+	if m.primary.UserID != next {
+		t.Errorf("expected the next member to stand in, got %s", m.primary.DisplayName)
+	}
+	if m.secondary != nil {
+		t.Errorf("expected no secondary while the other member is away, got %s", m.secondary.DisplayName)
+	}
+
+	// Time off outside the shift does not count.
+	periods[0].StartAt = time.Date(2026, 3, 3, 17, 0, 0, 0, time.UTC)
+	if m := pickLayer(r, layers, periods, at); m.primary.UserID != holder {
+		t.Errorf("expected the scheduled member, got %s", m.primary.DisplayName)
+	}
+
+	// With everyone away the rotation stands.
+	periods = []timeoff.Period{
+		{UserID: holder, StartAt: at, EndAt: at.Add(time.Hour)},
+		{UserID: next, StartAt: at, EndAt: at.Add(time.Hour)},
+	}
+	if m := pickLayer(r, layers, periods, at); m.primary.UserID != holder || m.secondary.UserID != next {
+		t.Errorf("expected the scheduled members when nobody is free, got %+v / %+v", m.primary, m.secondary)
+	}
+}
+
+func TestResolveFromCache_LayerGap(t *testing.T) {
+	r, layers := businessHoursRoster()
+	rc := &coverageCache{layers: layers}
+
+	_, covered := resolveFromCache(rc, r, time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC)) // Saturday
+	if covered {
+		t.Error("expected Saturday to be uncovered")
+	}
+
+	c, covered := resolveFromCache(rc, r, time.Date(2026, 3, 3, 10, 0, 0, 0, time.UTC))
+	if !covered || c.Source != "layer" || c.Layer != "Business hours" {
+		t.Errorf("expected business hours coverage, got %+v (covered=%v)", c, covered)
+	}
+}
+
+func TestGapTracker(t *testing.T) {
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	var g gapTracker
+	for i, gap := range []bool{false, true, true, false, true} {
+		g.observe(base.Add(time.Duration(i)*time.Hour), gap)
+	}
+	g.finish(base.Add(5 * time.Hour))
+
+	if len(g.gaps) != 2 {
+		t.Fatalf("expected 2 gaps, got %d", len(g.gaps))
+	}
+	if g.minutes != 180 {
+		t.Errorf("expected 180 gap minutes, got %d", g.minutes)
+	}
+}
+
+func TestValidateLayer(t *testing.T) {
+	ok := LayerRequest{Name: "Nights", StartTime: strPtr("18:00"), EndTime: strPtr("08:00"), DaysOfWeek: []int{1, 5}}
+	if err := validateLayer(ok); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validateLayer(LayerRequest{StartTime: strPtr("18:00")}); err == nil {
+		t.Error("expected error for start_time without end_time")
+	}
+	if err := validateLayer(LayerRequest{DaysOfWeek: []int{7}}); err == nil {
+		t.Error("expected error for day 7")
+	}
+}
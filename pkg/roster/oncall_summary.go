@@ -0,0 +1,235 @@
+package roster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// OnCallSummary is a roster's on-call state at an instant together with the
+// next handoff, as shown by the chat `oncall` commands.
+type OnCallSummary struct {
+	Roster    RosterResponse
+	Current   *OnCallResponse
+	HandoffAt *time.Time      // nil when nothing is scheduled to change
+	Next      *OnCallResponse // who answers from HandoffAt
+}
+
+// nextShiftLookahead bounds the search for the next shift of a roster that
+// is currently unassigned.
+const nextShiftLookahead = 30 * 24 * time.Hour
+
+// GetOnCallSummary resolves who is on call for roster at the given instant,
+// when that changes, and who takes over.
+func (s *Service) GetOnCallSummary(ctx context.Context, roster RosterResponse, at time.Time) (*OnCallSummary, error) {
+	current, err := s.GetOnCall(ctx, roster.ID, at)
+	if err != nil {
+		return nil, err
+	}
+	sum := &OnCallSummary{Roster: roster, Current: current}
+
+	handoff, err := s.nextHandoff(ctx, roster, current, at)
+	if err != nil {
+		return nil, err
+	}
+	if handoff == nil {
+		return sum, nil
+	}
+	next, err := s.GetOnCall(ctx, roster.ID, *handoff)
+	if err != nil {
+		return nil, err
+	}
+	sum.HandoffAt = handoff
+	sum.Next = next
+	return sum, nil
+}
+
+// nextHandoff returns the earliest instant after at where the resolution for
+// roster can change: the end of the active override or shift, the start of
+// an upcoming override, a layer window boundary, or a follow-the-sun
+// active-hours boundary.
+func (s *Service) nextHandoff(ctx context.Context, roster RosterResponse, current *OnCallResponse, at time.Time) (*time.Time, error) {
+	var handoff *time.Time
+	consider := func(t time.Time) {
+		if t.After(at) && (handoff == nil || t.Before(*handoff)) {
+			handoff = &t
+		}
+	}
+
+	if current.ActiveOverride != nil {
+		consider(current.ActiveOverride.EndAt)
+	}
+	if current.ShiftEnd != nil {
+		consider(*current.ShiftEnd)
+	}
+	active := roster
+	if current.DelegatedFrom != nil {
+		delegated, err := s.store.GetRoster(ctx, current.RosterID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("getting delegated roster: %w", err)
+		}
+		if err == nil {
+			active = delegated
+			if roster.IsFollowTheSun {
+				consider(nextWindowBoundary(active, at))
+			}
+		}
+	}
+	if roster.IsFollowTheSun {
+		consider(nextWindowBoundary(roster, at))
+	}
+
+	layers, err := s.store.ListLayers(ctx, current.RosterID)
+	if err != nil {
+		return nil, fmt.Errorf("listing layers: %w", err)
+	}
+	if t, ok := nextLayerBoundary(active, layers, at); ok {
+		consider(t)
+	}
+
+	// Without a known end, the next change is the next generated shift.
+	if handoff == nil {
+		shifts, err := s.store.ListSchedule(ctx, current.RosterID, at, at.Add(nextShiftLookahead))
+		if err != nil {
+			return nil, fmt.Errorf("listing upcoming shifts: %w", err)
+		}
+		for _, e := range shifts {
+			consider(e.ShiftStart)
+		}
+	}
+
+	until := at.Add(nextShiftLookahead)
+	if handoff != nil {
+		until = *handoff
+	}
+	overrides, err := s.store.ListOverridesInRange(ctx, current.RosterID, at, until)
+	if err != nil {
+		return nil, fmt.Errorf("listing upcoming overrides: %w", err)
+	}
+	for _, o := range overrides {
+		consider(o.StartAt)
+	}
+	return handoff, nil
+}
+
+// nextWindowBoundary returns the first instant after at where roster enters
+// or leaves its daily active window.
+func nextWindowBoundary(roster RosterResponse, at time.Time) time.Time {
+	loc, err := time.LoadLocation(roster.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	start, end := activeWindowMinutes(roster)
+	local := at.In(loc)
+	var best time.Time
+	for day := 0; day <= 1; day++ {
+		for _, m := range []int{start, end} {
+			t := time.Date(local.Year(), local.Month(), local.Day()+day, m/60, m%60, 0, 0, loc)
+			if t.After(at) && (best.IsZero() || t.Before(best)) {
+				best = t
+			}
+		}
+	}
+	return best
+}
+
+// nextLayerBoundary returns the first instant after at where a window of
+// one of the layers starts or ends, honouring their days of the week.
+func nextLayerBoundary(roster RosterResponse, layers []LayerResponse, at time.Time) (time.Time, bool) {
+	loc, err := time.LoadLocation(roster.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	until := at.Add(layerLookahead)
+	var best time.Time
+	consider := func(t time.Time) {
+		if t.After(at) && t.Before(until) && (best.IsZero() || t.Before(best)) {
+			best = t
+		}
+	}
+	for _, layer := range layers {
+		if len(layer.Members) == 0 {
+			continue
+		}
+		for _, w := range layerWindows(layer, loc, at, until) {
+			consider(w.start)
+			consider(w.end)
+		}
+	}
+	return best, !best.IsZero()
+}
+
+// ErrInvalidChatTime is returned by ParseChatTime for unrecognised input.
+var ErrInvalidChatTime = errors.New("invalid time (use HH:MM, tomorrow HH:MM, YYYY-MM-DD [HH:MM] or RFC3339)")
+
+// ParseChatTime parses the time argument of `/nightowl oncall ... at <time>`.
+// Times without a zone are read in loc; a bare clock time is today's.
+func ParseChatTime(s string, loc *time.Location, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	local := now.In(loc)
+	day := 0
+	lower := strings.ToLower(s)
+	switch {
+	case lower == "now":
+		return now, nil
+	case strings.HasPrefix(lower, "tomorrow"):
+		day = 1
+		s = strings.TrimSpace(s[len("tomorrow"):])
+		if s == "" {
+			s = "00:00"
+		}
+	}
+	clock, err := time.Parse("15:04", s)
+	if err != nil {
+		return time.Time{}, ErrInvalidChatTime
+	}
+	return time.Date(local.Year(), local.Month(), local.Day()+day, clock.Hour(), clock.Minute(), 0, 0, loc), nil
+}
+
+// SplitOnCallArgs splits `/nightowl oncall` arguments into a roster filter
+// and an optional time following the last "at".
+func SplitOnCallArgs(args []string) (filter, at string) {
+	for i := len(args) - 1; i >= 0; i-- {
+		if strings.EqualFold(args[i], "at") && i < len(args)-1 {
+			return strings.Join(args[:i], " "), strings.Join(args[i+1:], " ")
+		}
+	}
+	return strings.Join(args, " "), ""
+}
+
+// FormatRemaining renders a duration as a short human string such as
+// "2d 4h", "3h 20m" or "45m".
+func FormatRemaining(d time.Duration) string {
+	if d < time.Minute {
+		return "<1m"
+	}
+	d = d.Round(time.Minute)
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	mins := int(d % time.Hour / time.Minute)
+	switch {
+	case days > 0 && hours > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case days > 0:
+		return fmt.Sprintf("%dd", days)
+	case hours > 0 && mins > 0:
+		return fmt.Sprintf("%dh %dm", hours, mins)
+	case hours > 0:
+		return fmt.Sprintf("%dh", hours)
+	default:
+		return fmt.Sprintf("%dm", mins)
+	}
+}
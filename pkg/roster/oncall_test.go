@@ -3,6 +3,8 @@ package roster
 import (
 	"testing"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 func TestParseHandoffTime(t *testing.T) {
@@ -116,3 +118,165 @@ func TestIsInShiftWindow_WrapsMidnight(t *testing.T) {
 		})
 	}
 }
+
+func TestPickFollowTheSun(t *testing.T) {
+	svc := &Service{}
+	apacStart, apacEnd := "20:00", "08:00"
+	emeaStart, emeaEnd := "08:00", "20:00"
+	apac := RosterResponse{
+		ID:               uuid.New(),
+		Name:             "APAC",
+		Timezone:         "UTC",
+		HandoffTime:      "20:00",
+		IsFollowTheSun:   true,
+		IsActive:         true,
+		ActiveHoursStart: &apacStart,
+		ActiveHoursEnd:   &apacEnd,
+	}
+	emea := RosterResponse{
+		ID:               uuid.New(),
+		Name:             "EMEA",
+		Timezone:         "UTC",
+		HandoffTime:      "08:00",
+		IsFollowTheSun:   true,
+		IsActive:         true,
+		ActiveHoursStart: &emeaStart,
+		ActiveHoursEnd:   &emeaEnd,
+	}
+	inactive := emea
+	inactive.IsActive = false
+
+	tests := []struct {
+		name     string
+		roster   RosterResponse
+		partners []RosterResponse
+		at       time.Time
+		want     uuid.UUID
+	}{
+		{"own active hours", apac, []RosterResponse{emea}, time.Date(2026, 1, 1, 23, 0, 0, 0, time.UTC), apac.ID},
+		{"partner active hours", apac, []RosterResponse{emea}, time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC), emea.ID},
+		{"handoff instant", emea, []RosterResponse{apac}, time.Date(2026, 1, 1, 20, 0, 0, 0, time.UTC), apac.ID},
+		{"partner inactive", apac, []RosterResponse{inactive}, time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC), apac.ID},
+		{"no partner", apac, nil, time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC), apac.ID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := svc.pickFollowTheSun(tt.roster, tt.partners, tt.at)
+			if got.ID != tt.want {
+				t.Errorf("pickFollowTheSun(%v) = %s, want %s", tt.at, got.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitOnCallArgs(t *testing.T) {
+	tests := []struct {
+		args       []string
+		filter, at string
+	}{
+		{nil, "", ""},
+		{[]string{"platform", "eu"}, "platform eu", ""},
+		{[]string{"platform", "at", "tomorrow", "09:00"}, "platform", "tomorrow 09:00"},
+		{[]string{"at", "18:00"}, "", "18:00"},
+		{[]string{"look", "at", "me"}, "look", "me"},
+		{[]string{"platform", "at"}, "platform at", ""},
+	}
+	for _, tt := range tests {
+		filter, at := SplitOnCallArgs(tt.args)
+		if filter != tt.filter || at != tt.at {
+			t.Errorf("SplitOnCallArgs(%q) = (%q, %q), want (%q, %q)", tt.args, filter, at, tt.filter, tt.at)
+		}
+	}
+}
+
+func TestParseChatTime(t *testing.T) {
+	loc, _ := time.LoadLocation("Europe/Berlin")
+	now := time.Date(2026, 3, 2, 10, 30, 0, 0, time.UTC) // 11:30 in Berlin
+
+	tests := []struct {
+		input string
+		want  time.Time
+	}{
+		{"18:00", time.Date(2026, 3, 2, 18, 0, 0, 0, loc)},
+		{"tomorrow 09:00", time.Date(2026, 3, 3, 9, 0, 0, 0, loc)},
+		{"2026-03-10", time.Date(2026, 3, 10, 0, 0, 0, 0, loc)},
+		{"2026-03-10 07:15", time.Date(2026, 3, 10, 7, 15, 0, 0, loc)},
+		{"2026-03-10T07:15:00Z", time.Date(2026, 3, 10, 7, 15, 0, 0, time.UTC)},
+		{"now", now},
+	}
+	for _, tt := range tests {
+		got, err := ParseChatTime(tt.input, loc, now)
+		if err != nil {
+			t.Errorf("ParseChatTime(%q) error: %v", tt.input, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseChatTime(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	if _, err := ParseChatTime("next week", loc, now); err != ErrInvalidChatTime {
+		t.Errorf("expected ErrInvalidChatTime, got %v", err)
+	}
+}
+
+func TestFormatRemaining(t *testing.T) {
+	tests := map[time.Duration]string{
+		30 * time.Second:              "<1m",
+		45 * time.Minute:              "45m",
+		3*time.Hour + 20*time.Minute:  "3h 20m",
+		5 * time.Hour:                 "5h",
+		52*time.Hour + 10*time.Minute: "2d 4h",
+		7 * 24 * time.Hour:            "7d",
+	}
+	for d, want := range tests {
+		if got := FormatRemaining(d); got != want {
+			t.Errorf("FormatRemaining(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestNextWindowBoundary(t *testing.T) {
+	start, end := "08:00", "20:00"
+	r := RosterResponse{Timezone: "UTC", ActiveHoursStart: &start, ActiveHoursEnd: &end}
+
+	tests := []struct {
+		at, want time.Time
+	}{
+		{time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC), time.Date(2026, 3, 2, 20, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 2, 22, 0, 0, 0, time.UTC), time.Date(2026, 3, 3, 8, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC), time.Date(2026, 3, 2, 20, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := nextWindowBoundary(r, tt.at); !got.Equal(tt.want) {
+			t.Errorf("nextWindowBoundary(%v) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestNextLayerBoundary(t *testing.T) {
+	// A 24/7 layer below a weekday 09:00–17:00 layer: handoffs happen where
+	// business hours start and end, not at midnight.
+	r, layers := alwaysOnRoster()
+
+	tests := []struct {
+		at, want time.Time
+	}{
+		{time.Date(2026, 3, 3, 8, 0, 0, 0, time.UTC), time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 3, 10, 0, 0, 0, time.UTC), time.Date(2026, 3, 3, 17, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 3, 20, 0, 0, 0, time.UTC), time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 6, 18, 0, 0, 0, time.UTC), time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)}, // over the weekend
+	}
+	for _, tt := range tests {
+		got, ok := nextLayerBoundary(r, layers, tt.at)
+		if !ok || !got.Equal(tt.want) {
+			t.Errorf("nextLayerBoundary(%v) = %v, %v, want %v", tt.at, got, ok, tt.want)
+		}
+	}
+
+	// An unrestricted layer alone never hands over by itself.
+	if got, ok := nextLayerBoundary(r, layers[1:], time.Date(2026, 3, 3, 8, 0, 0, 0, time.UTC)); ok {
+		t.Errorf("expected no boundary for a 24/7 layer, got %v", got)
+	}
+}
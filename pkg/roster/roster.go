@@ -18,6 +18,8 @@ type CreateRosterRequest struct {
 	HandoffDay          int        `json:"handoff_day"`                      // 0=Sun..6=Sat, default 1=Mon
 	ScheduleWeeksAhead  int        `json:"schedule_weeks_ahead"`             // default 12
 	MaxConsecutiveWeeks int        `json:"max_consecutive_weeks"`            // default 2
+	RotationUnit        string     `json:"rotation_unit"`                    // hours | days | weeks, default weeks
+	RotationLengths     []int      `json:"rotation_lengths"`                 // shift lengths cycled in order, default [1]
 	IsFollowTheSun      bool       `json:"is_follow_the_sun"`
 	LinkedRosterID      *uuid.UUID `json:"linked_roster_id"`
 	ActiveHoursStart    *string    `json:"active_hours_start"` // HH:MM
@@ -35,6 +37,8 @@ type UpdateRosterRequest struct {
 	HandoffDay          int     `json:"handoff_day"`
 	ScheduleWeeksAhead  int     `json:"schedule_weeks_ahead"`
 	MaxConsecutiveWeeks int     `json:"max_consecutive_weeks"`
+	RotationUnit        string  `json:"rotation_unit"`
+	RotationLengths     []int   `json:"rotation_lengths"`
 	EndDate             *string `json:"end_date"`
 	IsActive            *bool   `json:"is_active"`
 }
@@ -49,6 +53,8 @@ type RosterResponse struct {
 	HandoffDay          int        `json:"handoff_day"`
 	ScheduleWeeksAhead  int        `json:"schedule_weeks_ahead"`
 	MaxConsecutiveWeeks int        `json:"max_consecutive_weeks"`
+	RotationUnit        string     `json:"rotation_unit"`
+	RotationLengths     []int      `json:"rotation_lengths"`
 	IsFollowTheSun      bool       `json:"is_follow_the_sun"`
 	LinkedRosterID      *uuid.UUID `json:"linked_roster_id,omitempty"`
 	ActiveHoursStart    *string    `json:"active_hours_start,omitempty"`
@@ -81,18 +87,24 @@ type MemberResponse struct {
 	IsActive             bool       `json:"is_active"`
 	JoinedAt             time.Time  `json:"joined_at"`
 	LeftAt               *time.Time `json:"left_at,omitempty"`
-	PrimaryWeeksServed   int        `json:"primary_weeks_served"`
+	PrimaryWeeksServed   int        `json:"primary_weeks_served"` // shifts served, whatever their length
 	SecondaryWeeksServed int        `json:"secondary_weeks_served"`
+	PrimaryHoursServed   float64    `json:"primary_hours_served"`
+	SecondaryHoursServed float64    `json:"secondary_hours_served"`
 }
 
 // --- Schedule types ---
 
-// ScheduleEntry represents one week in the roster schedule.
+// ScheduleEntry represents one shift in the roster schedule. For weekly
+// rotations a shift is a week; daily and custom-length rotations produce
+// shorter shifts.
 type ScheduleEntry struct {
 	ID                   uuid.UUID  `json:"id"`
 	RosterID             uuid.UUID  `json:"roster_id"`
-	WeekStart            string     `json:"week_start"` // YYYY-MM-DD
-	WeekEnd              string     `json:"week_end"`
+	WeekStart            string     `json:"week_start"` // YYYY-MM-DD, local date the shift starts
+	WeekEnd              string     `json:"week_end"`   // YYYY-MM-DD, local date the shift ends
+	ShiftStart           time.Time  `json:"shift_start"`
+	ShiftEnd             time.Time  `json:"shift_end"`
 	PrimaryUserID        *uuid.UUID `json:"primary_user_id"`
 	PrimaryDisplayName   string     `json:"primary_display_name,omitempty"`
 	SecondaryUserID      *uuid.UUID `json:"secondary_user_id"`
@@ -102,9 +114,13 @@ type ScheduleEntry struct {
 	Notes                *string    `json:"notes,omitempty"`
 	CreatedAt            time.Time  `json:"created_at"`
 	UpdatedAt            time.Time  `json:"updated_at"`
+
+	// Conflicts lists assignees who are unavailable during the shift.
+	Conflicts []ScheduleConflict `json:"conflicts,omitempty"`
 }
 
 // UpdateScheduleWeekRequest is the JSON body for PUT /api/v1/rosters/:id/schedule/:weekStart.
+// The path key is a YYYY-MM-DD date or an RFC3339 instant inside the shift.
 type UpdateScheduleWeekRequest struct {
 	PrimaryUserID   *uuid.UUID `json:"primary_user_id"`
 	SecondaryUserID *uuid.UUID `json:"secondary_user_id"`
@@ -140,6 +156,96 @@ type OverrideResponse struct {
 	CreatedAt   time.Time  `json:"created_at"`
 }
 
+// --- Swap request types ---
+
+// Swap request kinds.
+const (
+	SwapKindCover = "cover" // responder takes the range via an override
+	SwapKindSwap  = "swap"  // requester and responder trade schedule shifts
+)
+
+// Swap request statuses.
+const (
+	SwapStatusPending   = "pending"
+	SwapStatusAccepted  = "accepted"
+	SwapStatusDeclined  = "declined"
+	SwapStatusCancelled = "cancelled"
+	SwapStatusExpired   = "expired"
+)
+
+// CreateSwapRequest is the JSON body for POST /api/v1/rosters/:id/swaps.
+// Times accept a YYYY-MM-DD date (the handoff time that day) or RFC3339.
+type CreateSwapRequest struct {
+	Kind         string     `json:"kind"`    // cover (default) | swap
+	Role         string     `json:"role"`    // primary (default) | secondary; cover is primary only
+	UserID       *uuid.UUID `json:"user_id"` // requester when calling with an API key
+	TargetUserID *uuid.UUID `json:"target_user_id"`
+	StartAt      string     `json:"start_at" validate:"required"`
+	EndAt        *string    `json:"end_at"`        // cover only; defaults to the end of the shift
+	SwapStartAt  *string    `json:"swap_start_at"` // swap only; the shift offered in return
+	Message      *string    `json:"message"`
+}
+
+// RespondSwapRequest is the JSON body for accept/decline/cancel.
+type RespondSwapRequest struct {
+	UserID      *uuid.UUID `json:"user_id"`       // responder when calling with an API key
+	SwapStartAt *string    `json:"swap_start_at"` // swap only, when the request left it open
+}
+
+// SwapRequestResponse is the JSON response for a swap request.
+type SwapRequestResponse struct {
+	ID            uuid.UUID  `json:"id"`
+	RosterID      uuid.UUID  `json:"roster_id"`
+	RosterName    string     `json:"roster_name"`
+	Kind          string     `json:"kind"`
+	Role          string     `json:"role"`
+	RequesterID   uuid.UUID  `json:"requester_id"`
+	RequesterName string     `json:"requester_name"`
+	TargetUserID  *uuid.UUID `json:"target_user_id,omitempty"`
+	TargetName    string     `json:"target_name,omitempty"`
+	StartAt       time.Time  `json:"start_at"`
+	EndAt         time.Time  `json:"end_at"`
+	SwapStartAt   *time.Time `json:"swap_start_at,omitempty"`
+	Message       *string    `json:"message,omitempty"`
+	Status        string     `json:"status"`
+	ResponderID   *uuid.UUID `json:"responder_id,omitempty"`
+	ResponderName string     `json:"responder_name,omitempty"`
+	RespondedAt   *time.Time `json:"responded_at,omitempty"`
+	OverrideID    *uuid.UUID `json:"override_id,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// --- Layer types ---
+
+// LayerRequest is the JSON body for POST/PUT /api/v1/rosters/:id/layers.
+type LayerRequest struct {
+	Name            string      `json:"name" validate:"required"`
+	Priority        int         `json:"priority"`         // higher wins
+	RotationUnit    string      `json:"rotation_unit"`    // hours | days | weeks, default weeks
+	RotationLengths []int       `json:"rotation_lengths"` // default [1]
+	DaysOfWeek      []int       `json:"days_of_week"`     // 0=Sun..6=Sat, empty = every day
+	StartTime       *string     `json:"start_time"`       // HH:MM, nil = all day
+	EndTime         *string     `json:"end_time"`         // HH:MM, may wrap past midnight
+	UserIDs         []uuid.UUID `json:"user_ids" validate:"required,min=1"`
+}
+
+// LayerResponse is the JSON response for a roster layer.
+type LayerResponse struct {
+	ID              uuid.UUID     `json:"id"`
+	RosterID        uuid.UUID     `json:"roster_id"`
+	Name            string        `json:"name"`
+	Priority        int           `json:"priority"`
+	RotationUnit    string        `json:"rotation_unit"`
+	RotationLengths []int         `json:"rotation_lengths"`
+	DaysOfWeek      []int         `json:"days_of_week"`
+	StartTime       *string       `json:"start_time,omitempty"`
+	EndTime         *string       `json:"end_time,omitempty"`
+	Members         []OnCallEntry `json:"members"` // rotation order
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
 // --- On-call types ---
 
 // OnCallResponse describes who is currently on-call.
@@ -147,11 +253,16 @@ type OnCallResponse struct {
 	RosterID       uuid.UUID         `json:"roster_id"`
 	RosterName     string            `json:"roster_name"`
 	QueriedAt      time.Time         `json:"queried_at"`
-	Source         string            `json:"source"` // "override" | "schedule" | "unassigned"
+	Source         string            `json:"source"` // "override" | "layer" | "schedule" | "unassigned"
 	Primary        *OnCallEntry      `json:"primary"`
 	Secondary      *OnCallEntry      `json:"secondary"`
 	WeekStart      *string           `json:"week_start,omitempty"`
+	ShiftStart     *time.Time        `json:"shift_start,omitempty"`
+	ShiftEnd       *time.Time        `json:"shift_end,omitempty"`
 	ActiveOverride *OverrideResponse `json:"active_override,omitempty"`
+	DelegatedFrom  *uuid.UUID        `json:"delegated_from,omitempty"` // follow-the-sun roster that handed over
+	LayerID        *uuid.UUID        `json:"layer_id,omitempty"`
+	LayerName      string            `json:"layer_name,omitempty"`
 }
 
 // OnCallEntry describes a single on-call person.
@@ -203,10 +314,20 @@ type CoverageSlotRoster struct {
 	Primary    string    `json:"primary"`
 	Secondary  string    `json:"secondary,omitempty"`
 	Source     string    `json:"source"`
+	Layer      string    `json:"layer,omitempty"`
 }
 
 // GapSummary describes coverage gaps.
 type GapSummary struct {
+	TotalGapHours float64     `json:"total_gap_hours"`
+	Gaps          []GapInfo   `json:"gaps"`
+	LayerGaps     []LayerGaps `json:"layer_gaps"` // per layered roster, time no layer covers
+}
+
+// LayerGaps lists the gaps a layered roster leaves between its layers.
+type LayerGaps struct {
+	RosterID      uuid.UUID `json:"roster_id"`
+	RosterName    string    `json:"roster_name"`
 	TotalGapHours float64   `json:"total_gap_hours"`
 	Gaps          []GapInfo `json:"gaps"`
 }
@@ -0,0 +1,184 @@
+package roster
+
+import (
+	"fmt"
+	"time"
+)
+
+// Rotation units for RosterResponse.RotationUnit.
+const (
+	RotationHours = "hours"
+	RotationDays  = "days"
+	RotationWeeks = "weeks"
+)
+
+// rotationEpoch anchors shift boundaries so that every generation run agrees
+// on where a rotation period starts. It is a Monday.
+var rotationEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// validateRotation checks a rotation unit and its cycle of shift lengths.
+func validateRotation(unit string, lengths []int) error {
+	switch unit {
+	case RotationHours, RotationDays, RotationWeeks:
+	default:
+		return fmt.Errorf("rotation_unit must be one of hours, days, weeks")
+	}
+	if len(lengths) == 0 {
+		return fmt.Errorf("rotation_lengths must not be empty")
+	}
+	for _, l := range lengths {
+		if l <= 0 {
+			return fmt.Errorf("rotation_lengths must be positive")
+		}
+	}
+	return nil
+}
+
+// rotationOrDefault returns the roster's rotation with defaults applied.
+func rotationOrDefault(unit string, lengths []int) (string, []int) {
+	if unit == "" {
+		unit = RotationWeeks
+	}
+	if len(lengths) == 0 {
+		lengths = []int{1}
+	}
+	return unit, lengths
+}
+
+// isWeeklyRotation reports whether the roster uses one-week shifts.
+func isWeeklyRotation(roster RosterResponse) bool {
+	unit, lengths := rotationOrDefault(roster.RotationUnit, roster.RotationLengths)
+	return unit == RotationWeeks && len(lengths) == 1 && lengths[0] == 1
+}
+
+// rotation places a roster's shift boundaries in time.
+type rotation struct {
+	unit    string
+	lengths []int
+	period  int // sum of lengths, in unit
+	loc     *time.Location
+	anchor  time.Time
+}
+
+func newRotation(roster RosterResponse) rotation {
+	unit, lengths := rotationOrDefault(roster.RotationUnit, roster.RotationLengths)
+
+	loc, err := time.LoadLocation(roster.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	handoff, err := time.Parse("15:04", roster.HandoffTime)
+	if err != nil {
+		handoff, _ = time.Parse("15:04", "09:00")
+	}
+
+	period := 0
+	for _, l := range lengths {
+		period += l
+	}
+
+	epochDay := alignToHandoffDay(rotationEpoch, roster.HandoffDay)
+	return rotation{
+		unit:    unit,
+		lengths: lengths,
+		period:  period,
+		loc:     loc,
+		anchor: time.Date(epochDay.Year(), epochDay.Month(), epochDay.Day(),
+			handoff.Hour(), handoff.Minute(), 0, 0, loc),
+	}
+}
+
+func (r rotation) advance(t time.Time, n int) time.Time {
+	switch r.unit {
+	case RotationHours:
+		return t.Add(time.Duration(n) * time.Hour)
+	case RotationDays:
+		return t.AddDate(0, 0, n)
+	default:
+		return t.AddDate(0, 0, 7*n)
+	}
+}
+
+// periodAt returns the index and start of the rotation period containing t.
+// Day and week periods are counted in calendar days so DST shifts do not
+// skew the index.
+func (r rotation) periodAt(t time.Time) (int, time.Time) {
+	var k int
+	switch r.unit {
+	case RotationHours:
+		k = floorDiv(int(t.Sub(r.anchor)/time.Hour), r.period)
+	default:
+		periodDays := r.period
+		if r.unit == RotationWeeks {
+			periodDays *= 7
+		}
+		k = floorDiv(calendarDays(r.anchor, t.In(r.loc)), periodDays)
+	}
+	start := r.advance(r.anchor, k*r.period)
+	for start.After(t) {
+		k--
+		start = r.advance(start, -r.period)
+	}
+	return k, start
+}
+
+// shiftsBetween returns the roster's rotation shifts that overlap [from, to).
+// Boundaries fall at the handoff time in the roster's timezone; a shift of N
+// days or weeks keeps its wall-clock handoff across DST changes, while hour
+// shifts are exact durations.
+func shiftsBetween(roster RosterResponse, from, to time.Time) []shiftWindow {
+	r := newRotation(roster)
+	_, start := r.periodAt(from)
+
+	var shifts []shiftWindow
+	for cur := start; cur.Before(to); {
+		for _, l := range r.lengths {
+			end := r.advance(cur, l)
+			if end.After(from) && cur.Before(to) {
+				shifts = append(shifts, shiftWindow{start: cur, end: end})
+			}
+			cur = end
+		}
+	}
+	return shifts
+}
+
+// shiftAt returns the rotation shift containing the given instant.
+func shiftAt(roster RosterResponse, at time.Time) shiftWindow {
+	_, w := shiftIndexAt(roster, at)
+	return w
+}
+
+// shiftIndexAt returns the shift containing the given instant together with
+// its sequence number counted from the rotation anchor. Layers use the
+// sequence number to rotate through their members.
+func shiftIndexAt(roster RosterResponse, at time.Time) (int, shiftWindow) {
+	r := newRotation(roster)
+	k, cur := r.periodAt(at)
+	idx := k * len(r.lengths)
+	for {
+		for _, l := range r.lengths {
+			end := r.advance(cur, l)
+			if end.After(at) {
+				return idx, shiftWindow{start: cur, end: end}
+			}
+			cur = end
+			idx++
+		}
+	}
+}
+
+// calendarDays counts whole calendar days from a's local date to b's local date.
+func calendarDays(a, b time.Time) int {
+	da := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	db := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(db.Sub(da).Hours() / 24)
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
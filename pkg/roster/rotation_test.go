@@ -0,0 +1,140 @@
+package roster
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShiftsBetween_Weekly(t *testing.T) {
+	r := RosterResponse{Timezone: "UTC", HandoffTime: "09:00", HandoffDay: 1}
+
+	from := time.Date(2026, 2, 25, 12, 0, 0, 0, time.UTC) // Wednesday
+	shifts := shiftsBetween(r, from, from.AddDate(0, 0, 14))
+
+	if len(shifts) != 3 {
+		t.Fatalf("expected 3 weekly shifts, got %d", len(shifts))
+	}
+	wantStart := time.Date(2026, 2, 23, 9, 0, 0, 0, time.UTC)
+	if !shifts[0].start.Equal(wantStart) {
+		t.Errorf("first shift starts %v, want %v", shifts[0].start, wantStart)
+	}
+	if got := shifts[0].end.Sub(shifts[0].start); got != 7*24*time.Hour {
+		t.Errorf("expected one-week shift, got %v", got)
+	}
+}
+
+func TestShiftsBetween_Daily(t *testing.T) {
+	r := RosterResponse{
+		Timezone:        "UTC",
+		HandoffTime:     "08:00",
+		HandoffDay:      1,
+		RotationUnit:    RotationDays,
+		RotationLengths: []int{1},
+	}
+
+	from := time.Date(2026, 3, 4, 7, 0, 0, 0, time.UTC)
+	shifts := shiftsBetween(r, from, from.AddDate(0, 0, 3))
+
+	if len(shifts) != 4 {
+		t.Fatalf("expected 4 daily shifts, got %d", len(shifts))
+	}
+	wantStart := time.Date(2026, 3, 3, 8, 0, 0, 0, time.UTC)
+	if !shifts[0].start.Equal(wantStart) {
+		t.Errorf("first shift starts %v, want %v", shifts[0].start, wantStart)
+	}
+	for i := 1; i < len(shifts); i++ {
+		if !shifts[i].start.Equal(shifts[i-1].end) {
+			t.Errorf("shift %d does not start where shift %d ends", i, i-1)
+		}
+	}
+}
+
+func TestShiftsBetween_SplitWeek(t *testing.T) {
+	r := RosterResponse{
+		Timezone:        "UTC",
+		HandoffTime:     "09:00",
+		HandoffDay:      1,
+		RotationUnit:    RotationDays,
+		RotationLengths: []int{4, 3},
+	}
+
+	// Monday 09:00 starts a 4-day shift, Friday 09:00 a 3-day shift.
+	from := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	shifts := shiftsBetween(r, from, from.AddDate(0, 0, 7))
+
+	if len(shifts) != 2 {
+		t.Fatalf("expected 2 shifts, got %d", len(shifts))
+	}
+	if got := shifts[0].end; !got.Equal(time.Date(2026, 3, 6, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("first shift ends %v, want Friday 09:00", got)
+	}
+	if got := shifts[1].end; !got.Equal(time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("second shift ends %v, want Monday 09:00", got)
+	}
+}
+
+func TestShiftsBetween_TwelveHour(t *testing.T) {
+	r := RosterResponse{
+		Timezone:        "UTC",
+		HandoffTime:     "07:00",
+		RotationUnit:    RotationHours,
+		RotationLengths: []int{12},
+	}
+
+	at := time.Date(2026, 3, 4, 22, 30, 0, 0, time.UTC)
+	shift := shiftAt(r, at)
+
+	if !shift.start.Equal(time.Date(2026, 3, 4, 19, 0, 0, 0, time.UTC)) {
+		t.Errorf("shift starts %v, want 19:00", shift.start)
+	}
+	if !shift.end.Equal(time.Date(2026, 3, 5, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("shift ends %v, want 07:00 next day", shift.end)
+	}
+}
+
+func TestShiftsBetween_DailyAcrossDST(t *testing.T) {
+	r := RosterResponse{
+		Timezone:        "Europe/Berlin",
+		HandoffTime:     "09:00",
+		RotationUnit:    RotationDays,
+		RotationLengths: []int{1},
+	}
+	loc, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		t.Skip("timezone data unavailable")
+	}
+
+	// Clocks go forward on 2026-03-29; handoff stays at 09:00 local.
+	from := time.Date(2026, 3, 28, 12, 0, 0, 0, loc)
+	shifts := shiftsBetween(r, from, from.AddDate(0, 0, 2))
+
+	for _, s := range shifts {
+		if h := s.start.In(loc).Hour(); h != 9 {
+			t.Errorf("shift starts at %02d:00 local, want 09:00", h)
+		}
+	}
+	if got := shifts[0].end.Sub(shifts[0].start); got != 23*time.Hour {
+		t.Errorf("expected 23h shift over DST change, got %v", got)
+	}
+}
+
+func TestValidateRotation(t *testing.T) {
+	tests := []struct {
+		unit    string
+		lengths []int
+		wantErr bool
+	}{
+		{RotationWeeks, []int{1}, false},
+		{RotationDays, []int{4, 3}, false},
+		{RotationHours, []int{12}, false},
+		{"months", []int{1}, true},
+		{RotationDays, nil, true},
+		{RotationDays, []int{0}, true},
+	}
+	for _, tt := range tests {
+		err := validateRotation(tt.unit, tt.lengths)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateRotation(%q, %v) error = %v, wantErr %v", tt.unit, tt.lengths, err, tt.wantErr)
+		}
+	}
+}
@@ -8,8 +8,11 @@ import (
 	"github.com/google/uuid"
 )
 
-// GenerateSchedule creates schedule entries for the given roster.
-// It respects locked weeks and distributes primary/secondary duty fairly.
+// GenerateSchedule creates schedule entries for the given roster, covering
+// the rotation shifts that overlap [from, from + weeks). Shift boundaries
+// follow the roster's rotation unit and lengths. It respects locked shifts,
+// skips members with time off during a shift, and distributes
+// primary/secondary duty fairly by time served.
 func (s *Service) GenerateSchedule(ctx context.Context, rosterID uuid.UUID, from time.Time, weeks int) ([]ScheduleEntry, error) {
 	roster, err := s.store.GetRoster(ctx, rosterID)
 	if err != nil {
@@ -26,61 +29,83 @@ func (s *Service) GenerateSchedule(ctx context.Context, rosterID uuid.UUID, from
 		return []ScheduleEntry{}, nil
 	}
 
-	// Align 'from' to the roster's handoff day.
-	weekStart := alignToHandoffDay(from, roster.HandoffDay)
+	loc, err := time.LoadLocation(roster.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	slots := shiftsBetween(roster, from, from.AddDate(0, 0, weeks*7))
+	if len(slots) == 0 {
+		return []ScheduleEntry{}, nil
+	}
+	windowStart, windowEnd := slots[0].start, slots[len(slots)-1].end
 
-	// Build duty counts from schedule entries BEFORE the generation window only.
+	// Build duty minutes from schedule entries BEFORE the generation window only.
 	// This avoids inflated counts from rows we're about to overwrite.
 	primaryCount := make(map[uuid.UUID]int)
 	secondaryCount := make(map[uuid.UUID]int)
 	for _, m := range members {
-		pc, _ := s.store.CountPrimaryWeeksBefore(ctx, rosterID, m.UserID, weekStart)
+		pc, sc, _ := s.store.DutyMinutes(ctx, rosterID, m.UserID, &windowStart)
 		primaryCount[m.UserID] = pc
-		sc, _ := s.store.CountSecondaryWeeksBefore(ctx, rosterID, m.UserID, weekStart)
 		secondaryCount[m.UserID] = sc
 	}
 
-	// Load existing schedule for the range so we can skip locked weeks.
-	endDate := weekStart.AddDate(0, 0, weeks*7)
-	existing, err := s.store.ListSchedule(ctx, rosterID, weekStart, endDate)
+	// Load existing schedule for the range so we can skip locked shifts.
+	existing, err := s.store.ListSchedule(ctx, rosterID, windowStart, windowEnd)
 	if err != nil {
 		return nil, fmt.Errorf("listing existing schedule: %w", err)
 	}
-	existingMap := make(map[string]*ScheduleEntry, len(existing))
-	for i := range existing {
-		existingMap[existing[i].WeekStart] = &existing[i]
+	periods, err := s.memberPeriods(ctx, members, windowStart, windowEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	var locked []ScheduleEntry
+	for _, e := range existing {
+		if e.IsLocked {
+			locked = append(locked, e)
+		}
+	}
+
+	// Drop unlocked shifts whose boundaries no longer match the rotation,
+	// e.g. after switching a roster from weekly to daily shifts.
+	keep := make([]time.Time, len(slots))
+	for i, slot := range slots {
+		keep[i] = slot.start
+	}
+	if err := s.store.DeleteUnlockedShiftsExcept(ctx, rosterID, windowStart, windowEnd, keep); err != nil {
+		return nil, err
 	}
 
 	// Track consecutive primary assignments for max_consecutive enforcement.
 	var lastPrimary *uuid.UUID
 	consecutiveCount := 0
 
-	// Look back at the week before 'from' to seed consecutive tracking.
-	prevWeek := weekStart.AddDate(0, 0, -7)
-	prevEntry, _ := s.store.GetScheduleWeek(ctx, rosterID, prevWeek)
-	if prevEntry != nil && prevEntry.PrimaryUserID != nil {
-		lastPrimary = prevEntry.PrimaryUserID
-		consecutiveCount = 1
-		// Count further back.
-		for i := 2; i <= roster.MaxConsecutiveWeeks; i++ {
-			pw := weekStart.AddDate(0, 0, -7*i)
-			pe, _ := s.store.GetScheduleWeek(ctx, rosterID, pw)
-			if pe != nil && pe.PrimaryUserID != nil && *pe.PrimaryUserID == *lastPrimary {
-				consecutiveCount++
-			} else {
+	// Look back at the shifts before the window to seed consecutive tracking.
+	if roster.MaxConsecutiveWeeks > 0 {
+		recent, _ := s.store.ListRecentShifts(ctx, rosterID, windowStart, roster.MaxConsecutiveWeeks)
+		for _, pe := range recent {
+			if pe.PrimaryUserID == nil {
+				break
+			}
+			if lastPrimary == nil {
+				lastPrimary = pe.PrimaryUserID
+			} else if *pe.PrimaryUserID != *lastPrimary {
 				break
 			}
+			consecutiveCount++
 		}
 	}
 
 	var generated []ScheduleEntry
-	for i := 0; i < weeks; i++ {
-		ws := weekStart.AddDate(0, 0, i*7)
-		we := ws.AddDate(0, 0, 7)
-		wsKey := ws.Format("2006-01-02")
-
-		// Skip locked weeks — but track them for consecutive counting.
-		if e, ok := existingMap[wsKey]; ok && e.IsLocked {
+	emitted := make(map[uuid.UUID]bool)
+	for _, slot := range slots {
+		// Skip slots covered by a locked shift — but track them for consecutive counting.
+		if e := lockedShiftOverlapping(locked, slot); e != nil {
+			if emitted[e.ID] {
+				continue
+			}
+			emitted[e.ID] = true
 			if e.PrimaryUserID != nil {
 				if lastPrimary != nil && *lastPrimary == *e.PrimaryUserID {
 					consecutiveCount++
@@ -93,23 +118,29 @@ func (s *Service) GenerateSchedule(ctx context.Context, rosterID uuid.UUID, from
 			continue
 		}
 
-		// Pick primary: least served, not exceeding max consecutive.
-		primary := pickPrimary(members, primaryCount, lastPrimary, consecutiveCount, roster.MaxConsecutiveWeeks)
+		// Pick primary: least served among those available, not exceeding max consecutive.
+		candidates := availableMembers(members, periods, slot)
+		if len(candidates) < len(members) {
+			s.logger.Debug("skipping unavailable members", "roster_id", rosterID,
+				"shift_start", slot.start, "skipped", len(members)-len(candidates))
+		}
+		primary := pickPrimary(candidates, primaryCount, lastPrimary, consecutiveCount, roster.MaxConsecutiveWeeks)
 		var secondary *uuid.UUID
-		if len(members) > 1 && primary != nil {
-			secondary = pickSecondary(members, primaryCount, secondaryCount, *primary)
+		if primary != nil {
+			secondary = pickSecondary(candidates, primaryCount, secondaryCount, *primary)
 		}
 
-		entry, err := s.store.UpsertScheduleWeek(ctx, rosterID, ws, we,
+		entry, err := s.store.UpsertShift(ctx, rosterID, slot.start, slot.end, loc,
 			primary, secondary, false, true, nil)
 		if err != nil {
-			return nil, fmt.Errorf("upserting schedule week %s: %w", wsKey, err)
+			return nil, fmt.Errorf("upserting schedule shift %s: %w", slot.start.Format(time.RFC3339), err)
 		}
 		generated = append(generated, *entry)
 
 		// Update tracking.
+		minutes := int(slot.end.Sub(slot.start).Minutes())
 		if primary != nil {
-			primaryCount[*primary]++
+			primaryCount[*primary] += minutes
 			if lastPrimary != nil && *lastPrimary == *primary {
 				consecutiveCount++
 			} else {
@@ -118,13 +149,23 @@ func (s *Service) GenerateSchedule(ctx context.Context, rosterID uuid.UUID, from
 			}
 		}
 		if secondary != nil {
-			secondaryCount[*secondary]++
+			secondaryCount[*secondary] += minutes
 		}
 	}
 
 	return generated, nil
 }
 
+// lockedShiftOverlapping returns the locked shift overlapping the slot, if any.
+func lockedShiftOverlapping(locked []ScheduleEntry, slot shiftWindow) *ScheduleEntry {
+	for i := range locked {
+		if locked[i].ShiftStart.Before(slot.end) && slot.start.Before(locked[i].ShiftEnd) {
+			return &locked[i]
+		}
+	}
+	return nil
+}
+
 // alignToHandoffDay finds the most recent handoff day on or before the given date.
 // handoffDay: 0=Sunday, 1=Monday, ..., 6=Saturday.
 func alignToHandoffDay(t time.Time, handoffDay int) time.Time {
@@ -138,8 +179,8 @@ func alignToHandoffDay(t time.Time, handoffDay int) time.Time {
 	return d.AddDate(0, 0, -diff)
 }
 
-// pickPrimary selects the member with the fewest primary weeks served,
-// respecting max consecutive weeks constraint.
+// pickPrimary selects the member with the least primary duty served,
+// respecting the max consecutive shifts constraint.
 func pickPrimary(members []MemberResponse, primaryCount map[uuid.UUID]int,
 	lastPrimary *uuid.UUID, consecutiveCount, maxConsecutive int) *uuid.UUID {
 
@@ -175,8 +216,8 @@ func pickPrimary(members []MemberResponse, primaryCount map[uuid.UUID]int,
 	return best
 }
 
-// pickSecondary selects the member with the fewest total duty weeks (primary + secondary),
-// excluding the current week's primary.
+// pickSecondary selects the member with the least total duty (primary + secondary),
+// excluding the current shift's primary.
 func pickSecondary(members []MemberResponse, primaryCount, secondaryCount map[uuid.UUID]int, primaryUID uuid.UUID) *uuid.UUID {
 	var best *uuid.UUID
 	bestTotal := -1
@@ -2,27 +2,32 @@ package roster
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 
 	"github.com/wisbric/nightowl/internal/db"
+	"github.com/wisbric/nightowl/pkg/timeoff"
 )
 
 // Service encapsulates roster business logic.
 type Service struct {
-	store  *Store
-	logger *slog.Logger
+	store   *Store
+	timeoff *timeoff.Store
+	logger  *slog.Logger
 }
 
 // NewService creates a roster Service backed by the given database connection.
 func NewService(dbtx db.DBTX, logger *slog.Logger) *Service {
 	return &Service{
-		store:  NewStore(dbtx),
-		logger: logger,
+		store:   NewStore(dbtx),
+		timeoff: timeoff.NewStore(dbtx),
+		logger:  logger,
 	}
 }
 
@@ -41,7 +46,37 @@ func (s *Service) ListRosters(ctx context.Context) ([]RosterResponse, error) {
 }
 
 func (s *Service) UpdateRoster(ctx context.Context, id uuid.UUID, req UpdateRosterRequest) (RosterResponse, error) {
-	return s.store.UpdateRoster(ctx, id, req)
+	before, err := s.store.GetRoster(ctx, id)
+	if err != nil {
+		return RosterResponse{}, err
+	}
+	updated, err := s.store.UpdateRoster(ctx, id, req)
+	if err != nil {
+		return RosterResponse{}, err
+	}
+	// Shift boundaries moved: rebuild unlocked future shifts on the new rotation.
+	if rotationChanged(before, updated) {
+		if _, err := s.GenerateSchedule(ctx, id, time.Now(), updated.ScheduleWeeksAhead); err != nil {
+			s.logger.Error("regenerating schedule after rotation change", "error", err, "roster_id", id)
+		}
+	}
+	return updated, nil
+}
+
+// rotationChanged reports whether two versions of a roster place shift
+// boundaries differently.
+func rotationChanged(a, b RosterResponse) bool {
+	if a.RotationUnit != b.RotationUnit || a.Timezone != b.Timezone ||
+		a.HandoffTime != b.HandoffTime || a.HandoffDay != b.HandoffDay ||
+		len(a.RotationLengths) != len(b.RotationLengths) {
+		return true
+	}
+	for i := range a.RotationLengths {
+		if a.RotationLengths[i] != b.RotationLengths[i] {
+			return true
+		}
+	}
+	return false
 }
 
 func (s *Service) DeleteRoster(ctx context.Context, id uuid.UUID) error {
@@ -55,12 +90,15 @@ func (s *Service) ListMembers(ctx context.Context, rosterID uuid.UUID) ([]Member
 	if err != nil {
 		return nil, err
 	}
-	// Enrich with primary and secondary weeks served.
+	// Enrich with primary and secondary shifts and hours served.
 	for i := range members {
 		pCount, _ := s.store.CountPrimaryWeeks(ctx, rosterID, members[i].UserID)
 		members[i].PrimaryWeeksServed = pCount
 		sCount, _ := s.store.CountSecondaryWeeks(ctx, rosterID, members[i].UserID)
 		members[i].SecondaryWeeksServed = sCount
+		pMin, sMin, _ := s.store.DutyMinutes(ctx, rosterID, members[i].UserID, nil)
+		members[i].PrimaryHoursServed = float64(pMin) / 60
+		members[i].SecondaryHoursServed = float64(sMin) / 60
 	}
 	return members, nil
 }
@@ -99,7 +137,7 @@ func (s *Service) SetMemberActive(ctx context.Context, rosterID, userID uuid.UUI
 	return nil
 }
 
-// regenerateFuture regenerates unlocked future schedule weeks.
+// regenerateFuture regenerates unlocked future schedule shifts.
 func (s *Service) regenerateFuture(ctx context.Context, rosterID uuid.UUID) error {
 	roster, err := s.store.GetRoster(ctx, rosterID)
 	if err != nil {
@@ -111,23 +149,112 @@ func (s *Service) regenerateFuture(ctx context.Context, rosterID uuid.UUID) erro
 
 // --- Schedule ---
 
+// GetSchedule lists the shifts in [from, to), each annotated with any
+// conflicts between its assignees and their time off.
 func (s *Service) GetSchedule(ctx context.Context, rosterID uuid.UUID, from, to time.Time) ([]ScheduleEntry, error) {
-	return s.store.ListSchedule(ctx, rosterID, from, to)
+	entries, err := s.store.ListSchedule(ctx, rosterID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.annotateConflicts(ctx, entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ErrInvalidShiftKey is returned when a schedule path key is neither a
+// YYYY-MM-DD date nor an RFC3339 instant.
+var ErrInvalidShiftKey = errors.New("invalid shift key (use YYYY-MM-DD or RFC3339)")
+
+// shiftInstant resolves a schedule path key to an instant inside the shift it
+// names. A date names the shift running at that day's handoff time, so the
+// weekly URLs from before custom rotations keep working.
+func shiftInstant(roster RosterResponse, key string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, key); err == nil {
+		return t, nil
+	}
+	d, err := time.Parse("2006-01-02", key)
+	if err != nil {
+		return time.Time{}, ErrInvalidShiftKey
+	}
+	loc, err := time.LoadLocation(roster.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	handoff, err := time.Parse("15:04", roster.HandoffTime)
+	if err != nil {
+		handoff, _ = time.Parse("15:04", "09:00")
+	}
+	return time.Date(d.Year(), d.Month(), d.Day(), handoff.Hour(), handoff.Minute(), 0, 0, loc), nil
 }
 
-func (s *Service) GetScheduleWeek(ctx context.Context, rosterID uuid.UUID, weekStart time.Time) (*ScheduleEntry, error) {
-	return s.store.GetScheduleWeek(ctx, rosterID, weekStart)
+// GetScheduleWeek returns the schedule shift named by key, or nil if none.
+func (s *Service) GetScheduleWeek(ctx context.Context, rosterID uuid.UUID, key string) (*ScheduleEntry, error) {
+	roster, err := s.store.GetRoster(ctx, rosterID)
+	if err != nil {
+		return nil, err
+	}
+	at, err := shiftInstant(roster, key)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.GetScheduleForTime(ctx, rosterID, at)
 }
 
-func (s *Service) UpdateScheduleWeek(ctx context.Context, rosterID uuid.UUID, weekStart time.Time, req UpdateScheduleWeekRequest) (*ScheduleEntry, error) {
-	// Compute week_end.
-	weekEnd := weekStart.AddDate(0, 0, 7)
-	return s.store.UpsertScheduleWeek(ctx, rosterID, weekStart, weekEnd,
+// UpdateScheduleWeek assigns and locks the shift named by key. An existing
+// shift keeps its boundaries; otherwise the rotation slot is used.
+func (s *Service) UpdateScheduleWeek(ctx context.Context, rosterID uuid.UUID, key string, req UpdateScheduleWeekRequest) (*ScheduleEntry, error) {
+	roster, err := s.store.GetRoster(ctx, rosterID)
+	if err != nil {
+		return nil, err
+	}
+	at, err := shiftInstant(roster, key)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(roster.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	slot := shiftAt(roster, at)
+	if existing, err := s.store.GetScheduleForTime(ctx, rosterID, at); err != nil {
+		return nil, err
+	} else if existing != nil {
+		slot = shiftWindow{start: existing.ShiftStart, end: existing.ShiftEnd}
+	}
+	return s.store.UpsertShift(ctx, rosterID, slot.start, slot.end, loc,
 		req.PrimaryUserID, req.SecondaryUserID, true, false, req.Notes)
 }
 
-func (s *Service) UnlockScheduleWeek(ctx context.Context, rosterID uuid.UUID, weekStart time.Time) error {
-	return s.store.UnlockScheduleWeek(ctx, rosterID, weekStart)
+// UnlockScheduleWeek unlocks the shift named by key.
+func (s *Service) UnlockScheduleWeek(ctx context.Context, rosterID uuid.UUID, key string) error {
+	entry, err := s.GetScheduleWeek(ctx, rosterID, key)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return pgx.ErrNoRows
+	}
+	return s.store.UnlockShift(ctx, rosterID, entry.ID)
+}
+
+// --- Layers ---
+
+func (s *Service) ListLayers(ctx context.Context, rosterID uuid.UUID) ([]LayerResponse, error) {
+	return s.store.ListLayers(ctx, rosterID)
+}
+
+func (s *Service) CreateLayer(ctx context.Context, rosterID uuid.UUID, req LayerRequest) (LayerResponse, error) {
+	return s.store.CreateLayer(ctx, rosterID, req)
+}
+
+func (s *Service) UpdateLayer(ctx context.Context, rosterID, layerID uuid.UUID, req LayerRequest) (LayerResponse, error) {
+	return s.store.UpdateLayer(ctx, rosterID, layerID, req)
+}
+
+func (s *Service) DeleteLayer(ctx context.Context, rosterID, layerID uuid.UUID) error {
+	return s.store.DeleteLayer(ctx, rosterID, layerID)
 }
 
 // --- Overrides ---
@@ -157,18 +284,104 @@ func (s *Service) DeleteOverride(ctx context.Context, overrideID uuid.UUID) erro
 
 // --- On-call resolution: override → schedule → unassigned ---
 
+// GetOnCall resolves who is on-call for a roster at the given instant.
+// For a follow-the-sun roster the answer comes from whichever half of the
+// linked pair is inside its active hours at that instant.
 func (s *Service) GetOnCall(ctx context.Context, rosterID uuid.UUID, at time.Time) (*OnCallResponse, error) {
 	roster, err := s.store.GetRoster(ctx, rosterID)
 	if err != nil {
 		return nil, fmt.Errorf("getting roster: %w", err)
 	}
 
-	// Always resolve from this roster's own schedule.
-	// Follow-the-sun delegation is handled by the escalation engine,
-	// not the per-roster on-call endpoint.
-	return s.resolveOnCall(ctx, roster, at)
+	active, err := s.followTheSunRoster(ctx, roster, at)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.resolveOnCall(ctx, active, at)
+	if err != nil {
+		return nil, err
+	}
+	if active.ID != roster.ID {
+		resp.DelegatedFrom = &roster.ID
+	}
+	return resp, nil
 }
 
+// GetOnCallForPolicy resolves the on-call responders of every active roster
+// bound to the given escalation policy. A roster that fails to resolve does
+// not keep the others from answering: their answers are returned together
+// with the failures.
+func (s *Service) GetOnCallForPolicy(ctx context.Context, policyID uuid.UUID, at time.Time) ([]*OnCallResponse, error) {
+	rosters, err := s.store.ListRostersByEscalationPolicy(ctx, policyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*OnCallResponse
+	var errs []error
+	for _, r := range rosters {
+		if !r.IsActive {
+			continue
+		}
+		resp, err := s.GetOnCall(ctx, r.ID, at)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("roster %s: %w", r.Name, err))
+			continue
+		}
+		result = append(result, resp)
+	}
+	return result, errors.Join(errs...)
+}
+
+// followTheSunRoster returns the roster that answers for the given roster at
+// the given instant. A follow-the-sun roster outside its active hours hands
+// over to a linked partner that is inside its own active hours.
+func (s *Service) followTheSunRoster(ctx context.Context, roster RosterResponse, at time.Time) (RosterResponse, error) {
+	if !roster.IsFollowTheSun || s.isInActiveHours(roster, at) {
+		return roster, nil
+	}
+
+	var partners []RosterResponse
+	if roster.LinkedRosterID != nil {
+		linked, err := s.store.GetRoster(ctx, *roster.LinkedRosterID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return RosterResponse{}, fmt.Errorf("getting linked roster: %w", err)
+		}
+		if err == nil {
+			partners = append(partners, linked)
+		}
+	}
+	linkedTo, err := s.store.ListRostersLinkedTo(ctx, roster.ID)
+	if err != nil {
+		return RosterResponse{}, err
+	}
+	partners = append(partners, linkedTo...)
+
+	return s.pickFollowTheSun(roster, partners, at), nil
+}
+
+// pickFollowTheSun chooses between a follow-the-sun roster and its linked
+// partners. The roster keeps ownership when no partner is active, so an
+// uncovered gap still resolves to someone rather than nobody.
+func (s *Service) pickFollowTheSun(roster RosterResponse, partners []RosterResponse, at time.Time) RosterResponse {
+	if s.isInActiveHours(roster, at) {
+		return roster
+	}
+	for _, p := range partners {
+		if p.ID == roster.ID || !p.IsActive {
+			continue
+		}
+		if s.isInActiveHours(p, at) {
+			return p
+		}
+	}
+	return roster
+}
+
+// resolveOnCall resolves on-call in order override → layers → schedule →
+// unassigned. A roster with layers is answered by its layers alone; time no
+// layer covers is unassigned rather than falling back to the schedule.
 func (s *Service) resolveOnCall(ctx context.Context, roster RosterResponse, at time.Time) (*OnCallResponse, error) {
 	resp := &OnCallResponse{
 		RosterID:   roster.ID,
@@ -176,6 +389,17 @@ func (s *Service) resolveOnCall(ctx context.Context, roster RosterResponse, at t
 		QueriedAt:  at,
 	}
 
+	layers, err := s.store.ListLayers(ctx, roster.ID)
+	if err != nil {
+		return nil, fmt.Errorf("listing layers: %w", err)
+	}
+	var periods []timeoff.Period
+	if len(layers) > 0 {
+		if periods, err = s.layerPeriods(ctx, roster, layers, at, at); err != nil {
+			return nil, err
+		}
+	}
+
 	// 1. Check for active override.
 	override, err := s.store.GetActiveOverride(ctx, roster.ID, at)
 	if err != nil {
@@ -190,10 +414,18 @@ func (s *Service) resolveOnCall(ctx context.Context, roster RosterResponse, at t
 		}
 		resp.ActiveOverride = override
 
-		// Still look up scheduled secondary.
+		// Still look up the scheduled secondary.
+		if len(layers) > 0 {
+			if m := pickLayer(roster, layers, periods, at); m != nil {
+				resp.Secondary = m.secondary
+			}
+			return resp, nil
+		}
 		sched, _ := s.store.GetScheduleForTime(ctx, roster.ID, at)
 		if sched != nil {
 			resp.WeekStart = &sched.WeekStart
+			resp.ShiftStart = &sched.ShiftStart
+			resp.ShiftEnd = &sched.ShiftEnd
 			if sched.SecondaryUserID != nil {
 				resp.Secondary = &OnCallEntry{
 					UserID:      *sched.SecondaryUserID,
@@ -204,7 +436,24 @@ func (s *Service) resolveOnCall(ctx context.Context, roster RosterResponse, at t
 		return resp, nil
 	}
 
-	// 2. Check schedule for current time.
+	// 2. Layered rosters resolve through their highest-priority matching layer.
+	if len(layers) > 0 {
+		m := pickLayer(roster, layers, periods, at)
+		if m == nil {
+			resp.Source = "unassigned"
+			return resp, nil
+		}
+		resp.Source = "layer"
+		resp.LayerID = &m.layer.ID
+		resp.LayerName = m.layer.Name
+		resp.Primary = m.primary
+		resp.Secondary = m.secondary
+		resp.ShiftStart = &m.start
+		resp.ShiftEnd = &m.end
+		return resp, nil
+	}
+
+	// 3. Check schedule for current time.
 	sched, err := s.store.GetScheduleForTime(ctx, roster.ID, at)
 	if err != nil {
 		return nil, fmt.Errorf("getting schedule: %w", err)
@@ -213,6 +462,8 @@ func (s *Service) resolveOnCall(ctx context.Context, roster RosterResponse, at t
 	if sched != nil {
 		resp.Source = "schedule"
 		resp.WeekStart = &sched.WeekStart
+		resp.ShiftStart = &sched.ShiftStart
+		resp.ShiftEnd = &sched.ShiftEnd
 		if sched.PrimaryUserID != nil {
 			resp.Primary = &OnCallEntry{
 				UserID:      *sched.PrimaryUserID,
@@ -228,7 +479,7 @@ func (s *Service) resolveOnCall(ctx context.Context, roster RosterResponse, at t
 		return resp, nil
 	}
 
-	// 3. Unassigned.
+	// 4. Unassigned.
 	resp.Source = "unassigned"
 	return resp, nil
 }
@@ -276,21 +527,22 @@ func (s *Service) GetCoverage(ctx context.Context, req CoverageRequest) (*Covera
 		return resp, nil
 	}
 
-	// Pre-fetch schedule and override data per roster.
+	// Pre-fetch schedule, layer and override data per roster.
 	cache := make(map[uuid.UUID]*coverageCache)
 	for _, r := range activeRosters {
-		sched, _ := s.store.ListSchedule(ctx, r.ID, req.From.AddDate(0, 0, -7), req.To)
+		sched, _ := s.store.ListSchedule(ctx, r.ID, req.From, req.To)
 		overrides, _ := s.store.ListOverridesInRange(ctx, r.ID, req.From, req.To)
-		cache[r.ID] = &coverageCache{schedule: sched, overrides: overrides}
+		layers, _ := s.store.ListLayers(ctx, r.ID)
+		periods, _ := s.layerPeriods(ctx, r, layers, req.From, req.To)
+		cache[r.ID] = &coverageCache{schedule: sched, overrides: overrides, layers: layers, periods: periods}
 	}
 
 	// Generate time slots.
-	var totalGapMinutes int
-	var gaps []GapInfo
-	var inGap bool
-	var gapStart time.Time
+	step := time.Duration(resolution) * time.Minute
+	var overall gapTracker
+	layerTrackers := make(map[uuid.UUID]*gapTracker)
 
-	for t := req.From; t.Before(req.To); t = t.Add(time.Duration(resolution) * time.Minute) {
+	for t := req.From; t.Before(req.To); t = t.Add(step) {
 		slot := CoverageSlot{Time: t}
 
 		for _, r := range activeRosters {
@@ -299,14 +551,21 @@ func (s *Service) GetCoverage(ctx context.Context, req CoverageRequest) (*Covera
 			}
 
 			rc := cache[r.ID]
-			primary, secondary, source := s.resolveFromCache(rc, r.ID, t)
-			slot.Coverage = append(slot.Coverage, CoverageSlotRoster{
-				RosterID:   r.ID,
-				RosterName: r.Name,
-				Primary:    primary,
-				Secondary:  secondary,
-				Source:     source,
-			})
+			c, covered := resolveFromCache(rc, r, t)
+			if len(rc.layers) > 0 {
+				lt := layerTrackers[r.ID]
+				if lt == nil {
+					lt = &gapTracker{}
+					layerTrackers[r.ID] = lt
+				}
+				lt.observe(t, !covered)
+			}
+			if !covered {
+				continue
+			}
+			c.RosterID = r.ID
+			c.RosterName = r.Name
+			slot.Coverage = append(slot.Coverage, c)
 		}
 
 		slot.Gap = len(slot.Coverage) == 0
@@ -315,44 +574,74 @@ func (s *Service) GetCoverage(ctx context.Context, req CoverageRequest) (*Covera
 		}
 		resp.Slots = append(resp.Slots, slot)
 
-		// Track gaps.
-		if slot.Gap {
-			if !inGap {
-				inGap = true
-				gapStart = t
-			}
-		} else if inGap {
-			inGap = false
-			gapEnd := t
-			dur := gapEnd.Sub(gapStart)
-			totalGapMinutes += int(dur.Minutes())
-			gaps = append(gaps, GapInfo{
-				Start:         gapStart,
-				End:           gapEnd,
-				DurationHours: dur.Hours(),
-			})
-		}
+		overall.observe(t, slot.Gap)
+	}
+	overall.finish(req.To)
+
+	resp.GapSummary = GapSummary{
+		TotalGapHours: float64(overall.minutes) / 60.0,
+		Gaps:          overall.gaps,
+		LayerGaps:     []LayerGaps{},
 	}
-	if inGap {
-		gapEnd := req.To
-		dur := gapEnd.Sub(gapStart)
-		totalGapMinutes += int(dur.Minutes())
-		gaps = append(gaps, GapInfo{
-			Start:         gapStart,
-			End:           gapEnd,
-			DurationHours: dur.Hours(),
+	for _, r := range activeRosters {
+		lt := layerTrackers[r.ID]
+		if lt == nil {
+			continue
+		}
+		lt.finish(req.To)
+		if len(lt.gaps) == 0 {
+			continue
+		}
+		resp.GapSummary.LayerGaps = append(resp.GapSummary.LayerGaps, LayerGaps{
+			RosterID:      r.ID,
+			RosterName:    r.Name,
+			TotalGapHours: float64(lt.minutes) / 60.0,
+			Gaps:          lt.gaps,
 		})
 	}
 
-	if gaps == nil {
-		gaps = []GapInfo{}
+	return resp, nil
+}
+
+// gapTracker folds a sequence of coverage slots into gap intervals.
+type gapTracker struct {
+	inGap   bool
+	start   time.Time
+	minutes int
+	gaps    []GapInfo
+}
+
+// observe records whether the slot starting at t is a gap.
+func (g *gapTracker) observe(t time.Time, gap bool) {
+	if gap {
+		if !g.inGap {
+			g.inGap = true
+			g.start = t
+		}
+	} else if g.inGap {
+		g.close(t)
 	}
-	resp.GapSummary = GapSummary{
-		TotalGapHours: float64(totalGapMinutes) / 60.0,
-		Gaps:          gaps,
+}
+
+// finish closes an open gap at end and normalises the result.
+func (g *gapTracker) finish(end time.Time) {
+	if g.inGap {
+		g.close(end)
+	}
+	if g.gaps == nil {
+		g.gaps = []GapInfo{}
 	}
+}
 
-	return resp, nil
+func (g *gapTracker) close(end time.Time) {
+	g.inGap = false
+	dur := end.Sub(g.start)
+	g.minutes += int(dur.Minutes())
+	g.gaps = append(g.gaps, GapInfo{
+		Start:         g.start,
+		End:           end,
+		DurationHours: dur.Hours(),
+	})
 }
 
 // isRosterActiveAt checks if a roster should be providing coverage at a given time.
@@ -368,37 +657,48 @@ func (s *Service) isRosterActiveAt(r RosterResponse, at time.Time) bool {
 type coverageCache struct {
 	schedule  []ScheduleEntry
 	overrides []OverrideResponse
+	layers    []LayerResponse
+	periods   []timeoff.Period // time off of layer members
 }
 
-func (s *Service) resolveFromCache(rc *coverageCache, rosterID uuid.UUID, at time.Time) (primary, secondary, source string) {
+// resolveFromCache mirrors resolveOnCall over pre-fetched data. covered is
+// false when a layered roster has no override and no layer for the instant.
+func resolveFromCache(rc *coverageCache, roster RosterResponse, at time.Time) (c CoverageSlotRoster, covered bool) {
 	// Check overrides.
 	for _, o := range rc.overrides {
 		if !at.Before(o.StartAt) && at.Before(o.EndAt) {
-			return o.DisplayName, "", "override"
+			return CoverageSlotRoster{Primary: o.DisplayName, Source: "override"}, true
+		}
+	}
+
+	// Check layers.
+	if len(rc.layers) > 0 {
+		m := pickLayer(roster, rc.layers, rc.periods, at)
+		if m == nil {
+			return CoverageSlotRoster{Source: "unassigned"}, false
+		}
+		c = CoverageSlotRoster{Primary: m.primary.DisplayName, Source: "layer", Layer: m.layer.Name}
+		if m.secondary != nil {
+			c.Secondary = m.secondary.DisplayName
 		}
+		return c, true
 	}
 
 	// Check schedule.
 	for _, e := range rc.schedule {
-		ws, _ := time.Parse("2006-01-02", e.WeekStart)
-		we, _ := time.Parse("2006-01-02", e.WeekEnd)
-		if !at.Before(ws) && at.Before(we) {
-			p := ""
-			if e.PrimaryDisplayName != "" {
-				p = e.PrimaryDisplayName
+		if !at.Before(e.ShiftStart) && at.Before(e.ShiftEnd) {
+			if e.PrimaryDisplayName == "" {
+				return CoverageSlotRoster{Source: "unassigned"}, true
 			}
-			sec := ""
-			if e.SecondaryDisplayName != "" {
-				sec = e.SecondaryDisplayName
-			}
-			if p == "" {
-				return "", "", "unassigned"
-			}
-			return p, sec, "schedule"
+			return CoverageSlotRoster{
+				Primary:   e.PrimaryDisplayName,
+				Secondary: e.SecondaryDisplayName,
+				Source:    "schedule",
+			}, true
 		}
 	}
 
-	return "", "", "unassigned"
+	return CoverageSlotRoster{Source: "unassigned"}, true
 }
 
 func (s *Service) isInActiveHours(roster RosterResponse, at time.Time) bool {
@@ -432,6 +732,25 @@ func (s *Service) isInActiveHours(roster RosterResponse, at time.Time) bool {
 	return currentMin >= startMin || currentMin < endMin
 }
 
+// activeWindowMinutes returns a roster's daily active window as minutes since
+// local midnight. Without explicit active hours it falls back to the 12-hour
+// window starting at the handoff time, matching isInActiveHours.
+func activeWindowMinutes(roster RosterResponse) (start, end int) {
+	if roster.ActiveHoursStart != nil && roster.ActiveHoursEnd != nil {
+		s, errS := time.Parse("15:04", *roster.ActiveHoursStart)
+		e, errE := time.Parse("15:04", *roster.ActiveHoursEnd)
+		if errS == nil && errE == nil {
+			return s.Hour()*60 + s.Minute(), e.Hour()*60 + e.Minute()
+		}
+	}
+	handoff, err := time.Parse("15:04", roster.HandoffTime)
+	if err != nil {
+		handoff, _ = time.Parse("15:04", "09:00")
+	}
+	start = handoff.Hour()*60 + handoff.Minute()
+	return start, (start + 12*60) % (24 * 60)
+}
+
 func (s *Service) isInHandoffWindow(roster RosterResponse, at time.Time) bool {
 	loc, err := time.LoadLocation(roster.Timezone)
 	if err != nil {
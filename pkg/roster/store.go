@@ -24,10 +24,41 @@ func NewStore(dbtx db.DBTX) *Store {
 	return &Store{q: db.New(dbtx), dbtx: dbtx}
 }
 
+// inTx runs fn with a Store bound to a transaction on s's connection and
+// commits if fn succeeds. Pools, pooled connections and transactions (as a
+// savepoint) can all begin one.
+func (s *Store) inTx(ctx context.Context, fn func(tx *Store) error) error {
+	b, ok := s.dbtx.(interface {
+		Begin(context.Context) (pgx.Tx, error)
+	})
+	if !ok {
+		return errors.New("roster store connection cannot begin a transaction")
+	}
+	tx, err := b.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := fn(NewStore(tx)); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
 // =====================
 // Roster operations
 // =====================
 
+// rosterColumns is the column list scanned by scanRoster and scanRosterFromRows.
+const rosterColumns = `id, name, description, timezone, handoff_time, handoff_day,
+	           schedule_weeks_ahead, max_consecutive_weeks, rotation_unit, rotation_lengths,
+	           is_follow_the_sun, linked_roster_id, active_hours_start, active_hours_end,
+	           escalation_policy_id, end_date, is_active, created_at, updated_at`
+
 func (s *Store) CreateRoster(ctx context.Context, r CreateRosterRequest) (RosterResponse, error) {
 	handoffTime, err := parseHandoffTime(r.HandoffTime)
 	if err != nil {
@@ -51,39 +82,32 @@ func (s *Store) CreateRoster(ctx context.Context, r CreateRosterRequest) (Roster
 		}
 	}
 
+	unit, lengths := rotationOrDefault(r.RotationUnit, r.RotationLengths)
+
 	query := `INSERT INTO rosters (name, description, timezone, handoff_time, handoff_day,
 	           schedule_weeks_ahead, max_consecutive_weeks, is_follow_the_sun,
 	           linked_roster_id, active_hours_start, active_hours_end,
-	           escalation_policy_id, end_date, is_active)
-	          VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,true)
-	          RETURNING id, name, description, timezone, handoff_time, handoff_day,
-	           schedule_weeks_ahead, max_consecutive_weeks, is_follow_the_sun,
-	           linked_roster_id, active_hours_start, active_hours_end,
-	           escalation_policy_id, end_date, is_active, created_at, updated_at`
+	           escalation_policy_id, end_date, is_active, rotation_unit, rotation_lengths)
+	          VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,true,$14,$15)
+	          RETURNING ` + rosterColumns
 
 	return s.scanRoster(s.dbtx.QueryRow(ctx, query,
 		r.Name, r.Description, r.Timezone, handoffTime, r.HandoffDay,
 		weeksAhead, maxConsec, r.IsFollowTheSun,
 		uuidToPgtype(r.LinkedRosterID),
 		parseOptionalTime(r.ActiveHoursStart), parseOptionalTime(r.ActiveHoursEnd),
-		uuidToPgtype(r.EscalationPolicyID), endDate,
+		uuidToPgtype(r.EscalationPolicyID), endDate, unit, lengths,
 	))
 }
 
 func (s *Store) GetRoster(ctx context.Context, id uuid.UUID) (RosterResponse, error) {
-	query := `SELECT id, name, description, timezone, handoff_time, handoff_day,
-	           schedule_weeks_ahead, max_consecutive_weeks, is_follow_the_sun,
-	           linked_roster_id, active_hours_start, active_hours_end,
-	           escalation_policy_id, end_date, is_active, created_at, updated_at
+	query := `SELECT ` + rosterColumns + `
 	          FROM rosters WHERE id = $1`
 	return s.scanRoster(s.dbtx.QueryRow(ctx, query, id))
 }
 
 func (s *Store) ListRosters(ctx context.Context) ([]RosterResponse, error) {
-	query := `SELECT id, name, description, timezone, handoff_time, handoff_day,
-	           schedule_weeks_ahead, max_consecutive_weeks, is_follow_the_sun,
-	           linked_roster_id, active_hours_start, active_hours_end,
-	           escalation_policy_id, end_date, is_active, created_at, updated_at
+	query := `SELECT ` + rosterColumns + `
 	          FROM rosters ORDER BY created_at DESC`
 	rows, err := s.dbtx.Query(ctx, query)
 	if err != nil {
@@ -105,6 +129,36 @@ func (s *Store) ListRosters(ctx context.Context) ([]RosterResponse, error) {
 	return result, nil
 }
 
+// ListRostersLinkedTo lists rosters whose linked_roster_id points at the given roster.
+func (s *Store) ListRostersLinkedTo(ctx context.Context, id uuid.UUID) ([]RosterResponse, error) {
+	return s.listRostersWhere(ctx, "linked_roster_id = $1", id)
+}
+
+// ListRostersByEscalationPolicy lists rosters bound to the given escalation policy.
+func (s *Store) ListRostersByEscalationPolicy(ctx context.Context, policyID uuid.UUID) ([]RosterResponse, error) {
+	return s.listRostersWhere(ctx, "escalation_policy_id = $1", policyID)
+}
+
+func (s *Store) listRostersWhere(ctx context.Context, where string, args ...any) ([]RosterResponse, error) {
+	query := `SELECT ` + rosterColumns + `
+	          FROM rosters WHERE ` + where + ` ORDER BY name`
+	rows, err := s.dbtx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing rosters: %w", err)
+	}
+	defer rows.Close()
+
+	var result []RosterResponse
+	for rows.Next() {
+		r, err := s.scanRosterFromRows(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
 func (s *Store) UpdateRoster(ctx context.Context, id uuid.UUID, r UpdateRosterRequest) (RosterResponse, error) {
 	handoffTime, err := parseHandoffTime(r.HandoffTime)
 	if err != nil {
@@ -128,18 +182,17 @@ func (s *Store) UpdateRoster(ctx context.Context, id uuid.UUID, r UpdateRosterRe
 		}
 	}
 
+	unit, lengths := rotationOrDefault(r.RotationUnit, r.RotationLengths)
+
 	query := `UPDATE rosters SET name=$2, description=$3, timezone=$4, handoff_time=$5,
 	           handoff_day=$6, schedule_weeks_ahead=$7, max_consecutive_weeks=$8,
-	           end_date=$9, updated_at=now()
+	           end_date=$9, rotation_unit=$10, rotation_lengths=$11, updated_at=now()
 	          WHERE id=$1
-	          RETURNING id, name, description, timezone, handoff_time, handoff_day,
-	           schedule_weeks_ahead, max_consecutive_weeks, is_follow_the_sun,
-	           linked_roster_id, active_hours_start, active_hours_end,
-	           escalation_policy_id, end_date, is_active, created_at, updated_at`
+	          RETURNING ` + rosterColumns
 
 	return s.scanRoster(s.dbtx.QueryRow(ctx, query,
 		id, r.Name, r.Description, r.Timezone, handoffTime,
-		r.HandoffDay, weeksAhead, maxConsec, endDate,
+		r.HandoffDay, weeksAhead, maxConsec, endDate, unit, lengths,
 	))
 }
 
@@ -169,7 +222,7 @@ func (s *Store) scanRoster(row pgx.Row) (RosterResponse, error) {
 
 	err := row.Scan(
 		&r.ID, &r.Name, &r.Description, &r.Timezone, &handoffTime, &r.HandoffDay,
-		&r.ScheduleWeeksAhead, &r.MaxConsecutiveWeeks, &fts,
+		&r.ScheduleWeeksAhead, &r.MaxConsecutiveWeeks, &r.RotationUnit, &r.RotationLengths, &fts,
 		&linkedRosterID, &activeHoursStart, &activeHoursEnd,
 		&escalationPolicyID, &endDate, &r.IsActive, &r.CreatedAt, &r.UpdatedAt,
 	)
@@ -204,7 +257,7 @@ func (s *Store) scanRosterFromRows(rows pgx.Rows) (RosterResponse, error) {
 
 	err := rows.Scan(
 		&r.ID, &r.Name, &r.Description, &r.Timezone, &handoffTime, &r.HandoffDay,
-		&r.ScheduleWeeksAhead, &r.MaxConsecutiveWeeks, &fts,
+		&r.ScheduleWeeksAhead, &r.MaxConsecutiveWeeks, &r.RotationUnit, &r.RotationLengths, &fts,
 		&linkedRosterID, &activeHoursStart, &activeHoursEnd,
 		&escalationPolicyID, &endDate, &r.IsActive, &r.CreatedAt, &r.UpdatedAt,
 	)
@@ -337,17 +390,39 @@ func (s *Store) GetUserDisplayName(ctx context.Context, userID uuid.UUID) (strin
 // Schedule operations
 // =====================
 
-func (s *Store) ListSchedule(ctx context.Context, rosterID uuid.UUID, from, to time.Time) ([]ScheduleEntry, error) {
-	query := `SELECT rs.id, rs.roster_id, rs.week_start, rs.week_end,
+// scheduleColumns is the column list scanned by scanScheduleEntry.
+const scheduleColumns = `rs.id, rs.roster_id, rs.week_start, rs.week_end, rs.shift_start, rs.shift_end,
 	                 rs.primary_user_id, COALESCE(up.display_name, ''),
 	                 rs.secondary_user_id, COALESCE(us.display_name, ''),
 	                 rs.is_locked, rs.generated, rs.notes,
 	                 rs.created_at, rs.updated_at
 	          FROM roster_schedule rs
 	          LEFT JOIN users up ON up.id = rs.primary_user_id
-	          LEFT JOIN users us ON us.id = rs.secondary_user_id
-	          WHERE rs.roster_id = $1 AND rs.week_start >= $2 AND rs.week_start <= $3
-	          ORDER BY rs.week_start`
+	          LEFT JOIN users us ON us.id = rs.secondary_user_id`
+
+func scanScheduleEntry(row pgx.Row) (ScheduleEntry, error) {
+	var e ScheduleEntry
+	var ws, we time.Time
+	var primaryUID, secondaryUID pgtype.UUID
+	if err := row.Scan(&e.ID, &e.RosterID, &ws, &we, &e.ShiftStart, &e.ShiftEnd,
+		&primaryUID, &e.PrimaryDisplayName,
+		&secondaryUID, &e.SecondaryDisplayName,
+		&e.IsLocked, &e.Generated, &e.Notes,
+		&e.CreatedAt, &e.UpdatedAt); err != nil {
+		return ScheduleEntry{}, err
+	}
+	e.WeekStart = ws.Format("2006-01-02")
+	e.WeekEnd = we.Format("2006-01-02")
+	e.PrimaryUserID = pgtypeUUIDToPtr(primaryUID)
+	e.SecondaryUserID = pgtypeUUIDToPtr(secondaryUID)
+	return e, nil
+}
+
+// ListSchedule lists the shifts overlapping [from, to].
+func (s *Store) ListSchedule(ctx context.Context, rosterID uuid.UUID, from, to time.Time) ([]ScheduleEntry, error) {
+	query := `SELECT ` + scheduleColumns + `
+	          WHERE rs.roster_id = $1 AND rs.shift_end > $2 AND rs.shift_start <= $3
+	          ORDER BY rs.shift_start`
 	rows, err := s.dbtx.Query(ctx, query, rosterID, from, to)
 	if err != nil {
 		return nil, fmt.Errorf("listing schedule: %w", err)
@@ -356,20 +431,10 @@ func (s *Store) ListSchedule(ctx context.Context, rosterID uuid.UUID, from, to t
 
 	var result []ScheduleEntry
 	for rows.Next() {
-		var e ScheduleEntry
-		var weekStart, weekEnd time.Time
-		var primaryUID, secondaryUID pgtype.UUID
-		if err := rows.Scan(&e.ID, &e.RosterID, &weekStart, &weekEnd,
-			&primaryUID, &e.PrimaryDisplayName,
-			&secondaryUID, &e.SecondaryDisplayName,
-			&e.IsLocked, &e.Generated, &e.Notes,
-			&e.CreatedAt, &e.UpdatedAt); err != nil {
+		e, err := scanScheduleEntry(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scanning schedule row: %w", err)
 		}
-		e.WeekStart = weekStart.Format("2006-01-02")
-		e.WeekEnd = weekEnd.Format("2006-01-02")
-		e.PrimaryUserID = pgtypeUUIDToPtr(primaryUID)
-		e.SecondaryUserID = pgtypeUUIDToPtr(secondaryUID)
 		result = append(result, e)
 	}
 	if result == nil {
@@ -378,83 +443,57 @@ func (s *Store) ListSchedule(ctx context.Context, rosterID uuid.UUID, from, to t
 	return result, nil
 }
 
-func (s *Store) GetScheduleWeek(ctx context.Context, rosterID uuid.UUID, weekStart time.Time) (*ScheduleEntry, error) {
-	query := `SELECT rs.id, rs.roster_id, rs.week_start, rs.week_end,
-	                 rs.primary_user_id, COALESCE(up.display_name, ''),
-	                 rs.secondary_user_id, COALESCE(us.display_name, ''),
-	                 rs.is_locked, rs.generated, rs.notes,
-	                 rs.created_at, rs.updated_at
-	          FROM roster_schedule rs
-	          LEFT JOIN users up ON up.id = rs.primary_user_id
-	          LEFT JOIN users us ON us.id = rs.secondary_user_id
-	          WHERE rs.roster_id = $1 AND rs.week_start = $2`
-	var e ScheduleEntry
-	var ws, we time.Time
-	var primaryUID, secondaryUID pgtype.UUID
-	err := s.dbtx.QueryRow(ctx, query, rosterID, weekStart).Scan(
-		&e.ID, &e.RosterID, &ws, &we,
-		&primaryUID, &e.PrimaryDisplayName,
-		&secondaryUID, &e.SecondaryDisplayName,
-		&e.IsLocked, &e.Generated, &e.Notes,
-		&e.CreatedAt, &e.UpdatedAt,
-	)
+// ListRecentShifts lists up to limit shifts starting before the given instant, newest first.
+func (s *Store) ListRecentShifts(ctx context.Context, rosterID uuid.UUID, before time.Time, limit int) ([]ScheduleEntry, error) {
+	query := `SELECT ` + scheduleColumns + `
+	          WHERE rs.roster_id = $1 AND rs.shift_start < $2
+	          ORDER BY rs.shift_start DESC
+	          LIMIT $3`
+	rows, err := s.dbtx.Query(ctx, query, rosterID, before, limit)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, nil
+		return nil, fmt.Errorf("listing recent shifts: %w", err)
+	}
+	defer rows.Close()
+
+	var result []ScheduleEntry
+	for rows.Next() {
+		e, err := scanScheduleEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning schedule row: %w", err)
 		}
-		return nil, fmt.Errorf("getting schedule week: %w", err)
+		result = append(result, e)
 	}
-	e.WeekStart = ws.Format("2006-01-02")
-	e.WeekEnd = we.Format("2006-01-02")
-	e.PrimaryUserID = pgtypeUUIDToPtr(primaryUID)
-	e.SecondaryUserID = pgtypeUUIDToPtr(secondaryUID)
-	return &e, nil
+	return result, nil
 }
 
 // GetScheduleForTime finds the schedule entry covering a specific timestamp.
 func (s *Store) GetScheduleForTime(ctx context.Context, rosterID uuid.UUID, at time.Time) (*ScheduleEntry, error) {
-	query := `SELECT rs.id, rs.roster_id, rs.week_start, rs.week_end,
-	                 rs.primary_user_id, COALESCE(up.display_name, ''),
-	                 rs.secondary_user_id, COALESCE(us.display_name, ''),
-	                 rs.is_locked, rs.generated, rs.notes,
-	                 rs.created_at, rs.updated_at
-	          FROM roster_schedule rs
-	          LEFT JOIN users up ON up.id = rs.primary_user_id
-	          LEFT JOIN users us ON us.id = rs.secondary_user_id
-	          WHERE rs.roster_id = $1 AND rs.week_start <= $2 AND rs.week_end > $2`
-	var e ScheduleEntry
-	var ws, we time.Time
-	var primaryUID, secondaryUID pgtype.UUID
-	err := s.dbtx.QueryRow(ctx, query, rosterID, at).Scan(
-		&e.ID, &e.RosterID, &ws, &we,
-		&primaryUID, &e.PrimaryDisplayName,
-		&secondaryUID, &e.SecondaryDisplayName,
-		&e.IsLocked, &e.Generated, &e.Notes,
-		&e.CreatedAt, &e.UpdatedAt,
-	)
+	query := `SELECT ` + scheduleColumns + `
+	          WHERE rs.roster_id = $1 AND rs.shift_start <= $2 AND rs.shift_end > $2`
+	e, err := scanScheduleEntry(s.dbtx.QueryRow(ctx, query, rosterID, at))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("getting schedule for time: %w", err)
 	}
-	e.WeekStart = ws.Format("2006-01-02")
-	e.WeekEnd = we.Format("2006-01-02")
-	e.PrimaryUserID = pgtypeUUIDToPtr(primaryUID)
-	e.SecondaryUserID = pgtypeUUIDToPtr(secondaryUID)
 	return &e, nil
 }
 
-func (s *Store) UpsertScheduleWeek(ctx context.Context, rosterID uuid.UUID,
-	weekStart, weekEnd time.Time,
+// UpsertShift creates or replaces the shift starting at shiftStart. The
+// local week_start/week_end dates are derived in the given location.
+func (s *Store) UpsertShift(ctx context.Context, rosterID uuid.UUID,
+	shiftStart, shiftEnd time.Time, loc *time.Location,
 	primaryUID, secondaryUID *uuid.UUID,
 	isLocked, generated bool,
 	notes *string) (*ScheduleEntry, error) {
 
-	query := `INSERT INTO roster_schedule (roster_id, week_start, week_end,
+	query := `INSERT INTO roster_schedule (roster_id, week_start, week_end, shift_start, shift_end,
 	           primary_user_id, secondary_user_id, is_locked, generated, notes)
-	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-	          ON CONFLICT (roster_id, week_start) DO UPDATE SET
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	          ON CONFLICT (roster_id, shift_start) DO UPDATE SET
+	           week_end = EXCLUDED.week_end,
+	           shift_end = EXCLUDED.shift_end,
 	           primary_user_id = EXCLUDED.primary_user_id,
 	           secondary_user_id = EXCLUDED.secondary_user_id,
 	           is_locked = EXCLUDED.is_locked,
@@ -471,13 +510,17 @@ func (s *Store) UpsertScheduleWeek(ctx context.Context, rosterID uuid.UUID,
 		sUID = pgtype.UUID{Bytes: *secondaryUID, Valid: true}
 	}
 
+	weekStart := shiftStart.In(loc).Format("2006-01-02")
+	weekEnd := shiftEnd.In(loc).Format("2006-01-02")
+
 	var id uuid.UUID
 	var createdAt, updatedAt time.Time
 	err := s.dbtx.QueryRow(ctx, query,
-		rosterID, weekStart, weekEnd, pUID, sUID, isLocked, generated, notes,
+		rosterID, weekStart, weekEnd, shiftStart, shiftEnd,
+		pUID, sUID, isLocked, generated, notes,
 	).Scan(&id, &createdAt, &updatedAt)
 	if err != nil {
-		return nil, fmt.Errorf("upserting schedule week: %w", err)
+		return nil, fmt.Errorf("upserting schedule shift: %w", err)
 	}
 
 	// Resolve display names.
@@ -492,8 +535,10 @@ func (s *Store) UpsertScheduleWeek(ctx context.Context, rosterID uuid.UUID,
 	return &ScheduleEntry{
 		ID:                   id,
 		RosterID:             rosterID,
-		WeekStart:            weekStart.Format("2006-01-02"),
-		WeekEnd:              weekEnd.Format("2006-01-02"),
+		WeekStart:            weekStart,
+		WeekEnd:              weekEnd,
+		ShiftStart:           shiftStart,
+		ShiftEnd:             shiftEnd,
 		PrimaryUserID:        primaryUID,
 		PrimaryDisplayName:   pName,
 		SecondaryUserID:      secondaryUID,
@@ -506,12 +551,28 @@ func (s *Store) UpsertScheduleWeek(ctx context.Context, rosterID uuid.UUID,
 	}, nil
 }
 
-func (s *Store) UnlockScheduleWeek(ctx context.Context, rosterID uuid.UUID, weekStart time.Time) error {
+// DeleteUnlockedShiftsExcept removes unlocked shifts starting in [from, to)
+// whose start is not in keep. Regeneration uses it to drop shifts left over
+// from a previous rotation shape.
+func (s *Store) DeleteUnlockedShiftsExcept(ctx context.Context, rosterID uuid.UUID, from, to time.Time, keep []time.Time) error {
+	_, err := s.dbtx.Exec(ctx,
+		`DELETE FROM roster_schedule
+		 WHERE roster_id = $1 AND is_locked = false
+		   AND shift_start >= $2 AND shift_start < $3
+		   AND NOT (shift_start = ANY($4))`,
+		rosterID, from, to, keep)
+	if err != nil {
+		return fmt.Errorf("deleting stale shifts: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) UnlockShift(ctx context.Context, rosterID, shiftID uuid.UUID) error {
 	tag, err := s.dbtx.Exec(ctx,
-		`UPDATE roster_schedule SET is_locked = false, updated_at = now() WHERE roster_id = $1 AND week_start = $2`,
-		rosterID, weekStart)
+		`UPDATE roster_schedule SET is_locked = false, updated_at = now() WHERE roster_id = $1 AND id = $2`,
+		rosterID, shiftID)
 	if err != nil {
-		return fmt.Errorf("unlocking schedule week: %w", err)
+		return fmt.Errorf("unlocking schedule shift: %w", err)
 	}
 	if tag.RowsAffected() == 0 {
 		return pgx.ErrNoRows
@@ -519,7 +580,7 @@ func (s *Store) UnlockScheduleWeek(ctx context.Context, rosterID uuid.UUID, week
 	return nil
 }
 
-// CountPrimaryWeeks counts how many weeks a user has been primary for a roster.
+// CountPrimaryWeeks counts how many shifts a user has been primary for a roster.
 func (s *Store) CountPrimaryWeeks(ctx context.Context, rosterID, userID uuid.UUID) (int, error) {
 	var count int
 	err := s.dbtx.QueryRow(ctx,
@@ -528,7 +589,7 @@ func (s *Store) CountPrimaryWeeks(ctx context.Context, rosterID, userID uuid.UUI
 	return count, err
 }
 
-// CountSecondaryWeeks counts how many weeks a user has been secondary for a roster.
+// CountSecondaryWeeks counts how many shifts a user has been secondary for a roster.
 func (s *Store) CountSecondaryWeeks(ctx context.Context, rosterID, userID uuid.UUID) (int, error) {
 	var count int
 	err := s.dbtx.QueryRow(ctx,
@@ -537,22 +598,18 @@ func (s *Store) CountSecondaryWeeks(ctx context.Context, rosterID, userID uuid.U
 	return count, err
 }
 
-// CountPrimaryWeeksBefore counts primary weeks before a given date (for fair regeneration).
-func (s *Store) CountPrimaryWeeksBefore(ctx context.Context, rosterID, userID uuid.UUID, before time.Time) (int, error) {
-	var count int
-	err := s.dbtx.QueryRow(ctx,
-		`SELECT COUNT(*) FROM roster_schedule WHERE roster_id = $1 AND primary_user_id = $2 AND week_start < $3`,
-		rosterID, userID, before).Scan(&count)
-	return count, err
-}
-
-// CountSecondaryWeeksBefore counts secondary weeks before a given date (for fair regeneration).
-func (s *Store) CountSecondaryWeeksBefore(ctx context.Context, rosterID, userID uuid.UUID, before time.Time) (int, error) {
-	var count int
-	err := s.dbtx.QueryRow(ctx,
-		`SELECT COUNT(*) FROM roster_schedule WHERE roster_id = $1 AND secondary_user_id = $2 AND week_start < $3`,
-		rosterID, userID, before).Scan(&count)
-	return count, err
+// DutyMinutes sums the primary and secondary minutes a user is scheduled for
+// on a roster. When before is set only shifts starting before it count,
+// which keeps regeneration from counting the rows it is about to overwrite.
+func (s *Store) DutyMinutes(ctx context.Context, rosterID, userID uuid.UUID, before *time.Time) (primary, secondary int, err error) {
+	err = s.dbtx.QueryRow(ctx,
+		`SELECT
+		   COALESCE(SUM(EXTRACT(EPOCH FROM shift_end - shift_start) / 60) FILTER (WHERE primary_user_id = $2), 0)::int,
+		   COALESCE(SUM(EXTRACT(EPOCH FROM shift_end - shift_start) / 60) FILTER (WHERE secondary_user_id = $2), 0)::int
+		 FROM roster_schedule
+		 WHERE roster_id = $1 AND ($3::timestamptz IS NULL OR shift_start < $3)`,
+		rosterID, userID, before).Scan(&primary, &secondary)
+	return primary, secondary, err
 }
 
 // ListOverridesInRange lists overrides for a roster within a time range.
@@ -583,6 +640,171 @@ func (s *Store) ListOverridesInRange(ctx context.Context, rosterID uuid.UUID, fr
 	return result, nil
 }
 
+// =====================
+// Layer operations
+// =====================
+
+// ListLayers lists a roster's layers, highest priority first, with members in rotation order.
+func (s *Store) ListLayers(ctx context.Context, rosterID uuid.UUID) ([]LayerResponse, error) {
+	rows, err := s.dbtx.Query(ctx,
+		`SELECT id, roster_id, name, priority, rotation_unit, rotation_lengths,
+		        days_of_week, start_time, end_time, created_at, updated_at
+		 FROM roster_layers WHERE roster_id = $1
+		 ORDER BY priority DESC, created_at`, rosterID)
+	if err != nil {
+		return nil, fmt.Errorf("listing roster layers: %w", err)
+	}
+	defer rows.Close()
+
+	var result []LayerResponse
+	index := make(map[uuid.UUID]int)
+	for rows.Next() {
+		l, err := scanLayer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning layer row: %w", err)
+		}
+		index[l.ID] = len(result)
+		result = append(result, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	if result == nil {
+		return []LayerResponse{}, nil
+	}
+
+	mrows, err := s.dbtx.Query(ctx,
+		`SELECT lm.layer_id, lm.user_id, COALESCE(u.display_name, lm.user_id::text)
+		 FROM roster_layer_members lm
+		 JOIN roster_layers l ON l.id = lm.layer_id
+		 LEFT JOIN users u ON u.id = lm.user_id
+		 WHERE l.roster_id = $1
+		 ORDER BY lm.layer_id, lm.position`, rosterID)
+	if err != nil {
+		return nil, fmt.Errorf("listing layer members: %w", err)
+	}
+	defer mrows.Close()
+	for mrows.Next() {
+		var layerID uuid.UUID
+		var m OnCallEntry
+		if err := mrows.Scan(&layerID, &m.UserID, &m.DisplayName); err != nil {
+			return nil, fmt.Errorf("scanning layer member row: %w", err)
+		}
+		if i, ok := index[layerID]; ok {
+			result[i].Members = append(result[i].Members, m)
+		}
+	}
+	return result, mrows.Err()
+}
+
+// GetLayer returns a single layer with its members.
+func (s *Store) GetLayer(ctx context.Context, rosterID, layerID uuid.UUID) (LayerResponse, error) {
+	layers, err := s.ListLayers(ctx, rosterID)
+	if err != nil {
+		return LayerResponse{}, err
+	}
+	for _, l := range layers {
+		if l.ID == layerID {
+			return l, nil
+		}
+	}
+	return LayerResponse{}, pgx.ErrNoRows
+}
+
+func (s *Store) CreateLayer(ctx context.Context, rosterID uuid.UUID, req LayerRequest) (LayerResponse, error) {
+	unit, lengths := rotationOrDefault(req.RotationUnit, req.RotationLengths)
+	days := req.DaysOfWeek
+	if days == nil {
+		days = []int{}
+	}
+	var id uuid.UUID
+	err := s.dbtx.QueryRow(ctx,
+		`INSERT INTO roster_layers (roster_id, name, priority, rotation_unit, rotation_lengths,
+		   days_of_week, start_time, end_time)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 RETURNING id`,
+		rosterID, req.Name, req.Priority, unit, lengths, days,
+		parseOptionalTime(req.StartTime), parseOptionalTime(req.EndTime),
+	).Scan(&id)
+	if err != nil {
+		return LayerResponse{}, fmt.Errorf("creating roster layer: %w", err)
+	}
+	if err := s.setLayerMembers(ctx, id, req.UserIDs); err != nil {
+		return LayerResponse{}, err
+	}
+	return s.GetLayer(ctx, rosterID, id)
+}
+
+func (s *Store) UpdateLayer(ctx context.Context, rosterID, layerID uuid.UUID, req LayerRequest) (LayerResponse, error) {
+	unit, lengths := rotationOrDefault(req.RotationUnit, req.RotationLengths)
+	days := req.DaysOfWeek
+	if days == nil {
+		days = []int{}
+	}
+	tag, err := s.dbtx.Exec(ctx,
+		`UPDATE roster_layers SET name=$3, priority=$4, rotation_unit=$5, rotation_lengths=$6,
+		   days_of_week=$7, start_time=$8, end_time=$9, updated_at=now()
+		 WHERE roster_id=$1 AND id=$2`,
+		rosterID, layerID, req.Name, req.Priority, unit, lengths, days,
+		parseOptionalTime(req.StartTime), parseOptionalTime(req.EndTime))
+	if err != nil {
+		return LayerResponse{}, fmt.Errorf("updating roster layer: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return LayerResponse{}, pgx.ErrNoRows
+	}
+	if err := s.setLayerMembers(ctx, layerID, req.UserIDs); err != nil {
+		return LayerResponse{}, err
+	}
+	return s.GetLayer(ctx, rosterID, layerID)
+}
+
+func (s *Store) DeleteLayer(ctx context.Context, rosterID, layerID uuid.UUID) error {
+	tag, err := s.dbtx.Exec(ctx, `DELETE FROM roster_layers WHERE roster_id = $1 AND id = $2`, rosterID, layerID)
+	if err != nil {
+		return fmt.Errorf("deleting roster layer: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// setLayerMembers replaces a layer's rotation with the given users, in order.
+func (s *Store) setLayerMembers(ctx context.Context, layerID uuid.UUID, userIDs []uuid.UUID) error {
+	if _, err := s.dbtx.Exec(ctx, `DELETE FROM roster_layer_members WHERE layer_id = $1`, layerID); err != nil {
+		return fmt.Errorf("clearing layer members: %w", err)
+	}
+	for i, uid := range userIDs {
+		if _, err := s.dbtx.Exec(ctx,
+			`INSERT INTO roster_layer_members (layer_id, position, user_id) VALUES ($1, $2, $3)`,
+			layerID, i, uid); err != nil {
+			return fmt.Errorf("adding layer member: %w", err)
+		}
+	}
+	return nil
+}
+
+func scanLayer(row pgx.Row) (LayerResponse, error) {
+	var l LayerResponse
+	var start, end pgtype.Time
+	if err := row.Scan(&l.ID, &l.RosterID, &l.Name, &l.Priority, &l.RotationUnit, &l.RotationLengths,
+		&l.DaysOfWeek, &start, &end, &l.CreatedAt, &l.UpdatedAt); err != nil {
+		return LayerResponse{}, err
+	}
+	if start.Valid {
+		v := pgtypeTimeToString(start)
+		l.StartTime = &v
+	}
+	if end.Valid {
+		v := pgtypeTimeToString(end)
+		l.EndTime = &v
+	}
+	l.Members = []OnCallEntry{}
+	return l, nil
+}
+
 // =====================
 // Override operations (unchanged)
 // =====================
@@ -663,3 +885,172 @@ func (s *Store) GetActiveOverride(ctx context.Context, rosterID uuid.UUID, at ti
 	o.CreatedBy = pgtypeUUIDToPtr(createdBy)
 	return &o, nil
 }
+
+// =====================
+// Swap request operations
+// =====================
+
+const swapColumns = `sr.id, sr.roster_id, r.name, sr.kind, sr.role,
+	sr.requester_id, COALESCE(ru.display_name, sr.requester_id::text),
+	sr.target_user_id, COALESCE(tu.display_name, ''),
+	sr.start_at, sr.end_at, sr.swap_start_at, sr.message, sr.status,
+	sr.responder_id, COALESCE(pu.display_name, ''), sr.responded_at, sr.override_id,
+	sr.created_at, sr.updated_at
+	FROM shift_swap_requests sr
+	JOIN rosters r ON r.id = sr.roster_id
+	LEFT JOIN users ru ON ru.id = sr.requester_id
+	LEFT JOIN users tu ON tu.id = sr.target_user_id
+	LEFT JOIN users pu ON pu.id = sr.responder_id`
+
+func scanSwapRequest(row pgx.Row) (SwapRequestResponse, error) {
+	var sr SwapRequestResponse
+	var target, responder, override pgtype.UUID
+	var swapStart, respondedAt pgtype.Timestamptz
+	if err := row.Scan(&sr.ID, &sr.RosterID, &sr.RosterName, &sr.Kind, &sr.Role,
+		&sr.RequesterID, &sr.RequesterName, &target, &sr.TargetName,
+		&sr.StartAt, &sr.EndAt, &swapStart, &sr.Message, &sr.Status,
+		&responder, &sr.ResponderName, &respondedAt, &override,
+		&sr.CreatedAt, &sr.UpdatedAt); err != nil {
+		return SwapRequestResponse{}, err
+	}
+	sr.TargetUserID = pgtypeUUIDToPtr(target)
+	sr.ResponderID = pgtypeUUIDToPtr(responder)
+	sr.OverrideID = pgtypeUUIDToPtr(override)
+	if swapStart.Valid {
+		sr.SwapStartAt = &swapStart.Time
+	}
+	if respondedAt.Valid {
+		sr.RespondedAt = &respondedAt.Time
+	}
+	return sr, nil
+}
+
+// ListSwapRequests lists a roster's swap requests, newest first, optionally
+// filtered by status.
+func (s *Store) ListSwapRequests(ctx context.Context, rosterID uuid.UUID, status string) ([]SwapRequestResponse, error) {
+	query := `SELECT ` + swapColumns + `
+	          WHERE sr.roster_id = $1 AND ($2 = '' OR sr.status = $2)
+	          ORDER BY sr.created_at DESC`
+	return s.querySwapRequests(ctx, query, rosterID, status)
+}
+
+// ListPendingSwapRequestsForUser lists pending requests the user made, was
+// asked to take, or could take as an active member of the roster.
+func (s *Store) ListPendingSwapRequestsForUser(ctx context.Context, userID uuid.UUID) ([]SwapRequestResponse, error) {
+	query := `SELECT ` + swapColumns + `
+	          WHERE sr.status = 'pending' AND sr.end_at > now()
+	            AND (sr.requester_id = $1 OR sr.target_user_id = $1
+	                 OR (sr.target_user_id IS NULL AND EXISTS (
+	                       SELECT 1 FROM roster_members rm
+	                       WHERE rm.roster_id = sr.roster_id AND rm.user_id = $1 AND rm.is_active)))
+	          ORDER BY sr.start_at`
+	return s.querySwapRequests(ctx, query, userID)
+}
+
+func (s *Store) querySwapRequests(ctx context.Context, query string, args ...any) ([]SwapRequestResponse, error) {
+	rows, err := s.dbtx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing swap requests: %w", err)
+	}
+	defer rows.Close()
+
+	var result []SwapRequestResponse
+	for rows.Next() {
+		sr, err := scanSwapRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning swap request row: %w", err)
+		}
+		result = append(result, sr)
+	}
+	if result == nil {
+		result = []SwapRequestResponse{}
+	}
+	return result, rows.Err()
+}
+
+func (s *Store) GetSwapRequest(ctx context.Context, rosterID, id uuid.UUID) (SwapRequestResponse, error) {
+	return scanSwapRequest(s.dbtx.QueryRow(ctx,
+		`SELECT `+swapColumns+` WHERE sr.roster_id = $1 AND sr.id = $2`, rosterID, id))
+}
+
+// GetSwapRequestByID loads a request without knowing its roster, for chat
+// commands that only carry the request ID.
+func (s *Store) GetSwapRequestByID(ctx context.Context, id uuid.UUID) (SwapRequestResponse, error) {
+	return scanSwapRequest(s.dbtx.QueryRow(ctx, `SELECT `+swapColumns+` WHERE sr.id = $1`, id))
+}
+
+func (s *Store) CreateSwapRequest(ctx context.Context, rosterID uuid.UUID, kind, role string,
+	requesterID uuid.UUID, targetUserID *uuid.UUID, startAt, endAt time.Time,
+	swapStartAt *time.Time, message *string) (SwapRequestResponse, error) {
+
+	var id uuid.UUID
+	err := s.dbtx.QueryRow(ctx,
+		`INSERT INTO shift_swap_requests (roster_id, kind, role, requester_id, target_user_id,
+		   start_at, end_at, swap_start_at, message)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 RETURNING id`,
+		rosterID, kind, role, requesterID, targetUserID, startAt, endAt, swapStartAt, message).Scan(&id)
+	if err != nil {
+		return SwapRequestResponse{}, fmt.Errorf("creating swap request: %w", err)
+	}
+	return s.GetSwapRequest(ctx, rosterID, id)
+}
+
+// ClaimSwapRequest moves a pending request to status, recording the
+// responder. It returns pgx.ErrNoRows if the request is no longer pending,
+// so two people cannot accept the same request.
+func (s *Store) ClaimSwapRequest(ctx context.Context, id uuid.UUID, status string, responderID *uuid.UUID) error {
+	tag, err := s.dbtx.Exec(ctx,
+		`UPDATE shift_swap_requests
+		 SET status = $2, responder_id = $3, responded_at = now(), updated_at = now()
+		 WHERE id = $1 AND status = 'pending'`, id, status, responderID)
+	if err != nil {
+		return fmt.Errorf("updating swap request: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// CompleteSwapRequest records what an accepted request changed.
+func (s *Store) CompleteSwapRequest(ctx context.Context, id uuid.UUID, overrideID *uuid.UUID, swapStartAt *time.Time) error {
+	_, err := s.dbtx.Exec(ctx,
+		`UPDATE shift_swap_requests
+		 SET override_id = $2, swap_start_at = COALESCE($3, swap_start_at), updated_at = now()
+		 WHERE id = $1`, id, overrideID, swapStartAt)
+	if err != nil {
+		return fmt.Errorf("completing swap request: %w", err)
+	}
+	return nil
+}
+
+// IsActiveMember reports whether the user is an active member of the roster.
+func (s *Store) IsActiveMember(ctx context.Context, rosterID, userID uuid.UUID) (bool, error) {
+	var ok bool
+	err := s.dbtx.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM roster_members
+		                WHERE roster_id = $1 AND user_id = $2 AND is_active)`,
+		rosterID, userID).Scan(&ok)
+	return ok, err
+}
+
+// UserEmails returns the email address of each given user.
+func (s *Store) UserEmails(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]string, error) {
+	rows, err := s.dbtx.Query(ctx, `SELECT id, email FROM users WHERE id = ANY($1)`, userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("listing user emails: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[uuid.UUID]string, len(userIDs))
+	for rows.Next() {
+		var id uuid.UUID
+		var email string
+		if err := rows.Scan(&id, &email); err != nil {
+			return nil, fmt.Errorf("scanning user email: %w", err)
+		}
+		result[id] = email
+	}
+	return result, rows.Err()
+}
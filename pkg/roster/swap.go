@@ -0,0 +1,422 @@
+package roster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Swap request errors. Handlers map them to 400, 403 and 409.
+var (
+	ErrSwapInvalid   = errors.New("invalid swap request")
+	ErrSwapForbidden = errors.New("not allowed to act on this swap request")
+	ErrSwapConflict  = errors.New("swap request can no longer be applied")
+)
+
+func (s *Service) ListSwapRequests(ctx context.Context, rosterID uuid.UUID, status string) ([]SwapRequestResponse, error) {
+	return s.store.ListSwapRequests(ctx, rosterID, status)
+}
+
+func (s *Service) ListPendingSwapRequestsForUser(ctx context.Context, userID uuid.UUID) ([]SwapRequestResponse, error) {
+	return s.store.ListPendingSwapRequestsForUser(ctx, userID)
+}
+
+func (s *Service) GetSwapRequest(ctx context.Context, rosterID, id uuid.UUID) (SwapRequestResponse, error) {
+	return s.store.GetSwapRequest(ctx, rosterID, id)
+}
+
+// CreateSwapRequest records a request by requesterID to give away duty.
+//
+// A cover request offers [start_at, end_at) — by default the rest of the
+// shift containing start_at — and is applied as an override for whoever
+// accepts. A swap request offers the requester's shift containing start_at
+// in exchange for the responder's shift containing swap_start_at; naming
+// swap_start_at up front also fixes the target to that shift's holder.
+func (s *Service) CreateSwapRequest(ctx context.Context, rosterID uuid.UUID, req CreateSwapRequest, requesterID uuid.UUID) (SwapRequestResponse, error) {
+	roster, err := s.store.GetRoster(ctx, rosterID)
+	if err != nil {
+		return SwapRequestResponse{}, err
+	}
+
+	kind, role := req.Kind, req.Role
+	if kind == "" {
+		kind = SwapKindCover
+	}
+	if role == "" {
+		role = "primary"
+	}
+	if kind != SwapKindCover && kind != SwapKindSwap {
+		return SwapRequestResponse{}, fmt.Errorf("%w: kind must be cover or swap", ErrSwapInvalid)
+	}
+	if role != "primary" && role != "secondary" {
+		return SwapRequestResponse{}, fmt.Errorf("%w: role must be primary or secondary", ErrSwapInvalid)
+	}
+	if kind == SwapKindCover && role != "primary" {
+		// Overrides only replace the primary.
+		return SwapRequestResponse{}, fmt.Errorf("%w: cover requests are for primary duty; use kind swap for secondary", ErrSwapInvalid)
+	}
+
+	if ok, err := s.store.IsActiveMember(ctx, rosterID, requesterID); err != nil {
+		return SwapRequestResponse{}, err
+	} else if !ok {
+		return SwapRequestResponse{}, fmt.Errorf("%w: requester is not an active member of this roster", ErrSwapForbidden)
+	}
+
+	at, err := shiftInstant(roster, req.StartAt)
+	if err != nil {
+		return SwapRequestResponse{}, fmt.Errorf("%w: start_at: %v", ErrSwapInvalid, err)
+	}
+	target := req.TargetUserID
+
+	var start, end time.Time
+	var swapStart *time.Time
+	switch kind {
+	case SwapKindCover:
+		onCall, err := s.resolveOnCall(ctx, roster, at)
+		if err != nil {
+			return SwapRequestResponse{}, err
+		}
+		if onCall.Primary == nil || onCall.Primary.UserID != requesterID {
+			return SwapRequestResponse{}, fmt.Errorf("%w: requester is not primary at %s", ErrSwapInvalid, at.Format(time.RFC3339))
+		}
+		start = at
+		if req.EndAt != nil {
+			if end, err = shiftInstant(roster, *req.EndAt); err != nil {
+				return SwapRequestResponse{}, fmt.Errorf("%w: end_at: %v", ErrSwapInvalid, err)
+			}
+		} else {
+			end = shiftAt(roster, at).end
+			if entry, err := s.store.GetScheduleForTime(ctx, rosterID, at); err != nil {
+				return SwapRequestResponse{}, err
+			} else if entry != nil {
+				end = entry.ShiftEnd
+			}
+		}
+
+	case SwapKindSwap:
+		mine, err := s.heldShift(ctx, rosterID, at, role, requesterID)
+		if err != nil {
+			return SwapRequestResponse{}, err
+		}
+		start, end = mine.ShiftStart, mine.ShiftEnd
+		if req.SwapStartAt != nil {
+			other, err := s.offeredShift(ctx, roster, *req.SwapStartAt, role, mine)
+			if err != nil {
+				return SwapRequestResponse{}, err
+			}
+			holder := roleHolder(*other, role)
+			if target != nil && *target != *holder {
+				return SwapRequestResponse{}, fmt.Errorf("%w: target_user_id does not hold the shift at swap_start_at", ErrSwapInvalid)
+			}
+			target = holder
+			swapStart = &other.ShiftStart
+		}
+	}
+
+	if !end.After(start) {
+		return SwapRequestResponse{}, fmt.Errorf("%w: end_at must be after start_at", ErrSwapInvalid)
+	}
+	if !end.After(time.Now()) {
+		return SwapRequestResponse{}, fmt.Errorf("%w: the offered time is already over", ErrSwapInvalid)
+	}
+	if target != nil {
+		if *target == requesterID {
+			return SwapRequestResponse{}, fmt.Errorf("%w: cannot ask yourself", ErrSwapInvalid)
+		}
+		if ok, err := s.store.IsActiveMember(ctx, rosterID, *target); err != nil {
+			return SwapRequestResponse{}, err
+		} else if !ok {
+			return SwapRequestResponse{}, fmt.Errorf("%w: target is not an active member of this roster", ErrSwapInvalid)
+		}
+	}
+
+	return s.store.CreateSwapRequest(ctx, rosterID, kind, role, requesterID, target, start, end, swapStart, req.Message)
+}
+
+// AcceptSwapRequest applies a pending request on behalf of responderID:
+// cover requests create an override, swap requests trade the two shifts and
+// lock them. swapStartAt names the responder's shift when the request left
+// it open.
+func (s *Service) AcceptSwapRequest(ctx context.Context, rosterID, id, responderID uuid.UUID, swapStartAt *string) (SwapRequestResponse, error) {
+	sr, err := s.pendingSwapRequest(ctx, rosterID, id)
+	if err != nil {
+		return SwapRequestResponse{}, err
+	}
+	if responderID == sr.RequesterID {
+		return SwapRequestResponse{}, fmt.Errorf("%w: cannot accept your own request", ErrSwapForbidden)
+	}
+	if sr.TargetUserID != nil && *sr.TargetUserID != responderID {
+		return SwapRequestResponse{}, fmt.Errorf("%w: the request was made to someone else", ErrSwapForbidden)
+	}
+	if ok, err := s.store.IsActiveMember(ctx, rosterID, responderID); err != nil {
+		return SwapRequestResponse{}, err
+	} else if !ok {
+		return SwapRequestResponse{}, fmt.Errorf("%w: only active roster members can accept", ErrSwapForbidden)
+	}
+
+	roster, err := s.store.GetRoster(ctx, rosterID)
+	if err != nil {
+		return SwapRequestResponse{}, err
+	}
+
+	// Validate the swap before claiming so a bad shift key leaves the
+	// request pending.
+	var mine, theirs *ScheduleEntry
+	if sr.Kind == SwapKindSwap {
+		if mine, err = s.heldShift(ctx, rosterID, sr.StartAt, sr.Role, sr.RequesterID); err != nil {
+			if errors.Is(err, ErrSwapInvalid) {
+				// The schedule changed since the request was made.
+				return SwapRequestResponse{}, fmt.Errorf("%w: %v", ErrSwapConflict, err)
+			}
+			return SwapRequestResponse{}, err
+		}
+		key := ""
+		switch {
+		case sr.SwapStartAt != nil:
+			key = sr.SwapStartAt.Format(time.RFC3339)
+		case swapStartAt != nil:
+			key = *swapStartAt
+		default:
+			return SwapRequestResponse{}, fmt.Errorf("%w: swap_start_at is required to accept a swap", ErrSwapInvalid)
+		}
+		if theirs, err = s.offeredShift(ctx, roster, key, sr.Role, mine); err != nil {
+			return SwapRequestResponse{}, err
+		}
+		if holder := roleHolder(*theirs, sr.Role); *holder != responderID {
+			return SwapRequestResponse{}, fmt.Errorf("%w: you do not hold the shift offered in return", ErrSwapInvalid)
+		}
+		if isAssigned(*mine, responderID) || isAssigned(*theirs, sr.RequesterID) {
+			return SwapRequestResponse{}, fmt.Errorf("%w: the trade would put someone on both roles of a shift", ErrSwapConflict)
+		}
+	}
+
+	// Claim, apply and complete together: a failure part way leaves both
+	// the request and the schedule as they were.
+	err = s.store.inTx(ctx, func(tx *Store) error {
+		if err := tx.ClaimSwapRequest(ctx, id, SwapStatusAccepted, &responderID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("%w: request is no longer pending", ErrSwapConflict)
+			}
+			return err
+		}
+		var overrideID *uuid.UUID
+		var swapped *time.Time
+		if sr.Kind == SwapKindCover {
+			o, err := coverOverride(ctx, tx, sr, responderID)
+			if err != nil {
+				return err
+			}
+			overrideID = &o.ID
+		} else {
+			if err := tradeShifts(ctx, tx, roster, sr, mine, theirs, responderID); err != nil {
+				return err
+			}
+			swapped = &theirs.ShiftStart
+		}
+		return tx.CompleteSwapRequest(ctx, id, overrideID, swapped)
+	})
+	if err != nil {
+		return SwapRequestResponse{}, err
+	}
+	return s.store.GetSwapRequest(ctx, rosterID, id)
+}
+
+func coverOverride(ctx context.Context, store *Store, sr SwapRequestResponse, responderID uuid.UUID) (OverrideResponse, error) {
+	start := sr.StartAt
+	if now := time.Now(); start.Before(now) {
+		start = now
+	}
+	reason := fmt.Sprintf("Covering for %s (swap request %s)", sr.RequesterName, sr.ID)
+	return store.CreateOverride(ctx, sr.RosterID, responderID, start, sr.EndAt, &reason,
+		pgtype.UUID{Bytes: responderID, Valid: true})
+}
+
+// tradeShifts exchanges the role between the requester's and responder's
+// shifts and locks both so regeneration keeps the trade.
+func tradeShifts(ctx context.Context, store *Store, roster RosterResponse, sr SwapRequestResponse,
+	mine, theirs *ScheduleEntry, responderID uuid.UUID) error {
+
+	loc, err := time.LoadLocation(roster.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	notes := fmt.Sprintf("Swapped via request %s", sr.ID)
+	requesterID := sr.RequesterID
+
+	for _, change := range []struct {
+		entry *ScheduleEntry
+		to    uuid.UUID
+	}{{mine, responderID}, {theirs, requesterID}} {
+		primary, secondary := change.entry.PrimaryUserID, change.entry.SecondaryUserID
+		to := change.to
+		if sr.Role == "primary" {
+			primary = &to
+		} else {
+			secondary = &to
+		}
+		if _, err := store.UpsertShift(ctx, roster.ID, change.entry.ShiftStart, change.entry.ShiftEnd, loc,
+			primary, secondary, true, false, &notes); err != nil {
+			return fmt.Errorf("updating shift %s: %w", change.entry.ShiftStart.Format(time.RFC3339), err)
+		}
+	}
+	return nil
+}
+
+// DeclineSwapRequest lets the target of a directed request turn it down.
+// Open requests stay available to the rest of the roster instead.
+func (s *Service) DeclineSwapRequest(ctx context.Context, rosterID, id, responderID uuid.UUID) (SwapRequestResponse, error) {
+	sr, err := s.pendingSwapRequest(ctx, rosterID, id)
+	if err != nil {
+		return SwapRequestResponse{}, err
+	}
+	if sr.TargetUserID == nil {
+		return SwapRequestResponse{}, fmt.Errorf("%w: open requests can only be cancelled by the requester", ErrSwapInvalid)
+	}
+	if *sr.TargetUserID != responderID {
+		return SwapRequestResponse{}, fmt.Errorf("%w: the request was made to someone else", ErrSwapForbidden)
+	}
+	return s.closeSwapRequest(ctx, rosterID, id, SwapStatusDeclined, &responderID)
+}
+
+// CancelSwapRequest withdraws a pending request; only the requester may.
+func (s *Service) CancelSwapRequest(ctx context.Context, rosterID, id, callerID uuid.UUID) (SwapRequestResponse, error) {
+	sr, err := s.pendingSwapRequest(ctx, rosterID, id)
+	if err != nil {
+		return SwapRequestResponse{}, err
+	}
+	if sr.RequesterID != callerID {
+		return SwapRequestResponse{}, fmt.Errorf("%w: only the requester can cancel", ErrSwapForbidden)
+	}
+	return s.closeSwapRequest(ctx, rosterID, id, SwapStatusCancelled, nil)
+}
+
+func (s *Service) closeSwapRequest(ctx context.Context, rosterID, id uuid.UUID, status string, responderID *uuid.UUID) (SwapRequestResponse, error) {
+	if err := s.store.ClaimSwapRequest(ctx, id, status, responderID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return SwapRequestResponse{}, fmt.Errorf("%w: request is no longer pending", ErrSwapConflict)
+		}
+		return SwapRequestResponse{}, err
+	}
+	return s.store.GetSwapRequest(ctx, rosterID, id)
+}
+
+// pendingSwapRequest loads a request and checks it can still be acted on,
+// expiring it once the offered time is over.
+func (s *Service) pendingSwapRequest(ctx context.Context, rosterID, id uuid.UUID) (SwapRequestResponse, error) {
+	sr, err := s.store.GetSwapRequest(ctx, rosterID, id)
+	if err != nil {
+		return SwapRequestResponse{}, err
+	}
+	if sr.Status != SwapStatusPending {
+		return SwapRequestResponse{}, fmt.Errorf("%w: request is %s", ErrSwapConflict, sr.Status)
+	}
+	if !sr.EndAt.After(time.Now()) {
+		if err := s.store.ClaimSwapRequest(ctx, id, SwapStatusExpired, nil); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			s.logger.Error("expiring swap request", "error", err, "swap_id", id)
+		}
+		return SwapRequestResponse{}, fmt.Errorf("%w: request has expired", ErrSwapConflict)
+	}
+	return sr, nil
+}
+
+// heldShift returns the schedule shift at `at`, checking userID holds role.
+func (s *Service) heldShift(ctx context.Context, rosterID uuid.UUID, at time.Time, role string, userID uuid.UUID) (*ScheduleEntry, error) {
+	entry, err := s.store.GetScheduleForTime(ctx, rosterID, at)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("%w: no schedule shift at %s", ErrSwapInvalid, at.Format(time.RFC3339))
+	}
+	if holder := roleHolder(*entry, role); holder == nil || *holder != userID {
+		return nil, fmt.Errorf("%w: requester is not %s for the shift at %s", ErrSwapInvalid, role, at.Format(time.RFC3339))
+	}
+	return entry, nil
+}
+
+// offeredShift resolves the shift offered in return for mine.
+func (s *Service) offeredShift(ctx context.Context, roster RosterResponse, key, role string, mine *ScheduleEntry) (*ScheduleEntry, error) {
+	at, err := shiftInstant(roster, key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: swap_start_at: %v", ErrSwapInvalid, err)
+	}
+	other, err := s.store.GetScheduleForTime(ctx, roster.ID, at)
+	if err != nil {
+		return nil, err
+	}
+	if other == nil || roleHolder(*other, role) == nil {
+		return nil, fmt.Errorf("%w: no assigned %s shift at swap_start_at", ErrSwapInvalid, role)
+	}
+	if other.ID == mine.ID {
+		return nil, fmt.Errorf("%w: swap_start_at names the same shift", ErrSwapInvalid)
+	}
+	return other, nil
+}
+
+func roleHolder(e ScheduleEntry, role string) *uuid.UUID {
+	if role == "secondary" {
+		return e.SecondaryUserID
+	}
+	return e.PrimaryUserID
+}
+
+// swapParticipants returns who should hear about a swap event: the target
+// (or every other active member for open requests) when it is created, and
+// the requester and responder once it is answered.
+func swapParticipants(event string, sr SwapRequestResponse, members []MemberResponse) []uuid.UUID {
+	var ids []uuid.UUID
+	switch event {
+	case SwapStatusPending:
+		if sr.TargetUserID != nil {
+			return []uuid.UUID{*sr.TargetUserID}
+		}
+		for _, m := range members {
+			if m.UserID != sr.RequesterID {
+				ids = append(ids, m.UserID)
+			}
+		}
+	case SwapStatusCancelled:
+		if sr.TargetUserID != nil {
+			ids = append(ids, *sr.TargetUserID)
+		}
+	default:
+		ids = append(ids, sr.RequesterID)
+		if sr.ResponderID != nil {
+			ids = append(ids, *sr.ResponderID)
+		}
+	}
+	return ids
+}
+
+// SwapAuditDetail is the audit log detail for a swap request action.
+func SwapAuditDetail(sr SwapRequestResponse) json.RawMessage {
+	detail := map[string]any{
+		"swap_id":      sr.ID,
+		"kind":         sr.Kind,
+		"role":         sr.Role,
+		"status":       sr.Status,
+		"requester_id": sr.RequesterID,
+		"start_at":     sr.StartAt.Format(time.RFC3339),
+		"end_at":       sr.EndAt.Format(time.RFC3339),
+	}
+	if sr.TargetUserID != nil {
+		detail["target_user_id"] = *sr.TargetUserID
+	}
+	if sr.ResponderID != nil {
+		detail["responder_id"] = *sr.ResponderID
+	}
+	if sr.SwapStartAt != nil {
+		detail["swap_start_at"] = sr.SwapStartAt.Format(time.RFC3339)
+	}
+	if sr.OverrideID != nil {
+		detail["override_id"] = *sr.OverrideID
+	}
+	b, _ := json.Marshal(detail)
+	return b
+}
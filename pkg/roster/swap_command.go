@@ -0,0 +1,165 @@
+package roster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// SwapCommandUsage is the help text for `/nightowl swap`.
+const SwapCommandUsage = "Usage:\n" +
+	"`/nightowl swap list` — pending requests you made or can take\n" +
+	"`/nightowl swap give <roster> <start> [end]` — ask anyone on the roster to cover (dates or RFC3339)\n" +
+	"`/nightowl swap trade <roster> <your-shift> <their-shift>` — offer to trade primary shifts\n" +
+	"`/nightowl swap accept <id> [your-shift]` · `decline <id>` · `cancel <id>`"
+
+// SwapCommandResult is the outcome of a `/nightowl swap` command. When
+// Request is set, the caller should notify participants of Event and audit
+// Action against the request's roster.
+type SwapCommandResult struct {
+	Text    string
+	Event   string
+	Action  string
+	Request *SwapRequestResponse
+}
+
+// RunSwapCommand executes `/nightowl swap <args>` for the given NightOwl user.
+// User errors are returned as result text; only internal failures return err.
+func (s *Service) RunSwapCommand(ctx context.Context, callerID uuid.UUID, args []string) (SwapCommandResult, error) {
+	sub := "list"
+	if len(args) > 0 {
+		sub = strings.ToLower(args[0])
+		args = args[1:]
+	}
+
+	var sr SwapRequestResponse
+	var err error
+	var event, action string
+	switch sub {
+	case "list":
+		return s.swapListCommand(ctx, callerID)
+
+	case "give", "trade":
+		if (sub == "give" && len(args) < 2) || (sub == "trade" && len(args) < 3) {
+			return SwapCommandResult{Text: SwapCommandUsage}, nil
+		}
+		ro, msg, err := s.findRosterByName(ctx, args[0])
+		if err != nil {
+			return SwapCommandResult{}, err
+		}
+		if msg != "" {
+			return SwapCommandResult{Text: msg}, nil
+		}
+		req := CreateSwapRequest{Kind: SwapKindCover, StartAt: args[1]}
+		if sub == "give" && len(args) > 2 {
+			req.EndAt = &args[2]
+		}
+		if sub == "trade" {
+			req.Kind = SwapKindSwap
+			req.SwapStartAt = &args[2]
+		}
+		sr, err = s.CreateSwapRequest(ctx, ro.ID, req, callerID)
+		event, action = SwapStatusPending, "create_swap_request"
+
+	case "accept", "decline", "cancel":
+		if len(args) < 1 {
+			return SwapCommandResult{Text: SwapCommandUsage}, nil
+		}
+		id, perr := uuid.Parse(args[0])
+		if perr != nil {
+			return SwapCommandResult{Text: "Invalid swap request ID."}, nil
+		}
+		existing, gerr := s.store.GetSwapRequestByID(ctx, id)
+		if errors.Is(gerr, pgx.ErrNoRows) {
+			return SwapCommandResult{Text: "Swap request not found."}, nil
+		} else if gerr != nil {
+			return SwapCommandResult{}, gerr
+		}
+		switch sub {
+		case "accept":
+			var swapAt *string
+			if len(args) > 1 {
+				swapAt = &args[1]
+			}
+			sr, err = s.AcceptSwapRequest(ctx, existing.RosterID, id, callerID, swapAt)
+			event, action = SwapStatusAccepted, "accept_swap_request"
+		case "decline":
+			sr, err = s.DeclineSwapRequest(ctx, existing.RosterID, id, callerID)
+			event, action = SwapStatusDeclined, "decline_swap_request"
+		default:
+			sr, err = s.CancelSwapRequest(ctx, existing.RosterID, id, callerID)
+			event, action = SwapStatusCancelled, "cancel_swap_request"
+		}
+
+	default:
+		return SwapCommandResult{Text: SwapCommandUsage}, nil
+	}
+
+	if err != nil {
+		if errors.Is(err, ErrSwapInvalid) || errors.Is(err, ErrSwapForbidden) || errors.Is(err, ErrSwapConflict) ||
+			errors.Is(err, ErrInvalidShiftKey) {
+			return SwapCommandResult{Text: "Could not " + sub + ": " + err.Error()}, nil
+		}
+		return SwapCommandResult{}, err
+	}
+	return SwapCommandResult{
+		Text:    SwapMessage(event, sr, time.UTC) + fmt.Sprintf(" (request `%s`)", sr.ID),
+		Event:   event,
+		Action:  action,
+		Request: &sr,
+	}, nil
+}
+
+func (s *Service) swapListCommand(ctx context.Context, callerID uuid.UUID) (SwapCommandResult, error) {
+	items, err := s.store.ListPendingSwapRequestsForUser(ctx, callerID)
+	if err != nil {
+		return SwapCommandResult{}, err
+	}
+	if len(items) == 0 {
+		return SwapCommandResult{Text: "No pending swap requests."}, nil
+	}
+	lines := []string{"Pending swap requests:"}
+	for _, sr := range items {
+		who := sr.RequesterName
+		if sr.RequesterID == callerID {
+			who = "you"
+		}
+		lines = append(lines, fmt.Sprintf("- `%s` %s: %s %s %s → %s (by %s)",
+			sr.ID, sr.RosterName, sr.Kind, sr.Role,
+			sr.StartAt.UTC().Format("Jan 2 15:04"), sr.EndAt.UTC().Format("Jan 2 15:04 MST"), who))
+	}
+	return SwapCommandResult{Text: strings.Join(lines, "\n")}, nil
+}
+
+// findRosterByName matches a roster by exact name, then by unique prefix,
+// ignoring case. A non-empty msg explains why nothing matched.
+func (s *Service) findRosterByName(ctx context.Context, name string) (RosterResponse, string, error) {
+	rosters, err := s.store.ListRosters(ctx)
+	if err != nil {
+		return RosterResponse{}, "", err
+	}
+	name = strings.ToLower(name)
+	var matches []RosterResponse
+	for _, ro := range rosters {
+		lower := strings.ToLower(ro.Name)
+		if lower == name {
+			return ro, "", nil
+		}
+		if strings.HasPrefix(lower, name) {
+			matches = append(matches, ro)
+		}
+	}
+	switch len(matches) {
+	case 1:
+		return matches[0], "", nil
+	case 0:
+		return RosterResponse{}, "No roster named " + name + ".", nil
+	default:
+		return RosterResponse{}, "Several rosters match " + name + "; be more specific.", nil
+	}
+}
@@ -0,0 +1,120 @@
+package roster
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/wisbric/nightowl/internal/db"
+	"github.com/wisbric/nightowl/pkg/messaging"
+)
+
+// SwapNotifier DMs the people involved in a swap request through every
+// registered messaging provider.
+type SwapNotifier struct {
+	registry *messaging.Registry
+	logger   *slog.Logger
+}
+
+// NewSwapNotifier creates a SwapNotifier. A nil registry disables it.
+func NewSwapNotifier(registry *messaging.Registry, logger *slog.Logger) *SwapNotifier {
+	return &SwapNotifier{registry: registry, logger: logger}
+}
+
+// Notify tells the participants of sr about event, which is the request's
+// new status. Delivery failures are logged, never returned.
+func (n *SwapNotifier) Notify(ctx context.Context, dbtx db.DBTX, event string, sr SwapRequestResponse) {
+	if n == nil || n.registry == nil {
+		return
+	}
+	providers := n.registry.All()
+	if len(providers) == 0 {
+		return
+	}
+
+	store := NewStore(dbtx)
+	loc := time.UTC
+	if roster, err := store.GetRoster(ctx, sr.RosterID); err == nil {
+		if l, err := time.LoadLocation(roster.Timezone); err == nil {
+			loc = l
+		}
+	}
+	var members []MemberResponse
+	if event == SwapStatusPending && sr.TargetUserID == nil {
+		var err error
+		if members, err = store.ListActiveMembers(ctx, sr.RosterID); err != nil {
+			n.logger.Error("listing members for swap notification", "error", err, "swap_id", sr.ID)
+			return
+		}
+	}
+
+	recipients := swapParticipants(event, sr, members)
+	if len(recipients) == 0 {
+		return
+	}
+	emails, err := store.UserEmails(ctx, recipients)
+	if err != nil {
+		n.logger.Error("loading emails for swap notification", "error", err, "swap_id", sr.ID)
+		return
+	}
+
+	text := SwapMessage(event, sr, loc)
+	for _, p := range providers {
+		for _, uid := range recipients {
+			email := emails[uid]
+			if email == "" {
+				continue
+			}
+			ref, err := p.LookupUser(ctx, email)
+			if err != nil || ref == "" {
+				n.logger.Debug("swap notification recipient not found", "provider", p.Name(), "user_id", uid, "error", err)
+				continue
+			}
+			if err := p.SendDM(ctx, ref, messaging.DirectMessage{Text: text, Urgency: "normal"}); err != nil {
+				n.logger.Warn("sending swap notification", "provider", p.Name(), "user_id", uid, "error", err)
+			}
+		}
+	}
+}
+
+// SwapMessage renders the notification text for a swap event. It uses only
+// formatting Slack and Mattermost render the same way.
+func SwapMessage(event string, sr SwapRequestResponse, loc *time.Location) string {
+	what := fmt.Sprintf("%s duty on %s from %s to %s", sr.Role, sr.RosterName,
+		sr.StartAt.In(loc).Format("Mon Jan 2 15:04"), sr.EndAt.In(loc).Format("Mon Jan 2 15:04 MST"))
+	if sr.Kind == SwapKindSwap && sr.SwapStartAt != nil {
+		what += fmt.Sprintf(", in exchange for the shift starting %s", sr.SwapStartAt.In(loc).Format("Mon Jan 2 15:04 MST"))
+	}
+
+	var text string
+	switch event {
+	case SwapStatusPending:
+		verb := "cover"
+		if sr.Kind == SwapKindSwap {
+			verb = "swap"
+		}
+		if sr.TargetUserID != nil {
+			text = fmt.Sprintf("%s asked you to %s their %s.", sr.RequesterName, verb, what)
+		} else {
+			text = fmt.Sprintf("%s is looking for someone to %s their %s.", sr.RequesterName, verb, what)
+		}
+		if sr.Message != nil && *sr.Message != "" {
+			text += "\n> " + *sr.Message
+		}
+		text += fmt.Sprintf("\nReply with `/nightowl swap accept %s`", sr.ID)
+		if sr.TargetUserID != nil {
+			text += fmt.Sprintf(" or `/nightowl swap decline %s`", sr.ID)
+		}
+		text += "."
+	case SwapStatusAccepted:
+		text = fmt.Sprintf("%s accepted %s's request: %s.", sr.ResponderName, sr.RequesterName, what)
+	case SwapStatusDeclined:
+		text = fmt.Sprintf("%s declined %s's request: %s.", sr.ResponderName, sr.RequesterName, what)
+	case SwapStatusCancelled:
+		text = fmt.Sprintf("%s cancelled their request: %s.", sr.RequesterName, what)
+	default:
+		text = fmt.Sprintf("Swap request %s is now %s: %s.", sr.ID, event, what)
+	}
+	return text
+}
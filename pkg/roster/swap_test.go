@@ -0,0 +1,137 @@
+package roster
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/wisbric/nightowl/internal/db"
+)
+
+func sampleSwap() SwapRequestResponse {
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	return SwapRequestResponse{
+		ID:            uuid.New(),
+		RosterID:      uuid.New(),
+		RosterName:    "Platform",
+		Kind:          SwapKindCover,
+		Role:          "primary",
+		RequesterID:   uuid.New(),
+		RequesterName: "Alice",
+		StartAt:       start,
+		EndAt:         start.AddDate(0, 0, 7),
+		Status:        SwapStatusPending,
+	}
+}
+
+func TestSwapParticipants(t *testing.T) {
+	sr := sampleSwap()
+	bob, carol := uuid.New(), uuid.New()
+	members := []MemberResponse{{UserID: sr.RequesterID}, {UserID: bob}, {UserID: carol}}
+
+	// Open request: everyone but the requester hears about it.
+	got := swapParticipants(SwapStatusPending, sr, members)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 recipients for open request, got %d", len(got))
+	}
+
+	// Directed request: only the target.
+	sr.TargetUserID = &bob
+	got = swapParticipants(SwapStatusPending, sr, members)
+	if len(got) != 1 || got[0] != bob {
+		t.Errorf("expected only the target, got %v", got)
+	}
+
+	// Answered: both parties.
+	sr.ResponderID = &bob
+	got = swapParticipants(SwapStatusAccepted, sr, nil)
+	if len(got) != 2 || got[0] != sr.RequesterID || got[1] != bob {
+		t.Errorf("expected requester and responder, got %v", got)
+	}
+}
+
+func TestSwapMessage(t *testing.T) {
+	sr := sampleSwap()
+	msg := SwapMessage(SwapStatusPending, sr, time.UTC)
+	if !strings.Contains(msg, "looking for someone to cover") || !strings.Contains(msg, "/nightowl swap accept "+sr.ID.String()) {
+		t.Errorf("unexpected open request message: %q", msg)
+	}
+	if strings.Contains(msg, "decline") {
+		t.Error("open requests should not offer decline")
+	}
+
+	bob := uuid.New()
+	sr.TargetUserID = &bob
+	sr.ResponderID = &bob
+	sr.ResponderName = "Bob"
+	if msg := SwapMessage(SwapStatusAccepted, sr, time.UTC); !strings.HasPrefix(msg, "Bob accepted Alice's request") {
+		t.Errorf("unexpected accepted message: %q", msg)
+	}
+}
+
+func TestRoleHolder(t *testing.T) {
+	p, s := uuid.New(), uuid.New()
+	e := ScheduleEntry{PrimaryUserID: &p, SecondaryUserID: &s}
+	if *roleHolder(e, "primary") != p || *roleHolder(e, "secondary") != s {
+		t.Error("roleHolder returned the wrong user")
+	}
+}
+
+func TestRunSwapCommand_Usage(t *testing.T) {
+	svc := &Service{}
+	res, err := svc.RunSwapCommand(context.Background(), uuid.New(), []string{"give"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Text != SwapCommandUsage || res.Request != nil {
+		t.Errorf("expected usage text, got %+v", res)
+	}
+
+	res, _ = svc.RunSwapCommand(context.Background(), uuid.New(), []string{"accept", "not-a-uuid"})
+	if res.Text != "Invalid swap request ID." {
+		t.Errorf("unexpected response %q", res.Text)
+	}
+}
+
+type fakeTx struct {
+	pgx.Tx
+	committed, rolledBack bool
+}
+
+func (t *fakeTx) Commit(context.Context) error   { t.committed = true; return nil }
+func (t *fakeTx) Rollback(context.Context) error { t.rolledBack = true; return nil }
+
+type fakeConn struct {
+	db.DBTX
+	tx *fakeTx
+}
+
+func (c *fakeConn) Begin(context.Context) (pgx.Tx, error) { return c.tx, nil }
+
+func TestStore_InTx(t *testing.T) {
+	conn := &fakeConn{tx: &fakeTx{}}
+	failed := errors.New("second shift update failed")
+	err := NewStore(conn).inTx(context.Background(), func(tx *Store) error {
+		if tx.dbtx != conn.tx {
+			t.Error("fn got a store outside the transaction")
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) || conn.tx.committed || !conn.tx.rolledBack {
+		t.Errorf("failed fn: err = %v, committed = %v, rolled back = %v", err, conn.tx.committed, conn.tx.rolledBack)
+	}
+
+	conn.tx = &fakeTx{}
+	if err := NewStore(conn).inTx(context.Background(), func(*Store) error { return nil }); err != nil || !conn.tx.committed {
+		t.Errorf("successful fn: err = %v, committed = %v", err, conn.tx.committed)
+	}
+
+	if err := NewStore(struct{ db.DBTX }{}).inTx(context.Background(), func(*Store) error { return nil }); err == nil {
+		t.Error("a connection that cannot begin a transaction must fail")
+	}
+}
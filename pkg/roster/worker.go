@@ -9,6 +9,7 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/wisbric/nightowl/internal/db"
+	"github.com/wisbric/nightowl/pkg/timeoff"
 )
 
 // ScheduleTopUp tops up schedules for all active rosters across all tenants.
@@ -39,6 +40,11 @@ func topUpTenant(ctx context.Context, pool *pgxpool.Pool, slug string, logger *s
 		return fmt.Errorf("setting search_path: %w", err)
 	}
 
+	// Refresh imported time off first so generation sees current availability.
+	if err := timeoff.NewService(conn, logger).SyncAll(ctx); err != nil {
+		logger.Error("calendar sync failed for tenant", "tenant", slug, "error", err)
+	}
+
 	svc := NewService(conn, logger)
 	rosters, err := svc.ListRosters(ctx)
 	if err != nil {
@@ -1,20 +1,31 @@
 package slack
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	goslack "github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
 
+	"github.com/wisbric/core/pkg/auth"
+
+	"github.com/wisbric/nightowl/internal/audit"
 	"github.com/wisbric/nightowl/internal/db"
+	"github.com/wisbric/nightowl/pkg/roster"
 	"github.com/wisbric/nightowl/pkg/tenant"
+	"github.com/wisbric/nightowl/pkg/user"
 )
 
 // Handler provides HTTP handlers for Slack integration.
@@ -24,16 +35,21 @@ type Handler struct {
 	logger        *slog.Logger
 	signingSecret string
 	defaultTenant string // slug of the default tenant for Slack interactions
+	audit         *audit.Writer
+	swaps         *roster.SwapNotifier
 }
 
 // NewHandler creates a Slack Handler.
-func NewHandler(notifier *Notifier, pool *pgxpool.Pool, logger *slog.Logger, signingSecret, defaultTenant string) *Handler {
+func NewHandler(notifier *Notifier, pool *pgxpool.Pool, logger *slog.Logger, signingSecret, defaultTenant string,
+	auditWriter *audit.Writer, swaps *roster.SwapNotifier) *Handler {
 	return &Handler{
 		notifier:      notifier,
 		pool:          pool,
 		logger:        logger,
 		signingSecret: signingSecret,
 		defaultTenant: defaultTenant,
+		audit:         auditWriter,
+		swaps:         swaps,
 	}
 }
 
@@ -180,6 +196,16 @@ func (h *Handler) handleAckAction(r *http.Request, ic goslack.InteractionCallbac
 	}
 	defer conn.Release()
 
+	actor, msg, err := h.actingUser(r.Context(), conn, ic.User.ID, auth.RoleEngineer, "acknowledge alerts")
+	if err != nil {
+		h.logger.Error("resolving slack user for ack", "error", err, "user", ic.User.ID)
+		return
+	}
+	if msg != "" {
+		_ = h.notifier.PostEphemeral(r.Context(), ic.Channel.ID, ic.User.ID, msg)
+		return
+	}
+
 	alert, err := q.GetAlert(r.Context(), alertID)
 	if err != nil {
 		h.logger.Error("getting alert for ack", "error", err, "alert_id", alertID)
@@ -194,12 +220,15 @@ func (h *Handler) handleAckAction(r *http.Request, ic goslack.InteractionCallbac
 
 	// Acknowledge the alert.
 	_, err = q.AcknowledgeAlert(r.Context(), db.AcknowledgeAlertParams{
-		ID: alertID,
+		ID:             alertID,
+		AcknowledgedBy: pgtype.UUID{Bytes: actor.ID, Valid: true},
 	})
 	if err != nil {
 		h.logger.Error("acknowledging alert from slack", "error", err, "alert_id", alertID)
 		return
 	}
+	detail, _ := json.Marshal(map[string]string{"title": alert.Title})
+	h.auditChat(actor.ID, "acknowledge", "alert", alertID, detail)
 
 	// Post thread reply using the message_mappings table.
 	var channelID2, messageID string
@@ -218,6 +247,7 @@ func (h *Handler) handleAckAction(r *http.Request, ic goslack.InteractionCallbac
 	h.logger.Info("alert acknowledged via slack",
 		"alert_id", alertID,
 		"user", ic.User.ID,
+		"user_id", actor.ID,
 	)
 }
 
@@ -265,12 +295,17 @@ func (h *Handler) handleCreateIncidentModalAction(r *http.Request, ic goslack.In
 func (h *Handler) handleViewSubmission(w http.ResponseWriter, r *http.Request, ic goslack.InteractionCallback) {
 	switch ic.View.CallbackID {
 	case "create_incident_submit":
-		h.handleCreateIncidentSubmit(r, ic)
+		if msg := h.handleCreateIncidentSubmit(r, ic); msg != "" {
+			respondJSON(w, map[string]any{"response_action": "errors", "errors": map[string]string{"title": msg}})
+			return
+		}
 	}
 	w.WriteHeader(http.StatusOK)
 }
 
-func (h *Handler) handleCreateIncidentSubmit(r *http.Request, ic goslack.InteractionCallback) {
+// handleCreateIncidentSubmit creates a KB entry from the modal. A non-empty
+// result is shown to the user as a validation error on the form.
+func (h *Handler) handleCreateIncidentSubmit(r *http.Request, ic goslack.InteractionCallback) string {
 	values := ic.View.State.Values
 
 	title := values["title"]["title_input"].Value
@@ -289,22 +324,35 @@ func (h *Handler) handleCreateIncidentSubmit(r *http.Request, ic goslack.Interac
 	conn, q, err := h.acquireTenantConn(r)
 	if err != nil {
 		h.logger.Error("acquiring tenant connection for incident creation", "error", err)
-		return
+		return "Internal error."
 	}
 	defer conn.Release()
 
-	_, err = q.CreateIncident(r.Context(), db.CreateIncidentParams{
-		Title:    title,
-		Severity: severityOpt,
-		Symptoms: &symptoms,
-		Solution: &solution,
+	actor, msg, err := h.actingUser(r.Context(), conn, ic.User.ID, auth.RoleEngineer, "create knowledge base entries")
+	if err != nil {
+		h.logger.Error("resolving slack user for incident creation", "error", err, "user", ic.User.ID)
+		return "Internal error."
+	}
+	if msg != "" {
+		return msg
+	}
+
+	inc, err := q.CreateIncident(r.Context(), db.CreateIncidentParams{
+		Title:     title,
+		Severity:  severityOpt,
+		Symptoms:  &symptoms,
+		Solution:  &solution,
+		CreatedBy: pgtype.UUID{Bytes: actor.ID, Valid: true},
 	})
 	if err != nil {
 		h.logger.Error("creating incident from slack modal", "error", err)
-		return
+		return "Failed to create the knowledge base entry."
 	}
+	detail, _ := json.Marshal(map[string]string{"title": title})
+	h.auditChat(actor.ID, "create", "incident", inc.ID, detail)
 
 	h.logger.Info("incident created successfully from slack", "title", title)
+	return ""
 }
 
 // --- Command handler ---
@@ -327,7 +375,7 @@ func (h *Handler) handleCommands(w http.ResponseWriter, r *http.Request) {
 	if len(parts) == 0 {
 		respondJSON(w, map[string]string{
 			"response_type": "ephemeral",
-			"text":          "Usage: /nightowl <search|oncall|ack|resolve|roster> [args]",
+			"text":          "Usage: /nightowl <search|oncall|ack|resolve|roster|swap|link> [args]",
 		})
 		return
 	}
@@ -346,10 +394,14 @@ func (h *Handler) handleCommands(w http.ResponseWriter, r *http.Request) {
 		h.handleResolveCommand(w, r, cmd, args)
 	case "roster":
 		h.handleRosterCommand(w, r, cmd, args)
+	case "swap":
+		h.handleSwapCommand(w, r, cmd, args)
+	case "link":
+		h.handleLinkCommand(w, r, cmd, args)
 	default:
 		respondJSON(w, map[string]string{
 			"response_type": "ephemeral",
-			"text":          "Unknown command: " + subcommand + ". Available: search, oncall, ack, resolve, roster",
+			"text":          "Unknown command: " + subcommand + ". Available: search, oncall, ack, resolve, roster, swap, link",
 		})
 	}
 }
@@ -401,40 +453,116 @@ func (h *Handler) handleSearchCommand(w http.ResponseWriter, r *http.Request, cm
 }
 
 func (h *Handler) handleOnCallCommand(w http.ResponseWriter, r *http.Request, cmd goslack.SlashCommand, args []string) {
-	conn, q, err := h.acquireTenantConn(r)
+	conn, _, err := h.acquireTenantConn(r)
 	if err != nil {
 		respondJSON(w, map[string]string{"response_type": "ephemeral", "text": "Internal error."})
 		return
 	}
 	defer conn.Release()
 
-	rosters, err := q.ListRosters(r.Context())
+	svc := roster.NewService(conn, h.logger)
+	rosters, err := svc.ListRosters(r.Context())
 	if err != nil {
 		h.logger.Error("listing rosters from slack", "error", err)
 		respondJSON(w, map[string]string{"response_type": "ephemeral", "text": "Failed to list rosters."})
 		return
 	}
 
-	// If a roster name is specified, filter.
-	filterName := ""
-	if len(args) > 0 {
-		filterName = strings.ToLower(strings.Join(args, " "))
-	}
+	// Optional roster name filter and "at <time>" lookup.
+	filter, atArg := roster.SplitOnCallArgs(args)
+	filterName := strings.ToLower(filter)
 
+	now := time.Now()
+	mentions := newMentionCache(roster.NewStore(conn), NewProvider(h.notifier, h.logger))
 	var entries []OnCallEntry
-	for _, roster := range rosters {
-		if filterName != "" && !strings.Contains(strings.ToLower(roster.Name), filterName) {
+	for _, ro := range rosters {
+		if !ro.IsActive {
 			continue
 		}
-		entries = append(entries, OnCallEntry{
-			RosterName:  roster.Name,
-			UserDisplay: "calculating...", // placeholder — requires full on-call logic
-			Timezone:    roster.Timezone,
-		})
+		if filterName != "" && !strings.Contains(strings.ToLower(ro.Name), filterName) {
+			continue
+		}
+		loc, err := time.LoadLocation(ro.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		at := now
+		if atArg != "" {
+			if at, err = roster.ParseChatTime(atArg, loc, now); err != nil {
+				respondJSON(w, map[string]string{"response_type": "ephemeral", "text": "Usage: /nightowl oncall [roster] [at <time>]: " + err.Error()})
+				return
+			}
+		}
+		sum, err := svc.GetOnCallSummary(r.Context(), ro, at)
+		if err != nil {
+			h.logger.Error("resolving on-call from slack", "error", err, "roster_id", ro.ID)
+			continue
+		}
+		entries = append(entries, h.onCallEntry(r.Context(), mentions, sum, at, loc))
 	}
 
-	blocks := OnCallBlocks(entries)
-	respondBlocks(w, "ephemeral", blocks)
+	if atArg != "" && len(entries) > 0 {
+		respondBlocks(w, "ephemeral", OnCallAtBlocks(entries, atArg))
+		return
+	}
+	respondBlocks(w, "ephemeral", OnCallBlocks(entries))
+}
+
+// onCallEntry renders a roster's on-call summary with Slack mentions.
+func (h *Handler) onCallEntry(ctx context.Context, mentions *mentionCache, sum *roster.OnCallSummary, at time.Time, loc *time.Location) OnCallEntry {
+	oc := sum.Current
+	display := "Unassigned"
+	if oc.Primary != nil {
+		display = mentions.mention(ctx, *oc.Primary)
+	}
+	if oc.DelegatedFrom != nil {
+		display += " (via " + oc.RosterName + ")"
+	}
+	entry := OnCallEntry{
+		RosterName:  sum.Roster.Name,
+		UserDisplay: display,
+		Timezone:    sum.Roster.Timezone,
+		IsOverride:  oc.Source == "override",
+	}
+	if oc.Secondary != nil {
+		entry.Secondary = mentions.mention(ctx, *oc.Secondary)
+	}
+	if sum.HandoffAt != nil {
+		entry.Handoff = fmt.Sprintf("in %s (%s)", roster.FormatRemaining(sum.HandoffAt.Sub(at)),
+			sum.HandoffAt.In(loc).Format("Mon Jan 2 15:04 MST"))
+		entry.Next = "nobody"
+		if sum.Next != nil && sum.Next.Primary != nil {
+			entry.Next = mentions.mention(ctx, *sum.Next.Primary)
+		}
+	}
+	return entry
+}
+
+// mentionCache turns NightOwl users into Slack mentions, looking each user
+// up by email at most once per command.
+type mentionCache struct {
+	store    *roster.Store
+	provider *Provider
+	cache    map[uuid.UUID]string
+}
+
+func newMentionCache(store *roster.Store, provider *Provider) *mentionCache {
+	return &mentionCache{store: store, provider: provider, cache: make(map[uuid.UUID]string)}
+}
+
+// mention returns "<@U123>" for users found in Slack, else the display name.
+func (m *mentionCache) mention(ctx context.Context, e roster.OnCallEntry) string {
+	if v, ok := m.cache[e.UserID]; ok {
+		return v
+	}
+	v := e.DisplayName
+	if emails, err := m.store.UserEmails(ctx, []uuid.UUID{e.UserID}); err == nil && emails[e.UserID] != "" {
+		if id, err := m.provider.LookupUser(ctx, emails[e.UserID]); err == nil && id != "" {
+			v = "<@" + id + ">"
+		}
+	}
+	m.cache[e.UserID] = v
+	return v
 }
 
 func (h *Handler) handleAckCommand(w http.ResponseWriter, r *http.Request, cmd goslack.SlashCommand, args []string) {
@@ -456,14 +584,31 @@ func (h *Handler) handleAckCommand(w http.ResponseWriter, r *http.Request, cmd g
 	}
 	defer conn.Release()
 
-	_, err = q.AcknowledgeAlert(r.Context(), db.AcknowledgeAlertParams{
-		ID: alertID,
-	})
+	actor, msg, err := h.actingUser(r.Context(), conn, cmd.UserID, auth.RoleEngineer, "acknowledge alerts")
 	if err != nil {
+		h.logger.Error("resolving slack user for ack", "error", err, "user", cmd.UserID)
+		respondJSON(w, map[string]string{"response_type": "ephemeral", "text": "Internal error."})
+		return
+	}
+	if msg != "" {
+		respondJSON(w, map[string]string{"response_type": "ephemeral", "text": msg})
+		return
+	}
+
+	row, err := q.AcknowledgeAlert(r.Context(), db.AcknowledgeAlertParams{
+		ID:             alertID,
+		AcknowledgedBy: pgtype.UUID{Bytes: actor.ID, Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		respondJSON(w, map[string]string{"response_type": "ephemeral", "text": "Alert not found."})
+		return
+	} else if err != nil {
 		h.logger.Error("acknowledging alert from slash command", "error", err, "alert_id", alertID)
 		respondJSON(w, map[string]string{"response_type": "ephemeral", "text": "Failed to acknowledge alert."})
 		return
 	}
+	detail, _ := json.Marshal(map[string]string{"title": row.Title})
+	h.auditChat(actor.ID, "acknowledge", "alert", alertID, detail)
 
 	respondJSON(w, map[string]string{
 		"response_type": "in_channel",
@@ -490,20 +635,39 @@ func (h *Handler) handleResolveCommand(w http.ResponseWriter, r *http.Request, c
 	}
 	defer conn.Release()
 
-	_, err = q.ResolveAlert(r.Context(), db.ResolveAlertParams{
-		ID: alertID,
-	})
+	actor, msg, err := h.actingUser(r.Context(), conn, cmd.UserID, auth.RoleEngineer, "resolve alerts")
 	if err != nil {
+		h.logger.Error("resolving slack user for resolve", "error", err, "user", cmd.UserID)
+		respondJSON(w, map[string]string{"response_type": "ephemeral", "text": "Internal error."})
+		return
+	}
+	if msg != "" {
+		respondJSON(w, map[string]string{"response_type": "ephemeral", "text": msg})
+		return
+	}
+
+	row, err := q.ResolveAlert(r.Context(), db.ResolveAlertParams{
+		ID:         alertID,
+		ResolvedBy: pgtype.UUID{Bytes: actor.ID, Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		respondJSON(w, map[string]string{"response_type": "ephemeral", "text": "Alert not found."})
+		return
+	} else if err != nil {
 		h.logger.Error("resolving alert from slash command", "error", err, "alert_id", alertID)
 		respondJSON(w, map[string]string{"response_type": "ephemeral", "text": "Failed to resolve alert."})
 		return
 	}
 
 	text := "✅ Alert `" + alertID.String() + "` resolved by <@" + cmd.UserID + ">."
+	fields := map[string]string{"title": row.Title}
 	if len(args) > 1 {
 		notes := strings.Join(args[1:], " ")
 		text += " Notes: " + notes
+		fields["notes"] = notes
 	}
+	detail, _ := json.Marshal(fields)
+	h.auditChat(actor.ID, "resolve", "alert", alertID, detail)
 
 	respondJSON(w, map[string]string{
 		"response_type": "in_channel",
@@ -550,6 +714,125 @@ func (h *Handler) handleRosterCommand(w http.ResponseWriter, r *http.Request, cm
 	})
 }
 
+func (h *Handler) handleSwapCommand(w http.ResponseWriter, r *http.Request, cmd goslack.SlashCommand, args []string) {
+	conn, _, err := h.acquireTenantConn(r)
+	if err != nil {
+		respondJSON(w, map[string]string{"response_type": "ephemeral", "text": "Internal error."})
+		return
+	}
+	defer conn.Release()
+
+	actor, msg, err := h.actingUser(r.Context(), conn, cmd.UserID, auth.RoleEngineer, "manage shift swaps")
+	if err != nil {
+		h.logger.Error("resolving slack user for swap", "error", err, "user", cmd.UserID)
+		respondJSON(w, map[string]string{"response_type": "ephemeral", "text": "Internal error."})
+		return
+	}
+	if msg != "" {
+		respondJSON(w, map[string]string{"response_type": "ephemeral", "text": msg})
+		return
+	}
+
+	svc := roster.NewService(conn, h.logger)
+	res, err := svc.RunSwapCommand(r.Context(), actor.ID, args)
+	if err != nil {
+		h.logger.Error("running swap command from slack", "error", err)
+		respondJSON(w, map[string]string{"response_type": "ephemeral", "text": "Swap command failed."})
+		return
+	}
+	if res.Request != nil {
+		h.auditChat(actor.ID, res.Action, "roster", res.Request.RosterID, roster.SwapAuditDetail(*res.Request))
+		h.swaps.Notify(r.Context(), conn, res.Event, *res.Request)
+	}
+
+	respondJSON(w, map[string]string{"response_type": "ephemeral", "text": res.Text})
+}
+
+func (h *Handler) handleLinkCommand(w http.ResponseWriter, r *http.Request, cmd goslack.SlashCommand, args []string) {
+	conn, _, err := h.acquireTenantConn(r)
+	if err != nil {
+		respondJSON(w, map[string]string{"response_type": "ephemeral", "text": "Internal error."})
+		return
+	}
+	defer conn.Release()
+
+	svc := user.NewService(conn, h.logger)
+	if len(args) == 0 {
+		u, err := h.chatUser(r.Context(), conn, cmd.UserID)
+		switch {
+		case errors.Is(err, user.ErrNotLinked):
+			respondJSON(w, map[string]string{"response_type": "ephemeral", "text": user.LinkInstructions})
+		case err != nil:
+			h.logger.Error("resolving slack user for link", "error", err, "user", cmd.UserID)
+			respondJSON(w, map[string]string{"response_type": "ephemeral", "text": "Internal error."})
+		default:
+			respondJSON(w, map[string]string{"response_type": "ephemeral",
+				"text": "Your Slack account is linked to NightOwl user *" + u.DisplayName + "* (" + u.Email + ")."})
+		}
+		return
+	}
+
+	u, err := svc.LinkChatAccount(r.Context(), user.ChatSlack, cmd.UserID, args[0])
+	if errors.Is(err, user.ErrInvalidLinkCode) {
+		respondJSON(w, map[string]string{"response_type": "ephemeral", "text": "That link code is invalid or has expired."})
+		return
+	} else if err != nil {
+		h.logger.Error("linking slack account", "error", err, "user", cmd.UserID)
+		respondJSON(w, map[string]string{"response_type": "ephemeral", "text": "Failed to link your account."})
+		return
+	}
+	detail, _ := json.Marshal(map[string]string{"chat_user_id": cmd.UserID})
+	h.auditChat(u.ID, "link_chat_account", "user", u.ID, detail)
+
+	respondJSON(w, map[string]string{"response_type": "ephemeral",
+		"text": "Linked your Slack account to NightOwl user *" + u.DisplayName + "*."})
+}
+
+// --- Identity ---
+
+// chatUser resolves the NightOwl user behind a Slack user, by linked Slack
+// ID or else by the email on their Slack profile.
+func (h *Handler) chatUser(ctx context.Context, dbtx db.DBTX, slackUserID string) (user.UserRow, error) {
+	return user.NewService(dbtx, h.logger).ResolveChatUser(ctx, user.ChatSlack, slackUserID,
+		func(ctx context.Context) (string, error) { return h.notifier.UserEmail(ctx, slackUserID) })
+}
+
+// actingUser resolves the Slack caller and checks they hold at least
+// minRole. A non-empty msg explains to the caller why they can't proceed.
+func (h *Handler) actingUser(ctx context.Context, dbtx db.DBTX, slackUserID, minRole, what string) (u user.UserRow, msg string, err error) {
+	u, err = h.chatUser(ctx, dbtx, slackUserID)
+	if errors.Is(err, user.ErrNotLinked) {
+		return u, "Your Slack account is not linked to a NightOwl user. " + user.LinkInstructions, nil
+	}
+	if err != nil {
+		return u, "", err
+	}
+	if !u.HasRole(minRole) {
+		return u, "Your NightOwl role (" + u.Role + ") is not allowed to " + what + ".", nil
+	}
+	return u, "", nil
+}
+
+// auditChat records an action taken from Slack on behalf of a linked user.
+// detail must be a JSON object; "source": "slack" is added to it.
+func (h *Handler) auditChat(userID uuid.UUID, action, resource string, resourceID uuid.UUID, detail json.RawMessage) {
+	if h.audit == nil {
+		return
+	}
+	fields := map[string]any{}
+	_ = json.Unmarshal(detail, &fields)
+	fields["source"] = "slack"
+	detail, _ = json.Marshal(fields)
+	h.audit.Log(audit.Entry{
+		TenantSchema: tenant.SchemaName(h.defaultTenant),
+		UserID:       pgtype.UUID{Bytes: userID, Valid: true},
+		Action:       action,
+		Resource:     resource,
+		ResourceID:   resourceID,
+		Detail:       detail,
+	})
+}
+
 // --- Helpers ---
 
 func respondJSON(w http.ResponseWriter, v any) {
@@ -20,6 +20,8 @@ func newTestRouter() chi.Router {
 		logger,
 		"", // no signing secret (dev mode)
 		"devco",
+		nil,
+		nil,
 	)
 	router := chi.NewRouter()
 	router.Mount("/slack", h.Routes())
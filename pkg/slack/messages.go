@@ -162,6 +162,16 @@ func SearchResultBlocks(query string, results []SearchResult) []goslack.Block {
 
 // OnCallBlocks builds blocks showing who is currently on-call.
 func OnCallBlocks(entries []OnCallEntry) []goslack.Block {
+	return onCallBlocks("Current On-Call", entries)
+}
+
+// OnCallAtBlocks builds blocks showing who is on-call at the time the user
+// asked about, labelled as they wrote it.
+func OnCallAtBlocks(entries []OnCallEntry, at string) []goslack.Block {
+	return onCallBlocks("On-Call at "+at, entries)
+}
+
+func onCallBlocks(title string, entries []OnCallEntry) []goslack.Block {
 	if len(entries) == 0 {
 		return []goslack.Block{
 			goslack.NewSectionBlock(
@@ -173,7 +183,7 @@ func OnCallBlocks(entries []OnCallEntry) []goslack.Block {
 
 	blocks := []goslack.Block{
 		goslack.NewHeaderBlock(
-			goslack.NewTextBlockObject(goslack.PlainTextType, "Current On-Call", true, false),
+			goslack.NewTextBlockObject(goslack.PlainTextType, title, true, false),
 		),
 	}
 
@@ -185,6 +195,15 @@ func OnCallBlocks(entries []OnCallEntry) []goslack.Block {
 		if e.IsOverride {
 			text += " _(override)_"
 		}
+		if e.Secondary != "" {
+			text += "\nSecondary: " + e.Secondary
+		}
+		if e.Handoff != "" {
+			text += "\nHandoff " + e.Handoff
+			if e.Next != "" {
+				text += " to " + e.Next
+			}
+		}
 		section := goslack.NewSectionBlock(
 			goslack.NewTextBlockObject(goslack.MarkdownType, text, false, false),
 			nil, nil,
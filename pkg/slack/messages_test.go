@@ -1,7 +1,10 @@
 package slack
 
 import (
+	"strings"
 	"testing"
+
+	goslack "github.com/slack-go/slack"
 )
 
 func TestSeverityEmoji(t *testing.T) {
@@ -149,6 +152,27 @@ func TestOnCallBlocks_WithEntries(t *testing.T) {
 	}
 }
 
+func TestOnCallAtBlocks_Handoff(t *testing.T) {
+	entries := []OnCallEntry{
+		{RosterName: "Primary", UserDisplay: "<@U1>", Secondary: "<@U2>", Handoff: "in 3h (Mon Mar 2 18:00 UTC)", Next: "<@U3>"},
+	}
+
+	blocks := OnCallAtBlocks(entries, "tomorrow 09:00")
+	if len(blocks) != 2 {
+		t.Fatalf("expected 2 blocks, got %d", len(blocks))
+	}
+	header := blocks[0].(*goslack.HeaderBlock)
+	if header.Text.Text != "On-Call at tomorrow 09:00" {
+		t.Errorf("header = %q", header.Text.Text)
+	}
+	section := blocks[1].(*goslack.SectionBlock)
+	for _, want := range []string{"Secondary: <@U2>", "Handoff in 3h (Mon Mar 2 18:00 UTC) to <@U3>"} {
+		if !strings.Contains(section.Text.Text, want) {
+			t.Errorf("section %q missing %q", section.Text.Text, want)
+		}
+	}
+}
+
 func TestCreateIncidentModal(t *testing.T) {
 	modal := CreateIncidentModal("alert-123", "Pod Crash", "crashing repeatedly", "critical")
 
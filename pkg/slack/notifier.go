@@ -160,3 +160,16 @@ func (n *Notifier) SendDM(ctx context.Context, slackUserID, text string) error {
 	}
 	return nil
 }
+
+// UserEmail returns the email on a Slack user's profile, or "" when the
+// notifier is disabled.
+func (n *Notifier) UserEmail(ctx context.Context, slackUserID string) (string, error) {
+	if n.client == nil {
+		return "", nil
+	}
+	user, err := n.client.GetUserInfoContext(ctx, slackUserID)
+	if err != nil {
+		return "", fmt.Errorf("getting slack user info: %w", err)
+	}
+	return user.Profile.Email, nil
+}
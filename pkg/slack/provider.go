@@ -120,6 +120,31 @@ func (p *Provider) PostHandoff(ctx context.Context, msg messaging.HandoffMessage
 	return err
 }
 
+func (p *Provider) PostReport(ctx context.Context, msg messaging.ReportMessage) error {
+	text := fmt.Sprintf("*%s*", msg.Title)
+	if msg.Summary != "" {
+		text += "\n" + msg.Summary
+	}
+	for _, s := range msg.Sections {
+		text += fmt.Sprintf("\n\n*%s*\n%s", s.Title, s.Body)
+	}
+
+	if p.notifier.client == nil {
+		return nil
+	}
+	channel := msg.ChannelID
+	if channel == "" {
+		channel = p.notifier.channel
+	}
+	if channel == "" {
+		return nil
+	}
+
+	_, _, err := p.notifier.client.PostMessageContext(ctx, channel,
+		goslack.MsgOptionText(text, false))
+	return err
+}
+
 func (p *Provider) PostResolutionPrompt(ctx context.Context, msg messaging.ResolutionPromptMessage) error {
 	text := fmt.Sprintf("Alert *%s* was resolved by %s.", msg.Title, msg.ResolvedBy)
 	if msg.Resolution != "" {
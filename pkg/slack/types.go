@@ -26,6 +26,9 @@ type SearchResult struct {
 type OnCallEntry struct {
 	RosterName  string
 	UserDisplay string
+	Secondary   string
 	Timezone    string
 	IsOverride  bool
+	Handoff     string // e.g. "in 3h 20m (Mon 09:00 CET)"; empty when nothing is scheduled
+	Next        string
 }
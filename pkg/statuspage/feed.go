@@ -0,0 +1,163 @@
+package statuspage
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	PubDate     string  `xml:"pubDate"`
+	GUID        rssGUID `xml:"guid"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Link      atomLink    `xml:"link"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// incidentURL links to an incident on its page.
+func incidentURL(pageURL string, inc Incident) string {
+	return pageURL + "#incident-" + inc.ID.String()
+}
+
+// timelineHTML renders an incident's updates, newest first, as HTML for
+// feed readers.
+func timelineHTML(inc Incident) string {
+	var b strings.Builder
+	for _, u := range inc.Updates {
+		fmt.Fprintf(&b, "<p><strong>%s</strong> - %s<br><small>%s</small></p>",
+			html.EscapeString(statusLabel(u.Status)),
+			strings.ReplaceAll(html.EscapeString(u.Body), "\n", "<br>"),
+			u.CreatedAt.UTC().Format("Jan 2, 15:04 MST"))
+	}
+	return b.String()
+}
+
+// feedUpdated returns when a page or any of its incidents last changed.
+func feedUpdated(p Page, incidents []Incident) time.Time {
+	updated := p.UpdatedAt
+	for _, inc := range incidents {
+		if inc.UpdatedAt.After(updated) {
+			updated = inc.UpdatedAt
+		}
+	}
+	return updated
+}
+
+// renderRSS renders a page's incidents as an RSS 2.0 feed, one item per
+// incident with its timeline as the description.
+func renderRSS(p Page, pageURL string, incidents []Incident) ([]byte, error) {
+	feed := rssFeed{Version: "2.0", Channel: rssChannel{
+		Title:         p.Name + " status",
+		Link:          pageURL,
+		Description:   "Incident history for " + p.Name,
+		LastBuildDate: feedUpdated(p, incidents).UTC().Format(time.RFC1123Z),
+	}}
+	for _, inc := range incidents {
+		url := incidentURL(pageURL, inc)
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       inc.Title,
+			Link:        url,
+			Description: timelineHTML(inc),
+			PubDate:     inc.CreatedAt.UTC().Format(time.RFC1123Z),
+			GUID:        rssGUID{IsPermaLink: true, Value: url},
+		})
+	}
+	return marshalFeed(feed)
+}
+
+// renderAtom renders a page's incidents as an Atom feed, one entry per
+// incident with its timeline as the content.
+func renderAtom(p Page, pageURL, feedURL string, incidents []Incident) ([]byte, error) {
+	feed := atomFeed{
+		ID:      pageURL,
+		Title:   p.Name + " status",
+		Updated: feedUpdated(p, incidents).UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: pageURL, Rel: "alternate", Type: "text/html"},
+			{Href: feedURL, Rel: "self", Type: "application/atom+xml"},
+		},
+	}
+	for _, inc := range incidents {
+		url := incidentURL(pageURL, inc)
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:        url,
+			Title:     inc.Title,
+			Published: inc.CreatedAt.UTC().Format(time.RFC3339),
+			Updated:   inc.UpdatedAt.UTC().Format(time.RFC3339),
+			Link:      atomLink{Href: url, Rel: "alternate", Type: "text/html"},
+			Content:   atomContent{Type: "html", Body: timelineHTML(inc)},
+		})
+	}
+	return marshalFeed(feed)
+}
+
+func marshalFeed(feed any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		return nil, fmt.Errorf("encoding feed: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// statusLabel turns a status such as "partial_outage" into "Partial outage".
+func statusLabel(status string) string {
+	if status == "" {
+		return ""
+	}
+	s := strings.ReplaceAll(status, "_", " ")
+	return strings.ToUpper(s[:1]) + s[1:]
+}
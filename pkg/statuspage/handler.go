@@ -0,0 +1,524 @@
+package statuspage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wisbric/core/pkg/auth"
+	"github.com/wisbric/core/pkg/httpserver"
+
+	"github.com/wisbric/nightowl/internal/audit"
+	"github.com/wisbric/nightowl/pkg/mailer"
+	"github.com/wisbric/nightowl/pkg/tenant"
+)
+
+// Handler provides HTTP handlers for managing status pages and for serving
+// public pages and their feeds.
+type Handler struct {
+	logger    *slog.Logger
+	audit     *audit.Writer
+	publicURL string
+	tokenKey  []byte
+
+	// Mailer, if set, sends subscription confirmations. Without it public
+	// pages do not offer subscriptions.
+	Mailer mailer.Sender
+	// Limiter, if set, limits subscription requests per client address.
+	Limiter auth.LoginRateLimiter
+	// TrustedProxies are the proxies whose X-Forwarded-For header names the
+	// client for rate limiting.
+	TrustedProxies []netip.Prefix
+}
+
+// NewHandler creates a Handler. publicURL is the externally reachable base
+// URL used for links in feeds and mail, and tokenKey signs the links
+// mailed to subscribers; it must match the Notifier's.
+func NewHandler(logger *slog.Logger, audit *audit.Writer, publicURL string, tokenKey []byte) *Handler {
+	return &Handler{logger: logger, audit: audit, publicURL: strings.TrimRight(publicURL, "/"), tokenKey: tokenKey}
+}
+
+// Routes returns a chi.Router with the management routes mounted. Anyone
+// may read pages; engineers and above post incidents and set component
+// status; admins manage pages, components and subscribers.
+func (h *Handler) Routes() chi.Router {
+	admin := auth.RequireRole(auth.RoleAdmin)
+	responder := auth.RequireMinRole(auth.RoleEngineer)
+
+	r := chi.NewRouter()
+	r.Get("/", h.handleListPages)
+	r.With(admin).Post("/", h.handleCreatePage)
+	r.Route("/{id}", func(r chi.Router) {
+		r.Get("/", h.handleGetPage)
+		r.With(admin).Put("/", h.handleUpdatePage)
+		r.With(admin).Delete("/", h.handleDeletePage)
+		r.Get("/summary", h.handleSummary)
+
+		r.Get("/components", h.handleListComponents)
+		r.With(admin).Post("/components", h.handleCreateComponent)
+		r.With(admin).Put("/components/{componentID}", h.handleUpdateComponent)
+		r.With(admin).Delete("/components/{componentID}", h.handleDeleteComponent)
+		r.With(responder).Post("/components/{componentID}/status", h.handleSetComponentStatus)
+
+		r.Get("/incidents", h.handleListIncidents)
+		r.With(responder).Post("/incidents", h.handleCreateIncident)
+		r.Get("/incidents/{incidentID}", h.handleGetIncident)
+		r.With(responder).Put("/incidents/{incidentID}", h.handleEditIncident)
+		r.With(admin).Delete("/incidents/{incidentID}", h.handleDeleteIncident)
+		r.With(responder).Post("/incidents/{incidentID}/updates", h.handlePostUpdate)
+
+		r.With(admin).Get("/subscribers", h.handleListSubscribers)
+		r.With(admin).Post("/subscribers", h.handleAddSubscriber)
+		r.With(admin).Delete("/subscribers/{subscriberID}", h.handleDeleteSubscriber)
+	})
+	return r
+}
+
+func (h *Handler) store(r *http.Request) *Store {
+	return NewStore(tenant.ConnFromContext(r.Context()))
+}
+
+func parseID(w http.ResponseWriter, r *http.Request, param, what string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, param))
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid "+what+" ID")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// page loads the page named in the URL, responding with an error if it
+// cannot.
+func (h *Handler) page(w http.ResponseWriter, r *http.Request) (Page, bool) {
+	id, ok := parseID(w, r, "id", "status page")
+	if !ok {
+		return Page{}, false
+	}
+	p, err := h.store(r).GetPage(r.Context(), id)
+	if err != nil {
+		h.respondErr(w, "getting status page", err)
+		return Page{}, false
+	}
+	return p, true
+}
+
+func createdBy(r *http.Request) pgtype.UUID {
+	if id := auth.FromContext(r.Context()); id != nil && id.UserID != nil {
+		return pgtype.UUID{Bytes: *id.UserID, Valid: true}
+	}
+	return pgtype.UUID{}
+}
+
+// pageURL returns the public URL of a page of the request's tenant.
+func (h *Handler) pageURL(ctx context.Context, p Page) string {
+	return h.publicURL + PagePath(tenantSlug(ctx), p.Slug)
+}
+
+// summary builds the current summary of a page.
+func (h *Handler) summary(ctx context.Context, store *Store, p Page) (Summary, error) {
+	components, err := store.ListComponents(ctx, p.ID)
+	if err != nil {
+		return Summary{}, err
+	}
+	incidents, err := store.currentIncidents(ctx, p.ID, time.Now().Add(-recentIncidents))
+	if err != nil {
+		return Summary{}, err
+	}
+	return buildSummary(p, h.pageURL(ctx, p), components, incidents), nil
+}
+
+func validSlug(w http.ResponseWriter, slug string) bool {
+	if !slugPattern.MatchString(slug) {
+		httpserver.RespondError(w, http.StatusUnprocessableEntity, "validation_error",
+			"slug must start with a lowercase letter or digit and contain only lowercase letters, digits and hyphens")
+		return false
+	}
+	return true
+}
+
+func (h *Handler) handleListPages(w http.ResponseWriter, r *http.Request) {
+	pages, err := h.store(r).ListPages(r.Context())
+	if err != nil {
+		h.respondErr(w, "listing status pages", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]any{"pages": pages, "count": len(pages)})
+}
+
+func (h *Handler) handleCreatePage(w http.ResponseWriter, r *http.Request) {
+	var req PageRequest
+	if !httpserver.DecodeAndValidate(w, r, &req) || !validSlug(w, req.Slug) {
+		return
+	}
+	p, err := h.store(r).CreatePage(r.Context(), req)
+	if err != nil {
+		h.respondErr(w, "creating status page", err)
+		return
+	}
+	h.log(r, "create", "status_page", p.ID, map[string]any{"slug": p.Slug, "public": p.Public})
+	httpserver.Respond(w, http.StatusCreated, p)
+}
+
+func (h *Handler) handleGetPage(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.page(w, r)
+	if !ok {
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, p)
+}
+
+func (h *Handler) handleUpdatePage(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseID(w, r, "id", "status page")
+	if !ok {
+		return
+	}
+	var req PageRequest
+	if !httpserver.DecodeAndValidate(w, r, &req) || !validSlug(w, req.Slug) {
+		return
+	}
+	p, err := h.store(r).UpdatePage(r.Context(), id, req)
+	if err != nil {
+		h.respondErr(w, "updating status page", err)
+		return
+	}
+	h.log(r, "update", "status_page", p.ID, map[string]any{"slug": p.Slug, "public": p.Public})
+	httpserver.Respond(w, http.StatusOK, p)
+}
+
+func (h *Handler) handleDeletePage(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseID(w, r, "id", "status page")
+	if !ok {
+		return
+	}
+	p, err := h.store(r).DeletePage(r.Context(), id)
+	if err != nil {
+		h.respondErr(w, "deleting status page", err)
+		return
+	}
+	h.log(r, "delete", "status_page", p.ID, map[string]any{"slug": p.Slug})
+	httpserver.Respond(w, http.StatusNoContent, nil)
+}
+
+// handleSummary returns a page's summary. It serves internal pages, which
+// have no public summary.json.
+func (h *Handler) handleSummary(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.page(w, r)
+	if !ok {
+		return
+	}
+	s, err := h.summary(r.Context(), h.store(r), p)
+	if err != nil {
+		h.respondErr(w, "building status page summary", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, s)
+}
+
+func (h *Handler) handleListComponents(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.page(w, r)
+	if !ok {
+		return
+	}
+	components, err := h.store(r).ListComponents(r.Context(), p.ID)
+	if err != nil {
+		h.respondErr(w, "listing status components", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]any{"components": components, "count": len(components)})
+}
+
+func (h *Handler) handleCreateComponent(w http.ResponseWriter, r *http.Request) {
+	pageID, ok := parseID(w, r, "id", "status page")
+	if !ok {
+		return
+	}
+	var req ComponentRequest
+	if !httpserver.DecodeAndValidate(w, r, &req) {
+		return
+	}
+	if _, err := h.store(r).GetPage(r.Context(), pageID); err != nil {
+		h.respondErr(w, "getting status page", err)
+		return
+	}
+	c, err := h.store(r).CreateComponent(r.Context(), pageID, req)
+	if err != nil {
+		h.respondErr(w, "creating status component", err)
+		return
+	}
+	h.log(r, "create", "status_component", c.ID, map[string]any{"page_id": pageID, "service_id": c.ServiceID})
+	httpserver.Respond(w, http.StatusCreated, c)
+}
+
+func (h *Handler) handleUpdateComponent(w http.ResponseWriter, r *http.Request) {
+	pageID, ok := parseID(w, r, "id", "status page")
+	if !ok {
+		return
+	}
+	id, ok := parseID(w, r, "componentID", "status component")
+	if !ok {
+		return
+	}
+	var req ComponentRequest
+	if !httpserver.DecodeAndValidate(w, r, &req) {
+		return
+	}
+	c, err := h.store(r).UpdateComponent(r.Context(), pageID, id, req)
+	if err != nil {
+		h.respondErr(w, "updating status component", err)
+		return
+	}
+	h.log(r, "update", "status_component", c.ID, map[string]any{"page_id": pageID, "service_id": c.ServiceID})
+	httpserver.Respond(w, http.StatusOK, c)
+}
+
+// handleSetComponentStatus sets a component's status by hand, overriding
+// the status derived from alerts until it is cleared.
+func (h *Handler) handleSetComponentStatus(w http.ResponseWriter, r *http.Request) {
+	pageID, ok := parseID(w, r, "id", "status page")
+	if !ok {
+		return
+	}
+	id, ok := parseID(w, r, "componentID", "status component")
+	if !ok {
+		return
+	}
+	var req ComponentStatusRequest
+	if !httpserver.DecodeAndValidate(w, r, &req) {
+		return
+	}
+	c, err := h.store(r).SetComponentStatus(r.Context(), pageID, id, req.Status)
+	if err != nil {
+		h.respondErr(w, "setting component status", err)
+		return
+	}
+	h.log(r, "set_status", "status_component", c.ID, map[string]any{"page_id": pageID, "status_override": req.Status})
+	httpserver.Respond(w, http.StatusOK, c)
+}
+
+func (h *Handler) handleDeleteComponent(w http.ResponseWriter, r *http.Request) {
+	pageID, ok := parseID(w, r, "id", "status page")
+	if !ok {
+		return
+	}
+	id, ok := parseID(w, r, "componentID", "status component")
+	if !ok {
+		return
+	}
+	if err := h.store(r).DeleteComponent(r.Context(), pageID, id); err != nil {
+		h.respondErr(w, "deleting status component", err)
+		return
+	}
+	h.log(r, "delete", "status_component", id, map[string]any{"page_id": pageID})
+	httpserver.Respond(w, http.StatusNoContent, nil)
+}
+
+func (h *Handler) handleListIncidents(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.page(w, r)
+	if !ok {
+		return
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 200 {
+			limit = n
+		}
+	}
+	incidents, err := h.store(r).ListIncidents(r.Context(), p.ID, limit)
+	if err != nil {
+		h.respondErr(w, "listing status incidents", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]any{"incidents": incidents, "count": len(incidents)})
+}
+
+// handleCreateIncident opens an incident. Its body is the first update and
+// is mailed to subscribers unless notify is false.
+func (h *Handler) handleCreateIncident(w http.ResponseWriter, r *http.Request) {
+	pageID, ok := parseID(w, r, "id", "status page")
+	if !ok {
+		return
+	}
+	var req IncidentRequest
+	if !httpserver.DecodeAndValidate(w, r, &req) {
+		return
+	}
+	if _, err := h.store(r).GetPage(r.Context(), pageID); err != nil {
+		h.respondErr(w, "getting status page", err)
+		return
+	}
+	inc, err := h.store(r).CreateIncident(r.Context(), pageID, req, createdBy(r))
+	if err != nil {
+		h.respondErr(w, "creating status incident", err)
+		return
+	}
+	h.log(r, "create", "status_incident", inc.ID, map[string]any{"page_id": pageID, "title": inc.Title, "status": inc.Status})
+	httpserver.Respond(w, http.StatusCreated, inc)
+}
+
+func (h *Handler) handleGetIncident(w http.ResponseWriter, r *http.Request) {
+	pageID, ok := parseID(w, r, "id", "status page")
+	if !ok {
+		return
+	}
+	id, ok := parseID(w, r, "incidentID", "status incident")
+	if !ok {
+		return
+	}
+	inc, err := h.store(r).GetIncident(r.Context(), pageID, id)
+	if err != nil {
+		h.respondErr(w, "getting status incident", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, inc)
+}
+
+func (h *Handler) handleEditIncident(w http.ResponseWriter, r *http.Request) {
+	pageID, ok := parseID(w, r, "id", "status page")
+	if !ok {
+		return
+	}
+	id, ok := parseID(w, r, "incidentID", "status incident")
+	if !ok {
+		return
+	}
+	var req IncidentEditRequest
+	if !httpserver.DecodeAndValidate(w, r, &req) {
+		return
+	}
+	inc, err := h.store(r).EditIncident(r.Context(), pageID, id, req)
+	if err != nil {
+		h.respondErr(w, "updating status incident", err)
+		return
+	}
+	h.log(r, "update", "status_incident", inc.ID, map[string]any{"page_id": pageID, "title": inc.Title, "impact": inc.Impact})
+	httpserver.Respond(w, http.StatusOK, inc)
+}
+
+// handlePostUpdate adds an update to an incident's timeline, moving the
+// incident to the update's status.
+func (h *Handler) handlePostUpdate(w http.ResponseWriter, r *http.Request) {
+	pageID, ok := parseID(w, r, "id", "status page")
+	if !ok {
+		return
+	}
+	id, ok := parseID(w, r, "incidentID", "status incident")
+	if !ok {
+		return
+	}
+	var req UpdateRequest
+	if !httpserver.DecodeAndValidate(w, r, &req) {
+		return
+	}
+	inc, err := h.store(r).PostUpdate(r.Context(), pageID, id, req, createdBy(r))
+	if err != nil {
+		h.respondErr(w, "posting incident update", err)
+		return
+	}
+	h.log(r, "post_update", "status_incident", inc.ID, map[string]any{"page_id": pageID, "status": inc.Status})
+	httpserver.Respond(w, http.StatusCreated, inc)
+}
+
+func (h *Handler) handleDeleteIncident(w http.ResponseWriter, r *http.Request) {
+	pageID, ok := parseID(w, r, "id", "status page")
+	if !ok {
+		return
+	}
+	id, ok := parseID(w, r, "incidentID", "status incident")
+	if !ok {
+		return
+	}
+	if err := h.store(r).DeleteIncident(r.Context(), pageID, id); err != nil {
+		h.respondErr(w, "deleting status incident", err)
+		return
+	}
+	h.log(r, "delete", "status_incident", id, map[string]any{"page_id": pageID})
+	httpserver.Respond(w, http.StatusNoContent, nil)
+}
+
+func (h *Handler) handleListSubscribers(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.page(w, r)
+	if !ok {
+		return
+	}
+	subs, err := h.store(r).ListSubscribers(r.Context(), p.ID)
+	if err != nil {
+		h.respondErr(w, "listing subscribers", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]any{"subscribers": subs, "count": len(subs)})
+}
+
+// handleAddSubscriber subscribes an address without asking it to confirm.
+func (h *Handler) handleAddSubscriber(w http.ResponseWriter, r *http.Request) {
+	pageID, ok := parseID(w, r, "id", "status page")
+	if !ok {
+		return
+	}
+	var req SubscribeRequest
+	if !httpserver.DecodeAndValidate(w, r, &req) {
+		return
+	}
+	if _, err := h.store(r).GetPage(r.Context(), pageID); err != nil {
+		h.respondErr(w, "getting status page", err)
+		return
+	}
+	sub, err := h.store(r).AddSubscriber(r.Context(), pageID, req.Email)
+	if err != nil {
+		h.respondErr(w, "adding subscriber", err)
+		return
+	}
+	h.log(r, "create", "status_subscriber", sub.ID, map[string]any{"page_id": pageID, "email": sub.Email})
+	httpserver.Respond(w, http.StatusCreated, sub)
+}
+
+func (h *Handler) handleDeleteSubscriber(w http.ResponseWriter, r *http.Request) {
+	pageID, ok := parseID(w, r, "id", "status page")
+	if !ok {
+		return
+	}
+	id, ok := parseID(w, r, "subscriberID", "subscriber")
+	if !ok {
+		return
+	}
+	sub, err := h.store(r).DeleteSubscriber(r.Context(), pageID, id)
+	if err != nil {
+		h.respondErr(w, "deleting subscriber", err)
+		return
+	}
+	h.log(r, "delete", "status_subscriber", sub.ID, map[string]any{"page_id": pageID, "email": sub.Email})
+	httpserver.Respond(w, http.StatusNoContent, nil)
+}
+
+func (h *Handler) log(r *http.Request, action, resource string, id uuid.UUID, detail map[string]any) {
+	if h.audit == nil {
+		return
+	}
+	b, _ := json.Marshal(detail)
+	h.audit.LogFromRequest(r, action, resource, id, b)
+}
+
+// respondErr maps status page errors to HTTP responses.
+func (h *Handler) respondErr(w http.ResponseWriter, what string, err error) {
+	switch {
+	case errors.Is(err, ErrPageNotFound), errors.Is(err, ErrComponentNotFound),
+		errors.Is(err, ErrIncidentNotFound), errors.Is(err, ErrSubscriberNotFound):
+		httpserver.RespondError(w, http.StatusNotFound, "not_found", err.Error())
+	case errors.Is(err, ErrPageExists), errors.Is(err, ErrComponentExists), errors.Is(err, ErrSubscriberExists):
+		httpserver.RespondError(w, http.StatusConflict, "conflict", err.Error())
+	case errors.Is(err, ErrServiceNotFound):
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "service does not exist")
+	default:
+		h.logger.Error(what, "error", err)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed "+what)
+	}
+}
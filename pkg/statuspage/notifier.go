@@ -0,0 +1,181 @@
+package statuspage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/wisbric/nightowl/internal/db"
+	"github.com/wisbric/nightowl/pkg/mailer"
+	"github.com/wisbric/nightowl/pkg/tenant"
+)
+
+// Notifier settings. Updates older than maxNotifyAge are no longer mailed,
+// so a relay outage or enabling mail later does not send stale news.
+const (
+	notifyBatch      = 100
+	maxNotifyAge     = 24 * time.Hour
+	unconfirmedAfter = 7 * 24 * time.Hour
+)
+
+// Notifier mails incident updates to the confirmed subscribers of their
+// page and prunes subscriptions that were never confirmed.
+type Notifier struct {
+	pool      *pgxpool.Pool
+	mail      mailer.Sender
+	publicURL string
+	tokenKey  []byte
+	logger    *slog.Logger
+}
+
+// NewNotifier creates a Notifier sending through mail. publicURL is the
+// externally reachable base URL used for links, and tokenKey signs the
+// unsubscribe links; it must match the Handler's.
+func NewNotifier(pool *pgxpool.Pool, mail mailer.Sender, publicURL string, tokenKey []byte, logger *slog.Logger) *Notifier {
+	return &Notifier{pool: pool, mail: mail, publicURL: strings.TrimRight(publicURL, "/"), tokenKey: tokenKey, logger: logger}
+}
+
+// Run notifies every tenant's subscribers at start and then every interval
+// until ctx is cancelled.
+func (n *Notifier) Run(ctx context.Context, interval time.Duration) {
+	n.logger.Info("status page notifier started", "interval", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n.notifyAll(ctx)
+		select {
+		case <-ctx.Done():
+			n.logger.Info("status page notifier stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (n *Notifier) notifyAll(ctx context.Context) {
+	tenants, err := db.New(n.pool).ListTenants(ctx)
+	if err != nil {
+		n.logger.Error("listing tenants for status page notifications", "error", err)
+		return
+	}
+	for _, t := range tenants {
+		if ctx.Err() != nil {
+			return
+		}
+		tctx, release, err := tenant.Acquire(ctx, n.pool, t.Slug)
+		if errors.Is(err, tenant.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			n.logger.Error("acquiring tenant for status page notifications", "tenant", t.Slug, "error", err)
+			continue
+		}
+		n.notifyTenant(tctx, t.Slug)
+		release()
+	}
+}
+
+// notifyTenant mails a tenant's pending updates. ctx must carry the tenant.
+func (n *Notifier) notifyTenant(ctx context.Context, slug string) {
+	store := NewStore(tenant.ConnFromContext(ctx))
+
+	if pruned, err := store.pruneUnconfirmed(ctx, time.Now().Add(-unconfirmedAfter)); err != nil {
+		n.logger.Error("pruning status page subscribers", "tenant", slug, "error", err)
+	} else if pruned > 0 {
+		n.logger.Info("pruned unconfirmed status page subscribers", "tenant", slug, "count", pruned)
+	}
+
+	pending, err := store.pendingUpdates(ctx, notifyBatch)
+	if err != nil {
+		n.logger.Error("listing pending incident updates", "tenant", slug, "error", err)
+		return
+	}
+	subscribers := map[uuid.UUID][]Subscriber{}
+	for _, u := range pending {
+		if ctx.Err() != nil {
+			return
+		}
+		subs, ok := subscribers[u.page.ID]
+		if !ok {
+			if subs, err = store.confirmedSubscribers(ctx, u.page.ID); err != nil {
+				n.logger.Error("listing status page subscribers", "tenant", slug, "page", u.page.Slug, "error", err)
+				return
+			}
+			subscribers[u.page.ID] = subs
+		}
+		if !n.send(ctx, slug, u, subs) {
+			// The relay is likely down; try again next run.
+			return
+		}
+		if err := store.markNotified(ctx, u.ID); err != nil {
+			n.logger.Error("recording incident update notification", "tenant", slug, "error", err)
+		}
+	}
+}
+
+// send mails an update to subscribers and reports whether it is done with.
+// It is not if every mail failed, so the next run retries it, unless the
+// update has grown too old to send.
+func (n *Notifier) send(ctx context.Context, slug string, u pendingUpdate, subs []Subscriber) bool {
+	if time.Since(u.CreatedAt) > maxNotifyAge {
+		n.logger.Warn("dropping stale incident update notification", "tenant", slug, "page", u.page.Slug, "update", u.ID)
+		return true
+	}
+	pageURL := n.publicURL + PagePath(slug, u.page.Slug)
+	sent, failed := 0, 0
+	for _, sub := range subs {
+		if err := n.mail.Send(ctx, updateMessage(u.page, pageURL, u.title, u.IncidentUpdate, sub, subscriberToken(n.tokenKey, sub.ID))); err != nil {
+			n.logger.Warn("mailing incident update", "tenant", slug, "page", u.page.Slug, "error", err)
+			failed++
+			continue
+		}
+		sent++
+	}
+	if sent > 0 || failed > 0 {
+		n.logger.Info("mailed incident update", "tenant", slug, "page", u.page.Slug, "update", u.ID, "sent", sent, "failed", failed)
+	}
+	return sent > 0 || failed == 0
+}
+
+// confirmationMessage asks a new subscriber to confirm their address.
+func confirmationMessage(p Page, pageURL string, sub Subscriber, token string) mailer.Message {
+	link := pageURL + "/confirm?token=" + url.QueryEscape(token)
+	return mailer.Message{
+		To:      sub.Email,
+		Subject: "Confirm your subscription to " + p.Name + " status",
+		Body: fmt.Sprintf("Someone, hopefully you, subscribed %s to incident updates for %s.\n\n"+
+			"Confirm the subscription by opening this link:\n%s\n\n"+
+			"If you did not subscribe, ignore this email and the subscription expires in seven days.\n",
+			sub.Email, p.Name, link),
+	}
+}
+
+// updateMessage mails an incident update to a subscriber. The unsubscribe
+// link supports one-click unsubscribe (RFC 8058).
+func updateMessage(p Page, pageURL, title string, u IncidentUpdate, sub Subscriber, token string) mailer.Message {
+	unsubscribe := pageURL + "/unsubscribe?token=" + url.QueryEscape(token)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n%s: %s\n\n", title, statusLabel(u.Status), u.Body)
+	fmt.Fprintf(&b, "Posted %s\n", u.CreatedAt.UTC().Format("Jan 2, 2006 15:04 MST"))
+	if p.Public {
+		fmt.Fprintf(&b, "\nFollow the incident at %s#incident-%s\n", pageURL, u.IncidentID)
+	}
+	fmt.Fprintf(&b, "\n--\nYou are subscribed to %s status updates. Unsubscribe: %s\n", p.Name, unsubscribe)
+	return mailer.Message{
+		To:      sub.Email,
+		Subject: fmt.Sprintf("[%s] %s: %s", p.Name, title, statusLabel(u.Status)),
+		Body:    b.String(),
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + unsubscribe + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	}
+}
@@ -0,0 +1,367 @@
+package statuspage
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/wisbric/core/pkg/httpserver"
+
+	"github.com/wisbric/nightowl/pkg/apikey"
+	"github.com/wisbric/nightowl/pkg/tenant"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"label": statusLabel,
+	"when": func(t time.Time) string {
+		return t.UTC().Format("Jan 2, 2006 15:04 MST")
+	},
+}).ParseFS(templateFS, "templates/*.html"))
+
+// pageView is the data of the public HTML page.
+type pageView struct {
+	Summary
+	// Path is the page's URL path, which the feed and form links extend.
+	Path         string
+	CanSubscribe bool
+	Notice       string
+}
+
+// messageView is the data of the pages answering subscription links.
+type messageView struct {
+	PageName string
+	PagePath string
+	Title    string
+	Message  string
+	// Action, if set, is where the page's confirmation button posts.
+	Action string
+}
+
+// subscribeLimitPrefix keys subscription attempts in the rate limiter,
+// apart from login attempts.
+const subscribeLimitPrefix = "status-subscribe:"
+
+// confirmationInterval is how long a pending address waits before another
+// confirmation link is mailed to it.
+const confirmationInterval = time.Hour
+
+// PublicRoutes returns the unauthenticated routes of public pages. They are
+// mounted outside the API-key protected router, below a tenant resolved
+// from the path. Internal pages are not found here, except through the
+// confirm and unsubscribe links mailed to their subscribers, whose tokens
+// authenticate them.
+func (h *Handler) PublicRoutes() chi.Router {
+	r := chi.NewRouter()
+	r.Route("/{slug}", func(r chi.Router) {
+		r.Get("/", h.handlePublicPage)
+		r.Get("/summary.json", h.handlePublicSummary)
+		r.Get("/feed.rss", h.handleRSS)
+		r.Get("/feed.atom", h.handleAtom)
+		r.Post("/subscribe", h.handleSubscribe)
+		r.Get("/confirm", h.handleConfirm)
+		r.Get("/unsubscribe", h.handleUnsubscribeForm)
+		r.Post("/unsubscribe", h.handleUnsubscribe)
+	})
+	return r
+}
+
+func tenantSlug(ctx context.Context) string {
+	if info := tenant.FromContext(ctx); info != nil {
+		return info.Slug
+	}
+	return ""
+}
+
+// publicPage loads the public page named in the URL, responding with an
+// error if it cannot.
+func (h *Handler) publicPage(w http.ResponseWriter, r *http.Request, publicOnly bool) (Page, bool) {
+	p, err := h.store(r).GetPageBySlug(r.Context(), chi.URLParam(r, "slug"), publicOnly)
+	if err != nil {
+		h.respondErr(w, "getting status page", err)
+		return Page{}, false
+	}
+	return p, true
+}
+
+// publicSummary loads the public page named in the URL with its summary.
+func (h *Handler) publicSummary(w http.ResponseWriter, r *http.Request) (Summary, bool) {
+	p, ok := h.publicPage(w, r, true)
+	if !ok {
+		return Summary{}, false
+	}
+	s, err := h.summary(r.Context(), h.store(r), p)
+	if err != nil {
+		h.respondErr(w, "building status page summary", err)
+		return Summary{}, false
+	}
+	// Who posted what, and when subscribers were mailed, stays internal.
+	for i := range s.Incidents {
+		inc := &s.Incidents[i]
+		inc.CreatedBy = nil
+		for j := range inc.Updates {
+			inc.Updates[j].CreatedBy = nil
+			inc.Updates[j].NotifiedAt = nil
+		}
+	}
+	return s, true
+}
+
+func (h *Handler) handlePublicPage(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.publicSummary(w, r)
+	if !ok {
+		return
+	}
+	view := pageView{
+		Summary:      s,
+		Path:         PagePath(tenantSlug(r.Context()), chi.URLParam(r, "slug")),
+		CanSubscribe: h.Mailer != nil,
+	}
+	if r.URL.Query().Get("subscribed") != "" {
+		view.Notice = "Check your inbox for a link to confirm your subscription."
+	}
+	h.render(w, http.StatusOK, "page.html", view)
+}
+
+func (h *Handler) handlePublicSummary(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.publicSummary(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	httpserver.Respond(w, http.StatusOK, s)
+}
+
+func (h *Handler) handleRSS(w http.ResponseWriter, r *http.Request) {
+	h.serveFeed(w, r, "application/rss+xml; charset=utf-8", func(p Page, pageURL string, incidents []Incident) ([]byte, error) {
+		return renderRSS(p, pageURL, incidents)
+	})
+}
+
+func (h *Handler) handleAtom(w http.ResponseWriter, r *http.Request) {
+	h.serveFeed(w, r, "application/atom+xml; charset=utf-8", func(p Page, pageURL string, incidents []Incident) ([]byte, error) {
+		return renderAtom(p, pageURL, pageURL+"/feed.atom", incidents)
+	})
+}
+
+// serveFeed serves a feed of a public page's latest incidents.
+func (h *Handler) serveFeed(w http.ResponseWriter, r *http.Request, contentType string,
+	render func(p Page, pageURL string, incidents []Incident) ([]byte, error)) {
+	p, ok := h.publicPage(w, r, true)
+	if !ok {
+		return
+	}
+	incidents, err := h.store(r).ListIncidents(r.Context(), p.ID, feedIncidents)
+	if err != nil {
+		h.respondErr(w, "listing status incidents", err)
+		return
+	}
+	body, err := render(p, h.pageURL(r.Context(), p), incidents)
+	if err != nil {
+		h.respondErr(w, "rendering status feed", err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(body)
+}
+
+// handleSubscribe subscribes an address to a public page and mails it a
+// confirmation link. It takes a JSON body or the page's form, which is
+// redirected back to the page. An address that is already confirmed gets
+// the same answer without a mail, so the form does not reveal who is
+// subscribed.
+func (h *Handler) handleSubscribe(w http.ResponseWriter, r *http.Request) {
+	if h.Mailer == nil {
+		httpserver.RespondError(w, http.StatusNotFound, "not_found", "subscriptions are not enabled")
+		return
+	}
+	if !h.allowSubscribe(w, r, "addr:"+apikey.ClientAddr(r, h.TrustedProxies).String()) {
+		return
+	}
+
+	form := !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+	var req SubscribeRequest
+	if form {
+		req.Email = strings.TrimSpace(r.PostFormValue("email"))
+		if errs := httpserver.Validate(&req); len(errs) > 0 {
+			h.render(w, http.StatusUnprocessableEntity, "message.html", messageView{
+				PagePath: PagePath(tenantSlug(r.Context()), chi.URLParam(r, "slug")),
+				Title:    "Invalid address",
+				Message:  "Enter a valid email address to subscribe.",
+			})
+			return
+		}
+	} else if !httpserver.DecodeAndValidate(w, r, &req) {
+		return
+	}
+	// Limit by recipient too, so many client addresses cannot flood one
+	// inbox through the tenant's sender.
+	if !h.allowSubscribe(w, r, "email:"+normalizeEmail(req.Email)) {
+		return
+	}
+
+	p, ok := h.publicPage(w, r, true)
+	if !ok {
+		return
+	}
+	store := h.store(r)
+	sub, err := store.Subscribe(r.Context(), p.ID, req.Email)
+	if err != nil {
+		h.respondErr(w, "subscribing", err)
+		return
+	}
+	if sub.ConfirmedAt == nil {
+		if err := h.sendConfirmation(r.Context(), store, p, sub); err != nil {
+			h.logger.Error("sending subscription confirmation", "error", err, "page", p.Slug)
+			httpserver.RespondError(w, http.StatusBadGateway, "mail_error", "failed to send the confirmation email")
+			return
+		}
+	}
+
+	if form {
+		http.Redirect(w, r, PagePath(tenantSlug(r.Context()), p.Slug)+"?subscribed=1", http.StatusSeeOther)
+		return
+	}
+	httpserver.Respond(w, http.StatusAccepted, map[string]string{
+		"status": "pending_confirmation", "message": "check your inbox for a link to confirm the subscription",
+	})
+}
+
+// sendConfirmation mails sub its confirmation link unless one was sent
+// within confirmationInterval; the answer to the form is the same either way.
+func (h *Handler) sendConfirmation(ctx context.Context, store *Store, p Page, sub Subscriber) error {
+	claimed, err := store.claimConfirmation(ctx, sub.ID, confirmationInterval)
+	if err != nil || !claimed {
+		return err
+	}
+	if err := h.Mailer.Send(ctx, confirmationMessage(p, h.pageURL(ctx, p), sub, subscriberToken(h.tokenKey, sub.ID))); err != nil {
+		if rerr := store.releaseConfirmation(ctx, sub.ID); rerr != nil {
+			h.logger.Error("releasing subscription confirmation", "error", rerr, "page", p.Slug)
+		}
+		return err
+	}
+	return nil
+}
+
+// allowSubscribe counts a subscription attempt against the limit for key,
+// responding with 429 once it is used up.
+func (h *Handler) allowSubscribe(w http.ResponseWriter, r *http.Request, key string) bool {
+	if h.Limiter == nil {
+		return true
+	}
+	key = subscribeLimitPrefix + key
+	res, err := h.Limiter.Check(r.Context(), key)
+	if err != nil {
+		// Fail open: a Redis outage should not stop subscriptions.
+		h.logger.Warn("checking subscription rate limit", "error", err)
+		return true
+	}
+	if !res.Allowed {
+		w.Header().Set("Retry-After", retryAfter(res.RetryAt))
+		httpserver.RespondError(w, http.StatusTooManyRequests, "rate_limited", "too many subscription requests, try again later")
+		return false
+	}
+	if err := h.Limiter.Record(r.Context(), key); err != nil {
+		h.logger.Warn("recording subscription attempt", "error", err)
+	}
+	return true
+}
+
+func retryAfter(at time.Time) string {
+	secs := int(time.Until(at).Seconds()) + 1
+	if secs < 1 {
+		secs = 1
+	}
+	return strconv.Itoa(secs)
+}
+
+func (h *Handler) handleConfirm(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.publicPage(w, r, false)
+	if !ok {
+		return
+	}
+	view := messageView{PageName: p.Name}
+	if p.Public {
+		view.PagePath = PagePath(tenantSlug(r.Context()), p.Slug)
+	}
+	err := ErrSubscriberNotFound
+	if id, ok := parseSubscriberToken(h.tokenKey, r.URL.Query().Get("token")); ok {
+		_, err = h.store(r).Confirm(r.Context(), p.ID, id)
+	}
+	switch {
+	case errors.Is(err, ErrSubscriberNotFound):
+		view.Title, view.Message = "Link expired", "This confirmation link is invalid or has expired. Subscribe again to get a new one."
+		h.render(w, http.StatusNotFound, "message.html", view)
+	case err != nil:
+		h.respondErr(w, "confirming subscription", err)
+	default:
+		view.Title, view.Message = "Subscription confirmed", "You will get an email for each update to "+p.Name+" incidents."
+		h.render(w, http.StatusOK, "message.html", view)
+	}
+}
+
+// handleUnsubscribeForm asks to confirm unsubscribing, so that link
+// scanners following the mailed link do not unsubscribe anyone.
+func (h *Handler) handleUnsubscribeForm(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.publicPage(w, r, false)
+	if !ok {
+		return
+	}
+	h.render(w, http.StatusOK, "message.html", messageView{
+		PageName: p.Name,
+		Title:    "Unsubscribe",
+		Message:  "Stop getting email updates about " + p.Name + " incidents?",
+		Action:   r.URL.RequestURI(),
+	})
+}
+
+// handleUnsubscribe deletes a subscription. Mail clients post here for
+// one-click unsubscribe (RFC 8058).
+func (h *Handler) handleUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.publicPage(w, r, false)
+	if !ok {
+		return
+	}
+	view := messageView{PageName: p.Name}
+	if p.Public {
+		view.PagePath = PagePath(tenantSlug(r.Context()), p.Slug)
+	}
+	var sub Subscriber
+	err := ErrSubscriberNotFound
+	if id, ok := parseSubscriberToken(h.tokenKey, r.URL.Query().Get("token")); ok {
+		sub, err = h.store(r).DeleteSubscriber(r.Context(), p.ID, id)
+	}
+	switch {
+	case errors.Is(err, ErrSubscriberNotFound):
+		view.Title, view.Message = "Not subscribed", "This address is not subscribed to "+p.Name+" status updates."
+		h.render(w, http.StatusNotFound, "message.html", view)
+	case err != nil:
+		h.respondErr(w, "unsubscribing", err)
+	default:
+		h.logger.Info("status page subscriber unsubscribed", "page", p.Slug, "subscriber", sub.ID)
+		view.Title, view.Message = "Unsubscribed", "You will no longer get email updates about "+p.Name+" incidents."
+		h.render(w, http.StatusOK, "message.html", view)
+	}
+}
+
+func (h *Handler) render(w http.ResponseWriter, status int, name string, data any) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		h.logger.Error("rendering status page", "template", name, "error", err)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed rendering status page")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
+}
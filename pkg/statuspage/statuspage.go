@@ -0,0 +1,379 @@
+// Package statuspage implements status pages: components that show the
+// health of services, derived from their open alerts or set by hand, and
+// incidents with a timeline of updates. Public pages are served without
+// authentication as HTML and JSON/RSS/Atom feeds, and subscribers are
+// mailed each incident update.
+package statuspage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Component statuses, from best to worst apart from maintenance.
+const (
+	StatusOperational         = "operational"
+	StatusDegradedPerformance = "degraded_performance"
+	StatusPartialOutage       = "partial_outage"
+	StatusMajorOutage         = "major_outage"
+	StatusUnderMaintenance    = "under_maintenance"
+)
+
+// Incident statuses.
+const (
+	IncidentInvestigating = "investigating"
+	IncidentIdentified    = "identified"
+	IncidentMonitoring    = "monitoring"
+	IncidentResolved      = "resolved"
+)
+
+// Page indicators summarise a page's components and unresolved incidents.
+// They match the impact levels of incidents, plus maintenance.
+const (
+	IndicatorNone        = "none"
+	IndicatorMinor       = "minor"
+	IndicatorMajor       = "major"
+	IndicatorCritical    = "critical"
+	IndicatorMaintenance = "maintenance"
+)
+
+// recentIncidents is how long resolved incidents stay in a page summary.
+const recentIncidents = 7 * 24 * time.Hour
+
+// feedIncidents is how many incidents the RSS and Atom feeds carry.
+const feedIncidents = 50
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+var (
+	// ErrPageNotFound is returned when no status page matches.
+	ErrPageNotFound = errors.New("status page not found")
+	// ErrPageExists is returned when a page slug is taken.
+	ErrPageExists = errors.New("a status page with this slug already exists")
+	// ErrComponentNotFound is returned when no component matches.
+	ErrComponentNotFound = errors.New("status component not found")
+	// ErrComponentExists is returned when a service already has a
+	// component on the page.
+	ErrComponentExists = errors.New("the service already has a component on this page")
+	// ErrServiceNotFound is returned when a component names an unknown service.
+	ErrServiceNotFound = errors.New("service not found")
+	// ErrIncidentNotFound is returned when no incident matches.
+	ErrIncidentNotFound = errors.New("status incident not found")
+	// ErrSubscriberNotFound is returned when no subscriber matches.
+	ErrSubscriberNotFound = errors.New("subscriber not found")
+	// ErrSubscriberExists is returned when an address is already subscribed.
+	ErrSubscriberExists = errors.New("this address is already subscribed")
+)
+
+// Page is a status page. Only public pages are served without
+// authentication.
+type Page struct {
+	ID          uuid.UUID `json:"id"`
+	Slug        string    `json:"slug"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Public      bool      `json:"public"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// PageRequest is the JSON body for creating or updating a page.
+type PageRequest struct {
+	// Slug names the page in its public URL: lowercase letters, digits and
+	// hyphens.
+	Slug        string `json:"slug" validate:"required,max=63"`
+	Name        string `json:"name" validate:"required,min=2,max=100"`
+	Description string `json:"description" validate:"max=1000"`
+	Public      bool   `json:"public"`
+}
+
+// Component shows the status of one service on a page.
+type Component struct {
+	ID          uuid.UUID `json:"id"`
+	PageID      uuid.UUID `json:"page_id"`
+	ServiceID   uuid.UUID `json:"service_id"`
+	ServiceName string    `json:"service_name"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Position    int       `json:"position"`
+	// Status is StatusOverride if set, else derived from the worst open
+	// alert of the service, whose severity is AlertSeverity.
+	Status         string    `json:"status"`
+	StatusOverride *string   `json:"status_override,omitempty"`
+	AlertSeverity  *string   `json:"alert_severity,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// ComponentRequest is the JSON body for adding or updating a component.
+type ComponentRequest struct {
+	ServiceID uuid.UUID `json:"service_id" validate:"required"`
+	// Name defaults to the service name.
+	Name           string  `json:"name" validate:"max=100"`
+	Description    string  `json:"description" validate:"max=1000"`
+	Position       int     `json:"position"`
+	StatusOverride *string `json:"status_override" validate:"omitempty,oneof=operational degraded_performance partial_outage major_outage under_maintenance"`
+}
+
+// ComponentStatusRequest sets or, when Status is empty, clears a
+// component's manual status.
+type ComponentStatusRequest struct {
+	Status string `json:"status" validate:"omitempty,oneof=operational degraded_performance partial_outage major_outage under_maintenance"`
+}
+
+// Incident is an incident shown on a page, with its timeline of updates,
+// newest first.
+type Incident struct {
+	ID           uuid.UUID        `json:"id"`
+	PageID       uuid.UUID        `json:"page_id"`
+	Title        string           `json:"title"`
+	Status       string           `json:"status"`
+	Impact       string           `json:"impact"`
+	ComponentIDs []uuid.UUID      `json:"component_ids"`
+	Updates      []IncidentUpdate `json:"updates"`
+	CreatedBy    *uuid.UUID       `json:"created_by,omitempty"`
+	CreatedAt    time.Time        `json:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+	ResolvedAt   *time.Time       `json:"resolved_at,omitempty"`
+}
+
+// IncidentUpdate is one entry of an incident's timeline.
+type IncidentUpdate struct {
+	ID         uuid.UUID  `json:"id"`
+	IncidentID uuid.UUID  `json:"incident_id"`
+	Status     string     `json:"status"`
+	Body       string     `json:"body"`
+	Notify     bool       `json:"notify"`
+	NotifiedAt *time.Time `json:"notified_at,omitempty"`
+	CreatedBy  *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// IncidentRequest is the JSON body for opening an incident. Body is the
+// first update of its timeline.
+type IncidentRequest struct {
+	Title        string      `json:"title" validate:"required,min=2,max=200"`
+	Status       string      `json:"status" validate:"required,oneof=investigating identified monitoring resolved"`
+	Impact       string      `json:"impact" validate:"omitempty,oneof=none minor major critical"`
+	ComponentIDs []uuid.UUID `json:"component_ids"`
+	Body         string      `json:"body" validate:"required,max=10000"`
+	// Notify mails the update to subscribers; it defaults to true.
+	Notify *bool `json:"notify"`
+}
+
+// IncidentEditRequest is the JSON body for correcting an incident's title,
+// impact or components without posting an update.
+type IncidentEditRequest struct {
+	Title        string      `json:"title" validate:"required,min=2,max=200"`
+	Impact       string      `json:"impact" validate:"required,oneof=none minor major critical"`
+	ComponentIDs []uuid.UUID `json:"component_ids"`
+}
+
+// UpdateRequest is the JSON body for posting an incident update, which
+// also sets the incident's status.
+type UpdateRequest struct {
+	Status string `json:"status" validate:"required,oneof=investigating identified monitoring resolved"`
+	Body   string `json:"body" validate:"required,max=10000"`
+	// Notify mails the update to subscribers; it defaults to true.
+	Notify *bool `json:"notify"`
+}
+
+// Subscriber is an email address subscribed to a page's incident updates.
+// Updates are only mailed once the address is confirmed.
+type Subscriber struct {
+	ID          uuid.UUID  `json:"id"`
+	PageID      uuid.UUID  `json:"page_id"`
+	Email       string     `json:"email"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// SubscribeRequest is the JSON or form body for subscribing to a page.
+type SubscribeRequest struct {
+	Email string `json:"email" validate:"required,email,max=254"`
+}
+
+// Summary is a page's current state: the body of summary.json and the
+// data of the HTML page.
+type Summary struct {
+	Page       SummaryPage        `json:"page"`
+	Status     SummaryStatus      `json:"status"`
+	Components []SummaryComponent `json:"components"`
+	// Incidents are the unresolved incidents and those resolved in the
+	// last seven days, newest first.
+	Incidents []Incident `json:"incidents"`
+}
+
+// SummaryPage describes the page in a summary.
+type SummaryPage struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	URL         string    `json:"url"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// SummaryStatus is the overall status of a page.
+type SummaryStatus struct {
+	Indicator   string `json:"indicator"`
+	Description string `json:"description"`
+}
+
+// SummaryComponent is a component in a summary, without its service.
+type SummaryComponent struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Status      string    `json:"status"`
+}
+
+// statusFromSeverity maps the severity of a service's worst open alert to a
+// component status. Info alerts and no alerts leave it operational.
+func statusFromSeverity(severity string) string {
+	switch severity {
+	case "critical":
+		return StatusMajorOutage
+	case "major":
+		return StatusPartialOutage
+	case "warning":
+		return StatusDegradedPerformance
+	default:
+		return StatusOperational
+	}
+}
+
+// componentStatus returns a component's status: the manual override if set,
+// else the status derived from its open alerts.
+func componentStatus(override, alertSeverity *string) string {
+	if override != nil {
+		return *override
+	}
+	if alertSeverity != nil {
+		return statusFromSeverity(*alertSeverity)
+	}
+	return StatusOperational
+}
+
+var indicatorRank = map[string]int{
+	IndicatorNone:        0,
+	IndicatorMaintenance: 1,
+	IndicatorMinor:       2,
+	IndicatorMajor:       3,
+	IndicatorCritical:    4,
+}
+
+var statusIndicator = map[string]string{
+	StatusOperational:         IndicatorNone,
+	StatusUnderMaintenance:    IndicatorMaintenance,
+	StatusDegradedPerformance: IndicatorMinor,
+	StatusPartialOutage:       IndicatorMajor,
+	StatusMajorOutage:         IndicatorCritical,
+}
+
+var indicatorDescriptions = map[string]string{
+	IndicatorNone:        "All Systems Operational",
+	IndicatorMaintenance: "Under Maintenance",
+	IndicatorMinor:       "Degraded Performance",
+	IndicatorMajor:       "Partial System Outage",
+	IndicatorCritical:    "Major System Outage",
+}
+
+// pageStatus returns the overall status of a page: the worst of its
+// components' statuses and its unresolved incidents' impacts.
+func pageStatus(components []Component, incidents []Incident) SummaryStatus {
+	worst := IndicatorNone
+	raise := func(ind string) {
+		if indicatorRank[ind] > indicatorRank[worst] {
+			worst = ind
+		}
+	}
+	for _, c := range components {
+		raise(statusIndicator[c.Status])
+	}
+	for _, inc := range incidents {
+		if inc.Status != IncidentResolved {
+			raise(inc.Impact)
+		}
+	}
+	return SummaryStatus{Indicator: worst, Description: indicatorDescriptions[worst]}
+}
+
+// buildSummary assembles a page summary. url is the page's public URL.
+func buildSummary(p Page, url string, components []Component, incidents []Incident) Summary {
+	s := Summary{
+		Page: SummaryPage{
+			ID: p.ID, Name: p.Name, Description: p.Description, URL: url, UpdatedAt: p.UpdatedAt,
+		},
+		Status:     pageStatus(components, incidents),
+		Components: make([]SummaryComponent, len(components)),
+		Incidents:  incidents,
+	}
+	for i, c := range components {
+		s.Components[i] = SummaryComponent{ID: c.ID, Name: c.Name, Description: c.Description, Status: c.Status}
+	}
+	for _, inc := range incidents {
+		if inc.UpdatedAt.After(s.Page.UpdatedAt) {
+			s.Page.UpdatedAt = inc.UpdatedAt
+		}
+	}
+	return s
+}
+
+// PagePath returns the URL path of a public page.
+func PagePath(tenantSlug, pageSlug string) string {
+	return "/status/" + tenantSlug + "/" + pageSlug
+}
+
+// subscriberToken returns the token in a subscriber's confirm and
+// unsubscribe links: their ID signed with key. Tokens are derived rather
+// than stored, so the database holds nothing that works as a link.
+func subscriberToken(key []byte, id uuid.UUID) string {
+	return id.String() + "." + tokenMAC(key, id)
+}
+
+// parseSubscriberToken returns the subscriber ID a token was issued for,
+// or false if the token was not signed with key.
+func parseSubscriberToken(key []byte, token string) (uuid.UUID, bool) {
+	idPart, mac, ok := strings.Cut(token, ".")
+	if !ok {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(idPart)
+	if err != nil || !hmac.Equal([]byte(mac), []byte(tokenMAC(key, id))) {
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func tokenMAC(key []byte, id uuid.UUID) string {
+	m := hmac.New(sha256.New, key)
+	m.Write(id[:])
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+func notifyOrDefault(notify *bool) bool {
+	return notify == nil || *notify
+}
+
+func impactOrDefault(impact string) string {
+	if impact == "" {
+		return IndicatorMinor
+	}
+	return impact
+}
+
+func pgtypeUUIDToPtr(p pgtype.UUID) *uuid.UUID {
+	if !p.Valid {
+		return nil
+	}
+	id := uuid.UUID(p.Bytes)
+	return &id
+}
@@ -0,0 +1,284 @@
+package statuspage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wisbric/core/pkg/auth"
+
+	"github.com/wisbric/nightowl/pkg/mailer"
+)
+
+func ptr(s string) *string { return &s }
+
+func TestComponentStatus(t *testing.T) {
+	tests := []struct {
+		override, severity *string
+		want               string
+	}{
+		{nil, nil, StatusOperational},
+		{nil, ptr("info"), StatusOperational},
+		{nil, ptr("warning"), StatusDegradedPerformance},
+		{nil, ptr("major"), StatusPartialOutage},
+		{nil, ptr("critical"), StatusMajorOutage},
+		{ptr(StatusUnderMaintenance), ptr("critical"), StatusUnderMaintenance},
+		{ptr(StatusOperational), ptr("critical"), StatusOperational},
+	}
+	for _, tt := range tests {
+		if got := componentStatus(tt.override, tt.severity); got != tt.want {
+			t.Errorf("componentStatus(%v, %v) = %q, want %q", tt.override, tt.severity, got, tt.want)
+		}
+	}
+}
+
+func TestPageStatus(t *testing.T) {
+	comp := func(status string) Component { return Component{Status: status} }
+	inc := func(status, impact string) Incident { return Incident{Status: status, Impact: impact} }
+	tests := []struct {
+		name       string
+		components []Component
+		incidents  []Incident
+		want       string
+	}{
+		{"empty", nil, nil, IndicatorNone},
+		{"operational", []Component{comp(StatusOperational)}, nil, IndicatorNone},
+		{"maintenance", []Component{comp(StatusUnderMaintenance), comp(StatusOperational)}, nil, IndicatorMaintenance},
+		{"degraded beats maintenance", []Component{comp(StatusUnderMaintenance), comp(StatusDegradedPerformance)}, nil, IndicatorMinor},
+		{"worst component", []Component{comp(StatusPartialOutage), comp(StatusMajorOutage)}, nil, IndicatorCritical},
+		{"open incident", []Component{comp(StatusOperational)}, []Incident{inc(IncidentIdentified, "major")}, IndicatorMajor},
+		{"resolved incident", nil, []Incident{inc(IncidentResolved, "critical")}, IndicatorNone},
+	}
+	for _, tt := range tests {
+		got := pageStatus(tt.components, tt.incidents)
+		if got.Indicator != tt.want || got.Description != indicatorDescriptions[tt.want] {
+			t.Errorf("%s: status = %+v, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func testIncident() Incident {
+	created := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	id := uuid.New()
+	return Incident{
+		ID: id, Title: "API errors <5xx>", Status: IncidentResolved, Impact: "major",
+		CreatedAt: created, UpdatedAt: created.Add(time.Hour),
+		Updates: []IncidentUpdate{
+			{IncidentID: id, Status: IncidentResolved, Body: "Fixed.", CreatedAt: created.Add(time.Hour)},
+			{IncidentID: id, Status: IncidentInvestigating, Body: "Looking into <errors>.", CreatedAt: created},
+		},
+	}
+}
+
+func TestRenderFeeds(t *testing.T) {
+	p := Page{Name: "Acme", UpdatedAt: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)}
+	pageURL := "https://status.example.com/status/acme/public"
+	inc := testIncident()
+
+	body, err := renderRSS(p, pageURL, []Incident{inc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rss rssFeed
+	if err := xml.Unmarshal(body, &rss); err != nil {
+		t.Fatalf("parsing RSS: %v", err)
+	}
+	if len(rss.Channel.Items) != 1 {
+		t.Fatalf("items = %d, want 1", len(rss.Channel.Items))
+	}
+	item := rss.Channel.Items[0]
+	if item.Title != inc.Title || item.Link != pageURL+"#incident-"+inc.ID.String() {
+		t.Errorf("item = %+v", item)
+	}
+	if !strings.Contains(item.Description, "<strong>Resolved</strong> - Fixed.") ||
+		!strings.Contains(item.Description, "Looking into &lt;errors&gt;.") {
+		t.Errorf("description = %q", item.Description)
+	}
+	if rss.Channel.LastBuildDate != "Mon, 02 Mar 2026 13:00:00 +0000" {
+		t.Errorf("lastBuildDate = %q", rss.Channel.LastBuildDate)
+	}
+
+	body, err = renderAtom(p, pageURL, pageURL+"/feed.atom", []Incident{inc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var atom atomFeed
+	if err := xml.Unmarshal(body, &atom); err != nil {
+		t.Fatalf("parsing Atom: %v", err)
+	}
+	if atom.ID != pageURL || atom.Updated != "2026-03-02T13:00:00Z" || len(atom.Entries) != 1 {
+		t.Errorf("feed = %+v", atom)
+	}
+	if e := atom.Entries[0]; e.Content.Type != "html" || e.Published != "2026-03-02T12:00:00Z" {
+		t.Errorf("entry = %+v", e)
+	}
+}
+
+func TestRenderPage(t *testing.T) {
+	p := Page{ID: uuid.New(), Name: "Acme"}
+	components := []Component{{ID: uuid.New(), Name: "API", Status: StatusPartialOutage}}
+	view := pageView{
+		Summary:      buildSummary(p, "https://status.example.com/status/acme/public", components, []Incident{testIncident()}),
+		Path:         "/status/acme/public",
+		CanSubscribe: true,
+	}
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, "page.html", view); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	for _, want := range []string{
+		"Partial System Outage", "Partial outage", "API errors &lt;5xx&gt;",
+		`action="/status/acme/public/subscribe"`, `href="/status/acme/public/feed.atom"`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+}
+
+func TestUpdateMessage(t *testing.T) {
+	inc := testIncident()
+	sub := Subscriber{Email: "ops@example.com"}
+	pageURL := "https://status.example.com/status/acme/public"
+
+	msg := updateMessage(Page{Name: "Acme", Public: true}, pageURL, inc.Title, inc.Updates[0], sub, "tok")
+	if msg.To != "ops@example.com" || msg.Subject != "[Acme] API errors <5xx>: Resolved" {
+		t.Errorf("message = %+v", msg)
+	}
+	if msg.Headers["List-Unsubscribe"] != "<"+pageURL+"/unsubscribe?token=tok>" ||
+		msg.Headers["List-Unsubscribe-Post"] != "List-Unsubscribe=One-Click" {
+		t.Errorf("headers = %v", msg.Headers)
+	}
+	if !strings.Contains(msg.Body, "#incident-"+inc.ID.String()) {
+		t.Errorf("body lacks the incident link: %q", msg.Body)
+	}
+
+	// Internal pages cannot be linked to.
+	msg = updateMessage(Page{Name: "Acme"}, pageURL, inc.Title, inc.Updates[0], sub, "tok")
+	if strings.Contains(msg.Body, "#incident-") {
+		t.Errorf("internal page update links to the page: %q", msg.Body)
+	}
+}
+
+func TestSubscriberToken(t *testing.T) {
+	key := []byte("key")
+	id := uuid.New()
+	token := subscriberToken(key, id)
+	if got, ok := parseSubscriberToken(key, token); !ok || got != id {
+		t.Fatalf("parseSubscriberToken(%q) = %v, %v", token, got, ok)
+	}
+
+	other := uuid.New()
+	idPart, mac, _ := strings.Cut(token, ".")
+	for _, bad := range []string{
+		"",
+		idPart,
+		idPart + ".",
+		other.String() + "." + mac,
+		subscriberToken([]byte("other key"), id),
+		"not-a-uuid." + mac,
+	} {
+		if _, ok := parseSubscriberToken(key, bad); ok {
+			t.Errorf("parseSubscriberToken(%q) accepted", bad)
+		}
+	}
+}
+
+func TestHandler_RejectsBadRequests(t *testing.T) {
+	h := NewHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), nil, "https://status.example.com", []byte("key"))
+	pageID := uuid.NewString()
+	tests := []struct {
+		role, method, path, body string
+		status                   int
+	}{
+		{auth.RoleAdmin, http.MethodGet, "/not-a-uuid", "", http.StatusBadRequest},
+		{auth.RoleAdmin, http.MethodPost, "/", `{"slug":"Public Page","name":"Acme"}`, http.StatusUnprocessableEntity},
+		{auth.RoleAdmin, http.MethodPost, "/", `{"slug":"-acme","name":"Acme"}`, http.StatusUnprocessableEntity},
+		{auth.RoleEngineer, http.MethodPost, "/", `{"slug":"acme","name":"Acme"}`, http.StatusForbidden},
+		{auth.RoleReadonly, http.MethodPost, "/" + pageID + "/incidents", `{}`, http.StatusForbidden},
+		{auth.RoleEngineer, http.MethodPost, "/" + pageID + "/incidents", `{"title":"API errors","status":"down","body":"x"}`, http.StatusUnprocessableEntity},
+		{auth.RoleEngineer, http.MethodPost, "/" + pageID + "/incidents/not-a-uuid/updates", `{}`, http.StatusBadRequest},
+		{auth.RoleEngineer, http.MethodPost, "/" + pageID + "/components/" + uuid.NewString() + "/status", `{"status":"broken"}`, http.StatusUnprocessableEntity},
+		{auth.RoleAdmin, http.MethodPost, "/" + pageID + "/subscribers", `{"email":"not-an-address"}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		r = r.WithContext(auth.NewContext(r.Context(), &auth.Identity{Subject: "user", Role: tt.role}))
+		rec := httptest.NewRecorder()
+		h.Routes().ServeHTTP(rec, r)
+		if rec.Code != tt.status {
+			t.Errorf("%s %s %s as %s: status = %d, want %d", tt.method, tt.path, tt.body, tt.role, rec.Code, tt.status)
+		}
+	}
+}
+
+func TestPublicRoutes_SubscribeDisabledWithoutMailer(t *testing.T) {
+	h := NewHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), nil, "https://status.example.com", []byte("key"))
+	r := httptest.NewRequest(http.MethodPost, "/public/subscribe", strings.NewReader("email=ops%40example.com"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h.PublicRoutes().ServeHTTP(rec, r)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+type fakeMailer struct{ sent []mailer.Message }
+
+func (m *fakeMailer) Send(_ context.Context, msg mailer.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// fakeLimiter allows every key but those in denied, recording the keys it
+// is asked about.
+type fakeLimiter struct {
+	denied  map[string]bool
+	checked []string
+}
+
+func (l *fakeLimiter) Check(_ context.Context, key string) (*auth.RateLimitResult, error) {
+	l.checked = append(l.checked, key)
+	if l.denied[key] {
+		return &auth.RateLimitResult{RetryAt: time.Now().Add(time.Hour)}, nil
+	}
+	return &auth.RateLimitResult{Allowed: true}, nil
+}
+
+func (l *fakeLimiter) Record(context.Context, string) error { return nil }
+func (l *fakeLimiter) Reset(context.Context, string) error  { return nil }
+
+func TestPublicRoutes_SubscribeLimitedPerRecipient(t *testing.T) {
+	limiter := &fakeLimiter{denied: map[string]bool{subscribeLimitPrefix + "email:ops@example.com": true}}
+	mail := &fakeMailer{}
+	h := NewHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), nil, "https://status.example.com", []byte("key"))
+	h.Mailer, h.Limiter = mail, limiter
+
+	// A fresh client address does not lift the limit on the recipient.
+	r := httptest.NewRequest(http.MethodPost, "/public/subscribe", strings.NewReader(`{"email":"Ops@Example.com"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.RemoteAddr = "203.0.113.7:40000"
+	rec := httptest.NewRecorder()
+	h.PublicRoutes().ServeHTTP(rec, r)
+
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("status = %d, Retry-After = %q; want 429 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	want := []string{subscribeLimitPrefix + "addr:203.0.113.7", subscribeLimitPrefix + "email:ops@example.com"}
+	if strings.Join(limiter.checked, " ") != strings.Join(want, " ") {
+		t.Errorf("checked keys = %v, want %v", limiter.checked, want)
+	}
+	if len(mail.sent) != 0 {
+		t.Errorf("sent %d mails, want none", len(mail.sent))
+	}
+}
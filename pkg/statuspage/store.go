@@ -0,0 +1,634 @@
+package statuspage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wisbric/nightowl/internal/db"
+)
+
+const pageColumns = `id, slug, name, description, public, created_at, updated_at`
+
+// componentSelect selects components with their service name and the
+// severity of the service's worst open alert.
+const componentSelect = `
+	SELECT c.id, c.page_id, c.service_id, s.name, c.name, c.description, c.position, c.status_override,
+	    (SELECT a.severity FROM alerts a
+	     WHERE a.service_id = c.service_id AND a.status <> 'resolved'
+	     ORDER BY CASE a.severity WHEN 'critical' THEN 4 WHEN 'major' THEN 3 WHEN 'warning' THEN 2 ELSE 1 END DESC
+	     LIMIT 1),
+	    c.created_at, c.updated_at
+	FROM status_components c
+	JOIN services s ON s.id = c.service_id`
+
+const incidentColumns = `id, page_id, title, status, impact, component_ids, created_by,
+	created_at, updated_at, resolved_at`
+
+const updateColumns = `id, incident_id, status, body, notify, notified_at, created_by, created_at`
+
+const subscriberColumns = `id, page_id, email, confirmed_at, created_at`
+
+// pageComponentIDs keeps the IDs in $2 that are components of page $1.
+const pageComponentIDs = `ARRAY(SELECT id FROM status_components WHERE page_id = $1 AND id = ANY($2::uuid[]))`
+
+// Store provides database operations for status pages.
+type Store struct {
+	dbtx db.DBTX
+}
+
+// NewStore creates a Store backed by the given connection.
+func NewStore(dbtx db.DBTX) *Store {
+	return &Store{dbtx: dbtx}
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func scanPage(row pgx.Row) (Page, error) {
+	var p Page
+	if err := row.Scan(&p.ID, &p.Slug, &p.Name, &p.Description, &p.Public, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Page{}, ErrPageNotFound
+		}
+		return Page{}, err
+	}
+	return p, nil
+}
+
+// ListPages returns all pages ordered by name.
+func (s *Store) ListPages(ctx context.Context) ([]Page, error) {
+	rows, err := s.dbtx.Query(ctx, `SELECT `+pageColumns+` FROM status_pages ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("listing status pages: %w", err)
+	}
+	defer rows.Close()
+
+	pages := []Page{}
+	for rows.Next() {
+		p, err := scanPage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning status page: %w", err)
+		}
+		pages = append(pages, p)
+	}
+	return pages, rows.Err()
+}
+
+// GetPage returns one page by ID.
+func (s *Store) GetPage(ctx context.Context, id uuid.UUID) (Page, error) {
+	return scanPage(s.dbtx.QueryRow(ctx, `SELECT `+pageColumns+` FROM status_pages WHERE id = $1`, id))
+}
+
+// GetPageBySlug returns one page by slug. With publicOnly, internal pages
+// are not found.
+func (s *Store) GetPageBySlug(ctx context.Context, slug string, publicOnly bool) (Page, error) {
+	return scanPage(s.dbtx.QueryRow(ctx,
+		`SELECT `+pageColumns+` FROM status_pages WHERE slug = $1 AND (public OR NOT $2)`, slug, publicOnly))
+}
+
+// CreatePage inserts a page.
+func (s *Store) CreatePage(ctx context.Context, req PageRequest) (Page, error) {
+	p, err := scanPage(s.dbtx.QueryRow(ctx, `
+		INSERT INTO status_pages (slug, name, description, public)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+pageColumns,
+		req.Slug, req.Name, req.Description, req.Public))
+	if isUniqueViolation(err) {
+		return Page{}, ErrPageExists
+	}
+	if err != nil {
+		return Page{}, fmt.Errorf("creating status page: %w", err)
+	}
+	return p, nil
+}
+
+// UpdatePage replaces a page's settings.
+func (s *Store) UpdatePage(ctx context.Context, id uuid.UUID, req PageRequest) (Page, error) {
+	p, err := scanPage(s.dbtx.QueryRow(ctx, `
+		UPDATE status_pages
+		SET slug = $2, name = $3, description = $4, public = $5, updated_at = now()
+		WHERE id = $1
+		RETURNING `+pageColumns,
+		id, req.Slug, req.Name, req.Description, req.Public))
+	switch {
+	case isUniqueViolation(err):
+		return Page{}, ErrPageExists
+	case errors.Is(err, ErrPageNotFound):
+		return Page{}, err
+	case err != nil:
+		return Page{}, fmt.Errorf("updating status page: %w", err)
+	}
+	return p, nil
+}
+
+// DeletePage deletes a page with its components, incidents and subscribers.
+func (s *Store) DeletePage(ctx context.Context, id uuid.UUID) (Page, error) {
+	return scanPage(s.dbtx.QueryRow(ctx, `DELETE FROM status_pages WHERE id = $1 RETURNING `+pageColumns, id))
+}
+
+func scanComponent(row pgx.Row) (Component, error) {
+	var c Component
+	if err := row.Scan(&c.ID, &c.PageID, &c.ServiceID, &c.ServiceName, &c.Name, &c.Description, &c.Position,
+		&c.StatusOverride, &c.AlertSeverity, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Component{}, ErrComponentNotFound
+		}
+		return Component{}, err
+	}
+	c.Status = componentStatus(c.StatusOverride, c.AlertSeverity)
+	return c, nil
+}
+
+// ListComponents returns a page's components in display order.
+func (s *Store) ListComponents(ctx context.Context, pageID uuid.UUID) ([]Component, error) {
+	rows, err := s.dbtx.Query(ctx, componentSelect+` WHERE c.page_id = $1 ORDER BY c.position, c.name`, pageID)
+	if err != nil {
+		return nil, fmt.Errorf("listing status components: %w", err)
+	}
+	defer rows.Close()
+
+	components := []Component{}
+	for rows.Next() {
+		c, err := scanComponent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning status component: %w", err)
+		}
+		components = append(components, c)
+	}
+	return components, rows.Err()
+}
+
+// GetComponent returns one component of a page.
+func (s *Store) GetComponent(ctx context.Context, pageID, id uuid.UUID) (Component, error) {
+	return scanComponent(s.dbtx.QueryRow(ctx, componentSelect+` WHERE c.page_id = $1 AND c.id = $2`, pageID, id))
+}
+
+// CreateComponent adds a service to a page. The component is named after
+// the service unless the request names it.
+func (s *Store) CreateComponent(ctx context.Context, pageID uuid.UUID, req ComponentRequest) (Component, error) {
+	var id uuid.UUID
+	err := s.dbtx.QueryRow(ctx, `
+		INSERT INTO status_components (page_id, service_id, name, description, position, status_override)
+		SELECT $1, s.id, COALESCE(NULLIF($3, ''), s.name), $4, $5, $6
+		FROM services s WHERE s.id = $2
+		RETURNING id`,
+		pageID, req.ServiceID, req.Name, req.Description, req.Position, req.StatusOverride).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Component{}, ErrServiceNotFound
+	}
+	if isUniqueViolation(err) {
+		return Component{}, ErrComponentExists
+	}
+	if err != nil {
+		return Component{}, fmt.Errorf("creating status component: %w", err)
+	}
+	return s.GetComponent(ctx, pageID, id)
+}
+
+// UpdateComponent replaces a component's settings.
+func (s *Store) UpdateComponent(ctx context.Context, pageID, id uuid.UUID, req ComponentRequest) (Component, error) {
+	tag, err := s.dbtx.Exec(ctx, `
+		UPDATE status_components c
+		SET service_id = s.id, name = COALESCE(NULLIF($4, ''), s.name), description = $5,
+		    position = $6, status_override = $7, updated_at = now()
+		FROM services s
+		WHERE c.page_id = $1 AND c.id = $2 AND s.id = $3`,
+		pageID, id, req.ServiceID, req.Name, req.Description, req.Position, req.StatusOverride)
+	if isUniqueViolation(err) {
+		return Component{}, ErrComponentExists
+	}
+	if err != nil {
+		return Component{}, fmt.Errorf("updating status component: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		// Tell a missing component from a missing service.
+		if _, err := s.GetComponent(ctx, pageID, id); err != nil {
+			return Component{}, err
+		}
+		return Component{}, ErrServiceNotFound
+	}
+	return s.GetComponent(ctx, pageID, id)
+}
+
+// SetComponentStatus sets a component's manual status, or clears it when
+// status is empty so the status is derived from alerts again.
+func (s *Store) SetComponentStatus(ctx context.Context, pageID, id uuid.UUID, status string) (Component, error) {
+	tag, err := s.dbtx.Exec(ctx, `
+		UPDATE status_components SET status_override = NULLIF($3, ''), updated_at = now()
+		WHERE page_id = $1 AND id = $2`, pageID, id, status)
+	if err != nil {
+		return Component{}, fmt.Errorf("setting component status: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return Component{}, ErrComponentNotFound
+	}
+	return s.GetComponent(ctx, pageID, id)
+}
+
+// DeleteComponent removes a component from a page.
+func (s *Store) DeleteComponent(ctx context.Context, pageID, id uuid.UUID) error {
+	tag, err := s.dbtx.Exec(ctx, `DELETE FROM status_components WHERE page_id = $1 AND id = $2`, pageID, id)
+	if err != nil {
+		return fmt.Errorf("deleting status component: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrComponentNotFound
+	}
+	return nil
+}
+
+func scanIncident(row pgx.Row) (Incident, error) {
+	var inc Incident
+	var createdBy pgtype.UUID
+	var resolvedAt pgtype.Timestamptz
+	if err := row.Scan(&inc.ID, &inc.PageID, &inc.Title, &inc.Status, &inc.Impact, &inc.ComponentIDs,
+		&createdBy, &inc.CreatedAt, &inc.UpdatedAt, &resolvedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Incident{}, ErrIncidentNotFound
+		}
+		return Incident{}, err
+	}
+	if inc.ComponentIDs == nil {
+		inc.ComponentIDs = []uuid.UUID{}
+	}
+	inc.Updates = []IncidentUpdate{}
+	inc.CreatedBy = pgtypeUUIDToPtr(createdBy)
+	if resolvedAt.Valid {
+		inc.ResolvedAt = &resolvedAt.Time
+	}
+	return inc, nil
+}
+
+func scanUpdate(row pgx.Row) (IncidentUpdate, error) {
+	var u IncidentUpdate
+	var createdBy pgtype.UUID
+	var notifiedAt pgtype.Timestamptz
+	if err := row.Scan(&u.ID, &u.IncidentID, &u.Status, &u.Body, &u.Notify, &notifiedAt,
+		&createdBy, &u.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return IncidentUpdate{}, ErrIncidentNotFound
+		}
+		return IncidentUpdate{}, err
+	}
+	u.CreatedBy = pgtypeUUIDToPtr(createdBy)
+	if notifiedAt.Valid {
+		u.NotifiedAt = &notifiedAt.Time
+	}
+	return u, nil
+}
+
+// queryIncidents returns the incidents a query selects with their updates.
+func (s *Store) queryIncidents(ctx context.Context, sql string, args ...any) ([]Incident, error) {
+	rows, err := s.dbtx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	incidents := []Incident{}
+	for rows.Next() {
+		inc, err := scanIncident(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning status incident: %w", err)
+		}
+		incidents = append(incidents, inc)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(incidents) == 0 {
+		return incidents, nil
+	}
+
+	ids := make([]uuid.UUID, len(incidents))
+	byID := make(map[uuid.UUID]*Incident, len(incidents))
+	for i := range incidents {
+		ids[i] = incidents[i].ID
+		byID[incidents[i].ID] = &incidents[i]
+	}
+	rows, err = s.dbtx.Query(ctx, `
+		SELECT `+updateColumns+` FROM status_incident_updates
+		WHERE incident_id = ANY($1) ORDER BY created_at DESC`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("listing incident updates: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		u, err := scanUpdate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning incident update: %w", err)
+		}
+		inc := byID[u.IncidentID]
+		inc.Updates = append(inc.Updates, u)
+	}
+	return incidents, rows.Err()
+}
+
+// ListIncidents returns a page's latest incidents, newest first.
+func (s *Store) ListIncidents(ctx context.Context, pageID uuid.UUID, limit int) ([]Incident, error) {
+	incidents, err := s.queryIncidents(ctx, `
+		SELECT `+incidentColumns+` FROM status_incidents
+		WHERE page_id = $1 ORDER BY created_at DESC LIMIT $2`, pageID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("listing status incidents: %w", err)
+	}
+	return incidents, nil
+}
+
+// currentIncidents returns a page's unresolved incidents and those resolved
+// since the given time, newest first.
+func (s *Store) currentIncidents(ctx context.Context, pageID uuid.UUID, resolvedSince time.Time) ([]Incident, error) {
+	incidents, err := s.queryIncidents(ctx, `
+		SELECT `+incidentColumns+` FROM status_incidents
+		WHERE page_id = $1 AND (resolved_at IS NULL OR resolved_at >= $2)
+		ORDER BY created_at DESC`, pageID, resolvedSince)
+	if err != nil {
+		return nil, fmt.Errorf("listing current status incidents: %w", err)
+	}
+	return incidents, nil
+}
+
+// GetIncident returns one incident of a page with its updates.
+func (s *Store) GetIncident(ctx context.Context, pageID, id uuid.UUID) (Incident, error) {
+	incidents, err := s.queryIncidents(ctx, `
+		SELECT `+incidentColumns+` FROM status_incidents WHERE page_id = $1 AND id = $2`, pageID, id)
+	if err != nil {
+		return Incident{}, fmt.Errorf("getting status incident: %w", err)
+	}
+	if len(incidents) == 0 {
+		return Incident{}, ErrIncidentNotFound
+	}
+	return incidents[0], nil
+}
+
+// CreateIncident opens an incident with its first update. Component IDs
+// that are not components of the page are dropped.
+func (s *Store) CreateIncident(ctx context.Context, pageID uuid.UUID, req IncidentRequest, createdBy pgtype.UUID) (Incident, error) {
+	var id uuid.UUID
+	err := s.dbtx.QueryRow(ctx, `
+		WITH inc AS (
+		    INSERT INTO status_incidents (page_id, component_ids, title, status, impact, created_by, resolved_at)
+		    VALUES ($1, `+pageComponentIDs+`, $3, $4, $5, $6, CASE WHEN $4 = 'resolved' THEN now() END)
+		    RETURNING id
+		), upd AS (
+		    INSERT INTO status_incident_updates (incident_id, status, body, notify, created_by)
+		    SELECT id, $4, $7, $8, $6 FROM inc
+		)
+		SELECT id FROM inc`,
+		pageID, req.ComponentIDs, req.Title, req.Status, impactOrDefault(req.Impact), createdBy,
+		req.Body, notifyOrDefault(req.Notify)).Scan(&id)
+	if err != nil {
+		return Incident{}, fmt.Errorf("creating status incident: %w", err)
+	}
+	return s.GetIncident(ctx, pageID, id)
+}
+
+// EditIncident corrects an incident's title, impact and components.
+func (s *Store) EditIncident(ctx context.Context, pageID, id uuid.UUID, req IncidentEditRequest) (Incident, error) {
+	tag, err := s.dbtx.Exec(ctx, `
+		UPDATE status_incidents
+		SET component_ids = `+pageComponentIDs+`, title = $4, impact = $5, updated_at = now()
+		WHERE page_id = $1 AND id = $3`,
+		pageID, req.ComponentIDs, id, req.Title, req.Impact)
+	if err != nil {
+		return Incident{}, fmt.Errorf("updating status incident: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return Incident{}, ErrIncidentNotFound
+	}
+	return s.GetIncident(ctx, pageID, id)
+}
+
+// PostUpdate adds an update to an incident's timeline and moves the
+// incident to the update's status. Resolving sets resolved_at; any other
+// status reopens the incident.
+func (s *Store) PostUpdate(ctx context.Context, pageID, id uuid.UUID, req UpdateRequest, createdBy pgtype.UUID) (Incident, error) {
+	_, err := scanUpdate(s.dbtx.QueryRow(ctx, `
+		WITH inc AS (
+		    UPDATE status_incidents
+		    SET status = $3, updated_at = now(),
+		        resolved_at = CASE WHEN $3 = 'resolved' THEN COALESCE(resolved_at, now()) END
+		    WHERE page_id = $1 AND id = $2
+		    RETURNING id
+		)
+		INSERT INTO status_incident_updates (incident_id, status, body, notify, created_by)
+		SELECT id, $3, $4, $5, $6 FROM inc
+		RETURNING `+updateColumns,
+		pageID, id, req.Status, req.Body, notifyOrDefault(req.Notify), createdBy))
+	if errors.Is(err, ErrIncidentNotFound) {
+		return Incident{}, err
+	}
+	if err != nil {
+		return Incident{}, fmt.Errorf("posting incident update: %w", err)
+	}
+	return s.GetIncident(ctx, pageID, id)
+}
+
+// DeleteIncident deletes an incident with its timeline.
+func (s *Store) DeleteIncident(ctx context.Context, pageID, id uuid.UUID) error {
+	tag, err := s.dbtx.Exec(ctx, `DELETE FROM status_incidents WHERE page_id = $1 AND id = $2`, pageID, id)
+	if err != nil {
+		return fmt.Errorf("deleting status incident: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrIncidentNotFound
+	}
+	return nil
+}
+
+func scanSubscriber(row pgx.Row) (Subscriber, error) {
+	var sub Subscriber
+	var confirmedAt pgtype.Timestamptz
+	if err := row.Scan(&sub.ID, &sub.PageID, &sub.Email, &confirmedAt, &sub.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Subscriber{}, ErrSubscriberNotFound
+		}
+		return Subscriber{}, err
+	}
+	if confirmedAt.Valid {
+		sub.ConfirmedAt = &confirmedAt.Time
+	}
+	return sub, nil
+}
+
+func (s *Store) querySubscribers(ctx context.Context, sql string, args ...any) ([]Subscriber, error) {
+	rows, err := s.dbtx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []Subscriber{}
+	for rows.Next() {
+		sub, err := scanSubscriber(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning subscriber: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// normalizeEmail lowercases an address so each is subscribed once.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ListSubscribers returns a page's subscribers ordered by address.
+func (s *Store) ListSubscribers(ctx context.Context, pageID uuid.UUID) ([]Subscriber, error) {
+	subs, err := s.querySubscribers(ctx,
+		`SELECT `+subscriberColumns+` FROM status_subscribers WHERE page_id = $1 ORDER BY email`, pageID)
+	if err != nil {
+		return nil, fmt.Errorf("listing subscribers: %w", err)
+	}
+	return subs, nil
+}
+
+// AddSubscriber subscribes a confirmed address, for admins adding
+// subscribers directly.
+func (s *Store) AddSubscriber(ctx context.Context, pageID uuid.UUID, email string) (Subscriber, error) {
+	sub, err := scanSubscriber(s.dbtx.QueryRow(ctx, `
+		INSERT INTO status_subscribers (page_id, email, confirmed_at)
+		VALUES ($1, $2, now())
+		RETURNING `+subscriberColumns,
+		pageID, normalizeEmail(email)))
+	if isUniqueViolation(err) {
+		return Subscriber{}, ErrSubscriberExists
+	}
+	if err != nil {
+		return Subscriber{}, fmt.Errorf("adding subscriber: %w", err)
+	}
+	return sub, nil
+}
+
+// Subscribe subscribes an unconfirmed address, or returns the existing
+// subscription of an address that is already subscribed.
+func (s *Store) Subscribe(ctx context.Context, pageID uuid.UUID, email string) (Subscriber, error) {
+	sub, err := scanSubscriber(s.dbtx.QueryRow(ctx, `
+		INSERT INTO status_subscribers (page_id, email)
+		VALUES ($1, $2)
+		ON CONFLICT (page_id, email) DO UPDATE SET email = EXCLUDED.email
+		RETURNING `+subscriberColumns,
+		pageID, normalizeEmail(email)))
+	if err != nil {
+		return Subscriber{}, fmt.Errorf("subscribing: %w", err)
+	}
+	return sub, nil
+}
+
+// claimConfirmation records that a confirmation link is being mailed to an
+// unconfirmed subscriber. It reports false if one was mailed within
+// interval, so concurrent requests send at most one.
+func (s *Store) claimConfirmation(ctx context.Context, id uuid.UUID, interval time.Duration) (bool, error) {
+	tag, err := s.dbtx.Exec(ctx, `
+		UPDATE status_subscribers SET confirmation_sent_at = now()
+		WHERE id = $1 AND confirmed_at IS NULL
+		  AND (confirmation_sent_at IS NULL OR confirmation_sent_at < now() - $2::interval)`,
+		id, interval)
+	if err != nil {
+		return false, fmt.Errorf("claiming subscription confirmation: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// releaseConfirmation undoes claimConfirmation after the mail failed.
+func (s *Store) releaseConfirmation(ctx context.Context, id uuid.UUID) error {
+	_, err := s.dbtx.Exec(ctx,
+		`UPDATE status_subscribers SET confirmation_sent_at = NULL WHERE id = $1`, id)
+	return err
+}
+
+// Confirm confirms a subscription.
+func (s *Store) Confirm(ctx context.Context, pageID, id uuid.UUID) (Subscriber, error) {
+	return scanSubscriber(s.dbtx.QueryRow(ctx, `
+		UPDATE status_subscribers SET confirmed_at = COALESCE(confirmed_at, now())
+		WHERE page_id = $1 AND id = $2
+		RETURNING `+subscriberColumns, pageID, id))
+}
+
+// DeleteSubscriber deletes a subscriber by ID.
+func (s *Store) DeleteSubscriber(ctx context.Context, pageID, id uuid.UUID) (Subscriber, error) {
+	return scanSubscriber(s.dbtx.QueryRow(ctx, `
+		DELETE FROM status_subscribers WHERE page_id = $1 AND id = $2
+		RETURNING `+subscriberColumns, pageID, id))
+}
+
+// pendingUpdate is an incident update still to be mailed, with its
+// incident and page.
+type pendingUpdate struct {
+	IncidentUpdate
+	title string
+	page  Page
+}
+
+// pendingUpdates returns the oldest updates still to be mailed.
+func (s *Store) pendingUpdates(ctx context.Context, limit int) ([]pendingUpdate, error) {
+	rows, err := s.dbtx.Query(ctx, `
+		SELECT u.id, u.incident_id, u.status, u.body, u.notify, u.notified_at, u.created_by, u.created_at,
+		    i.title, p.id, p.slug, p.name, p.description, p.public, p.created_at, p.updated_at
+		FROM status_incident_updates u
+		JOIN status_incidents i ON i.id = u.incident_id
+		JOIN status_pages p ON p.id = i.page_id
+		WHERE u.notify AND u.notified_at IS NULL
+		ORDER BY u.created_at
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("listing pending incident updates: %w", err)
+	}
+	defer rows.Close()
+
+	var pending []pendingUpdate
+	for rows.Next() {
+		var u pendingUpdate
+		var createdBy pgtype.UUID
+		var notifiedAt pgtype.Timestamptz
+		if err := rows.Scan(&u.ID, &u.IncidentID, &u.Status, &u.Body, &u.Notify, &notifiedAt, &createdBy, &u.CreatedAt,
+			&u.title, &u.page.ID, &u.page.Slug, &u.page.Name, &u.page.Description, &u.page.Public,
+			&u.page.CreatedAt, &u.page.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning pending incident update: %w", err)
+		}
+		u.CreatedBy = pgtypeUUIDToPtr(createdBy)
+		pending = append(pending, u)
+	}
+	return pending, rows.Err()
+}
+
+// confirmedSubscribers returns a page's confirmed subscribers.
+func (s *Store) confirmedSubscribers(ctx context.Context, pageID uuid.UUID) ([]Subscriber, error) {
+	subs, err := s.querySubscribers(ctx, `
+		SELECT `+subscriberColumns+` FROM status_subscribers
+		WHERE page_id = $1 AND confirmed_at IS NOT NULL ORDER BY email`, pageID)
+	if err != nil {
+		return nil, fmt.Errorf("listing confirmed subscribers: %w", err)
+	}
+	return subs, nil
+}
+
+// markNotified records that an update was mailed.
+func (s *Store) markNotified(ctx context.Context, id uuid.UUID) error {
+	if _, err := s.dbtx.Exec(ctx,
+		`UPDATE status_incident_updates SET notified_at = now() WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("marking incident update notified: %w", err)
+	}
+	return nil
+}
+
+// pruneUnconfirmed deletes subscriptions left unconfirmed since before.
+func (s *Store) pruneUnconfirmed(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := s.dbtx.Exec(ctx,
+		`DELETE FROM status_subscribers WHERE confirmed_at IS NULL AND created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("pruning unconfirmed subscribers: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
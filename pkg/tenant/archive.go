@@ -0,0 +1,494 @@
+package tenant
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/wisbric/core/pkg/version"
+)
+
+// ArchiveFormat identifies a NightOwl tenant archive; ArchiveFormatVersion
+// is bumped whenever the archive layout changes incompatibly.
+const (
+	ArchiveFormat        = "nightowl-tenant-archive"
+	ArchiveFormatVersion = 1
+)
+
+const manifestName = "manifest.json"
+
+var (
+	// ErrInvalidArchive is returned for archives that are malformed or not
+	// NightOwl tenant archives.
+	ErrInvalidArchive = errors.New("invalid tenant archive")
+	// ErrMigrationMismatch is returned when an archive's schema version does
+	// not match the tenant migrations of the importing binary.
+	ErrMigrationMismatch = errors.New("archive migration version does not match target")
+)
+
+// archiveTable describes a tenant table included in archives. Tables are
+// listed in foreign-key order so that importing them in sequence satisfies
+// every reference.
+type archiveTable struct {
+	Name string
+	// Deferred holds self-referencing columns, set after all rows of the
+	// table are inserted.
+	Deferred []string
+	// Omit holds derived columns that are rebuilt on insert.
+	Omit []string
+	// Alerts marks alert data, only exported on request.
+	Alerts bool
+	// AlertRefs holds columns referring to alert data, with the values they
+	// are reset to in archives without alerts.
+	AlertRefs map[string]any
+}
+
+var archiveTables = []archiveTable{
+	{Name: "users"},
+	{Name: "services"},
+	{Name: "escalation_policies"},
+	{Name: "runbooks"},
+	{Name: "rosters", Deferred: []string{"linked_roster_id"}},
+	{Name: "roster_members"},
+	{Name: "roster_layers"},
+	{Name: "roster_layer_members"},
+	{Name: "roster_schedule"},
+	{Name: "roster_overrides"},
+	{Name: "unavailability_calendars"},
+	{Name: "user_unavailability"},
+	{Name: "shift_swap_requests"},
+	{Name: "alert_grouping_rules"},
+	{Name: "webhook_integrations"},
+	{Name: "incidents", Deferred: []string{"merged_into_id"}, Omit: []string{"search_vector"}},
+	{Name: "incident_history"},
+	{Name: "status_pages"},
+	{Name: "status_components"},
+	{Name: "status_incidents"},
+	{Name: "status_incident_updates"},
+	{Name: "status_subscribers"},
+	{Name: "alert_groups", Alerts: true},
+	{Name: "alerts", Alerts: true},
+	{Name: "escalation_events", Alerts: true},
+	// Without its alert a down monitor raises a new one after import.
+	{Name: "heartbeat_monitors", AlertRefs: map[string]any{"alert_id": nil, "chat_messages": []any{}}},
+}
+
+// Manifest is the archive header, stored as manifest.json.
+type Manifest struct {
+	Format           string           `json:"format"`
+	FormatVersion    int              `json:"format_version"`
+	MigrationVersion uint             `json:"migration_version"`
+	ExportedAt       time.Time        `json:"exported_at"`
+	NightOwlVersion  string           `json:"nightowl_version"`
+	IncludesAlerts   bool             `json:"includes_alerts"`
+	Tenant           ManifestTenant   `json:"tenant"`
+	Tables           []ManifestTables `json:"tables"`
+}
+
+// ManifestTenant is the exported tenant's global record.
+type ManifestTenant struct {
+	Name   string          `json:"name"`
+	Slug   string          `json:"slug"`
+	Config json.RawMessage `json:"config"`
+}
+
+// ManifestTables records how many rows of a table an archive holds.
+type ManifestTables struct {
+	Name string `json:"name"`
+	Rows int    `json:"rows"`
+}
+
+// Row is one table row keyed by column name.
+type Row = map[string]any
+
+// Archive is a tenant's data in memory: the manifest plus rows per table.
+type Archive struct {
+	Manifest Manifest
+	Tables   map[string][]Row
+}
+
+// tableFile is the archive path of a table's newline-delimited JSON rows.
+func tableFile(table string) string {
+	return path.Join("tables", table+".ndjson")
+}
+
+// WriteArchive writes a as a gzip-compressed tar: manifest.json first, then
+// one NDJSON file per table.
+func WriteArchive(w io.Writer, a *Archive) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	manifest, err := json.MarshalIndent(a.Manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding manifest: %w", err)
+	}
+	if err := writeTarFile(tw, manifestName, manifest, a.Manifest.ExportedAt); err != nil {
+		return err
+	}
+	for _, t := range a.Manifest.Tables {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, row := range a.Tables[t.Name] {
+			if err := enc.Encode(row); err != nil {
+				return fmt.Errorf("encoding %s row: %w", t.Name, err)
+			}
+		}
+		if err := writeTarFile(tw, tableFile(t.Name), buf.Bytes(), a.Manifest.ExportedAt); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("closing archive: %w", err)
+	}
+	return gz.Close()
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	hdr := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: modTime}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("writing %s header: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	return nil
+}
+
+// ReadArchive reads and validates an archive written by WriteArchive.
+func ReadArchive(r io.Reader) (*Archive, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer func() { _ = gz.Close() }()
+	tr := tar.NewReader(gz)
+
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != manifestName {
+		return nil, fmt.Errorf("%w: %s must be the first entry", ErrInvalidArchive, manifestName)
+	}
+	a := &Archive{Tables: map[string][]Row{}}
+	if err := json.NewDecoder(tr).Decode(&a.Manifest); err != nil {
+		return nil, fmt.Errorf("%w: decoding manifest: %v", ErrInvalidArchive, err)
+	}
+	if a.Manifest.Format != ArchiveFormat {
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidArchive, a.Manifest.Format)
+	}
+	if a.Manifest.FormatVersion != ArchiveFormatVersion {
+		return nil, fmt.Errorf("%w: unsupported format version %d", ErrInvalidArchive, a.Manifest.FormatVersion)
+	}
+
+	expected := map[string]int{}
+	for _, t := range a.Manifest.Tables {
+		if lookupArchiveTable(t.Name) == nil {
+			return nil, fmt.Errorf("%w: unknown table %q", ErrInvalidArchive, t.Name)
+		}
+		expected[tableFile(t.Name)] = t.Rows
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+		want, ok := expected[hdr.Name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unexpected entry %q", ErrInvalidArchive, hdr.Name)
+		}
+		table := strings.TrimSuffix(path.Base(hdr.Name), ".ndjson")
+		rows, err := readRows(tr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidArchive, hdr.Name, err)
+		}
+		if len(rows) != want {
+			return nil, fmt.Errorf("%w: %s has %d rows, manifest says %d", ErrInvalidArchive, table, len(rows), want)
+		}
+		a.Tables[table] = rows
+		delete(expected, hdr.Name)
+	}
+	for name := range expected {
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalidArchive, name)
+	}
+	return a, nil
+}
+
+func readRows(r io.Reader) ([]Row, error) {
+	rows := []Row{}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for sc.Scan() {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		dec := json.NewDecoder(bytes.NewReader(sc.Bytes()))
+		dec.UseNumber()
+		var row Row
+		if err := dec.Decode(&row); err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, sc.Err()
+}
+
+func lookupArchiveTable(name string) *archiveTable {
+	for i := range archiveTables {
+		if archiveTables[i].Name == name {
+			return &archiveTables[i]
+		}
+	}
+	return nil
+}
+
+// RemapIDs gives every row a fresh "id" and rewrites all references to the
+// old IDs, including those nested in JSON columns such as escalation tiers.
+// It returns the old-to-new mapping.
+func (a *Archive) RemapIDs() map[string]string {
+	ids := map[string]string{}
+	for _, rows := range a.Tables {
+		for _, row := range rows {
+			if id, ok := row["id"].(string); ok {
+				if _, err := uuid.Parse(id); err == nil {
+					ids[id] = uuid.NewString()
+				}
+			}
+		}
+	}
+	for _, rows := range a.Tables {
+		for i := range rows {
+			rows[i] = remapValue(rows[i], ids).(Row)
+		}
+	}
+	return ids
+}
+
+func remapValue(v any, ids map[string]string) any {
+	switch v := v.(type) {
+	case string:
+		if id, ok := ids[v]; ok {
+			return id
+		}
+		return v
+	case map[string]any:
+		for k, inner := range v {
+			v[k] = remapValue(inner, ids)
+		}
+		return v
+	case []any:
+		for i, inner := range v {
+			v[i] = remapValue(inner, ids)
+		}
+		return v
+	}
+	return v
+}
+
+// =====================
+// Service operations
+// =====================
+
+// Export reads one tenant's data into an archive inside a single read-only
+// snapshot. Alerts, alert groups and escalation events are included only
+// when includeAlerts is set.
+func (s *Service) Export(ctx context.Context, slug string, includeAlerts bool) (*Archive, error) {
+	rec, err := s.store.Get(ctx, slug)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	migVersion, dirty, err := s.store.MigrationVersion(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if dirty {
+		return nil, fmt.Errorf("tenant %s has a dirty migration at version %d", slug, migVersion)
+	}
+	var config json.RawMessage
+	if err := s.prov.DB.QueryRow(ctx, `SELECT config FROM public.tenants WHERE slug = $1`, slug).Scan(&config); err != nil {
+		return nil, fmt.Errorf("reading tenant config: %w", err)
+	}
+
+	a := &Archive{
+		Manifest: Manifest{
+			Format:           ArchiveFormat,
+			FormatVersion:    ArchiveFormatVersion,
+			MigrationVersion: migVersion,
+			ExportedAt:       time.Now().UTC(),
+			NightOwlVersion:  version.Version,
+			IncludesAlerts:   includeAlerts,
+			Tenant:           ManifestTenant{Name: rec.Name, Slug: rec.Slug, Config: config},
+		},
+		Tables: map[string][]Row{},
+	}
+
+	tx, err := s.prov.DB.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("starting export transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if _, err := tx.Exec(ctx, "SELECT set_config('search_path', $1, true)", SchemaName(slug)); err != nil {
+		return nil, fmt.Errorf("setting search_path: %w", err)
+	}
+
+	for _, t := range archiveTables {
+		if t.Alerts && !includeAlerts {
+			continue
+		}
+		rows, err := exportTable(ctx, tx, t)
+		if err != nil {
+			return nil, err
+		}
+		if !includeAlerts {
+			t.resetAlertRefs(rows)
+		}
+		a.Tables[t.Name] = rows
+		a.Manifest.Tables = append(a.Manifest.Tables, ManifestTables{Name: t.Name, Rows: len(rows)})
+	}
+	s.logger.Info("tenant exported", "slug", slug, "migration_version", migVersion, "alerts", includeAlerts)
+	return a, nil
+}
+
+// resetAlertRefs clears rows' references to alert data left out of an
+// archive.
+func (t archiveTable) resetAlertRefs(rows []Row) {
+	for _, row := range rows {
+		for col, v := range t.AlertRefs {
+			row[col] = v
+		}
+	}
+}
+
+func exportTable(ctx context.Context, tx pgx.Tx, t archiveTable) ([]Row, error) {
+	expr := "to_jsonb(t)"
+	for _, col := range t.Omit {
+		expr += " - '" + col + "'"
+	}
+	rows, err := tx.Query(ctx, `SELECT (`+expr+`)::text FROM `+pgx.Identifier{t.Name}.Sanitize()+` t`)
+	if err != nil {
+		return nil, fmt.Errorf("exporting %s: %w", t.Name, err)
+	}
+	raws, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("exporting %s: %w", t.Name, err)
+	}
+	out := make([]Row, 0, len(raws))
+	for _, raw := range raws {
+		dec := json.NewDecoder(strings.NewReader(raw))
+		dec.UseNumber()
+		var row Row
+		if err := dec.Decode(&row); err != nil {
+			return nil, fmt.Errorf("decoding %s row: %w", t.Name, err)
+		}
+		out = append(out, row)
+	}
+	return out, nil
+}
+
+// Import provisions a new tenant from an archive. slug and name default to
+// the exported tenant's. Every row gets a new ID. The archive must come from
+// the same tenant migration version this binary provisions; on failure the
+// new tenant is deprovisioned again.
+func (s *Service) Import(ctx context.Context, a *Archive, slug, name string) (Response, error) {
+	latest, err := LatestMigrationVersion(s.prov.MigrationsDir)
+	if err != nil {
+		return Response{}, err
+	}
+	if a.Manifest.MigrationVersion != latest {
+		return Response{}, fmt.Errorf("%w: archive is at version %d, target migrations are at %d",
+			ErrMigrationMismatch, a.Manifest.MigrationVersion, latest)
+	}
+	if slug == "" {
+		slug = a.Manifest.Tenant.Slug
+	}
+	if name == "" {
+		name = a.Manifest.Tenant.Name
+	}
+
+	if _, err := s.Create(ctx, name, slug, a.Manifest.Tenant.Config); err != nil {
+		return Response{}, err
+	}
+	ids := a.RemapIDs()
+	if err := s.importRows(ctx, slug, a); err != nil {
+		if derr := s.prov.Deprovision(ctx, slug); derr != nil {
+			s.logger.Error("removing partially imported tenant", "slug", slug, "error", derr)
+		}
+		return Response{}, err
+	}
+	s.logger.Info("tenant imported", "slug", slug, "from", a.Manifest.Tenant.Slug, "ids_remapped", len(ids))
+	return s.Get(ctx, slug)
+}
+
+func (s *Service) importRows(ctx context.Context, slug string, a *Archive) error {
+	tx, err := s.prov.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("starting import transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if _, err := tx.Exec(ctx, "SELECT set_config('search_path', $1, true)", SchemaName(slug)); err != nil {
+		return fmt.Errorf("setting search_path: %w", err)
+	}
+
+	for _, t := range archiveTables {
+		rows, ok := a.Tables[t.Name]
+		if !ok {
+			continue
+		}
+		if err := importTable(ctx, tx, t, rows); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing import: %w", err)
+	}
+	return nil
+}
+
+func importTable(ctx context.Context, tx pgx.Tx, t archiveTable, rows []Row) error {
+	table := pgx.Identifier{t.Name}.Sanitize()
+	insert := `INSERT INTO ` + table + ` SELECT * FROM json_populate_record(NULL::` + table + `, $1::json)`
+
+	deferred := map[string]Row{}
+	for _, row := range rows {
+		later := Row{}
+		for _, col := range t.Deferred {
+			if v, ok := row[col]; ok && v != nil {
+				later[col] = v
+				row[col] = nil
+			}
+		}
+		data, err := json.Marshal(row)
+		if err != nil {
+			return fmt.Errorf("encoding %s row: %w", t.Name, err)
+		}
+		if _, err := tx.Exec(ctx, insert, string(data)); err != nil {
+			return fmt.Errorf("importing %s: %w", t.Name, err)
+		}
+		if id, ok := row["id"].(string); ok && len(later) > 0 {
+			deferred[id] = later
+		}
+	}
+
+	for id, cols := range deferred {
+		for col, v := range cols {
+			q := `UPDATE ` + table + ` SET ` + pgx.Identifier{col}.Sanitize() + ` = $2 WHERE id = $1`
+			if _, err := tx.Exec(ctx, q, id, v); err != nil {
+				return fmt.Errorf("importing %s.%s: %w", t.Name, col, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package tenant
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testArchive() *Archive {
+	return &Archive{
+		Manifest: Manifest{
+			Format:           ArchiveFormat,
+			FormatVersion:    ArchiveFormatVersion,
+			MigrationVersion: 29,
+			ExportedAt:       time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+			Tenant:           ManifestTenant{Name: "Acme", Slug: "acme", Config: json.RawMessage(`{"timezone":"UTC"}`)},
+			Tables: []ManifestTables{
+				{Name: "users", Rows: 2},
+				{Name: "escalation_policies", Rows: 1},
+				{Name: "rosters", Rows: 0},
+				{Name: "services", Rows: 1},
+				{Name: "status_pages", Rows: 1},
+				{Name: "status_components", Rows: 1},
+				{Name: "status_incidents", Rows: 1},
+				{Name: "status_incident_updates", Rows: 1},
+				{Name: "status_subscribers", Rows: 1},
+				{Name: "heartbeat_monitors", Rows: 1},
+			},
+		},
+		Tables: map[string][]Row{
+			"users": {
+				{"id": "11111111-1111-1111-1111-111111111111", "email": "alice@example.com"},
+				{"id": "22222222-2222-2222-2222-222222222222", "email": "bob@example.com"},
+			},
+			"escalation_policies": {
+				{
+					"id":   "33333333-3333-3333-3333-333333333333",
+					"name": "Default",
+					"tiers": []any{
+						map[string]any{"tier": json.Number("1"), "user_ids": []any{"22222222-2222-2222-2222-222222222222"}},
+					},
+				},
+			},
+			"rosters": {},
+			"services": {
+				{"id": "44444444-4444-4444-4444-444444444444", "name": "api"},
+			},
+			"status_pages": {
+				{"id": "55555555-5555-5555-5555-555555555555", "slug": "public", "public": true},
+			},
+			"status_components": {
+				{
+					"id":         "66666666-6666-6666-6666-666666666666",
+					"page_id":    "55555555-5555-5555-5555-555555555555",
+					"service_id": "44444444-4444-4444-4444-444444444444",
+				},
+			},
+			"status_incidents": {
+				{
+					"id":            "77777777-7777-7777-7777-777777777777",
+					"page_id":       "55555555-5555-5555-5555-555555555555",
+					"component_ids": []any{"66666666-6666-6666-6666-666666666666"},
+					"created_by":    "11111111-1111-1111-1111-111111111111",
+				},
+			},
+			"status_incident_updates": {
+				{
+					"id":          "88888888-8888-8888-8888-888888888888",
+					"incident_id": "77777777-7777-7777-7777-777777777777",
+					"created_by":  "11111111-1111-1111-1111-111111111111",
+				},
+			},
+			"status_subscribers": {
+				{
+					"id":      "99999999-9999-9999-9999-999999999999",
+					"page_id": "55555555-5555-5555-5555-555555555555",
+					"email":   "ops@example.com",
+				},
+			},
+			"heartbeat_monitors": {
+				{
+					"id":            "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa",
+					"service_id":    "44444444-4444-4444-4444-444444444444",
+					"created_by":    "22222222-2222-2222-2222-222222222222",
+					"token_hash":    "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+					"alert_id":      "bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb",
+					"chat_messages": []any{map[string]any{"provider": "slack", "ts": "1700000000.000100"}},
+				},
+			},
+		},
+	}
+}
+
+func TestArchive_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteArchive(&buf, testArchive()); err != nil {
+		t.Fatalf("WriteArchive: %v", err)
+	}
+	got, err := ReadArchive(&buf)
+	if err != nil {
+		t.Fatalf("ReadArchive: %v", err)
+	}
+	if got.Manifest.MigrationVersion != 29 || got.Manifest.Tenant.Slug != "acme" {
+		t.Errorf("manifest = %+v", got.Manifest)
+	}
+	if len(got.Tables["users"]) != 2 || got.Tables["users"][1]["email"] != "bob@example.com" {
+		t.Errorf("users = %v", got.Tables["users"])
+	}
+	if rows, ok := got.Tables["rosters"]; !ok || len(rows) != 0 {
+		t.Errorf("rosters = %v, %v; want empty table", rows, ok)
+	}
+	for _, table := range []string{"status_pages", "status_components", "status_incidents",
+		"status_incident_updates", "status_subscribers", "heartbeat_monitors"} {
+		if len(got.Tables[table]) != 1 {
+			t.Errorf("%s = %v, want 1 row", table, got.Tables[table])
+		}
+	}
+	if got.Tables["heartbeat_monitors"][0]["token_hash"] != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Errorf("heartbeat monitor = %v, token hash not kept", got.Tables["heartbeat_monitors"][0])
+	}
+}
+
+func TestReadArchive_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(a *Archive)
+		want   string
+	}{
+		{name: "wrong format", mutate: func(a *Archive) { a.Manifest.Format = "other" }, want: "unknown format"},
+		{name: "future format version", mutate: func(a *Archive) { a.Manifest.FormatVersion = 99 }, want: "unsupported format version"},
+		{name: "unknown table", mutate: func(a *Archive) {
+			a.Manifest.Tables = append(a.Manifest.Tables, ManifestTables{Name: "audit_log"})
+		}, want: "unknown table"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := testArchive()
+			tt.mutate(a)
+			var buf bytes.Buffer
+			if err := WriteArchive(&buf, a); err != nil {
+				t.Fatalf("WriteArchive: %v", err)
+			}
+			_, err := ReadArchive(&buf)
+			if !errors.Is(err, ErrInvalidArchive) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want ErrInvalidArchive containing %q", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("row count mismatch", func(t *testing.T) {
+		// The manifest claims more users than the table file holds.
+		a := testArchive()
+		a.Manifest.Tables[0].Rows = 3
+		var buf bytes.Buffer
+		if err := WriteArchive(&buf, a); err != nil {
+			t.Fatalf("WriteArchive: %v", err)
+		}
+		if _, err := ReadArchive(&buf); !errors.Is(err, ErrInvalidArchive) {
+			t.Errorf("error = %v, want ErrInvalidArchive", err)
+		}
+	})
+
+	t.Run("not gzip", func(t *testing.T) {
+		if _, err := ReadArchive(strings.NewReader("plain text")); !errors.Is(err, ErrInvalidArchive) {
+			t.Errorf("error = %v, want ErrInvalidArchive", err)
+		}
+	})
+}
+
+func TestArchive_RemapIDs(t *testing.T) {
+	a := testArchive()
+	ids := a.RemapIDs()
+	if len(ids) != 10 {
+		t.Fatalf("remapped %d ids, want 10", len(ids))
+	}
+
+	bob := a.Tables["users"][1]["id"].(string)
+	if bob == "22222222-2222-2222-2222-222222222222" || bob != ids["22222222-2222-2222-2222-222222222222"] {
+		t.Errorf("bob id = %s, not remapped", bob)
+	}
+	tier := a.Tables["escalation_policies"][0]["tiers"].([]any)[0].(map[string]any)
+	if got := tier["user_ids"].([]any)[0]; got != bob {
+		t.Errorf("nested tier user id = %v, want %s", got, bob)
+	}
+	if a.Tables["users"][0]["email"] != "alice@example.com" {
+		t.Error("non-ID values must be left alone")
+	}
+
+	service := a.Tables["services"][0]["id"]
+	component := a.Tables["status_components"][0]
+	if component["service_id"] != service || component["page_id"] != a.Tables["status_pages"][0]["id"] {
+		t.Errorf("status component = %v, references not remapped", component)
+	}
+	incident := a.Tables["status_incidents"][0]
+	if incident["component_ids"].([]any)[0] != component["id"] || incident["created_by"] != a.Tables["users"][0]["id"] {
+		t.Errorf("status incident = %v, references not remapped", incident)
+	}
+	if update := a.Tables["status_incident_updates"][0]; update["incident_id"] != incident["id"] {
+		t.Errorf("status incident update = %v, incident not remapped", update)
+	}
+	monitor := a.Tables["heartbeat_monitors"][0]
+	if monitor["service_id"] != service || monitor["created_by"] != bob {
+		t.Errorf("heartbeat monitor = %v, references not remapped", monitor)
+	}
+}
+
+func TestArchiveTable_ResetAlertRefs(t *testing.T) {
+	a := testArchive()
+	rows := a.Tables["heartbeat_monitors"]
+	lookupArchiveTable("heartbeat_monitors").resetAlertRefs(rows)
+	if rows[0]["alert_id"] != nil || len(rows[0]["chat_messages"].([]any)) != 0 {
+		t.Errorf("heartbeat monitor = %v, alert references kept", rows[0])
+	}
+	if rows[0]["service_id"] == nil {
+		t.Error("other columns must be left alone")
+	}
+}
+
+// TestArchiveTables_ForeignKeyOrder checks that every table is listed after
+// the tables it references, so imports satisfy foreign keys in order.
+func TestArchiveTables_ForeignKeyOrder(t *testing.T) {
+	schema, err := os.ReadFile("../../sqlc/schema/tenant.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs := regexp.MustCompile(`(\w+)\s+[^,]*?REFERENCES (\w+)`)
+	seen := map[string]bool{}
+	for _, tbl := range archiveTables {
+		for _, m := range refs.FindAllStringSubmatch(createTable(string(schema), tbl.Name), -1) {
+			col, target := m[1], m[2]
+			switch {
+			case target == tbl.Name:
+				if !slices.Contains(tbl.Deferred, col) {
+					t.Errorf("%s.%s references its own table but is not deferred", tbl.Name, col)
+				}
+			case lookupArchiveTable(target) != nil && !seen[target]:
+				t.Errorf("%s.%s references %s, which is listed later", tbl.Name, col, target)
+			}
+		}
+		seen[tbl.Name] = true
+	}
+}
+
+// createTable returns the body of a CREATE TABLE statement in schema.
+func createTable(schema, table string) string {
+	_, body, ok := strings.Cut(schema, "CREATE TABLE "+table+" (")
+	if !ok {
+		return ""
+	}
+	body, _, _ = strings.Cut(body, "\n);")
+	return body
+}
+
+func TestArchiveTables_ExistInSchema(t *testing.T) {
+	schema, err := os.ReadFile("../../sqlc/schema/tenant.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tbl := range archiveTables {
+		if !strings.Contains(string(schema), "CREATE TABLE "+tbl.Name+" (") {
+			t.Errorf("archive table %s not in tenant schema", tbl.Name)
+		}
+	}
+}
+
+func TestService_ImportRejectsMigrationMismatch(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "000030_next.up.sql"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewService(&Provisioner{MigrationsDir: dir}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	_, err := svc.Import(context.Background(), testArchive(), "", "")
+	if !errors.Is(err, ErrMigrationMismatch) {
+		t.Errorf("error = %v, want ErrMigrationMismatch", err)
+	}
+}
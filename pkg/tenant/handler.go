@@ -0,0 +1,221 @@
+package tenant
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/wisbric/core/pkg/httpserver"
+)
+
+// Handler serves the platform (super-admin) tenant management API. It sits
+// outside the tenant-scoped /api/v1 router and authenticates with a static
+// platform token rather than a tenant identity.
+type Handler struct {
+	svc          *Service
+	logger       *slog.Logger
+	token        string
+	defaultGrace time.Duration
+}
+
+// NewHandler creates a platform tenant Handler. Requests must carry
+// "Authorization: Bearer <token>". defaultGrace applies to deletions that
+// do not specify a grace period.
+func NewHandler(svc *Service, logger *slog.Logger, token string, defaultGrace time.Duration) *Handler {
+	return &Handler{svc: svc, logger: logger, token: token, defaultGrace: defaultGrace}
+}
+
+// CreateRequest is the JSON body for POST /platform/tenants.
+type CreateRequest struct {
+	Name   string          `json:"name" validate:"required,min=1,max=255"`
+	Slug   string          `json:"slug" validate:"required"`
+	Config json.RawMessage `json:"config"`
+}
+
+// RenameRequest is the JSON body for PATCH /platform/tenants/{slug}.
+type RenameRequest struct {
+	Name string `json:"name" validate:"required,min=1,max=255"`
+}
+
+// Routes returns a chi.Router with the platform tenant routes mounted.
+func (h *Handler) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(h.requireToken)
+	r.Get("/", h.handleList)
+	r.Post("/", h.handleCreate)
+	r.Post("/migrate", h.handleMigrateAll)
+	r.Post("/purge", h.handlePurge)
+	r.Route("/{slug}", func(r chi.Router) {
+		r.Get("/", h.handleGet)
+		r.Patch("/", h.handleRename)
+		r.Delete("/", h.handleDelete)
+		r.Post("/suspend", h.handleSuspend)
+		r.Post("/resume", h.handleResume)
+		r.Post("/restore", h.handleRestore)
+		r.Post("/migrate", h.handleMigrate)
+	})
+	return r
+}
+
+func (h *Handler) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || h.token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(h.token)) != 1 {
+			httpserver.RespondError(w, http.StatusUnauthorized, "unauthorized", "invalid platform token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
+	items, err := h.svc.List(r.Context())
+	if err != nil {
+		h.logger.Error("listing tenants", "error", err)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to list tenants")
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]any{"tenants": items, "count": len(items)})
+}
+
+func (h *Handler) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var req CreateRequest
+	if !httpserver.DecodeAndValidate(w, r, &req) {
+		return
+	}
+	resp, err := h.svc.Create(r.Context(), req.Name, req.Slug, req.Config)
+	if err != nil {
+		h.respondErr(w, "creating tenant", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusCreated, resp)
+}
+
+func (h *Handler) handleGet(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.svc.Get(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		h.respondErr(w, "getting tenant", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, resp)
+}
+
+func (h *Handler) handleRename(w http.ResponseWriter, r *http.Request) {
+	var req RenameRequest
+	if !httpserver.DecodeAndValidate(w, r, &req) {
+		return
+	}
+	resp, err := h.svc.Rename(r.Context(), chi.URLParam(r, "slug"), req.Name)
+	if err != nil {
+		h.respondErr(w, "renaming tenant", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, resp)
+}
+
+func (h *Handler) handleSuspend(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.svc.Suspend(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		h.respondErr(w, "suspending tenant", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, resp)
+}
+
+func (h *Handler) handleResume(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.svc.Resume(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		h.respondErr(w, "resuming tenant", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, resp)
+}
+
+// handleDelete soft-deletes a tenant. The optional ?grace= duration (e.g.
+// 168h) sets how long the schema is kept before it is purged.
+func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	grace := h.defaultGrace
+	if g := r.URL.Query().Get("grace"); g != "" {
+		d, err := time.ParseDuration(g)
+		if err != nil {
+			httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid grace duration")
+			return
+		}
+		grace = d
+	}
+	resp, err := h.svc.Delete(r.Context(), chi.URLParam(r, "slug"), grace)
+	if err != nil {
+		h.respondErr(w, "deleting tenant", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusAccepted, resp)
+}
+
+func (h *Handler) handleRestore(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.svc.Restore(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		h.respondErr(w, "restoring tenant", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, resp)
+}
+
+func (h *Handler) handleMigrate(w http.ResponseWriter, r *http.Request) {
+	res, err := h.svc.Migrate(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		h.respondErr(w, "migrating tenant", err)
+		return
+	}
+	status := http.StatusOK
+	if res.Error != "" {
+		status = http.StatusInternalServerError
+	}
+	httpserver.Respond(w, status, res)
+}
+
+func (h *Handler) handleMigrateAll(w http.ResponseWriter, r *http.Request) {
+	results, err := h.svc.MigrateAll(r.Context())
+	if err != nil {
+		h.respondErr(w, "migrating tenants", err)
+		return
+	}
+	failed := 0
+	for _, res := range results {
+		if res.Error != "" {
+			failed++
+		}
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]any{"results": results, "count": len(results), "failed": failed})
+}
+
+func (h *Handler) handlePurge(w http.ResponseWriter, r *http.Request) {
+	purged, err := h.svc.Purge(r.Context())
+	if err != nil {
+		h.respondErr(w, "purging tenants", err)
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]any{"purged": purged, "count": len(purged)})
+}
+
+// respondErr maps lifecycle errors to HTTP responses.
+func (h *Handler) respondErr(w http.ResponseWriter, what string, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		httpserver.RespondError(w, http.StatusNotFound, "not_found", err.Error())
+	case errors.Is(err, ErrExists):
+		httpserver.RespondError(w, http.StatusConflict, "conflict", err.Error())
+	case errors.Is(err, ErrInvalidTransition):
+		httpserver.RespondError(w, http.StatusConflict, "invalid_status", err.Error())
+	case errors.Is(err, ErrInvalidSlug), errors.Is(err, ErrGraceTooShort):
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
+	default:
+		h.logger.Error(what, "error", err)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed "+what)
+	}
+}
@@ -0,0 +1,451 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/wisbric/core/pkg/platform"
+	coretenant "github.com/wisbric/core/pkg/tenant"
+
+	"github.com/wisbric/nightowl/internal/db"
+)
+
+// Tenant lifecycle states stored in public.tenants.status.
+const (
+	StatusActive          = "active"
+	StatusSuspended       = "suspended"
+	StatusPendingDeletion = "pending_deletion"
+)
+
+const (
+	// MinDeletionGrace is the shortest soft-delete grace period accepted
+	// before a tenant's schema may be dropped.
+	MinDeletionGrace = 24 * time.Hour
+	// DefaultDeletionGrace is used when no grace period is given.
+	DefaultDeletionGrace = 30 * 24 * time.Hour
+)
+
+// slugPattern matches the slugs the core provisioner accepts.
+var slugPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,62}$`)
+
+var (
+	// ErrNotFound is returned when no tenant has the given slug.
+	ErrNotFound = errors.New("tenant not found")
+	// ErrExists is returned when creating a tenant whose slug is taken.
+	ErrExists = errors.New("tenant already exists")
+	// ErrInvalidSlug is returned for slugs that cannot name a schema.
+	ErrInvalidSlug = errors.New("slug must be 2-63 lowercase letters, digits or underscores, starting with a letter")
+	// ErrInvalidTransition is returned when a lifecycle change does not apply
+	// to the tenant's current status.
+	ErrInvalidTransition = errors.New("tenant status does not allow this change")
+	// ErrGraceTooShort is returned for deletion grace periods below MinDeletionGrace.
+	ErrGraceTooShort = fmt.Errorf("deletion grace period must be at least %s", MinDeletionGrace)
+)
+
+// Record is a row of public.tenants including its lifecycle state.
+type Record struct {
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	Slug        string     `json:"slug"`
+	Status      string     `json:"status"`
+	SuspendedAt *time.Time `json:"suspended_at,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	PurgeAfter  *time.Time `json:"purge_after,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// MigrationStatus reports a tenant schema's migration version against the
+// newest migration on disk.
+type MigrationStatus struct {
+	Version uint `json:"version"`
+	Dirty   bool `json:"dirty"`
+	Latest  uint `json:"latest"`
+	Pending bool `json:"pending"`
+}
+
+// Response is a tenant together with its schema migration state.
+type Response struct {
+	Record
+	Migration *MigrationStatus `json:"migration,omitempty"`
+}
+
+// MigrateResult is the outcome of migrating one tenant schema.
+type MigrateResult struct {
+	Slug      string           `json:"slug"`
+	Migration *MigrationStatus `json:"migration,omitempty"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// =====================
+// Store operations
+// =====================
+
+const recordColumns = `id, name, slug, status, suspended_at, deleted_at, purge_after, created_at, updated_at`
+
+// Store provides lifecycle operations on public.tenants.
+type Store struct {
+	dbtx db.DBTX
+}
+
+// NewStore creates a tenant Store backed by the given database connection.
+func NewStore(dbtx db.DBTX) *Store {
+	return &Store{dbtx: dbtx}
+}
+
+func scanRecord(row pgx.Row) (Record, error) {
+	var t Record
+	err := row.Scan(&t.ID, &t.Name, &t.Slug, &t.Status, &t.SuspendedAt, &t.DeletedAt,
+		&t.PurgeAfter, &t.CreatedAt, &t.UpdatedAt)
+	return t, err
+}
+
+// List returns every tenant regardless of status.
+func (s *Store) List(ctx context.Context) ([]Record, error) {
+	rows, err := s.dbtx.Query(ctx, `SELECT `+recordColumns+` FROM public.tenants ORDER BY slug`)
+	if err != nil {
+		return nil, fmt.Errorf("listing tenants: %w", err)
+	}
+	defer rows.Close()
+
+	items := []Record{}
+	for rows.Next() {
+		t, err := scanRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning tenant: %w", err)
+		}
+		items = append(items, t)
+	}
+	return items, rows.Err()
+}
+
+// Get returns the tenant with the given slug.
+func (s *Store) Get(ctx context.Context, slug string) (Record, error) {
+	return scanRecord(s.dbtx.QueryRow(ctx,
+		`SELECT `+recordColumns+` FROM public.tenants WHERE slug = $1`, slug))
+}
+
+// Rename changes a tenant's display name.
+func (s *Store) Rename(ctx context.Context, slug, name string) (Record, error) {
+	return scanRecord(s.dbtx.QueryRow(ctx,
+		`UPDATE public.tenants SET name = $2, updated_at = now()
+		 WHERE slug = $1 RETURNING `+recordColumns, slug, name))
+}
+
+// SetStatus moves a tenant from one of the from statuses to status and
+// stamps the matching timestamps. It returns pgx.ErrNoRows when the tenant
+// does not exist or is in none of the from statuses.
+func (s *Store) SetStatus(ctx context.Context, slug, status string, from []string, purgeAfter *time.Time) (Record, error) {
+	return scanRecord(s.dbtx.QueryRow(ctx,
+		`UPDATE public.tenants SET
+			status       = $2,
+			suspended_at = CASE WHEN $2 = 'suspended' THEN now() ELSE NULL END,
+			deleted_at   = CASE WHEN $2 = 'pending_deletion' THEN now() ELSE NULL END,
+			purge_after  = $4,
+			updated_at   = now()
+		 WHERE slug = $1 AND status = ANY($3)
+		 RETURNING `+recordColumns, slug, status, from, purgeAfter))
+}
+
+// ListPurgeable returns tenants whose deletion grace period has ended.
+func (s *Store) ListPurgeable(ctx context.Context, now time.Time) ([]string, error) {
+	rows, err := s.dbtx.Query(ctx,
+		`SELECT slug FROM public.tenants
+		 WHERE status = 'pending_deletion' AND purge_after <= $1 ORDER BY purge_after`, now)
+	if err != nil {
+		return nil, fmt.Errorf("listing purgeable tenants: %w", err)
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// MigrationVersion reads golang-migrate's version row from a tenant schema.
+// A schema that was never migrated reports version 0.
+func (s *Store) MigrationVersion(ctx context.Context, slug string) (uint, bool, error) {
+	table := pgx.Identifier{SchemaName(slug), "schema_migrations"}.Sanitize()
+	var version int64
+	var dirty bool
+	err := s.dbtx.QueryRow(ctx, `SELECT version, dirty FROM `+table+` LIMIT 1`).Scan(&version, &dirty)
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return 0, false, nil
+	case errors.As(err, &pgErr) && (pgErr.Code == "42P01" || pgErr.Code == "3F000"):
+		// undefined_table / invalid_schema_name: nothing migrated yet.
+		return 0, false, nil
+	case err != nil:
+		return 0, false, fmt.Errorf("reading migration version: %w", err)
+	}
+	return uint(version), dirty, nil
+}
+
+// LatestMigrationVersion returns the highest migration version in dir.
+func LatestMigrationVersion(dir string) (uint, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("reading migrations dir: %w", err)
+	}
+	var latest uint
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".up.sql") {
+			continue
+		}
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			continue
+		}
+		latest = max(latest, uint(v))
+	}
+	return latest, nil
+}
+
+// =====================
+// Service operations
+// =====================
+
+// Service manages tenant lifecycle for platform operators: provisioning,
+// suspension, soft deletion with a grace period, and schema migrations.
+type Service struct {
+	store  *Store
+	prov   *Provisioner
+	logger *slog.Logger
+}
+
+// NewService creates a tenant Service. The provisioner supplies the pool,
+// database URL and tenant migrations directory.
+func NewService(prov *Provisioner, logger *slog.Logger) *Service {
+	return &Service{store: NewStore(prov.DB), prov: prov, logger: logger}
+}
+
+// List returns all tenants with their migration state.
+func (s *Service) List(ctx context.Context) ([]Response, error) {
+	records, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := LatestMigrationVersion(s.prov.MigrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Response, 0, len(records))
+	for _, rec := range records {
+		resp, err := s.withMigration(ctx, rec, latest)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, resp)
+	}
+	return items, nil
+}
+
+// Get returns a single tenant with its migration state.
+func (s *Service) Get(ctx context.Context, slug string) (Response, error) {
+	rec, err := s.store.Get(ctx, slug)
+	if err != nil {
+		return Response{}, notFound(err)
+	}
+	latest, err := LatestMigrationVersion(s.prov.MigrationsDir)
+	if err != nil {
+		return Response{}, err
+	}
+	return s.withMigration(ctx, rec, latest)
+}
+
+func (s *Service) withMigration(ctx context.Context, rec Record, latest uint) (Response, error) {
+	resp := Response{Record: rec}
+	if rec.Status == StatusPendingDeletion {
+		// The schema is kept but no longer maintained.
+		return resp, nil
+	}
+	version, dirty, err := s.store.MigrationVersion(ctx, rec.Slug)
+	if err != nil {
+		return Response{}, fmt.Errorf("tenant %s: %w", rec.Slug, err)
+	}
+	resp.Migration = &MigrationStatus{Version: version, Dirty: dirty, Latest: latest, Pending: dirty || version < latest}
+	return resp, nil
+}
+
+// Create provisions a new tenant: its global row, schema and migrations.
+func (s *Service) Create(ctx context.Context, name, slug string, config []byte) (Response, error) {
+	if !slugPattern.MatchString(slug) {
+		return Response{}, ErrInvalidSlug
+	}
+	if _, err := s.store.Get(ctx, slug); err == nil {
+		return Response{}, ErrExists
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return Response{}, fmt.Errorf("checking tenant: %w", err)
+	}
+	if _, err := s.prov.Provision(ctx, name, slug, config); err != nil {
+		return Response{}, err
+	}
+	return s.Get(ctx, slug)
+}
+
+// Rename changes a tenant's display name. The slug, which names the schema,
+// cannot change.
+func (s *Service) Rename(ctx context.Context, slug, name string) (Response, error) {
+	if _, err := s.store.Rename(ctx, slug, name); err != nil {
+		return Response{}, notFound(err)
+	}
+	return s.Get(ctx, slug)
+}
+
+// Suspend blocks API access and background processing for an active tenant.
+func (s *Service) Suspend(ctx context.Context, slug string) (Response, error) {
+	return s.transition(ctx, slug, StatusSuspended, []string{StatusActive}, nil)
+}
+
+// Resume reactivates a suspended tenant.
+func (s *Service) Resume(ctx context.Context, slug string) (Response, error) {
+	return s.transition(ctx, slug, StatusActive, []string{StatusSuspended}, nil)
+}
+
+// Delete soft-deletes a tenant. Its schema is kept until the grace period
+// ends and Purge drops it; until then Restore undoes the deletion.
+func (s *Service) Delete(ctx context.Context, slug string, grace time.Duration) (Response, error) {
+	if grace < MinDeletionGrace {
+		return Response{}, ErrGraceTooShort
+	}
+	purgeAfter := time.Now().Add(grace).UTC()
+	return s.transition(ctx, slug, StatusPendingDeletion, []string{StatusActive, StatusSuspended}, &purgeAfter)
+}
+
+// Restore cancels a pending deletion and reactivates the tenant.
+func (s *Service) Restore(ctx context.Context, slug string) (Response, error) {
+	return s.transition(ctx, slug, StatusActive, []string{StatusPendingDeletion}, nil)
+}
+
+func (s *Service) transition(ctx context.Context, slug, status string, from []string, purgeAfter *time.Time) (Response, error) {
+	rec, err := s.store.SetStatus(ctx, slug, status, from, purgeAfter)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, getErr := s.store.Get(ctx, slug); getErr != nil {
+			return Response{}, notFound(getErr)
+		}
+		return Response{}, ErrInvalidTransition
+	} else if err != nil {
+		return Response{}, fmt.Errorf("updating tenant status: %w", err)
+	}
+	s.logger.Info("tenant status changed", "slug", slug, "status", rec.Status)
+	return s.Get(ctx, slug)
+}
+
+// Purge deprovisions every tenant whose deletion grace period has ended and
+// returns their slugs.
+func (s *Service) Purge(ctx context.Context) ([]string, error) {
+	slugs, err := s.store.ListPurgeable(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	purged := make([]string, 0, len(slugs))
+	for _, slug := range slugs {
+		if err := s.prov.Deprovision(ctx, slug); err != nil {
+			return purged, fmt.Errorf("deprovisioning %s: %w", slug, err)
+		}
+		purged = append(purged, slug)
+	}
+	return purged, nil
+}
+
+// Migrate applies pending tenant migrations to one tenant's schema.
+func (s *Service) Migrate(ctx context.Context, slug string) (MigrateResult, error) {
+	rec, err := s.store.Get(ctx, slug)
+	if err != nil {
+		return MigrateResult{}, notFound(err)
+	}
+	if rec.Status == StatusPendingDeletion {
+		return MigrateResult{}, ErrInvalidTransition
+	}
+	res := MigrateResult{Slug: slug}
+	if err := s.migrateSchema(slug); err != nil {
+		res.Error = err.Error()
+	}
+	resp, err := s.Get(ctx, slug)
+	if err != nil {
+		return res, err
+	}
+	res.Migration = resp.Migration
+	return res, nil
+}
+
+// MigrateAll applies pending migrations to every tenant not pending
+// deletion. A failure on one tenant does not stop the others.
+func (s *Service) MigrateAll(ctx context.Context) ([]MigrateResult, error) {
+	records, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Slug < records[j].Slug })
+
+	results := make([]MigrateResult, 0, len(records))
+	for _, rec := range records {
+		if rec.Status == StatusPendingDeletion {
+			continue
+		}
+		res, err := s.Migrate(ctx, rec.Slug)
+		if err != nil {
+			res = MigrateResult{Slug: rec.Slug, Error: err.Error()}
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+func (s *Service) migrateSchema(slug string) error {
+	tenantURL, err := coretenant.WithSearchPath(s.prov.DatabaseURL, SchemaName(slug))
+	if err != nil {
+		return err
+	}
+	if err := platform.RunTenantMigrations(tenantURL, s.prov.MigrationsDir); err != nil {
+		s.logger.Error("tenant migration failed", "slug", slug, "error", err)
+		return err
+	}
+	s.logger.Info("tenant migrations applied", "slug", slug)
+	return nil
+}
+
+func notFound(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// RunPurgeLoop purges tenants past their deletion grace period periodically
+// until ctx is cancelled.
+func RunPurgeLoop(ctx context.Context, svc *Service, logger *slog.Logger, interval time.Duration) {
+	logger.Info("tenant purge loop started", "interval", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("tenant purge loop stopped")
+			return
+		case <-ticker.C:
+			purged, err := svc.Purge(ctx)
+			if err != nil {
+				logger.Error("tenant purge", "error", err)
+			}
+			for _, slug := range purged {
+				logger.Info("tenant purged after grace period", "slug", slug)
+			}
+		}
+	}
+}
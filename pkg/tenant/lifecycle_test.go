@@ -0,0 +1,123 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLatestMigrationVersion(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"000001_init.up.sql",
+		"000001_init.down.sql",
+		"000012_add_things.up.sql",
+		"000013_add_more.down.sql", // no matching up file
+		"README.md",
+		"notaversion_x.up.sql",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := LatestMigrationVersion(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != 12 {
+		t.Errorf("LatestMigrationVersion = %d, want 12", got)
+	}
+
+	if _, err := LatestMigrationVersion(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected error for missing directory")
+	}
+}
+
+func TestLatestMigrationVersion_RepoTenantMigrations(t *testing.T) {
+	got, err := LatestMigrationVersion("../../migrations/tenant")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got == 0 {
+		t.Error("expected tenant migrations in the repository")
+	}
+}
+
+func TestService_DeleteRequiresGracePeriod(t *testing.T) {
+	svc := NewService(&Provisioner{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	for _, grace := range []time.Duration{0, time.Hour, MinDeletionGrace - time.Second} {
+		if _, err := svc.Delete(context.Background(), "acme", grace); !errors.Is(err, ErrGraceTooShort) {
+			t.Errorf("Delete(grace=%s) error = %v, want ErrGraceTooShort", grace, err)
+		}
+	}
+}
+
+func TestService_CreateRejectsInvalidSlug(t *testing.T) {
+	svc := NewService(&Provisioner{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	for _, slug := range []string{"", "a", "Acme", "1acme", "acme-corp", "acme corp"} {
+		if _, err := svc.Create(context.Background(), "Acme", slug, nil); !errors.Is(err, ErrInvalidSlug) {
+			t.Errorf("Create(slug=%q) error = %v, want ErrInvalidSlug", slug, err)
+		}
+	}
+}
+
+func TestHandler_RequiresPlatformToken(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tests := []struct {
+		name       string
+		token      string
+		header     string
+		wantStatus int
+	}{
+		{name: "missing header", token: "secret", header: "", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", token: "secret", header: "Bearer nope", wantStatus: http.StatusUnauthorized},
+		{name: "not bearer", token: "secret", header: "secret", wantStatus: http.StatusUnauthorized},
+		{name: "empty configured token", token: "", header: "Bearer ", wantStatus: http.StatusUnauthorized},
+		// A valid token reaches the handler, which rejects the bad grace value.
+		{name: "valid token", token: "secret", header: "Bearer secret", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(NewService(&Provisioner{}, logger), logger, tt.token, DefaultDeletionGrace)
+			r := httptest.NewRequest(http.MethodDelete, "/acme?grace=soon", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.Routes().ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestHandler_RespondErr(t *testing.T) {
+	h := NewHandler(nil, slog.New(slog.NewTextHandler(io.Discard, nil)), "secret", DefaultDeletionGrace)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{ErrNotFound, http.StatusNotFound},
+		{ErrExists, http.StatusConflict},
+		{ErrInvalidTransition, http.StatusConflict},
+		{ErrInvalidSlug, http.StatusBadRequest},
+		{ErrGraceTooShort, http.StatusBadRequest},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.respondErr(w, "doing thing", tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondErr(%v) status = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
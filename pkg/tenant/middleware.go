@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"net/http"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 
@@ -32,6 +33,21 @@ func (HeaderResolver) Resolve(r *http.Request) (string, error) {
 	return slug, nil
 }
 
+// PathResolver resolves the tenant from a chi URL parameter. It serves
+// token-authenticated endpoints, such as integration ingest URLs, that carry
+// the tenant slug in the path.
+type PathResolver struct {
+	Param string
+}
+
+func (p PathResolver) Resolve(r *http.Request) (string, error) {
+	slug := chi.URLParam(r, p.Param)
+	if slug == "" {
+		return "", fmt.Errorf("missing tenant in path")
+	}
+	return slug, nil
+}
+
 // sqlcLookup implements core tenant.TenantLookup using nightowl's sqlc queries.
 type sqlcLookup struct {
 	pool *pgxpool.Pool
@@ -43,11 +59,48 @@ func (l *sqlcLookup) LookupBySlug(ctx context.Context, slug string) (uuid.UUID,
 	if err != nil {
 		return uuid.Nil, "", err
 	}
+	if t.Status != StatusActive {
+		return uuid.Nil, "", fmt.Errorf("tenant %q is %s", slug, t.Status)
+	}
 	return t.ID, t.Name, nil
 }
 
+// NewLookup returns a tenant lookup that only resolves active tenants, so
+// suspended and soft-deleted tenants are refused at the API boundary.
+func NewLookup(pool *pgxpool.Pool) coretenant.TenantLookup {
+	return &sqlcLookup{pool: pool}
+}
+
 // Middleware returns the core tenant middleware using nightowl's sqlc-based
 // tenant lookup instead of raw SQL.
 func Middleware(pool *pgxpool.Pool, resolver Resolver, logger *slog.Logger) func(http.Handler) http.Handler {
 	return coretenant.MiddlewareWithLookup(pool, &sqlcLookup{pool: pool}, resolver, logger)
 }
+
+// Acquire resolves an active tenant and acquires a pooled connection with
+// its search_path set, for entry points outside HTTP such as the SMTP
+// listener. The returned context carries the tenant info and connection
+// like Middleware does; call release when done. Unknown and inactive
+// tenants return ErrNotFound.
+func Acquire(ctx context.Context, pool *pgxpool.Pool, slug string) (context.Context, func(), error) {
+	t, err := db.New(pool).GetTenantBySlug(ctx, slug)
+	if err != nil {
+		return nil, nil, fmt.Errorf("looking up tenant: %w", notFound(err))
+	}
+	if t.Status != StatusActive {
+		return nil, nil, ErrNotFound
+	}
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("acquiring database connection: %w", err)
+	}
+	schema := SchemaName(slug)
+	if _, err := conn.Exec(ctx, "SELECT set_config('search_path', $1, false)", schema+", public"); err != nil {
+		conn.Release()
+		return nil, nil, fmt.Errorf("setting search_path: %w", err)
+	}
+
+	ctx = NewContext(ctx, &Info{ID: t.ID, Name: t.Name, Slug: slug, Schema: schema})
+	return NewConnContext(ctx, conn), conn.Release, nil
+}
@@ -0,0 +1,319 @@
+package timeoff
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wisbric/core/pkg/auth"
+	"github.com/wisbric/core/pkg/httpserver"
+
+	"github.com/wisbric/nightowl/internal/audit"
+	"github.com/wisbric/nightowl/pkg/tenant"
+)
+
+// Handler provides HTTP handlers for the time-off API.
+type Handler struct {
+	logger *slog.Logger
+	audit  *audit.Writer
+}
+
+// NewHandler creates a timeoff Handler.
+func NewHandler(logger *slog.Logger, audit *audit.Writer) *Handler {
+	return &Handler{logger: logger, audit: audit}
+}
+
+// Routes returns a chi.Router with all time-off routes mounted.
+func (h *Handler) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Get("/", h.handleList)
+	r.Post("/", h.handleCreate)
+	r.Delete("/{id}", h.handleDelete)
+
+	r.Get("/calendars", h.handleListCalendars)
+	r.Post("/calendars", h.handleCreateCalendar)
+	r.Post("/calendars/{id}/sync", h.handleSyncCalendar)
+	r.Delete("/calendars/{id}", h.handleDeleteCalendar)
+	return r
+}
+
+func (h *Handler) service(r *http.Request) *Service {
+	conn := tenant.ConnFromContext(r.Context())
+	return NewService(conn, h.logger)
+}
+
+func callerUUID(r *http.Request) pgtype.UUID {
+	id := auth.FromContext(r.Context())
+	if id != nil && id.UserID != nil {
+		return pgtype.UUID{Bytes: *id.UserID, Valid: true}
+	}
+	return pgtype.UUID{}
+}
+
+// mayManage reports whether the caller may change userID's time off and
+// calendars: members manage their own, managers and admins anyone's.
+func mayManage(r *http.Request, userID uuid.UUID) bool {
+	id := auth.FromContext(r.Context())
+	if id == nil {
+		return false
+	}
+	if id.Role == auth.RoleAdmin || id.Role == auth.RoleManager {
+		return true
+	}
+	return id.UserID != nil && *id.UserID == userID
+}
+
+func respondNotOwner(w http.ResponseWriter) {
+	httpserver.RespondError(w, http.StatusForbidden, "forbidden", "only managers and admins can manage other users' time off")
+}
+
+// parseTimeParam accepts an RFC3339 instant or a YYYY-MM-DD date (UTC midnight).
+func parseTimeParam(v string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+func parseUserFilter(r *http.Request) (*uuid.UUID, bool) {
+	v := r.URL.Query().Get("user_id")
+	if v == "" {
+		return nil, true
+	}
+	id, err := uuid.Parse(v)
+	if err != nil {
+		return nil, false
+	}
+	return &id, true
+}
+
+// =====================
+// Period handlers
+// =====================
+
+func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUserFilter(r)
+	if !ok {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid user_id")
+		return
+	}
+
+	// Default range: today to 90 days ahead.
+	from := time.Now().UTC().Truncate(24 * time.Hour)
+	to := from.AddDate(0, 0, 90)
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, ok = parseTimeParam(v); !ok {
+			httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid 'from' (use YYYY-MM-DD or RFC3339)")
+			return
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, ok = parseTimeParam(v); !ok {
+			httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid 'to' (use YYYY-MM-DD or RFC3339)")
+			return
+		}
+	}
+
+	items, err := h.service(r).List(r.Context(), userID, from, to)
+	if err != nil {
+		h.logger.Error("listing unavailability", "error", err)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to list unavailability")
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]any{
+		"items": items,
+		"count": len(items),
+	})
+}
+
+func (h *Handler) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var req CreateRequest
+	if !httpserver.DecodeAndValidate(w, r, &req) {
+		return
+	}
+	if !mayManage(r, req.UserID) {
+		respondNotOwner(w)
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, req.StartAt)
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid start_at (use RFC3339)")
+		return
+	}
+	end, err := time.Parse(time.RFC3339, req.EndAt)
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid end_at (use RFC3339)")
+		return
+	}
+	if !end.After(start) {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "end_at must be after start_at")
+		return
+	}
+
+	p, err := h.service(r).Create(r.Context(), req.UserID, start, end, req.Reason, callerUUID(r))
+	if err != nil {
+		h.logger.Error("creating unavailability", "error", err)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to create unavailability")
+		return
+	}
+
+	if h.audit != nil {
+		detail, _ := json.Marshal(map[string]string{
+			"user_id":  p.UserID.String(),
+			"start_at": p.StartAt.Format(time.RFC3339),
+			"end_at":   p.EndAt.Format(time.RFC3339),
+		})
+		h.audit.LogFromRequest(r, "create", "unavailability", p.ID, detail)
+	}
+	httpserver.Respond(w, http.StatusCreated, p)
+}
+
+func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid ID")
+		return
+	}
+	svc := h.service(r)
+	p, err := svc.Get(r.Context(), id)
+	if err == nil && !mayManage(r, p.UserID) {
+		respondNotOwner(w)
+		return
+	}
+	if err == nil {
+		err = svc.Delete(r.Context(), id)
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			httpserver.RespondError(w, http.StatusNotFound, "not_found", "unavailability not found")
+			return
+		}
+		h.logger.Error("deleting unavailability", "error", err, "id", id)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to delete unavailability")
+		return
+	}
+	if h.audit != nil {
+		h.audit.LogFromRequest(r, "delete", "unavailability", id, nil)
+	}
+	httpserver.Respond(w, http.StatusNoContent, nil)
+}
+
+// =====================
+// Calendar handlers
+// =====================
+
+func (h *Handler) handleListCalendars(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUserFilter(r)
+	if !ok {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid user_id")
+		return
+	}
+	items, err := h.service(r).ListCalendars(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("listing calendars", "error", err)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to list calendars")
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, map[string]any{
+		"items": items,
+		"count": len(items),
+	})
+}
+
+func (h *Handler) handleCreateCalendar(w http.ResponseWriter, r *http.Request) {
+	var req CreateCalendarRequest
+	if !httpserver.DecodeAndValidate(w, r, &req) {
+		return
+	}
+	if !mayManage(r, req.UserID) {
+		respondNotOwner(w)
+		return
+	}
+	cal, err := h.service(r).AddCalendar(r.Context(), req)
+	if err != nil {
+		h.logger.Error("creating calendar", "error", err)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to create calendar")
+		return
+	}
+	if h.audit != nil {
+		detail, _ := json.Marshal(map[string]string{"user_id": cal.UserID.String()})
+		h.audit.LogFromRequest(r, "create", "unavailability_calendar", cal.ID, detail)
+	}
+	httpserver.Respond(w, http.StatusCreated, cal)
+}
+
+func (h *Handler) handleSyncCalendar(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid calendar ID")
+		return
+	}
+	svc := h.service(r)
+	cal, err := svc.GetCalendar(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			httpserver.RespondError(w, http.StatusNotFound, "not_found", "calendar not found")
+			return
+		}
+		h.logger.Error("getting calendar", "error", err, "calendar_id", id)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to get calendar")
+		return
+	}
+	if !mayManage(r, cal.UserID) {
+		respondNotOwner(w)
+		return
+	}
+	if err := svc.Sync(r.Context(), cal); err != nil {
+		h.logger.Warn("calendar sync failed", "error", err, "calendar_id", id)
+		httpserver.RespondError(w, http.StatusBadGateway, "sync_failed", "failed to sync calendar")
+		return
+	}
+	cal, err = svc.GetCalendar(r.Context(), id)
+	if err != nil {
+		h.logger.Error("getting calendar", "error", err, "calendar_id", id)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to get calendar")
+		return
+	}
+	httpserver.Respond(w, http.StatusOK, cal)
+}
+
+func (h *Handler) handleDeleteCalendar(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		httpserver.RespondError(w, http.StatusBadRequest, "bad_request", "invalid calendar ID")
+		return
+	}
+	svc := h.service(r)
+	cal, err := svc.GetCalendar(r.Context(), id)
+	if err == nil && !mayManage(r, cal.UserID) {
+		respondNotOwner(w)
+		return
+	}
+	if err == nil {
+		err = svc.DeleteCalendar(r.Context(), id)
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			httpserver.RespondError(w, http.StatusNotFound, "not_found", "calendar not found")
+			return
+		}
+		h.logger.Error("deleting calendar", "error", err, "calendar_id", id)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to delete calendar")
+		return
+	}
+	if h.audit != nil {
+		h.audit.LogFromRequest(r, "delete", "unavailability_calendar", id, nil)
+	}
+	httpserver.Respond(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,59 @@
+package timeoff
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/wisbric/core/pkg/auth"
+)
+
+func TestMayManage(t *testing.T) {
+	self, other := uuid.New(), uuid.New()
+	tests := []struct {
+		name string
+		id   *auth.Identity
+		want bool
+	}{
+		{"own records", &auth.Identity{Role: auth.RoleEngineer, UserID: &self}, true},
+		{"someone else's", &auth.Identity{Role: auth.RoleEngineer, UserID: &other}, false},
+		{"manager", &auth.Identity{Role: auth.RoleManager, UserID: &other}, true},
+		{"admin", &auth.Identity{Role: auth.RoleAdmin, UserID: &other}, true},
+		{"engineer API key", &auth.Identity{Role: auth.RoleEngineer, Method: auth.MethodAPIKey}, false},
+		{"anonymous", nil, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		if tt.id != nil {
+			r = r.WithContext(auth.NewContext(r.Context(), tt.id))
+		}
+		if got := mayManage(r, self); got != tt.want {
+			t.Errorf("%s: mayManage = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHandler_RejectsOtherUsersRecords(t *testing.T) {
+	h := NewHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+	caller, other := uuid.New(), uuid.New()
+	tests := []struct {
+		path, body string
+	}{
+		{"/", `{"user_id":"` + other.String() + `","start_at":"2026-08-01T00:00:00Z","end_at":"2026-08-08T00:00:00Z"}`},
+		{"/calendars", `{"user_id":"` + other.String() + `","url":"https://calendar.example.com/holidays.ics"}`},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		r = r.WithContext(auth.NewContext(r.Context(), &auth.Identity{Role: auth.RoleEngineer, UserID: &caller}))
+		w := httptest.NewRecorder()
+		h.Routes().ServeHTTP(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("POST %s: status = %d, want %d", tt.path, w.Code, http.StatusForbidden)
+		}
+	}
+}
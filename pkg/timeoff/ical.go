@@ -0,0 +1,137 @@
+package timeoff
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Event is a busy period read from an iCal feed.
+type Event struct {
+	UID     string
+	Summary string
+	Start   time.Time
+	End     time.Time
+}
+
+// ParseICS extracts VEVENTs from an iCalendar stream. All-day events span
+// whole days in loc; floating times are read in loc as well. Cancelled and
+// transparent (free) events are skipped. Recurrence rules are not expanded:
+// only the first occurrence of a recurring event is imported.
+func ParseICS(r io.Reader, loc *time.Location) ([]Event, error) {
+	lines, err := unfoldLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	var cur *Event
+	var skip bool
+	var allDayEnd bool
+	for _, line := range lines {
+		name, params, value := splitContentLine(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			cur = &Event{}
+			skip, allDayEnd = false, false
+		case name == "END" && value == "VEVENT":
+			if cur != nil && !skip && !cur.Start.IsZero() {
+				if cur.End.IsZero() {
+					// RFC 5545: an all-day event without DTEND lasts one day.
+					if allDayEnd {
+						cur.End = cur.Start.AddDate(0, 0, 1)
+					}
+				}
+				if cur.End.After(cur.Start) {
+					events = append(events, *cur)
+				}
+			}
+			cur = nil
+		case cur == nil:
+			continue
+		case name == "UID":
+			cur.UID = value
+		case name == "SUMMARY":
+			cur.Summary = unescapeText(value)
+		case name == "STATUS" && strings.EqualFold(value, "CANCELLED"):
+			skip = true
+		case name == "TRANSP" && strings.EqualFold(value, "TRANSPARENT"):
+			skip = true
+		case name == "DTSTART":
+			t, allDay, err := parseICSTime(value, params, loc)
+			if err != nil {
+				return nil, fmt.Errorf("parsing DTSTART %q: %w", value, err)
+			}
+			cur.Start = t
+			allDayEnd = allDay
+		case name == "DTEND":
+			t, _, err := parseICSTime(value, params, loc)
+			if err != nil {
+				return nil, fmt.Errorf("parsing DTEND %q: %w", value, err)
+			}
+			cur.End = t
+		}
+	}
+	return events, nil
+}
+
+// unfoldLines joins RFC 5545 folded lines (continuations start with a space or tab).
+func unfoldLines(r io.Reader) ([]string, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lines []string
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, sc.Err()
+}
+
+// splitContentLine splits "NAME;PARAM=V:value" into its parts.
+func splitContentLine(line string) (name string, params map[string]string, value string) {
+	colon := strings.Index(line, ":")
+	if colon < 0 {
+		return strings.ToUpper(line), nil, ""
+	}
+	head, value := line[:colon], line[colon+1:]
+	parts := strings.Split(head, ";")
+	name = strings.ToUpper(parts[0])
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return name, params, value
+}
+
+// parseICSTime parses DATE and DATE-TIME values, honouring TZID.
+func parseICSTime(value string, params map[string]string, loc *time.Location) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	if tzid := params["TZID"]; tzid != "" {
+		if tz, err := time.LoadLocation(tzid); err == nil {
+			loc = tz
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+func unescapeText(s string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
@@ -0,0 +1,90 @@
+package timeoff
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:holiday-1\r\n" +
+	"SUMMARY:Summer holiday\\, Spain\r\n" +
+	"DTSTART;VALUE=DATE:20260803\r\n" +
+	"DTEND;VALUE=DATE:20260815\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:dentist\r\n" +
+	"SUMMARY:Dentist appoint\r\n" +
+	" ment\r\n" +
+	"DTSTART;TZID=Europe/Berlin:20260310T140000\r\n" +
+	"DTEND;TZID=Europe/Berlin:20260310T153000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:day-off\r\n" +
+	"DTSTART;VALUE=DATE:20260401\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:cancelled\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"DTSTART:20260501T090000Z\r\n" +
+	"DTEND:20260501T100000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:free\r\n" +
+	"TRANSP:TRANSPARENT\r\n" +
+	"DTSTART:20260502T090000Z\r\n" +
+	"DTEND:20260502T100000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICS(t *testing.T) {
+	events, err := ParseICS(strings.NewReader(sampleICS), time.UTC)
+	if err != nil {
+		t.Fatalf("ParseICS: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d: %+v", len(events), events)
+	}
+
+	holiday := events[0]
+	if holiday.Summary != "Summer holiday, Spain" {
+		t.Errorf("summary = %q", holiday.Summary)
+	}
+	if want := time.Date(2026, 8, 15, 0, 0, 0, 0, time.UTC); !holiday.End.Equal(want) {
+		t.Errorf("holiday end = %v, want %v", holiday.End, want)
+	}
+
+	dentist := events[1]
+	if dentist.Summary != "Dentist appointment" {
+		t.Errorf("folded summary = %q", dentist.Summary)
+	}
+	if want := time.Date(2026, 3, 10, 13, 0, 0, 0, time.UTC); !dentist.Start.Equal(want) {
+		t.Errorf("dentist start = %v, want %v", dentist.Start.UTC(), want)
+	}
+
+	dayOff := events[2]
+	if got := dayOff.End.Sub(dayOff.Start); got != 24*time.Hour {
+		t.Errorf("all-day event without DTEND lasts %v, want 24h", got)
+	}
+}
+
+func TestParseICS_InvalidDate(t *testing.T) {
+	ics := "BEGIN:VEVENT\nDTSTART:not-a-date\nEND:VEVENT\n"
+	if _, err := ParseICS(strings.NewReader(ics), time.UTC); err == nil {
+		t.Error("expected error for invalid DTSTART")
+	}
+}
+
+func TestPeriodOverlaps(t *testing.T) {
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	p := Period{StartAt: base, EndAt: base.Add(48 * time.Hour)}
+
+	if !p.Overlaps(base.Add(24*time.Hour), base.Add(72*time.Hour)) {
+		t.Error("expected overlap")
+	}
+	if p.Overlaps(base.Add(48*time.Hour), base.Add(72*time.Hour)) {
+		t.Error("adjacent ranges must not overlap")
+	}
+}
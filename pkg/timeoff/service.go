@@ -0,0 +1,196 @@
+package timeoff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wisbric/nightowl/internal/db"
+)
+
+// maxFeedBytes caps how much of a calendar feed is read on sync.
+const maxFeedBytes = 5 << 20
+
+// syncHorizon bounds which imported events are kept: past events older than
+// this are dropped so the table does not grow with a feed's full history.
+const syncHorizon = 30 * 24 * time.Hour
+
+// ErrBlockedAddress is returned when a calendar URL leads to an address the
+// server must not fetch from, such as loopback or cluster-internal ones.
+var ErrBlockedAddress = errors.New("calendar URL resolves to a disallowed address")
+
+// Service encapsulates unavailability business logic.
+type Service struct {
+	store  *Store
+	logger *slog.Logger
+	client *http.Client
+}
+
+// NewService creates a timeoff Service backed by the given database connection.
+func NewService(dbtx db.DBTX, logger *slog.Logger) *Service {
+	return &Service{
+		store:  NewStore(dbtx),
+		logger: logger,
+		client: feedClient(),
+	}
+}
+
+// feedClient returns the client calendar feeds are fetched with. Feed URLs
+// are user-supplied, so every connection, including redirects, is checked
+// against the address it actually dials.
+func feedClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: checkFeedAddr}
+	return &http.Client{
+		Timeout: 15 * time.Second,
+		// No proxy: the check must see the feed's own address.
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// checkFeedAddr rejects connections to loopback, private, link-local,
+// multicast and unspecified addresses.
+func checkFeedAddr(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !publicAddr(addr) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
+
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddrSpace.Contains(addr)
+}
+
+// sharedAddrSpace is carrier-grade NAT space (RFC 6598), often used for
+// cluster networks.
+var sharedAddrSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// List returns periods intersecting [from, to), optionally for one user.
+func (s *Service) List(ctx context.Context, userID *uuid.UUID, from, to time.Time) ([]Period, error) {
+	var ids []uuid.UUID
+	if userID != nil {
+		ids = []uuid.UUID{*userID}
+	}
+	return s.store.ListOverlapping(ctx, ids, from, to)
+}
+
+// Create records a manual unavailability period.
+func (s *Service) Create(ctx context.Context, userID uuid.UUID, start, end time.Time, reason *string, callerID pgtype.UUID) (Period, error) {
+	return s.store.Create(ctx, userID, start, end, reason, callerID)
+}
+
+func (s *Service) Get(ctx context.Context, id uuid.UUID) (Period, error) {
+	return s.store.Get(ctx, id)
+}
+
+func (s *Service) Delete(ctx context.Context, id uuid.UUID) error {
+	return s.store.Delete(ctx, id)
+}
+
+// --- Calendars ---
+
+func (s *Service) ListCalendars(ctx context.Context, userID *uuid.UUID) ([]Calendar, error) {
+	return s.store.ListCalendars(ctx, userID)
+}
+
+// AddCalendar subscribes a user to an iCal feed and imports it immediately.
+// A failed first import is recorded on the calendar rather than returned.
+func (s *Service) AddCalendar(ctx context.Context, req CreateCalendarRequest) (Calendar, error) {
+	cal, err := s.store.UpsertCalendar(ctx, req.UserID, req.URL)
+	if err != nil {
+		return Calendar{}, err
+	}
+	if err := s.Sync(ctx, cal); err != nil {
+		s.logger.Warn("initial calendar sync failed", "error", err, "calendar_id", cal.ID)
+	}
+	return s.store.GetCalendar(ctx, cal.ID)
+}
+
+func (s *Service) GetCalendar(ctx context.Context, id uuid.UUID) (Calendar, error) {
+	return s.store.GetCalendar(ctx, id)
+}
+
+func (s *Service) DeleteCalendar(ctx context.Context, id uuid.UUID) error {
+	return s.store.DeleteCalendar(ctx, id)
+}
+
+// Sync fetches a calendar feed and replaces its imported periods. The
+// outcome is recorded on the calendar either way.
+func (s *Service) Sync(ctx context.Context, cal Calendar) error {
+	events, err := s.fetch(ctx, cal.URL)
+	if err == nil {
+		cutoff := time.Now().Add(-syncHorizon)
+		kept := events[:0]
+		for _, e := range events {
+			if e.End.After(cutoff) {
+				kept = append(kept, e)
+			}
+		}
+		err = s.store.ReplaceCalendarPeriods(ctx, cal, kept)
+	}
+	if markErr := s.store.MarkSynced(ctx, cal.ID, err); markErr != nil {
+		s.logger.Error("recording calendar sync", "error", markErr, "calendar_id", cal.ID)
+	}
+	return err
+}
+
+// SyncAll syncs every subscribed calendar, logging failures.
+func (s *Service) SyncAll(ctx context.Context) error {
+	cals, err := s.store.ListCalendars(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, cal := range cals {
+		if err := s.Sync(ctx, cal); err != nil {
+			s.logger.Warn("calendar sync failed", "error", err, "calendar_id", cal.ID, "user_id", cal.UserID)
+		}
+	}
+	return nil
+}
+
+func (s *Service) fetch(ctx context.Context, url string) ([]Event, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building request: %w", err)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported calendar URL scheme %q", req.URL.Scheme)
+	}
+	req.Header.Set("Accept", "text/calendar")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching calendar: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching calendar: unexpected status %d", resp.StatusCode)
+	}
+	events, err := ParseICS(io.LimitReader(resp.Body, maxFeedBytes), time.UTC)
+	if err != nil {
+		return nil, fmt.Errorf("parsing calendar: %w", err)
+	}
+	return events, nil
+}
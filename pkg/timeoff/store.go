@@ -0,0 +1,219 @@
+package timeoff
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/wisbric/nightowl/internal/db"
+)
+
+const periodColumns = `uu.id, uu.user_id, COALESCE(u.display_name, uu.user_id::text),
+	uu.start_at, uu.end_at, uu.reason, uu.source, uu.calendar_id, uu.created_by, uu.created_at`
+
+// Store provides database operations for unavailability periods and calendars.
+type Store struct {
+	dbtx db.DBTX
+}
+
+// NewStore creates a timeoff Store backed by the given database connection.
+func NewStore(dbtx db.DBTX) *Store {
+	return &Store{dbtx: dbtx}
+}
+
+func scanPeriod(row pgx.Row) (Period, error) {
+	var p Period
+	var calendarID, createdBy pgtype.UUID
+	if err := row.Scan(&p.ID, &p.UserID, &p.DisplayName, &p.StartAt, &p.EndAt,
+		&p.Reason, &p.Source, &calendarID, &createdBy, &p.CreatedAt); err != nil {
+		return Period{}, err
+	}
+	p.CalendarID = uuidPtr(calendarID)
+	p.CreatedBy = uuidPtr(createdBy)
+	return p, nil
+}
+
+// ListOverlapping lists periods intersecting [from, to). When userIDs is
+// non-empty only those users are returned.
+func (s *Store) ListOverlapping(ctx context.Context, userIDs []uuid.UUID, from, to time.Time) ([]Period, error) {
+	query := `SELECT ` + periodColumns + `
+	          FROM user_unavailability uu
+	          LEFT JOIN users u ON u.id = uu.user_id
+	          WHERE uu.end_at > $1 AND uu.start_at < $2
+	            AND (cardinality($3::uuid[]) = 0 OR uu.user_id = ANY($3))
+	          ORDER BY uu.start_at`
+	if userIDs == nil {
+		userIDs = []uuid.UUID{}
+	}
+	rows, err := s.dbtx.Query(ctx, query, from, to, userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("listing unavailability: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Period
+	for rows.Next() {
+		p, err := scanPeriod(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning unavailability row: %w", err)
+		}
+		result = append(result, p)
+	}
+	if result == nil {
+		result = []Period{}
+	}
+	return result, rows.Err()
+}
+
+func (s *Store) Create(ctx context.Context, userID uuid.UUID, start, end time.Time, reason *string, createdBy pgtype.UUID) (Period, error) {
+	query := `WITH uu AS (
+	            INSERT INTO user_unavailability (user_id, start_at, end_at, reason, source, created_by)
+	            VALUES ($1, $2, $3, $4, 'manual', $5)
+	            RETURNING *
+	          )
+	          SELECT ` + periodColumns + `
+	          FROM uu LEFT JOIN users u ON u.id = uu.user_id`
+	p, err := scanPeriod(s.dbtx.QueryRow(ctx, query, userID, start, end, reason, createdBy))
+	if err != nil {
+		return Period{}, fmt.Errorf("creating unavailability: %w", err)
+	}
+	return p, nil
+}
+
+func (s *Store) Get(ctx context.Context, id uuid.UUID) (Period, error) {
+	return scanPeriod(s.dbtx.QueryRow(ctx, `SELECT `+periodColumns+`
+	          FROM user_unavailability uu
+	          LEFT JOIN users u ON u.id = uu.user_id
+	          WHERE uu.id = $1`, id))
+}
+
+func (s *Store) Delete(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.dbtx.Exec(ctx, `DELETE FROM user_unavailability WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("deleting unavailability: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// ReplaceCalendarPeriods swaps the imported periods of a calendar for the
+// given events.
+func (s *Store) ReplaceCalendarPeriods(ctx context.Context, cal Calendar, events []Event) error {
+	if _, err := s.dbtx.Exec(ctx, `DELETE FROM user_unavailability WHERE calendar_id = $1`, cal.ID); err != nil {
+		return fmt.Errorf("clearing calendar periods: %w", err)
+	}
+	for _, e := range events {
+		var reason *string
+		if e.Summary != "" {
+			reason = &e.Summary
+		}
+		if _, err := s.dbtx.Exec(ctx,
+			`INSERT INTO user_unavailability (user_id, start_at, end_at, reason, source, calendar_id, external_uid)
+			 VALUES ($1, $2, $3, $4, 'ical', $5, $6)`,
+			cal.UserID, e.Start, e.End, reason, cal.ID, e.UID); err != nil {
+			return fmt.Errorf("inserting calendar period: %w", err)
+		}
+	}
+	return nil
+}
+
+// =====================
+// Calendars
+// =====================
+
+const calendarColumns = `id, user_id, url, last_synced_at, last_error, created_at`
+
+func scanCalendar(row pgx.Row) (Calendar, error) {
+	var c Calendar
+	var synced pgtype.Timestamptz
+	if err := row.Scan(&c.ID, &c.UserID, &c.URL, &synced, &c.LastError, &c.CreatedAt); err != nil {
+		return Calendar{}, err
+	}
+	if synced.Valid {
+		c.LastSyncedAt = &synced.Time
+	}
+	return c, nil
+}
+
+// ListCalendars lists subscribed calendars, optionally for one user.
+func (s *Store) ListCalendars(ctx context.Context, userID *uuid.UUID) ([]Calendar, error) {
+	rows, err := s.dbtx.Query(ctx,
+		`SELECT `+calendarColumns+` FROM unavailability_calendars
+		 WHERE $1::uuid IS NULL OR user_id = $1
+		 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("listing calendars: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Calendar
+	for rows.Next() {
+		c, err := scanCalendar(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning calendar row: %w", err)
+		}
+		result = append(result, c)
+	}
+	if result == nil {
+		result = []Calendar{}
+	}
+	return result, rows.Err()
+}
+
+func (s *Store) GetCalendar(ctx context.Context, id uuid.UUID) (Calendar, error) {
+	return scanCalendar(s.dbtx.QueryRow(ctx,
+		`SELECT `+calendarColumns+` FROM unavailability_calendars WHERE id = $1`, id))
+}
+
+// UpsertCalendar subscribes a user to a calendar URL, returning the existing
+// subscription if there is one.
+func (s *Store) UpsertCalendar(ctx context.Context, userID uuid.UUID, url string) (Calendar, error) {
+	c, err := scanCalendar(s.dbtx.QueryRow(ctx,
+		`INSERT INTO unavailability_calendars (user_id, url) VALUES ($1, $2)
+		 ON CONFLICT (user_id, url) DO UPDATE SET url = EXCLUDED.url
+		 RETURNING `+calendarColumns, userID, url))
+	if err != nil {
+		return Calendar{}, fmt.Errorf("creating calendar: %w", err)
+	}
+	return c, nil
+}
+
+// MarkSynced records the outcome of a calendar sync.
+func (s *Store) MarkSynced(ctx context.Context, id uuid.UUID, syncErr error) error {
+	var msg *string
+	if syncErr != nil {
+		m := syncErr.Error()
+		msg = &m
+	}
+	_, err := s.dbtx.Exec(ctx,
+		`UPDATE unavailability_calendars
+		 SET last_synced_at = CASE WHEN $2::text IS NULL THEN now() ELSE last_synced_at END,
+		     last_error = $2
+		 WHERE id = $1`, id, msg)
+	return err
+}
+
+func (s *Store) DeleteCalendar(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.dbtx.Exec(ctx, `DELETE FROM unavailability_calendars WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("deleting calendar: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func uuidPtr(u pgtype.UUID) *uuid.UUID {
+	if !u.Valid {
+		return nil
+	}
+	id := uuid.UUID(u.Bytes)
+	return &id
+}
@@ -0,0 +1,56 @@
+package timeoff
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sources of an unavailability period.
+const (
+	SourceManual = "manual"
+	SourceICal   = "ical"
+)
+
+// CreateRequest is the JSON body for POST /api/v1/timeoff.
+type CreateRequest struct {
+	UserID  uuid.UUID `json:"user_id" validate:"required"`
+	StartAt string    `json:"start_at" validate:"required"` // RFC3339
+	EndAt   string    `json:"end_at" validate:"required"`   // RFC3339
+	Reason  *string   `json:"reason"`
+}
+
+// Period is a span of time during which a user cannot be scheduled.
+type Period struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	DisplayName string     `json:"display_name"`
+	StartAt     time.Time  `json:"start_at"`
+	EndAt       time.Time  `json:"end_at"`
+	Reason      *string    `json:"reason,omitempty"`
+	Source      string     `json:"source"` // "manual" | "ical"
+	CalendarID  *uuid.UUID `json:"calendar_id,omitempty"`
+	CreatedBy   *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Overlaps reports whether the period intersects [start, end).
+func (p Period) Overlaps(start, end time.Time) bool {
+	return p.StartAt.Before(end) && start.Before(p.EndAt)
+}
+
+// CreateCalendarRequest is the JSON body for POST /api/v1/timeoff/calendars.
+type CreateCalendarRequest struct {
+	UserID uuid.UUID `json:"user_id" validate:"required"`
+	URL    string    `json:"url" validate:"required,url"`
+}
+
+// Calendar is a subscribed iCal feed whose events mark a user unavailable.
+type Calendar struct {
+	ID           uuid.UUID  `json:"id"`
+	UserID       uuid.UUID  `json:"user_id"`
+	URL          string     `json:"url"`
+	LastSyncedAt *time.Time `json:"last_synced_at,omitempty"`
+	LastError    *string    `json:"last_error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
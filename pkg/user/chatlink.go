@@ -0,0 +1,289 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/wisbric/core/pkg/auth"
+)
+
+// Chat providers a NightOwl user can be linked to. They match the messaging
+// provider names.
+const (
+	ChatSlack      = "slack"
+	ChatMattermost = "mattermost"
+)
+
+// linkCodeTTL is how long a /nightowl link code stays valid.
+const linkCodeTTL = 15 * time.Minute
+
+// linkCodeAlphabet avoids characters that are easily confused when typed.
+const linkCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+var (
+	// ErrNotLinked is returned when a chat account maps to no active user.
+	ErrNotLinked = errors.New("chat account is not linked to a NightOwl user")
+	// ErrInvalidLinkCode is returned for unknown or expired link codes.
+	ErrInvalidLinkCode = errors.New("invalid or expired link code")
+	// ErrUnknownChatProvider is returned for providers other than Slack and Mattermost.
+	ErrUnknownChatProvider = errors.New("unknown chat provider")
+)
+
+// LinkInstructions tells an unlinked chat user how to link their account.
+const LinkInstructions = "To link it, create a link code under Profile → Chat accounts in NightOwl " +
+	"(or `POST /api/v1/user/chat-links/code`) and run `/nightowl link <code>`."
+
+// LinkCodeResponse is the JSON response for POST /user/chat-links/code.
+type LinkCodeResponse struct {
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Command   string    `json:"command"`
+}
+
+// HasRole reports whether the user holds min or a more privileged role.
+func (u *UserRow) HasRole(min string) bool {
+	return roleRank(u.Role) >= roleRank(min)
+}
+
+// roleRank orders roles by privilege; auth.ValidRoles lists them highest first.
+func roleRank(role string) int {
+	for i, r := range auth.ValidRoles {
+		if r == role {
+			return len(auth.ValidRoles) - i
+		}
+	}
+	return 0
+}
+
+// chatIDColumn returns the users column holding a provider's chat user ID.
+func chatIDColumn(provider string) (string, error) {
+	switch provider {
+	case ChatSlack:
+		return "slack_user_id", nil
+	case ChatMattermost:
+		return "mattermost_user_id", nil
+	}
+	return "", ErrUnknownChatProvider
+}
+
+func hashLinkCode(code string) string {
+	normalized := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	h := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(h[:])
+}
+
+func newLinkCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = linkCodeAlphabet[int(b[i])%len(linkCodeAlphabet)]
+	}
+	return string(b[:4]) + "-" + string(b[4:]), nil
+}
+
+// =====================
+// Store operations
+// =====================
+
+// FindByChatID returns the active user linked to a provider's chat user ID.
+func (s *Store) FindByChatID(ctx context.Context, provider, externalID string) (UserRow, error) {
+	col, err := chatIDColumn(provider)
+	if err != nil {
+		return UserRow{}, err
+	}
+	query := `SELECT ` + userColumns + ` FROM users WHERE is_active AND ` + col + ` = $1 LIMIT 1`
+	return scanUserRow(s.dbtx.QueryRow(ctx, query, externalID))
+}
+
+// FindByEmail returns the active user with the given email, ignoring case.
+func (s *Store) FindByEmail(ctx context.Context, email string) (UserRow, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE is_active AND lower(email) = lower($1) LIMIT 1`
+	return scanUserRow(s.dbtx.QueryRow(ctx, query, email))
+}
+
+// ListUnlinked returns active users with no chat ID for the provider.
+func (s *Store) ListUnlinked(ctx context.Context, provider string) ([]UserRow, error) {
+	col, err := chatIDColumn(provider)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + userColumns + ` FROM users WHERE is_active AND ` + col + ` IS NULL ORDER BY display_name`
+	rows, err := s.dbtx.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("listing unlinked users: %w", err)
+	}
+	return scanUserRows(rows)
+}
+
+// SetChatID links a user to a provider's chat user ID, or unlinks them when
+// externalID is nil. A chat ID belongs to one user, so it is first cleared
+// from anyone else holding it.
+func (s *Store) SetChatID(ctx context.Context, userID uuid.UUID, provider string, externalID *string) error {
+	col, err := chatIDColumn(provider)
+	if err != nil {
+		return err
+	}
+	if externalID != nil {
+		if _, err := s.dbtx.Exec(ctx,
+			`UPDATE users SET `+col+` = NULL, updated_at = now() WHERE `+col+` = $1 AND id <> $2`,
+			*externalID, userID); err != nil {
+			return fmt.Errorf("clearing previous chat link: %w", err)
+		}
+	}
+	tag, err := s.dbtx.Exec(ctx,
+		`UPDATE users SET `+col+` = $2, updated_at = now() WHERE id = $1`, userID, externalID)
+	if err != nil {
+		return fmt.Errorf("setting chat link: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// ReplaceLinkCode stores a user's link code, discarding any earlier one.
+func (s *Store) ReplaceLinkCode(ctx context.Context, userID uuid.UUID, codeHash string, expiresAt time.Time) error {
+	if _, err := s.dbtx.Exec(ctx, `DELETE FROM chat_link_codes WHERE user_id = $1 OR expires_at < now()`, userID); err != nil {
+		return fmt.Errorf("deleting old link codes: %w", err)
+	}
+	if _, err := s.dbtx.Exec(ctx,
+		`INSERT INTO chat_link_codes (code_hash, user_id, expires_at) VALUES ($1, $2, $3)`,
+		codeHash, userID, expiresAt); err != nil {
+		return fmt.Errorf("creating link code: %w", err)
+	}
+	return nil
+}
+
+// ConsumeLinkCode deletes an unexpired link code and returns its user. It
+// returns pgx.ErrNoRows for unknown or expired codes.
+func (s *Store) ConsumeLinkCode(ctx context.Context, codeHash string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := s.dbtx.QueryRow(ctx,
+		`DELETE FROM chat_link_codes WHERE code_hash = $1 AND expires_at > now() RETURNING user_id`,
+		codeHash).Scan(&userID)
+	return userID, err
+}
+
+// =====================
+// Service operations
+// =====================
+
+// ResolveChatUser returns the NightOwl user behind a chat account: the user
+// linked to its chat ID, else the active user whose email matches the chat
+// profile. An email match is remembered as a link. email is only called
+// when needed and may be nil.
+func (s *Service) ResolveChatUser(ctx context.Context, provider, externalID string,
+	email func(context.Context) (string, error)) (UserRow, error) {
+	row, err := s.store.FindByChatID(ctx, provider, externalID)
+	if err == nil {
+		return row, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return UserRow{}, fmt.Errorf("finding chat user: %w", err)
+	}
+	if email == nil {
+		return UserRow{}, ErrNotLinked
+	}
+
+	addr, err := email(ctx)
+	if err != nil {
+		s.logger.Warn("looking up chat user email", "provider", provider, "chat_user", externalID, "error", err)
+		return UserRow{}, ErrNotLinked
+	}
+	if addr == "" {
+		return UserRow{}, ErrNotLinked
+	}
+	row, err = s.store.FindByEmail(ctx, addr)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return UserRow{}, ErrNotLinked
+	} else if err != nil {
+		return UserRow{}, fmt.Errorf("finding user by email: %w", err)
+	}
+
+	// Remember the match unless the user is already linked to another account.
+	if chatID(row, provider) == nil {
+		if err := s.store.SetChatID(ctx, row.ID, provider, &externalID); err != nil {
+			s.logger.Warn("saving chat link", "provider", provider, "user_id", row.ID, "error", err)
+		}
+	}
+	return row, nil
+}
+
+// CreateLinkCode issues a one-time code the user types into chat as
+// `/nightowl link <code>` to prove they own that chat account.
+func (s *Service) CreateLinkCode(ctx context.Context, userID uuid.UUID) (LinkCodeResponse, error) {
+	code, err := newLinkCode()
+	if err != nil {
+		return LinkCodeResponse{}, fmt.Errorf("generating link code: %w", err)
+	}
+	expiresAt := time.Now().Add(linkCodeTTL).UTC()
+	if err := s.store.ReplaceLinkCode(ctx, userID, hashLinkCode(code), expiresAt); err != nil {
+		return LinkCodeResponse{}, err
+	}
+	return LinkCodeResponse{Code: code, ExpiresAt: expiresAt, Command: "/nightowl link " + code}, nil
+}
+
+// LinkChatAccount completes the handshake: the chat account that presents
+// a valid code is linked to the user who created it.
+func (s *Service) LinkChatAccount(ctx context.Context, provider, externalID, code string) (UserRow, error) {
+	if _, err := chatIDColumn(provider); err != nil {
+		return UserRow{}, err
+	}
+	userID, err := s.store.ConsumeLinkCode(ctx, hashLinkCode(code))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return UserRow{}, ErrInvalidLinkCode
+	} else if err != nil {
+		return UserRow{}, fmt.Errorf("consuming link code: %w", err)
+	}
+	if err := s.store.SetChatID(ctx, userID, provider, &externalID); err != nil {
+		return UserRow{}, err
+	}
+	return s.store.Get(ctx, userID)
+}
+
+// UnlinkChatAccount removes a user's link to a chat provider.
+func (s *Service) UnlinkChatAccount(ctx context.Context, userID uuid.UUID, provider string) error {
+	return s.store.SetChatID(ctx, userID, provider, nil)
+}
+
+// SyncChatLinks links every unlinked active user whose email the provider
+// knows. lookup is the provider's LookupUser. It returns how many users
+// were linked.
+func (s *Service) SyncChatLinks(ctx context.Context, provider string,
+	lookup func(ctx context.Context, email string) (string, error)) (int, error) {
+	users, err := s.store.ListUnlinked(ctx, provider)
+	if err != nil {
+		return 0, err
+	}
+	linked := 0
+	for _, u := range users {
+		externalID, err := lookup(ctx, u.Email)
+		if err != nil || externalID == "" {
+			s.logger.Debug("chat user not found by email", "provider", provider, "user_id", u.ID, "error", err)
+			continue
+		}
+		if err := s.store.SetChatID(ctx, u.ID, provider, &externalID); err != nil {
+			return linked, err
+		}
+		linked++
+	}
+	return linked, nil
+}
+
+func chatID(u UserRow, provider string) *string {
+	if provider == ChatMattermost {
+		return u.MattermostUserID
+	}
+	return u.SlackUserID
+}
@@ -0,0 +1,63 @@
+package user
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wisbric/core/pkg/auth"
+)
+
+func TestHasRole(t *testing.T) {
+	tests := []struct {
+		role, min string
+		want      bool
+	}{
+		{auth.RoleAdmin, auth.RoleEngineer, true},
+		{auth.RoleEngineer, auth.RoleEngineer, true},
+		{auth.RoleReadonly, auth.RoleEngineer, false},
+		{auth.RoleManager, auth.RoleAdmin, false},
+		{"unknown", auth.RoleReadonly, false},
+	}
+	for _, tt := range tests {
+		u := UserRow{Role: tt.role}
+		if got := u.HasRole(tt.min); got != tt.want {
+			t.Errorf("HasRole(%q >= %q) = %v, want %v", tt.role, tt.min, got, tt.want)
+		}
+	}
+}
+
+func TestNewLinkCode(t *testing.T) {
+	code, err := newLinkCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != 9 || code[4] != '-' {
+		t.Fatalf("unexpected code format %q", code)
+	}
+	for _, c := range strings.ReplaceAll(code, "-", "") {
+		if !strings.ContainsRune(linkCodeAlphabet, c) {
+			t.Errorf("code %q contains %q outside the alphabet", code, c)
+		}
+	}
+}
+
+func TestHashLinkCode_Normalizes(t *testing.T) {
+	want := hashLinkCode("ABCD-EFGH")
+	for _, in := range []string{"abcd-efgh", " ABCDEFGH ", "abcdEFGH"} {
+		if got := hashLinkCode(in); got != want {
+			t.Errorf("hashLinkCode(%q) differs from canonical form", in)
+		}
+	}
+}
+
+func TestChatIDColumn(t *testing.T) {
+	if col, _ := chatIDColumn(ChatSlack); col != "slack_user_id" {
+		t.Errorf("slack column = %q", col)
+	}
+	if col, _ := chatIDColumn(ChatMattermost); col != "mattermost_user_id" {
+		t.Errorf("mattermost column = %q", col)
+	}
+	if _, err := chatIDColumn("teams"); err != ErrUnknownChatProvider {
+		t.Errorf("expected ErrUnknownChatProvider, got %v", err)
+	}
+}
@@ -15,18 +15,21 @@ import (
 	"github.com/wisbric/core/pkg/httpserver"
 
 	"github.com/wisbric/nightowl/internal/audit"
+	"github.com/wisbric/nightowl/pkg/messaging"
 	"github.com/wisbric/nightowl/pkg/tenant"
 )
 
 // Handler provides HTTP handlers for the users API.
 type Handler struct {
-	logger *slog.Logger
-	audit  *audit.Writer
+	logger   *slog.Logger
+	audit    *audit.Writer
+	registry *messaging.Registry
 }
 
-// NewHandler creates a user Handler.
-func NewHandler(logger *slog.Logger, audit *audit.Writer) *Handler {
-	return &Handler{logger: logger, audit: audit}
+// NewHandler creates a user Handler. The messaging registry backs chat
+// account sync and may be nil.
+func NewHandler(logger *slog.Logger, audit *audit.Writer, registry *messaging.Registry) *Handler {
+	return &Handler{logger: logger, audit: audit, registry: registry}
 }
 
 // Routes returns a chi.Router with all user routes mounted.
@@ -34,6 +37,7 @@ func (h *Handler) Routes() chi.Router {
 	r := chi.NewRouter()
 	r.Post("/", h.handleCreate)
 	r.Get("/", h.handleList)
+	r.With(auth.RequireRole(auth.RoleAdmin)).Post("/chat-links/sync", h.handleSyncChatLinks)
 	r.Route("/{id}", func(r chi.Router) {
 		r.Get("/", h.handleGet)
 		r.Put("/", h.handleUpdate)
@@ -226,3 +230,114 @@ func (h *Handler) handleUpdatePreferences(w http.ResponseWriter, r *http.Request
 	w.WriteHeader(http.StatusOK)
 	w.Write(body) //nolint:errcheck
 }
+
+// =====================
+// Chat account links
+// =====================
+
+// ChatLinkRoutes returns a chi.Router for the caller's /user/chat-links endpoints.
+func (h *Handler) ChatLinkRoutes() chi.Router {
+	r := chi.NewRouter()
+	r.Get("/", h.handleGetChatLinks)
+	r.Post("/code", h.handleCreateLinkCode)
+	r.Delete("/{provider}", h.handleUnlinkChat)
+	return r
+}
+
+// currentUserID returns the authenticated user's ID, or responds 401.
+func currentUserID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id := auth.FromContext(r.Context())
+	if id == nil || id.UserID == nil {
+		httpserver.RespondError(w, http.StatusUnauthorized, "unauthorized", "user authentication required")
+		return uuid.UUID{}, false
+	}
+	return *id.UserID, true
+}
+
+func (h *Handler) handleGetChatLinks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.service(r).Get(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("getting chat links", "error", err, "user_id", userID)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to get chat links")
+		return
+	}
+
+	httpserver.Respond(w, http.StatusOK, map[string]*string{
+		ChatSlack:      resp.SlackUserID,
+		ChatMattermost: resp.MattermostUserID,
+	})
+}
+
+func (h *Handler) handleCreateLinkCode(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := h.service(r).CreateLinkCode(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("creating chat link code", "error", err, "user_id", userID)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to create link code")
+		return
+	}
+
+	httpserver.Respond(w, http.StatusCreated, resp)
+}
+
+func (h *Handler) handleUnlinkChat(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+	provider := chi.URLParam(r, "provider")
+
+	if err := h.service(r).UnlinkChatAccount(r.Context(), userID, provider); err != nil {
+		if errors.Is(err, ErrUnknownChatProvider) {
+			httpserver.RespondError(w, http.StatusBadRequest, "bad_request", err.Error())
+			return
+		}
+		h.logger.Error("unlinking chat account", "error", err, "user_id", userID)
+		httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to unlink chat account")
+		return
+	}
+
+	if h.audit != nil {
+		detail, _ := json.Marshal(map[string]string{"provider": provider})
+		h.audit.LogFromRequest(r, "unlink_chat_account", "user", userID, detail)
+	}
+
+	httpserver.Respond(w, http.StatusNoContent, nil)
+}
+
+// handleSyncChatLinks links unlinked users by looking up their email with
+// every registered messaging provider.
+func (h *Handler) handleSyncChatLinks(w http.ResponseWriter, r *http.Request) {
+	svc := h.service(r)
+	linked := map[string]int{}
+	if h.registry != nil {
+		for _, p := range h.registry.All() {
+			if _, err := chatIDColumn(p.Name()); err != nil {
+				continue
+			}
+			n, err := svc.SyncChatLinks(r.Context(), p.Name(), p.LookupUser)
+			if err != nil {
+				h.logger.Error("syncing chat links", "error", err, "provider", p.Name())
+				httpserver.RespondError(w, http.StatusInternalServerError, "internal_error", "failed to sync chat links")
+				return
+			}
+			linked[p.Name()] = n
+		}
+	}
+
+	if h.audit != nil {
+		detail, _ := json.Marshal(linked)
+		h.audit.LogFromRequest(r, "sync_chat_links", "user", uuid.Nil, detail)
+	}
+
+	httpserver.Respond(w, http.StatusOK, map[string]any{"linked": linked})
+}
@@ -21,36 +21,38 @@ func NewStore(dbtx db.DBTX) *Store {
 	return &Store{dbtx: dbtx}
 }
 
-const userColumns = `id, external_id, email, display_name, timezone, phone, slack_user_id, role, is_active, created_at, updated_at`
+const userColumns = `id, external_id, email, display_name, timezone, phone, slack_user_id, mattermost_user_id, role, is_active, created_at, updated_at`
 
 // UserRow represents a row returned from the users table.
 type UserRow struct {
-	ID          uuid.UUID
-	ExternalID  string
-	Email       string
-	DisplayName string
-	Timezone    string
-	Phone       *string
-	SlackUserID *string
-	Role        string
-	IsActive    bool
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID               uuid.UUID
+	ExternalID       string
+	Email            string
+	DisplayName      string
+	Timezone         string
+	Phone            *string
+	SlackUserID      *string
+	MattermostUserID *string
+	Role             string
+	IsActive         bool
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
 }
 
 // ToResponse converts a UserRow to a Response DTO.
 func (u *UserRow) ToResponse() Response {
 	return Response{
-		ID:          u.ID,
-		Email:       u.Email,
-		DisplayName: u.DisplayName,
-		Role:        u.Role,
-		Timezone:    u.Timezone,
-		Phone:       u.Phone,
-		SlackUserID: u.SlackUserID,
-		IsActive:    u.IsActive,
-		CreatedAt:   u.CreatedAt,
-		UpdatedAt:   u.UpdatedAt,
+		ID:               u.ID,
+		Email:            u.Email,
+		DisplayName:      u.DisplayName,
+		Role:             u.Role,
+		Timezone:         u.Timezone,
+		Phone:            u.Phone,
+		SlackUserID:      u.SlackUserID,
+		MattermostUserID: u.MattermostUserID,
+		IsActive:         u.IsActive,
+		CreatedAt:        u.CreatedAt,
+		UpdatedAt:        u.UpdatedAt,
 	}
 }
 
@@ -59,7 +61,7 @@ func scanUserRow(row pgx.Row) (UserRow, error) {
 	var u UserRow
 	err := row.Scan(
 		&u.ID, &u.ExternalID, &u.Email, &u.DisplayName, &u.Timezone,
-		&u.Phone, &u.SlackUserID, &u.Role, &u.IsActive, &u.CreatedAt, &u.UpdatedAt,
+		&u.Phone, &u.SlackUserID, &u.MattermostUserID, &u.Role, &u.IsActive, &u.CreatedAt, &u.UpdatedAt,
 	)
 	return u, err
 }
@@ -72,7 +74,7 @@ func scanUserRows(rows pgx.Rows) ([]UserRow, error) {
 		var u UserRow
 		if err := rows.Scan(
 			&u.ID, &u.ExternalID, &u.Email, &u.DisplayName, &u.Timezone,
-			&u.Phone, &u.SlackUserID, &u.Role, &u.IsActive, &u.CreatedAt, &u.UpdatedAt,
+			&u.Phone, &u.SlackUserID, &u.MattermostUserID, &u.Role, &u.IsActive, &u.CreatedAt, &u.UpdatedAt,
 		); err != nil {
 			return nil, fmt.Errorf("scanning user row: %w", err)
 		}
@@ -165,17 +167,24 @@ func (s *Store) Deactivate(ctx context.Context, id uuid.UUID) error {
 		return fmt.Errorf("deactivating roster memberships: %w", err)
 	}
 
-	// Remove from current and future unlocked schedule weeks (preserve locked/past weeks for history).
+	// Remove from current and future unlocked schedule shifts (preserve locked/past shifts for history).
 	if _, err := s.dbtx.Exec(ctx,
-		`UPDATE roster_schedule SET primary_user_id = NULL WHERE primary_user_id = $1 AND is_locked = false AND week_start >= CURRENT_DATE`,
+		`UPDATE roster_schedule SET primary_user_id = NULL WHERE primary_user_id = $1 AND is_locked = false AND shift_end > now()`,
 		id); err != nil {
 		return fmt.Errorf("clearing primary schedule assignments: %w", err)
 	}
 	if _, err := s.dbtx.Exec(ctx,
-		`UPDATE roster_schedule SET secondary_user_id = NULL WHERE secondary_user_id = $1 AND is_locked = false AND week_start >= CURRENT_DATE`,
+		`UPDATE roster_schedule SET secondary_user_id = NULL WHERE secondary_user_id = $1 AND is_locked = false AND shift_end > now()`,
 		id); err != nil {
 		return fmt.Errorf("clearing secondary schedule assignments: %w", err)
 	}
 
+	// Drop from layer rotations; the remaining members keep their order.
+	if _, err := s.dbtx.Exec(ctx,
+		`DELETE FROM roster_layer_members WHERE user_id = $1`,
+		id); err != nil {
+		return fmt.Errorf("removing layer rotation memberships: %w", err)
+	}
+
 	return nil
 }
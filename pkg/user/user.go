@@ -28,14 +28,15 @@ type UpdateRequest struct {
 
 // Response is the JSON response for a single user.
 type Response struct {
-	ID          uuid.UUID `json:"id"`
-	Email       string    `json:"email"`
-	DisplayName string    `json:"display_name"`
-	Role        string    `json:"role"`
-	Timezone    string    `json:"timezone"`
-	Phone       *string   `json:"phone,omitempty"`
-	SlackUserID *string   `json:"slack_user_id,omitempty"`
-	IsActive    bool      `json:"is_active"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID               uuid.UUID `json:"id"`
+	Email            string    `json:"email"`
+	DisplayName      string    `json:"display_name"`
+	Role             string    `json:"role"`
+	Timezone         string    `json:"timezone"`
+	Phone            *string   `json:"phone,omitempty"`
+	SlackUserID      *string   `json:"slack_user_id,omitempty"`
+	MattermostUserID *string   `json:"mattermost_user_id,omitempty"`
+	IsActive         bool      `json:"is_active"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
 }
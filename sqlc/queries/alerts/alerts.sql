@@ -12,9 +12,9 @@ SELECT * FROM alerts ORDER BY created_at DESC LIMIT $1 OFFSET $2;
 -- name: CreateAlert :one
 INSERT INTO alerts (
     fingerprint, status, severity, source, title, description,
-    labels, annotations, service_id, escalation_policy_id
+    labels, annotations, service_id, escalation_policy_id, integration_id
 )
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 RETURNING *;
 
 -- name: AcknowledgeAlert :one
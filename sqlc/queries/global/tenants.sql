@@ -5,7 +5,8 @@ SELECT * FROM public.tenants WHERE id = $1;
 SELECT * FROM public.tenants WHERE slug = $1;
 
 -- name: ListTenants :many
-SELECT * FROM public.tenants ORDER BY name;
+-- Only active tenants; background workers skip suspended and deleted ones.
+SELECT * FROM public.tenants WHERE status = 'active' ORDER BY name;
 
 -- name: CreateTenant :one
 INSERT INTO public.tenants (name, slug, config)
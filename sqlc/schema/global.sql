@@ -4,7 +4,12 @@ CREATE TABLE public.tenants (
     slug        TEXT NOT NULL UNIQUE,
     config      JSONB NOT NULL DEFAULT '{}',
     created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
-    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    status      TEXT NOT NULL DEFAULT 'active'
+        CHECK (status IN ('active', 'suspended', 'pending_deletion')),
+    suspended_at TIMESTAMPTZ,
+    deleted_at  TIMESTAMPTZ,
+    purge_after TIMESTAMPTZ
 );
 
 CREATE TABLE public.api_keys (
@@ -17,7 +22,8 @@ CREATE TABLE public.api_keys (
     scopes      TEXT[] NOT NULL DEFAULT '{}',
     last_used   TIMESTAMPTZ,
     expires_at  TIMESTAMPTZ,
-    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    allowed_cidrs TEXT[] NOT NULL DEFAULT '{}'
 );
 
 CREATE TABLE public.local_admins (
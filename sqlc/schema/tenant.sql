@@ -6,6 +6,7 @@ CREATE TABLE users (
     timezone        TEXT NOT NULL DEFAULT 'UTC',
     phone           TEXT,
     slack_user_id   TEXT,
+    mattermost_user_id TEXT,
     role            TEXT NOT NULL DEFAULT 'engineer',
     is_active       BOOLEAN NOT NULL DEFAULT true,
     password_hash   TEXT,
@@ -122,6 +123,10 @@ CREATE TABLE rosters (
     handoff_day             INTEGER NOT NULL DEFAULT 1,
     schedule_weeks_ahead    INTEGER NOT NULL DEFAULT 12,
     max_consecutive_weeks   INTEGER NOT NULL DEFAULT 2,
+    rotation_unit           TEXT NOT NULL DEFAULT 'weeks'
+        CHECK (rotation_unit IN ('hours', 'days', 'weeks')),
+    rotation_lengths        INTEGER[] NOT NULL DEFAULT '{1}'
+        CHECK (cardinality(rotation_lengths) > 0 AND 0 < ALL(rotation_lengths)),
     is_follow_the_sun       BOOLEAN DEFAULT false,
     linked_roster_id        UUID REFERENCES rosters(id),
     escalation_policy_id    UUID REFERENCES escalation_policies(id),
@@ -148,6 +153,8 @@ CREATE TABLE roster_schedule (
     roster_id         UUID NOT NULL REFERENCES rosters(id) ON DELETE CASCADE,
     week_start        DATE NOT NULL,
     week_end          DATE NOT NULL,
+    shift_start       TIMESTAMPTZ NOT NULL,
+    shift_end         TIMESTAMPTZ NOT NULL,
     primary_user_id   UUID REFERENCES users(id),
     secondary_user_id UUID REFERENCES users(id),
     is_locked         BOOLEAN NOT NULL DEFAULT false,
@@ -155,9 +162,9 @@ CREATE TABLE roster_schedule (
     notes             TEXT,
     created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
     updated_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
-    UNIQUE(roster_id, week_start),
+    UNIQUE(roster_id, shift_start),
     CHECK (primary_user_id IS DISTINCT FROM secondary_user_id),
-    CHECK (week_end > week_start)
+    CHECK (shift_end > shift_start)
 );
 
 CREATE TABLE roster_overrides (
@@ -172,6 +179,118 @@ CREATE TABLE roster_overrides (
     CHECK (end_at > start_at)
 );
 
+CREATE TABLE roster_layers (
+    id               UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    roster_id        UUID NOT NULL REFERENCES rosters(id) ON DELETE CASCADE,
+    name             TEXT NOT NULL,
+    priority         INTEGER NOT NULL DEFAULT 0,
+    rotation_unit    TEXT NOT NULL DEFAULT 'weeks'
+        CHECK (rotation_unit IN ('hours', 'days', 'weeks')),
+    rotation_lengths INTEGER[] NOT NULL DEFAULT '{1}'
+        CHECK (cardinality(rotation_lengths) > 0 AND 0 < ALL(rotation_lengths)),
+    days_of_week     INTEGER[] NOT NULL DEFAULT '{}',
+    start_time       TIME,
+    end_time         TIME,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK ((start_time IS NULL) = (end_time IS NULL))
+);
+
+CREATE TABLE roster_layer_members (
+    layer_id UUID NOT NULL REFERENCES roster_layers(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    user_id  UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (layer_id, position)
+);
+
+CREATE TABLE unavailability_calendars (
+    id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id        UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url            TEXT NOT NULL,
+    last_synced_at TIMESTAMPTZ,
+    last_error     TEXT,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, url)
+);
+
+CREATE TABLE user_unavailability (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id      UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    start_at     TIMESTAMPTZ NOT NULL,
+    end_at       TIMESTAMPTZ NOT NULL,
+    reason       TEXT,
+    source       TEXT NOT NULL DEFAULT 'manual',
+    calendar_id  UUID REFERENCES unavailability_calendars(id) ON DELETE CASCADE,
+    external_uid TEXT,
+    created_by   UUID REFERENCES users(id),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (end_at > start_at)
+);
+
+CREATE TABLE shift_swap_requests (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    roster_id       UUID NOT NULL REFERENCES rosters(id) ON DELETE CASCADE,
+    kind            TEXT NOT NULL DEFAULT 'cover' CHECK (kind IN ('cover', 'swap')),
+    role            TEXT NOT NULL DEFAULT 'primary' CHECK (role IN ('primary', 'secondary')),
+    requester_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_user_id  UUID REFERENCES users(id) ON DELETE CASCADE,
+    start_at        TIMESTAMPTZ NOT NULL,
+    end_at          TIMESTAMPTZ NOT NULL,
+    swap_start_at   TIMESTAMPTZ,
+    message         TEXT,
+    status          TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'accepted', 'declined', 'cancelled', 'expired')),
+    responder_id    UUID REFERENCES users(id) ON DELETE SET NULL,
+    responded_at    TIMESTAMPTZ,
+    override_id     UUID REFERENCES roster_overrides(id) ON DELETE SET NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CHECK (end_at > start_at)
+);
+
+CREATE INDEX idx_shift_swap_requests_roster ON shift_swap_requests(roster_id, status, start_at);
+CREATE INDEX idx_shift_swap_requests_target ON shift_swap_requests(target_user_id) WHERE status = 'pending';
+
+CREATE TABLE chat_link_codes (
+    code_hash   TEXT PRIMARY KEY,
+    user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at  TIMESTAMPTZ NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_chat_link_codes_user ON chat_link_codes(user_id);
+
+-- Named webhook integrations: each sender gets its own ingest token, source
+-- type and defaults, so it can be identified, tracked and revoked on its own.
+CREATE TABLE webhook_integrations (
+    id                   UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name                 TEXT NOT NULL UNIQUE,
+    source_type          TEXT NOT NULL,
+    token_hash           TEXT NOT NULL UNIQUE,
+    token_prefix         TEXT NOT NULL,
+    enabled              BOOLEAN NOT NULL DEFAULT true,
+    default_labels       JSONB NOT NULL DEFAULT '{}',
+    service_id           UUID REFERENCES services(id) ON DELETE SET NULL,
+    escalation_policy_id UUID REFERENCES escalation_policies(id) ON DELETE SET NULL,
+    request_count        BIGINT NOT NULL DEFAULT 0,
+    alert_count          BIGINT NOT NULL DEFAULT 0,
+    error_count          BIGINT NOT NULL DEFAULT 0,
+    last_received_at     TIMESTAMPTZ,
+    last_error           TEXT,
+    last_error_at        TIMESTAMPTZ,
+    created_by           UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE alerts
+    ADD COLUMN integration_id UUID REFERENCES webhook_integrations(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_alerts_integration ON alerts(integration_id) WHERE integration_id IS NOT NULL;
+
+ALTER TABLE webhook_integrations ADD COLUMN mapping JSONB;
+
 CREATE TABLE escalation_events (
     id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     alert_id        UUID NOT NULL REFERENCES alerts(id) ON DELETE CASCADE,
@@ -197,6 +316,29 @@ CREATE TABLE audit_log (
     created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
 );
 
+ALTER TABLE audit_log
+    ADD COLUMN seq       BIGINT,
+    ADD COLUMN prev_hash BYTEA,
+    ADD COLUMN hash      BYTEA;
+
+CREATE UNIQUE INDEX idx_audit_log_seq ON audit_log(seq);
+
+CREATE TABLE audit_sinks (
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name          TEXT NOT NULL UNIQUE,
+    kind          TEXT NOT NULL,
+    config        JSONB NOT NULL DEFAULT '{}',
+    enabled       BOOLEAN NOT NULL DEFAULT true,
+    delivered_seq BIGINT NOT NULL DEFAULT 0,
+    delivered_at  TIMESTAMPTZ,
+    failures      INTEGER NOT NULL DEFAULT 0,
+    last_error    TEXT,
+    last_error_at TIMESTAMPTZ,
+    created_by    UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
 CREATE TABLE message_mappings (
     id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     alert_id    UUID REFERENCES alerts(id) ON DELETE CASCADE,
@@ -258,3 +400,146 @@ CREATE TABLE oidc_config (
     created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
     updated_at      TIMESTAMPTZ NOT NULL DEFAULT now()
 );
+
+CREATE TABLE noise_reports (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    period_start    TIMESTAMPTZ NOT NULL,
+    period_end      TIMESTAMPTZ NOT NULL UNIQUE,
+    report          JSONB NOT NULL,
+    delivered_via   TEXT,
+    delivered_at    TIMESTAMPTZ,
+    delivery_error  TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE ingest_queue (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    source          TEXT NOT NULL,
+    integration_id  UUID REFERENCES webhook_integrations(id) ON DELETE SET NULL,
+    payload         BYTEA NOT NULL,
+    query           TEXT NOT NULL DEFAULT '',
+    origin          JSONB NOT NULL DEFAULT '{}',
+    status          TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'dead')),
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    processed       INTEGER NOT NULL DEFAULT 0,
+    alert_ids       UUID[] NOT NULL DEFAULT '{}',
+    last_error      TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    received_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_ingest_queue_due ON ingest_queue (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_ingest_queue_dead ON ingest_queue (received_at DESC) WHERE status = 'dead';
+
+ALTER TABLE webhook_integrations
+    ADD COLUMN payload_retention_days INTEGER NOT NULL DEFAULT 7
+        CHECK (payload_retention_days BETWEEN 0 AND 90);
+
+ALTER TABLE ingest_queue ADD COLUMN headers JSONB NOT NULL DEFAULT '{}';
+
+CREATE TABLE webhook_payloads (
+    id             UUID PRIMARY KEY,
+    source         TEXT NOT NULL,
+    integration_id UUID REFERENCES webhook_integrations(id) ON DELETE SET NULL,
+    payload        BYTEA NOT NULL,
+    query          TEXT NOT NULL DEFAULT '',
+    headers        JSONB NOT NULL DEFAULT '{}',
+    alert_ids      UUID[] NOT NULL DEFAULT '{}',
+    received_at    TIMESTAMPTZ NOT NULL,
+    processed_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_webhook_payloads_received ON webhook_payloads (received_at DESC, id DESC);
+CREATE INDEX idx_webhook_payloads_integration ON webhook_payloads (integration_id, received_at DESC);
+CREATE INDEX idx_webhook_payloads_alert_ids ON webhook_payloads USING GIN (alert_ids);
+
+CREATE TABLE heartbeat_monitors (
+    id                   UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name                 TEXT NOT NULL UNIQUE,
+    description          TEXT NOT NULL DEFAULT '',
+    token_hash           TEXT NOT NULL UNIQUE,
+    token_prefix         TEXT NOT NULL,
+    interval_seconds     INTEGER NOT NULL CHECK (interval_seconds BETWEEN 60 AND 604800),
+    grace_seconds        INTEGER NOT NULL DEFAULT 0 CHECK (grace_seconds BETWEEN 0 AND 86400),
+    severity             TEXT NOT NULL DEFAULT 'critical',
+    labels               JSONB NOT NULL DEFAULT '{}',
+    service_id           UUID REFERENCES services(id) ON DELETE SET NULL,
+    escalation_policy_id UUID REFERENCES escalation_policies(id) ON DELETE SET NULL,
+    enabled              BOOLEAN NOT NULL DEFAULT true,
+    expected_by          TIMESTAMPTZ NOT NULL,
+    last_ping_at         TIMESTAMPTZ,
+    ping_count           BIGINT NOT NULL DEFAULT 0,
+    down_since           TIMESTAMPTZ,
+    alert_id             UUID REFERENCES alerts(id) ON DELETE SET NULL,
+    chat_messages        JSONB NOT NULL DEFAULT '[]',
+    created_by           UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_heartbeat_monitors_expected ON heartbeat_monitors (expected_by) WHERE enabled;
+CREATE INDEX idx_heartbeat_monitors_down ON heartbeat_monitors (down_since) WHERE down_since IS NOT NULL;
+
+CREATE TABLE status_pages (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    slug        TEXT NOT NULL UNIQUE CHECK (slug ~ '^[a-z0-9][a-z0-9-]{0,62}$'),
+    name        TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    public      BOOLEAN NOT NULL DEFAULT false,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE status_components (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    page_id         UUID NOT NULL REFERENCES status_pages(id) ON DELETE CASCADE,
+    service_id      UUID NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+    name            TEXT NOT NULL,
+    description     TEXT NOT NULL DEFAULT '',
+    position        INTEGER NOT NULL DEFAULT 0,
+    status_override TEXT CHECK (status_override IN ('operational', 'degraded_performance',
+                        'partial_outage', 'major_outage', 'under_maintenance')),
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (page_id, service_id)
+);
+
+CREATE TABLE status_incidents (
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    page_id       UUID NOT NULL REFERENCES status_pages(id) ON DELETE CASCADE,
+    title         TEXT NOT NULL,
+    status        TEXT NOT NULL CHECK (status IN ('investigating', 'identified', 'monitoring', 'resolved')),
+    impact        TEXT NOT NULL DEFAULT 'minor' CHECK (impact IN ('none', 'minor', 'major', 'critical')),
+    component_ids UUID[] NOT NULL DEFAULT '{}',
+    created_by    UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    resolved_at   TIMESTAMPTZ
+);
+
+CREATE INDEX idx_status_incidents_page ON status_incidents (page_id, created_at DESC);
+
+CREATE TABLE status_incident_updates (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    incident_id UUID NOT NULL REFERENCES status_incidents(id) ON DELETE CASCADE,
+    status      TEXT NOT NULL CHECK (status IN ('investigating', 'identified', 'monitoring', 'resolved')),
+    body        TEXT NOT NULL,
+    notify      BOOLEAN NOT NULL DEFAULT true,
+    notified_at TIMESTAMPTZ,
+    created_by  UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_status_incident_updates_incident ON status_incident_updates (incident_id, created_at);
+CREATE INDEX idx_status_incident_updates_pending ON status_incident_updates (created_at)
+    WHERE notify AND notified_at IS NULL;
+
+CREATE TABLE status_subscribers (
+    id                   UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    page_id              UUID NOT NULL REFERENCES status_pages(id) ON DELETE CASCADE,
+    email                TEXT NOT NULL,
+    confirmed_at         TIMESTAMPTZ,
+    confirmation_sent_at TIMESTAMPTZ,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (page_id, email)
+);
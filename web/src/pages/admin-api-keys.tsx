@@ -13,17 +13,19 @@ import { Dialog, DialogHeader, DialogTitle, DialogContent, DialogFooter } from "
 import { LoadingSpinner } from "@/components/ui/loading-spinner";
 import { EmptyState } from "@/components/ui/empty-state";
 import { formatRelativeTime } from "@/lib/utils";
-import type { ApiKeysResponse, ApiKeyCreateResponse } from "@/types/api";
+import type { ApiKeysResponse, ApiKeyCreateResponse, ApiKeyScopesResponse } from "@/types/api";
 import { Plus, Trash2, Copy, AlertTriangle } from "lucide-react";
 
 interface KeyForm {
   description: string;
   role: string;
+  scopes: string[];
 }
 
 const emptyForm: KeyForm = {
   description: "",
   role: "engineer",
+  scopes: [],
 };
 
 const ROLES = ["admin", "manager", "engineer", "readonly"] as const;
@@ -43,6 +45,12 @@ export function AdminApiKeysPage() {
     queryFn: () => api.get<ApiKeysResponse>("/api-keys"),
   });
 
+  const { data: scopeData } = useQuery({
+    queryKey: ["api-key-scopes"],
+    queryFn: () => api.get<ApiKeyScopesResponse>("/api-keys/scopes"),
+  });
+  const scopes = scopeData?.scopes ?? [];
+
   const createMutation = useMutation({
     mutationFn: (data: KeyForm) => api.post<ApiKeyCreateResponse>("/api-keys", data),
     onSuccess: (result) => {
@@ -79,6 +87,13 @@ export function AdminApiKeysPage() {
     createMutation.mutate(form);
   }
 
+  function toggleScope(scope: string, checked: boolean) {
+    setForm({
+      ...form,
+      scopes: checked ? [...form.scopes, scope] : form.scopes.filter((s) => s !== scope),
+    });
+  }
+
   function copyToClipboard(text: string) {
     navigator.clipboard.writeText(text).then(() => {
       setCopied(true);
@@ -123,6 +138,7 @@ export function AdminApiKeysPage() {
                   <TableHead>Prefix</TableHead>
                   <TableHead>Description</TableHead>
                   <TableHead>Role</TableHead>
+                  <TableHead>Scopes</TableHead>
                   <TableHead>Last Used</TableHead>
                   <TableHead>Created</TableHead>
                   <TableHead className="w-12"></TableHead>
@@ -136,6 +152,9 @@ export function AdminApiKeysPage() {
                     <TableCell>
                       <Badge variant="outline" className="text-xs capitalize">{key.role}</Badge>
                     </TableCell>
+                    <TableCell className="text-sm font-mono">
+                      {key.scopes.length > 0 ? key.scopes.join(", ") : "role only (legacy)"}
+                    </TableCell>
                     <TableCell className="text-sm text-muted-foreground whitespace-nowrap">
                       {key.last_used ? formatRelativeTime(key.last_used) : "Never"}
                     </TableCell>
@@ -221,6 +240,26 @@ export function AdminApiKeysPage() {
                   ))}
                 </Select>
               </div>
+              <div>
+                <label className="text-sm font-medium">Scopes</label>
+                <p className="text-xs text-muted-foreground mb-2">
+                  Pick at least one. <span className="font-mono">admin</span> grants everything the role allows;
+                  monitoring senders only need <span className="font-mono">webhooks:ingest</span>.
+                </p>
+                <div className="grid grid-cols-2 gap-2">
+                  {scopes.map((scope) => (
+                    <label key={scope} className="flex items-center gap-2 text-sm font-mono">
+                      <input
+                        type="checkbox"
+                        checked={form.scopes.includes(scope)}
+                        onChange={(e) => toggleScope(scope, e.target.checked)}
+                        className="accent-accent"
+                      />
+                      {scope}
+                    </label>
+                  ))}
+                </div>
+              </div>
               {createMutation.isError && (
                 <p className="text-sm text-destructive">
                   Error: {createMutation.error.message}
@@ -229,7 +268,7 @@ export function AdminApiKeysPage() {
             </DialogContent>
             <DialogFooter>
               <Button type="button" variant="outline" onClick={closeCreateDialog}>Cancel</Button>
-              <Button type="submit" disabled={createMutation.isPending}>
+              <Button type="submit" disabled={createMutation.isPending || form.scopes.length === 0}>
                 {createMutation.isPending ? "Creating..." : "Create Key"}
               </Button>
             </DialogFooter>
@@ -372,6 +372,11 @@ export interface ApiKeyCreateResponse extends ApiKeyDetail {
   raw_key: string;
 }
 
+export interface ApiKeyScopesResponse {
+  scopes: string[];
+  count: number;
+}
+
 // --- Personal Access Tokens ---
 
 export interface PersonalAccessToken {